@@ -0,0 +1,203 @@
+package idmatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// accountsPageSize is the number of accounts requested at once from the paginated APIs.
+const accountsPageSize = 100
+
+// jiraAccountFields is the JIRA user search API mapping of the account fields, see
+// https://developer.atlassian.com/cloud/jira/platform/rest/v2/api-group-users/
+var jiraAccountFields = map[string]string{
+	"items": "", "id": "accountId", "name": "displayName", "email": "emailAddress",
+	"offset": "startAt", "limit": "maxResults",
+}
+
+// restAccountFields is the default mapping of the account fields for SourceREST.
+var restAccountFields = map[string]string{
+	"items": "", "id": "id", "name": "name", "email": "email", "offset": "", "limit": "",
+}
+
+// accountSource is the signatureSource which pulls the user accounts of an issue tracker, either
+// from the JIRA users API (SourceJIRA) or from a generic REST API (SourceREST). The tracker plays
+// the role of the single repository whose identifier is the tracker URL. Each account with an
+// email becomes a signature; the account ID is the pseudo commit hash.
+//
+// The response fields are mapped by the "items", "id", "name" and "email" dotted JSON paths:
+// "items" points to the array of accounts, the blank value means the whole response.
+// If the "offset" and "limit" query parameter names are mapped, the accounts are requested page by
+// page until the page is not full.
+type accountSource struct {
+	client *http.Client
+	kind   SourceKind
+	url    string
+	repo   string
+	token  string
+	fields map[string]string
+	opts   ExtractionOptions
+}
+
+func newAccountSource(kind SourceKind, opts ExtractionOptions) (*accountSource, error) {
+	if opts.AccountsURL == "" {
+		return nil, fmt.Errorf("the accounts URL must be specified for the %s source", kind)
+	}
+	var defaults map[string]string
+	endpoint := opts.AccountsURL
+	switch kind {
+	case SourceJIRA:
+		defaults = jiraAccountFields
+		endpoint = strings.TrimSuffix(endpoint, "/") + "/rest/api/2/users/search"
+	case SourceREST:
+		defaults = restAccountFields
+	default:
+		return nil, fmt.Errorf("unsupported accounts source: %s", kind)
+	}
+	if _, err := url.Parse(endpoint); err != nil {
+		return nil, err
+	}
+	fields := map[string]string{}
+	for key, val := range defaults {
+		fields[key] = val
+	}
+	for key, val := range opts.AccountFields {
+		if _, exists := defaults[key]; !exists {
+			return nil, fmt.Errorf("unsupported account field: %s", key)
+		}
+		fields[key] = val
+	}
+	if (fields["offset"] == "") != (fields["limit"] == "") {
+		return nil, fmt.Errorf("both the offset and the limit account fields must be specified")
+	}
+	return &accountSource{
+		client: http.DefaultClient,
+		kind:   kind,
+		url:    endpoint,
+		repo:   strings.TrimSuffix(opts.AccountsURL, "/"),
+		token:  opts.AccountsToken,
+		fields: fields,
+		opts:   opts,
+	}, nil
+}
+
+func (s *accountSource) Repositories(ctx context.Context) ([]string, error) {
+	return []string{s.repo}, nil
+}
+
+// Signatures requests all the accounts and converts them to signatures. The accounts without
+// an email are skipped.
+func (s *accountSource) Signatures(ctx context.Context, repo string) ([]Signature, error) {
+	signatures := signatureAggregator{}
+	for offset := 0; ; offset += accountsPageSize {
+		accounts, err := s.page(ctx, offset)
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range accounts {
+			email := accountValue(account, s.fields["email"])
+			if email == "" {
+				reporter.Increment("skipped accounts without email")
+				continue
+			}
+			signatures.add(Signature{
+				Repo:   repo,
+				Name:   accountValue(account, s.fields["name"]),
+				Email:  email,
+				Hash:   accountValue(account, s.fields["id"]),
+				Source: s.kind,
+				Role:   RoleAuthor,
+			})
+		}
+		if s.fields["offset"] == "" || len(accounts) < accountsPageSize {
+			break
+		}
+	}
+	return signatures.signatures(), nil
+}
+
+// page requests the accounts starting from offset with retries.
+func (s *accountSource) page(ctx context.Context, offset int) ([]interface{}, error) {
+	endpoint, _ := url.Parse(s.url)
+	if s.fields["offset"] != "" {
+		query := endpoint.Query()
+		query.Set(s.fields["offset"], strconv.Itoa(offset))
+		query.Set(s.fields["limit"], strconv.Itoa(accountsPageSize))
+		endpoint.RawQuery = query.Encode()
+	}
+	var accounts []interface{}
+	name := fmt.Sprintf("listing the accounts of %s from %d", s.repo, offset)
+	err := retryQuery(ctx, s.opts, name, func(ctx context.Context) error {
+		request, err := http.NewRequest(http.MethodGet, endpoint.String(), nil)
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Accept", "application/json")
+		if parts := strings.SplitN(s.token, ":", 2); len(parts) == 2 {
+			request.SetBasicAuth(parts[0], parts[1])
+		} else if s.token != "" {
+			request.Header.Set("Authorization", "Bearer "+s.token)
+		}
+		response, err := s.client.Do(request.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+		if response.StatusCode != http.StatusOK {
+			return httpStatusError{string(s.kind), response.StatusCode, strings.TrimSpace(string(data))}
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		var document interface{}
+		if err := decoder.Decode(&document); err != nil {
+			return err
+		}
+		items, ok := accountPath(document, s.fields["items"]).([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %q is not an array of accounts", s.repo, s.fields["items"])
+		}
+		accounts = items
+		return nil
+	})
+	return accounts, err
+}
+
+// accountPath returns the value of the dotted JSON path in the document or nil if it does not exist.
+func accountPath(document interface{}, path string) interface{} {
+	if path == "" {
+		return document
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := document.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		document = object[key]
+	}
+	return document
+}
+
+// accountValue returns the string or number at the dotted JSON path of the account.
+func accountValue(account interface{}, path string) string {
+	switch value := accountPath(account, path).(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case json.Number:
+		return value.String()
+	default:
+		return ""
+	}
+}
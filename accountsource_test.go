@@ -0,0 +1,102 @@
+package idmatch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJIRAAccountSource(t *testing.T) {
+	req := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req.Equal("/rest/api/2/users/search", r.URL.Path)
+		username, password, ok := r.BasicAuth()
+		req.True(ok)
+		req.Equal("user:secret", username+":"+password)
+		req.Equal("100", r.URL.Query().Get("maxResults"))
+		start, err := strconv.Atoi(r.URL.Query().Get("startAt"))
+		req.NoError(err)
+		var accounts []string
+		for i := start; i < start+accountsPageSize && i <= accountsPageSize; i++ {
+			accounts = append(accounts, fmt.Sprintf(
+				`{"accountId": "id%03d", "displayName": "User %d", "emailAddress": "user%d@google.com"}`,
+				i, i, i))
+		}
+		if start > 0 {
+			accounts = append(accounts, `{"accountId": "app", "displayName": "Bot"}`)
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(accounts, ","))
+	}))
+	defer server.Close()
+
+	_, err := newAccountSource(SourceJIRA, ExtractionOptions{})
+	req.Error(err)
+	_, err = newAccountSource(SourceJIRA, ExtractionOptions{
+		AccountsURL: server.URL, AccountFields: map[string]string{"login": "name"}})
+	req.Error(err)
+	_, err = newAccountSource(SourceGit, ExtractionOptions{AccountsURL: server.URL})
+	req.Error(err)
+	source, err := newAccountSource(SourceJIRA, ExtractionOptions{
+		AccountsURL: server.URL + "/", AccountsToken: "user:secret"})
+	req.NoError(err)
+	repos, err := source.Repositories(context.Background())
+	req.NoError(err)
+	req.Equal([]string{server.URL}, repos)
+	signatures, err := source.Signatures(context.Background(), server.URL)
+	req.NoError(err)
+	req.Len(signatures, accountsPageSize+1)
+	req.Equal(Signature{
+		Repo: server.URL, Name: "User 0", Email: "user0@google.com", Hash: "id000", Source: SourceJIRA,
+		Role: RoleAuthor,
+	}, signatures[0])
+}
+
+func TestRESTAccountSource(t *testing.T) {
+	req := require.New(t)
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req.Equal("Bearer token", r.Header.Get("Authorization"))
+		req.Equal("", r.URL.Query().Get("offset"))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/broken" {
+			fmt.Fprint(w, `{"data": {"users": {}}}`)
+			return
+		}
+		fmt.Fprint(w, `{"data": {"users": [
+			{"id": 2, "login": "bob", "profile": {"email": "bob@google.com"}},
+			{"id": 1, "login": "alice", "profile": {"email": " alice@google.com "}}]}}`)
+	}))
+	defer server.Close()
+
+	fields := map[string]string{"items": "data.users", "name": "login", "email": "profile.email"}
+	_, err := newAccountSource(SourceREST, ExtractionOptions{
+		AccountsURL: server.URL, AccountFields: map[string]string{"offset": "offset"}})
+	req.Error(err)
+	opts := NewExtractionOptions()
+	opts.Source = SourceREST
+	opts.AccountsURL = server.URL + "/users"
+	opts.AccountsToken = "token"
+	opts.AccountFields = fields
+	opts.InitialBackoff = 0
+	signatures, err := findSignatures(nil, "", "", opts)
+	req.NoError(err)
+	req.Equal([]Signature{
+		{opts.AccountsURL, "alice", "alice@google.com", "1", time.Time{}, SourceREST, RoleAuthor, "", nil, nil},
+		{opts.AccountsURL, "bob", "bob@google.com", "2", time.Time{}, SourceREST, RoleAuthor, "", nil, nil},
+	}, signatures)
+
+	opts.AccountsURL = server.URL + "/broken"
+	_, err = findSignatures(nil, "", "", opts)
+	req.EqualError(err, opts.AccountsURL+`: "data.users" is not an array of accounts`)
+}
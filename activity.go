@@ -0,0 +1,222 @@
+package idmatch
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Activity is the behavioral fingerprint of an identity: the histogram of the commit hours in
+// the committer's local time and the histogram of the UTC offsets of the commits.
+type Activity struct {
+	// Hours are the numbers of commits per local hour of the day.
+	Hours [24]int
+	// Offsets map the UTC offsets in minutes to the numbers of commits.
+	Offsets map[int]int
+}
+
+// BehaviorOptions configure the behavioral matching which compares the activities of the candidate
+// identities, see BuildIdentityGraph. The identities with fewer commits are never compared.
+type BehaviorOptions struct {
+	// MinCommits is the minimum number of commits of both identities to compare their activities.
+	MinCommits int
+	// VetoSimilarity is the activity similarity below which the identities are not merged by
+	// the names alone, see IdentityGraph.vetoed.
+	VetoSimilarity float64
+	// MinSimilarity is the activity similarity starting from which the identities connected by
+	// any other evidence receive EvidenceActivity.
+	MinSimilarity float64
+}
+
+// compare returns the similarity of the activities and whether both have enough commits.
+func (opts *BehaviorOptions) compare(a, b *Activity) (float64, bool) {
+	minCommits := opts.MinCommits
+	if minCommits < 1 {
+		minCommits = 1
+	}
+	if a.Commits() < minCommits || b.Commits() < minCommits {
+		return 0, false
+	}
+	return a.Similarity(b), true
+}
+
+// newActivity returns the activity of a single commit at the given local time or nil if the time
+// is unknown.
+func newActivity(when time.Time) *Activity {
+	if when.IsZero() {
+		return nil
+	}
+	_, offset := when.Zone()
+	activity := &Activity{Offsets: map[int]int{offset / 60: 1}}
+	activity.Hours[when.Hour()] = 1
+	return activity
+}
+
+// Commits returns the number of commits in the histograms.
+func (a *Activity) Commits() int {
+	if a == nil {
+		return 0
+	}
+	count := 0
+	for _, hour := range a.Hours {
+		count += hour
+	}
+	return count
+}
+
+// copy returns an independent copy of the activity. nil stays nil.
+func (a *Activity) copy() *Activity {
+	if a == nil {
+		return nil
+	}
+	return mergeActivity(&Activity{}, a)
+}
+
+// mergeActivity adds the histograms of other to a and returns a. If a is nil, the copy of other
+// is returned.
+func mergeActivity(a, other *Activity) *Activity {
+	if other == nil {
+		return a
+	}
+	if a == nil {
+		return other.copy()
+	}
+	for hour, count := range other.Hours {
+		a.Hours[hour] += count
+	}
+	if a.Offsets == nil && len(other.Offsets) > 0 {
+		a.Offsets = map[int]int{}
+	}
+	for offset, count := range other.Offsets {
+		a.Offsets[offset] += count
+	}
+	return a
+}
+
+// Similarity compares two activities from 0 (disjoint) to 1 (identical). It is the mean of
+// the overlaps of the normalized hour histograms, smoothed by one hour to tolerate the jitter,
+// and of the normalized offset histograms. If the offsets are unknown, only the hours count.
+func (a *Activity) Similarity(other *Activity) float64 {
+	if a.Commits() == 0 || other.Commits() == 0 {
+		return 0
+	}
+	smooth := func(activity *Activity) []float64 {
+		result := make([]float64, 24)
+		total := 0.0
+		for hour := range result {
+			result[hour] = float64(activity.Hours[(hour+23)%24] + 2*activity.Hours[hour] +
+				activity.Hours[(hour+1)%24])
+			total += result[hour]
+		}
+		for hour := range result {
+			result[hour] /= total
+		}
+		return result
+	}
+	hours1, hours2 := smooth(a), smooth(other)
+	hours := 0.0
+	for hour := range hours1 {
+		hours += minFloat(hours1[hour], hours2[hour])
+	}
+	total1, total2 := 0, 0
+	for _, count := range a.Offsets {
+		total1 += count
+	}
+	for _, count := range other.Offsets {
+		total2 += count
+	}
+	if total1 == 0 || total2 == 0 {
+		return hours
+	}
+	offsets := 0.0
+	for offset, count := range a.Offsets {
+		offsets += minFloat(float64(count)/float64(total1),
+			float64(other.Offsets[offset])/float64(total2))
+	}
+	return (hours + offsets) / 2
+}
+
+func minFloat(x, y float64) float64 {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// String formats the activity as "<hour>=<count>,...;<offset>=<count>,..." with the non-zero
+// hours and the offsets in minutes in increasing order. nil becomes the empty string.
+func (a *Activity) String() string {
+	if a == nil {
+		return ""
+	}
+	var hours, offsets []string
+	for hour, count := range a.Hours {
+		if count > 0 {
+			hours = append(hours, fmt.Sprintf("%d=%d", hour, count))
+		}
+	}
+	keys := make([]int, 0, len(a.Offsets))
+	for offset := range a.Offsets {
+		keys = append(keys, offset)
+	}
+	sort.Ints(keys)
+	for _, offset := range keys {
+		offsets = append(offsets, fmt.Sprintf("%d=%d", offset, a.Offsets[offset]))
+	}
+	return strings.Join(hours, ",") + ";" + strings.Join(offsets, ",")
+}
+
+// parseActivity is the inverse of Activity.String.
+func parseActivity(value string) (*Activity, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parts := strings.Split(value, ";")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid activity: %s", value)
+	}
+	parse := func(part string, add func(key, count int) error) error {
+		if part == "" {
+			return nil
+		}
+		for _, item := range strings.Split(part, ",") {
+			pair := strings.Split(item, "=")
+			if len(pair) != 2 {
+				return fmt.Errorf("invalid activity item: %s", item)
+			}
+			key, err := strconv.Atoi(pair[0])
+			if err != nil {
+				return err
+			}
+			count, err := strconv.Atoi(pair[1])
+			if err != nil {
+				return err
+			}
+			if err := add(key, count); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	activity := &Activity{Offsets: map[int]int{}}
+	err := parse(parts[0], func(hour, count int) error {
+		if hour < 0 || hour >= 24 {
+			return fmt.Errorf("invalid activity hour: %d", hour)
+		}
+		activity.Hours[hour] += count
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = parse(parts[1], func(offset, count int) error {
+		activity.Offsets[offset] += count
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
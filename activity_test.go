@@ -0,0 +1,66 @@
+package idmatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testActivity sums the activities of the commits at the given times.
+func testActivity(times ...time.Time) *Activity {
+	var activity *Activity
+	for _, when := range times {
+		activity = mergeActivity(activity, newActivity(when))
+	}
+	return activity
+}
+
+func TestActivity(t *testing.T) {
+	req := require.New(t)
+	req.Nil(newActivity(time.Time{}))
+	berlin := time.FixedZone("CET", 3600)
+	activity := testActivity(
+		time.Date(2019, 1, 1, 10, 0, 0, 0, berlin),
+		time.Date(2019, 1, 2, 10, 30, 0, 0, berlin),
+		time.Date(2019, 1, 3, 23, 0, 0, 0, time.UTC))
+	req.Equal(3, activity.Commits())
+	req.Equal(2, activity.Hours[10])
+	req.Equal(map[int]int{0: 1, 60: 2}, activity.Offsets)
+	req.Equal("10=2,23=1;0=1,60=2", activity.String())
+	parsed, err := parseActivity(activity.String())
+	req.NoError(err)
+	req.Equal(activity, parsed)
+	parsed, err = parseActivity("")
+	req.NoError(err)
+	req.Nil(parsed)
+	for _, invalid := range []string{"10=2", "24=1;", "x=1;", "1=1;0", "1=1;0=y"} {
+		_, err = parseActivity(invalid)
+		req.Error(err, invalid)
+	}
+	req.Equal("", (*Activity)(nil).String())
+	req.Equal(0, (*Activity)(nil).Commits())
+
+	merged := mergeActivity(activity.copy(), activity)
+	req.Equal(6, merged.Commits())
+	req.Equal(3, activity.Commits())
+}
+
+func TestActivitySimilarity(t *testing.T) {
+	req := require.New(t)
+	berlin := time.FixedZone("CET", 3600)
+	tokyo := time.FixedZone("JST", 9*3600)
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var european, sameEuropean, asian []time.Time
+	for i := 0; i < 10; i++ {
+		european = append(european, day.AddDate(0, 0, i).Add(10*time.Hour).In(berlin))
+		sameEuropean = append(sameEuropean, day.AddDate(0, 0, i).Add(11*time.Hour).In(berlin))
+		asian = append(asian, day.AddDate(0, 0, i).Add(time.Hour).In(tokyo))
+	}
+	req.InDelta(1, testActivity(european...).Similarity(testActivity(european...)), 1e-9)
+	similar := testActivity(european...).Similarity(testActivity(sameEuropean...))
+	req.True(similar > 0.7, similar)
+	different := testActivity(european...).Similarity(testActivity(asian...))
+	req.True(different < 0.3, different)
+	req.Equal(0.0, testActivity(european...).Similarity(nil))
+}
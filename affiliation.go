@@ -0,0 +1,278 @@
+package idmatch
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// PersonalAffiliation is the organization of the emails at the free email providers.
+const PersonalAffiliation = "personal"
+
+// Organizations maps email domains to the organizations which own them. The subdomains belong
+// to the organization of the parent domain.
+type Organizations map[string]string
+
+// NewOrganizations returns the built-in organizations which map the popular free email
+// providers to PersonalAffiliation.
+func NewOrganizations() Organizations {
+	orgs := Organizations{}
+	for _, domain := range freemailDomains {
+		orgs[domain] = PersonalAffiliation
+	}
+	return orgs
+}
+
+// ReadOrganizations loads the organizations from a CSV file with the columns "domain" and
+// "organization".
+func ReadOrganizations(path string) (orgs Organizations, err error) {
+	orgs = Organizations{}
+	err = readCSVRecords(path, "organizations", []string{"domain", "organization"},
+		func(header map[string]int, record []string) error {
+			domain := strings.ToLower(strings.TrimSpace(record[header["domain"]]))
+			organization := strings.TrimSpace(record[header["organization"]])
+			if domain == "" || organization == "" {
+				return fmt.Errorf("invalid organizations file %s: empty domain or organization in %s",
+					path, strings.Join(record, ","))
+			}
+			orgs[domain] = organization
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// WriteOrganizations saves the organizations to the CSV file which ReadOrganizations loads,
+// sorted by domain.
+func (orgs Organizations) WriteOrganizations(path string) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write([]string{"domain", "organization"}); err != nil {
+		return
+	}
+	domains := make([]string, 0, len(orgs))
+	for domain := range orgs {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		if err = writer.Write([]string{domain, orgs[domain]}); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Merge returns the union of both organization sets, the other organizations take precedence.
+func (orgs Organizations) Merge(other Organizations) Organizations {
+	result := Organizations{}
+	for domain, organization := range orgs {
+		result[domain] = organization
+	}
+	for domain, organization := range other {
+		result[domain] = organization
+	}
+	return result
+}
+
+// resolve finds the organization of the email domain or its closest parent domain. It returns
+// an empty string if the domain is unknown.
+func (orgs Organizations) resolve(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	for domain := strings.ToLower(email[at+1:]); domain != ""; {
+		if organization, exists := orgs[domain]; exists {
+			return organization
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return ""
+}
+
+// Affiliation is the period during which the person committed with the emails of
+// the organization.
+type Affiliation struct {
+	Organization string    `json:"organization"`
+	Since        time.Time `json:"since"`
+	Until        time.Time `json:"until"`
+	// Emails are the sorted emails of the person at the organization.
+	Emails []string `json:"emails"`
+}
+
+// Affiliations infers the affiliation timeline of the person from the domains of the emails and
+// the times they were seen, see Person.AliasHistory. The emails at the unknown domains and
+// the emails which were never seen with a commit time are ignored. The result is ordered by
+// Affiliation.Since, an organization appears once and spans all its emails, so the periods of
+// the different organizations may overlap.
+func (orgs Organizations) Affiliations(person *Person) []Affiliation {
+	byOrganization := map[string]*Affiliation{}
+	for email, span := range person.AliasHistory.Emails {
+		organization := orgs.resolve(email)
+		if organization == "" {
+			continue
+		}
+		affiliation := byOrganization[organization]
+		if affiliation == nil {
+			affiliation = &Affiliation{Organization: organization, Since: span.FirstSeen,
+				Until: span.LastSeen}
+			byOrganization[organization] = affiliation
+		}
+		if span.FirstSeen.Before(affiliation.Since) {
+			affiliation.Since = span.FirstSeen
+		}
+		if span.LastSeen.After(affiliation.Until) {
+			affiliation.Until = span.LastSeen
+		}
+		affiliation.Emails = append(affiliation.Emails, email)
+	}
+	affiliations := make([]Affiliation, 0, len(byOrganization))
+	for _, affiliation := range byOrganization {
+		sort.Strings(affiliation.Emails)
+		affiliations = append(affiliations, *affiliation)
+	}
+	sort.Slice(affiliations, func(i, j int) bool {
+		a, b := affiliations[i], affiliations[j]
+		if !a.Since.Equal(b.Since) {
+			return a.Since.Before(b.Since)
+		}
+		return a.Organization < b.Organization
+	})
+	return affiliations
+}
+
+// affiliationsRecord is the line of the JSON affiliations file.
+type affiliationsRecord struct {
+	ID           int64         `json:"id"`
+	PrimaryName  string        `json:"primary_name"`
+	PrimaryEmail string        `json:"primary_email"`
+	Affiliations []Affiliation `json:"affiliations"`
+}
+
+// parquetAffiliations is the row of the parquet affiliations file.
+type parquetAffiliations struct {
+	ID           int64                `parquet:"name=id, type=INT_64"`
+	PrimaryName  string               `parquet:"name=primary_name, type=UTF8"`
+	PrimaryEmail string               `parquet:"name=primary_email, type=UTF8"`
+	Affiliations []parquetAffiliation `parquet:"name=affiliations, type=LIST"`
+}
+
+type parquetAffiliation struct {
+	Organization string   `parquet:"name=organization, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	Since        int64    `parquet:"name=since, type=TIMESTAMP_MILLIS"`
+	Until        int64    `parquet:"name=until, type=TIMESTAMP_MILLIS"`
+	Emails       []string `parquet:"name=emails, type=LIST, valuetype=UTF8"`
+}
+
+// WriteAffiliations saves the affiliation timelines of the people, see
+// Organizations.Affiliations, one record per person sorted by ID. The file is parquet with
+// the nested list of the affiliations if path ends with ".parquet", otherwise JSON lines.
+// The people without affiliations are skipped.
+func (p People) WriteAffiliations(path string, orgs Organizations) error {
+	var records []affiliationsRecord
+	for _, id := range peopleIDs(p) {
+		person := p[id]
+		affiliations := orgs.Affiliations(person)
+		if len(affiliations) == 0 {
+			continue
+		}
+		records = append(records, affiliationsRecord{
+			person.ID, person.PrimaryName, person.PrimaryEmail, affiliations})
+	}
+	if strings.HasSuffix(path, ".parquet") {
+		return writeAffiliationsParquet(path, records)
+	}
+	return writeAffiliationsJSON(path, records)
+}
+
+func writeAffiliationsJSON(path string, records []affiliationsRecord) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	writer := bufio.NewWriter(file)
+	defer func() {
+		errFlush := writer.Flush()
+		if err == nil {
+			err = errFlush
+		}
+	}()
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err = encoder.Encode(record); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func writeAffiliationsParquet(path string, records []affiliationsRecord) (err error) {
+	file, err := createParquetFile(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	pw, err := writer.NewParquetWriter(file, new(parquetAffiliations), int64(runtime.NumCPU()))
+	if err != nil {
+		return err
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	for _, record := range records {
+		row := parquetAffiliations{ID: record.ID, PrimaryName: record.PrimaryName,
+			PrimaryEmail: record.PrimaryEmail}
+		for _, affiliation := range record.Affiliations {
+			row.Affiliations = append(row.Affiliations, parquetAffiliation{
+				affiliation.Organization, timeToMillis(affiliation.Since),
+				timeToMillis(affiliation.Until), affiliation.Emails})
+		}
+		if err = pw.Write(row); err != nil {
+			return err
+		}
+	}
+	return pw.WriteStop()
+}
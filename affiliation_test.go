@@ -0,0 +1,131 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func newAffiliationTestPeople() People {
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2019, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	return People{
+		1: {ID: 1, PrimaryName: "bob", PrimaryEmail: "bob@corp.com", AliasHistory: AliasHistory{
+			Emails: map[string]AliasSpan{
+				"bob@gmail.com":         {day(1, 1), day(3, 1)},
+				"bob@corp.com":          {day(2, 1), day(6, 1)},
+				"bob@eu.corp.com":       {day(5, 1), day(8, 1)},
+				"bob@unknown.org":       {day(1, 1), day(9, 1)},
+				"robert@googlemail.com": {day(7, 1), day(7, 2)},
+			},
+		}},
+		2: {ID: 2, PrimaryName: "alice", AliasHistory: testAliasHistory(
+			"alice@unknown.org", "alice", day(1, 1), day(1, 2))},
+	}
+}
+
+func TestOrganizationsResolve(t *testing.T) {
+	req := require.New(t)
+	orgs := NewOrganizations().Merge(Organizations{"corp.com": "Corp Inc", "gmail.com": "Google"})
+	req.Equal("Corp Inc", orgs.resolve("bob@corp.com"))
+	req.Equal("Corp Inc", orgs.resolve("bob@eu.Corp.com"))
+	req.Equal("Google", orgs.resolve("bob@gmail.com"))
+	req.Equal(PersonalAffiliation, orgs.resolve("bob@yahoo.com"))
+	req.Equal("", orgs.resolve("bob@othercorp.com"))
+	req.Equal("", orgs.resolve("bob"))
+}
+
+func TestReadOrganizations(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := f.WriteString("domain,organization\nCorp.com,Corp Inc\ngmail.com, Google \n")
+	req.NoError(err)
+	orgs, err := ReadOrganizations(f.Name())
+	req.NoError(err)
+	req.Equal(Organizations{"corp.com": "Corp Inc", "gmail.com": "Google"}, orgs)
+
+	f2, cleanup2 := tempFile(t, "*.csv")
+	defer cleanup2()
+	_, err = f2.WriteString("domain,org\ncorp.com,Corp Inc\n")
+	req.NoError(err)
+	_, err = ReadOrganizations(f2.Name())
+	req.EqualError(err, "invalid organizations file "+f2.Name()+": no organization column")
+
+	f4, cleanup4 := tempFile(t, "*.csv")
+	defer cleanup4()
+	req.NoError(orgs.WriteOrganizations(f4.Name()))
+	data, err := ioutil.ReadFile(f4.Name())
+	req.NoError(err)
+	req.Equal("domain,organization\ncorp.com,Corp Inc\ngmail.com,Google\n", string(data))
+
+	f3, cleanup3 := tempFile(t, "*.csv")
+	defer cleanup3()
+	_, err = f3.WriteString("domain,organization\ncorp.com,\n")
+	req.NoError(err)
+	_, err = ReadOrganizations(f3.Name())
+	req.Error(err)
+}
+
+func TestAffiliations(t *testing.T) {
+	req := require.New(t)
+	people := newAffiliationTestPeople()
+	orgs := NewOrganizations().Merge(Organizations{"corp.com": "Corp Inc"})
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2019, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	req.Equal([]Affiliation{
+		{PersonalAffiliation, day(1, 1), day(7, 2), []string{"bob@gmail.com", "robert@googlemail.com"}},
+		{"Corp Inc", day(2, 1), day(8, 1), []string{"bob@corp.com", "bob@eu.corp.com"}},
+	}, orgs.Affiliations(people[1]))
+	req.Empty(orgs.Affiliations(people[2]))
+	req.Empty(orgs.Affiliations(&Person{Emails: []string{"bob@corp.com"}}))
+}
+
+func TestWriteAffiliations(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-affiliations")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people := newAffiliationTestPeople()
+	orgs := Organizations{"corp.com": "Corp Inc"}
+
+	path := filepath.Join(dir, "affiliations.json")
+	req.NoError(people.WriteAffiliations(path, orgs))
+	data, err := ioutil.ReadFile(path)
+	req.NoError(err)
+	req.Equal(`{"id":1,"primary_name":"bob","primary_email":"bob@corp.com","affiliations":[`+
+		`{"organization":"Corp Inc","since":"2019-02-01T00:00:00Z","until":"2019-08-01T00:00:00Z",`+
+		`"emails":["bob@corp.com","bob@eu.corp.com"]}]}`, strings.TrimSpace(string(data)))
+
+	path = filepath.Join(dir, "affiliations.parquet")
+	req.NoError(people.WriteAffiliations(path, NewOrganizations().Merge(orgs)))
+	fr, err := local.NewLocalFileReader(path)
+	req.NoError(err)
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, new(parquetAffiliations), int64(runtime.NumCPU()))
+	req.NoError(err)
+	rows := make([]parquetAffiliations, pr.GetNumRows())
+	req.NoError(pr.Read(&rows))
+	pr.ReadStop()
+	req.Len(rows, 1)
+	req.Equal(int64(1), rows[0].ID)
+	req.Equal("bob@corp.com", rows[0].PrimaryEmail)
+	req.Equal([]parquetAffiliation{
+		{PersonalAffiliation, timeToMillis(people[1].AliasHistory.Emails["bob@gmail.com"].FirstSeen),
+			timeToMillis(people[1].AliasHistory.Emails["robert@googlemail.com"].LastSeen),
+			[]string{"bob@gmail.com", "robert@googlemail.com"}},
+		{"Corp Inc", timeToMillis(people[1].AliasHistory.Emails["bob@corp.com"].FirstSeen),
+			timeToMillis(people[1].AliasHistory.Emails["bob@eu.corp.com"].LastSeen),
+			[]string{"bob@corp.com", "bob@eu.corp.com"}},
+	}, rows[0].Affiliations)
+}
@@ -0,0 +1,115 @@
+package idmatch
+
+import (
+	"sort"
+	"time"
+)
+
+// AliasSpan is the time range during which an email or a name was observed in the signatures.
+type AliasSpan struct {
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// AliasHistory maps the emails and the names of the person to the time ranges during which
+// they were observed, e.g. to find when the person switched from the personal to
+// the corporate email. The signatures without Signature.Time are not tracked, and the same as
+// Person.FirstCommit, the first seen time is the latest commit of the earliest signature.
+type AliasHistory struct {
+	Emails map[string]AliasSpan
+	Names  map[string]AliasSpan
+}
+
+// AliasChange is the email or the name of AliasHistory.Timeline.
+type AliasChange struct {
+	// Email is set if the alias is the email, otherwise Name is set.
+	Email string
+	Name  string
+	AliasSpan
+}
+
+// Timeline returns the emails and the names ordered by the time they were first seen.
+// The emails go before the names which were first seen at the same time.
+func (h AliasHistory) Timeline() []AliasChange {
+	var timeline []AliasChange
+	for email, span := range h.Emails {
+		timeline = append(timeline, AliasChange{Email: email, AliasSpan: span})
+	}
+	for name, span := range h.Names {
+		timeline = append(timeline, AliasChange{Name: name, AliasSpan: span})
+	}
+	sort.Slice(timeline, func(i, j int) bool {
+		a, b := timeline[i], timeline[j]
+		switch {
+		case !a.FirstSeen.Equal(b.FirstSeen):
+			return a.FirstSeen.Before(b.FirstSeen)
+		case (a.Email == "") != (b.Email == ""):
+			return a.Email != ""
+		case a.Email != b.Email:
+			return a.Email < b.Email
+		}
+		return a.Name < b.Name
+	})
+	return timeline
+}
+
+// newAliasHistory records that the email and the name were observed at the time of
+// the signature.
+func newAliasHistory(email, name string, when time.Time) AliasHistory {
+	span := AliasSpan{when, when}
+	return AliasHistory{
+		Emails: addAliasSpan(nil, email, span),
+		Names:  addAliasSpan(nil, name, span),
+	}
+}
+
+// merge extends the history with the other one.
+func (h *AliasHistory) merge(other AliasHistory) {
+	for email, span := range other.Emails {
+		h.Emails = addAliasSpan(h.Emails, email, span)
+	}
+	for name, span := range other.Names {
+		h.Names = addAliasSpan(h.Names, name, span)
+	}
+}
+
+// copy returns the deep copy of the history.
+func (h AliasHistory) copy() AliasHistory {
+	same := func(key string) string { return key }
+	return AliasHistory{Emails: replaceSpanKeys(h.Emails, same), Names: replaceSpanKeys(h.Names, same)}
+}
+
+// addAliasSpan extends the time range of the key with the span. It returns the updated map,
+// which is created if it is nil and the span is known.
+func addAliasSpan(spans map[string]AliasSpan, key string, span AliasSpan) map[string]AliasSpan {
+	if key == "" || span.FirstSeen.IsZero() {
+		return spans
+	}
+	if spans == nil {
+		spans = map[string]AliasSpan{}
+	}
+	existing, exists := spans[key]
+	if exists {
+		if existing.FirstSeen.Before(span.FirstSeen) {
+			span.FirstSeen = existing.FirstSeen
+		}
+		if existing.LastSeen.After(span.LastSeen) {
+			span.LastSeen = existing.LastSeen
+		}
+	}
+	spans[key] = span
+	return spans
+}
+
+// replaceSpanKeys returns the copy of AliasHistory.Emails or AliasHistory.Names with the keys
+// replaced. The spans of the keys which are replaced with the same value are merged.
+func replaceSpanKeys(spans map[string]AliasSpan, replace func(string) string) map[string]AliasSpan {
+	if spans == nil {
+		return nil
+	}
+	result := make(map[string]AliasSpan, len(spans))
+	for key, span := range spans {
+		result = addAliasSpan(result, replace(key), span)
+	}
+	return result
+}
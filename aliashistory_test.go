@@ -0,0 +1,79 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliasHistory(t *testing.T) {
+	req := require.New(t)
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := newAliasHistory("bob@home.org", "bob", day)
+	req.Equal(AliasHistory{}, newAliasHistory("bob@home.org", "bob", time.Time{}))
+	history.merge(newAliasHistory("bob@google.com", "bob", day.AddDate(1, 0, 0)))
+	history.merge(newAliasHistory("bob@home.org", "robert", day.AddDate(0, 6, 0)))
+	history.merge(newAliasHistory("bob@home.org", "bob", time.Time{}))
+	req.Equal(AliasHistory{
+		Emails: map[string]AliasSpan{
+			"bob@home.org":   {day, day.AddDate(0, 6, 0)},
+			"bob@google.com": {day.AddDate(1, 0, 0), day.AddDate(1, 0, 0)},
+		},
+		Names: map[string]AliasSpan{
+			"bob":    {day, day.AddDate(1, 0, 0)},
+			"robert": {day.AddDate(0, 6, 0), day.AddDate(0, 6, 0)},
+		},
+	}, history)
+	req.Equal([]AliasChange{
+		{Email: "bob@home.org", AliasSpan: AliasSpan{day, day.AddDate(0, 6, 0)}},
+		{Name: "bob", AliasSpan: AliasSpan{day, day.AddDate(1, 0, 0)}},
+		{Name: "robert", AliasSpan: AliasSpan{day.AddDate(0, 6, 0), day.AddDate(0, 6, 0)}},
+		{Email: "bob@google.com", AliasSpan: AliasSpan{day.AddDate(1, 0, 0), day.AddDate(1, 0, 0)}},
+	}, history.Timeline())
+
+	clone := history.copy()
+	req.Equal(history, clone)
+	delete(clone.Emails, "bob@home.org")
+	req.Len(history.Emails, 2)
+
+	domain := func(email string) string { return email[strings.Index(email, "@"):] }
+	req.Equal(map[string]AliasSpan{
+		"@home.org":   {day, day.AddDate(0, 6, 0)},
+		"@google.com": {day.AddDate(1, 0, 0), day.AddDate(1, 0, 0)},
+	}, replaceSpanKeys(history.Emails, domain))
+	req.Nil(replaceSpanKeys(nil, domain))
+}
+
+func TestAliasHistoryParquet(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-aliases")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := newAliasHistory("bob@home.org", "bob", day)
+	history.merge(newAliasHistory("bob@google.com", "bob", day.AddDate(1, 0, 0)))
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}},
+			Emails: []string{"bob@google.com", "bob@home.org"}, PrimaryName: "bob",
+			PrimaryEmail: "bob@google.com", AliasHistory: history},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"},
+			PrimaryName: "alice", PrimaryEmail: "alice@google.com"},
+	}
+	path := filepath.Join(dir, "identities.parquet")
+	req.NoError(people.WriteToParquet(path, ""))
+	stored, _, err := readFromParquet(path)
+	req.NoError(err)
+	req.Equal(history, stored[1].AliasHistory)
+	req.Equal(AliasHistory{}, stored[2].AliasHistory)
+
+	s, err := NewSuppressions("bob@home.org")
+	req.NoError(err)
+	req.Equal(1, stored.erase(s))
+	req.Equal(map[string]AliasSpan{"bob@google.com": history.Emails["bob@google.com"]},
+		stored[1].AliasHistory.Emails)
+}
@@ -0,0 +1,269 @@
+package idmatch
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// The kinds of Anomaly.
+const (
+	// AnomalyNames is the identity with too many distinct names.
+	AnomalyNames = "names"
+	// AnomalyEmailNames is the email which was used by too many very different names.
+	AnomalyEmailNames = "email_names"
+	// AnomalyRepositories is the identity which contributed to implausibly many repositories.
+	AnomalyRepositories = "repositories"
+	// AnomalyBlacklistNearMiss is the name or the email which differs from a blacklisted one by
+	// a single character, e.g. a misspelled CI account, and thus was not ignored.
+	AnomalyBlacklistNearMiss = "blacklist_near_miss"
+)
+
+// minNearMissLength is the shortest value checked for the blacklist near misses: the short
+// names and emails are a single edit away from too many others.
+const minNearMissLength = 5
+
+// Anomaly is a matched identity which looks wrong and deserves the manual inspection.
+type Anomaly struct {
+	ID   int64  `json:"id"`
+	Kind string `json:"kind"`
+	// Value is the suspicious email or name, if any.
+	Value string `json:"value,omitempty"`
+	// Details explain the anomaly, e.g. list the names.
+	Details string `json:"details"`
+}
+
+// AnomalyOptions set the limits beyond which the identities are reported as anomalies. The zero
+// values disable the corresponding checks.
+type AnomalyOptions struct {
+	// MaxNames is the maximum number of the distinct names of an identity.
+	MaxNames int
+	// MaxEmailNames is the maximum number of the very different names of a single email: the names
+	// which share a word are counted once, so that "bob smith" and "bob" are not different.
+	MaxEmailNames int
+	// MaxRepositories is the maximum number of the repositories of an identity.
+	MaxRepositories int
+	// NearMisses enables AnomalyBlacklistNearMiss.
+	NearMisses bool
+}
+
+// EmailNames returns the names of each email in the people. FindAnomalies needs the names of
+// the people before the matching, when each of them has a single email, otherwise the names of
+// the other emails of the same identity are attributed to every email.
+func EmailNames(people People) map[string][]string {
+	sets := map[string]map[string]struct{}{}
+	for _, person := range people {
+		for _, email := range person.Emails {
+			if sets[email] == nil {
+				sets[email] = map[string]struct{}{}
+			}
+			for _, name := range person.NamesWithRepos {
+				sets[email][name.Name] = struct{}{}
+			}
+		}
+	}
+	result := make(map[string][]string, len(sets))
+	for email, names := range sets {
+		result[email] = sortedKeys(names)
+	}
+	return result
+}
+
+// FindAnomalies returns the identities which look wrong, sorted by ID. emailNames are
+// the names of each email before the matching, see EmailNames; AnomalyEmailNames is not checked
+// if it is nil.
+func FindAnomalies(people People, emailNames map[string][]string, blacklist Blacklist,
+	opts AnomalyOptions) []Anomaly {
+	var nearMisses nearMissIndex
+	if opts.NearMisses {
+		nearMisses = newNearMissIndex(blacklist.Names, blacklist.Emails)
+	}
+	var anomalies []Anomaly
+	for _, id := range peopleIDs(people) {
+		person := people[id]
+		names := map[string]struct{}{}
+		for _, name := range person.NamesWithRepos {
+			names[name.Name] = struct{}{}
+		}
+		if opts.MaxNames > 0 && len(names) > opts.MaxNames {
+			anomalies = append(anomalies, Anomaly{ID: id, Kind: AnomalyNames,
+				Details: fmt.Sprintf("%d names: %s", len(names), strings.Join(sortedKeys(names), "; "))})
+		}
+		if opts.MaxEmailNames > 0 && emailNames != nil {
+			for _, email := range person.Emails {
+				if groups := countNameGroups(emailNames[email]); groups > opts.MaxEmailNames {
+					anomalies = append(anomalies, Anomaly{ID: id, Kind: AnomalyEmailNames, Value: email,
+						Details: fmt.Sprintf("%d different names: %s", groups,
+							strings.Join(emailNames[email], "; "))})
+				}
+			}
+		}
+		if opts.MaxRepositories > 0 && len(person.Repositories) > opts.MaxRepositories {
+			anomalies = append(anomalies, Anomaly{ID: id, Kind: AnomalyRepositories,
+				Details: fmt.Sprintf("%d repositories", len(person.Repositories))})
+		}
+		if nearMisses != nil {
+			values := append(sortedKeys(names), person.Emails...)
+			for _, value := range values {
+				if blacklisted := nearMisses.find(value); blacklisted != "" {
+					anomalies = append(anomalies, Anomaly{ID: id, Kind: AnomalyBlacklistNearMiss,
+						Value: value, Details: "similar to the blacklisted " + blacklisted})
+				}
+			}
+		}
+	}
+	for _, anomaly := range anomalies {
+		reporter.Increment("anomalies: " + anomaly.Kind)
+	}
+	return anomalies
+}
+
+// countNameGroups returns the number of the groups of the names which share a word with
+// another name of the same group.
+func countNameGroups(names []string) int {
+	if len(names) < 2 {
+		return len(names)
+	}
+	groups := make([]int, len(names))
+	for i := range groups {
+		groups[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if groups[i] != i {
+			groups[i] = find(groups[i])
+		}
+		return groups[i]
+	}
+	owners := map[string]int{}
+	for i, name := range names {
+		for _, token := range splitNameTokens(name) {
+			if owner, exists := owners[token]; exists {
+				groups[find(i)] = find(owner)
+			} else {
+				owners[token] = i
+			}
+		}
+	}
+	count := 0
+	for i := range groups {
+		if find(i) == i {
+			count++
+		}
+	}
+	return count
+}
+
+// nearMissIndex finds the values which are a single edit away from the blacklisted ones with
+// the symmetric deletions: both strings must share a variant with at most one character
+// deleted.
+type nearMissIndex map[string][]string
+
+func newNearMissIndex(sets ...map[string]struct{}) nearMissIndex {
+	index := nearMissIndex{}
+	for _, set := range sets {
+		for value := range set {
+			if len([]rune(value)) < minNearMissLength {
+				continue
+			}
+			for _, variant := range deletionVariants(value) {
+				index[variant] = append(index[variant], value)
+			}
+		}
+	}
+	for variant, values := range index {
+		sort.Strings(values)
+		index[variant] = values
+	}
+	return index
+}
+
+// find returns the blacklisted value which is a single edit away from the given one or
+// an empty string.
+func (index nearMissIndex) find(value string) string {
+	runes := []rune(value)
+	if len(runes) < minNearMissLength {
+		return ""
+	}
+	best := ""
+	for _, variant := range deletionVariants(value) {
+		for _, blacklisted := range index[variant] {
+			if blacklisted == value {
+				// the value is blacklisted itself
+				return ""
+			}
+			if editDistance(runes, []rune(blacklisted)) == 1 && (best == "" || blacklisted < best) {
+				best = blacklisted
+			}
+		}
+	}
+	return best
+}
+
+// deletionVariants returns the string and all its variants with a single character deleted.
+func deletionVariants(value string) []string {
+	runes := []rune(value)
+	variants := []string{value}
+	for i := range runes {
+		variants = append(variants, string(runes[:i])+string(runes[i+1:]))
+	}
+	return variants
+}
+
+// WriteAnomalies saves the anomalies to the JSON lines file if path ends with ".json" or
+// ".jsonl", otherwise to the CSV file with the columns id, kind, value and details.
+func WriteAnomalies(path string, anomalies []Anomaly) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	if strings.HasSuffix(path, ".json") || strings.HasSuffix(path, ".jsonl") {
+		writer := bufio.NewWriter(file)
+		defer func() {
+			errFlush := writer.Flush()
+			if err == nil {
+				err = errFlush
+			}
+		}()
+		encoder := json.NewEncoder(writer)
+		for _, anomaly := range anomalies {
+			if err = encoder.Encode(anomaly); err != nil {
+				return
+			}
+		}
+		return
+	}
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write([]string{"id", "kind", "value", "details"}); err != nil {
+		return
+	}
+	for _, anomaly := range anomalies {
+		err = writer.Write([]string{strconv.FormatInt(anomaly.ID, 10), anomaly.Kind, anomaly.Value,
+			anomaly.Details})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
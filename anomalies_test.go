@@ -0,0 +1,92 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindAnomalies(t *testing.T) {
+	req := require.New(t)
+	blacklist := newTestBlacklist(t)
+	blacklist.Names["jenkins"] = struct{}{}
+	signatures := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob smith", ""}}, Emails: []string{"bob@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"team@google.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"eve", ""}}, Emails: []string{"team@google.com"}},
+		5: {ID: 5, NamesWithRepos: []NameWithRepo{{"mallory", ""}}, Emails: []string{"team@google.com"}},
+	}
+	emailNames := EmailNames(signatures)
+	req.Equal([]string{"bob", "bob smith"}, emailNames["bob@google.com"])
+	req.Equal([]string{"alice", "eve", "mallory"}, emailNames["team@google.com"])
+
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob smith", ""}, {"bob", ""}, {"robert", ""}},
+			Emails: []string{"bob@google.com"}, Repositories: []string{"r1", "r2", "r3"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}, {"eve", ""}, {"mallory", ""}},
+			Emails: []string{"team@google.com"}},
+		6: {ID: 6, NamesWithRepos: []NameWithRepo{{"jenkin", ""}}, Emails: []string{"ci@google.com"}},
+		7: {ID: 7, NamesWithRepos: []NameWithRepo{{"jenkins", ""}}, Emails: []string{"jenkins@google.com"}},
+	}
+	opts := AnomalyOptions{MaxNames: 2, MaxEmailNames: 2, MaxRepositories: 2, NearMisses: true}
+	anomalies := FindAnomalies(people, emailNames, blacklist, opts)
+	req.Equal([]Anomaly{
+		{ID: 1, Kind: AnomalyNames, Details: "3 names: bob; bob smith; robert"},
+		{ID: 1, Kind: AnomalyRepositories, Details: "3 repositories"},
+		{ID: 3, Kind: AnomalyNames, Details: "3 names: alice; eve; mallory"},
+		{ID: 3, Kind: AnomalyEmailNames, Value: "team@google.com",
+			Details: "3 different names: alice; eve; mallory"},
+		{ID: 6, Kind: AnomalyBlacklistNearMiss, Value: "jenkin", Details: "similar to the blacklisted jenkins"},
+	}, anomalies)
+
+	anomalies = FindAnomalies(people, nil, blacklist, AnomalyOptions{})
+	req.Empty(anomalies)
+}
+
+func TestCountNameGroups(t *testing.T) {
+	req := require.New(t)
+	req.Equal(0, countNameGroups(nil))
+	req.Equal(1, countNameGroups([]string{"bob"}))
+	req.Equal(1, countNameGroups([]string{"bob", "bob smith", "smith"}))
+	req.Equal(2, countNameGroups([]string{"bob", "alice smith", "bob smith", "eve"}))
+}
+
+func TestNearMissIndex(t *testing.T) {
+	req := require.New(t)
+	index := newNearMissIndex(map[string]struct{}{"jenkins": {}, "build-bot": {}, "ci": {}},
+		map[string]struct{}{"noreply@github.com": {}})
+	req.Equal("jenkins", index.find("jenkinz"))
+	req.Equal("jenkins", index.find("jenkinss"))
+	req.Equal("jenkins", index.find("jenkns"))
+	req.Equal("build-bot", index.find("buld-bot"))
+	req.Equal("noreply@github.com", index.find("noreply@gthub.com"))
+	req.Equal("", index.find("jenkins"))
+	req.Equal("", index.find("jenkies2"))
+	req.Equal("", index.find("cj"))
+}
+
+func TestWriteAnomalies(t *testing.T) {
+	req := require.New(t)
+	anomalies := []Anomaly{
+		{ID: 1, Kind: AnomalyNames, Details: "3 names: a; b; c"},
+		{ID: 6, Kind: AnomalyBlacklistNearMiss, Value: "jenkin", Details: "similar to the blacklisted jenkins"},
+	}
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(WriteAnomalies(f.Name(), anomalies))
+	data, err := ioutil.ReadFile(f.Name())
+	req.NoError(err)
+	req.Equal("id,kind,value,details\n1,names,,3 names: a; b; c\n"+
+		"6,blacklist_near_miss,jenkin,similar to the blacklisted jenkins\n", string(data))
+
+	f2, cleanup2 := tempFile(t, "*.json")
+	defer cleanup2()
+	req.NoError(WriteAnomalies(f2.Name(), anomalies))
+	data, err = ioutil.ReadFile(f2.Name())
+	req.NoError(err)
+	req.Equal(`{"id":1,"kind":"names","details":"3 names: a; b; c"}`+"\n"+
+		`{"id":6,"kind":"blacklist_near_miss","value":"jenkin","details":"similar to the blacklisted jenkins"}`+"\n",
+		string(data))
+}
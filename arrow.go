@@ -0,0 +1,270 @@
+package idmatch
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// SignaturesArrowSchema is the schema of the signatures record batches. The columns are the same
+// as in the signatures cache, "source", "role", "signing_key" and "activity" are optional in
+// the input.
+var SignaturesArrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "repo", Type: arrow.BinaryTypes.String},
+	{Name: "name", Type: arrow.BinaryTypes.String},
+	{Name: "email", Type: arrow.BinaryTypes.String},
+	{Name: "hash", Type: arrow.BinaryTypes.String},
+	{Name: "time", Type: arrow.FixedWidthTypes.Timestamp_ms},
+	{Name: "source", Type: arrow.BinaryTypes.String},
+	{Name: "role", Type: arrow.BinaryTypes.String},
+	{Name: "signing_key", Type: arrow.BinaryTypes.String},
+	{Name: "activity", Type: arrow.BinaryTypes.String},
+}, nil)
+
+// PeopleArrowSchema is the schema of the people record batches: the columns of the identities
+// parquet table and the lists of the names and the emails of each person.
+var PeopleArrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "id", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "primary_name", Type: arrow.BinaryTypes.String},
+	{Name: "primary_email", Type: arrow.BinaryTypes.String},
+	{Name: "external_id_provider", Type: arrow.BinaryTypes.String},
+	{Name: "external_id", Type: arrow.BinaryTypes.String},
+	{Name: "is_bot", Type: arrow.FixedWidthTypes.Boolean},
+	{Name: "first_commit", Type: arrow.FixedWidthTypes.Timestamp_ms},
+	{Name: "last_commit", Type: arrow.FixedWidthTypes.Timestamp_ms},
+	{Name: "commits", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "recent_commits", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "repositories", Type: arrow.PrimitiveTypes.Int64},
+	{Name: "names", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+	{Name: "emails", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+}, nil)
+
+// SignaturesToArrow converts the signatures to the record batch with SignaturesArrowSchema.
+// The caller must release the record.
+func SignaturesToArrow(mem memory.Allocator, signatures []Signature) array.Record {
+	builder := array.NewRecordBuilder(mem, SignaturesArrowSchema)
+	defer builder.Release()
+	builder.Reserve(len(signatures))
+	for _, s := range signatures {
+		for i, value := range []string{s.Repo, s.Name, s.Email, s.Hash} {
+			builder.Field(i).(*array.StringBuilder).Append(value)
+		}
+		builder.Field(4).(*array.TimestampBuilder).Append(arrow.Timestamp(timeToMillis(s.Time)))
+		for i, value := range []string{string(s.Source), string(s.Role), s.SigningKey, s.Activity.String()} {
+			builder.Field(5 + i).(*array.StringBuilder).Append(value)
+		}
+	}
+	return builder.NewRecord()
+}
+
+// SignaturesFromArrow converts the record batch with the signatures cache columns, see
+// SignaturesArrowSchema. The values are normalized the same way as the cached signatures and
+// the rows are deduplicated by repository, name and email, so the batch may contain a row per
+// commit. "time" may be a timestamp of any unit.
+func SignaturesFromArrow(record array.Record) ([]Signature, error) {
+	repos := map[string]signatureAggregator{}
+	if err := addArrowSignatures(repos, record); err != nil {
+		return nil, err
+	}
+	return aggregatedSignatures(repos), nil
+}
+
+// ReadSignaturesFromArrow reads all the record batches of the Arrow IPC stream with
+// SignaturesFromArrow.
+func ReadSignaturesFromArrow(r io.Reader) ([]Signature, error) {
+	reader, err := ipc.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Release()
+	repos := map[string]signatureAggregator{}
+	for reader.Next() {
+		if err = addArrowSignatures(repos, reader.Record()); err != nil {
+			return nil, err
+		}
+	}
+	return aggregatedSignatures(repos), nil
+}
+
+func readSignaturesFromArrowFile(path string) ([]Signature, error) {
+	file, err := OpenPath(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			reporter.Warnf("failed to close %s: %v", path, err)
+		}
+	}()
+	signatures, err := ReadSignaturesFromArrow(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return signatures, nil
+}
+
+// addArrowSignatures aggregates the rows of the record batch by repository.
+func addArrowSignatures(repos map[string]signatureAggregator, record array.Record) error {
+	columns := map[string]array.Interface{}
+	for i, field := range record.Schema().Fields() {
+		columns[field.Name] = record.Column(i)
+	}
+	strs := map[string]*array.String{}
+	for index, name := range signatureCSVHeader {
+		column, exists := columns[name]
+		if !exists {
+			if index < requiredSignatureFields {
+				return fmt.Errorf("column %s of the signatures does not exist", name)
+			}
+			continue
+		}
+		if name == "time" {
+			continue
+		}
+		str, isString := column.(*array.String)
+		if !isString {
+			return fmt.Errorf("column %s of the signatures must be a string, got %s",
+				name, column.DataType().Name())
+		}
+		strs[name] = str
+	}
+	times, isTimestamp := columns["time"].(*array.Timestamp)
+	if !isTimestamp {
+		return fmt.Errorf("column time of the signatures must be a timestamp, got %s",
+			columns["time"].DataType().Name())
+	}
+	unit := times.DataType().(*arrow.TimestampType).Unit
+	cell := func(name string, row int) string {
+		column := strs[name]
+		if column == nil || column.IsNull(row) {
+			return ""
+		}
+		return column.Value(row)
+	}
+	for row := 0; row < int(record.NumRows()); row++ {
+		var values [4]string
+		valid := !times.IsNull(row)
+		for j, name := range signatureCSVHeader[:4] {
+			var err error
+			if values[j], err = normalizeSignatureValue(cell(name, row)); err != nil {
+				return err
+			}
+			valid = valid && values[j] != ""
+		}
+		if !valid {
+			reporter.Warnf("invalid arrow row %d: %v", row, values)
+			continue
+		}
+		activity, err := parseActivity(strings.TrimSpace(cell("activity", row)))
+		if err != nil {
+			reporter.Warnf("invalid arrow row %d: %v", row, err)
+			continue
+		}
+		aggregator := repos[values[0]]
+		if aggregator == nil {
+			aggregator = signatureAggregator{}
+			repos[values[0]] = aggregator
+		}
+		aggregator.add(Signature{
+			Repo:   values[0],
+			Name:   values[1],
+			Email:  values[2],
+			Hash:   values[3],
+			Time:   arrowTime(times.Value(row), unit),
+			Source: SourceKind(strings.TrimSpace(cell("source", row))),
+			Role:   SignatureRole(strings.TrimSpace(cell("role", row))),
+
+			SigningKey: strings.ToLower(strings.TrimSpace(cell("signing_key", row))),
+			Activity:   activity,
+		})
+	}
+	return nil
+}
+
+// aggregatedSignatures returns the signatures of all the repositories sorted by repository.
+func aggregatedSignatures(repos map[string]signatureAggregator) []Signature {
+	names := make([]string, 0, len(repos))
+	for repo := range repos {
+		names = append(names, repo)
+	}
+	sort.Strings(names)
+	var result []Signature
+	for _, repo := range names {
+		result = append(result, repos[repo].signatures()...)
+	}
+	return result
+}
+
+func arrowTime(value arrow.Timestamp, unit arrow.TimeUnit) time.Time {
+	switch unit {
+	case arrow.Second:
+		return time.Unix(int64(value), 0).UTC()
+	case arrow.Millisecond:
+		return time.Unix(0, int64(value)*int64(time.Millisecond)).UTC()
+	case arrow.Microsecond:
+		return time.Unix(0, int64(value)*int64(time.Microsecond)).UTC()
+	}
+	return time.Unix(0, int64(value)).UTC()
+}
+
+// ToArrow converts the people to the record batch with PeopleArrowSchema, one row per person
+// sorted by ID. The external ID of externalIDProvider is preferred, see ExternalIDs.Primary.
+// The caller must release the record.
+func (p People) ToArrow(mem memory.Allocator, externalIDProvider string) array.Record {
+	builder := array.NewRecordBuilder(mem, PeopleArrowSchema)
+	defer builder.Release()
+	builder.Reserve(len(p))
+	appendList := func(field int, values []string) {
+		list := builder.Field(field).(*array.ListBuilder)
+		list.Append(true)
+		items := list.ValueBuilder().(*array.StringBuilder)
+		for _, value := range values {
+			items.Append(value)
+		}
+	}
+	for _, id := range peopleIDs(p) {
+		person := p[id]
+		provider, externalID := person.ExternalIDs.Primary(externalIDProvider)
+		builder.Field(0).(*array.Int64Builder).Append(id)
+		for i, value := range []string{person.PrimaryName, person.PrimaryEmail, provider, externalID} {
+			builder.Field(1 + i).(*array.StringBuilder).Append(value)
+		}
+		builder.Field(5).(*array.BooleanBuilder).Append(person.IsBot)
+		builder.Field(6).(*array.TimestampBuilder).Append(arrow.Timestamp(timeToMillis(person.FirstCommit)))
+		builder.Field(7).(*array.TimestampBuilder).Append(arrow.Timestamp(timeToMillis(person.LastCommit)))
+		builder.Field(8).(*array.Int64Builder).Append(int64(person.Commits))
+		builder.Field(9).(*array.Int64Builder).Append(int64(person.RecentCommits))
+		builder.Field(10).(*array.Int64Builder).Append(int64(len(person.Repositories)))
+		names := make([]string, len(person.NamesWithRepos))
+		for i, name := range person.NamesWithRepos {
+			names[i] = name.Name
+		}
+		appendList(11, names)
+		appendList(12, person.Emails)
+	}
+	return builder.NewRecord()
+}
+
+// WriteArrow writes the people to the Arrow IPC stream as a single record batch, see ToArrow.
+func (p People) WriteArrow(w io.Writer, externalIDProvider string) (err error) {
+	mem := memory.NewGoAllocator()
+	record := p.ToArrow(mem, externalIDProvider)
+	defer record.Release()
+	writer := ipc.NewWriter(w, ipc.WithSchema(PeopleArrowSchema), ipc.WithAllocator(mem))
+	defer func() {
+		errClose := writer.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	return writer.Write(record)
+}
@@ -0,0 +1,129 @@
+package idmatch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/ipc"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignaturesArrow(t *testing.T) {
+	req := require.New(t)
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	record := SignaturesToArrow(mem, []Signature{
+		{"repo2", "Bob", "bob@google.com", "bbb", day, "", "", "", nil, nil},
+		{"repo1", "Alice ", "alice@google.com", "aaa", day, "", "", "", nil, nil},
+		{"repo1", "alice", "Alice@google.com", "ccc", day.Add(-time.Hour), "", "", "", nil, nil},
+		{"repo1", "", "eve@google.com", "ddd", day, "", "", "", nil, nil},
+	})
+	defer record.Release()
+	req.Equal(int64(4), record.NumRows())
+	req.True(record.Schema().Equal(SignaturesArrowSchema))
+
+	expected := []Signature{
+		{"repo1", "alice", "alice@google.com", "ccc", day, "", "", "", nil, nil},
+		{"repo2", "bob", "bob@google.com", "bbb", day, "", "", "", nil, nil},
+	}
+	signatures, err := SignaturesFromArrow(record)
+	req.NoError(err)
+	req.Equal(expected, signatures)
+
+	buffer := &bytes.Buffer{}
+	writer := ipc.NewWriter(buffer, ipc.WithSchema(SignaturesArrowSchema), ipc.WithAllocator(mem))
+	req.NoError(writer.Write(record))
+	req.NoError(writer.Write(record))
+	req.NoError(writer.Close())
+	dir, err := ioutil.TempDir("", "idmatch-arrow")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "signatures.arrow")
+	req.NoError(ioutil.WriteFile(path, buffer.Bytes(), 0666))
+	signatures, err = ReadSignaturesFromArrow(bytes.NewReader(buffer.Bytes()))
+	req.NoError(err)
+	req.Equal(expected, signatures)
+	signatures, err = findSignatures(nil, "", path, ExtractionOptions{})
+	req.NoError(err)
+	req.Equal(expected, signatures)
+	_, err = findSignatures(nil, "", filepath.Join(dir, "missing.arrow"), ExtractionOptions{})
+	req.True(os.IsNotExist(err))
+}
+
+func TestSignaturesFromArrowErrors(t *testing.T) {
+	req := require.New(t)
+	mem := memory.NewGoAllocator()
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "repo", Type: arrow.BinaryTypes.String},
+		{Name: "name", Type: arrow.BinaryTypes.String},
+		{Name: "email", Type: arrow.BinaryTypes.String},
+		{Name: "time", Type: arrow.FixedWidthTypes.Timestamp_s},
+	}, nil)
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+	record := builder.NewRecord()
+	defer record.Release()
+	_, err := SignaturesFromArrow(record)
+	req.EqualError(err, "column hash of the signatures does not exist")
+
+	schema = arrow.NewSchema([]arrow.Field{
+		{Name: "repo", Type: arrow.BinaryTypes.String},
+		{Name: "name", Type: arrow.BinaryTypes.String},
+		{Name: "email", Type: arrow.BinaryTypes.String},
+		{Name: "hash", Type: arrow.BinaryTypes.String},
+		{Name: "time", Type: arrow.PrimitiveTypes.Int64},
+	}, nil)
+	builder = array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+	record = builder.NewRecord()
+	defer record.Release()
+	_, err = SignaturesFromArrow(record)
+	req.EqualError(err, "column time of the signatures must be a timestamp, got int64")
+}
+
+func TestPeopleWriteArrow(t *testing.T) {
+	req := require.New(t)
+	people := People{
+		2: {ID: 2, PrimaryName: "bob", PrimaryEmail: "bob@google.com", ExternalIDs: ExternalIDs{"github": "bob_username"},
+			NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
+			Commits: 3},
+		1: {ID: 1, PrimaryName: "alice", PrimaryEmail: "alice@google.com",
+			NamesWithRepos: []NameWithRepo{{"alice", ""}, {"al", "repo1"}},
+			Emails:         []string{"alice@google.com", "al@google.com"}, IsBot: true},
+	}
+	buffer := &bytes.Buffer{}
+	req.NoError(people.WriteArrow(buffer, "github"))
+	reader, err := ipc.NewReader(buffer)
+	req.NoError(err)
+	defer reader.Release()
+	req.True(reader.Schema().Equal(PeopleArrowSchema))
+	req.True(reader.Next())
+	record := reader.Record()
+	req.Equal(int64(2), record.NumRows())
+	req.Equal([]int64{1, 2}, record.Column(0).(*array.Int64).Int64Values())
+	strs := func(column int) []string {
+		values := record.Column(column).(*array.String)
+		result := make([]string, values.Len())
+		for i := range result {
+			result[i] = values.Value(i)
+		}
+		return result
+	}
+	req.Equal([]string{"alice@google.com", "bob@google.com"}, strs(2))
+	req.Equal([]string{"", "github"}, strs(3))
+	req.Equal([]string{"", "bob_username"}, strs(4))
+	req.True(record.Column(5).(*array.Boolean).Value(0))
+	req.Equal([]int64{0, 3}, record.Column(8).(*array.Int64).Int64Values())
+	emails := record.Column(12).(*array.List)
+	req.Equal([]int32{0, 2, 3}, emails.Offsets()[:3])
+	req.Equal(3, emails.ListValues().Len())
+	req.False(reader.Next())
+}
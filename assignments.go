@@ -0,0 +1,95 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// SignatureAssignment is the person whom a signature resolved to, the join table between
+// the commits and the people.
+type SignatureAssignment struct {
+	Repo  string
+	Hash  string
+	Name  string
+	Email string
+	// ID is Person.ID.
+	ID int64
+}
+
+type parquetSignatureAssignment struct {
+	Repo  string `parquet:"name=repo, type=UTF8"`
+	Hash  string `parquet:"name=hash, type=UTF8"`
+	Name  string `parquet:"name=name, type=UTF8"`
+	Email string `parquet:"name=email, type=UTF8"`
+	ID    int64  `parquet:"name=id, type=INT_64"`
+}
+
+// Assignments resolves every signature which the people were matched from, e.g. the signatures
+// cache of FindPeople, to its person in the same order. A signature belongs to the person with
+// its email and name, see PeopleIndex.FindBySignature. The signatures of the people who are not
+// there, such as the blacklisted ones, are skipped.
+func (p People) Assignments(signatures []Signature) []SignatureAssignment {
+	index := p.Index()
+	result := make([]SignatureAssignment, 0, len(signatures))
+	skipped := 0
+	for _, sig := range signatures {
+		person := index.FindBySignature(sig.Name, sig.Repo, sig.Email)
+		if person == nil {
+			skipped++
+			continue
+		}
+		result = append(result, SignatureAssignment{
+			Repo: sig.Repo, Hash: sig.Hash, Name: sig.Name, Email: sig.Email, ID: person.ID})
+	}
+	reporter.Commit("assigned signatures", len(result))
+	reporter.Commit("unassigned signatures", skipped)
+	return result
+}
+
+// WriteAssignments saves the assignments to the CSV file with the columns "repo", "hash", "name",
+// "email" and "id", or to the parquet file with the same columns if path ends with ".parquet".
+func WriteAssignments(path string, assignments []SignatureAssignment) (err error) {
+	if strings.HasSuffix(path, ".parquet") {
+		pw, cleanup := newParquetWriter(path, new(parquetSignatureAssignment))
+		defer cleanup()
+		for _, a := range assignments {
+			if err = pw.Write(parquetSignatureAssignment{a.Repo, a.Hash, a.Name, a.Email, a.ID}); err != nil {
+				return
+			}
+		}
+		return
+	}
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write([]string{"repo", "hash", "name", "email", "id"}); err != nil {
+		return
+	}
+	for _, a := range assignments {
+		err = writer.Write([]string{a.Repo, a.Hash, a.Name, a.Email, strconv.FormatInt(a.ID, 10)})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,80 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestAssignments(t *testing.T) {
+	req := require.New(t)
+	people, signatures := testRepoViewPeople()
+	assignments := people.Assignments(signatures)
+	req.Equal([]SignatureAssignment{
+		{Repo: "src-d/gitbase", Hash: "aaa", Name: "bob", Email: "bob@google.com", ID: 1},
+		{Repo: "src-d/gitbase", Hash: "bbb", Name: "bobby", Email: "bob@apple.com", ID: 1},
+		{Repo: "src-d/engine", Hash: "ccc", Name: "bob", Email: "bob@apple.com", ID: 1},
+		{Repo: "src-d/engine", Hash: "ddd", Name: "alice", Email: "alice@google.com", ID: 2},
+	}, assignments)
+
+	dir, err := ioutil.TempDir("", "idmatch-assignments")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "assignments.csv")
+	req.NoError(WriteAssignments(path, assignments[2:]))
+	data, err := ioutil.ReadFile(path)
+	req.NoError(err)
+	req.Equal(`repo,hash,name,email,id
+src-d/engine,ccc,bob,bob@apple.com,1
+src-d/engine,ddd,alice,alice@google.com,2
+`, string(data))
+
+	path = filepath.Join(dir, "assignments.parquet")
+	req.NoError(WriteAssignments(path, assignments[2:]))
+	fr, err := local.NewLocalFileReader(path)
+	req.NoError(err)
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, new(parquetSignatureAssignment), int64(runtime.NumCPU()))
+	req.NoError(err)
+	rows := make([]parquetSignatureAssignment, pr.GetNumRows())
+	req.NoError(pr.Read(&rows))
+	pr.ReadStop()
+	req.Equal([]parquetSignatureAssignment{
+		{"src-d/engine", "ccc", "bob", "bob@apple.com", 1},
+		{"src-d/engine", "ddd", "alice", "alice@google.com", 2},
+	}, rows)
+}
+
+func TestAssignmentsPopularEmail(t *testing.T) {
+	req := require.New(t)
+	blacklist := newTestBlacklist(t)
+	blacklist.PopularEmails = map[string]struct{}{"ci@builds.io": {}}
+	signatures := []Signature{
+		{Repo: "repo1", Name: "Bob Smith", Email: "bob@google.com", Hash: "aaa"},
+		{Repo: "repo1", Name: "Bob Smith", Email: "ci@builds.io", Hash: "bbb"},
+		{Repo: "repo1", Name: "Alice Jones", Email: "alice@google.com", Hash: "ccc"},
+		{Repo: "repo1", Name: "Alice Jones", Email: "ci@builds.io", Hash: "ddd"},
+	}
+	people, _, _, err := PeopleFromSignatures(context.Background(), signatures,
+		ExtractionOptions{}, blacklist, PopularityThresholds{}, BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	req.NoError(ReducePeople(context.Background(), people, nil, blacklist,
+		ReduceOptions{MaxIdentities: 100}))
+	req.Len(people, 2)
+	index := people.Index()
+	bob := index.FindByEmail("bob@google.com")
+	alice := index.FindByEmail("alice@google.com")
+	req.NotEqual(bob.ID, alice.ID)
+	ids := map[string]int64{}
+	for _, assignment := range people.Assignments(signatures) {
+		ids[assignment.Hash] = assignment.ID
+	}
+	req.Equal(map[string]int64{"aaa": bob.ID, "bbb": bob.ID, "ccc": alice.ID, "ddd": alice.ID}, ids)
+}
@@ -0,0 +1,92 @@
+package idmatch
+
+import (
+	"io"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// PeopleAvroSchema is the Avro schema of the records which People.WriteAvro writes. The fields
+// are the same as in people.proto.
+const PeopleAvroSchema = `{
+  "type": "record",
+  "name": "Person",
+  "namespace": "identitymatching",
+  "fields": [
+    {"name": "id", "type": "long"},
+    {"name": "names", "type": {"type": "array", "items": {
+      "type": "record",
+      "name": "NameWithRepo",
+      "fields": [
+        {"name": "name", "type": "string"},
+        {"name": "repo", "type": "string"}
+      ]
+    }}},
+    {"name": "emails", "type": {"type": "array", "items": "string"}},
+    {"name": "primary_name", "type": "string"},
+    {"name": "primary_email", "type": "string"},
+    {"name": "external_id_provider", "type": "string"},
+    {"name": "external_id", "type": "string"},
+    {"name": "is_bot", "type": "boolean"},
+    {"name": "first_commit", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "last_commit", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "commits", "type": "long"},
+    {"name": "recent_commits", "type": "long"},
+    {"name": "repositories", "type": "long"}
+  ]
+}`
+
+// AvroExternalIDProviderKey is the key of the Avro file metadata with the external ID provider.
+const AvroExternalIDProviderKey = "idmatch.external_id_provider"
+
+// WriteAvro writes the people to the Avro object container file with PeopleAvroSchema, one
+// record per person sorted by ID. The external ID of externalIDProvider is preferred, see
+// ExternalIDs.Primary, and the provider is also stored in the file metadata under
+// AvroExternalIDProviderKey so that the consumers do not have to scan the records.
+func (p People) WriteAvro(w io.Writer, externalIDProvider string) error {
+	writer, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:               w,
+		Schema:          PeopleAvroSchema,
+		CompressionName: goavro.CompressionDeflateLabel,
+		MetaData:        map[string][]byte{AvroExternalIDProviderKey: []byte(externalIDProvider)},
+	})
+	if err != nil {
+		return err
+	}
+	records := make([]interface{}, 0, len(p))
+	for _, id := range peopleIDs(p) {
+		person := p[id]
+		provider, externalID := person.ExternalIDs.Primary(externalIDProvider)
+		names := make([]interface{}, len(person.NamesWithRepos))
+		for i, name := range person.NamesWithRepos {
+			names[i] = map[string]interface{}{"name": name.Name, "repo": name.Repo}
+		}
+		emails := make([]interface{}, len(person.Emails))
+		for i, email := range person.Emails {
+			emails[i] = email
+		}
+		records = append(records, map[string]interface{}{
+			"id":                   id,
+			"names":                names,
+			"emails":               emails,
+			"primary_name":         person.PrimaryName,
+			"primary_email":        person.PrimaryEmail,
+			"external_id_provider": provider,
+			"external_id":          externalID,
+			"is_bot":               person.IsBot,
+			"first_commit":         avroTime(person.FirstCommit),
+			"last_commit":          avroTime(person.LastCommit),
+			"commits":              int64(person.Commits),
+			"recent_commits":       int64(person.RecentCommits),
+			"repositories":         int64(len(person.Repositories)),
+		})
+	}
+	return writer.Append(records)
+}
+
+// avroTime converts the time to timestamp-millis the same way as timeToMillis: the zero time
+// becomes the Unix epoch.
+func avroTime(t time.Time) time.Time {
+	return time.Unix(0, timeToMillis(t)*int64(time.Millisecond)).UTC()
+}
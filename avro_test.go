@@ -0,0 +1,54 @@
+package idmatch
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func newEncoderTestPeople() People {
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	return People{
+		2: {ID: 2, PrimaryName: "bob", PrimaryEmail: "bob@google.com", ExternalIDs: ExternalIDs{"github": "bob_username"},
+			NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
+			Commits: 3, FirstCommit: day, LastCommit: day.Add(time.Hour),
+			Repositories: []string{"repo1", "repo2"}},
+		1: {ID: 1, PrimaryName: "alice", PrimaryEmail: "alice@google.com",
+			NamesWithRepos: []NameWithRepo{{"alice", ""}, {"al", "repo1"}},
+			Emails:         []string{"alice@google.com", "al@google.com"}, IsBot: true},
+	}
+}
+
+func TestPeopleWriteAvro(t *testing.T) {
+	req := require.New(t)
+	buffer := &bytes.Buffer{}
+	req.NoError(newEncoderTestPeople().WriteAvro(buffer, "github"))
+	reader, err := goavro.NewOCFReader(buffer)
+	req.NoError(err)
+	req.Equal([]byte("github"), reader.MetaData()[AvroExternalIDProviderKey])
+	var records []map[string]interface{}
+	for reader.Scan() {
+		record, err := reader.Read()
+		req.NoError(err)
+		records = append(records, record.(map[string]interface{}))
+	}
+	req.NoError(reader.Err())
+	req.Len(records, 2)
+	req.Equal(int64(1), records[0]["id"])
+	req.Equal("", records[0]["external_id_provider"])
+	req.Equal(true, records[0]["is_bot"])
+	req.Equal([]interface{}{
+		map[string]interface{}{"name": "alice", "repo": ""},
+		map[string]interface{}{"name": "al", "repo": "repo1"},
+	}, records[0]["names"])
+	req.Equal([]interface{}{"alice@google.com", "al@google.com"}, records[0]["emails"])
+	req.Equal(int64(2), records[1]["id"])
+	req.Equal("github", records[1]["external_id_provider"])
+	req.Equal("bob_username", records[1]["external_id"])
+	req.Equal(int64(3), records[1]["commits"])
+	req.Equal(int64(2), records[1]["repositories"])
+	req.Equal(time.Date(2019, 1, 1, 1, 0, 0, 0, time.UTC), records[1]["last_commit"])
+}
@@ -0,0 +1,54 @@
+// Package bigquery exports the matched people to the BigQuery tables.
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	bq "cloud.google.com/go/bigquery"
+
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// Export creates or replaces the BigQuery table with the people. The people are staged
+// as the Avro file, see idmatch.People.WriteAvro, and loaded with a single load job, so the table
+// is either replaced completely or left as it was. The columns are the fields of
+// idmatch.PeopleAvroSchema: "names" is the repeated record and the times are the timestamps.
+func Export(ctx context.Context, table *bq.Table, people idmatch.People,
+	externalIDProvider string) error {
+	loader, err := newLoader(table, people, externalIDProvider)
+	if err != nil {
+		return err
+	}
+	job, err := loader.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start loading %s: %v", table.FullyQualifiedName(), err)
+	}
+	status, err := job.Wait(ctx)
+	if err == nil {
+		err = status.Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %v", table.FullyQualifiedName(), err)
+	}
+	reporter.Commit("bigquery people", len(people))
+	return nil
+}
+
+// newLoader prepares the load job of Export.
+func newLoader(table *bq.Table, people idmatch.People, externalIDProvider string) (
+	*bq.Loader, error) {
+	buffer := &bytes.Buffer{}
+	if err := people.WriteAvro(buffer, externalIDProvider); err != nil {
+		return nil, err
+	}
+	source := bq.NewReaderSource(buffer)
+	source.SourceFormat = bq.Avro
+	loader := table.LoaderFrom(source)
+	loader.UseAvroLogicalTypes = true
+	loader.CreateDisposition = bq.CreateIfNeeded
+	loader.WriteDisposition = bq.WriteTruncate
+	return loader, nil
+}
@@ -0,0 +1,31 @@
+package bigquery
+
+import (
+	"context"
+	"testing"
+
+	bq "cloud.google.com/go/bigquery"
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/api/option"
+)
+
+func TestNewLoader(t *testing.T) {
+	req := require.New(t)
+	client, err := bq.NewClient(context.Background(), "project",
+		option.WithoutAuthentication())
+	req.NoError(err)
+	defer client.Close()
+	people := idmatch.People{
+		1: {ID: 1, NamesWithRepos: []idmatch.NameWithRepo{{Name: "alice"}},
+			Emails: []string{"alice@google.com"}},
+	}
+	loader, err := newLoader(client.Dataset("analytics").Table("people"), people, "github")
+	req.NoError(err)
+	req.Equal(bq.WriteTruncate, loader.WriteDisposition)
+	req.Equal(bq.CreateIfNeeded, loader.CreateDisposition)
+	req.True(loader.UseAvroLogicalTypes)
+	req.Equal("project:analytics.people", loader.Dst.FullyQualifiedName())
+	source := loader.Src.(*bq.ReaderSource)
+	req.Equal(bq.Avro, source.SourceFormat)
+}
@@ -8,8 +8,14 @@ import (
 	"bufio"
 	"compress/gzip"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"regexp"
 	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/src-d/identity-matching/reporter"
 )
 
 // Blacklist contains all the data to filter identities or identities connection
@@ -20,10 +26,27 @@ type Blacklist struct {
 	Emails          map[string]struct{}
 	PopularEmails   map[string]struct{}
 	PopularNames    map[string]struct{}
+	// NamePatterns are the regular expressions which match the ignored names.
+	NamePatterns *PatternSet
+	// EmailPatterns are the regular expressions which match the ignored emails.
+	EmailPatterns *PatternSet
+	// DisposableDomains are the throwaway mailbox domains, including their subdomains.
+	// The emails there are kept but never used to match the identities.
+	DisposableDomains map[string]struct{}
+	// NoReplyPatterns are the regular expressions which match the generated noreply emails.
+	// The same as with DisposableDomains, such emails are kept but never used to match.
+	NoReplyPatterns *PatternSet
+	// SharedMailboxes are the team emails used by many different people, see
+	// SharedMailboxDetector. The same as with DisposableDomains, they are kept but never used to match.
+	SharedMailboxes map[string]struct{}
 }
 
 var blacklistFiles = []string{"domains", "top_level_domains", "names", "emails", "popular_emails", "popular_names"}
 
+// blacklistKinds are the kinds of the sets in ReadBlacklist: the embedded files and the ones
+// which are built into the code.
+var blacklistKinds = append(append([]string{}, blacklistFiles...), "disposable_domains")
+
 // NewBlacklist generates Blacklist from the data files embedded to blacklists.go
 func NewBlacklist() (Blacklist, error) {
 	var blacklist []map[string]struct{}
@@ -34,9 +57,18 @@ func NewBlacklist() (Blacklist, error) {
 		}
 		blacklist = append(blacklist, lines)
 	}
+	disposable := map[string]struct{}{}
+	for _, domain := range disposableDomains {
+		disposable[domain] = struct{}{}
+	}
+	noreply, err := NewPatternSet(noreplyPatterns...)
+	if err != nil {
+		return Blacklist{}, err
+	}
 
 	return Blacklist{Domains: blacklist[0], TopLevelDomains: blacklist[1], Names: blacklist[2],
-		Emails: blacklist[3], PopularEmails: blacklist[4], PopularNames: blacklist[5]}, nil
+		Emails: blacklist[3], PopularEmails: blacklist[4], PopularNames: blacklist[5],
+		DisposableDomains: disposable, NoReplyPatterns: noreply}, nil
 }
 
 func readFileLinesSet(filename string) (map[string]struct{}, error) {
@@ -59,19 +91,239 @@ func readFileLinesSet(filename string) (map[string]struct{}, error) {
 	lines := make(map[string]struct{})
 
 	for scanner.Scan() {
-		line := scanner.Text()
-		normLine, _, err := removeDiacritical(line)
+		line, err := normalizeBlacklistEntry(scanner.Text())
 		if err != nil {
 			return nil, err
 		}
-		lines[strings.ToLower(strings.TrimSpace(normalizeSpaces(normLine)))] = struct{}{}
+		lines[line] = struct{}{}
 	}
 
 	return lines, err
 }
 
+func normalizeBlacklistEntry(entry string) (string, error) {
+	normEntry, err := transliterate(entry)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSpace(normalizeSpaces(normEntry))), nil
+}
+
+// LoadBlacklist generates the built-in Blacklist and merges it with the entries from the given
+// user-supplied files. See ReadBlacklist about the supported file formats.
+func LoadBlacklist(paths ...string) (Blacklist, error) {
+	blacklist, err := NewBlacklist()
+	if err != nil {
+		return Blacklist{}, err
+	}
+	for _, path := range paths {
+		custom, err := ReadBlacklist(path)
+		if err != nil {
+			return Blacklist{}, fmt.Errorf("failed to read the blacklist from %s: %v", path, err)
+		}
+		blacklist = blacklist.Merge(custom)
+	}
+	return blacklist, nil
+}
+
+// ReadBlacklist loads the Blacklist entries from a YAML or a CSV file, depending on
+// the extension. The YAML file is a mapping from the blacklist kinds - "domains",
+// "top_level_domains", "names", "emails", "popular_emails", "popular_names",
+// "disposable_domains", "name_patterns", "email_patterns" and "noreply_patterns" - to the lists
+// of values. The CSV file has two columns: "kind" and "value".
+// The patterns are regular expressions, see NewPatternSet.
+func ReadBlacklist(path string) (Blacklist, error) {
+	entries := map[string][]string{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return Blacklist{}, err
+		}
+		if err = yaml.UnmarshalStrict(data, &entries); err != nil {
+			return Blacklist{}, err
+		}
+	case ".csv":
+		var err error
+		if entries, err = readBlacklistCSV(path); err != nil {
+			return Blacklist{}, err
+		}
+	default:
+		return Blacklist{}, fmt.Errorf("unsupported blacklist file format: %s", path)
+	}
+	var sets []map[string]struct{}
+	for _, kind := range blacklistKinds {
+		set := map[string]struct{}{}
+		for _, entry := range entries[kind] {
+			normEntry, err := normalizeBlacklistEntry(entry)
+			if err != nil {
+				return Blacklist{}, err
+			}
+			set[normEntry] = struct{}{}
+		}
+		delete(entries, kind)
+		sets = append(sets, set)
+	}
+	namePatterns, err := NewPatternSet(entries["name_patterns"]...)
+	if err != nil {
+		return Blacklist{}, err
+	}
+	emailPatterns, err := NewPatternSet(entries["email_patterns"]...)
+	if err != nil {
+		return Blacklist{}, err
+	}
+	noreplyPatterns, err := NewPatternSet(entries["noreply_patterns"]...)
+	if err != nil {
+		return Blacklist{}, err
+	}
+	delete(entries, "name_patterns")
+	delete(entries, "email_patterns")
+	delete(entries, "noreply_patterns")
+	for kind := range entries {
+		return Blacklist{}, fmt.Errorf("unknown blacklist kind: %s", kind)
+	}
+	return Blacklist{Domains: sets[0], TopLevelDomains: sets[1], Names: sets[2],
+		Emails: sets[3], PopularEmails: sets[4], PopularNames: sets[5], DisposableDomains: sets[6],
+		NamePatterns: namePatterns, EmailPatterns: emailPatterns, NoReplyPatterns: noreplyPatterns}, nil
+}
+
+func readBlacklistCSV(path string) (entries map[string][]string, err error) {
+	entries = map[string][]string{}
+	err = readCSVRecords(path, "blacklist", []string{"kind", "value"},
+		func(header map[string]int, record []string) error {
+			kind := strings.TrimSpace(record[header["kind"]])
+			entries[kind] = append(entries[kind], record[header["value"]])
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// PopularityThresholds define when a name or an email is so frequent that it is considered
+// popular in addition to the built-in popular lists. The zero value disables the thresholds.
+type PopularityThresholds struct {
+	// MinNameCount is the minimum absolute frequency of a popular name. 0 disables the limit.
+	MinNameCount int
+	// MinNameShare is the minimum share of all the signatures of a popular name. 0 disables
+	// the limit.
+	MinNameShare float64
+	// MinEmailCount is the minimum absolute frequency of a popular email. 0 disables the limit.
+	MinEmailCount int
+	// MinEmailShare is the minimum share of all the signatures of a popular email. 0 disables
+	// the limit.
+	MinEmailShare float64
+
+	// Frequencies configure the frequency counting. If HalfLife is set, the thresholds apply
+	// to Frequency.Decayed instead of Frequency.Total, so that the names and emails which
+	// were frequent long ago do not remain popular forever.
+	Frequencies FrequencyOptions
+}
+
+// count returns the frequency which the thresholds are compared with.
+func (thresholds PopularityThresholds) count(freq *Frequency) float64 {
+	if thresholds.Frequencies.HalfLife > 0 {
+		return freq.Decayed
+	}
+	return float64(freq.Total)
+}
+
+// WithPopular returns the copy of the blacklist with the names and emails whose frequencies
+// pass the popularity thresholds added to PopularNames and PopularEmails.
+func (b Blacklist) WithPopular(nameFreqs, emailFreqs map[string]*Frequency,
+	thresholds PopularityThresholds) Blacklist {
+	return b.withPopular(nameFreqs, emailFreqs, thresholds, true)
+}
+
+// withPopular is WithPopular which reports the new popular values only if report is true.
+// The pipeline extends the blacklist internally before the caller does it with WithPopular,
+// so the values must be reported once.
+func (b Blacklist) withPopular(nameFreqs, emailFreqs map[string]*Frequency,
+	thresholds PopularityThresholds, report bool) Blacklist {
+	extend := func(popular map[string]struct{}, freqs map[string]*Frequency,
+		minCount int, minShare float64, kind string) map[string]struct{} {
+		result := map[string]struct{}{}
+		for key := range popular {
+			result[key] = struct{}{}
+		}
+		total := 0.0
+		for _, freq := range freqs {
+			total += thresholds.count(freq)
+		}
+		for value, freq := range freqs {
+			count := thresholds.count(freq)
+			if minCount > 0 && count >= float64(minCount) ||
+				minShare > 0 && count >= minShare*total {
+				if _, exists := result[value]; !exists {
+					if report {
+						reporter.Increment("popular " + kind + " by frequency")
+					}
+					result[value] = struct{}{}
+				}
+			}
+		}
+		return result
+	}
+	extended := b
+	extended.PopularNames = extend(b.PopularNames, nameFreqs,
+		thresholds.MinNameCount, thresholds.MinNameShare, "names")
+	extended.PopularEmails = extend(b.PopularEmails, emailFreqs,
+		thresholds.MinEmailCount, thresholds.MinEmailShare, "emails")
+	return extended
+}
+
+// Merge returns the union of both blacklists.
+func (b Blacklist) Merge(other Blacklist) Blacklist {
+	union := func(sets ...map[string]struct{}) map[string]struct{} {
+		result := map[string]struct{}{}
+		for _, set := range sets {
+			for key := range set {
+				result[key] = struct{}{}
+			}
+		}
+		return result
+	}
+	return Blacklist{
+		Domains:           union(b.Domains, other.Domains),
+		TopLevelDomains:   union(b.TopLevelDomains, other.TopLevelDomains),
+		Names:             union(b.Names, other.Names),
+		Emails:            union(b.Emails, other.Emails),
+		PopularEmails:     union(b.PopularEmails, other.PopularEmails),
+		PopularNames:      union(b.PopularNames, other.PopularNames),
+		NamePatterns:      b.NamePatterns.Merge(other.NamePatterns),
+		EmailPatterns:     b.EmailPatterns.Merge(other.EmailPatterns),
+		DisposableDomains: union(b.DisposableDomains, other.DisposableDomains),
+		NoReplyPatterns:   b.NoReplyPatterns.Merge(other.NoReplyPatterns),
+		SharedMailboxes:   union(b.SharedMailboxes, other.SharedMailboxes),
+	}
+}
+
+// CheckSignature returns the error which satisfies errors.Is(err, ErrBlacklisted) if
+// the cleaned name or email of the signature is ignored, so that the signature never becomes
+// an identity, and the error which satisfies errors.Is(err, ErrInvalidSignature) if they
+// cannot be cleaned.
+func (b Blacklist) CheckSignature(signature Signature) error {
+	name, err := nameCleaner.Clean(signature.Name)
+	if err != nil {
+		return withKind(ErrInvalidSignature, err)
+	}
+	email, err := cleanEmail(signature.Email)
+	if err != nil {
+		return withKind(ErrInvalidSignature, err)
+	}
+	if b.isIgnoredEmail(email) {
+		return fmt.Errorf("%w: the email %s is ignored", ErrBlacklisted, email)
+	}
+	if b.isIgnoredName(name) {
+		return fmt.Errorf("%w: the name %s is ignored", ErrBlacklisted, name)
+	}
+	return nil
+}
+
 func (b Blacklist) isIgnoredEmail(s string) bool {
-	if !strings.Contains(s, "@") || b.isBlacklistedEmail(s) || isMultipleEmail(s) {
+	if !strings.Contains(s, "@") || b.isBlacklistedEmail(s) || isMultipleEmail(s) ||
+		b.EmailPatterns.MatchString(s) {
 		return true
 	}
 	parts := strings.Split(s, "@")
@@ -117,7 +369,7 @@ func (b Blacklist) isIgnoredTopLevelDomain(s string) bool {
 
 func (b Blacklist) isIgnoredName(name string) bool {
 	_, ok := b.Names[strings.ToLower(name)]
-	return ok
+	return ok || b.NamePatterns.MatchString(name)
 }
 
 var isIP4EmailRegex = regexp.MustCompile(`\d+\.\d+\.\d+\.\d+$`)
@@ -1,8 +1,11 @@
 package idmatch
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/src-d/identity-matching/reporter"
 	"github.com/stretchr/testify/require"
 )
 
@@ -130,3 +133,174 @@ func TestIsIgnoredEmail(t *testing.T) {
 		require.False(blacklist.isIgnoredEmail(email))
 	}
 }
+
+func TestReadBlacklistYAML(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.yaml")
+	defer cleanup()
+	_, err := f.WriteString(`names:
+  - Jenkins CI
+  - "  Build  Bot "
+emails:
+  - ci@company.com
+domains:
+  - build.company.com
+`)
+	req.NoError(err)
+	blacklist, err := ReadBlacklist(f.Name())
+	req.NoError(err)
+	req.Equal(Blacklist{
+		Domains:           map[string]struct{}{"build.company.com": {}},
+		TopLevelDomains:   map[string]struct{}{},
+		Names:             map[string]struct{}{"jenkins ci": {}, "build bot": {}},
+		Emails:            map[string]struct{}{"ci@company.com": {}},
+		PopularEmails:     map[string]struct{}{},
+		PopularNames:      map[string]struct{}{},
+		DisposableDomains: map[string]struct{}{},
+	}, blacklist)
+}
+
+func TestReadBlacklistCSV(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := f.WriteString("kind,value\npopular_names,Łukasz\ntop_level_domains,corp\n")
+	req.NoError(err)
+	blacklist, err := ReadBlacklist(f.Name())
+	req.NoError(err)
+	req.Equal(map[string]struct{}{"lukasz": {}}, blacklist.PopularNames)
+	req.Equal(map[string]struct{}{"corp": {}}, blacklist.TopLevelDomains)
+}
+
+func TestReadBlacklistErrors(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.yml")
+	defer cleanup()
+	_, err := f.WriteString("bots:\n  - jenkins\n")
+	req.NoError(err)
+	_, err = ReadBlacklist(f.Name())
+	req.EqualError(err, "unknown blacklist kind: bots")
+
+	f2, cleanup2 := tempFile(t, "*.txt")
+	defer cleanup2()
+	_, err = ReadBlacklist(f2.Name())
+	req.Error(err)
+}
+
+func TestLoadBlacklist(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := f.WriteString("kind,value\nnames,jenkins\n")
+	req.NoError(err)
+	blacklist, err := LoadBlacklist(f.Name())
+	req.NoError(err)
+	req.Contains(blacklist.Names, "jenkins")
+	req.Contains(blacklist.Names, "your name")
+	req.Contains(blacklist.PopularNames, "alex")
+}
+
+func TestBlacklistPatterns(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.yaml")
+	defer cleanup()
+	_, err := f.WriteString(`name_patterns:
+  - ^jenkins
+  - \bbot$
+email_patterns:
+  - -bot@
+  - ^noreply@
+`)
+	req.NoError(err)
+	custom, err := ReadBlacklist(f.Name())
+	req.NoError(err)
+	blacklist := newTestBlacklist(t).Merge(custom)
+	req.True(blacklist.isIgnoredName("jenkins ci"))
+	req.True(blacklist.isIgnoredName("release bot"))
+	req.True(blacklist.isIgnoredName("unknown"))
+	req.False(blacklist.isIgnoredName("robot"))
+	req.True(blacklist.isIgnoredEmail("deploy-bot@company.com"))
+	req.True(blacklist.isIgnoredEmail("noreply@company.com"))
+	req.False(blacklist.isIgnoredEmail("bot@company.com"))
+
+	f2, cleanup2 := tempFile(t, "*.csv")
+	defer cleanup2()
+	_, err = f2.WriteString("kind,value\nemail_patterns,(unclosed\n")
+	req.NoError(err)
+	_, err = ReadBlacklist(f2.Name())
+	req.Error(err)
+}
+
+func TestBlacklistUnmatchableEmails(t *testing.T) {
+	req := require.New(t)
+	blacklist, err := NewBlacklist()
+	req.NoError(err)
+	for _, email := range []string{
+		"12345+bob@users.noreply.github.com", "noreply@github.com", "no-reply@company.com",
+		"do-not-reply@company.com", "bob@localhost.localdomain", "bob@laptop.local",
+		"bob@mailinator.com", "bob@eu.mailinator.com", "bob@users.noreply.gitlab.com"} {
+		req.True(blacklist.isUnmatchableEmail(email), email)
+	}
+	for _, email := range []string{
+		"bob@google.com", "reply@company.com", "noreply.fan@company.com", "bob@notmailinator.com",
+		"bob@local.company.com"} {
+		req.False(blacklist.isUnmatchableEmail(email), email)
+	}
+
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err = f.WriteString("kind,value\ndisposable_domains,Throwaway.io\nnoreply_patterns,^bounce@\n")
+	req.NoError(err)
+	custom, err := ReadBlacklist(f.Name())
+	req.NoError(err)
+	blacklist = blacklist.Merge(custom)
+	req.True(blacklist.isUnmatchableEmail("bob@throwaway.io"))
+	req.True(blacklist.isUnmatchableEmail("bounce@company.com"))
+	req.True(blacklist.isUnmatchableEmail("bob@yopmail.com"))
+	req.False(blacklist.isIgnoredEmail("bob@yopmail.com"))
+}
+
+func TestBlacklistWithPopular(t *testing.T) {
+	req := require.New(t)
+	blacklist := newTestBlacklist(t)
+	nameFreqs := map[string]*Frequency{"bob": {Recent: 1, Total: 6}, "alice": {Recent: 1, Total: 3}, "root": {Recent: 0, Total: 1}}
+	emailFreqs := map[string]*Frequency{"bob@google.com": {Recent: 1, Total: 2}, "ci@google.com": {Recent: 5, Total: 8}}
+
+	same := blacklist.WithPopular(nameFreqs, emailFreqs, PopularityThresholds{})
+	req.Equal(blacklist, same)
+
+	extended := blacklist.WithPopular(nameFreqs, emailFreqs, PopularityThresholds{
+		MinNameCount: 5, MinEmailShare: 0.5})
+	req.Equal(map[string]struct{}{"popular": {}, "bob": {}}, extended.PopularNames)
+	req.Equal(map[string]struct{}{"popular@email.com": {}, "ci@google.com": {}}, extended.PopularEmails)
+	req.NotContains(blacklist.PopularNames, "bob")
+
+	extended = blacklist.WithPopular(nameFreqs, emailFreqs, PopularityThresholds{MinNameShare: 0.3})
+	req.Equal(map[string]struct{}{"popular": {}, "bob": {}, "alice": {}}, extended.PopularNames)
+
+	nameFreqs["bob"].Decayed = 0.5
+	nameFreqs["alice"].Decayed = 2.5
+	extended = blacklist.WithPopular(nameFreqs, emailFreqs, PopularityThresholds{
+		MinNameCount: 2, Frequencies: FrequencyOptions{HalfLife: 24 * time.Hour}})
+	req.Equal(map[string]struct{}{"popular": {}, "alice": {}}, extended.PopularNames)
+}
+
+func TestBlacklistWithPopularReportedOnce(t *testing.T) {
+	req := require.New(t)
+	reporter.Reset()
+	defer reporter.Reset()
+	blacklist := newTestBlacklist(t)
+	popularity := PopularityThresholds{MinNameCount: 2}
+	signatures := []Signature{
+		{Repo: "repo1", Name: "Bob Smith", Email: "bob@google.com", Hash: "aaa"},
+		{Repo: "repo1", Name: "Bob Smith", Email: "bob@apple.com", Hash: "bbb"},
+		{Repo: "repo1", Name: "Alice Jones", Email: "alice@google.com", Hash: "ccc"},
+	}
+	_, nameFreqs, emailFreqs, err := PeopleFromSignatures(context.Background(), signatures,
+		ExtractionOptions{}, blacklist, popularity, BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	extended := blacklist.WithPopular(nameFreqs, emailFreqs, popularity)
+	req.Contains(extended.PopularNames, "bob smith")
+	count, _ := reporter.Get("popular names by frequency")
+	req.Equal(1, count)
+}
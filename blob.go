@@ -0,0 +1,156 @@
+package idmatch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go-source/writerfile"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// BlobBackend reads and writes the objects of an object storage, e.g. S3 or Google Cloud
+// Storage. The paths with the scheme of a registered backend, such as "s3://bucket/key",
+// are read and written with the backend instead of the local file system, see
+// RegisterBlobBackend.
+type BlobBackend interface {
+	// NewReader opens the object. The error satisfies os.IsNotExist if the object does not exist.
+	NewReader(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	// NewWriter creates or replaces the object, which is stored when the writer is closed.
+	NewWriter(ctx context.Context, bucket, key string) (io.WriteCloser, error)
+}
+
+var blobBackends = struct {
+	sync.RWMutex
+	schemes map[string]BlobBackend
+}{schemes: map[string]BlobBackend{}}
+
+// RegisterBlobBackend makes the paths "<scheme>://bucket/key" refer to the objects of
+// the backend. The nil backend unregisters the scheme.
+func RegisterBlobBackend(scheme string, backend BlobBackend) {
+	blobBackends.Lock()
+	defer blobBackends.Unlock()
+	if backend == nil {
+		delete(blobBackends.schemes, scheme)
+		return
+	}
+	blobBackends.schemes[scheme] = backend
+}
+
+// parseBlobURL returns the backend, the bucket and the key of the object in path. The backend
+// is nil if path is local.
+func parseBlobURL(path string) (BlobBackend, string, string, error) {
+	index := strings.Index(path, "://")
+	if index < 0 {
+		return nil, "", "", nil
+	}
+	blobBackends.RLock()
+	backend := blobBackends.schemes[path[:index]]
+	blobBackends.RUnlock()
+	if backend == nil {
+		return nil, "", "", fmt.Errorf("unsupported object storage %s in %s", path[:index], path)
+	}
+	bucket := path[index+len("://"):]
+	slash := strings.Index(bucket, "/")
+	if slash <= 0 || slash == len(bucket)-1 {
+		return nil, "", "", fmt.Errorf("the object URL must be %s://bucket/key, got %s",
+			path[:index], path)
+	}
+	return backend, bucket[:slash], bucket[slash+1:], nil
+}
+
+// isBlobURL returns whether path refers to an object storage rather than to a local file.
+func isBlobURL(path string) bool {
+	return strings.Contains(path, "://")
+}
+
+// OpenPath opens the local file or the object of the registered BlobBackend for reading.
+func OpenPath(path string) (io.ReadCloser, error) {
+	backend, bucket, key, err := parseBlobURL(path)
+	if err != nil {
+		return nil, err
+	}
+	if backend == nil {
+		return os.Open(path)
+	}
+	return backend.NewReader(context.Background(), bucket, key)
+}
+
+// CreatePath creates or truncates the local file or the object of the registered BlobBackend
+// for writing.
+func CreatePath(path string) (io.WriteCloser, error) {
+	backend, bucket, key, err := parseBlobURL(path)
+	if err != nil {
+		return nil, err
+	}
+	if backend == nil {
+		return os.Create(path)
+	}
+	return backend.NewWriter(context.Background(), bucket, key)
+}
+
+// pathExists returns whether the local file or the object exists.
+func pathExists(path string) (bool, error) {
+	var err error
+	if isBlobURL(path) {
+		var reader io.ReadCloser
+		if reader, err = OpenPath(path); err == nil {
+			err = reader.Close()
+		}
+	} else {
+		_, err = os.Stat(path)
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// openParquetFile opens the local parquet file or downloads the parquet object to memory
+// because the parquet reader seeks.
+func openParquetFile(path string) (source.ParquetFile, error) {
+	if !isBlobURL(path) {
+		return local.NewLocalFileReader(path)
+	}
+	reader, err := OpenPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(reader)
+	errClose := reader.Close()
+	if err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buffer.NewBufferFile(data)
+}
+
+// createParquetFile creates the local parquet file or the parquet object.
+func createParquetFile(path string) (source.ParquetFile, error) {
+	if !isBlobURL(path) {
+		return local.NewLocalFileWriter(path)
+	}
+	writer, err := CreatePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return blobParquetFile{writerfile.NewWriterFile(writer), writer}, nil
+}
+
+// blobParquetFile stores the written parquet object on Close.
+type blobParquetFile struct {
+	source.ParquetFile
+	writer io.WriteCloser
+}
+
+func (file blobParquetFile) Close() error {
+	return file.writer.Close()
+}
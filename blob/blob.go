@@ -0,0 +1,16 @@
+// Package blob implements the idmatch.BlobBackend-s of S3 and Google Cloud Storage. The clients
+// are created on the first use with the default credentials of each cloud, so registering
+// the backends costs nothing if the paths are local:
+//
+//	idmatch.RegisterBlobBackend("s3", blob.NewS3())
+//	idmatch.RegisterBlobBackend("gs", blob.NewGCS())
+package blob
+
+import (
+	"os"
+)
+
+// notExist returns the error which satisfies os.IsNotExist for the missing object.
+func notExist(scheme, bucket, key string) error {
+	return &os.PathError{Op: "open", Path: scheme + "://" + bucket + "/" + key, Err: os.ErrNotExist}
+}
@@ -0,0 +1,51 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// GCS reads and writes the objects "gs://bucket/key" of Google Cloud Storage.
+type GCS struct {
+	options []option.ClientOption
+	once    sync.Once
+	client  *storage.Client
+	err     error
+}
+
+// NewGCS creates the Google Cloud Storage backend. The client uses the application default
+// credentials unless options say otherwise.
+func NewGCS(options ...option.ClientOption) *GCS {
+	return &GCS{options: options}
+}
+
+func (b *GCS) init() error {
+	b.once.Do(func() {
+		b.client, b.err = storage.NewClient(context.Background(), b.options...)
+	})
+	return b.err
+}
+
+// NewReader opens the object, see idmatch.BlobBackend.
+func (b *GCS) NewReader(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	if err := b.init(); err != nil {
+		return nil, err
+	}
+	reader, err := b.client.Bucket(bucket).Object(key).NewReader(ctx)
+	if err == storage.ErrObjectNotExist || err == storage.ErrBucketNotExist {
+		return nil, notExist("gs", bucket, key)
+	}
+	return reader, err
+}
+
+// NewWriter uploads the object, see idmatch.BlobBackend.
+func (b *GCS) NewWriter(ctx context.Context, bucket, key string) (io.WriteCloser, error) {
+	if err := b.init(); err != nil {
+		return nil, err
+	}
+	return b.client.Bucket(bucket).Object(key).NewWriter(ctx), nil
+}
@@ -0,0 +1,91 @@
+package blob
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3 reads and writes the objects "s3://bucket/key".
+type S3 struct {
+	configs []*aws.Config
+	once    sync.Once
+	session *session.Session
+	err     error
+}
+
+// NewS3 creates the S3 backend. The session is configured with the environment, the shared
+// configuration files and then configs, see session.NewSessionWithOptions.
+func NewS3(configs ...*aws.Config) *S3 {
+	return &S3{configs: configs}
+}
+
+func (b *S3) init() error {
+	b.once.Do(func() {
+		config := aws.NewConfig()
+		config.MergeIn(b.configs...)
+		b.session, b.err = session.NewSessionWithOptions(session.Options{
+			Config:            *config,
+			SharedConfigState: session.SharedConfigEnable,
+		})
+	})
+	return b.err
+}
+
+// NewReader opens the object, see idmatch.BlobBackend.
+func (b *S3) NewReader(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	if err := b.init(); err != nil {
+		return nil, err
+	}
+	output, err := s3.New(b.session).GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && (awsErr.Code() == s3.ErrCodeNoSuchKey ||
+			awsErr.Code() == s3.ErrCodeNoSuchBucket) {
+			return nil, notExist("s3", bucket, key)
+		}
+		return nil, err
+	}
+	return output.Body, nil
+}
+
+// NewWriter streams the object to S3 with the multipart upload, see idmatch.BlobBackend.
+func (b *S3) NewWriter(ctx context.Context, bucket, key string) (io.WriteCloser, error) {
+	if err := b.init(); err != nil {
+		return nil, err
+	}
+	reader, writer := io.Pipe()
+	w := &s3Writer{PipeWriter: writer, done: make(chan error, 1)}
+	go func() {
+		_, err := s3manager.NewUploader(b.session).UploadWithContext(ctx, &s3manager.UploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   reader,
+		})
+		// unblock the writes if the upload failed
+		reader.CloseWithError(err)
+		w.done <- err
+	}()
+	return w, nil
+}
+
+// s3Writer feeds the upload and waits for it to finish on Close.
+type s3Writer struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (w *s3Writer) Close() error {
+	if err := w.PipeWriter.Close(); err != nil {
+		return err
+	}
+	return <-w.done
+}
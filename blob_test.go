@@ -0,0 +1,112 @@
+package idmatch
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// memoryBlobBackend keeps the objects in memory.
+type memoryBlobBackend struct {
+	lock    sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *memoryBlobBackend) NewReader(ctx context.Context, bucket, key string) (
+	io.ReadCloser, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	data, exists := b.objects[bucket+"/"+key]
+	if !exists {
+		return nil, &os.PathError{Op: "open", Path: bucket + "/" + key, Err: os.ErrNotExist}
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (b *memoryBlobBackend) NewWriter(ctx context.Context, bucket, key string) (
+	io.WriteCloser, error) {
+	return &memoryBlobWriter{backend: b, name: bucket + "/" + key}, nil
+}
+
+type memoryBlobWriter struct {
+	bytes.Buffer
+	backend *memoryBlobBackend
+	name    string
+}
+
+func (w *memoryBlobWriter) Close() error {
+	w.backend.lock.Lock()
+	defer w.backend.lock.Unlock()
+	w.backend.objects[w.name] = w.Bytes()
+	return nil
+}
+
+func registerTestBlobBackend(t *testing.T) *memoryBlobBackend {
+	backend := &memoryBlobBackend{objects: map[string][]byte{}}
+	RegisterBlobBackend("mem", backend)
+	t.Cleanup(func() { RegisterBlobBackend("mem", nil) })
+	return backend
+}
+
+func TestBlobSignatures(t *testing.T) {
+	req := require.New(t)
+	backend := registerTestBlobBackend(t)
+	req.NoError(storeSignaturesOnDisk("mem://bucket/signatures.csv", Signatures))
+	req.Contains(backend.objects, "bucket/signatures.csv")
+	cached, err := readSignaturesFromDisk(nil, "mem://bucket/signatures.csv")
+	req.NoError(err)
+	req.Len(cached, len(Signatures))
+
+	exists, err := pathExists("mem://bucket/signatures.csv")
+	req.NoError(err)
+	req.True(exists)
+	exists, err = pathExists("mem://bucket/missing.csv")
+	req.NoError(err)
+	req.False(exists)
+	_, err = findSignatures(nil, "", "mem://bucket/missing.arrow", ExtractionOptions{})
+	req.True(os.IsNotExist(err))
+}
+
+func TestBlobParquet(t *testing.T) {
+	req := require.New(t)
+	backend := registerTestBlobBackend(t)
+	people := newEncoderTestPeople()
+	req.NoError(people.WriteToParquet("mem://bucket/people.parquet", "github"))
+	req.NotEmpty(backend.objects["bucket/people-aliases.parquet"])
+	req.NotEmpty(backend.objects["bucket/people-identities.parquet"])
+	stored, provider, err := readFromParquet("mem://bucket/people.parquet")
+	req.NoError(err)
+	req.Equal("github", provider)
+	req.Len(stored, 2)
+	req.Equal(people[2].ExternalIDs["github"], stored[2].ExternalIDs["github"])
+	req.Equal(people[1].Emails, stored[1].Emails)
+
+	req.NoError(people.WritePartitionedParquet("mem://bucket/parts", "github",
+		PartitionOptions{PeoplePerPartition: 1}))
+	req.NotEmpty(backend.objects["bucket/parts/manifest.json"])
+	req.NotEmpty(backend.objects["bucket/parts/part-00001-identities.parquet"])
+	stored, _, err = ReadIdentitiesFromParquet("mem://bucket/parts", nil)
+	req.NoError(err)
+	req.Len(stored, 2)
+	req.Equal(people[1].Emails, stored[1].Emails)
+}
+
+func TestBlobURLErrors(t *testing.T) {
+	req := require.New(t)
+	_, err := OpenPath("ftp://bucket/key")
+	req.EqualError(err, "unsupported object storage ftp in ftp://bucket/key")
+	registerTestBlobBackend(t)
+	for _, path := range []string{"mem://bucket", "mem:///key", "mem://bucket/"} {
+		_, err = CreatePath(path)
+		req.EqualError(err, "the object URL must be mem://bucket/key, got "+path)
+	}
+	RegisterBlobBackend("mem", nil)
+	_, err = OpenPath("mem://bucket/key")
+	req.EqualError(err, "unsupported object storage mem in mem://bucket/key")
+}
@@ -0,0 +1,334 @@
+package idmatch
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// Blocker generates the blocking keys of the signatures for the candidate pair generation:
+// the identities which share a key form the candidate pairs which PairScorer compares, in
+// addition to the pairs connected by the other evidence, see ReduceOptions.Blockers. The coarse
+// keys find more pairs at the cost of the runtime.
+type Blocker interface {
+	// BlockingKeys returns the keys of the signature with the given cleaned name and email.
+	// Either may be empty.
+	BlockingKeys(name, email string) []string
+}
+
+// Blockers are the built-in blockers by name:
+// "email" - the same email;
+// "name" - the same name with the words in any order;
+// "initial_domain" - the same first letter of the name and the same email domain;
+// "metaphone" - the names which sound alike, see metaphone;
+// "soundex" - the names which sound alike and start with the same letter, see soundex.
+var Blockers = map[string]Blocker{
+	"email":          emailBlocker{},
+	"name":           nameBlocker{},
+	"initial_domain": initialDomainBlocker{},
+	"metaphone":      phoneticBlocker{metaphone},
+	"soundex":        phoneticBlocker{soundex},
+}
+
+// ParseBlockers returns the built-in blockers with the given names.
+func ParseBlockers(names []string) ([]Blocker, error) {
+	var blockers []Blocker
+	for _, name := range names {
+		blocker, exists := Blockers[name]
+		if !exists {
+			return nil, fmt.Errorf("unknown blocker: %s", name)
+		}
+		blockers = append(blockers, blocker)
+	}
+	return blockers, nil
+}
+
+type emailBlocker struct{}
+
+func (emailBlocker) BlockingKeys(name, email string) []string {
+	if email == "" {
+		return nil
+	}
+	return []string{email}
+}
+
+type nameBlocker struct{}
+
+func (nameBlocker) BlockingKeys(name, email string) []string {
+	tokens := splitNameTokens(strings.ToLower(name))
+	if len(tokens) == 0 {
+		return nil
+	}
+	sort.Strings(tokens)
+	return []string{strings.Join(tokens, " ")}
+}
+
+type initialDomainBlocker struct{}
+
+func (initialDomainBlocker) BlockingKeys(name, email string) []string {
+	tokens := splitNameTokens(strings.ToLower(name))
+	_, domain := splitEmailAddress(email)
+	if len(tokens) == 0 || domain == "" {
+		return nil
+	}
+	initial := []rune(tokens[0])[0]
+	return []string{string(initial) + "@" + domain}
+}
+
+// phoneticBlocker encodes each word of the name with the phonetic algorithm, so that
+// the misspelled names, e.g. "Stephen" and "Steven", fall into the same block. The key is
+// the sorted codes of the words.
+type phoneticBlocker struct {
+	encode func(word string) string
+}
+
+func (blocker phoneticBlocker) BlockingKeys(name, email string) []string {
+	var codes []string
+	for _, token := range splitNameTokens(name) {
+		if code := blocker.encode(token); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	sort.Strings(codes)
+	return []string{strings.Join(codes, " ")}
+}
+
+// metaphone returns the Metaphone phonetic code of the word by Lawrence Philips, so that
+// the English names which sound alike have the same code, e.g. "Smith" and "Smyth" are both
+// "SM0". The letters outside of A-Z are ignored, so the names should be transliterated first.
+func metaphone(word string) string {
+	var letters []byte
+	for _, r := range strings.ToUpper(word) {
+		if r >= 'A' && r <= 'Z' {
+			letters = append(letters, byte(r))
+		}
+	}
+	if len(letters) == 0 {
+		return ""
+	}
+	switch {
+	case len(letters) > 1 && (string(letters[:2]) == "AE" || string(letters[:2]) == "GN" ||
+		string(letters[:2]) == "KN" || string(letters[:2]) == "PN" || string(letters[:2]) == "WR"):
+		letters = letters[1:]
+	case letters[0] == 'X':
+		letters[0] = 'S'
+	case len(letters) > 1 && string(letters[:2]) == "WH":
+		letters = letters[1:]
+		letters[0] = 'W'
+	}
+	at := func(i int) byte {
+		if i < 0 || i >= len(letters) {
+			return 0
+		}
+		return letters[i]
+	}
+	isVowel := func(c byte) bool {
+		return c == 'A' || c == 'E' || c == 'I' || c == 'O' || c == 'U'
+	}
+	isFrontVowel := func(c byte) bool {
+		return c == 'E' || c == 'I' || c == 'Y'
+	}
+	var code strings.Builder
+	for i, c := range letters {
+		if c == at(i-1) && c != 'C' {
+			continue
+		}
+		next := at(i + 1)
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				code.WriteByte(c)
+			}
+		case 'B':
+			if !(at(i-1) == 'M' && i == len(letters)-1) {
+				code.WriteByte('B')
+			}
+		case 'C':
+			switch {
+			case next == 'I' && at(i+2) == 'A':
+				code.WriteByte('X')
+			case next == 'H':
+				if at(i-1) == 'S' {
+					code.WriteByte('K')
+				} else {
+					code.WriteByte('X')
+				}
+			case isFrontVowel(next):
+				if at(i-1) != 'S' {
+					code.WriteByte('S')
+				}
+			default:
+				code.WriteByte('K')
+			}
+		case 'D':
+			if next == 'G' && isFrontVowel(at(i+2)) {
+				code.WriteByte('J')
+			} else {
+				code.WriteByte('T')
+			}
+		case 'G':
+			switch {
+			case next == 'H' && i+2 < len(letters) && !isVowel(at(i+2)):
+			case next == 'N' && (i+2 == len(letters) ||
+				(string(letters[i+1:]) == "NED")):
+			case isFrontVowel(next) && at(i-1) != 'G':
+				code.WriteByte('J')
+			default:
+				code.WriteByte('K')
+			}
+		case 'H':
+			previous := at(i - 1)
+			if (isVowel(previous) && !isVowel(next)) || previous == 'C' || previous == 'S' ||
+				previous == 'P' || previous == 'T' || previous == 'G' {
+				continue
+			}
+			code.WriteByte('H')
+		case 'K':
+			if at(i-1) != 'C' {
+				code.WriteByte('K')
+			}
+		case 'P':
+			if next == 'H' {
+				code.WriteByte('F')
+			} else {
+				code.WriteByte('P')
+			}
+		case 'Q':
+			code.WriteByte('K')
+		case 'S':
+			if next == 'H' || (next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A')) {
+				code.WriteByte('X')
+			} else {
+				code.WriteByte('S')
+			}
+		case 'T':
+			switch {
+			case next == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				code.WriteByte('X')
+			case next == 'H':
+				code.WriteByte('0')
+			case next == 'C' && at(i+2) == 'H':
+			default:
+				code.WriteByte('T')
+			}
+		case 'V':
+			code.WriteByte('F')
+		case 'W', 'Y':
+			if isVowel(next) {
+				code.WriteByte(c)
+			}
+		case 'X':
+			code.WriteString("KS")
+		case 'Z':
+			code.WriteByte('S')
+		default:
+			code.WriteByte(c)
+		}
+	}
+	return code.String()
+}
+
+// soundexCodes are the Soundex digits of the letters from A to Z; 0 means that the letter
+// is not encoded.
+const soundexCodes = "01230120022455012623010202"
+
+// soundex returns the American Soundex code of the word: the first letter followed by
+// three digits, e.g. "Robert" and "Rupert" are both "R163". Unlike metaphone, the names
+// which start with different letters never have the same code, e.g. "Cathy" and "Kathy".
+// The letters outside of A-Z are ignored.
+func soundex(word string) string {
+	var code []byte
+	var last byte
+	for _, r := range strings.ToUpper(word) {
+		if r < 'A' || r > 'Z' {
+			continue
+		}
+		digit := soundexCodes[r-'A']
+		if len(code) == 0 {
+			code = append(code, byte(r))
+			last = digit
+			continue
+		}
+		if digit != '0' && digit != last {
+			code = append(code, digit)
+			if len(code) == 4 {
+				break
+			}
+		}
+		// H and W do not separate the letters with the same code, the vowels do
+		if r != 'H' && r != 'W' {
+			last = digit
+		}
+	}
+	if len(code) == 0 {
+		return ""
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
+// addBlockedCandidates records the pairs of the identities which share a blocking key of any
+// blocker as the candidates, see IdentityGraph.candidateEdges. The blocks of more than
+// ReduceOptions.MaxBlockSize identities are skipped because they are too coarse to be useful
+// and would take quadratic time.
+func addBlockedCandidates(peopleGraph *IdentityGraph, people People, ids []int64, opts ReduceOptions) {
+	blocks := map[string][]int64{}
+	for _, id := range ids {
+		person := people[id]
+		names := []string{""}
+		if len(person.NamesWithRepos) > 0 {
+			names = names[:0]
+			for _, name := range person.NamesWithRepos {
+				names = append(names, name.Name)
+			}
+		}
+		emails := person.Emails
+		if len(emails) == 0 {
+			emails = []string{""}
+		}
+		keys := map[string]struct{}{}
+		for index, blocker := range opts.Blockers {
+			prefix := strconv.Itoa(index) + "\x00"
+			for _, name := range names {
+				for _, email := range emails {
+					for _, key := range blocker.BlockingKeys(name, email) {
+						keys[prefix+key] = struct{}{}
+					}
+				}
+			}
+		}
+		for key := range keys {
+			blocks[key] = append(blocks[key], id)
+		}
+	}
+	keys := make([]string, 0, len(blocks))
+	for key := range blocks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	skipped := 0
+	for _, key := range keys {
+		block := blocks[key]
+		if opts.MaxBlockSize > 0 && len(block) > opts.MaxBlockSize {
+			skipped++
+			continue
+		}
+		Int64Slice(block).Sort()
+		for i, id1 := range block {
+			for _, id2 := range block[i+1:] {
+				peopleGraph.addCandidate(id1, id2)
+			}
+		}
+	}
+	reporter.Commit("blocks", len(blocks))
+	reporter.Commit("oversized blocks", skipped)
+	reporter.Commit("blocked candidate pairs", len(peopleGraph.candidates))
+}
@@ -0,0 +1,123 @@
+package idmatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testDomainBlocker struct{}
+
+func (testDomainBlocker) BlockingKeys(name, email string) []string {
+	_, domain := splitEmailAddress(email)
+	return []string{domain}
+}
+
+func TestParseBlockers(t *testing.T) {
+	req := require.New(t)
+	blockers, err := ParseBlockers([]string{"email", "metaphone"})
+	req.NoError(err)
+	req.Len(blockers, 2)
+	req.Equal(emailBlocker{}, blockers[0])
+	blockers, err = ParseBlockers(nil)
+	req.NoError(err)
+	req.Nil(blockers)
+	_, err = ParseBlockers([]string{"nysiis"})
+	req.EqualError(err, "unknown blocker: nysiis")
+}
+
+func TestBuiltinBlockers(t *testing.T) {
+	req := require.New(t)
+	req.Equal([]string{"bob@google.com"}, Blockers["email"].BlockingKeys("bob", "bob@google.com"))
+	req.Nil(Blockers["email"].BlockingKeys("bob", ""))
+	req.Equal([]string{"john smith"}, Blockers["name"].BlockingKeys("Smith, John", "js@google.com"))
+	req.Nil(Blockers["name"].BlockingKeys("", "js@google.com"))
+	req.Equal([]string{"j@google.com"},
+		Blockers["initial_domain"].BlockingKeys("john smith", "js@google.com"))
+	req.Nil(Blockers["initial_domain"].BlockingKeys("john smith", "js"))
+	req.Equal([]string{"JN SM0"}, Blockers["metaphone"].BlockingKeys("smyth john", ""))
+	req.Equal(Blockers["metaphone"].BlockingKeys("john smith", ""),
+		Blockers["metaphone"].BlockingKeys("jon smyth", ""))
+}
+
+func TestMetaphone(t *testing.T) {
+	req := require.New(t)
+	for word, code := range map[string]string{
+		"":          "",
+		"Smith":     "SM0",
+		"Schmidt":   "SKMTT",
+		"Knight":    "NT",
+		"Thompson":  "0MPSN",
+		"Philip":    "FLP",
+		"Xavier":    "SFR",
+		"Wright":    "RT",
+		"Catherine": "K0RN",
+		"Katherine": "K0RN",
+		"Bach":      "BX",
+		"Lamb":      "LM",
+		"Science":   "SNS",
+	} {
+		req.Equal(code, metaphone(word), word)
+	}
+}
+
+func TestSoundex(t *testing.T) {
+	req := require.New(t)
+	for word, code := range map[string]string{
+		"":         "",
+		"Robert":   "R163",
+		"Rupert":   "R163",
+		"Ashcraft": "A261",
+		"Tymczak":  "T522",
+		"Pfister":  "P236",
+		"Lee":      "L000",
+		"Stephen":  "S315",
+		"Steven":   "S315",
+	} {
+		req.Equal(code, soundex(word), word)
+	}
+}
+
+func TestPhoneticBlockers(t *testing.T) {
+	req := require.New(t)
+	for _, pair := range [][2]string{{"stephen king", "steven king"}, {"cathy smith", "kathy smyth"}} {
+		req.Equal(Blockers["metaphone"].BlockingKeys(pair[0], ""),
+			Blockers["metaphone"].BlockingKeys(pair[1], ""), pair[0])
+	}
+	req.Equal(Blockers["soundex"].BlockingKeys("stephen king", ""),
+		Blockers["soundex"].BlockingKeys("steven king", ""))
+	req.NotEqual(Blockers["soundex"].BlockingKeys("cathy", ""), Blockers["soundex"].BlockingKeys("kathy", ""))
+	req.Nil(Blockers["soundex"].BlockingKeys("", "bob@google.com"))
+}
+
+func TestBuildIdentityGraphBlockers(t *testing.T) {
+	req := require.New(t)
+	opts := ReduceOptions{
+		MaxIdentities:      100,
+		MinEdgeWeight:      0.5,
+		MinPairProbability: 0.6,
+		PairScorer: &LogisticRegression{
+			Intercept: -2, Weights: map[string]float64{"repository_similarity": 4}},
+	}
+	g, err := BuildIdentityGraph(context.Background(), newRepositoryTestPeople(), nil,
+		newTestBlacklist(t), opts)
+	req.NoError(err)
+	req.Equal([][]int64{{1, 2}, {3, 4}, {5}, {6}}, g.Components())
+
+	opts.Blockers = []Blocker{testDomainBlocker{}}
+	g, err = BuildIdentityGraph(context.Background(), newRepositoryTestPeople(), nil,
+		newTestBlacklist(t), opts)
+	req.NoError(err)
+	edge, _ := g.Edge(5, 6)
+	req.Equal([]Evidence{{EvidenceClassifier, "0.88", sigmoid(2)}}, edge.Evidence)
+	_, exists := g.Edge(1, 5)
+	req.False(exists)
+	req.Equal([][]int64{{1, 2}, {3, 4, 5, 6}}, g.Components())
+
+	opts.MaxBlockSize = 5
+	g, err = BuildIdentityGraph(context.Background(), newRepositoryTestPeople(), nil,
+		newTestBlacklist(t), opts)
+	req.NoError(err)
+	req.Equal([][]int64{{1, 2}, {3, 4}, {5}, {6}}, g.Components())
+}
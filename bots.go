@@ -0,0 +1,136 @@
+package idmatch
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// BotDetectionOptions configures the heuristics which detect automated identities.
+// The zero value disables the detection.
+type BotDetectionOptions struct {
+	// Enabled turns the bot detection on.
+	Enabled bool
+	// Exclude removes the detected bots from People instead of setting Person.IsBot.
+	Exclude bool
+	// MinCommits is the minimum number of commits with the same email to apply the timing
+	// heuristics. The signatures fetched from gitbase are aggregated per repository, so the timing
+	// heuristics are effective only with per-commit signatures.
+	MinCommits int
+	// BurstInterval is the maximum time between two consecutive commits to consider them a burst.
+	BurstInterval time.Duration
+	// MaxBurstRatio is the share of the commits made in bursts above which the email is a bot.
+	MaxBurstRatio float64
+	// MaxHourEntropy is the normalized entropy of the hour-of-day commit histogram above which
+	// the activity has no diurnal pattern and the email is a bot. The value is between 0 and 1.
+	MaxHourEntropy float64
+}
+
+// NewBotDetectionOptions returns the enabled bot detection with the default thresholds.
+func NewBotDetectionOptions() BotDetectionOptions {
+	return BotDetectionOptions{
+		Enabled:        true,
+		MinCommits:     1000,
+		BurstInterval:  time.Minute,
+		MaxBurstRatio:  0.5,
+		MaxHourEntropy: 0.97,
+	}
+}
+
+var botNameRegexp = regexp.MustCompile(`\[bot\]|\bbot\b|\bci\b|\bautomation\b|\bautomated\b`)
+
+// isBotName checks whether the clean name looks like the name of an automated account,
+// e.g. "dependabot[bot]", "release-bot" or "acme ci".
+func isBotName(name string) bool {
+	return botNameRegexp.MatchString(name)
+}
+
+// hourEntropy calculates the normalized entropy of the hour-of-day distribution of the times.
+// Each time is taken in its own time zone. 1 means that the commits are evenly spread over
+// the day, 0 means that all of them happen in the same hour.
+func hourEntropy(times []time.Time) float64 {
+	if len(times) == 0 {
+		return 0
+	}
+	var hist [24]int
+	for _, t := range times {
+		hist[t.Hour()]++
+	}
+	entropy := 0.0
+	for _, count := range hist {
+		if count == 0 {
+			continue
+		}
+		p := float64(count) / float64(len(times))
+		entropy -= p * math.Log(p)
+	}
+	return entropy / math.Log(24)
+}
+
+// burstRatio calculates the share of the commits which follow the previous commit within
+// the given interval.
+func burstRatio(times []time.Time, interval time.Duration) float64 {
+	if len(times) < 2 {
+		return 0
+	}
+	sorted := make([]time.Time, len(times))
+	copy(sorted, times)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	bursts := 0
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Sub(sorted[i-1]) <= interval {
+			bursts++
+		}
+	}
+	return float64(bursts) / float64(len(sorted)-1)
+}
+
+// detectBots returns the clean emails which belong to automated accounts according to
+// the name and the commit timing heuristics.
+func detectBots(prog *progress, commits []Signature, opts BotDetectionOptions) (
+	map[string]struct{}, error) {
+	bots := map[string]struct{}{}
+	if !opts.Enabled {
+		return bots, nil
+	}
+	email2times := map[string][]time.Time{}
+	stage := prog.stage("detecting bots", len(commits))
+	defer stage.done()
+	for _, commit := range commits {
+		if err := stage.tick(); err != nil {
+			return nil, err
+		}
+		email, err := cleanEmail(commit.Email)
+		if err != nil {
+			return nil, err
+		}
+		name, err := cleanName(commit.Name)
+		if err != nil {
+			return nil, err
+		}
+		if isBotName(name) {
+			if _, exists := bots[email]; !exists {
+				reporter.Debugf("bot detected by name: %s <%s>", name, email)
+				bots[email] = struct{}{}
+			}
+		}
+		email2times[email] = append(email2times[email], commit.Time)
+	}
+	for email, times := range email2times {
+		if _, exists := bots[email]; exists || opts.MinCommits <= 0 || len(times) < opts.MinCommits {
+			continue
+		}
+		if ratio := burstRatio(times, opts.BurstInterval); ratio > opts.MaxBurstRatio {
+			reporter.Debugf("bot detected by bursty commits: %s (%.2f)", email, ratio)
+			bots[email] = struct{}{}
+		} else if entropy := hourEntropy(times); entropy > opts.MaxHourEntropy {
+			reporter.Debugf("bot detected by the absent diurnal pattern: %s (%.2f)", email, entropy)
+			bots[email] = struct{}{}
+		}
+	}
+	reporter.Commit("bots detected", len(bots))
+	return bots, nil
+}
@@ -0,0 +1,81 @@
+package idmatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsBotName(t *testing.T) {
+	require := require.New(t)
+	for _, name := range []string{"dependabot[bot]", "release-bot", "acme ci", "bot", "test automation"} {
+		require.True(isBotName(name), name)
+	}
+	for _, name := range []string{"talbot", "abbott", "cicero", "bob"} {
+		require.False(isBotName(name), name)
+	}
+}
+
+func TestHourEntropy(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var uniform, daily []time.Time
+	for i := 0; i < 24*10; i++ {
+		uniform = append(uniform, start.Add(time.Duration(i)*time.Hour))
+		daily = append(daily, start.AddDate(0, 0, i).Add(14*time.Hour))
+	}
+	require.InDelta(t, 1, hourEntropy(uniform), 1e-9)
+	require.Equal(t, 0.0, hourEntropy(daily))
+	require.Equal(t, 0.0, hourEntropy(nil))
+}
+
+func TestBurstRatio(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{start.Add(time.Hour), start, start.Add(10 * time.Second), start.Add(20 * time.Second)}
+	require.InDelta(t, 2.0/3, burstRatio(times, time.Minute), 1e-9)
+	require.Equal(t, 0.0, burstRatio(times[:1], time.Minute))
+}
+
+func TestDetectBots(t *testing.T) {
+	require := require.New(t)
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var commits []Signature
+	for i := 0; i < 48; i++ {
+		commits = append(commits, Signature{
+			Repo: "repo", Name: "Nightly", Email: "nightly@company.com", Hash: "x",
+			Time: start.Add(time.Duration(i) * time.Hour)})
+		commits = append(commits, Signature{
+			Repo: "repo", Name: "Alice", Email: "alice@company.com", Hash: "x",
+			Time: start.AddDate(0, 0, i).Add(time.Duration(10+i%8) * time.Hour)})
+	}
+	commits = append(commits, Signature{
+		Repo: "repo", Name: "Release Bot", Email: "release@company.com", Hash: "y", Time: start})
+
+	bots, err := detectBots(nil, commits, BotDetectionOptions{})
+	require.NoError(err)
+	require.Empty(bots)
+
+	opts := NewBotDetectionOptions()
+	opts.MinCommits = 10
+	bots, err = detectBots(nil, commits, opts)
+	require.NoError(err)
+	require.Equal(map[string]struct{}{"nightly@company.com": {}, "release@company.com": {}}, bots)
+}
+
+func TestPeopleMarkBots(t *testing.T) {
+	bots := map[string]struct{}{"bot@google.com": {}}
+	newPeople := func() People {
+		return People{
+			1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+			2: {ID: 2, NamesWithRepos: []NameWithRepo{{"ci", ""}}, Emails: []string{"bot@google.com"}},
+		}
+	}
+	people := newPeople()
+	people.markBots(bots, false)
+	require.False(t, people[1].IsBot)
+	require.True(t, people[2].IsBot)
+	people = newPeople()
+	people.markBots(bots, true)
+	require.Len(t, people, 1)
+	require.Contains(t, people, int64(1))
+}
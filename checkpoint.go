@@ -0,0 +1,218 @@
+package idmatch
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// signatureSource fetches the signatures repository by repository.
+type signatureSource interface {
+	// Repositories returns the sorted repository identifiers.
+	Repositories(ctx context.Context) ([]string, error)
+	// Signatures returns the signatures in the given repository.
+	Signatures(ctx context.Context, repo string) ([]Signature, error)
+}
+
+// extractionCheckpoint is the cursor of the interrupted signatures extraction.
+// The signatures are aggregated per repository so the cursor is the last fully extracted
+// repository together with the size of the partial CSV file at that moment.
+type extractionCheckpoint struct {
+	Repository string `json:"repository"`
+	Offset     int64  `json:"offset"`
+	Signatures int    `json:"signatures"`
+}
+
+// checkpointPaths returns the paths to the partial CSV file and to the checkpoint of
+// the extraction which is going to be cached in cachePath.
+func checkpointPaths(cachePath string) (partialPath, checkpointPath string) {
+	return cachePath + ".partial", cachePath + ".checkpoint"
+}
+
+// readCheckpoint loads the checkpoint. It returns nil if the file does not exist.
+func readCheckpoint(path string) (*extractionCheckpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &extractionCheckpoint{}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+// writeCheckpoint atomically replaces the checkpoint.
+func writeCheckpoint(path string, checkpoint extractionCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".tmp", data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// openPartialSignatures opens the partial CSV file and the checkpoint to continue from.
+// The file is truncated to the checkpoint so that the signatures of the repository which was
+// being extracted during the interruption are not duplicated. Without resume or without
+// the checkpoint the extraction starts from scratch.
+func openPartialSignatures(partialPath, checkpointPath string, resume bool) (
+	*os.File, *extractionCheckpoint, error) {
+	var checkpoint *extractionCheckpoint
+	if resume {
+		var err error
+		if checkpoint, err = readCheckpoint(checkpointPath); err != nil {
+			return nil, nil, err
+		}
+	}
+	if checkpoint == nil {
+		file, err := os.Create(partialPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		writer := csv.NewWriter(file)
+		err = writer.Write(signatureCSVHeader)
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		offset, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		return file, &extractionCheckpoint{Offset: offset}, nil
+	}
+	reporter.Infof("resuming the extraction after repository %s with %d signatures",
+		checkpoint.Repository, checkpoint.Signatures)
+	file, err := os.OpenFile(partialPath, os.O_WRONLY, 0666)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = file.Truncate(checkpoint.Offset); err == nil {
+		_, err = file.Seek(checkpoint.Offset, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return file, checkpoint, nil
+}
+
+// extractSignatures fetches the signatures from the source repository by repository and
+// appends them to the partial CSV file, saving the checkpoint after each repository.
+// If resume is true and the checkpoint exists, the repositories up to and including
+// the checkpoint repository are skipped.
+// The complete file is renamed to cachePath and read back, or, if cachePath is compressed or
+// parquet or chunkSize is positive, read and written with storeSignatureCache.
+func extractSignatures(prog *progress, source signatureSource, cachePath string, resume bool,
+	chunkSize int) (commits []Signature, err error) {
+	partialPath, checkpointPath := checkpointPaths(cachePath)
+	file, checkpoint, err := openPartialSignatures(partialPath, checkpointPath, resume)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if file == nil {
+			return
+		}
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	repos, err := source.Repositories(prog.context())
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint.Repository != "" {
+		index := -1
+		for i, repo := range repos {
+			if repo == checkpoint.Repository {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return nil, fmt.Errorf("the checkpoint repository %s does not exist, "+
+				"restart the extraction from scratch", checkpoint.Repository)
+		}
+		repos = repos[index+1:]
+	}
+	writer := csv.NewWriter(file)
+	stage := prog.stage("reading signatures", 0)
+	stage.processed = checkpoint.Signatures
+	for _, repo := range repos {
+		signatures, err := source.Signatures(prog.context(), repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, signature := range signatures {
+			if err := stage.tick(); err != nil {
+				return nil, err
+			}
+			if err := writer.Write(signatureRecord(signature)); err != nil {
+				return nil, err
+			}
+		}
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return nil, err
+		}
+		if err := file.Sync(); err != nil {
+			return nil, err
+		}
+		offset, err := file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		checkpoint.Repository = repo
+		checkpoint.Offset = offset
+		checkpoint.Signatures += len(signatures)
+		if err := writeCheckpoint(checkpointPath, *checkpoint); err != nil {
+			return nil, err
+		}
+	}
+	stage.done()
+	err = file.Close()
+	file = nil
+	if err != nil {
+		return nil, err
+	}
+	if !isCompressed(cachePath) && !strings.HasSuffix(cachePath, ".parquet") && chunkSize <= 0 {
+		if err := os.Rename(partialPath, cachePath); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(checkpointPath); err != nil {
+			return nil, err
+		}
+		return readSignaturesFromDisk(prog, cachePath)
+	}
+	// the partial cache is always the plain CSV because it is appended to
+	if commits, err = readSignaturesFromDisk(prog, partialPath); err != nil {
+		return nil, err
+	}
+	if err := storeSignatureCache(cachePath, commits, chunkSize); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(checkpointPath); err != nil {
+		return nil, err
+	}
+	return commits, os.Remove(partialPath)
+}
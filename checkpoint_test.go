@@ -0,0 +1,118 @@
+package idmatch
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testSignatureSource struct {
+	signatures map[string][]Signature
+	failOn     string
+	fetched    []string
+}
+
+func (s *testSignatureSource) Repositories(ctx context.Context) ([]string, error) {
+	return []string{"repo1", "repo2", "repo3"}, nil
+}
+
+func (s *testSignatureSource) Signatures(ctx context.Context, repo string) (
+	[]Signature, error) {
+	if repo == s.failOn {
+		return nil, errors.New("connection lost")
+	}
+	s.fetched = append(s.fetched, repo)
+	return s.signatures[repo], nil
+}
+
+func newTestSignatureSource() *testSignatureSource {
+	source := &testSignatureSource{signatures: map[string][]Signature{}}
+	for _, signature := range Signatures {
+		source.signatures[signature.Repo] = append(source.signatures[signature.Repo], signature)
+	}
+	source.signatures["repo3"] = []Signature{{Repo: "repo3", Name: "Eve",
+		Email: "eve@google.com", Hash: "ggg", Time: Signatures[0].Time}}
+	return source
+}
+
+func TestExtractSignaturesResume(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-checkpoint")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "cache.csv")
+	partialPath, checkpointPath := checkpointPaths(cachePath)
+
+	source := newTestSignatureSource()
+	source.failOn = "repo2"
+	_, err = extractSignatures(nil, source, cachePath, false, 0)
+	req.EqualError(err, "connection lost")
+	checkpoint, err := readCheckpoint(checkpointPath)
+	req.NoError(err)
+	req.Equal("repo1", checkpoint.Repository)
+	req.Equal(5, checkpoint.Signatures)
+	_, err = os.Stat(cachePath)
+	req.True(os.IsNotExist(err))
+
+	// the signatures written after the checkpoint are discarded
+	partial, err := os.OpenFile(partialPath, os.O_APPEND|os.O_WRONLY, 0666)
+	req.NoError(err)
+	_, err = partial.WriteString("repo2,bob,bob@google.com,bbb,2019-01-01T00:00:00Z\n")
+	req.NoError(err)
+	req.NoError(partial.Close())
+
+	source.failOn = ""
+	source.fetched = nil
+	commits, err := extractSignatures(nil, source, cachePath, true, 0)
+	req.NoError(err)
+	req.Equal([]string{"repo2", "repo3"}, source.fetched)
+	req.Len(commits, 7)
+	req.Equal("aaa", commits[0].Hash)
+	req.Equal("bbb", commits[5].Hash)
+	req.Equal("eve", commits[6].Name)
+	_, err = os.Stat(checkpointPath)
+	req.True(os.IsNotExist(err))
+	_, err = os.Stat(partialPath)
+	req.True(os.IsNotExist(err))
+	cached, err := readSignaturesFromDisk(nil, cachePath)
+	req.NoError(err)
+	req.Equal(commits, cached)
+}
+
+func TestExtractSignaturesNoResume(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-checkpoint")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "cache.csv")
+
+	source := newTestSignatureSource()
+	source.failOn = "repo3"
+	_, err = extractSignatures(nil, source, cachePath, false, 0)
+	req.Error(err)
+	source.failOn = ""
+	source.fetched = nil
+	commits, err := extractSignatures(nil, source, cachePath, false, 0)
+	req.NoError(err)
+	req.Equal([]string{"repo1", "repo2", "repo3"}, source.fetched)
+	req.Len(commits, 7)
+}
+
+func TestExtractSignaturesUnknownCheckpoint(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-checkpoint")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "cache.csv")
+	partialPath, checkpointPath := checkpointPaths(cachePath)
+	req.NoError(ioutil.WriteFile(partialPath, []byte("repo,name,email,hash,time\n"), 0666))
+	req.NoError(writeCheckpoint(checkpointPath, extractionCheckpoint{Repository: "deleted", Offset: 26}))
+	_, err = extractSignatures(nil, newTestSignatureSource(), cachePath, true, 0)
+	req.EqualError(err, "the checkpoint repository deleted does not exist, "+
+		"restart the extraction from scratch")
+}
@@ -0,0 +1,139 @@
+package idmatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// chunkPath returns the path of the chunk of the signatures cache in path: the zero-padded
+// index is inserted before the extensions of the file name, e.g. "signatures.csv.gz" becomes
+// "signatures-00002.csv.gz".
+func chunkPath(path string, index int) string {
+	base := strings.LastIndex(path, "/") + 1
+	dot := strings.Index(path[base:], ".")
+	if dot <= 0 {
+		return fmt.Sprintf("%s-%05d", path, index)
+	}
+	dot += base
+	return fmt.Sprintf("%s-%05d%s", path[:dot], index, path[dot:])
+}
+
+// signatureChunks returns the paths of the consecutive existing chunks of the signatures cache
+// in path starting from the first one.
+func signatureChunks(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	var chunks []string
+	for {
+		chunk := chunkPath(path, len(chunks))
+		exists, err := pathExists(chunk)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return chunks, nil
+		}
+		chunks = append(chunks, chunk)
+	}
+}
+
+// signatureCachePaths returns path if the signatures cache exists as a whole, otherwise its
+// chunks. The error satisfies os.IsNotExist if neither exists.
+func signatureCachePaths(path string) ([]string, error) {
+	exists, err := pathExists(path)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return []string{path}, nil
+	}
+	chunks, err := signatureChunks(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return chunks, nil
+}
+
+// storeSignatureCache writes the signatures to path as a whole if chunkSize is not positive,
+// otherwise to the chunks of at most chunkSize signatures each, see chunkPath.
+func storeSignatureCache(path string, commits []Signature, chunkSize int) error {
+	if chunkSize <= 0 {
+		return storeSignatureFile(path, commits)
+	}
+	for index := 0; index*chunkSize < len(commits) || index == 0; index++ {
+		end := (index + 1) * chunkSize
+		if end > len(commits) {
+			end = len(commits)
+		}
+		if err := storeSignatureFile(chunkPath(path, index), commits[index*chunkSize:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSignatureChunks reads the chunks of the signatures cache concurrently with the given
+// number of workers. The result is ordered by chunk.
+func readSignatureChunks(prog *progress, chunks []string, workers int) ([]Signature, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(prog.context())
+	defer cancel()
+	perChunk := make([][]Signature, len(chunks))
+	indexes := make(chan int)
+	var lock sync.Mutex
+	var firstErr error
+	stage := prog.stage("reading signatures", 0)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				signatures, err := readSignatureFile(nil, chunks[index], nil)
+				lock.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = fmt.Errorf("%s: %v", chunks[index], err)
+						cancel()
+					}
+				} else {
+					perChunk[index] = signatures
+					for range signatures {
+						// the cancellation is checked by the producer
+						_ = stage.tick()
+					}
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for index := range chunks {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	stage.done()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := prog.err(); err != nil {
+		return nil, err
+	}
+	var result []Signature
+	for _, signatures := range perChunk {
+		result = append(result, signatures...)
+	}
+	return result, nil
+}
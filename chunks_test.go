@@ -0,0 +1,80 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkPath(t *testing.T) {
+	req := require.New(t)
+	req.Equal("signatures-00002.csv.gz", chunkPath("signatures.csv.gz", 2))
+	req.Equal("/tmp/a.b/cache-00000.csv", chunkPath("/tmp/a.b/cache.csv", 0))
+	req.Equal("s3://bucket/cache-00010", chunkPath("s3://bucket/cache", 10))
+	req.Equal("dir/.cache-00001", chunkPath("dir/.cache", 1))
+}
+
+func TestSignatureChunks(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-chunks")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "cache.csv.gz")
+	_, err = signatureCachePaths(cachePath)
+	req.True(os.IsNotExist(err))
+
+	req.NoError(storeSignatureCache(cachePath, Signatures, 4))
+	chunks, err := signatureChunks(cachePath)
+	req.NoError(err)
+	req.Equal([]string{
+		filepath.Join(dir, "cache-00000.csv.gz"), filepath.Join(dir, "cache-00001.csv.gz")}, chunks)
+	paths, err := signatureCachePaths(cachePath)
+	req.NoError(err)
+	req.Equal(chunks, paths)
+	first, err := readSignaturesFromDisk(nil, chunks[0])
+	req.NoError(err)
+	req.Len(first, 4)
+
+	expected, err := readSignatureChunks(nil, chunks, 1)
+	req.NoError(err)
+	req.Len(expected, len(Signatures))
+	signatures, err := findSignatures(nil, "", cachePath, ExtractionOptions{Workers: 3})
+	req.NoError(err)
+	req.Equal(expected, signatures)
+
+	s, err := NewSuppressions("bob@google.com")
+	req.NoError(err)
+	erased, err := EraseSignatureCache(cachePath, s)
+	req.NoError(err)
+	req.Equal(3, erased)
+	signatures, err = findSignatures(nil, "", cachePath, ExtractionOptions{})
+	req.NoError(err)
+	req.Len(signatures, len(Signatures)-3)
+
+	req.NoError(ioutil.WriteFile(chunks[1], []byte("repo,name\n"), 0666))
+	_, err = readSignatureChunks(nil, chunks, 2)
+	req.Error(err)
+	req.Contains(err.Error(), chunks[1])
+}
+
+func TestExtractSignatureChunks(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-chunks")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "cache.csv")
+	commits, err := extractSignatures(nil, newTestSignatureSource(), cachePath, false, 5)
+	req.NoError(err)
+	req.Len(commits, len(Signatures)+1)
+	chunks, err := signatureChunks(cachePath)
+	req.NoError(err)
+	req.Len(chunks, 2)
+	_, err = os.Stat(cachePath)
+	req.True(os.IsNotExist(err))
+	cached, err := readSignatureChunks(nil, chunks, 2)
+	req.NoError(err)
+	req.Equal(commits, cached)
+}
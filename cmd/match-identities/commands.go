@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	idmatch "github.com/src-d/identity-matching"
+)
+
+// newRootCommand builds the command line interface: each stage of the identity matching is
+// a command with its own flags. The global flags are shared by all the commands.
+func newRootCommand() *cobra.Command {
+	global := &cliArgs{}
+	root := &cobra.Command{
+		Use:   "match-identities",
+		Short: "Match the identities of the contributors across their names, emails and accounts.",
+		Long: "match-identities merges the signatures of the same people found in the commits, " +
+			"the mailing lists and the user accounts into identities.\n\n" +
+			"\"match\" runs the whole pipeline on a single machine. \"shard\" and \"reduce\" split it " +
+			"across many machines. \"serve\" matches the signatures on demand. \"enrich\", \"export\", " +
+			"\"eval\", \"diff\" and \"versions\" work with the identities which were matched before, " +
+			"and \"erase\" and \"decrypt\" manage the personal data in them.",
+		Version: version,
+		PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+			if global.Config != "" {
+				applyConfig(cmd.Flags(), global.Config)
+			}
+			startDebugServers(*global)
+		},
+		PersistentPostRun: func(*cobra.Command, []string) {
+			if global.MemProfile != "" {
+				writeMemProfile(global.MemProfile)
+			}
+		},
+	}
+	root.CompletionOptions.DisableDefaultCmd = true
+	flags := root.PersistentFlags()
+	flags.SortFlags = false
+	flags.StringVar(&global.Config, "config", "",
+		"Path to the YAML configuration file with the options of the matching, see README. "+
+			"The command line flags override the file.")
+	flags.StringVar(&global.PProf, "pprof", "",
+		"Address to serve the live CPU and memory profiles at /debug/pprof/, e.g. \"localhost:6060\". "+
+			"The blank value disables the server.")
+	flags.StringVar(&global.MemProfile, "mem-profile", "",
+		"Path to the file to write the heap profile to at the end of the run.")
+	flags.StringVar(&global.Metrics, "metrics", "",
+		"Address to serve the Prometheus metrics at /metrics, e.g. \"localhost:9090\": the signatures "+
+			"read, the merges by reason and the stage durations. The blank value disables the server.")
+	root.AddCommand(
+		newMatchCommand(), newShardCommand(), newReduceCommand(), newServeCommand(),
+		newStreamCommand(), newEnrichCommand(), newExportCommand(), newEvalCommand(), newDiffCommand(),
+		newEraseCommand(), newDecryptCommand(), newImportCommand(), newVersionsCommand(),
+		newLookupCommand())
+	return root
+}
+
+// newCommand creates the command whose flags fill args. run receives the positional arguments
+// after the configuration file is applied.
+func newCommand(use, short, long string, positional cobra.PositionalArgs,
+	run func(ctx context.Context, args *cliArgs, positional []string)) (*cobra.Command, *cliArgs) {
+	args := &cliArgs{}
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  long,
+		Args:  positional,
+		Run: func(cmd *cobra.Command, positional []string) {
+			run(cmd.Context(), args, positional)
+		},
+	}
+	cmd.Flags().SortFlags = false
+	return cmd, args
+}
+
+// markRequired marks the flags which the command cannot run without.
+func markRequired(cmd *cobra.Command, names ...string) {
+	for _, name := range names {
+		if err := cmd.MarkFlagRequired(name); err != nil {
+			logrus.Fatalf("failed to require --%s: %v", name, err)
+		}
+	}
+}
+
+func newMatchCommand() *cobra.Command {
+	cmd, args := newCommand("match",
+		"Match the identities on a single machine and write them to --output.",
+		"Read the signatures, merge the signatures of the same people and write the identities "+
+			"to --output. --dry-run, --graph and --export-pairs show what the matching would do.",
+		cobra.NoArgs, func(ctx context.Context, args *cliArgs, _ []string) {
+			checkExtractionFlags(args)
+			checkMatchingFlags(args)
+			checkPrimaryFlags(args)
+			checkExternalFlags(args)
+			checkMatchFlags(args)
+			match(ctx, *args)
+		})
+	flags := cmd.Flags()
+	addStoreFlags(flags, args)
+	addAnomalyFlags(flags, args)
+	addExtractionFlags(flags, args)
+	addFilterFlags(flags, args)
+	addMatchingFlags(flags, args)
+	addPrimaryFlags(flags, args)
+	addExternalFlags(flags, args)
+	flags.StringVar(&args.Graph, "graph", "",
+		"Path to the file to write the evidence graph between the signatures to, for visualization "+
+			"in Gephi or Graphviz. The blank value disables the export.")
+	flags.StringVar(&args.GraphFormat, "graph-format", string(idmatch.GraphFormatGraphML),
+		"Format of the --graph file, options: "+strings.Join(graphFormatNames(), ", "))
+	flags.StringVar(&args.ExtIDReview, "external-id-review", "",
+		"Path to the CSV file to write the pairs of identities which were kept apart because of "+
+			"their different external IDs with --external-id-conflicts=review to.")
+	flags.StringVar(&args.OverMerges, "over-merge-review", "",
+		"Path to the CSV file to write the merges which --max-cluster-emails and --max-cluster-names "+
+			"refused to for the review.")
+	flags.StringVar(&args.DryRun, "dry-run", "",
+		"Path to the CSV file to write the proposed merges with their reasons and confidences to "+
+			"instead of writing the identities. The blank value disables the dry run.")
+	flags.StringVar(&args.ExportPairs, "export-pairs", "",
+		"Instead of matching, write the sampled candidate pairs of identities with their features "+
+			"to this CSV or parquet (\".parquet\" extension) file to label them and train --pair-model.")
+	flags.IntVar(&args.MaxPairs, "export-pairs-max", 10000,
+		"Maximum number of the exported positive (same email) and of the hard negative "+
+			"(same popular name) pairs. 0 disables the limit.")
+	flags.StringVar(&args.Evaluate, "evaluate", "",
+		"Path to the CSV file with the ground truth (columns: name, email, identity) to compare "+
+			"the reduced identities with and print the pairwise and B-cubed precision, recall and F1. "+
+			"The \"eval\" command does the same with the identities which were matched before.")
+	markRequired(cmd, "output")
+	return cmd
+}
+
+// checkMatchFlags validates the combinations of the flags of the "match" command.
+func checkMatchFlags(args *cliArgs) {
+	graphFormatSupported := false
+	for _, format := range graphFormatNames() {
+		graphFormatSupported = graphFormatSupported || format == args.GraphFormat
+	}
+	if !graphFormatSupported {
+		logrus.Fatalf("unsupported --graph-format value: %s", args.GraphFormat)
+	}
+	if args.EmailKey != "" && (args.Graph != "" || args.DryRun != "" || args.ExtIDReview != "" ||
+		args.OverMerges != "" || args.Stats != "" || args.Anomalies != "" || args.SharedReport != "") {
+		logrus.Fatalf("--email-key cannot be combined with --graph, --dry-run, " +
+			"--external-id-review, --over-merge-review, --stats, --anomalies and --shared-mailboxes, " +
+			"which write the plain emails")
+	}
+	if args.Pseudonymize != "" && (args.Graph != "" || args.DryRun != "" ||
+		args.ExportPairs != "" || args.EmailIssues != "" || args.ExtIDReview != "" ||
+		args.OverMerges != "" || args.Stats != "" || args.Anomalies != "" || args.SharedReport != "") {
+		logrus.Fatalf("--pseudonymize cannot be combined with --graph, --dry-run, " +
+			"--export-pairs, --email-issues, --external-id-review, --over-merge-review, --stats, " +
+			"--anomalies and --shared-mailboxes, which write the original names and emails")
+	}
+}
+
+func newShardCommand() *cobra.Command {
+	cmd, args := newCommand("shard",
+		"Match the signatures of a part of the dataset and write the partial identities.",
+		"Match the signatures of a part of the dataset, e.g. the repositories of a single machine, "+
+			"and write the partial identities to --output. \"reduce\" merges the partial identities "+
+			"of all the shards.",
+		cobra.NoArgs, func(ctx context.Context, args *cliArgs, _ []string) {
+			checkExtractionFlags(args)
+			checkMatchingFlags(args)
+			checkPrimaryFlags(args)
+			checkExternalFlags(args)
+			if args.Shard == "" {
+				args.Shard = args.Cache
+			}
+			shard(ctx, *args)
+		})
+	flags := cmd.Flags()
+	addOutputFlag(flags, args, "path to the parquet file to write the partial identities to")
+	flags.StringVar(&args.Shard, "shard", "",
+		"Name of the shard. The blank value means the --cache path.")
+	addExtractionFlags(flags, args)
+	addFilterFlags(flags, args)
+	addMatchingFlags(flags, args)
+	addPrimaryFlags(flags, args)
+	addExternalFlags(flags, args)
+	markRequired(cmd, "output")
+	return cmd
+}
+
+func newReduceCommand() *cobra.Command {
+	cmd, args := newCommand("reduce <shard files>...",
+		"Merge the partial identities of all the shards into the final --output.",
+		"Merge the partial identities which \"shard\" wrote on each machine into the final --output.",
+		cobra.MinimumNArgs(1), func(_ context.Context, args *cliArgs, shards []string) {
+			checkPrimaryFlags(args)
+			checkExternalFlags(args)
+			if args.Anomalies != "" && (args.EmailKey != "" || args.Pseudonymize != "") {
+				logrus.Fatalf("--anomalies cannot be combined with --email-key and --pseudonymize, " +
+					"it writes the original names and emails")
+			}
+			args.Shards = shards
+			reduce(*args)
+		})
+	flags := cmd.Flags()
+	addStoreFlags(flags, args)
+	addAnomalyFlags(flags, args)
+	addFilterFlags(flags, args)
+	addPrimaryFlags(flags, args)
+	addExternalFlags(flags, args)
+	addReproducibleFlag(flags, args)
+	markRequired(cmd, "output")
+	return cmd
+}
+
+func newServeCommand() *cobra.Command {
+	cmd, args := newCommand("serve",
+		"Run the gRPC service and the REST API which match the signatures on demand.",
+		"Run the gRPC service and the REST API which receive the signatures and match them on demand, "+
+			"see service/identity.proto. The review UI is served at /review.",
+		cobra.NoArgs, func(_ context.Context, args *cliArgs, _ []string) {
+			checkExtractionFlags(args)
+			checkMatchingFlags(args)
+			checkPrimaryFlags(args)
+			checkExternalFlags(args)
+			if args.Listen == "" && args.HTTP == "" {
+				logrus.Fatalf("serve requires --listen or --http")
+			}
+			serve(*args)
+		})
+	flags := cmd.Flags()
+	flags.StringVar(&args.Listen, "listen", "localhost:9432",
+		"Address to serve the gRPC service on. The blank value disables the gRPC service.")
+	flags.StringVar(&args.HTTP, "http", "",
+		"Address to serve the REST API with JSON bodies on, e.g. \":8080\". "+
+			"The blank value disables the REST API.")
+	flags.Float64Var(&args.ReviewMargin, "review-margin", 0.5,
+		"The merges whose edge weight differs from --min-edge-weight by less than this margin "+
+			"are listed for the review.")
+	flags.StringVar(&args.ExportDir, "export-dir", "",
+		"Directory where ExportParquet writes the parquet files; the requested paths are relative "+
+			"to it. The blank value disables ExportParquet.")
+	addExtractionFlags(flags, args)
+	addFilterFlags(flags, args)
+	addMatchingFlags(flags, args)
+	addPrimaryFlags(flags, args)
+	addExternalFlags(flags, args)
+	return cmd
+}
+
+func newStreamCommand() *cobra.Command {
+	cmd, args := newCommand("stream",
+		"Match the signatures consumed from Kafka and publish the identity updates.",
+		"Consume the JSON signatures from --input-topic, match them into the identities found "+
+			"before and publish the changes of the identities to --output-topic as JSON: "+
+			"the added and merged identities, the gained emails and the changed external ids. "+
+			"The identities keep their IDs and are kept in memory.",
+		cobra.NoArgs, func(ctx context.Context, args *cliArgs, _ []string) {
+			checkExtractionFlags(args)
+			checkMatchingFlags(args)
+			checkPrimaryFlags(args)
+			checkExternalFlags(args)
+			if args.BatchSize <= 0 && args.FlushInterval <= 0 {
+				logrus.Fatalf("stream requires a positive --batch-size or --flush-interval")
+			}
+			streamIdentities(ctx, *args)
+		})
+	flags := cmd.Flags()
+	flags.StringSliceVar(&args.Brokers, "brokers", []string{"localhost:9092"},
+		"Comma-separated addresses of the Kafka brokers.")
+	flags.StringVar(&args.InputTopic, "input-topic", "",
+		"Kafka topic with the signature events.")
+	flags.StringVar(&args.OutputTopic, "output-topic", "",
+		"Kafka topic to publish the identity updates to.")
+	flags.StringVar(&args.Group, "group", "identity-matching",
+		"Kafka consumer group which commits the offsets of the matched signatures.")
+	flags.IntVar(&args.BatchSize, "batch-size", 1000,
+		"Maximum number of the signatures which are matched together.")
+	flags.DurationVar(&args.FlushInterval, "flush-interval", 10*time.Second,
+		"Maximum time the consumed signatures wait for the matching.")
+	addExtractionFlags(flags, args)
+	addFilterFlags(flags, args)
+	addMatchingFlags(flags, args)
+	addPrimaryFlags(flags, args)
+	addExternalFlags(flags, args)
+	markRequired(cmd, "input-topic", "output-topic")
+	return cmd
+}
+
+func newEnrichCommand() *cobra.Command {
+	cmd, args := newCommand("enrich <identities>",
+		"Add the external ids to the identities which were matched before.",
+		"Query the --external identity provider for the emails of the identities without an external id "+
+			"and write the enriched identities to --output. The identities are not merged.",
+		cobra.ExactArgs(1), func(ctx context.Context, args *cliArgs, positional []string) {
+			checkExternalFlags(args)
+			if args.External == "" {
+				logrus.Fatalf("enrich requires --external")
+			}
+			enrich(ctx, *args, positional[0])
+		})
+	flags := cmd.Flags()
+	addOutputFlag(flags, args, "path to the parquet file to write the enriched identities to")
+	addExternalFlags(flags, args)
+	addEmailKeyFlag(flags, args,
+		"Path to the file with the AES key which encrypted the emails of the identities. "+
+			"The enriched identities are encrypted with the same key.")
+	markRequired(cmd, "output")
+	return cmd
+}
+
+func newExportCommand() *cobra.Command {
+	cmd, args := newCommand("export <identities>",
+		"Convert the identities to CSV, Avro, Protobuf, the .mailmap file, a warehouse table, "+
+			"the affiliations, the gitdm configuration or SortingHat.",
+		"Convert the identities parquet to --format and write them to --output: \"csv\" writes "+
+			"a row per identity, \"mailmap\" writes the Git .mailmap file, \"avro\" writes "+
+			"the Avro object container file and \"proto\" writes the People message of people.proto. "+
+			"\"bigquery\" and \"snowflake\" create or replace the table named by --output. "+
+			"\"affiliations\" writes the organization timelines inferred from the email domains "+
+			"to JSON lines or to parquet if --output ends with \".parquet\". \"gitdm\" writes "+
+			"the affiliations to the gitdm email map in --output and the other emails of each "+
+			"identity to the gitdm aliases file in --gitdm-aliases. \"sortinghat\" writes "+
+			"the unique identities, the organizations and the enrollments for \"sortinghat load\". "+
+			"\"repos-json\" and \"repos-csv\" write a file per repository of the signatures in --cache "+
+			"to the --output directory with the people who contributed there, their local names and "+
+			"emails and the period of their commits. \"teams\" rolls the identities up to the teams of "+
+			"--teams and writes JSON lines with the members and their activity, \"repo-teams\" writes "+
+			"the teams which touched each repository of --cache to CSV. \"assignments\" maps every "+
+			"signature in --cache to the ID of its identity and writes CSV or parquet if --output "+
+			"ends with \".parquet\".",
+		cobra.ExactArgs(1), func(ctx context.Context, args *cliArgs, positional []string) {
+			switch args.Format {
+			case "csv", "mailmap", "avro", "proto", "bigquery", "affiliations", "sortinghat":
+			case "repos-json", "repos-csv", "assignments":
+				if args.Cache == "" {
+					logrus.Fatalf("--format %s requires --cache", args.Format)
+				}
+			case "teams", "repo-teams":
+				if args.Teams == "" {
+					logrus.Fatalf("--format %s requires --teams", args.Format)
+				}
+				if args.Format == "repo-teams" && args.Cache == "" {
+					logrus.Fatalf("--format repo-teams requires --cache")
+				}
+			case "gitdm":
+				if args.GitdmAliases == "" {
+					logrus.Fatalf("--format gitdm requires --gitdm-aliases")
+				}
+			case "snowflake":
+				if args.Snowflake == "" {
+					logrus.Fatalf("--format snowflake requires --snowflake-dsn")
+				}
+			default:
+				logrus.Fatalf("unsupported --format value: %s", args.Format)
+			}
+			export(ctx, *args, positional[0])
+		})
+	flags := cmd.Flags()
+	addOutputFlag(flags, args, "path to the file to write, \"project.dataset.table\" for BigQuery "+
+		"or \"[database.][schema.]table\" for Snowflake")
+	flags.StringVar(&args.Format, "format", "csv",
+		"Format of --output, options: csv, mailmap, avro, proto, bigquery, snowflake, affiliations, "+
+			"gitdm, sortinghat, repos-json, repos-csv, teams, repo-teams, assignments.")
+	flags.StringVar(&args.Snowflake, "snowflake-dsn", "",
+		"Snowflake connection string for --format snowflake, "+
+			"e.g. \"user:password@account/database/schema?warehouse=wh\".")
+	flags.StringVar(&args.Organizations, "organizations", "",
+		"Path to the CSV file which maps the email domains to the organizations for "+
+			"--format affiliations, gitdm and sortinghat (columns: domain, organization). "+
+			"The free email providers map to \"personal\".")
+	flags.StringVar(&args.GitdmAliases, "gitdm-aliases", "",
+		"Path to the gitdm aliases file to write for --format gitdm.")
+	flags.StringVar(&args.Cache, "cache", "",
+		"Path to the signatures cache which the identities were matched from for --format repos-json, "+
+			"repos-csv, repo-teams and assignments.")
+	flags.StringVar(&args.Teams, "teams", "",
+		"Path to the CSV file which maps the emails to the teams for --format teams and repo-teams "+
+			"(columns: email, team), e.g. exported from LDAP.")
+	addEmailKeyFlag(flags, args,
+		"Path to the file with the AES key to decrypt the emails of the identities before the export.")
+	markRequired(cmd, "output")
+	return cmd
+}
+
+func newImportCommand() *cobra.Command {
+	cmd, args := newCommand("import <sortinghat.json>",
+		"Convert the SortingHat unique identities to the identities.",
+		"Read the JSON file of \"sortinghat export\" and write the unique identities to --output "+
+			"with the SortingHat uuids as the external ids, so that \"export --format sortinghat\" "+
+			"keeps them. The identities can be compared with the matched ones by \"diff\" and \"eval\".",
+		cobra.ExactArgs(1), func(_ context.Context, args *cliArgs, positional []string) {
+			importSortingHat(*args, positional[0])
+		})
+	flags := cmd.Flags()
+	addOutputFlag(flags, args, "path to the parquet file to write the identities to")
+	flags.StringVar(&args.Organizations, "organizations", "",
+		"Path to the CSV file to write the organizations of the SortingHat file to "+
+			"(columns: domain, organization). The blank value skips them.")
+	addEmailKeyFlag(flags, args, "Path to the file with the AES key to encrypt the emails in --output.")
+	markRequired(cmd, "output")
+	return cmd
+}
+
+func newEvalCommand() *cobra.Command {
+	cmd, args := newCommand("eval <identities>",
+		"Compare the identities with the ground truth.",
+		"Compare the identities with the --truth and print the pairwise and B-cubed precision, "+
+			"recall and F1.",
+		cobra.ExactArgs(1), func(_ context.Context, args *cliArgs, positional []string) {
+			evaluate(*args, positional[0])
+		})
+	flags := cmd.Flags()
+	flags.StringVar(&args.Evaluate, "truth", "",
+		"Path to the CSV file with the ground truth (columns: name, email, identity).")
+	addEmailKeyFlag(flags, args,
+		"Path to the file with the AES key to decrypt the emails of the identities.")
+	markRequired(cmd, "truth")
+	return cmd
+}
+
+func newDiffCommand() *cobra.Command {
+	cmd, args := newCommand("diff <old> <new>",
+		"Compare the identities of two runs.",
+		"Compare the identities of two runs and write the split, merged, changed, added and removed "+
+			"identities to --output.",
+		cobra.ExactArgs(2), func(_ context.Context, args *cliArgs, positional []string) {
+			args.Identifiers = positional
+			diff(*args)
+		})
+	flags := cmd.Flags()
+	addOutputFlag(flags, args, "path to the CSV file to write the changes to")
+	addEmailKeyFlag(flags, args,
+		"Path to the file with the AES key to decrypt the emails of both runs.")
+	flags.StringVar(&args.Versions, "versions", "",
+		"Path to the directory of the identity versions, see \"match --versions\". <old> and <new> "+
+			"are the version IDs then.")
+	markRequired(cmd, "output")
+	return cmd
+}
+
+func newVersionsCommand() *cobra.Command {
+	cmd, args := newCommand("versions <directory>",
+		"List and roll back the stored versions of the identities.",
+		"Print the versions of the identities which \"match --versions\" and \"reduce --versions\" "+
+			"stored in the directory, the current one marked with \"*\". --rollback makes an earlier "+
+			"version current without deleting the later ones.",
+		cobra.ExactArgs(1), func(_ context.Context, args *cliArgs, positional []string) {
+			versions(*args, positional[0])
+		})
+	cmd.Flags().StringVar(&args.Rollback, "rollback", "",
+		"ID of the version to make current.")
+	return cmd
+}
+
+func newLookupCommand() *cobra.Command {
+	cmd, args := newCommand("lookup <identities> <query>",
+		"Find the identities by a fuzzy name or email.",
+		"Print the identities whose names or emails are the most similar to the query, e.g. "+
+			"\"jon smth\" finds \"john smith\", with their emails, external ids and the sample commit. "+
+			"The similarity is the share of the common trigrams from 0 to 1.",
+		cobra.ExactArgs(2), func(_ context.Context, args *cliArgs, positional []string) {
+			lookup(*args, positional[0], positional[1])
+		})
+	flags := cmd.Flags()
+	flags.IntVar(&args.LookupLimit, "limit", 10, "Maximum number of the identities to print.")
+	flags.Float64Var(&args.LookupScore, "min-score", 0.2,
+		"Minimum similarity of the printed identities from 0 to 1.")
+	addEmailKeyFlag(flags, args, "Path to the file with the AES key to decrypt the emails of the identities.")
+	return cmd
+}
+
+func newEraseCommand() *cobra.Command {
+	cmd, args := newCommand("erase <emails and names>...",
+		"Remove the emails and the names from the caches and the identities.",
+		"Remove the given emails and names from --cache, --external-cache, --output and --versions "+
+			"and add them to --suppressions so that they are never matched again.",
+		cobra.MinimumNArgs(1), func(_ context.Context, args *cliArgs, positional []string) {
+			checkExternalFlags(args)
+			args.Identifiers = positional
+			erase(*args)
+		})
+	flags := cmd.Flags()
+	addSuppressionsFlag(flags, args)
+	addCacheFlag(flags, args)
+	addExternalCacheFlag(flags, args)
+	flags.StringVar(&args.External, "external", "",
+		"External matching service whose name replaces {provider} in --external-cache.")
+	addOutputFlag(flags, args, "path to the parquet file with the identities to erase the values from")
+	addEmailKeyFlag(flags, args,
+		"Path to the file with the AES key which encrypted the emails in --output and --versions.")
+	flags.StringVar(&args.Versions, "versions", "",
+		"Path to the directory of the identity versions, see \"match --versions\". Every version "+
+			"is rewritten without the erased values.")
+	markRequired(cmd, "suppressions")
+	return cmd
+}
+
+func newDecryptCommand() *cobra.Command {
+	cmd, args := newCommand("decrypt <file>",
+		"Decrypt the emails of the identities or of the CSV file.",
+		"Write the identities parquet or the CSV file with the emails encrypted by --email-key to --output.",
+		cobra.ExactArgs(1), func(_ context.Context, args *cliArgs, positional []string) {
+			args.Identifiers = positional
+			decrypt(*args)
+		})
+	flags := cmd.Flags()
+	addOutputFlag(flags, args, "path to the decrypted copy of the file to write")
+	addEmailKeyFlag(flags, args, "Path to the file with the hex or base64 AES key which encrypted the emails.")
+	markRequired(cmd, "output", "email-key")
+	return cmd
+}
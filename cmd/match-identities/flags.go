@@ -0,0 +1,603 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	flag "github.com/spf13/pflag"
+
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/src-d/identity-matching/external"
+)
+
+// The flag groups are shared by the commands which need them. Each add*Flags registers
+// a group and the corresponding check*Flags validates and converts it after the parsing.
+
+func matcherNames() []string {
+	var matchers []string
+	for key := range external.Matchers {
+		matchers = append(matchers, key)
+	}
+	sort.Strings(matchers)
+	return matchers
+}
+
+func sourceNames() []string {
+	var sources []string
+	for _, source := range idmatch.SourceKinds {
+		sources = append(sources, string(source))
+	}
+	return sources
+}
+
+func graphFormatNames() []string {
+	var graphFormats []string
+	for _, format := range idmatch.GraphFormats {
+		graphFormats = append(graphFormats, string(format))
+	}
+	return graphFormats
+}
+
+// addExtractionFlags registers the flags which read, clean and filter the signatures.
+func addExtractionFlags(flags *flag.FlagSet, args *cliArgs) {
+	args.Extraction = idmatch.NewExtractionOptions()
+	flags.StringVar(&args.Source, "source", string(idmatch.SourceGitbase),
+		"Where to read the signatures from, options: "+strings.Join(sourceNames(), ", ")+
+			". \"git\" walks the commit logs of the --repositories on disk and bypasses gitbase, "+
+			"\"github\" pulls the commit history of the --github-org repositories from the GitHub API, "+
+			"\"mbox\" reads the From: headers of the --mailboxes archives, "+
+			"\"jira\" and \"rest\" pull the user accounts from --accounts-url.")
+	flags.StringVar(&args.Host, "host", "0.0.0.0", "gitbase host")
+	flags.UintVar(&args.Port, "port", 3306, "gitbase port")
+	flags.StringVar(&args.User, "user", "root", "gitbase user, normally the default value is fine")
+	flags.StringVar(&args.Password, "password", "", "gitbase password")
+	flags.StringVar(&args.Extraction.Repositories, "repositories", "",
+		"Glob pattern which matches the repository directories to read with --source=git.")
+	flags.StringVar(&args.Extraction.Mailboxes, "mailboxes", "",
+		"Glob pattern which matches the mbox files and Maildir directories to read with --source=mbox.")
+	flags.StringVar(&args.Extraction.MailboxTag, "mailbox-tag", "",
+		"Prefix of the mailbox paths in the repository column with --source=mbox, e.g. \"mailing-list\".")
+	flags.StringVar(&args.Extraction.AccountsURL, "accounts-url", "",
+		"JIRA server URL with --source=jira or the accounts endpoint with --source=rest.")
+	flags.StringVar(&args.Extraction.AccountsToken, "accounts-token", "",
+		"Credentials for --accounts-url: \"username:password\" for the basic authentication, "+
+			"any other value is the bearer token.")
+	flags.StringToStringVar(&args.Extraction.AccountFields, "account-fields", nil,
+		"Dotted JSON paths of the account fields in the --accounts-url responses: items, id, name, email, "+
+			"and the names of the pagination query parameters: offset, limit. "+
+			"For example, \"items=data.users,email=profile.email\".")
+	flags.StringVar(&args.Extraction.GitHubOrg, "github-org", "",
+		"GitHub organization whose repositories are read with --source=github.")
+	flags.StringVar(&args.Extraction.GitHubToken, "github-token", "",
+		"GitHub API token for --source=github, https://github.com/settings/tokens")
+	flags.StringVar(&args.Extraction.GitHubAPIURL, "github-api-url", "",
+		"GitHub GraphQL API endpoint for --source=github, the blank value means the public API.")
+	flags.IntVar(&args.Extraction.GitHubRateLimitReserve, "github-rate-limit-reserve",
+		args.Extraction.GitHubRateLimitReserve,
+		"Number of GitHub API rate limit points to leave unspent with --source=github: "+
+			"the extraction waits for the rate limit reset instead.")
+	addCacheFlag(flags, args)
+	flags.StringToStringVar(&args.Extraction.ParquetColumns, "parquet-columns", nil,
+		"Column names of the --cache parquet commits table, e.g. \"repo=repository_id,name=author_name\". "+
+			"The fields are repo, name, email, hash, time and the optional source and role. "+
+			"The unmapped fields keep their names.")
+	flags.BoolVar(&args.Extraction.MatchCommitters, "match-committers", false,
+		"Match the committer signatures together with the authors. By default the committers are only "+
+			"reported because they are often merge bots.")
+	flags.StringToStringVar(&args.Trailers, "trailers", nil,
+		"Extract the people from the commit message trailers in addition to the co-authors "+
+			"and weigh their evidence, e.g. \"signed-off-by=0.5,reviewed-by=0.25\". "+
+			"The supported trailers are signed-off-by, reviewed-by and tested-by.")
+	flags.BoolVar(&args.Extraction.Resume, "resume", false,
+		"Continue the interrupted extraction of the signatures to --cache from the last checkpoint.")
+	flags.IntVar(&args.Extraction.CacheChunkSize, "cache-chunk-size", 0,
+		"Split --cache into the files of at most so many signatures each, e.g. cache-00000.csv.gz, "+
+			"which are read concurrently by --workers. 0 writes a single file.")
+	addReproducibleFlag(flags, args)
+	flags.BoolVar(&args.Extraction.DedupCommits, "dedup-commits", args.Extraction.DedupCommits,
+		"Count each commit which is present in several repositories, e.g. in the forks and the mirrors, "+
+			"once. The person keeps all the repositories of the commit.")
+	flags.StringVar(&args.Forks, "forks", "",
+		"Path to the CSV file with the forks and the mirrors (columns: fork, upstream) whose signatures "+
+			"belong to the upstream repositories.")
+	flags.StringSliceVar(&args.ReposInclude, "repos-include", nil,
+		"Match only the signatures of the repositories which match any of these patterns: globs, e.g. "+
+			"github.com/src-d/*, or regular expressions after \"re:\". --cache keeps all the repositories.")
+	flags.StringSliceVar(&args.ReposExclude, "repos-exclude", nil,
+		"Ignore the signatures of the repositories which match any of these patterns, see --repos-include.")
+	flags.StringVar(&args.Since, "since", "",
+		"Match only the signatures made at or after this time: RFC3339, YYYY-MM-DD, YYYY-MM or YYYY in UTC. "+
+			"--cache keeps all the signatures.")
+	flags.StringVar(&args.Until, "until", "",
+		"Match only the signatures made before this time, see --since. E.g. --since 2019 --until 2020 "+
+			"matches the identities of 2019.")
+	flags.DurationVar(&args.Extraction.QueryTimeout, "query-timeout", args.Extraction.QueryTimeout,
+		"Maximum duration of a single gitbase query. 0 disables the timeout.")
+	flags.IntVar(&args.Extraction.MaxRetries, "query-retries", args.Extraction.MaxRetries,
+		"Number of times a gitbase query is repeated with exponential backoff after a transient error.")
+	flags.IntVar(&args.Workers, "workers", runtime.GOMAXPROCS(0),
+		"Number of goroutines which process the shards of the signatures while matching "+
+			"and read the repositories with --source=git.")
+	addSuppressionsFlag(flags, args)
+	flags.StringVar(&args.Seeds, "seeds", "",
+		"Path to the previously curated identity map whose emails of the same person start as "+
+			"a single identity: the identities parquet of a prior run, decrypted with --email-key "+
+			"if it is set, or the CSV file (columns: email, id).")
+	flags.StringVar(&args.RepoGroups, "repo-groups", "",
+		"Path to the CSV file with the groups of repositories (columns: pattern, group) within which "+
+			"the popular names are matched instead of the single repositories. The pattern is a regular "+
+			"expression and the group may refer to its groups, e.g. ^github\\.com/([^/]+)/ and $1 "+
+			"scope the names to the GitHub organizations.")
+	flags.StringVar(&args.NameCleaning, "name-cleaning", "",
+		"Path to the CSV file with the ordered steps which normalize the names (columns: step, pattern, "+
+			"replacement) instead of the default transliterate and lowercase. The steps are "+
+			"transliterate, diacritics, lowercase, parens, suffixes and replace.")
+	flags.StringVar(&args.EmailCheck, "email-validation", "off",
+		"What to do with the signatures whose emails have invalid syntax or an unknown top-level domain, "+
+			"options: report, exclude, off. The common typos in the domains, e.g. gamil.com, are repaired "+
+			"unless it is off.")
+	flags.StringVar(&args.EmailRepairs, "email-repairs", "",
+		"Path to the CSV file with the misspelled email domains and their replacements (columns: domain, "+
+			"replacement) in addition to the built-in ones.")
+	flags.BoolVar(&args.EmailMX, "email-mx", false,
+		"Look up the MX records of the email domains during --email-validation and treat the domains "+
+			"without them as invalid.")
+	flags.StringVar(&args.EmailIssues, "email-issues", "",
+		"Path to the CSV file to write the repaired and the invalid emails to.")
+	flags.StringVar(&args.Stats, "stats", "",
+		"Path to the CSV or parquet (\".parquet\" extension) file to write the statistics of the "+
+			"names and the emails to: the frequencies, the number of repositories, the first and "+
+			"the last signature and the concentration across the repositories.")
+	flags.IntVar(&args.SharedMinNames, "shared-mailbox-min-names", 5,
+		"Minimum number of the different names, which do not share a word, of the emails which are "+
+			"detected as the shared team mailboxes, e.g. dev@company.com. Such emails stay with "+
+			"the identities but never merge them. 0 disables the detection.")
+	flags.StringVar(&args.SharedReport, "shared-mailboxes", "",
+		"Path to the CSV file to write the detected shared mailboxes to.")
+	flags.StringVar(&args.Bots, "bots", "mark",
+		"What to do with the automated accounts detected by the name and the commit timing "+
+			"heuristics, options: mark, exclude, off.")
+	flags.IntVar(&args.BotMinCommits, "bot-min-commits", 1000,
+		"Minimum number of commits with the same email to detect bots by the commit timing.")
+}
+
+func addCacheFlag(flags *flag.FlagSet, args *cliArgs) {
+	flags.StringVar(&args.Cache, "cache", fmt.Sprintf("cache-raw-%s.csv", idmatch.HashPeopleDiscoverySQL()),
+		"Path to the cached raw signatures")
+}
+
+func addReproducibleFlag(flags *flag.FlagSet, args *cliArgs) {
+	flags.BoolVar(&args.Extraction.Reproducible, "reproducible", false,
+		"Sort the signatures and the shard files so that the same input in any order produces "+
+			"byte-identical outputs.")
+}
+
+func addSuppressionsFlag(flags *flag.FlagSet, args *cliArgs) {
+	flags.StringVar(&args.Suppressions, "suppressions", "",
+		"Path to the CSV file with the erased emails and names (columns: kind, value). Their signatures "+
+			"are dropped in every run, and the \"erase\" command adds to it.")
+}
+
+// checkExtractionFlags validates the extraction flags and fills args.Extraction.
+func checkExtractionFlags(args *cliArgs) {
+	sourceSupported := false
+	for _, source := range sourceNames() {
+		sourceSupported = sourceSupported || source == args.Source
+	}
+	if !sourceSupported {
+		logrus.Fatalf("unsupported --source value: %s", args.Source)
+	}
+	if args.Source == string(idmatch.SourceGit) && args.Extraction.Repositories == "" {
+		logrus.Fatalf("--repositories must be specified with --source=git")
+	}
+	if args.Source == string(idmatch.SourceMbox) && args.Extraction.Mailboxes == "" {
+		logrus.Fatalf("--mailboxes must be specified with --source=mbox")
+	}
+	if args.Source == string(idmatch.SourceGitHub) && args.Extraction.GitHubOrg == "" {
+		logrus.Fatalf("--github-org must be specified with --source=github")
+	}
+	if (args.Source == string(idmatch.SourceJIRA) || args.Source == string(idmatch.SourceREST)) &&
+		args.Extraction.AccountsURL == "" {
+		logrus.Fatalf("--accounts-url must be specified with --source=%s", args.Source)
+	}
+	args.Extraction.Source = idmatch.SourceKind(args.Source)
+	args.Extraction.Workers = args.Workers
+	if args.Extraction.CacheChunkSize < 0 {
+		logrus.Fatalf("--cache-chunk-size must not be negative")
+	}
+	window, err := idmatch.NewTimeWindow(args.Since, args.Until)
+	if err != nil {
+		logrus.Fatalf("invalid --since or --until: %v", err)
+	}
+	args.Extraction.Window = window
+	if args.EmailCheck != "report" && args.EmailCheck != "exclude" && args.EmailCheck != "off" {
+		logrus.Fatalf("unsupported --email-validation value: %s", args.EmailCheck)
+	}
+	if args.Bots != "mark" && args.Bots != "exclude" && args.Bots != "off" {
+		logrus.Fatalf("unsupported --bots value: %s", args.Bots)
+	}
+	args.Weights = map[idmatch.EvidenceKind]float64{}
+	for trailer, weight := range args.Trailers {
+		role := idmatch.SignatureRole(strings.ToLower(trailer))
+		supported := false
+		for _, trailerRole := range idmatch.TrailerRoles {
+			supported = supported || role == trailerRole
+		}
+		if !supported {
+			logrus.Fatalf("unsupported --trailers value: %s", trailer)
+		}
+		value, err := strconv.ParseFloat(weight, 64)
+		if err != nil {
+			logrus.Fatalf("invalid --trailers weight of %s: %v", trailer, err)
+		}
+		args.Extraction.Trailers = append(args.Extraction.Trailers, role)
+		args.Weights[idmatch.EvidenceKind(role)] = value
+	}
+	sort.Slice(args.Extraction.Trailers, func(i, j int) bool {
+		return args.Extraction.Trailers[i] < args.Extraction.Trailers[j]
+	})
+}
+
+// addFilterFlags registers the blacklist and the popularity thresholds.
+func addFilterFlags(flags *flag.FlagSet, args *cliArgs) {
+	flags.StringSliceVar(&args.Blacklists, "blacklist", nil,
+		"Path to a YAML or CSV file with additional blacklist entries which are merged with "+
+			"the built-in ones. May be specified several times.")
+	flags.IntVar(&args.Popularity.MinNameCount, "popular-name-min-count", 0,
+		"Names which appear in at least this number of signatures are treated as popular. "+
+			"0 disables the threshold.")
+	flags.Float64Var(&args.Popularity.MinNameShare, "popular-name-min-share", 0,
+		"Names which appear in at least this share of all the signatures are treated as popular. "+
+			"0 disables the threshold.")
+	flags.IntVar(&args.Popularity.MinEmailCount, "popular-email-min-count", 0,
+		"Emails which appear in at least this number of signatures are treated as popular. "+
+			"0 disables the threshold.")
+	flags.Float64Var(&args.Popularity.MinEmailShare, "popular-email-min-share", 0,
+		"Emails which appear in at least this share of all the signatures are treated as popular. "+
+			"0 disables the threshold.")
+	flags.DurationVar(&args.Popularity.Frequencies.HalfLife, "popularity-half-life", 0,
+		"Weigh each signature by 0.5^(age / half-life) before comparing the frequencies with the "+
+			"popularity thresholds, e.g. 8760h, so that the values which were frequent long ago stop "+
+			"being popular. 0 counts all the signatures equally.")
+	flags.DurationSliceVar(&args.Popularity.Frequencies.Windows, "frequency-windows", nil,
+		"Additional periods of time before now to count the name and email frequencies within, "+
+			"e.g. 168h,720h. \"reduce\" sums them across the shards.")
+}
+
+// addMatchingFlags registers the flags of the heuristics which merge the signatures.
+func addMatchingFlags(flags *flag.FlagSet, args *cliArgs) {
+	flags.IntVar(&args.MaxIdentities, "max-identities", 20,
+		"If a person has more than this number of unique names and unique emails summed, "+
+			"no more identities will be merged. If the identities are matched by an external API "+
+			"or by email this limitation can be violated.")
+	flags.BoolVar(&args.ReorderedNames, "reordered-names", true,
+		"Match the names which consist of the same words in a different order, "+
+			"e.g. \"John Smith\" and \"Smith, John\".")
+	flags.IntVar(&args.MaxTokenFreq, "max-name-token-freq", 100,
+		"Reordered names are matched only if at least one of their words is used in no more "+
+			"than this number of distinct names. 0 disables the limit.")
+	flags.BoolVar(&args.DomainChanges, "domain-changes", false,
+		"Match the identities with the same name and the same email local part at different "+
+			"corporate domains whose activity periods do not overlap, e.g. after a job change. "+
+			"The identities without the commit times are never matched this way.")
+	flags.StringVar(&args.EmailAliases, "email-aliases", "",
+		"Path to the CSV file with the per-domain email alias rules (columns: domain, separators, "+
+			"ignore_dots, canonical_domain) which override the built-in rules for gmail.com, etc.")
+	flags.Float64Var(&args.MinEdgeWeight, "min-edge-weight", 0,
+		"Minimum number of independent pieces of evidence (the same external id, email or name) "+
+			"required to merge two identities. 0 and 1 merge on any single piece of evidence.")
+	flags.IntVar(&args.Behavior.MinCommits, "behavior-min-commits", 0,
+		"Compare the hours of the day and the time zones of the commits of the candidate identities "+
+			"which have at least this number of commits each. 0 disables the behavioral matching.")
+	flags.Float64Var(&args.Behavior.VetoSimilarity, "behavior-veto-similarity", 0.2,
+		"The identities whose activity similarity (0 to 1) is below this value are not merged "+
+			"by the names alone.")
+	flags.Float64Var(&args.Behavior.MinSimilarity, "behavior-min-similarity", 0.8,
+		"The identities whose activity similarity (0 to 1) reaches this value receive "+
+			"an additional piece of \"activity\" evidence.")
+	flags.IntVar(&args.Style.SampleSize, "style-sample-size", 0,
+		"Fetch up to this number of the files changed by each identity which shares a name with another "+
+			"one from gitbase and compare the coding styles of the lines they added: the indentation, "+
+			"the identifier casing, the comment density and the line lengths. It is slow. "+
+			"0 disables the stylometry.")
+	flags.IntVar(&args.Style.MinLines, "style-min-lines", 50,
+		"Minimum number of the sampled lines of both identities to compare their styles.")
+	flags.Float64Var(&args.Style.VetoSimilarity, "style-veto-similarity", 0.5,
+		"The identities whose style similarity (0 to 1) is below this value are not merged "+
+			"by the names alone.")
+	flags.Float64Var(&args.Style.MinSimilarity, "style-min-similarity", 0.9,
+		"The identities whose style similarity (0 to 1) reaches this value receive "+
+			"an additional piece of \"style\" evidence.")
+	flags.Float64Var(&args.MinRepoSim, "min-repo-similarity", 0,
+		"Add the \"repositories\" evidence to the candidate identities whose sets of repositories, "+
+			"weighted towards the rare ones, have at least this cosine similarity (0 to 1). "+
+			"0 disables the repository co-occurrence.")
+	flags.Float64Var(&args.RepoWeight, "repo-weight", 1,
+		"Weight of the \"repositories\" evidence at the cosine similarity 1. "+
+			"Lower similarities weigh proportionally less.")
+	flags.StringVar(&args.PairModel, "pair-model", "",
+		"Path to the JSON file with the logistic regression or the gradient boosting model which "+
+			"scores the candidate identities by the name, email, repository and activity features.")
+	flags.Float64Var(&args.MinPairProb, "min-pair-probability", 0.5,
+		"Minimum probability of --pair-model to add the \"classifier\" evidence, "+
+			"which weighs the probability.")
+	flags.StringSliceVar(&args.Blockers, "blockers", nil,
+		"Blocking keys which pair the identities for --pair-model in addition to those connected by "+
+			"the other evidence: \"email\", \"name\" (the same words in any order), "+
+			"\"initial_domain\" (the first letter of the name and the email domain) and "+
+			"\"metaphone\" and \"soundex\" (the names which sound alike, soundex also requires "+
+			"the same first letter). The coarser keys find more pairs but take longer.")
+	flags.IntVar(&args.MaxBlockSize, "max-block-size", 100,
+		"Identities which share a blocking key are not paired if there are more of them than this "+
+			"number. 0 disables the limit.")
+	flags.IntVar(&args.ClusterEmails, "max-cluster-emails", 0,
+		"Refuse the merges which would create a person with more distinct emails, e.g. because of "+
+			"a shared team email, and keep the sub-identities apart. The strongest evidence is merged "+
+			"first. 0 disables the limit.")
+	flags.IntVar(&args.ClusterNames, "max-cluster-names", 0,
+		"Refuse the merges which would create a person with more distinct names, see "+
+			"--max-cluster-emails. 0 disables the limit.")
+	flags.IntVar(&args.SampleCommits, "sample-commits", 1,
+		"Number of the representative commits which each merged identity keeps for the verification: "+
+			"the earliest, the latest and then the commits in the repositories with the biggest "+
+			"--repo-weights.")
+	flags.StringVar(&args.RepoWeights, "repo-weights", "",
+		"Path to the CSV file with the importance of the repositories, e.g. the stars "+
+			"(columns: repo, weight), which ranks the --sample-commits.")
+	flags.StringVar(&args.ExtIDConflicts, "external-id-conflicts",
+		string(idmatch.ExternalIDConflictRefuse),
+		"What to do when the evidence joins the identities with different external IDs of the same "+
+			"provider: \"refuse\" fails, \"priority\" merges them and keeps the external ID of "+
+			"the identities with more commits, \"keep_both\" merges them and keeps all the external "+
+			"IDs tagged with their providers and \"review\" keeps them apart and records the conflict.")
+	flags.StringVar(&args.Constraints, "constraints", "",
+		"Path to the CSV file with the hard constraints (columns: constraint, first, second), where "+
+			"the constraint is must_link or cannot_link and the keys are email:<email> or name:<name>. "+
+			"The constraints which cannot be honored are reported.")
+	flags.StringVar(&args.Decisions, "review-decisions", "",
+		"Path to the CSV file with the verdicts of the reviewers which force or forbid the merges. "+
+			"The \"serve\" command stores the verdicts made in the review UI at /review there.")
+	flags.BoolVar(&args.Explain, "explain", false,
+		"Record why each pair of signatures was merged and store the evidence next to --output "+
+			"with the \"-merges.parquet\" suffix.")
+}
+
+// checkMatchingFlags validates the matching flags. It must run after checkExtractionFlags
+// which collects the trailer weights.
+func checkMatchingFlags(args *cliArgs) {
+	if args.Weights == nil {
+		args.Weights = map[idmatch.EvidenceKind]float64{}
+	}
+	args.Weights[idmatch.EvidenceRepositories] = args.RepoWeight
+	if args.Behavior.VetoSimilarity > args.Behavior.MinSimilarity {
+		logrus.Fatalf("--behavior-veto-similarity must not exceed --behavior-min-similarity")
+	}
+	if args.Style.VetoSimilarity > args.Style.MinSimilarity {
+		logrus.Fatalf("--style-veto-similarity must not exceed --style-min-similarity")
+	}
+	if _, err := idmatch.ParseExternalIDConflictPolicy(args.ExtIDConflicts); err != nil {
+		logrus.Fatalf("unsupported --external-id-conflicts value: %s", args.ExtIDConflicts)
+	}
+	if len(args.Blockers) > 0 && args.PairModel == "" {
+		logrus.Fatalf("--blockers requires --pair-model")
+	}
+}
+
+// addPrimaryFlags registers the flags which choose the primary names and emails.
+func addPrimaryFlags(flags *flag.FlagSet, args *cliArgs) {
+	flags.StringVar(&args.Primary, "primary", string(idmatch.PrimaryFrequent),
+		"Strategy to choose the primary name and email of each person: \"frequent\" uses the stats "+
+			"described below, \"recent\" takes the latest commit and \"corporate\" prefers "+
+			"the corporate email domains to the freemail ones, see --domain-policies.")
+	flags.IntVar(&args.RecentMonths, "months", 12,
+		"Number of preceding months to consider while calculating stats for detecting "+
+			"the primary names and emails.")
+	flags.IntVar(&args.RecentMinCount, "min-count", 5,
+		"Minimum total number of commits the identity should have in the last --months so that "+
+			"the corresponding stats are used for detecting the primary names and emails. "+
+			"Otherwise, the stats collected through all the time will be used.")
+	flags.StringVar(&args.DomainPolicies, "domain-policies", "",
+		"Path to the CSV file with the email domain policies (columns: domain, policy). "+
+			"\"corporate\" domains have unique logins and are matched aggressively, "+
+			"\"freemail\" domains require the names to share a word to match by email.")
+	flags.StringSliceVar(&args.NoReplyUsers, "noreply-users", []string{"github", "gitlab"},
+		"Noreply emails whose usernames join the identities with the same platform account: "+
+			"\"github\", \"gitlab\" or \"provider=template\" where the template is the regular "+
+			"expression of the whole email with {user} for the username and {id} for the numeric ID, "+
+			"e.g. \"gitea={user}@noreply\\.git\\.example\\.com\". The blank value disables it.")
+	flags.StringVar(&args.Mailmap, "mailmap", "",
+		"Path to the Git .mailmap file. The emails which map to the same proper email are always merged "+
+			"and the proper names and emails become the primary ones.")
+}
+
+func checkPrimaryFlags(args *cliArgs) {
+	primarySupported := false
+	for _, strategy := range idmatch.PrimaryStrategies {
+		primarySupported = primarySupported || string(strategy) == args.Primary
+	}
+	if !primarySupported {
+		logrus.Fatalf("unsupported --primary value: %s", args.Primary)
+	}
+}
+
+// addExternalFlags registers the external identity provider.
+func addExternalFlags(flags *flag.FlagSet, args *cliArgs) {
+	flags.StringVar(&args.External, "external", "",
+		"enable external service matching, options: "+strings.Join(matcherNames(), ", "))
+	flags.StringVar(&args.APIURL, "api-url", "",
+		"API URL of the external matching service, the blank value means the public website. "+
+			"The Gerrit or JIRA server URL is required for \"gerrit\" and \"jira\", the LDAP URL - for \"ldap\".")
+	flags.StringVar(&args.Token, "token", "",
+		"API token for the external matching service, \"username:password\" HTTP credentials for \"gerrit\" and \"jira\", "+
+			"\"bindDN:password\" for \"ldap\". Several GitHub tokens separated by commas are used in turn.")
+	flags.BoolVar(&args.CommitSearch, "commit-search", false,
+		"Search the GitHub commits by the author email when the sample commit does not resolve to a login, "+
+			"e.g. because it was rebased, and take the most frequent login of the found commits.")
+	flags.Float64Var(&args.SearchShare, "commit-search-share", 0.5,
+		"Share of the commits found by --commit-search which the most frequent login must have.")
+	addExternalCacheFlag(flags, args)
+}
+
+func addExternalCacheFlag(flags *flag.FlagSet, args *cliArgs) {
+	flags.StringVar(&args.ExternalCache, "external-cache", "cache-external-{provider}.csv",
+		"Path to the cached matches found by using an external identity service such as GitHub API."+
+			"{provider} will be replaced with the external service name.")
+}
+
+func checkExternalFlags(args *cliArgs) {
+	if args.External != "" {
+		if _, exists := external.Matchers[args.External]; !exists {
+			logrus.Fatalf("unsupported external matching service: %s", args.External)
+		}
+	}
+	args.ExternalCache = strings.ReplaceAll(args.ExternalCache, "{provider}", args.External)
+}
+
+// addStoreFlags registers the flags which write the matched identities.
+func addStoreFlags(flags *flag.FlagSet, args *cliArgs) {
+	addOutputFlag(flags, args, "path to the parquet file to write")
+	flags.IntVar(&args.PartitionSize, "output-partition-size", 0,
+		"Maximum number of identities in each parquet file: --output becomes the directory with "+
+			"the files of the consecutive ranges of the identity IDs and manifest.json, which all "+
+			"the commands read in parallel. 0 writes a single file.")
+	flags.StringVar(&args.ExportMailmap, "export-mailmap", "",
+		"Path to the .mailmap file to write the matched people to in addition to --output.")
+	flags.StringVar(&args.Pseudonymize, "pseudonymize", "",
+		"Secret salt to replace the names and emails in --output, the merge evidence and "+
+			"--export-mailmap with their salted HMACs. The same salt yields the same pseudonyms in "+
+			"every run. The blank value disables the pseudonymization.")
+	addEmailKeyFlag(flags, args,
+		"Path to the file with the hex or base64 AES key (16, 24 or 32 bytes) to encrypt the emails in "+
+			"--output, --export-mailmap, --export-pairs and --email-issues with AES-GCM. "+
+			"The \"decrypt\" command reverts it.")
+	flags.StringVar(&args.Versions, "versions", "",
+		"Path to the directory to additionally store the identities to as a new immutable version, "+
+			"which the \"versions\" command lists and rolls back. The blank value disables it.")
+	flags.StringVar(&args.VersionLabel, "version-label", "",
+		"Free text to describe the version stored to --versions, e.g. the dataset.")
+}
+
+// addAnomalyFlags registers the flags of the report of the suspicious identities.
+func addAnomalyFlags(flags *flag.FlagSet, args *cliArgs) {
+	flags.StringVar(&args.Anomalies, "anomalies", "",
+		"Path to the CSV or JSON lines (\".json\" extension) file to write the identities which look "+
+			"wrong to for the manual inspection. The blank value disables the report.")
+	flags.IntVar(&args.Anomaly.MaxNames, "anomaly-max-names", 5,
+		"Report the identities with more distinct names. 0 disables the check.")
+	flags.IntVar(&args.Anomaly.MaxEmailNames, "anomaly-max-email-names", 2,
+		"Report the emails used by more names which do not share a word. 0 disables the check.")
+	flags.IntVar(&args.Anomaly.MaxRepositories, "anomaly-max-repos", 200,
+		"Report the identities which contributed to more repositories. 0 disables the check.")
+	flags.BoolVar(&args.Anomaly.NearMisses, "anomaly-near-misses", true,
+		"Report the names and emails which differ from a blacklisted one by a single character.")
+}
+
+func addOutputFlag(flags *flag.FlagSet, args *cliArgs, usage string) {
+	flags.StringVar(&args.Output, "output", "", usage)
+}
+
+func addEmailKeyFlag(flags *flag.FlagSet, args *cliArgs, usage string) {
+	flags.StringVar(&args.EmailKey, "email-key", "", usage)
+}
+
+// configFlags maps the options of the configuration file to the command line flags.
+var configFlags = map[string]string{
+	"cache":                                "cache",
+	"database.host":                        "host",
+	"database.port":                        "port",
+	"database.user":                        "user",
+	"database.password":                    "password",
+	"extraction.source":                    "source",
+	"extraction.repositories":              "repositories",
+	"extraction.workers":                   "workers",
+	"extraction.github_org":                "github-org",
+	"extraction.github_token":              "github-token",
+	"extraction.github_api_url":            "github-api-url",
+	"extraction.github_rate_limit_reserve": "github-rate-limit-reserve",
+	"extraction.mailboxes":                 "mailboxes",
+	"extraction.mailbox_tag":               "mailbox-tag",
+	"extraction.accounts_url":              "accounts-url",
+	"extraction.accounts_token":            "accounts-token",
+	"extraction.account_fields":            "account-fields",
+	"extraction.parquet_columns":           "parquet-columns",
+	"extraction.trailers":                  "trailers",
+	"extraction.match_committers":          "match-committers",
+	"extraction.resume":                    "resume",
+	"extraction.cache_chunk_size":          "cache-chunk-size",
+	"extraction.reproducible":              "reproducible",
+	"extraction.dedup_commits":             "dedup-commits",
+	"extraction.query_timeout":             "query-timeout",
+	"extraction.query_retries":             "query-retries",
+	"extraction.since":                     "since",
+	"extraction.until":                     "until",
+	"blacklists":                           "blacklist",
+	"popularity.name_min_count":            "popular-name-min-count",
+	"popularity.name_min_share":            "popular-name-min-share",
+	"popularity.email_min_count":           "popular-email-min-count",
+	"popularity.email_min_share":           "popular-email-min-share",
+	"popularity.half_life":                 "popularity-half-life",
+	"popularity.windows":                   "frequency-windows",
+	"popularity.shared_mailbox_min_names":  "shared-mailbox-min-names",
+	"bots.mode":                            "bots",
+	"bots.min_commits":                     "bot-min-commits",
+	"email_validation.mode":                "email-validation",
+	"email_validation.repairs":             "email-repairs",
+	"email_validation.mx":                  "email-mx",
+	"email_validation.issues":              "email-issues",
+	"primary.strategy":                     "primary",
+	"primary.recent_months":                "months",
+	"primary.min_recent_count":             "min-count",
+	"matching.max_identities":              "max-identities",
+	"matching.reordered_names":             "reordered-names",
+	"matching.max_name_token_frequency":    "max-name-token-freq",
+	"matching.domain_changes":              "domain-changes",
+	"matching.min_edge_weight":             "min-edge-weight",
+	"matching.min_repository_similarity":   "min-repo-similarity",
+	"matching.repository_weight":           "repo-weight",
+	"matching.pair_model":                  "pair-model",
+	"matching.min_pair_probability":        "min-pair-probability",
+	"matching.blockers":                    "blockers",
+	"matching.max_block_size":              "max-block-size",
+	"matching.max_cluster_emails":          "max-cluster-emails",
+	"matching.max_cluster_names":           "max-cluster-names",
+	"matching.sample_commits":              "sample-commits",
+	"matching.repo_weights":                "repo-weights",
+	"matching.name_cleaning":               "name-cleaning",
+	"matching.email_aliases":               "email-aliases",
+	"matching.domain_policies":             "domain-policies",
+	"matching.noreply_users":               "noreply-users",
+	"external.provider":                    "external",
+	"external.api_url":                     "api-url",
+	"external.token":                       "token",
+	"external.cache":                       "external-cache",
+	"external.commit_search":               "commit-search",
+	"external.commit_search_share":         "commit-search-share",
+}
+
+// applyConfig sets the flags of the command which are not given on the command line from
+// the configuration file. The options of the flags which the command does not have are ignored.
+func applyConfig(flags *flag.FlagSet, path string) {
+	config, err := idmatch.LoadConfig(path)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	keys := make([]string, 0, len(configFlags))
+	for key := range configFlags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, exists := config.Lookup(key)
+		name := configFlags[key]
+		if !exists || flags.Lookup(name) == nil || flags.Changed(name) {
+			continue
+		}
+		if err = flags.Set(name, value); err != nil {
+			logrus.Fatalf("invalid config %s: %s: %v", path, key, err)
+		}
+	}
+}
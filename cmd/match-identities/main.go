@@ -2,20 +2,35 @@ package main
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"runtime"
+	"runtime/pprof"
 	"sort"
 	"strings"
 	"time"
 
+	bq "cloud.google.com/go/bigquery"
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/segmentio/kafka-go"
 	"github.com/sirupsen/logrus"
-	flag "github.com/spf13/pflag"
+	"google.golang.org/grpc"
 
 	idmatch "github.com/src-d/identity-matching"
+	"github.com/src-d/identity-matching/bigquery"
+	"github.com/src-d/identity-matching/blob"
 	"github.com/src-d/identity-matching/external"
 	"github.com/src-d/identity-matching/reporter"
+	"github.com/src-d/identity-matching/service"
+	"github.com/src-d/identity-matching/snowflake"
+	"github.com/src-d/identity-matching/stream"
 )
 
 type cliArgs struct {
@@ -28,10 +43,103 @@ type cliArgs struct {
 	APIURL         string
 	Token          string
 	Cache          string
+	Source         string
+	Extraction     idmatch.ExtractionOptions
 	ExternalCache  string
+	CommitSearch   bool
+	SearchShare    float64
 	MaxIdentities  int
 	RecentMonths   int
 	RecentMinCount int
+	Primary        string
+	ReorderedNames bool
+	DomainChanges  bool
+	MaxTokenFreq   int
+	NameCleaning   string
+	EmailAliases   string
+	DomainPolicies string
+	EmailCheck     string
+	EmailRepairs   string
+	EmailMX        bool
+	EmailIssues    string
+	Stats          string
+	SharedMinNames int
+	SharedReport   string
+	Anomalies      string
+	Anomaly        idmatch.AnomalyOptions
+	MinEdgeWeight  float64
+	Behavior       idmatch.BehaviorOptions
+	Style          idmatch.StyleOptions
+	ExtIDConflicts string
+	ExtIDReview    string
+	MinRepoSim     float64
+	RepoWeight     float64
+	PairModel      string
+	MinPairProb    float64
+	Blockers       []string
+	NoReplyUsers   []string
+	MaxBlockSize   int
+	ClusterEmails  int
+	ClusterNames   int
+	SampleCommits  int
+	RepoWeights    string
+	OverMerges     string
+	ExportPairs    string
+	MaxPairs       int
+	Evaluate       string
+	Trailers       map[string]string
+	Weights        map[idmatch.EvidenceKind]float64
+	Graph          string
+	GraphFormat    string
+	DryRun         string
+	Explain        bool
+	Workers        int
+	PProf          string
+	MemProfile     string
+	Metrics        string
+	Shard          string
+	Shards         []string
+	Listen         string
+	HTTP           string
+	Brokers        []string
+	InputTopic     string
+	OutputTopic    string
+	Group          string
+	BatchSize      int
+	FlushInterval  time.Duration
+	Decisions      string
+	Constraints    string
+	Mailmap        string
+	ExportMailmap  string
+	ExportDir      string
+	PartitionSize  int
+	Versions       string
+	VersionLabel   string
+	Rollback       string
+	Pseudonymize   string
+	Suppressions   string
+	Seeds          string
+	RepoGroups     string
+	Forks          string
+	ReposInclude   []string
+	ReposExclude   []string
+	Since          string
+	Until          string
+	Identifiers    []string
+	EmailKey       string
+	ReviewMargin   float64
+	Blacklists     []string
+	Bots           string
+	BotMinCommits  int
+	Popularity     idmatch.PopularityThresholds
+	Format         string
+	Snowflake      string
+	Organizations  string
+	Teams          string
+	LookupLimit    int
+	LookupScore    float64
+	GitdmAliases   string
+	Config         string
 }
 
 var version string
@@ -53,71 +161,869 @@ func printBanner() {
 
 func main() {
 	printBanner()
-	args := parseArgs()
+	idmatch.RegisterBlobBackend("s3", blob.NewS3())
+	idmatch.RegisterBlobBackend("gs", blob.NewGCS())
 
 	ctx, cancel := context.WithCancel(context.Background())
-	signals := make(chan os.Signal)
-	defer close(signals)
+	signals := make(chan os.Signal, 1)
+	defer signal.Stop(signals)
 	signal.Notify(signals, os.Interrupt, os.Kill)
 	go func() {
 		<-signals
+		logrus.Warn("interrupted, stopping")
 		cancel()
 	}()
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		os.Exit(1)
+	}
+}
 
-	var extmatcher external.Matcher
-	if args.External != "" {
-		var err error
-		extmatcher, err = external.Matchers[args.External](args.APIURL, args.Token)
+// startDebugServers serves --pprof and --metrics in the background.
+func startDebugServers(args cliArgs) {
+	if args.PProf != "" {
+		go func() {
+			logrus.Infof("serving pprof on http://%s/debug/pprof/", args.PProf)
+			if err := http.ListenAndServe(args.PProf, nil); err != nil {
+				logrus.Errorf("failed to serve pprof: %v", err)
+			}
+		}()
+	}
+	if args.Metrics != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", reporter.MetricsHandler())
+			logrus.Infof("serving the metrics on http://%s/metrics", args.Metrics)
+			if err := http.ListenAndServe(args.Metrics, mux); err != nil {
+				logrus.Errorf("failed to serve the metrics: %v", err)
+			}
+		}()
+	}
+}
+
+// prepareExtraction applies the flags which change how the signatures are read and cleaned.
+func prepareExtraction(args *cliArgs) {
+	if args.NameCleaning != "" {
+		cleaner, err := idmatch.ReadNameCleaner(args.NameCleaning)
 		if err != nil {
-			logrus.Fatalf("failed to initialize %s: %v", args.External, err)
+			logrus.Fatalf("failed to load the name cleaning steps: %v", err)
 		}
-		if args.ExternalCache != "" {
-			extmatcher, err = external.NewCachedMatcher(extmatcher, args.ExternalCache)
-			if err != nil {
-				logrus.Fatalf("failed to initialize cached %s: %v", args.External, err)
-			}
+		idmatch.SetNameCleaner(cleaner)
+	}
+	args.Extraction.EmailValidator = newEmailValidator(*args)
+	args.Extraction.Suppressions = loadSuppressions(*args)
+	args.Extraction.Seeds = loadSeeds(*args)
+	if args.RepoGroups != "" {
+		groups, err := idmatch.ReadRepoGroups(args.RepoGroups)
+		if err != nil {
+			logrus.Fatalf("failed to load the repository groups: %v", err)
+		}
+		args.Extraction.RepoGroups = groups
+	}
+	if args.Forks != "" {
+		forks, err := idmatch.ReadRepoForks(args.Forks)
+		if err != nil {
+			logrus.Fatalf("failed to load the repository forks: %v", err)
+		}
+		args.Extraction.Forks = forks
+	}
+	if len(args.ReposInclude) > 0 || len(args.ReposExclude) > 0 {
+		repos, err := idmatch.NewRepoFilter(args.ReposInclude, args.ReposExclude)
+		if err != nil {
+			logrus.Fatalf("failed to compile the repository filters: %v", err)
+		}
+		args.Extraction.Repos = repos
+	}
+	if args.Stats != "" {
+		args.Extraction.Stats = &idmatch.Stats{}
+	}
+	if args.SharedMinNames > 0 {
+		args.Extraction.SharedMailboxes = &idmatch.SharedMailboxDetector{MinNames: args.SharedMinNames}
+	}
+}
+
+// loadSeeds reads --seeds. It returns nil if the flag is blank.
+func loadSeeds(args cliArgs) idmatch.IdentitySeeds {
+	if args.Seeds == "" {
+		return nil
+	}
+	if strings.HasSuffix(args.Seeds, ".parquet") {
+		people, _, err := idmatch.ReadIdentitiesFromParquet(args.Seeds, loadEmailCipher(args))
+		if err != nil {
+			logrus.Fatalf("failed to read the seed identities: %v", err)
 		}
+		return idmatch.SeedsFromPeople(people)
 	}
+	seeds, err := idmatch.ReadIdentitySeeds(args.Seeds)
+	if err != nil {
+		logrus.Fatalf("failed to load the identity seeds: %v", err)
+	}
+	return seeds
+}
 
-	logrus.Info("fetching signatures from the commits")
-	start := time.Now()
-	connStr := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s",
-		args.User, args.Password, args.Host, args.Port, "gitbase")
-	blacklist, err := idmatch.NewBlacklist()
+// loadSuppressions reads --suppressions. It returns the empty suppressions if the flag is blank
+// or the file does not exist yet.
+func loadSuppressions(args cliArgs) idmatch.Suppressions {
+	if args.Suppressions == "" {
+		return idmatch.Suppressions{}
+	}
+	suppressions, err := idmatch.ReadSuppressions(args.Suppressions)
+	if err != nil && !os.IsNotExist(err) {
+		logrus.Fatalf("failed to load the suppressions: %v", err)
+	}
+	return suppressions
+}
+
+// newExternalMatcher creates the --external matcher with --external-cache. It returns nil
+// if the flag is blank.
+func newExternalMatcher(args cliArgs) external.Matcher {
+	if args.External == "" {
+		return nil
+	}
+	extmatcher, err := external.Matchers[args.External](args.APIURL, args.Token)
+	if err != nil {
+		logrus.Fatalf("failed to initialize %s: %v", args.External, err)
+	}
+	if args.CommitSearch {
+		githubMatcher, ok := extmatcher.(external.GitHubMatcher)
+		if !ok {
+			logrus.Fatalf("--commit-search requires --external github")
+		}
+		extmatcher = githubMatcher.WithCommitSearch(external.CommitSearchOptions{MinShare: args.SearchShare})
+	}
+	if args.ExternalCache != "" {
+		extmatcher, err = external.NewCachedMatcher(extmatcher, args.ExternalCache)
+		if err != nil {
+			logrus.Fatalf("failed to initialize cached %s: %v", args.External, err)
+		}
+	}
+	return extmatcher
+}
+
+// loadBlacklist merges the built-in blacklist with --blacklist.
+func loadBlacklist(args cliArgs) idmatch.Blacklist {
+	blacklist, err := idmatch.LoadBlacklist(args.Blacklists...)
 	if err != nil {
 		logrus.Fatalf("failed to load the blacklist: %v", err)
 	}
-	people, nameFreqs, emailFreqs, err := idmatch.FindPeople(ctx, connStr, args.Cache, blacklist,
-		args.RecentMonths)
+	return blacklist
+}
+
+// gitbaseConnection returns the connection string of gitbase.
+func gitbaseConnection(args cliArgs) string {
+	return idmatch.GitbaseConnString(args.Host, args.Port, args.User, args.Password)
+}
+
+// errorHint suggests how to fix the error of the matching according to its kind.
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, idmatch.ErrExternalIDConflict):
+		return "; pass --external-id-conflicts review to record the conflicts instead of failing"
+	case errors.Is(err, idmatch.ErrSourceUnavailable):
+		return "; check that the source is reachable or increase --query-retries and --query-timeout"
+	case errors.Is(err, idmatch.ErrInvalidSignature):
+		return "; fix or remove the --cache file"
+	}
+	return ""
+}
+
+// findPeople reads the signatures and returns the people together with the blacklist
+// extended by the popular names and emails.
+func findPeople(ctx context.Context, args cliArgs, progress idmatch.ProgressReporter) (
+	idmatch.People, map[string]*idmatch.Frequency, map[string]*idmatch.Frequency, idmatch.Blacklist) {
+	blacklist := loadBlacklist(args)
+	logrus.Info("fetching signatures from the commits")
+	start := time.Now()
+	people, nameFreqs, emailFreqs, err := idmatch.FindPeopleWithOptions(ctx, idmatch.FindPeopleOptions{
+		ConnString:   gitbaseConnection(args),
+		CachePath:    args.Cache,
+		Extraction:   args.Extraction,
+		Blacklist:    blacklist,
+		Popularity:   args.Popularity,
+		Bots:         newBotDetectionOptions(args),
+		RecentMonths: args.RecentMonths,
+		Progress:     progress,
+	})
 	if err != nil {
-		logrus.Fatalf("failed to fetch the signatures: %v", err)
+		logrus.Fatalf("failed to fetch the signatures: %v%s", err, errorHint(err))
+	}
+	blacklist = blacklist.WithPopular(nameFreqs, emailFreqs, args.Popularity)
+	if detector := args.Extraction.SharedMailboxes; detector != nil {
+		mailboxes := detector.Mailboxes()
+		blacklist = blacklist.WithSharedMailboxes(mailboxes)
+		if len(mailboxes) > 0 {
+			logrus.Infof("%d shared mailboxes are not used to match the identities", len(mailboxes))
+		}
+		if args.SharedReport != "" {
+			if err := idmatch.WriteSharedMailboxes(args.SharedReport, mailboxes); err != nil {
+				logrus.Fatalf("failed to store the shared mailboxes: %v", err)
+			}
+			logrus.Infof("wrote %d shared mailboxes to %s", len(mailboxes), args.SharedReport)
+		}
 	}
 	logrus.WithFields(logrus.Fields{
 		"elapsed": time.Since(start),
 		"count":   len(people),
 	}).Info("found signatures")
+	if args.EmailIssues != "" && args.Extraction.EmailValidator != nil {
+		issues := args.Extraction.EmailValidator.Issues()
+		if cipher := loadEmailCipher(args); cipher != nil {
+			if issues, err = idmatch.EncryptEmailIssues(issues, cipher); err != nil {
+				logrus.Fatalf("failed to encrypt the email issues: %v", err)
+			}
+		}
+		if err := idmatch.WriteEmailIssues(args.EmailIssues, issues); err != nil {
+			logrus.Fatalf("failed to store the email issues: %v", err)
+		}
+		logrus.Infof("wrote %d repaired and invalid emails to %s", len(issues), args.EmailIssues)
+	}
+	if stats := args.Extraction.Stats; stats != nil {
+		if err := stats.Write(args.Stats); err != nil {
+			logrus.Fatalf("failed to store the name and email statistics: %v", err)
+		}
+		logrus.Infof("wrote the statistics of %d names and %d emails to %s",
+			len(stats.Names), len(stats.Emails), args.Stats)
+	}
+	return people, nameFreqs, emailFreqs, blacklist
+}
+
+// match reads the signatures, merges them and writes the identities to --output.
+func match(ctx context.Context, args cliArgs) {
+	progress := newProgressLogger()
+	prepareExtraction(&args)
+	extmatcher := newExternalMatcher(args)
+	people, nameFreqs, emailFreqs, blacklist := findPeople(ctx, args, progress)
+
+	if args.ExportPairs != "" {
+		pairs := idmatch.SampleTrainingPairs(people, blacklist, idmatch.TrainingPairOptions{
+			MaxPairs: args.MaxPairs})
+		if cipher := loadEmailCipher(args); cipher != nil {
+			if err := people.EncryptEmails(cipher); err != nil {
+				logrus.Fatalf("failed to encrypt the emails: %v", err)
+			}
+		}
+		if err := idmatch.WriteTrainingPairs(args.ExportPairs, people, pairs); err != nil {
+			logrus.Fatalf("failed to write the training pairs: %v", err)
+		}
+		logrus.Infof("wrote %d training pairs to %s", len(pairs), args.ExportPairs)
+		return
+	}
 
 	logrus.Info("reducing identities")
-	start = time.Now()
-	if err := idmatch.ReducePeople(people, extmatcher, blacklist, args.MaxIdentities); err != nil {
-		logrus.Fatalf("failed to reduce identities: %s", err)
+	start := time.Now()
+	peopleGraph, err := idmatch.BuildIdentityGraph(
+		ctx, people, extmatcher, blacklist, newReduceOptions(args, progress))
+	if err != nil {
+		logrus.Fatalf("failed to reduce identities: %s%s", err, errorHint(err))
+	}
+	if args.Graph != "" {
+		if err := peopleGraph.WriteGraph(args.Graph, idmatch.GraphFormat(args.GraphFormat)); err != nil {
+			logrus.Fatalf("failed to store the identity graph: %s", err)
+		}
+		logrus.Infof("stored the identity graph to %s", args.Graph)
+	}
+	if args.ExtIDReview != "" {
+		if err := peopleGraph.WriteExternalIDConflicts(args.ExtIDReview); err != nil {
+			logrus.Fatalf("failed to store the external ID conflicts: %s", err)
+		}
+		logrus.Infof("stored %d external ID conflicts to %s",
+			len(peopleGraph.ExternalIDConflicts()), args.ExtIDReview)
+	}
+	if args.OverMerges != "" {
+		if err := peopleGraph.WriteOverMerges(args.OverMerges); err != nil {
+			logrus.Fatalf("failed to store the refused over-merges: %s", err)
+		}
+		logrus.Infof("stored %d refused over-merges to %s",
+			len(peopleGraph.OverMerges()), args.OverMerges)
+	}
+	if args.DryRun != "" {
+		plan := peopleGraph.MergePlan()
+		if err := idmatch.WriteMergePlan(args.DryRun, plan); err != nil {
+			logrus.Fatalf("failed to store the merge plan: %s", err)
+		}
+		logrus.Infof("stored %d proposed merges to %s, the identities were not written",
+			len(plan), args.DryRun)
+		reporter.Write()
+		return
+	}
+	var emailNames map[string][]string
+	if args.Anomalies != "" {
+		emailNames = idmatch.EmailNames(people)
+	}
+	if err := peopleGraph.Reduce(ctx, people); err != nil {
+		logrus.Fatalf("failed to reduce identities: %s%s", err, errorHint(err))
 	}
 	logrus.WithFields(logrus.Fields{
 		"elapsed": time.Since(start),
 		"count":   len(people),
 	}).Info("reduced identities")
+	writeAnomalies(args, people, emailNames, blacklist)
 
-	start = time.Now()
-	idmatch.SetPrimaryValues(people, nameFreqs, emailFreqs, args.RecentMinCount)
+	if args.Evaluate != "" {
+		printEvaluation(args, people)
+	}
+	storeIdentities(args, people, nameFreqs, emailFreqs, extmatcher)
+}
+
+// shard matches the signatures of a part of the dataset and writes the partial identities
+// to --output.
+func shard(ctx context.Context, args cliArgs) {
+	progress := newProgressLogger()
+	prepareExtraction(&args)
+	extmatcher := newExternalMatcher(args)
+	people, nameFreqs, emailFreqs, blacklist := findPeople(ctx, args, progress)
+	logrus.Info("reducing identities")
+	start := time.Now()
+	err := idmatch.ReducePeople(ctx, people, extmatcher, blacklist, newReduceOptions(args, progress))
+	if err != nil {
+		logrus.Fatalf("failed to reduce identities: %s%s", err, errorHint(err))
+	}
+	logrus.WithFields(logrus.Fields{
+		"elapsed": time.Since(start),
+		"count":   len(people),
+	}).Info("reduced identities")
+	err = idmatch.WritePartialPeople(args.Output, args.Shard, people, nameFreqs, emailFreqs, blacklist)
+	if err != nil {
+		logrus.Fatalf("failed to store the shard: %s", err)
+	}
+	logrus.Infof("stored the shard %s to %s", args.Shard, args.Output)
+	reporter.Write()
+}
+
+// reduce merges the partial identities of the shards and writes them to --output.
+func reduce(args cliArgs) {
+	extmatcher := newExternalMatcher(args)
+	logrus.Info("merging the shards")
+	start := time.Now()
+	if args.Extraction.Reproducible {
+		sort.Strings(args.Shards)
+	}
+	blacklist := loadBlacklist(args)
+	people, nameFreqs, emailFreqs, err := idmatch.MergePartialPeople(
+		args.Shards, blacklist, args.Popularity)
+	if err != nil {
+		logrus.Fatalf("failed to merge the shards: %v", err)
+	}
+	logrus.WithFields(logrus.Fields{
+		"elapsed": time.Since(start),
+		"count":   len(people),
+	}).Info("merged the shards")
+	writeAnomalies(args, people, nil, blacklist)
+	storeIdentities(args, people, nameFreqs, emailFreqs, extmatcher)
+}
+
+// writeAnomalies writes the suspicious identities to --anomalies if it is not blank. emailNames
+// are the names of each email before the matching, see idmatch.EmailNames.
+func writeAnomalies(args cliArgs, people idmatch.People, emailNames map[string][]string,
+	blacklist idmatch.Blacklist) {
+	if args.Anomalies == "" {
+		return
+	}
+	anomalies := idmatch.FindAnomalies(people, emailNames, blacklist, args.Anomaly)
+	if err := idmatch.WriteAnomalies(args.Anomalies, anomalies); err != nil {
+		logrus.Fatalf("failed to store the anomalies: %v", err)
+	}
+	logrus.Infof("wrote %d anomalies to %s", len(anomalies), args.Anomalies)
+}
+
+// readIdentities reads the identities which were matched before and decrypts their emails
+// with --email-key.
+func readIdentities(args cliArgs, path string) (idmatch.People, string) {
+	people, provider, err := idmatch.ReadIdentitiesFromParquet(path, loadEmailCipher(args))
+	if err != nil {
+		logrus.Fatalf("failed to read the identities from %s: %v", path, err)
+	}
+	return people, provider
+}
+
+// enrich adds the external ids to the identities which were matched before and writes them
+// to --output.
+func enrich(ctx context.Context, args cliArgs, input string) {
+	people, _ := readIdentities(args, input)
+	extmatcher := newExternalMatcher(args)
+	count, err := idmatch.EnrichPeople(ctx, people, extmatcher, args.External)
+	if err != nil {
+		logrus.Fatalf("failed to enrich the identities: %v", err)
+	}
+	idmatch.SetPreferredEmails(people, extmatcher, args.External)
+	if cipher := loadEmailCipher(args); cipher != nil {
+		if err := people.EncryptEmails(cipher); err != nil {
+			logrus.Fatalf("failed to encrypt the emails: %v", err)
+		}
+	}
+	if err := people.WriteToParquet(args.Output, args.External); err != nil {
+		logrus.Fatalf("failed to store identities: %s", err)
+	}
+	logrus.Infof("found the external ids of %d identities out of %d, stored them to %s",
+		count, len(people), args.Output)
+	reporter.Write()
+}
+
+// export converts the identities which were matched before to --format.
+func export(ctx context.Context, args cliArgs, input string) {
+	people, provider := readIdentities(args, input)
+	var err error
+	switch args.Format {
+	case "mailmap":
+		err = people.WriteMailmap(args.Output)
+	case "avro":
+		err = writeOutput(args.Output, func(w io.Writer) error {
+			return people.WriteAvro(w, provider)
+		})
+	case "proto":
+		err = writeOutput(args.Output, func(w io.Writer) error {
+			_, err := w.Write(people.MarshalProto(provider))
+			return err
+		})
+	case "bigquery":
+		err = exportBigQuery(ctx, args.Output, people, provider)
+	case "snowflake":
+		err = exportSnowflake(ctx, args.Snowflake, args.Output, people, provider)
+	case "affiliations":
+		err = people.WriteAffiliations(args.Output, newOrganizations(args))
+	case "gitdm":
+		err = people.WriteGitdm(args.Output, args.GitdmAliases, newOrganizations(args))
+	case "sortinghat":
+		err = people.WriteSortingHat(args.Output, newOrganizations(args))
+	case "repos-json", "repos-csv":
+		var signatures []idmatch.Signature
+		if signatures, err = idmatch.ReadSignatures(args.Cache); err == nil {
+			views := people.RepoViews(signatures)
+			err = idmatch.WriteRepoViews(args.Output, views, strings.TrimPrefix(args.Format, "repos-"))
+		}
+	case "teams":
+		var teams idmatch.Teams
+		if teams, err = idmatch.ReadTeams(args.Teams); err == nil {
+			err = idmatch.WriteTeamSummaries(args.Output, people.TeamSummaries(teams))
+		}
+	case "repo-teams":
+		var teams idmatch.Teams
+		var signatures []idmatch.Signature
+		if teams, err = idmatch.ReadTeams(args.Teams); err == nil {
+			if signatures, err = idmatch.ReadSignatures(args.Cache); err == nil {
+				repoTeams := teams.RepoTeams(people, people.RepoViews(signatures))
+				err = idmatch.WriteRepoTeams(args.Output, repoTeams)
+			}
+		}
+	case "assignments":
+		var signatures []idmatch.Signature
+		if signatures, err = idmatch.ReadSignatures(args.Cache); err == nil {
+			err = idmatch.WriteAssignments(args.Output, people.Assignments(signatures))
+		}
+	default:
+		err = people.WriteToCSV(args.Output, provider)
+	}
+	if err != nil {
+		logrus.Fatalf("failed to export the identities: %v", err)
+	}
+	logrus.Infof("exported %d identities to %s", len(people), args.Output)
+}
+
+// exportBigQuery replaces the BigQuery table "project.dataset.table" with the people. The client
+// uses the application default credentials.
+func exportBigQuery(ctx context.Context, table string, people idmatch.People, provider string) error {
+	parts := strings.Split(table, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("the BigQuery table must be project.dataset.table, got %s", table)
+	}
+	client, err := bq.NewClient(ctx, parts[0])
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return bigquery.Export(ctx, client.Dataset(parts[1]).Table(parts[2]), people, provider)
+}
+
+// exportSnowflake replaces the Snowflake table with the people, dsn is the gosnowflake DSN.
+func exportSnowflake(ctx context.Context, dsn string, table string, people idmatch.People,
+	provider string) error {
+	db, err := sql.Open("snowflake", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return snowflake.Export(ctx, db, table, people, provider)
+}
+
+// importSortingHat converts the JSON file of "sortinghat export" to the identities.
+func importSortingHat(args cliArgs, input string) {
+	people, orgs, err := idmatch.ReadSortingHat(input)
+	if err != nil {
+		logrus.Fatalf("failed to read the SortingHat identities: %v", err)
+	}
+	if args.Organizations != "" {
+		if err = orgs.WriteOrganizations(args.Organizations); err != nil {
+			logrus.Fatalf("failed to store the organizations: %v", err)
+		}
+	}
+	if cipher := loadEmailCipher(args); cipher != nil {
+		if err = people.EncryptEmails(cipher); err != nil {
+			logrus.Fatalf("failed to encrypt the emails: %v", err)
+		}
+	}
+	if err = people.WriteToParquet(args.Output, idmatch.SortingHatProvider); err != nil {
+		logrus.Fatalf("failed to store the identities: %v", err)
+	}
+	logrus.Infof("imported %d identities and %d organization domains to %s",
+		len(people), len(orgs), args.Output)
+}
+
+// newOrganizations converts --organizations.
+func newOrganizations(args cliArgs) idmatch.Organizations {
+	orgs := idmatch.NewOrganizations()
+	if args.Organizations != "" {
+		custom, err := idmatch.ReadOrganizations(args.Organizations)
+		if err != nil {
+			logrus.Fatalf("failed to load the organizations: %v", err)
+		}
+		orgs = orgs.Merge(custom)
+	}
+	return orgs
+}
+
+// writeOutput creates the local file or the object in path and writes it with write.
+func writeOutput(path string, write func(io.Writer) error) (err error) {
+	var file io.WriteCloser
+	file, err = idmatch.CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	return write(file)
+}
+
+// evaluate compares the identities which were matched before with the ground truth.
+func evaluate(args cliArgs, input string) {
+	people, _ := readIdentities(args, input)
+	printEvaluation(args, people)
+}
+
+// printEvaluation compares the people with the ground truth in --evaluate or --truth.
+func printEvaluation(args cliArgs, people idmatch.People) {
+	truth, err := idmatch.ReadGroundTruth(args.Evaluate)
+	if err != nil {
+		logrus.Fatalf("failed to load the ground truth: %v", err)
+	}
+	fmt.Print(idmatch.Evaluate(people, truth))
+}
+
+// newBotDetectionOptions converts --bots and --bot-min-commits.
+func newBotDetectionOptions(args cliArgs) idmatch.BotDetectionOptions {
+	botOpts := idmatch.BotDetectionOptions{}
+	if args.Bots != "off" {
+		botOpts = idmatch.NewBotDetectionOptions()
+		botOpts.Exclude = args.Bots == "exclude"
+		botOpts.MinCommits = args.BotMinCommits
+	}
+	return botOpts
+}
+
+// newEmailValidator converts the email validation flags. It returns nil if the validation is off.
+func newEmailValidator(args cliArgs) *idmatch.EmailValidator {
+	if args.EmailCheck == "off" {
+		return nil
+	}
+	validator := idmatch.NewEmailValidator()
+	if args.EmailRepairs != "" {
+		repairs, err := idmatch.ReadEmailRepairs(args.EmailRepairs)
+		if err != nil {
+			logrus.Fatalf("failed to load the email repairs: %v", err)
+		}
+		validator.Repairs = validator.Repairs.Merge(repairs)
+	}
+	validator.CheckMX = args.EmailMX
+	validator.Exclude = args.EmailCheck == "exclude"
+	return validator
+}
+
+// newDomainPolicies merges the built-in domain policies with --domain-policies.
+func newDomainPolicies(args cliArgs) idmatch.DomainPolicies {
+	domainPolicies := idmatch.NewDomainPolicies()
+	if args.DomainPolicies != "" {
+		customPolicies, err := idmatch.ReadDomainPolicies(args.DomainPolicies)
+		if err != nil {
+			logrus.Fatalf("failed to load the domain policies: %v", err)
+		}
+		domainPolicies = domainPolicies.Merge(customPolicies)
+	}
+	return domainPolicies
+}
+
+// newPrimaryOptions converts the flags which choose the primary names and emails.
+func newPrimaryOptions(args cliArgs) idmatch.PrimaryOptions {
+	return idmatch.PrimaryOptions{
+		Strategy:       idmatch.PrimaryStrategy(args.Primary),
+		MinRecentCount: args.RecentMinCount,
+		DomainPolicies: newDomainPolicies(args),
+	}
+}
+
+// newReduceOptions loads the files referenced by the matching flags and converts the flags.
+func newReduceOptions(args cliArgs, progress idmatch.ProgressReporter) idmatch.ReduceOptions {
+	emailAliases := idmatch.NewEmailAliasRules()
+	if args.EmailAliases != "" {
+		customAliases, err := idmatch.ReadEmailAliasRules(args.EmailAliases)
+		if err != nil {
+			logrus.Fatalf("failed to load the email alias rules: %v", err)
+		}
+		emailAliases = emailAliases.Merge(customAliases)
+	}
+	var pairScorer idmatch.PairScorer
+	if args.PairModel != "" {
+		var err error
+		pairScorer, err = idmatch.LoadPairScorer(args.PairModel)
+		if err != nil {
+			logrus.Fatalf("failed to load the pair classifier: %v", err)
+		}
+	}
+	blockers, err := idmatch.ParseBlockers(args.Blockers)
+	if err != nil {
+		logrus.Fatalf("unsupported --blockers value: %v", err)
+	}
+	noreplyUsers, err := idmatch.ParseNoReplyUserPatterns(args.NoReplyUsers)
+	if err != nil {
+		logrus.Fatalf("invalid --noreply-users value: %v", err)
+	}
+	var behavior *idmatch.BehaviorOptions
+	if args.Behavior.MinCommits > 0 {
+		behavior = &args.Behavior
+	}
+	var style *idmatch.StyleOptions
+	if args.Style.SampleSize > 0 {
+		sampler, err := idmatch.NewGitbaseDiffSampler(gitbaseConnection(args), args.Extraction)
+		if err != nil {
+			logrus.Fatalf("failed to connect to gitbase: %v", err)
+		}
+		style = &args.Style
+		style.Sampler = sampler
+		style.Workers = args.Workers
+	}
+	externalIDs := idmatch.ExternalIDOptions{
+		Conflicts: idmatch.ExternalIDConflictPolicy(args.ExtIDConflicts),
+		Provider:  args.External,
+	}
+	var constraints idmatch.Constraints
+	if args.Constraints != "" {
+		var err error
+		constraints, err = idmatch.ReadConstraints(args.Constraints)
+		if err != nil {
+			logrus.Fatalf("failed to load the constraints: %v", err)
+		}
+	}
+	if mailmap := loadMailmap(args); mailmap != nil {
+		mailmapConstraints, err := mailmap.Constraints()
+		if err != nil {
+			logrus.Fatalf("failed to convert the mailmap: %v", err)
+		}
+		constraints = append(constraints, mailmapConstraints...)
+	}
+	var decisions idmatch.ReviewDecisions
+	if args.Decisions != "" {
+		var err error
+		decisions, err = idmatch.ReadReviewDecisions(args.Decisions)
+		if os.IsNotExist(err) {
+			logrus.Infof("no review decisions in %s yet", args.Decisions)
+		} else if err != nil {
+			logrus.Fatalf("failed to load the review decisions: %v", err)
+		}
+	}
+	samples := idmatch.SampleCommitOptions{Max: args.SampleCommits}
+	if args.RepoWeights != "" {
+		var err error
+		if samples.RepoWeights, err = idmatch.ReadRepoWeights(args.RepoWeights); err != nil {
+			logrus.Fatalf("failed to load the repository weights: %v", err)
+		}
+	}
+	return idmatch.ReduceOptions{
+		MaxIdentities:           args.MaxIdentities,
+		MatchReorderedNames:     args.ReorderedNames,
+		MatchDomainChanges:      args.DomainChanges,
+		MaxNameTokenFrequency:   args.MaxTokenFreq,
+		NoReplyUsers:            noreplyUsers,
+		EmailAliases:            emailAliases,
+		DomainPolicies:          newDomainPolicies(args),
+		MinEdgeWeight:           args.MinEdgeWeight,
+		EvidenceWeights:         args.Weights,
+		Behavior:                behavior,
+		Style:                   style,
+		ExternalIDs:             externalIDs,
+		MinRepositorySimilarity: args.MinRepoSim,
+		PairScorer:              pairScorer,
+		MinPairProbability:      args.MinPairProb,
+		Blockers:                blockers,
+		MaxBlockSize:            args.MaxBlockSize,
+		ExplainMerges:           args.Explain,
+		Decisions:               decisions,
+		Constraints:             constraints,
+		MaxClusterEmails:        args.ClusterEmails,
+		MaxClusterNames:         args.ClusterNames,
+		SampleCommits:           samples,
+		Workers:                 args.Workers,
+		Progress:                progress,
+	}
+}
+
+// loadMailmap reads --mailmap. It returns nil if the flag is blank.
+func loadMailmap(args cliArgs) idmatch.Mailmap {
+	if args.Mailmap == "" {
+		return nil
+	}
+	mailmap, err := idmatch.ReadMailmap(args.Mailmap)
+	if err != nil {
+		logrus.Fatalf("failed to load the mailmap: %v", err)
+	}
+	return mailmap
+}
+
+// serve runs the gRPC service and the REST API until they fail, see the service package.
+func serve(args cliArgs) {
+	prepareExtraction(&args)
+	progress := newProgressLogger()
+	blacklist := loadBlacklist(args)
+	extmatcher := newExternalMatcher(args)
+	server := service.NewServer(service.Options{
+		Extraction:          args.Extraction,
+		Blacklist:           blacklist,
+		Popularity:          args.Popularity,
+		Bots:                newBotDetectionOptions(args),
+		RecentMonths:        args.RecentMonths,
+		Reduce:              newReduceOptions(args, progress),
+		Primary:             newPrimaryOptions(args),
+		Matcher:             extmatcher,
+		ExternalIDProvider:  args.External,
+		ReviewMargin:        args.ReviewMargin,
+		ReviewDecisionsPath: args.Decisions,
+		ExportDir:           args.ExportDir,
+	})
+	if args.HTTP != "" {
+		go func() {
+			logrus.Infof("serving the identity matching REST API on http://%s/", args.HTTP)
+			if err := http.ListenAndServe(args.HTTP, service.NewHTTPHandler(server)); err != nil {
+				logrus.Fatalf("failed to serve the REST API: %v", err)
+			}
+		}()
+	}
+	if args.Listen == "" {
+		select {}
+	}
+	listener, err := net.Listen("tcp", args.Listen)
+	if err != nil {
+		logrus.Fatalf("failed to listen on %s: %v", args.Listen, err)
+	}
+	grpcServer := grpc.NewServer()
+	service.RegisterIdentityMatchingServer(grpcServer, server)
+	logrus.Infof("serving the identity matching on %s", args.Listen)
+	if err := grpcServer.Serve(listener); err != nil {
+		logrus.Fatalf("failed to serve: %v", err)
+	}
+}
+
+// streamIdentities consumes the signatures from --input-topic and publishes the changes of
+// the identities to --output-topic until ctx is cancelled, see the stream package.
+func streamIdentities(ctx context.Context, args cliArgs) {
+	prepareExtraction(&args)
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: args.Brokers,
+		GroupID: args.Group,
+		Topic:   args.InputTopic,
+	})
+	defer reader.Close()
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(args.Brokers...),
+		Topic:    args.OutputTopic,
+		Balancer: &kafka.Hash{},
+	}
+	defer writer.Close()
+	processor := stream.NewProcessor(stream.Options{
+		Options: service.Options{
+			Extraction:         args.Extraction,
+			Blacklist:          loadBlacklist(args),
+			Popularity:         args.Popularity,
+			Bots:               newBotDetectionOptions(args),
+			RecentMonths:       args.RecentMonths,
+			Reduce:             newReduceOptions(args, nil),
+			Primary:            newPrimaryOptions(args),
+			Matcher:            newExternalMatcher(args),
+			ExternalIDProvider: args.External,
+		},
+		BatchSize:     args.BatchSize,
+		FlushInterval: args.FlushInterval,
+	})
+	logrus.Infof("streaming the identities from %s to %s", args.InputTopic, args.OutputTopic)
+	if err := processor.Run(ctx, reader, writer); err != nil && ctx.Err() == nil {
+		logrus.Fatalf("failed to stream the identities: %v", err)
+	}
+	logrus.Infof("stopped streaming with %d identities", len(processor.People()))
+	reporter.Write()
+}
+
+// storeIdentities sets the primary names and emails of the people and writes them to --output.
+func storeIdentities(args cliArgs, people idmatch.People,
+	nameFreqs, emailFreqs map[string]*idmatch.Frequency, extmatcher external.Matcher) {
+	start := time.Now()
+	idmatch.SetPrimaryValues(people, nameFreqs, emailFreqs, newPrimaryOptions(args))
+	if extmatcher != nil {
+		idmatch.SetPreferredEmails(people, extmatcher, args.External)
+	}
+	if mailmap := loadMailmap(args); mailmap != nil {
+		if err := mailmap.SetPrimaryValues(people); err != nil {
+			logrus.Fatalf("failed to apply the mailmap: %v", err)
+		}
+	}
 	logrus.WithFields(logrus.Fields{
 		"elapsed": time.Since(start),
 	}).Info("set primary names and emails")
+	if args.Pseudonymize != "" {
+		pseudonymizer, err := idmatch.NewPseudonymizer(args.Pseudonymize)
+		if err != nil {
+			logrus.Fatalf("failed to pseudonymize the identities: %v", err)
+		}
+		people.Pseudonymize(pseudonymizer)
+		logrus.Info("pseudonymized the names and emails")
+	}
+	if cipher := loadEmailCipher(args); cipher != nil {
+		if err := people.EncryptEmails(cipher); err != nil {
+			logrus.Fatalf("failed to encrypt the emails: %v", err)
+		}
+		logrus.Info("encrypted the emails")
+	}
 
 	logrus.Info("storing identities")
 	start = time.Now()
-	if err := people.WriteToParquet(args.Output, args.External); err != nil {
+	if args.PartitionSize > 0 {
+		err := people.WritePartitionedParquet(args.Output, args.External,
+			idmatch.PartitionOptions{PeoplePerPartition: args.PartitionSize})
+		if err != nil {
+			logrus.Fatalf("failed to store identities: %s", err)
+		}
+	} else if err := people.WriteToParquet(args.Output, args.External); err != nil {
 		logrus.Fatalf("failed to store identities: %s", err)
 	}
+	if args.Explain {
+		if err := people.WriteMergesToParquet(args.Output); err != nil {
+			logrus.Fatalf("failed to store the merge evidence: %s", err)
+		}
+	}
+	if args.ExportMailmap != "" {
+		if err := people.WriteMailmap(args.ExportMailmap); err != nil {
+			logrus.Fatalf("failed to store the mailmap: %s", err)
+		}
+	}
+	if args.Versions != "" {
+		store, err := idmatch.OpenPeopleStore(args.Versions)
+		if err != nil {
+			logrus.Fatalf("failed to open the identity versions: %v", err)
+		}
+		version, err := store.Save(people, args.External, args.VersionLabel)
+		if err != nil {
+			logrus.Fatalf("failed to store the identity version: %v", err)
+		}
+		logrus.Infof("stored the identities as version %s of %s", version.ID, args.Versions)
+	}
 	logrus.WithFields(logrus.Fields{
 		"elapsed": time.Since(start),
 		"path":    args.Output,
@@ -126,48 +1032,224 @@ func main() {
 	reporter.Write()
 }
 
-func parseArgs() cliArgs {
-	var matchers []string
-	for key := range external.Matchers {
-		matchers = append(matchers, key)
-	}
-	sort.Strings(matchers)
-
-	args := cliArgs{}
-	flag.StringVar(&args.Output, "output", "", "path to the parquet file to write")
-	flag.StringVar(&args.Host, "host", "0.0.0.0", "gitbase host")
-	flag.UintVar(&args.Port, "port", 3306, "gitbase port")
-	flag.StringVar(&args.User, "user", "root", "gitbase user, normally the default value is fine")
-	flag.StringVar(&args.Password, "password", "", "gitbase password")
-	flag.StringVar(&args.External, "external", "",
-		"enable external service matching, options: "+strings.Join(matchers, ", "))
-	flag.StringVar(&args.APIURL, "api-url", "",
-		"API URL of the external matching service, the blank value means the public website")
-	flag.StringVar(&args.Token, "token", "", "API token for the external matching service")
-	flag.StringVar(&args.Cache, "cache", fmt.Sprintf("cache-raw-%s.csv", idmatch.HashPeopleDiscoverySQL()),
-		"Path to the cached raw signatures")
-	flag.StringVar(&args.ExternalCache, "external-cache", "cache-external-{provider}.csv",
-		"Path to the cached matches found by using an external identity service such as GitHub API."+
-			"{provider} will be replaced with the external service name.")
-	flag.IntVar(&args.MaxIdentities, "max-identities", 20,
-		"If a person has more than this number of unique names and unique emails summed, "+
-			"no more identities will be merged. If the identities are matched by an external API "+
-			"or by email this limitation can be violated.")
-	flag.IntVar(&args.RecentMonths, "months", 12,
-		"Number of preceding months to consider while calculating stats for detecting "+
-			"the primary names and emails.")
-	flag.IntVar(&args.RecentMinCount, "min-count", 5,
-		"Minimum total number of commits the identity should have in the last --months so that "+
-			"the corresponding stats are used for detecting the primary names and emails. "+
-			"Otherwise, the stats collected through all the time will be used.")
-	flag.CommandLine.SortFlags = false
-	flag.Parse()
-
-	if args.External != "" {
-		if _, exists := external.Matchers[args.External]; !exists {
-			logrus.Fatalf("unsupported external matching service: %s", args.External)
-		}
-	}
-	args.ExternalCache = strings.ReplaceAll(args.ExternalCache, "{provider}", args.External)
-	return args
+// erase adds the identifiers to --suppressions and removes all the suppressed emails and names
+// from --cache, --external-cache and --output.
+func erase(args cliArgs) {
+	erased, err := idmatch.NewSuppressions(args.Identifiers...)
+	if err != nil {
+		logrus.Fatalf("failed to clean the erased identifiers: %v", err)
+	}
+	suppressions := loadSuppressions(args).Merge(erased)
+	if err := suppressions.Write(args.Suppressions); err != nil {
+		logrus.Fatalf("failed to store the suppressions: %v", err)
+	}
+	logrus.Infof("stored %d suppressed emails and names to %s", suppressions.Len(), args.Suppressions)
+	if !strings.HasSuffix(args.Cache, ".arrow") {
+		// the cache may be chunked, so its absence is only known after trying
+		count, err := idmatch.EraseSignatureCache(args.Cache, suppressions)
+		if err != nil && !os.IsNotExist(err) {
+			logrus.Fatalf("failed to erase the signatures cache: %v", err)
+		}
+		if err == nil {
+			logrus.Infof("erased %d signatures from %s", count, args.Cache)
+		}
+	}
+	if args.ExternalCache != "" && external.PathExists(args.ExternalCache) {
+		count, err := external.EraseCachedEmails(args.ExternalCache, suppressions.Emails)
+		if err != nil {
+			logrus.Fatalf("failed to erase the external identities cache: %v", err)
+		}
+		logrus.Infof("erased %d emails from %s", count, args.ExternalCache)
+	}
+	if args.Output != "" {
+		count, err := idmatch.EraseIdentities(args.Output, suppressions, loadEmailCipher(args))
+		if err != nil && !os.IsNotExist(err) {
+			logrus.Fatalf("failed to erase the identities: %v", err)
+		}
+		logrus.Infof("erased %d emails and names from %s", count, args.Output)
+	}
+	if args.Versions != "" {
+		store, err := idmatch.OpenPeopleStore(args.Versions)
+		if err != nil {
+			logrus.Fatalf("failed to open the identity versions: %v", err)
+		}
+		count, err := store.Erase(suppressions, loadEmailCipher(args))
+		if err != nil {
+			logrus.Fatalf("failed to erase the identity versions: %v", err)
+		}
+		logrus.Infof("erased %d emails and names from the versions in %s", count, args.Versions)
+	}
+}
+
+// loadEmailCipher reads --email-key. It returns nil if the flag is blank.
+func loadEmailCipher(args cliArgs) *idmatch.EmailCipher {
+	if args.EmailKey == "" {
+		return nil
+	}
+	cipher, err := idmatch.LoadEmailCipher(args.EmailKey)
+	if err != nil {
+		logrus.Fatalf("failed to load the email key: %v", err)
+	}
+	return cipher
+}
+
+// decrypt writes the copy of the identities or the CSV file with the decrypted emails to --output.
+func decrypt(args cliArgs) {
+	cipher := loadEmailCipher(args)
+	input := args.Identifiers[0]
+	if strings.HasSuffix(input, ".parquet") {
+		people, provider, err := idmatch.ReadIdentitiesFromParquet(input, cipher)
+		if err != nil {
+			logrus.Fatalf("failed to read the identities: %v", err)
+		}
+		if err = people.WriteToParquet(args.Output, provider); err != nil {
+			logrus.Fatalf("failed to store the identities: %v", err)
+		}
+	} else if err := idmatch.DecryptCSV(input, args.Output, cipher); err != nil {
+		logrus.Fatalf("failed to decrypt %s: %v", input, err)
+	}
+	logrus.Infof("decrypted %s to %s", input, args.Output)
+}
+
+// diff compares the identities of two runs and writes the changes to --output.
+func diff(args cliArgs) {
+	cipher := loadEmailCipher(args)
+	var changes []idmatch.IdentityChange
+	if args.Versions != "" {
+		store, err := idmatch.OpenPeopleStore(args.Versions)
+		if err != nil {
+			logrus.Fatalf("failed to open the identity versions: %v", err)
+		}
+		if changes, err = store.Diff(args.Identifiers[0], args.Identifiers[1], cipher); err != nil {
+			logrus.Fatalf("failed to compare the versions: %v", err)
+		}
+	} else {
+		var runs [2]idmatch.People
+		for i, path := range args.Identifiers {
+			var err error
+			if runs[i], _, err = idmatch.ReadIdentitiesFromParquet(path, cipher); err != nil {
+				logrus.Fatalf("failed to read the identities from %s: %v", path, err)
+			}
+		}
+		changes = idmatch.DiffPeople(runs[0], runs[1])
+	}
+	if err := idmatch.WriteIdentityChanges(args.Output, changes); err != nil {
+		logrus.Fatalf("failed to store the changes: %v", err)
+	}
+	counts := map[idmatch.IdentityChangeKind]int{}
+	for _, change := range changes {
+		counts[change.Kind]++
+	}
+	logrus.Infof("wrote %d changes to %s: %d split, %d merged, %d gained emails, %d lost emails, "+
+		"%d changed external ids, %d added, %d removed", len(changes), args.Output,
+		counts[idmatch.IdentitySplit], counts[idmatch.IdentityMerged], counts[idmatch.IdentityGainedEmails],
+		counts[idmatch.IdentityLostEmails], counts[idmatch.IdentityExternalIDChanged],
+		counts[idmatch.IdentityAdded], counts[idmatch.IdentityRemoved])
+}
+
+// versions lists the versions of the identities in the store and makes --rollback current.
+func versions(args cliArgs, dir string) {
+	store, err := idmatch.OpenPeopleStore(dir)
+	if err != nil {
+		logrus.Fatalf("failed to open the identity versions: %v", err)
+	}
+	if args.Rollback != "" {
+		if err := store.Rollback(args.Rollback); err != nil {
+			logrus.Fatalf("failed to roll back: %v", err)
+		}
+		logrus.Infof("version %s is current, its identities are %s", args.Rollback,
+			store.Path(args.Rollback))
+	}
+	list, err := store.Versions()
+	if err != nil {
+		logrus.Fatalf("failed to list the identity versions: %v", err)
+	}
+	current, _, err := store.Current()
+	if err != nil {
+		logrus.Fatalf("failed to read the current version: %v", err)
+	}
+	for _, version := range list {
+		marker := " "
+		if version.ID == current.ID {
+			marker = "*"
+		}
+		fmt.Printf("%s %s  %s  %8d people  %s\n", marker, version.ID,
+			version.Created.Format(time.RFC3339), version.People, version.Label)
+	}
+}
+
+// lookup prints the identities which are the most similar to the query.
+func lookup(args cliArgs, input, query string) {
+	people, _ := readIdentities(args, input)
+	results := people.Index().Search(query, args.LookupLimit, args.LookupScore)
+	if len(results) == 0 {
+		logrus.Infof("found no identities similar to %q", query)
+		return
+	}
+	for _, result := range results {
+		person := result.Person
+		fmt.Printf("%d  %.2f  %s <%s>  matched %q\n", person.ID, result.Score, person.PrimaryName,
+			person.PrimaryEmail, result.Alias)
+		fmt.Printf("    emails: %s\n", strings.Join(person.Emails, ", "))
+		var externalIDs []string
+		for provider, id := range person.ExternalIDs {
+			externalIDs = append(externalIDs, provider+":"+id)
+		}
+		if len(externalIDs) > 0 {
+			sort.Strings(externalIDs)
+			fmt.Printf("    external ids: %s\n", strings.Join(externalIDs, ", "))
+		}
+		if person.SampleCommit != nil {
+			fmt.Printf("    sample commit: %s %s\n", person.SampleCommit.Repo, person.SampleCommit.Hash)
+		}
+	}
+}
+
+// writeMemProfile stores the heap profile at the end of the run to inspect with "go tool pprof".
+func writeMemProfile(path string) {
+	file, err := os.Create(path)
+	if err != nil {
+		logrus.Errorf("failed to write the memory profile: %v", err)
+		return
+	}
+	defer file.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(file); err != nil {
+		logrus.Errorf("failed to write the memory profile: %v", err)
+		return
+	}
+	logrus.Infof("stored the memory profile to %s", path)
+}
+
+// progressLogInterval is the minimum time between two progress messages of the same stage.
+const progressLogInterval = 5 * time.Second
+
+// newProgressLogger returns the ProgressReporter which logs the progress of each stage
+// with the estimated time left.
+func newProgressLogger() idmatch.ProgressReporter {
+	var stage string
+	var start, lastLog time.Time
+	return func(name string, processed, total int) {
+		now := time.Now()
+		if name != stage {
+			stage = name
+			start = now
+			lastLog = now
+			return
+		}
+		finished := total > 0 && processed >= total
+		if !finished && now.Sub(lastLog) < progressLogInterval {
+			return
+		}
+		lastLog = now
+		fields := logrus.Fields{"processed": processed, "elapsed": now.Sub(start).Round(time.Second)}
+		if total > 0 {
+			fields["total"] = total
+			if processed > 0 && !finished {
+				eta := time.Duration(float64(now.Sub(start)) * float64(total-processed) / float64(processed))
+				fields["eta"] = eta.Round(time.Second)
+			}
+		}
+		logrus.WithFields(fields).Info(stage)
+	}
 }
@@ -0,0 +1,136 @@
+package idmatch
+
+import (
+	"runtime"
+	"strings"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/writer"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// parquetSignatureCacheKey is the key of the file metadata of the parquet signatures cache
+// written by storeSignaturesToParquet, which tells it apart from the commits tables written
+// elsewhere. The value is parquetSignatureCacheVersion.
+const parquetSignatureCacheKey = "idmatch.signature_cache"
+
+// parquetSignatureCacheVersion is the layout of the parquet signatures cache: the values are
+// normalized and the signatures are not aggregated, so they are read back as is.
+const parquetSignatureCacheVersion = "1"
+
+// parquetSignature is the row of the parquet signatures cache, see signatureCSVHeader.
+type parquetSignature struct {
+	Repo       string `parquet:"name=repo, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	Name       string `parquet:"name=name, type=UTF8"`
+	Email      string `parquet:"name=email, type=UTF8"`
+	Hash       string `parquet:"name=hash, type=UTF8"`
+	Time       int64  `parquet:"name=time, type=TIMESTAMP_MILLIS"`
+	Source     string `parquet:"name=source, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	Role       string `parquet:"name=role, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	SigningKey string `parquet:"name=signing_key, type=UTF8"`
+	Activity   string `parquet:"name=activity, type=UTF8"`
+}
+
+// storeSignaturesToParquet writes the signatures cache to the snappy-compressed parquet file.
+// The columns are the same as in the CSV cache, but the time is the timestamp. The values are
+// normalized before they are written and the file metadata marks them so, so that
+// readSignaturesFromParquet skips the normalization and the aggregation of the commits tables.
+func storeSignaturesToParquet(path string, commits []Signature) (err error) {
+	file, err := createParquetFile(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	pw, err := writer.NewParquetWriter(file, new(parquetSignature), int64(runtime.NumCPU()))
+	if err != nil {
+		return err
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	for _, signature := range commits {
+		values := []string{signature.Repo, signature.Name, signature.Email, signature.Hash,
+			string(signature.Source), string(signature.Role), signature.SigningKey,
+			signature.Activity.String()}
+		for i, value := range values {
+			if values[i], err = normalizeSignatureValue(value); err != nil {
+				return err
+			}
+		}
+		// not timeToMillis because the zero time must survive
+		millis := signature.Time.Unix()*1000 + int64(signature.Time.Nanosecond())/1e6
+		if err = pw.Write(parquetSignature{
+			values[0], values[1], values[2], values[3], millis, values[4], values[5], values[6],
+			values[7]}); err != nil {
+			return err
+		}
+	}
+	version := parquetSignatureCacheVersion
+	pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata,
+		&parquet.KeyValue{Key: parquetSignatureCacheKey, Value: &version})
+	return pw.WriteStop()
+}
+
+// isParquetSignatureCache returns whether the parquet file was written by
+// storeSignaturesToParquet rather than being a commits table.
+func isParquetSignatureCache(pr *reader.ParquetReader) bool {
+	for _, kv := range pr.Footer.GetKeyValueMetadata() {
+		if kv.GetKey() == parquetSignatureCacheKey {
+			return kv.GetValue() == parquetSignatureCacheVersion
+		}
+	}
+	return false
+}
+
+// isWritableSignatureCache returns whether EraseSignatureCache may rewrite the signatures cache
+// in path: the CSV file or the parquet signatures cache, but not a commits table.
+func isWritableSignatureCache(path string) (bool, error) {
+	if strings.HasSuffix(path, ".arrow") {
+		return false, nil
+	}
+	if !strings.HasSuffix(path, ".parquet") {
+		return true, nil
+	}
+	file, err := openParquetFile(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			reporter.Warnf("failed to close %s: %v", path, err)
+		}
+	}()
+	pr, err := reader.NewParquetColumnReader(file, 1)
+	if err != nil {
+		return false, err
+	}
+	defer pr.ReadStop()
+	return isParquetSignatureCache(pr), nil
+}
+
+// readSignatureFile reads the signatures cache file according to its extension: the parquet
+// file, see readSignaturesFromParquet, the Arrow IPC stream or the CSV file.
+func readSignatureFile(prog *progress, path string, parquetColumns map[string]string) (
+	[]Signature, error) {
+	switch {
+	case strings.HasSuffix(path, ".parquet"):
+		return readSignaturesFromParquet(prog, path, parquetColumns)
+	case strings.HasSuffix(path, ".arrow"):
+		return readSignaturesFromArrowFile(path)
+	}
+	return readSignaturesFromDisk(prog, path)
+}
+
+// storeSignatureFile writes the signatures cache file: the parquet signatures cache if path ends
+// with ".parquet", otherwise the CSV file.
+func storeSignatureFile(path string, commits []Signature) error {
+	if strings.HasSuffix(path, ".parquet") {
+		return storeSignaturesToParquet(path, commits)
+	}
+	return storeSignaturesOnDisk(path, commits)
+}
@@ -0,0 +1,98 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParquetSignatureCache(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-columnar")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	signatures := append([]Signature{{Repo: "Repo3 ", Name: "Zoë", Email: "ZOE@google.com",
+		Hash: "zzz", Source: SourceGit, Role: RoleCommitter, SigningKey: "ABC"}}, Signatures...)
+	csvPath := filepath.Join(dir, "cache.csv")
+	req.NoError(storeSignaturesOnDisk(csvPath, signatures))
+	expected, err := readSignaturesFromDisk(nil, csvPath)
+	req.NoError(err)
+	req.Len(expected, len(signatures))
+	req.Equal(time.Time{}, expected[0].Time.UTC())
+
+	path := filepath.Join(dir, "cache.parquet")
+	req.NoError(storeSignatureFile(path, signatures))
+	cached, err := readSignaturesFromParquet(nil, path, map[string]string{"repo": "x"})
+	req.NoError(err)
+	for i := range cached {
+		req.True(expected[i].Time.Equal(cached[i].Time))
+		cached[i].Time = expected[i].Time
+	}
+	req.Equal(expected, cached)
+	writable, err := isWritableSignatureCache(path)
+	req.NoError(err)
+	req.True(writable)
+
+	s, err := NewSuppressions("bob@google.com")
+	req.NoError(err)
+	erased, err := EraseSignatureCache(path, s)
+	req.NoError(err)
+	req.Equal(3, erased)
+	cached, err = findSignatures(nil, "", path, ExtractionOptions{})
+	req.NoError(err)
+	req.Len(cached, len(signatures)-3)
+}
+
+func TestParquetCommitsTableIsNotErased(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-columnar")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "commits.parquet")
+	pw, cleanup := newParquetWriter(path, new(testParquetCommit))
+	req.NoError(pw.Write(testParquetCommit{"repo1", "Bob", "bob@google.com", "aaa", 0}))
+	cleanup()
+	writable, err := isWritableSignatureCache(path)
+	req.NoError(err)
+	req.False(writable)
+	s, err := NewSuppressions("bob@google.com")
+	req.NoError(err)
+	erased, err := EraseSignatureCache(path, s)
+	req.NoError(err)
+	req.Zero(erased)
+
+	writable, err = isWritableSignatureCache(filepath.Join(dir, "commits.arrow"))
+	req.NoError(err)
+	req.False(writable)
+	writable, err = isWritableSignatureCache(filepath.Join(dir, "cache.csv.gz"))
+	req.NoError(err)
+	req.True(writable)
+}
+
+func TestExtractParquetSignatureCache(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-columnar")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "cache.parquet")
+	commits, err := extractSignatures(nil, newTestSignatureSource(), path, false, 0)
+	req.NoError(err)
+	req.Len(commits, len(Signatures)+1)
+	cached, err := findSignatures(nil, "", path, ExtractionOptions{})
+	req.NoError(err)
+	req.Equal(commits, cached)
+
+	req.NoError(os.Remove(path))
+	req.NoError(storeSignatureCache(path, commits, 4))
+	chunks, err := signatureChunks(path)
+	req.NoError(err)
+	req.Equal([]string{filepath.Join(dir, "cache-00000.parquet"),
+		filepath.Join(dir, "cache-00001.parquet")}, chunks)
+	cached, err = findSignatures(nil, "", path, ExtractionOptions{Workers: 2})
+	req.NoError(err)
+	req.Equal(commits, cached)
+}
@@ -0,0 +1,74 @@
+package idmatch
+
+import (
+	"context"
+
+	"github.com/src-d/identity-matching/external"
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// commitBatchSize is the number of the queries which prefetchCommitMatches passes to
+// external.CommitBatchMatcher at once, so that the cache is saved along the way.
+const commitBatchSize = 10000
+
+// prefetchedMatcher answers MatchByCommit with the users resolved by prefetchCommitMatches and
+// forwards the other queries to the wrapped Matcher.
+type prefetchedMatcher struct {
+	external.Matcher
+	users map[external.CommitQuery]string
+}
+
+// MatchByCommit returns the prefetched user or queries the wrapped Matcher.
+func (m prefetchedMatcher) MatchByCommit(
+	ctx context.Context, email, repo, commit string) (string, error) {
+	if user, exists := m.users[external.CommitQuery{Email: email, Repo: repo, Commit: commit}]; exists {
+		if user == "" {
+			return "", external.ErrNoMatches
+		}
+		return user, nil
+	}
+	return m.Matcher.MatchByCommit(ctx, email, repo, commit)
+}
+
+// prefetchCommitMatches resolves the emails of the people in their sample commits at once if
+// the matcher implements external.CommitBatchMatcher, e.g. the GitHub matcher, and returns
+// the matcher which answers MatchByCommit from the results. The people for which skip returns
+// true are not queried. The failed queries fall back to MatchByCommit one by one.
+func prefetchCommitMatches(ctx context.Context, people People, matcher external.Matcher,
+	skip func(*Person) bool) external.Matcher {
+	batchMatcher, ok := matcher.(external.CommitBatchMatcher)
+	if !ok || !matcher.SupportsMatchingByCommit() {
+		return matcher
+	}
+	var queries []external.CommitQuery
+	for _, id := range peopleIDs(people) {
+		person := people[id]
+		if person.SampleCommit == nil || (skip != nil && skip(person)) {
+			continue
+		}
+		for _, email := range person.Emails {
+			queries = append(queries, external.CommitQuery{
+				Email: email, Repo: person.SampleCommit.Repo, Commit: person.SampleCommit.Hash})
+		}
+	}
+	users := map[external.CommitQuery]string{}
+	for start := 0; start < len(queries); start += commitBatchSize {
+		end := start + commitBatchSize
+		if end > len(queries) {
+			end = len(queries)
+		}
+		resolved, err := batchMatcher.MatchByCommits(ctx, queries[start:end])
+		for query, user := range resolved {
+			users[query] = user
+		}
+		if err != nil {
+			reporter.Errorf("failed to resolve %d commits at once, querying them one by one: %v",
+				end-start-len(resolved), err)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	reporter.Commit("prefetched commit matches", len(users))
+	return prefetchedMatcher{matcher, users}
+}
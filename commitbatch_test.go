@@ -0,0 +1,65 @@
+package idmatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/src-d/identity-matching/external"
+)
+
+// testBatchMatcher resolves the commits in MatchByCommits and fails MatchByCommit
+// except for the failed queries.
+type testBatchMatcher struct {
+	TestMatcher
+	batches int
+	single  []string
+}
+
+func (m *testBatchMatcher) SupportsMatchingByCommit() bool {
+	return true
+}
+
+func (m *testBatchMatcher) MatchByCommit(
+	ctx context.Context, email, repo, commit string) (string, error) {
+	m.single = append(m.single, email)
+	return "", external.ErrNoMatches
+}
+
+func (m *testBatchMatcher) MatchByCommits(
+	ctx context.Context, queries []external.CommitQuery) (map[external.CommitQuery]string, error) {
+	m.batches++
+	users := map[external.CommitQuery]string{}
+	for _, query := range queries {
+		switch query.Email {
+		case "bob@google.com":
+			users[query] = "bob"
+		case "eve@google.com":
+			users[query] = ""
+		}
+	}
+	return users, errors.New("failed alice")
+}
+
+func TestPrefetchCommitMatches(t *testing.T) {
+	req := require.New(t)
+	people := People{
+		1: {ID: 1, Emails: []string{"bob@google.com"}, SampleCommit: &Commit{Hash: "a", Repo: "r"}},
+		2: {ID: 2, Emails: []string{"alice@google.com", "eve@google.com"},
+			SampleCommit: &Commit{Hash: "b", Repo: "r"}},
+		3: {ID: 3, Emails: []string{"carol@google.com"}, SampleCommit: &Commit{Hash: "c", Repo: "r"},
+			ExternalIDs: ExternalIDs{"github": "carol"}},
+	}
+	matcher := &testBatchMatcher{}
+	enriched, err := EnrichPeople(context.Background(), people, matcher, "github")
+	req.NoError(err)
+	req.Equal(1, enriched)
+	req.Equal(1, matcher.batches)
+	req.Equal([]string{"alice@google.com"}, matcher.single)
+	req.Equal("bob", people[1].ExternalIDs["github"])
+	req.Nil(people[2].ExternalIDs)
+
+	req.Equal(TestMatcher{}, prefetchCommitMatches(context.Background(), people, TestMatcher{}, nil))
+}
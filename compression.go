@@ -0,0 +1,92 @@
+package idmatch
+
+import (
+	"compress/gzip"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// isCompressed returns whether the file in path is compressed with gzip or zstd, which is
+// detected by the ".gz" or the ".zst" extension.
+func isCompressed(path string) bool {
+	return strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".zst")
+}
+
+// openCompressed opens the local file or the object in path and decompresses it if
+// isCompressed.
+func openCompressed(path string) (io.ReadCloser, error) {
+	file, err := OpenPath(path)
+	if err != nil {
+		return nil, err
+	}
+	var reader io.ReadCloser
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		reader, err = gzip.NewReader(file)
+	case strings.HasSuffix(path, ".zst"):
+		var decoder *zstd.Decoder
+		if decoder, err = zstd.NewReader(file); err == nil {
+			reader = decoder.IOReadCloser()
+		}
+	default:
+		return file, nil
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return compressedReader{reader, file}, nil
+}
+
+// createCompressed creates the local file or the object in path and compresses it if
+// isCompressed.
+func createCompressed(path string) (io.WriteCloser, error) {
+	file, err := CreatePath(path)
+	if err != nil {
+		return nil, err
+	}
+	var writer io.WriteCloser
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		writer = gzip.NewWriter(file)
+	case strings.HasSuffix(path, ".zst"):
+		if writer, err = zstd.NewWriter(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+	default:
+		return file, nil
+	}
+	return compressedWriter{writer, file}, nil
+}
+
+// compressedReader closes both the decompression stream and the underlying file.
+type compressedReader struct {
+	io.ReadCloser
+	file io.Closer
+}
+
+func (r compressedReader) Close() error {
+	return closeBoth(r.ReadCloser, r.file)
+}
+
+// compressedWriter closes both the compression stream, which flushes it, and the underlying file.
+type compressedWriter struct {
+	io.WriteCloser
+	file io.Closer
+}
+
+func (w compressedWriter) Close() error {
+	return closeBoth(w.WriteCloser, w.file)
+}
+
+func closeBoth(stream, file io.Closer) error {
+	err := stream.Close()
+	errClose := file.Close()
+	if err == nil {
+		err = errClose
+	}
+	return err
+}
@@ -0,0 +1,59 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedSignatures(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-compression")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	plainPath := filepath.Join(dir, "cache.csv")
+	req.NoError(storeSignaturesOnDisk(plainPath, Signatures))
+	plain, err := ioutil.ReadFile(plainPath)
+	req.NoError(err)
+	expected, err := readSignaturesFromDisk(nil, plainPath)
+	req.NoError(err)
+	for extension, magic := range map[string]string{
+		".gz": "\x1f\x8b", ".zst": "\x28\xb5\x2f\xfd"} {
+		path := plainPath + extension
+		req.True(isCompressed(path))
+		req.NoError(storeSignaturesOnDisk(path, Signatures))
+		data, err := ioutil.ReadFile(path)
+		req.NoError(err)
+		req.Equal(magic, string(data[:len(magic)]), extension)
+		req.NotEqual(plain, data)
+		signatures, err := readSignaturesFromDisk(nil, path)
+		req.NoError(err)
+		req.Equal(expected, signatures, extension)
+	}
+	req.False(isCompressed(plainPath))
+
+	req.NoError(ioutil.WriteFile(plainPath+".gz", plain, 0666))
+	_, err = readSignaturesFromDisk(nil, plainPath+".gz")
+	req.Error(err)
+}
+
+func TestExtractCompressedSignatures(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-compression")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "cache.csv.zst")
+	commits, err := extractSignatures(nil, newTestSignatureSource(), cachePath, false, 0)
+	req.NoError(err)
+	req.Len(commits, len(Signatures)+1)
+	cached, err := readSignaturesFromDisk(nil, cachePath)
+	req.NoError(err)
+	req.Equal(commits, cached)
+	for _, path := range []string{cachePath + ".partial", cachePath + ".checkpoint"} {
+		_, err = os.Stat(path)
+		req.True(os.IsNotExist(err))
+	}
+}
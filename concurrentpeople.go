@@ -0,0 +1,82 @@
+package idmatch
+
+import "sync"
+
+// ConcurrentPeople share People between the goroutines which serve the identities and the ones
+// which update them in the background. The readers receive immutable snapshots, while
+// the writers change a copy and replace the snapshot, so a long update such as the matching never
+// blocks the reads and the reads never see a half-applied change.
+type ConcurrentPeople struct {
+	lock   sync.RWMutex
+	people People
+	index  *PeopleIndex
+	// updateLock serializes Store and Update without blocking the readers.
+	updateLock sync.Mutex
+}
+
+// NewConcurrentPeople wraps the people, which must not be changed afterwards. nil becomes
+// the empty people.
+func NewConcurrentPeople(people People) *ConcurrentPeople {
+	if people == nil {
+		people = People{}
+	}
+	return &ConcurrentPeople{people: people}
+}
+
+// Snapshot returns the current people. They must not be changed, use Update instead.
+func (c *ConcurrentPeople) Snapshot() People {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.people
+}
+
+// Index returns the query helpers of the current snapshot, see People.Index. The index is shared
+// by all the readers of the same snapshot.
+func (c *ConcurrentPeople) Index() *PeopleIndex {
+	c.lock.RLock()
+	index := c.index
+	c.lock.RUnlock()
+	if index != nil {
+		return index
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.index == nil {
+		c.index = c.people.Index()
+	}
+	return c.index
+}
+
+// Store replaces the snapshot with the people, which must not be changed afterwards.
+func (c *ConcurrentPeople) Store(people People) {
+	if people == nil {
+		people = People{}
+	}
+	c.updateLock.Lock()
+	defer c.updateLock.Unlock()
+	c.replace(people)
+}
+
+// Update calls change with the deep copy of the current people and replaces the snapshot with
+// the changed copy unless change fails. The updates are serialized, while the readers keep
+// reading the previous snapshot until Update returns.
+func (c *ConcurrentPeople) Update(change func(People) error) error {
+	c.updateLock.Lock()
+	defer c.updateLock.Unlock()
+	current := c.Snapshot()
+	people := make(People, len(current))
+	for id, person := range current {
+		people[id] = person.Copy()
+	}
+	if err := change(people); err != nil {
+		return err
+	}
+	c.replace(people)
+	return nil
+}
+
+func (c *ConcurrentPeople) replace(people People) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.people, c.index = people, nil
+}
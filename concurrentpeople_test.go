@@ -0,0 +1,66 @@
+package idmatch
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrentPeopleUpdate(t *testing.T) {
+	req := require.New(t)
+	people := NewConcurrentPeople(newQueryTestPeople())
+	snapshot := people.Snapshot()
+	req.Equal(snapshot[1], people.Index().FindByEmail("bob@google.com"))
+
+	req.NoError(people.Update(func(p People) error {
+		_, err := p.Merge(1, 2)
+		return err
+	}))
+	req.Len(snapshot, 4)
+	req.Equal([]string{"bob@google.com", "bob@gmail.com"}, snapshot[1].Emails)
+	req.Len(people.Snapshot(), 3)
+	req.Equal(int64(1), people.Index().FindByEmail("alex@google.com").ID)
+	req.Equal(int64(2), snapshot.Index().FindByEmail("alex@google.com").ID)
+
+	failure := errors.New("failed")
+	req.Equal(failure, people.Update(func(p People) error {
+		delete(p, 1)
+		return failure
+	}))
+	req.Len(people.Snapshot(), 3)
+
+	people.Store(nil)
+	req.Empty(people.Snapshot())
+	req.Nil(people.Index().FindByEmail("bob@google.com"))
+	req.NotNil(NewConcurrentPeople(nil).Snapshot())
+}
+
+func TestConcurrentPeopleRace(t *testing.T) {
+	people := NewConcurrentPeople(newQueryTestPeople())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for _, person := range people.Snapshot() {
+					_ = len(person.Emails)
+				}
+				people.Index().FindByName("alex", "repo1")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_ = people.Update(func(p People) error {
+					p[1].Emails = append(p[1].Emails, "x@google.com")
+					return nil
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, people.Snapshot()[1].Emails, 2+4*20)
+}
@@ -0,0 +1,540 @@
+package idmatch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/src-d/identity-matching/external"
+)
+
+// Config is the YAML configuration file of the identity matching. The keys are the yaml tags
+// of the fields and the nested sections are mappings, e.g. "extraction:\n  source: git".
+// All the options are optional: the omitted ones keep their defaults, and the command line flags
+// override the file. See LoadConfig.
+type Config struct {
+	// Cache is the path to the signatures cache, the CSV file or the parquet commits table.
+	Cache      string           `yaml:"cache"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Extraction ExtractionConfig `yaml:"extraction"`
+	// Blacklists are the paths to the extra blacklist files, see ReadBlacklist.
+	Blacklists      []string              `yaml:"blacklists"`
+	Popularity      PopularityConfig      `yaml:"popularity"`
+	Bots            BotsConfig            `yaml:"bots"`
+	EmailValidation EmailValidationConfig `yaml:"email_validation"`
+	Primary         PrimaryConfig         `yaml:"primary"`
+	Matching        MatchingConfig        `yaml:"matching"`
+	External        ExternalConfig        `yaml:"external"`
+
+	// values are the textual values of the options present in the file by the dotted keys.
+	values map[string]string
+}
+
+// DatabaseConfig is the gitbase connection, "root@0.0.0.0:3306" by default.
+type DatabaseConfig struct {
+	Host     string `yaml:"host"`
+	Port     uint   `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// ExtractionConfig mirrors ExtractionOptions.
+type ExtractionConfig struct {
+	// Source is one of SourceKinds, "gitbase" by default.
+	Source                 string            `yaml:"source"`
+	Repositories           string            `yaml:"repositories"`
+	Workers                int               `yaml:"workers"`
+	GitHubOrg              string            `yaml:"github_org"`
+	GitHubToken            string            `yaml:"github_token"`
+	GitHubAPIURL           string            `yaml:"github_api_url"`
+	GitHubRateLimitReserve int               `yaml:"github_rate_limit_reserve"`
+	Mailboxes              string            `yaml:"mailboxes"`
+	MailboxTag             string            `yaml:"mailbox_tag"`
+	AccountsURL            string            `yaml:"accounts_url"`
+	AccountsToken          string            `yaml:"accounts_token"`
+	AccountFields          map[string]string `yaml:"account_fields"`
+	ParquetColumns         map[string]string `yaml:"parquet_columns"`
+	// Trailers map TrailerRoles to the weights of their evidence.
+	Trailers        map[string]float64 `yaml:"trailers"`
+	MatchCommitters bool               `yaml:"match_committers"`
+	Resume          bool               `yaml:"resume"`
+	CacheChunkSize  int                `yaml:"cache_chunk_size"`
+	Reproducible    bool               `yaml:"reproducible"`
+	// DedupCommits is true if the key is missing.
+	DedupCommits bool `yaml:"dedup_commits"`
+	// QueryTimeout is written as "30m".
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+	QueryRetries int           `yaml:"query_retries"`
+	// Since and Until are the bounds of TimeWindow, see ParseTimeBound.
+	Since string `yaml:"since"`
+	Until string `yaml:"until"`
+}
+
+// PopularityConfig mirrors PopularityThresholds.
+type PopularityConfig struct {
+	NameMinCount  int     `yaml:"name_min_count"`
+	NameMinShare  float64 `yaml:"name_min_share"`
+	EmailMinCount int     `yaml:"email_min_count"`
+	EmailMinShare float64 `yaml:"email_min_share"`
+
+	HalfLife time.Duration   `yaml:"half_life"`
+	Windows  []time.Duration `yaml:"windows"`
+
+	// SharedMailboxMinNames is SharedMailboxDetector.MinNames, 5 by default. 0 disables
+	// the detection.
+	SharedMailboxMinNames int `yaml:"shared_mailbox_min_names"`
+}
+
+// BotsConfig configures BotDetectionOptions.
+type BotsConfig struct {
+	// Mode is "mark" (the default), which sets Person.IsBot, "exclude", which drops the bots,
+	// or "off".
+	Mode string `yaml:"mode"`
+	// MinCommits is 1000 by default.
+	MinCommits int `yaml:"min_commits"`
+}
+
+// EmailValidationConfig configures the EmailValidator.
+type EmailValidationConfig struct {
+	// Mode is "off" (the default), "report" or "exclude".
+	Mode string `yaml:"mode"`
+	// Repairs is the path to the extra domain typo repairs.
+	Repairs string `yaml:"repairs"`
+	// MX enables the lookups of the MX records.
+	MX bool `yaml:"mx"`
+	// Issues is the path to the CSV file to write the repaired and invalid emails to.
+	Issues string `yaml:"issues"`
+}
+
+// PrimaryConfig configures PrimaryOptions and the recent stats of FindPeople.
+type PrimaryConfig struct {
+	// Strategy is one of PrimaryStrategies, "frequent" by default.
+	Strategy string `yaml:"strategy"`
+	// RecentMonths is 12 by default.
+	RecentMonths int `yaml:"recent_months"`
+	// MinRecentCount is 5 by default.
+	MinRecentCount int `yaml:"min_recent_count"`
+}
+
+// MatchingConfig mirrors ReduceOptions. The paths refer to the files which ReadNameCleaner,
+// ReadEmailAliasRules, ReadDomainPolicies and LoadPairScorer read.
+type MatchingConfig struct {
+	MaxIdentities           int     `yaml:"max_identities"`
+	ReorderedNames          bool    `yaml:"reordered_names"`
+	MaxNameTokenFrequency   int     `yaml:"max_name_token_frequency"`
+	DomainChanges           bool    `yaml:"domain_changes"`
+	MinEdgeWeight           float64 `yaml:"min_edge_weight"`
+	MinRepositorySimilarity float64 `yaml:"min_repository_similarity"`
+	RepositoryWeight        float64 `yaml:"repository_weight"`
+	PairModel               string  `yaml:"pair_model"`
+	MinPairProbability      float64 `yaml:"min_pair_probability"`
+	NameCleaning            string  `yaml:"name_cleaning"`
+	EmailAliases            string  `yaml:"email_aliases"`
+	DomainPolicies          string  `yaml:"domain_policies"`
+
+	// Blockers are the names of Blockers.
+	Blockers     []string `yaml:"blockers"`
+	MaxBlockSize int      `yaml:"max_block_size"`
+
+	MaxClusterEmails int `yaml:"max_cluster_emails"`
+	MaxClusterNames  int `yaml:"max_cluster_names"`
+
+	SampleCommits int    `yaml:"sample_commits"`
+	RepoWeights   string `yaml:"repo_weights"`
+
+	// NoReplyUsers are the specs of ParseNoReplyUserPatterns.
+	NoReplyUsers []string `yaml:"noreply_users"`
+}
+
+// ExternalConfig is the external identity provider.
+type ExternalConfig struct {
+	// Provider is one of external.Matchers.
+	Provider string `yaml:"provider"`
+	APIURL   string `yaml:"api_url"`
+	Token    string `yaml:"token"`
+	// Cache is the path to the external identities cache.
+	Cache string `yaml:"cache"`
+	// CommitSearch and CommitSearchShare configure external.CommitSearchOptions.
+	CommitSearch      bool    `yaml:"commit_search"`
+	CommitSearchShare float64 `yaml:"commit_search_share"`
+}
+
+// configKeys returns the dotted keys of all the options and whether each is a section.
+func configKeys() map[string]bool {
+	keys := map[string]bool{}
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := field.Tag.Get("yaml")
+			if name == "" {
+				continue
+			}
+			key := prefix + name
+			isSection := field.Type.Kind() == reflect.Struct
+			keys[key] = isSection
+			if isSection {
+				walk(field.Type, key+".")
+			}
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	return keys
+}
+
+// LoadConfig reads and validates the YAML configuration file. The unknown options and
+// the invalid values are reported all at once.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw yaml.MapSlice
+	if err = yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse the config %s: %v", path, err)
+	}
+	config := &Config{values: map[string]string{}}
+	var problems []string
+	keys := configKeys()
+	var walk func(items yaml.MapSlice, prefix string)
+	walk = func(items yaml.MapSlice, prefix string) {
+		for _, item := range items {
+			key := prefix + fmt.Sprint(item.Key)
+			isSection, known := keys[key]
+			if !known {
+				problems = append(problems, unknownConfigKey(key, keys))
+				continue
+			}
+			if !isSection {
+				config.values[key] = formatConfigValue(item.Value)
+				continue
+			}
+			section, isMapping := item.Value.(yaml.MapSlice)
+			if !isMapping && item.Value != nil {
+				problems = append(problems, fmt.Sprintf("%s: must be a mapping of the options", key))
+				continue
+			}
+			walk(section, key+".")
+		}
+	}
+	walk(raw, "")
+	if len(problems) == 0 {
+		if err = yaml.UnmarshalStrict(data, config); err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			problems = config.validate()
+		}
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid config %s:\n  %s", path, strings.Join(problems, "\n  "))
+	}
+	return config, nil
+}
+
+// unknownConfigKey describes the unknown option and suggests the closest known one.
+func unknownConfigKey(key string, keys map[string]bool) string {
+	best, bestDistance := "", 0.34
+	for known := range keys {
+		if distance := normalizedEditDistance(key, known); distance < bestDistance ||
+			distance == bestDistance && known < best {
+			best, bestDistance = known, distance
+		}
+	}
+	if best == "" {
+		return fmt.Sprintf("%s: unknown option", key)
+	}
+	return fmt.Sprintf("%s: unknown option, did you mean %s?", key, best)
+}
+
+// formatConfigValue converts the YAML value to the text of the corresponding command line flag:
+// the lists are joined with "," and the mappings become "key=value" items joined with ",".
+func formatConfigValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = formatConfigValue(item)
+		}
+		return strings.Join(items, ",")
+	case yaml.MapSlice:
+		items := make([]string, len(value))
+		for i, item := range value {
+			items[i] = fmt.Sprint(item.Key) + "=" + formatConfigValue(item.Value)
+		}
+		sort.Strings(items)
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// Lookup returns the textual value of the option with the dotted key, e.g. "extraction.source",
+// and whether it is present in the file. The lists and the mappings are formatted the same way
+// as the command line flags accept them.
+func (c *Config) Lookup(key string) (string, bool) {
+	value, exists := c.values[key]
+	return value, exists
+}
+
+// validate returns the descriptions of the invalid values.
+func (c *Config) validate() []string {
+	var problems []string
+	oneOf := func(key, value string, options []string) {
+		if value == "" {
+			return
+		}
+		for _, option := range options {
+			if value == option {
+				return
+			}
+		}
+		problems = append(problems, fmt.Sprintf("%s: unsupported value %q, the options are %s",
+			key, value, strings.Join(options, ", ")))
+	}
+	within := func(key string, value, min, max float64) {
+		if value < min || value > max {
+			problems = append(problems, fmt.Sprintf("%s: %v must be between %v and %v",
+				key, value, min, max))
+		}
+	}
+	nonNegative := func(key string, value int) {
+		if value < 0 {
+			problems = append(problems, fmt.Sprintf("%s: %d must not be negative", key, value))
+		}
+	}
+
+	var sources []string
+	for _, source := range SourceKinds {
+		sources = append(sources, string(source))
+	}
+	e := c.Extraction
+	oneOf("extraction.source", e.Source, sources)
+	required := map[SourceKind][2]string{
+		SourceGit:    {"repositories", e.Repositories},
+		SourceMbox:   {"mailboxes", e.Mailboxes},
+		SourceGitHub: {"github_org", e.GitHubOrg},
+		SourceJIRA:   {"accounts_url", e.AccountsURL},
+		SourceREST:   {"accounts_url", e.AccountsURL},
+	}
+	if option, exists := required[SourceKind(e.Source)]; exists && option[1] == "" {
+		problems = append(problems, fmt.Sprintf("extraction.%s: required by the %s source",
+			option[0], e.Source))
+	}
+	var roles []string
+	for _, role := range TrailerRoles {
+		roles = append(roles, string(role))
+	}
+	for _, trailer := range sortedKeys(configMapKeys(e.Trailers)) {
+		oneOf("extraction.trailers", strings.ToLower(trailer), roles)
+	}
+	nonNegative("extraction.workers", e.Workers)
+	nonNegative("extraction.cache_chunk_size", e.CacheChunkSize)
+	nonNegative("extraction.github_rate_limit_reserve", e.GitHubRateLimitReserve)
+	nonNegative("extraction.query_retries", e.QueryRetries)
+	if e.QueryTimeout < 0 {
+		problems = append(problems, fmt.Sprintf("extraction.query_timeout: %s must not be negative",
+			e.QueryTimeout))
+	}
+	if _, err := NewTimeWindow(e.Since, e.Until); err != nil {
+		problems = append(problems, fmt.Sprintf("extraction.since, extraction.until: %v", err))
+	}
+
+	nonNegative("popularity.name_min_count", c.Popularity.NameMinCount)
+	within("popularity.name_min_share", c.Popularity.NameMinShare, 0, 1)
+	nonNegative("popularity.email_min_count", c.Popularity.EmailMinCount)
+	within("popularity.email_min_share", c.Popularity.EmailMinShare, 0, 1)
+	if c.Popularity.HalfLife < 0 {
+		problems = append(problems, fmt.Sprintf("popularity.half_life: %s must not be negative",
+			c.Popularity.HalfLife))
+	}
+	for _, window := range c.Popularity.Windows {
+		if window <= 0 {
+			problems = append(problems, fmt.Sprintf("popularity.windows: %s must be positive", window))
+		}
+	}
+	nonNegative("popularity.shared_mailbox_min_names", c.Popularity.SharedMailboxMinNames)
+	oneOf("bots.mode", c.Bots.Mode, []string{"mark", "exclude", "off"})
+	nonNegative("bots.min_commits", c.Bots.MinCommits)
+	oneOf("email_validation.mode", c.EmailValidation.Mode, []string{"off", "report", "exclude"})
+	var strategies []string
+	for _, strategy := range PrimaryStrategies {
+		strategies = append(strategies, string(strategy))
+	}
+	oneOf("primary.strategy", c.Primary.Strategy, strategies)
+	nonNegative("primary.recent_months", c.Primary.RecentMonths)
+	nonNegative("primary.min_recent_count", c.Primary.MinRecentCount)
+
+	m := c.Matching
+	nonNegative("matching.max_identities", m.MaxIdentities)
+	nonNegative("matching.max_name_token_frequency", m.MaxNameTokenFrequency)
+	if m.MinEdgeWeight < 0 {
+		problems = append(problems, fmt.Sprintf("matching.min_edge_weight: %v must not be negative",
+			m.MinEdgeWeight))
+	}
+	within("matching.min_repository_similarity", m.MinRepositorySimilarity, 0, 1)
+	within("matching.min_pair_probability", m.MinPairProbability, 0, 1)
+	var blockers []string
+	for name := range Blockers {
+		blockers = append(blockers, name)
+	}
+	sort.Strings(blockers)
+	for _, blocker := range m.Blockers {
+		oneOf("matching.blockers", blocker, blockers)
+	}
+	nonNegative("matching.max_block_size", m.MaxBlockSize)
+	nonNegative("matching.max_cluster_emails", m.MaxClusterEmails)
+	nonNegative("matching.max_cluster_names", m.MaxClusterNames)
+	nonNegative("matching.sample_commits", m.SampleCommits)
+	if _, err := ParseNoReplyUserPatterns(m.NoReplyUsers); err != nil {
+		problems = append(problems, fmt.Sprintf("matching.noreply_users: %v", err))
+	}
+
+	var providers []string
+	for provider := range external.Matchers {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	oneOf("external.provider", c.External.Provider, providers)
+	within("external.commit_search_share", c.External.CommitSearchShare, 0, 1)
+	if c.External.CommitSearch && c.External.Provider != "" && c.External.Provider != "github" {
+		problems = append(problems, "external.commit_search: requires the github provider")
+	}
+	return problems
+}
+
+func configMapKeys(m map[string]float64) map[string]struct{} {
+	keys := make(map[string]struct{}, len(m))
+	for key := range m {
+		keys[key] = struct{}{}
+	}
+	return keys
+}
+
+// ConnectionString returns the gitbase connection string for FindPeople.
+func (c *Config) ConnectionString() string {
+	return GitbaseConnString(c.Database.Host, c.Database.Port, c.Database.User, c.Database.Password)
+}
+
+// ExtractionOptions returns NewExtractionOptions with the options of the file applied.
+func (c *Config) ExtractionOptions() ExtractionOptions {
+	opts := NewExtractionOptions()
+	e := c.Extraction
+	if e.Source != "" {
+		opts.Source = SourceKind(e.Source)
+	}
+	opts.Repositories = e.Repositories
+	opts.Workers = e.Workers
+	opts.GitHubOrg = e.GitHubOrg
+	opts.GitHubToken = e.GitHubToken
+	opts.GitHubAPIURL = e.GitHubAPIURL
+	if _, exists := c.Lookup("extraction.github_rate_limit_reserve"); exists {
+		opts.GitHubRateLimitReserve = e.GitHubRateLimitReserve
+	}
+	opts.Mailboxes = e.Mailboxes
+	opts.MailboxTag = e.MailboxTag
+	opts.AccountsURL = e.AccountsURL
+	opts.AccountsToken = e.AccountsToken
+	opts.AccountFields = e.AccountFields
+	opts.ParquetColumns = e.ParquetColumns
+	for _, trailer := range sortedKeys(configMapKeys(e.Trailers)) {
+		opts.Trailers = append(opts.Trailers, SignatureRole(strings.ToLower(trailer)))
+	}
+	opts.MatchCommitters = e.MatchCommitters
+	opts.Resume = e.Resume
+	opts.CacheChunkSize = e.CacheChunkSize
+	opts.Reproducible = e.Reproducible
+	if _, exists := c.Lookup("extraction.dedup_commits"); exists {
+		opts.DedupCommits = e.DedupCommits
+	}
+	if _, exists := c.Lookup("extraction.query_timeout"); exists {
+		opts.QueryTimeout = e.QueryTimeout
+	}
+	if _, exists := c.Lookup("extraction.query_retries"); exists {
+		opts.MaxRetries = e.QueryRetries
+	}
+	// Validate reports the invalid window
+	opts.Window, _ = NewTimeWindow(e.Since, e.Until)
+	opts.SharedMailboxes = NewSharedMailboxDetector()
+	if _, exists := c.Lookup("popularity.shared_mailbox_min_names"); exists {
+		opts.SharedMailboxes.MinNames = c.Popularity.SharedMailboxMinNames
+		if opts.SharedMailboxes.MinNames == 0 {
+			opts.SharedMailboxes = nil
+		}
+	}
+	return opts
+}
+
+// PopularityThresholds returns the popularity thresholds of the file.
+func (c *Config) PopularityThresholds() PopularityThresholds {
+	return PopularityThresholds{
+		MinNameCount:  c.Popularity.NameMinCount,
+		MinNameShare:  c.Popularity.NameMinShare,
+		MinEmailCount: c.Popularity.EmailMinCount,
+		MinEmailShare: c.Popularity.EmailMinShare,
+		Frequencies: FrequencyOptions{
+			Windows:  c.Popularity.Windows,
+			HalfLife: c.Popularity.HalfLife,
+		},
+	}
+}
+
+// BotDetectionOptions returns the bot detection options of the file.
+func (c *Config) BotDetectionOptions() BotDetectionOptions {
+	if c.Bots.Mode == "off" {
+		return BotDetectionOptions{}
+	}
+	opts := NewBotDetectionOptions()
+	opts.Exclude = c.Bots.Mode == "exclude"
+	if _, exists := c.Lookup("bots.min_commits"); exists {
+		opts.MinCommits = c.Bots.MinCommits
+	}
+	return opts
+}
+
+// FindPeopleOptions returns the options of FindPeopleWithOptions of the file.
+func (c *Config) FindPeopleOptions(progress ProgressReporter) (FindPeopleOptions, error) {
+	blacklist, err := LoadBlacklist(c.Blacklists...)
+	if err != nil {
+		return FindPeopleOptions{}, err
+	}
+	recentMonths := c.Primary.RecentMonths
+	if recentMonths == 0 {
+		recentMonths = 12
+	}
+	return FindPeopleOptions{
+		ConnString:   c.ConnectionString(),
+		CachePath:    c.Cache,
+		Extraction:   c.ExtractionOptions(),
+		Blacklist:    blacklist,
+		Popularity:   c.PopularityThresholds(),
+		Bots:         c.BotDetectionOptions(),
+		RecentMonths: recentMonths,
+		Progress:     progress,
+	}, nil
+}
+
+// FindPeople calls FindPeopleWithOptions with all the options of the file. The blacklist is
+// returned together with the people because ReducePeople should receive it.
+func (c *Config) FindPeople(ctx context.Context, progress ProgressReporter) (
+	People, map[string]*Frequency, map[string]*Frequency, Blacklist, error) {
+	opts, err := c.FindPeopleOptions(progress)
+	if err != nil {
+		return nil, nil, nil, Blacklist{}, err
+	}
+	people, nameFreqs, emailFreqs, err := FindPeopleWithOptions(ctx, opts)
+	if err != nil {
+		return nil, nil, nil, Blacklist{}, err
+	}
+	blacklist := opts.Blacklist.WithPopular(nameFreqs, emailFreqs, opts.Popularity)
+	if opts.Extraction.SharedMailboxes != nil {
+		blacklist = blacklist.WithSharedMailboxes(opts.Extraction.SharedMailboxes.Mailboxes())
+	}
+	return people, nameFreqs, emailFreqs, blacklist, nil
+}
@@ -0,0 +1,132 @@
+package idmatch
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestConfig(t *testing.T, text string) (string, func()) {
+	t.Helper()
+	f, cleanup := tempFile(t, "*.yaml")
+	_, err := f.WriteString(text)
+	require.NoError(t, err)
+	return f.Name(), cleanup
+}
+
+func TestLoadConfig(t *testing.T) {
+	req := require.New(t)
+	path, cleanup := writeTestConfig(t, `cache: signatures.csv
+database:
+  host: gitbase
+  port: 3307
+extraction:
+  source: git
+  repositories: /repos
+  trailers:
+    signed-off-by: 0.5
+    reviewed-by: 0.25
+  query_timeout: 10m
+  dedup_commits: false
+  since: 2019-04-01
+  until: 2020
+blacklists: [bots.yaml, staff.csv]
+bots:
+  mode: exclude
+  min_commits: 500
+popularity:
+  half_life: 8760h
+  windows: [168h, 720h]
+matching:
+  reordered_names: false
+`)
+	defer cleanup()
+	config, err := LoadConfig(path)
+	req.NoError(err)
+
+	value, exists := config.Lookup("extraction.trailers")
+	req.True(exists)
+	req.Equal("reviewed-by=0.25,signed-off-by=0.5", value)
+	value, exists = config.Lookup("blacklists")
+	req.True(exists)
+	req.Equal("bots.yaml,staff.csv", value)
+	value, exists = config.Lookup("matching.reordered_names")
+	req.True(exists)
+	req.Equal("false", value)
+	value, exists = config.Lookup("popularity.windows")
+	req.True(exists)
+	req.Equal("168h,720h", value)
+	_, exists = config.Lookup("matching.max_identities")
+	req.False(exists)
+
+	req.Equal("root:@tcp(gitbase:3307)/gitbase", config.ConnectionString())
+	opts := config.ExtractionOptions()
+	req.Equal(SourceGit, opts.Source)
+	req.Equal("/repos", opts.Repositories)
+	req.Equal([]SignatureRole{RoleReviewedBy, RoleSignedOffBy}, opts.Trailers)
+	req.Equal(10*time.Minute, opts.QueryTimeout)
+	req.Equal(NewExtractionOptions().MaxRetries, opts.MaxRetries)
+	req.False(opts.DedupCommits)
+	req.True(NewExtractionOptions().DedupCommits)
+	req.Equal(TimeWindow{Since: time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC),
+		Until: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}, opts.Window)
+	bots := config.BotDetectionOptions()
+	req.True(bots.Exclude)
+	req.Equal(500, bots.MinCommits)
+	req.Equal(FrequencyOptions{Windows: []time.Duration{168 * time.Hour, 720 * time.Hour},
+		HalfLife: 8760 * time.Hour}, config.PopularityThresholds().Frequencies)
+}
+
+func TestLoadConfigUnknownOption(t *testing.T) {
+	req := require.New(t)
+	path, cleanup := writeTestConfig(t, `extraction:
+  sourse: git
+foo: bar
+`)
+	defer cleanup()
+	_, err := LoadConfig(path)
+	req.Error(err)
+	req.Contains(err.Error(), "extraction.sourse: unknown option, did you mean extraction.source?")
+	req.Contains(err.Error(), "\n  foo: unknown option")
+}
+
+func TestLoadConfigInvalidValues(t *testing.T) {
+	req := require.New(t)
+	path, cleanup := writeTestConfig(t, `extraction:
+  source: mbox
+  since: 2020
+  until: 2019
+bots:
+  mode: drop
+popularity:
+  name_min_share: 2
+  half_life: -24h
+matching:
+  noreply_users: [gitea]
+external:
+  provider: sourceforge
+  commit_search: true
+  commit_search_share: 1.5
+`)
+	defer cleanup()
+	_, err := LoadConfig(path)
+	req.Error(err)
+	for _, problem := range []string{
+		"extraction.mailboxes: required by the mbox source",
+		"extraction.since, extraction.until: the time window is empty: 2020 is not before 2019",
+		`bots.mode: unsupported value "drop"`,
+		"popularity.name_min_share: 2 must be between 0 and 1",
+		"popularity.half_life: -24h0m0s must not be negative",
+		"matching.noreply_users: unknown noreply provider gitea",
+		`external.provider: unsupported value "sourceforge"`,
+		"external.commit_search_share: 1.5 must be between 0 and 1",
+		"external.commit_search: requires the github provider",
+	} {
+		req.Contains(err.Error(), problem)
+	}
+
+	_, err = LoadConfig(path + ".missing")
+	req.True(os.IsNotExist(err))
+}
@@ -0,0 +1,246 @@
+package idmatch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// EvidenceConstraint means that both identities are in a must-link constraint, see Constraints.
+const EvidenceConstraint EvidenceKind = "constraint"
+
+// ConstraintKind tells whether the identities in the constraint must or must not be merged.
+type ConstraintKind string
+
+const (
+	// ConstraintMustLink merges all the identities with either key.
+	ConstraintMustLink ConstraintKind = "must_link"
+	// ConstraintCannotLink keeps the identities with one key apart from the identities with
+	// the other key, no matter the evidence between them.
+	ConstraintCannotLink ConstraintKind = "cannot_link"
+)
+
+// Constraint is a pair of the keys which must or must not belong to the same person. The keys
+// are "email:<email>" or "name:<name>" like the blocking keys of the shards.
+type Constraint struct {
+	Kind   ConstraintKind
+	First  string
+	Second string
+}
+
+// String formats the constraint as "kind(first, second)".
+func (c Constraint) String() string {
+	return fmt.Sprintf("%s(%s, %s)", c.Kind, c.First, c.Second)
+}
+
+// Constraints are the hard constraints which the matching honors, see ReduceOptions.Constraints.
+type Constraints []Constraint
+
+// ConstraintConflict is the constraint which the matching could not honor.
+type ConstraintConflict struct {
+	Constraint Constraint
+	Reason     string
+}
+
+// String formats the conflict for the logs.
+func (c ConstraintConflict) String() string {
+	return c.Constraint.String() + ": " + c.Reason
+}
+
+// normalizeConstraintKey cleans the value of the key the same way as the signatures are cleaned.
+func normalizeConstraintKey(key string) (string, error) {
+	parts := strings.SplitN(strings.TrimSpace(key), ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid constraint key %q: must be email:<email> or name:<name>", key)
+	}
+	var value string
+	var err error
+	switch parts[0] {
+	case "email":
+		value, err = cleanEmail(parts[1])
+	case "name":
+		value, err = cleanName(parts[1])
+	default:
+		return "", fmt.Errorf("invalid constraint key %q: must be email:<email> or name:<name>", key)
+	}
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", fmt.Errorf("invalid constraint key %q: empty value", key)
+	}
+	return parts[0] + ":" + value, nil
+}
+
+// ReadConstraints loads the constraints from a CSV file with the columns "constraint", "first" and
+// "second". The constraint is either "must_link" or "cannot_link", and the keys are
+// "email:<email>" or "name:<name>".
+func ReadConstraints(path string) (constraints Constraints, err error) {
+	err = readCSVRecords(path, "constraints", []string{"constraint", "first", "second"},
+		func(header map[string]int, record []string) error {
+			var err error
+			constraint := Constraint{
+				Kind: ConstraintKind(strings.ToLower(strings.TrimSpace(record[header["constraint"]]))),
+			}
+			if constraint.Kind != ConstraintMustLink && constraint.Kind != ConstraintCannotLink {
+				return fmt.Errorf("unknown constraint: %s", constraint.Kind)
+			}
+			if constraint.First, err = normalizeConstraintKey(record[header["first"]]); err != nil {
+				return err
+			}
+			if constraint.Second, err = normalizeConstraintKey(record[header["second"]]); err != nil {
+				return err
+			}
+			constraints = append(constraints, constraint)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return constraints, nil
+}
+
+// constraintNodes maps the constraint keys to the sorted IDs of the nodes which have them.
+func (g *IdentityGraph) constraintNodes(constraints Constraints) map[string][]int64 {
+	keys := map[string]struct{}{}
+	for _, constraint := range constraints {
+		keys[constraint.First] = struct{}{}
+		keys[constraint.Second] = struct{}{}
+	}
+	result := map[string][]int64{}
+	for id, node := range g.nodes {
+		for _, email := range node.Emails {
+			if _, exists := keys["email:"+email]; exists {
+				result["email:"+email] = append(result["email:"+email], id)
+			}
+		}
+		for _, name := range node.NamesWithRepos {
+			if _, exists := keys["name:"+name.Name]; exists {
+				result["name:"+name.Name] = append(result["name:"+name.Name], id)
+			}
+		}
+	}
+	for _, ids := range result {
+		Int64Slice(ids).Sort()
+	}
+	return result
+}
+
+// applyConstraints merges the identities in the must-link constraints and deactivates the edges
+// which would put the identities in a cannot-link constraint into the same person.
+// The must-link edges are joined first, then the other active edges from the heaviest, so that
+// the strongest evidence wins. The constraints which cannot be honored are returned.
+func (g *IdentityGraph) applyConstraints(constraints Constraints) []ConstraintConflict {
+	if len(constraints) == 0 {
+		return nil
+	}
+	nodes := g.constraintNodes(constraints)
+	var conflicts []ConstraintConflict
+	mustLinks := map[edgeKey]Constraint{}
+	// sides map the nodes to the cannot-link constraints they are in: 1 is the first key, 2 is
+	// the second key
+	sides := map[int64]map[int]int{}
+	for index, constraint := range constraints {
+		if constraint.Kind == ConstraintMustLink {
+			ids := append(append([]int64{}, nodes[constraint.First]...), nodes[constraint.Second]...)
+			Int64Slice(ids).Sort()
+			for i, id := range ids {
+				if i == 0 || id == ids[i-1] {
+					continue
+				}
+				value := constraint.First + " = " + constraint.Second
+				if err := g.force(ids[0], id, EvidenceConstraint, value); err != nil {
+					conflicts = append(conflicts, ConstraintConflict{constraint, err.Error()})
+					continue
+				}
+				mustLinks[newEdgeKey(ids[0], id)] = constraint
+			}
+			continue
+		}
+		for side, key := range []string{constraint.First, constraint.Second} {
+			for _, id := range nodes[key] {
+				if sides[id] == nil {
+					sides[id] = map[int]int{}
+				}
+				sides[id][index] |= side + 1
+			}
+		}
+	}
+	for index, constraint := range constraints {
+		for _, id := range nodes[constraint.First] {
+			if constraint.Kind == ConstraintCannotLink && sides[id][index] == 3 {
+				conflicts = append(conflicts, ConstraintConflict{constraint,
+					fmt.Sprintf("identity %d has both keys", id)})
+				for _, node := range sides {
+					delete(node, index)
+				}
+				break
+			}
+		}
+	}
+
+	edges := g.Edges()
+	sort.SliceStable(edges, func(i, j int) bool {
+		_, must1 := mustLinks[newEdgeKey(edges[i].From, edges[i].To)]
+		_, must2 := mustLinks[newEdgeKey(edges[j].From, edges[j].To)]
+		if must1 != must2 {
+			return must1
+		}
+		return edges[i].Weight > edges[j].Weight
+	})
+	sets := disjointSets{}
+	rootSides := map[int64]map[int]int{}
+	for id, node := range sides {
+		rootSides[id] = node
+	}
+	for _, edge := range edges {
+		if !edge.Active {
+			continue
+		}
+		root1, root2 := sets.find(edge.From), sets.find(edge.To)
+		if root1 == root2 {
+			continue
+		}
+		violated := -1
+		for index, side1 := range rootSides[root1] {
+			if side2 := rootSides[root2][index]; side1|side2 == 3 {
+				violated = index
+				break
+			}
+		}
+		if violated >= 0 {
+			g.edges[newEdgeKey(edge.From, edge.To)].Active = false
+			if constraint, exists := mustLinks[newEdgeKey(edge.From, edge.To)]; exists {
+				conflicts = append(conflicts, ConstraintConflict{constraint,
+					"contradicts " + constraints[violated].String()})
+			} else {
+				reporter.Increment("cannot-link vetoes")
+			}
+			continue
+		}
+		root := sets.union(root1, root2)
+		merged := map[int]int{}
+		for _, r := range []int64{root1, root2} {
+			for index, side := range rootSides[r] {
+				merged[index] |= side
+			}
+			delete(rootSides, r)
+		}
+		if len(merged) > 0 {
+			rootSides[root] = merged
+		}
+	}
+	for _, conflict := range conflicts {
+		reporter.Warnf("constraint conflict: %s", conflict)
+	}
+	reporter.Commit("constraint conflicts", len(conflicts))
+	return conflicts
+}
+
+// ConstraintConflicts returns the constraints which the graph could not honor, see
+// ReduceOptions.Constraints.
+func (g *IdentityGraph) ConstraintConflicts() []ConstraintConflict {
+	return g.conflicts
+}
@@ -0,0 +1,84 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConstraints(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(ioutil.WriteFile(f.Name(), []byte(`constraint,first,second
+must_link,email:Bob@Google.com,name:Bob  Smith
+Cannot_Link,name:alice,email:al@google.com
+`), 0666))
+	constraints, err := ReadConstraints(f.Name())
+	req.NoError(err)
+	req.Equal(Constraints{
+		{ConstraintMustLink, "email:bob@google.com", "name:bob smith"},
+		{ConstraintCannotLink, "name:alice", "email:al@google.com"},
+	}, constraints)
+	req.Equal("cannot_link(name:alice, email:al@google.com)", constraints[1].String())
+
+	for _, content := range []string{
+		"constraint,first\nmust_link,email:a@b.com\n",
+		"constraint,first,second\nmaybe_link,email:a@b.com,email:b@b.com\n",
+		"constraint,first,second\nmust_link,a@b.com,email:b@b.com\n",
+		"constraint,first,second\nmust_link,login:a,email:b@b.com\n",
+		"constraint,first,second\nmust_link,name: ,email:b@b.com\n",
+	} {
+		req.NoError(ioutil.WriteFile(f.Name(), []byte(content), 0666))
+		_, err = ReadConstraints(f.Name())
+		req.Error(err, content)
+	}
+}
+
+func TestBuildIdentityGraphConstraints(t *testing.T) {
+	req := require.New(t)
+	people := newGraphTestPeople()
+	g, err := BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t), ReduceOptions{
+		MaxIdentities: 100,
+		Constraints: Constraints{
+			{ConstraintCannotLink, "email:alice@google.com", "email:al@google.com"},
+			{ConstraintMustLink, "name:eve", "email:bob@google.com"},
+		},
+	})
+	req.NoError(err)
+	req.Empty(g.ConstraintConflicts())
+	edge, _ := g.Edge(3, 4)
+	req.False(edge.Active)
+	edge, _ = g.Edge(1, 5)
+	req.True(edge.Active)
+	req.Equal([]Evidence{{EvidenceConstraint, "name:eve = email:bob@google.com", 1}}, edge.Evidence)
+	req.Equal([][]int64{{1, 2, 5}, {3}, {4}}, g.Components())
+	req.NoError(g.Reduce(context.Background(), people))
+	req.Len(people, 3)
+}
+
+func TestBuildIdentityGraphConstraintConflicts(t *testing.T) {
+	req := require.New(t)
+	g, err := BuildIdentityGraph(context.Background(), newGraphTestPeople(), nil, newTestBlacklist(t),
+		ReduceOptions{
+			MaxIdentities: 100,
+			Constraints: Constraints{
+				{ConstraintCannotLink, "name:bob", "email:eve@google.com"},
+				{ConstraintMustLink, "name:bob", "name:eve"},
+				{ConstraintCannotLink, "name:alice", "email:al@google.com"},
+			},
+		})
+	req.NoError(err)
+	conflicts := g.ConstraintConflicts()
+	req.Len(conflicts, 2)
+	req.Equal(ConstraintConflict{
+		Constraint{ConstraintCannotLink, "name:alice", "email:al@google.com"},
+		"identity 4 has both keys",
+	}, conflicts[0])
+	req.Equal(ConstraintMustLink, conflicts[1].Constraint.Kind)
+	req.Equal("contradicts cannot_link(name:bob, email:eve@google.com)", conflicts[1].Reason)
+	// the contradicting must-link is dropped while the evidence between alices stays
+	req.Equal([][]int64{{1, 2}, {3, 4}, {5}}, g.Components())
+}
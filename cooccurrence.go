@@ -0,0 +1,102 @@
+package idmatch
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// repositoryVectors returns the repository vectors of the identities: each email is represented
+// by the repositories it contributes to, weighted by the inverse document frequency, so that
+// the small private repositories outweigh the popular ones which everybody contributes to.
+// The vector of a person is the union of the vectors of its emails.
+func repositoryVectors(people People) map[int64]map[string]float64 {
+	emailRepos := map[string]map[string]struct{}{}
+	for _, person := range people {
+		for _, email := range person.Emails {
+			repos := emailRepos[email]
+			if repos == nil {
+				repos = map[string]struct{}{}
+				emailRepos[email] = repos
+			}
+			for _, repo := range person.Repositories {
+				repos[repo] = struct{}{}
+			}
+		}
+	}
+	frequencies := map[string]int{}
+	for _, repos := range emailRepos {
+		for repo := range repos {
+			frequencies[repo]++
+		}
+	}
+	result := make(map[int64]map[string]float64, len(people))
+	for id, person := range people {
+		vector := map[string]float64{}
+		for _, email := range person.Emails {
+			for repo := range emailRepos[email] {
+				if idf := math.Log(float64(len(emailRepos)) / float64(frequencies[repo])); idf > 0 {
+					vector[repo] = idf
+				}
+			}
+		}
+		result[id] = vector
+	}
+	return result
+}
+
+// cosineSimilarity returns the cosine of the angle between two sparse vectors. It is 0 if any of
+// the vectors is empty. The sums follow the sorted keys so that the result does not depend on
+// the map iteration order to the last bit.
+func cosineSimilarity(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for _, key := range sortedVectorKeys(a) {
+		value := a[key]
+		dot += value * b[key]
+		normA += value * value
+	}
+	for _, key := range sortedVectorKeys(b) {
+		normB += b[key] * b[key]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
+
+func sortedVectorKeys(vector map[string]float64) []string {
+	keys := make([]string, 0, len(vector))
+	for key := range vector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// addRepositoryEvidence adds EvidenceRepositories to the existing edges between the identities
+// whose repository vectors are similar, see ReduceOptions.MinRepositorySimilarity.
+// The weight of the evidence is multiplied by the cosine similarity.
+func addRepositoryEvidence(peopleGraph *IdentityGraph, people People, opts ReduceOptions) {
+	vectors := repositoryVectors(people)
+	matched := 0
+	for _, edge := range peopleGraph.Edges() {
+		similarity := cosineSimilarity(vectors[edge.From], vectors[edge.To])
+		if similarity < opts.MinRepositorySimilarity {
+			continue
+		}
+		node1, node2 := peopleGraph.node(edge.From), peopleGraph.node(edge.To)
+		if !edge.Active && !passIdentitiesLimit(peopleGraph.graph, opts.MaxIdentities, node1, node2) {
+			continue
+		}
+		err := peopleGraph.addScaledEvidence(node1, node2, EvidenceRepositories,
+			fmt.Sprintf("%.2f", similarity), similarity)
+		if err != nil {
+			// the identities have different external ids
+			continue
+		}
+		matched++
+	}
+	reporter.Commit("people matched by repositories", matched)
+}
@@ -0,0 +1,75 @@
+package idmatch
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newRepositoryTestPeople() People {
+	return People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"},
+			Repositories: []string{"private1"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"al@google.com"},
+			Repositories: []string{"private1"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
+			Repositories: []string{"private2"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bobby@google.com"},
+			Repositories: []string{"public"}},
+		5: {ID: 5, NamesWithRepos: []NameWithRepo{{"carol", ""}}, Emails: []string{"carol@google.com"},
+			Repositories: []string{"public"}},
+		6: {ID: 6, NamesWithRepos: []NameWithRepo{{"eve", ""}}, Emails: []string{"eve@google.com"},
+			Repositories: []string{"public"}},
+	}
+}
+
+func TestRepositoryVectors(t *testing.T) {
+	req := require.New(t)
+	vectors := repositoryVectors(newRepositoryTestPeople())
+	req.Len(vectors, 6)
+	req.InDelta(math.Log(3), vectors[1]["private1"], 1e-9)
+	req.InDelta(math.Log(6), vectors[3]["private2"], 1e-9)
+	req.InDelta(math.Log(2), vectors[4]["public"], 1e-9)
+
+	vectors = repositoryVectors(People{
+		1: {ID: 1, Emails: []string{"alice@google.com"}, Repositories: []string{"repo"}},
+		2: {ID: 2, Emails: []string{"bob@google.com"}, Repositories: []string{"repo"}},
+	})
+	req.Empty(vectors[1])
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	req := require.New(t)
+	req.InDelta(1, cosineSimilarity(map[string]float64{"a": 2}, map[string]float64{"a": 1}), 1e-9)
+	req.InDelta(math.Sqrt(0.5), cosineSimilarity(
+		map[string]float64{"a": 1, "b": 1}, map[string]float64{"a": 1}), 1e-9)
+	req.Equal(0.0, cosineSimilarity(map[string]float64{"a": 1}, map[string]float64{"b": 1}))
+	req.Equal(0.0, cosineSimilarity(nil, map[string]float64{"b": 1}))
+}
+
+func TestBuildIdentityGraphRepositories(t *testing.T) {
+	req := require.New(t)
+	opts := ReduceOptions{MaxIdentities: 100, MinEdgeWeight: 1.5}
+	g, err := BuildIdentityGraph(context.Background(), newRepositoryTestPeople(), nil,
+		newTestBlacklist(t), opts)
+	req.NoError(err)
+	req.Equal([][]int64{{1}, {2}, {3}, {4}, {5}, {6}}, g.Components())
+
+	opts.MinRepositorySimilarity = 0.5
+	opts.EvidenceWeights = map[EvidenceKind]float64{EvidenceRepositories: 2}
+	g, err = BuildIdentityGraph(context.Background(), newRepositoryTestPeople(), nil,
+		newTestBlacklist(t), opts)
+	req.NoError(err)
+	edge, _ := g.Edge(1, 2)
+	req.Equal([]Evidence{{EvidenceName, "alice", 1}, {EvidenceRepositories, "1.00", 2}}, edge.Evidence)
+	req.Equal(3.0, edge.Weight)
+	edge, _ = g.Edge(3, 4)
+	req.Equal([]Evidence{{EvidenceName, "bob", 1}}, edge.Evidence)
+	req.Equal([][]int64{{1, 2}, {3}, {4}, {5}, {6}}, g.Components())
+
+	people := newRepositoryTestPeople()
+	req.NoError(g.Reduce(context.Background(), people))
+	req.Equal([]string{"private1"}, people[1].Repositories)
+}
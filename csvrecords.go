@@ -0,0 +1,57 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// readCSVRecords calls fn with every record of the CSV file after the header, see OpenPath.
+// The header must have all the required columns and each record must have as many fields as
+// the header; kind names the file in the errors, e.g. "teams". fn receives the indexes of
+// the columns by name and stops the reading with its error.
+func readCSVRecords(path, kind string, required []string,
+	fn func(header map[string]int, record []string) error) (err error) {
+	var file io.ReadCloser
+	file, err = OpenPath(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	r := csv.NewReader(file)
+	header := make(map[string]int)
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(header) == 0 {
+			for index, name := range record {
+				header[name] = index
+			}
+			for _, name := range required {
+				if _, exists := header[name]; !exists {
+					return fmt.Errorf("invalid %s file %s: no %s column", kind, path, name)
+				}
+			}
+			continue
+		}
+		if len(record) != len(header) {
+			return fmt.Errorf("invalid CSV record: %s", strings.Join(record, ","))
+		}
+		if err := fn(header, record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
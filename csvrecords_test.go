@@ -0,0 +1,49 @@
+package idmatch
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCSVRecords(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-csv")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	write := func(content string) string {
+		path := filepath.Join(dir, "records.csv")
+		req.NoError(ioutil.WriteFile(path, []byte(content), 0666))
+		return path
+	}
+
+	path := write("extra,key,value\nx,a,1\ny,b,2\n")
+	var values []string
+	req.NoError(readCSVRecords(path, "test", []string{"key", "value"},
+		func(header map[string]int, record []string) error {
+			values = append(values, record[header["key"]]+"="+record[header["value"]])
+			return nil
+		}))
+	req.Equal([]string{"a=1", "b=2"}, values)
+
+	stop := errors.New("stop")
+	calls := 0
+	req.Equal(stop, readCSVRecords(path, "test", nil,
+		func(map[string]int, []string) error {
+			calls++
+			return stop
+		}))
+	req.Equal(1, calls)
+
+	noop := func(map[string]int, []string) error { return nil }
+	path = write("key\na\n")
+	req.EqualError(readCSVRecords(path, "test", []string{"key", "value"}, noop),
+		"invalid test file "+path+": no value column")
+	path = write("key,value\na\n")
+	req.Error(readCSVRecords(path, "test", []string{"key"}, noop))
+	req.Error(readCSVRecords(filepath.Join(dir, "missing.csv"), "test", nil, noop))
+}
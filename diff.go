@@ -0,0 +1,218 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IdentityChangeKind is the type of the difference between the identities of two runs.
+type IdentityChangeKind string
+
+const (
+	// IdentitySplit is the old identity whose emails went to several new identities.
+	IdentitySplit IdentityChangeKind = "split"
+	// IdentityMerged is the new identity whose emails came from several old identities.
+	IdentityMerged IdentityChangeKind = "merged"
+	// IdentityGainedEmails is the identity which has new emails.
+	IdentityGainedEmails IdentityChangeKind = "gained_emails"
+	// IdentityLostEmails is the identity which does not have some of its old emails anymore.
+	IdentityLostEmails IdentityChangeKind = "lost_emails"
+	// IdentityExternalIDChanged is the identity with different external ids.
+	IdentityExternalIDChanged IdentityChangeKind = "external_id_changed"
+	// IdentityAdded is the new identity which shares nothing with the old identities.
+	IdentityAdded IdentityChangeKind = "added"
+	// IdentityRemoved is the old identity which shares nothing with the new identities.
+	IdentityRemoved IdentityChangeKind = "removed"
+)
+
+// identityChangeOrder is the order of the change kinds in DiffPeople.
+var identityChangeOrder = map[IdentityChangeKind]int{
+	IdentitySplit: 0, IdentityMerged: 1, IdentityGainedEmails: 2, IdentityLostEmails: 3,
+	IdentityExternalIDChanged: 4, IdentityAdded: 5, IdentityRemoved: 6,
+}
+
+// IdentityChange is a difference between the identities of two runs.
+type IdentityChange struct {
+	Kind IdentityChangeKind `json:"kind"`
+	// OldIDs and NewIDs are the sorted IDs of the involved identities in each run.
+	OldIDs []int64 `json:"old_ids,omitempty"`
+	NewIDs []int64 `json:"new_ids,omitempty"`
+	// Emails are the gained or the lost emails, or all the emails of the split, merged, added
+	// or removed identity.
+	Emails []string `json:"emails,omitempty"`
+	// OldExternalID and NewExternalID are the external IDs formatted by ExternalIDs.String.
+	OldExternalID string `json:"old_external_id,omitempty"`
+	NewExternalID string `json:"new_external_id,omitempty"`
+}
+
+// DiffPeople compares the identities of two runs. The IDs are not stable between the runs, so
+// the identities are matched by their emails, or by their names if they have no emails.
+// The changes are sorted by the kind and then by the IDs.
+func DiffPeople(oldPeople, newPeople People) []IdentityChange {
+	oldOwners, newOwners := diffOwners(oldPeople), diffOwners(newPeople)
+	var changes []IdentityChange
+	counterparts := func(person *Person, owners map[string]int64) []int64 {
+		set := map[int64]struct{}{}
+		for _, key := range diffKeys(person) {
+			if id, exists := owners[key]; exists {
+				set[id] = struct{}{}
+			}
+		}
+		return sortedIDs(set)
+	}
+	oldMatches := map[int64][]int64{}
+	for _, id := range peopleIDs(oldPeople) {
+		person := oldPeople[id]
+		matches := counterparts(person, newOwners)
+		oldMatches[id] = matches
+		if len(matches) == 0 {
+			changes = append(changes, IdentityChange{Kind: IdentityRemoved, OldIDs: []int64{id},
+				Emails: person.Emails, OldExternalID: person.ExternalIDs.String()})
+		} else if len(matches) > 1 {
+			changes = append(changes, IdentityChange{Kind: IdentitySplit, OldIDs: []int64{id},
+				NewIDs: matches, Emails: person.Emails, OldExternalID: person.ExternalIDs.String()})
+		}
+	}
+	for _, id := range peopleIDs(newPeople) {
+		person := newPeople[id]
+		matches := counterparts(person, oldOwners)
+		if len(matches) == 0 {
+			changes = append(changes, IdentityChange{Kind: IdentityAdded, NewIDs: []int64{id},
+				Emails: person.Emails, NewExternalID: person.ExternalIDs.String()})
+			continue
+		}
+		if len(matches) > 1 {
+			changes = append(changes, IdentityChange{Kind: IdentityMerged, OldIDs: matches,
+				NewIDs: []int64{id}, Emails: person.Emails, NewExternalID: person.ExternalIDs.String()})
+			continue
+		}
+		oldID := matches[0]
+		if len(oldMatches[oldID]) != 1 {
+			// reported as a split
+			continue
+		}
+		changes = append(changes, diffSameIdentity(oldPeople[oldID], person)...)
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		return identityChangeOrder[changes[i].Kind] < identityChangeOrder[changes[j].Kind]
+	})
+	return changes
+}
+
+// diffSameIdentity compares the old and the new versions of the same identity.
+func diffSameIdentity(oldPerson, newPerson *Person) []IdentityChange {
+	var changes []IdentityChange
+	oldIDs, newIDs := []int64{oldPerson.ID}, []int64{newPerson.ID}
+	if gained := stringsDifference(newPerson.Emails, oldPerson.Emails); len(gained) > 0 {
+		changes = append(changes, IdentityChange{Kind: IdentityGainedEmails, OldIDs: oldIDs,
+			NewIDs: newIDs, Emails: gained})
+	}
+	if lost := stringsDifference(oldPerson.Emails, newPerson.Emails); len(lost) > 0 {
+		changes = append(changes, IdentityChange{Kind: IdentityLostEmails, OldIDs: oldIDs,
+			NewIDs: newIDs, Emails: lost})
+	}
+	if !oldPerson.ExternalIDs.equal(newPerson.ExternalIDs) {
+		changes = append(changes, IdentityChange{Kind: IdentityExternalIDChanged, OldIDs: oldIDs,
+			NewIDs: newIDs, OldExternalID: oldPerson.ExternalIDs.String(),
+			NewExternalID: newPerson.ExternalIDs.String()})
+	}
+	return changes
+}
+
+// diffKeys returns the emails of the person, or the names prefixed with "name:" if there
+// are no emails.
+func diffKeys(person *Person) []string {
+	if len(person.Emails) > 0 {
+		return person.Emails
+	}
+	keys := make([]string, len(person.NamesWithRepos))
+	for i, name := range person.NamesWithRepos {
+		keys[i] = "name:" + name.Name
+	}
+	return keys
+}
+
+// diffOwners maps each key of diffKeys to the identity ID.
+func diffOwners(people People) map[string]int64 {
+	owners := map[string]int64{}
+	for id, person := range people {
+		for _, key := range diffKeys(person) {
+			owners[key] = id
+		}
+	}
+	return owners
+}
+
+func sortedIDs(set map[int64]struct{}) []int64 {
+	ids := make([]int64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Sort(Int64Slice(ids))
+	return ids
+}
+
+// stringsDifference returns the sorted strings of a which are missing in b.
+func stringsDifference(a, b []string) []string {
+	set := map[string]struct{}{}
+	for _, s := range b {
+		set[s] = struct{}{}
+	}
+	var result []string
+	for _, s := range a {
+		if _, exists := set[s]; !exists {
+			result = append(result, s)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+var identityChangesHeader = []string{
+	"kind", "old_ids", "new_ids", "emails", "old_external_id", "new_external_id"}
+
+// WriteIdentityChanges stores the result of DiffPeople to the CSV file. The IDs and the emails
+// are joined with "; ".
+func WriteIdentityChanges(path string, changes []IdentityChange) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write(identityChangesHeader); err != nil {
+		return
+	}
+	formatIDs := func(ids []int64) string {
+		items := make([]string, len(ids))
+		for i, id := range ids {
+			items[i] = strconv.FormatInt(id, 10)
+		}
+		return strings.Join(items, "; ")
+	}
+	for _, change := range changes {
+		err = writer.Write([]string{string(change.Kind), formatIDs(change.OldIDs),
+			formatIDs(change.NewIDs), strings.Join(change.Emails, "; "), change.OldExternalID,
+			change.NewExternalID})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
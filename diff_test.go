@@ -0,0 +1,52 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffPeople(t *testing.T) {
+	req := require.New(t)
+	oldPeople := People{
+		1: {ID: 1, Emails: []string{"bob@google.com", "bob@home.org"}, ExternalIDs: ExternalIDs{"github": "bob"}},
+		2: {ID: 2, Emails: []string{"alice@google.com"}},
+		3: {ID: 3, Emails: []string{"al@google.com"}},
+		4: {ID: 4, Emails: []string{"eve@google.com"}, ExternalIDs: ExternalIDs{"github": "eve"}},
+		5: {ID: 5, NamesWithRepos: []NameWithRepo{{"mallory", ""}}},
+		6: {ID: 6, Emails: []string{"carol@google.com"}},
+	}
+	newPeople := People{
+		10: {ID: 10, Emails: []string{"bob@google.com"}, ExternalIDs: ExternalIDs{"github": "bob"}},
+		11: {ID: 11, Emails: []string{"bob@home.org"}},
+		12: {ID: 12, Emails: []string{"al@google.com", "alice@google.com"}},
+		13: {ID: 13, Emails: []string{"eve@google.com", "eve@home.org"}, ExternalIDs: ExternalIDs{"github": "eve2"}},
+		14: {ID: 14, NamesWithRepos: []NameWithRepo{{"mallory", ""}}},
+		15: {ID: 15, Emails: []string{"dave@google.com"}},
+	}
+	changes := DiffPeople(oldPeople, newPeople)
+	req.Equal([]IdentityChange{
+		{Kind: IdentitySplit, OldIDs: []int64{1}, NewIDs: []int64{10, 11},
+			Emails: []string{"bob@google.com", "bob@home.org"}, OldExternalID: "github:bob"},
+		{Kind: IdentityMerged, OldIDs: []int64{2, 3}, NewIDs: []int64{12},
+			Emails: []string{"al@google.com", "alice@google.com"}},
+		{Kind: IdentityGainedEmails, OldIDs: []int64{4}, NewIDs: []int64{13},
+			Emails: []string{"eve@home.org"}},
+		{Kind: IdentityExternalIDChanged, OldIDs: []int64{4}, NewIDs: []int64{13},
+			OldExternalID: "github:eve", NewExternalID: "github:eve2"},
+		{Kind: IdentityAdded, NewIDs: []int64{15}, Emails: []string{"dave@google.com"}},
+		{Kind: IdentityRemoved, OldIDs: []int64{6}, Emails: []string{"carol@google.com"}},
+	}, changes)
+	req.Empty(DiffPeople(oldPeople, oldPeople))
+
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(WriteIdentityChanges(f.Name(), changes[2:4]))
+	content, err := ioutil.ReadFile(f.Name())
+	req.NoError(err)
+	req.Equal(`kind,old_ids,new_ids,emails,old_external_id,new_external_id
+gained_emails,4,13,eve@home.org,,
+external_id_changed,4,13,,github:eve,github:eve2
+`, string(content))
+}
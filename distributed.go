@@ -0,0 +1,166 @@
+package idmatch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// partialHeader is the first line of the partial people file: the name and the email
+// frequencies of the shard, which are summed by MergePartialPeople.
+type partialHeader struct {
+	Shard      string                `json:"shard"`
+	NameFreqs  map[string]*Frequency `json:"name_freqs"`
+	EmailFreqs map[string]*Frequency `json:"email_freqs"`
+}
+
+// partialPerson is a line of the partial people file after the header: the person reduced
+// inside the shard with the blocking keys which join it with the people of the other shards.
+type partialPerson struct {
+	Person *Person  `json:"person"`
+	Keys   []string `json:"keys"`
+}
+
+// blockingKeys returns the keys by which the person is matched across the shards:
+// "email:<email>" for the unpopular emails which are neither disposable nor noreply and
+// "name:<name>" for the unpopular names.
+// The popular names are attached to the repositories the same way as in newPeople.
+func blockingKeys(person *Person, blacklist Blacklist) []string {
+	var keys []string
+	for _, email := range person.Emails {
+		if !blacklist.isPopularEmail(email) && !blacklist.isUnmatchableEmail(email) {
+			keys = append(keys, "email:"+email)
+		}
+	}
+	for _, name := range person.NamesWithRepos {
+		if !blacklist.isPopularName(name.String()) {
+			keys = append(keys, "name:"+name.String())
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WritePartialPeople stores the people reduced inside a single shard of the signatures with
+// the frequencies of the names and the emails in the shard, so that MergePartialPeople joins
+// the shards which were processed on different machines. The file has JSON lines.
+func WritePartialPeople(path, shard string, people People,
+	nameFreqs, emailFreqs map[string]*Frequency, blacklist Blacklist) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	writer := bufio.NewWriter(file)
+	defer func() {
+		errFlush := writer.Flush()
+		if err == nil {
+			err = errFlush
+		}
+	}()
+	encoder := json.NewEncoder(writer)
+	if err = encoder.Encode(partialHeader{shard, nameFreqs, emailFreqs}); err != nil {
+		return
+	}
+	people.ForEach(func(id int64, person *Person) bool {
+		err = encoder.Encode(partialPerson{person, blockingKeys(person, blacklist)})
+		return err != nil
+	})
+	return
+}
+
+// readPartialPeople reads the file written by WritePartialPeople.
+func readPartialPeople(path string) (partialHeader, []partialPerson, error) {
+	var header partialHeader
+	file, err := OpenPath(path)
+	if err != nil {
+		return header, nil, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	if err := decoder.Decode(&header); err != nil {
+		return header, nil, fmt.Errorf("invalid partial people file %s: %v", path, err)
+	}
+	var persons []partialPerson
+	for decoder.More() {
+		var person partialPerson
+		if err := decoder.Decode(&person); err != nil {
+			return header, nil, fmt.Errorf("invalid partial people file %s: %v", path, err)
+		}
+		if person.Person == nil {
+			return header, nil, fmt.Errorf("invalid partial people file %s: no person", path)
+		}
+		persons = append(persons, person)
+	}
+	return header, persons, nil
+}
+
+// MergePartialPeople reads the files of all the shards written by WritePartialPeople, sums
+// the frequencies and merges the people who share a blocking key which is not popular across
+// all the shards. The people receive new IDs. The summed frequencies are returned for
+// SetPrimaryValues.
+func MergePartialPeople(paths []string, blacklist Blacklist, popularity PopularityThresholds) (
+	People, map[string]*Frequency, map[string]*Frequency, error) {
+	nameFreqs, emailFreqs := map[string]*Frequency{}, map[string]*Frequency{}
+	people := People{}
+	keys := map[int64][]string{}
+	shards := map[string]string{}
+	var id int64
+	for _, path := range paths {
+		header, persons, err := readPartialPeople(path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if other, exists := shards[header.Shard]; exists {
+			return nil, nil, nil, fmt.Errorf("shard %s is in both %s and %s", header.Shard, other, path)
+		}
+		shards[header.Shard] = path
+		MergeFrequencies(nameFreqs, header.NameFreqs)
+		MergeFrequencies(emailFreqs, header.EmailFreqs)
+		for _, person := range persons {
+			id++
+			person.Person.ID = id
+			people[id] = person.Person
+			keys[id] = person.Keys
+		}
+	}
+	blacklist = blacklist.WithPopular(nameFreqs, emailFreqs, popularity)
+	merger := NewPeopleMerger(people)
+	owners := map[string]int64{}
+	for id := int64(1); id <= int64(len(people)); id++ {
+		merger.Add(id)
+		for _, key := range keys[id] {
+			if kind := strings.SplitN(key, ":", 2); len(kind) == 2 &&
+				(kind[0] == "email" && (blacklist.isPopularEmail(kind[1]) ||
+					blacklist.isUnmatchableEmail(kind[1])) ||
+					kind[0] == "name" && blacklist.isPopularName(kind[1])) {
+				continue
+			}
+			owner, exists := owners[key]
+			if !exists {
+				owners[key] = id
+				continue
+			}
+			if err := merger.Union(owner, id); err != nil {
+				// the people have different external ids
+				reporter.Increment("partial people with different external ids")
+			}
+		}
+	}
+	if _, err := merger.Apply(); err != nil {
+		return nil, nil, nil, err
+	}
+	reporter.Commit("people after merging the shards", len(people))
+	return people, nameFreqs, emailFreqs, nil
+}
@@ -0,0 +1,57 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePartialPeople(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-partial")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	blacklist := newTestBlacklist(t)
+	path1, path2 := filepath.Join(dir, "1.jsonl"), filepath.Join(dir, "2.jsonl")
+	req.NoError(WritePartialPeople(path1, "1", People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
+			Repositories: []string{"repo1"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+	}, map[string]*Frequency{"bob": {Recent: 1, Total: 1}, "alice": {Recent: 0, Total: 1}},
+		map[string]*Frequency{"bob@google.com": {Recent: 1, Total: 1}, "alice@google.com": {Recent: 0, Total: 1}}, blacklist))
+	req.NoError(WritePartialPeople(path2, "2", People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"robert", ""}}, Emails: []string{"bob@google.com"},
+			Repositories: []string{"repo2"}, ExternalIDs: ExternalIDs{"github": "bob"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@yahoo.com"}},
+	}, map[string]*Frequency{"robert": {Recent: 1, Total: 1}, "alice": {Recent: 1, Total: 1}},
+		map[string]*Frequency{"bob@google.com": {Recent: 1, Total: 1}, "alice@yahoo.com": {Recent: 1, Total: 1}}, blacklist))
+
+	people, nameFreqs, emailFreqs, err := MergePartialPeople(
+		[]string{path1, path2}, blacklist, PopularityThresholds{})
+	req.NoError(err)
+	req.Len(people, 2)
+	req.Equal(&Person{ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}, {"robert", ""}},
+		Emails: []string{"bob@google.com"}, Repositories: []string{"repo1", "repo2"},
+		ExternalIDs: ExternalIDs{"github": "bob"}}, people[1])
+	req.Equal([]string{"alice@google.com", "alice@yahoo.com"}, people[2].Emails)
+	req.Equal(&Frequency{Recent: 1, Total: 2}, nameFreqs["alice"])
+	req.Equal(&Frequency{Recent: 2, Total: 2}, emailFreqs["bob@google.com"])
+
+	// "alice" is popular across the shards
+	people, _, _, err = MergePartialPeople(
+		[]string{path1, path2}, blacklist, PopularityThresholds{MinNameCount: 2})
+	req.NoError(err)
+	req.Len(people, 3)
+
+	_, _, _, err = MergePartialPeople([]string{path1, path1}, blacklist, PopularityThresholds{})
+	req.Error(err)
+	_, _, _, err = MergePartialPeople([]string{filepath.Join(dir, "missing")}, blacklist,
+		PopularityThresholds{})
+	req.Error(err)
+	req.NoError(ioutil.WriteFile(path2, []byte("{}\n{\"keys\": []}\n"), 0666))
+	_, _, _, err = MergePartialPeople([]string{path2}, blacklist, PopularityThresholds{})
+	req.Error(err)
+}
@@ -0,0 +1,118 @@
+package idmatch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DomainPolicy defines how much an email equality at a particular domain can be trusted.
+type DomainPolicy string
+
+const (
+	// DomainPolicyDefault means that the same email implies the same person unless the email
+	// is popular.
+	DomainPolicyDefault DomainPolicy = ""
+	// DomainPolicyCorporate means that the local part is a unique employee login: the same
+	// email always implies the same person, even if the email is popular, and the subdomains
+	// are treated as the domain itself.
+	DomainPolicyCorporate DomainPolicy = "corporate"
+	// DomainPolicyFreemail means that the mailbox can be shared or reused by unrelated people:
+	// the same email implies the same person only if their names share at least one word.
+	DomainPolicyFreemail DomainPolicy = "freemail"
+)
+
+// DomainPolicies maps email domains to the corresponding policies.
+type DomainPolicies map[string]DomainPolicy
+
+var freemailDomains = []string{
+	"gmail.com", "googlemail.com", "yahoo.com", "hotmail.com", "outlook.com", "live.com",
+	"icloud.com", "me.com", "aol.com", "gmx.de", "gmx.net", "web.de", "mail.ru", "yandex.ru",
+	"qq.com", "163.com", "126.com", "protonmail.com", "fastmail.com", "zoho.com",
+}
+
+// NewDomainPolicies returns the built-in policies which mark the popular free email providers.
+func NewDomainPolicies() DomainPolicies {
+	policies := DomainPolicies{}
+	for _, domain := range freemailDomains {
+		policies[domain] = DomainPolicyFreemail
+	}
+	return policies
+}
+
+// ReadDomainPolicies loads the domain policies from a CSV file with the columns "domain" and
+// "policy". The policy is either "corporate", "freemail" or "default".
+func ReadDomainPolicies(path string) (policies DomainPolicies, err error) {
+	policies = DomainPolicies{}
+	err = readCSVRecords(path, "domain policies", []string{"domain", "policy"},
+		func(header map[string]int, record []string) error {
+			domain := strings.ToLower(strings.TrimSpace(record[header["domain"]]))
+			policy := DomainPolicy(strings.ToLower(strings.TrimSpace(record[header["policy"]])))
+			switch policy {
+			case DomainPolicyCorporate, DomainPolicyFreemail:
+				policies[domain] = policy
+			case "default", DomainPolicyDefault:
+				policies[domain] = DomainPolicyDefault
+			default:
+				return fmt.Errorf("unknown policy for domain %s: %s", domain, policy)
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// Merge returns the union of both policy sets, the other policies take precedence.
+func (policies DomainPolicies) Merge(other DomainPolicies) DomainPolicies {
+	result := DomainPolicies{}
+	for domain, policy := range policies {
+		result[domain] = policy
+	}
+	for domain, policy := range other {
+		result[domain] = policy
+	}
+	return result
+}
+
+// resolve finds the policy of the email domain. The corporate policies are inherited by the
+// subdomains, in which case the email is rewritten to the corporate domain.
+func (policies DomainPolicies) resolve(email string) (string, DomainPolicy) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email, DomainPolicyDefault
+	}
+	local, domain := email[:at], email[at+1:]
+	if policy, exists := policies[domain]; exists {
+		return email, policy
+	}
+	for parent := domain; strings.Contains(parent, "."); {
+		parent = parent[strings.Index(parent, ".")+1:]
+		if policy, exists := policies[parent]; exists {
+			if policy == DomainPolicyCorporate {
+				return local + "@" + parent, policy
+			}
+			break
+		}
+	}
+	return email, DomainPolicyDefault
+}
+
+// shareNameTokens checks whether any name of the first person has a common word with any name
+// of the second person.
+func shareNameTokens(person1, person2 *Person) bool {
+	tokens := map[string]struct{}{}
+	for _, name := range person1.NamesWithRepos {
+		for _, token := range splitNameTokens(name.Name) {
+			tokens[token] = struct{}{}
+		}
+	}
+	for _, name := range person2.NamesWithRepos {
+		for _, token := range splitNameTokens(name.Name) {
+			if _, exists := tokens[token]; exists {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package idmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainPoliciesResolve(t *testing.T) {
+	require := require.New(t)
+	policies := NewDomainPolicies().Merge(DomainPolicies{"company.com": DomainPolicyCorporate})
+	email, policy := policies.resolve("bob@gmail.com")
+	require.Equal("bob@gmail.com", email)
+	require.Equal(DomainPolicyFreemail, policy)
+	email, policy = policies.resolve("bob@eng.company.com")
+	require.Equal("bob@company.com", email)
+	require.Equal(DomainPolicyCorporate, policy)
+	email, policy = policies.resolve("bob@other.com")
+	require.Equal("bob@other.com", email)
+	require.Equal(DomainPolicyDefault, policy)
+	var noPolicies DomainPolicies
+	email, policy = noPolicies.resolve("bob@gmail.com")
+	require.Equal("bob@gmail.com", email)
+	require.Equal(DomainPolicyDefault, policy)
+}
+
+func TestReadDomainPolicies(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := f.WriteString("domain,policy\nCompany.com,corporate\ngmail.com,default\nmail.org,freemail\n")
+	req.NoError(err)
+	policies, err := ReadDomainPolicies(f.Name())
+	req.NoError(err)
+	req.Equal(DomainPolicies{
+		"company.com": DomainPolicyCorporate,
+		"gmail.com":   DomainPolicyDefault,
+		"mail.org":    DomainPolicyFreemail,
+	}, policies)
+
+	f2, cleanup2 := tempFile(t, "*.csv")
+	defer cleanup2()
+	_, err = f2.WriteString("domain,policy\ncompany.com,aggressive\n")
+	req.NoError(err)
+	_, err = ReadDomainPolicies(f2.Name())
+	req.Error(err)
+}
+
+func TestShareNameTokens(t *testing.T) {
+	bob := &Person{NamesWithRepos: []NameWithRepo{{"bob smith", ""}}}
+	smith := &Person{NamesWithRepos: []NameWithRepo{{"alice", ""}, {"smith", ""}}}
+	alice := &Person{NamesWithRepos: []NameWithRepo{{"alice", ""}}}
+	require.True(t, shareNameTokens(bob, smith))
+	require.False(t, shareNameTokens(bob, alice))
+}
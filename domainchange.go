@@ -0,0 +1,102 @@
+package idmatch
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// domainChangeCandidate is the corporate email of an identity with the activity period of
+// the identity, see addDomainChangeEvidence.
+type domainChangeCandidate struct {
+	node   node
+	email  string
+	domain string
+	first  time.Time
+	last   time.Time
+}
+
+// isFreemailDomain checks whether the domain belongs to the built-in free email providers.
+func isFreemailDomain(domain string) bool {
+	for _, freemail := range freemailDomains {
+		if domain == freemail {
+			return true
+		}
+	}
+	return false
+}
+
+// addDomainChangeEvidence adds EvidenceDomainChange between the identities with the same name
+// and the same local part of the emails at different corporate domains, whose activity periods
+// do not overlap, e.g. bob@google.com until 2017 and bob@apple.com since 2018: the person has
+// most likely changed the job. The identities without the commit times are skipped, and so are
+// the emails at the free email providers, which are not owned by the employer.
+func addDomainChangeEvidence(peopleGraph *IdentityGraph, people People, ids []int64,
+	blacklist Blacklist, opts ReduceOptions) {
+	groups := map[string][]domainChangeCandidate{}
+	for _, id := range ids {
+		person := people[id]
+		if person.FirstCommit.IsZero() || person.LastCommit.IsZero() {
+			continue
+		}
+		names := map[string]struct{}{}
+		for _, name := range person.NamesWithRepos {
+			if name.Name != "" {
+				names[strings.ToLower(name.Name)] = struct{}{}
+			}
+		}
+		for _, email := range person.Emails {
+			if blacklist.isUnmatchableEmail(email) {
+				continue
+			}
+			key, policy := opts.DomainPolicies.resolve(opts.EmailAliases.canonicalEmail(email))
+			local, domain := splitEmailAddress(key)
+			if local == "" || domain == "" || policy == DomainPolicyFreemail || isFreemailDomain(domain) {
+				continue
+			}
+			candidate := domainChangeCandidate{peopleGraph.node(id), key, domain,
+				person.FirstCommit, person.LastCommit}
+			for name := range names {
+				groupKey := local + "\x00" + name
+				groups[groupKey] = append(groups[groupKey], candidate)
+			}
+		}
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	matched := 0
+	for _, key := range keys {
+		group := groups[key]
+		for i, candidate1 := range group {
+			for _, candidate2 := range group[i+1:] {
+				if candidate1.node.ID() == candidate2.node.ID() || candidate1.domain == candidate2.domain {
+					continue
+				}
+				earlier, later := candidate1, candidate2
+				if later.first.Before(earlier.first) {
+					earlier, later = later, earlier
+				}
+				if !earlier.last.Before(later.first) {
+					// the activity periods overlap
+					continue
+				}
+				if !passIdentitiesLimit(peopleGraph.graph, opts.MaxIdentities, earlier.node, later.node) {
+					continue
+				}
+				err := peopleGraph.addEvidence(earlier.node, later.node, EvidenceDomainChange,
+					earlier.email+" -> "+later.email)
+				if err != nil {
+					// the identities have different external ids
+					continue
+				}
+				matched++
+			}
+		}
+	}
+	reporter.Commit("people matched by domain change", matched)
+}
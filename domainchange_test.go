@@ -0,0 +1,51 @@
+package idmatch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newDomainChangeTestPeople() People {
+	year := func(y int) time.Time { return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC) }
+	return People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
+			FirstCommit: year(2014), LastCommit: year(2016)},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"Bob", ""}}, Emails: []string{"bob@apple.com"},
+			FirstCommit: year(2017), LastCommit: year(2019)},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@mozilla.org"},
+			FirstCommit: year(2018), LastCommit: year(2020)},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@gmail.com"},
+			FirstCommit: year(2020), LastCommit: year(2021)},
+		5: {ID: 5, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@ibm.com"}},
+		6: {ID: 6, NamesWithRepos: []NameWithRepo{{"bobby", ""}}, Emails: []string{"bob@intel.com"},
+			FirstCommit: year(2021), LastCommit: year(2022)},
+	}
+}
+
+func TestBuildIdentityGraphDomainChanges(t *testing.T) {
+	req := require.New(t)
+	opts := ReduceOptions{MaxIdentities: 100, MinEdgeWeight: 1.5}
+	g, err := BuildIdentityGraph(context.Background(), newDomainChangeTestPeople(), nil,
+		newTestBlacklist(t), opts)
+	req.NoError(err)
+	req.Equal([][]int64{{1}, {2}, {3}, {4}, {5}, {6}}, g.Components())
+
+	opts.MatchDomainChanges = true
+	opts.EvidenceWeights = map[EvidenceKind]float64{EvidenceDomainChange: 2}
+	g, err = BuildIdentityGraph(context.Background(), newDomainChangeTestPeople(), nil,
+		newTestBlacklist(t), opts)
+	req.NoError(err)
+	edge, _ := g.Edge(1, 2)
+	req.Equal([]Evidence{{EvidenceDomainChange, "bob@google.com -> bob@apple.com", 2}}, edge.Evidence)
+	req.True(edge.Active)
+	edge, _ = g.Edge(1, 3)
+	req.True(edge.Active)
+	// the periods of apple.com and mozilla.org overlap
+	edge, _ = g.Edge(2, 3)
+	req.False(edge.Active)
+	// gmail.com is a free email provider, ibm.com has no commit times and bobby is another name
+	req.Equal([][]int64{{1, 2, 3}, {4}, {5}, {6}}, g.Components())
+}
@@ -0,0 +1,105 @@
+package idmatch
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EmailAliasRule defines how the local part of an email address at a particular domain is
+// canonicalized so that all the aliases of the same mailbox become equal.
+type EmailAliasRule struct {
+	// Separators is the set of characters which start the ignored tag suffix of the local part,
+	// e.g. "+" turns "bob+work" into "bob". Empty means no tags.
+	Separators string
+	// IgnoreDots removes all the dots from the local part, e.g. "b.ob" becomes "bob".
+	IgnoreDots bool
+	// Domain replaces the original domain if not empty, e.g. googlemail.com -> gmail.com.
+	Domain string
+}
+
+// EmailAliasRules maps email domains to the corresponding alias rules.
+// The domains which are not listed are never canonicalized.
+type EmailAliasRules map[string]EmailAliasRule
+
+// NewEmailAliasRules returns the built-in rules for the popular email providers.
+func NewEmailAliasRules() EmailAliasRules {
+	plus := EmailAliasRule{Separators: "+"}
+	return EmailAliasRules{
+		"gmail.com":      {Separators: "+", IgnoreDots: true},
+		"googlemail.com": {Separators: "+", IgnoreDots: true, Domain: "gmail.com"},
+		"outlook.com":    plus,
+		"hotmail.com":    plus,
+		"live.com":       plus,
+		"icloud.com":     plus,
+		"me.com":         plus,
+		"fastmail.com":   plus,
+		"fastmail.fm":    plus,
+		"protonmail.com": plus,
+		"protonmail.ch":  plus,
+		"pm.me":          plus,
+		"yandex.ru":      plus,
+	}
+}
+
+// ReadEmailAliasRules loads the alias rules from a CSV file with the columns "domain",
+// "separators", "ignore_dots" and "canonical_domain".
+func ReadEmailAliasRules(path string) (rules EmailAliasRules, err error) {
+	rules = EmailAliasRules{}
+	required := []string{"domain", "separators", "ignore_dots", "canonical_domain"}
+	err = readCSVRecords(path, "email aliases", required,
+		func(header map[string]int, record []string) error {
+			ignoreDots, err := strconv.ParseBool(strings.TrimSpace(record[header["ignore_dots"]]))
+			if err != nil {
+				return fmt.Errorf("invalid ignore_dots value in %s: %v", strings.Join(record, ","), err)
+			}
+			domain := strings.ToLower(strings.TrimSpace(record[header["domain"]]))
+			rules[domain] = EmailAliasRule{
+				Separators: strings.TrimSpace(record[header["separators"]]),
+				IgnoreDots: ignoreDots,
+				Domain:     strings.ToLower(strings.TrimSpace(record[header["canonical_domain"]])),
+			}
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Merge returns the union of both rule sets, the other rules take precedence.
+func (rules EmailAliasRules) Merge(other EmailAliasRules) EmailAliasRules {
+	result := EmailAliasRules{}
+	for domain, rule := range rules {
+		result[domain] = rule
+	}
+	for domain, rule := range other {
+		result[domain] = rule
+	}
+	return result
+}
+
+// canonicalEmail returns the mailbox address which the email is delivered to.
+func (rules EmailAliasRules) canonicalEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+	rule, exists := rules[domain]
+	if !exists {
+		return email
+	}
+	if rule.Separators != "" {
+		if pos := strings.IndexAny(local, rule.Separators); pos > 0 {
+			local = local[:pos]
+		}
+	}
+	if rule.IgnoreDots {
+		local = strings.Replace(local, ".", "", -1)
+	}
+	if rule.Domain != "" {
+		domain = rule.Domain
+	}
+	return local + "@" + domain
+}
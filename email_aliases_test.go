@@ -0,0 +1,53 @@
+package idmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalEmail(t *testing.T) {
+	require := require.New(t)
+	rules := NewEmailAliasRules()
+	require.Equal("bob@gmail.com", rules.canonicalEmail("bob+work@gmail.com"))
+	require.Equal("bob@gmail.com", rules.canonicalEmail("b.ob@gmail.com"))
+	require.Equal("bob@gmail.com", rules.canonicalEmail("b.o.b+x+y@googlemail.com"))
+	require.Equal("b.ob@hotmail.com", rules.canonicalEmail("b.ob+spam@hotmail.com"))
+	require.Equal("bob-news@yahoo.com", rules.canonicalEmail("bob-news@yahoo.com"))
+	require.Equal("b.ob+work@company.com", rules.canonicalEmail("b.ob+work@company.com"))
+	require.Equal("+work@gmail.com", rules.canonicalEmail("+work@gmail.com"))
+	require.Equal("nodomain", rules.canonicalEmail("nodomain"))
+	var noRules EmailAliasRules
+	require.Equal("bob+work@gmail.com", noRules.canonicalEmail("bob+work@gmail.com"))
+}
+
+func TestReadEmailAliasRules(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := f.WriteString("domain,separators,ignore_dots,canonical_domain\n" +
+		"company.com,+,false,\n" +
+		"Gmail.com,,false,\n" +
+		"old.company.com,-,true,company.com\n")
+	req.NoError(err)
+	rules, err := ReadEmailAliasRules(f.Name())
+	req.NoError(err)
+	req.Equal(EmailAliasRules{
+		"company.com":     {Separators: "+"},
+		"gmail.com":       {},
+		"old.company.com": {Separators: "-", IgnoreDots: true, Domain: "company.com"},
+	}, rules)
+	merged := NewEmailAliasRules().Merge(rules)
+	req.Equal("b.ob+work@gmail.com", merged.canonicalEmail("b.ob+work@gmail.com"))
+	req.Equal("b.ob@company.com", merged.canonicalEmail("b.ob+work@company.com"))
+	req.Equal("bob@company.com", merged.canonicalEmail("b.ob-x@old.company.com"))
+}
+
+func TestReadEmailAliasRulesInvalid(t *testing.T) {
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := f.WriteString("domain,separators\ncompany.com,+\n")
+	require.NoError(t, err)
+	_, err = ReadEmailAliasRules(f.Name())
+	require.Error(t, err)
+}
@@ -0,0 +1,235 @@
+package idmatch
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// EncryptedEmailPrefix marks the encrypted values, so that the plain values are told apart
+// when reading them back.
+const EncryptedEmailPrefix = "aesgcm:"
+
+// EmailCipher encrypts the emails in the output files with AES-GCM. Each value is encrypted
+// with a random nonce, so the same email yields different ciphertexts and the encrypted emails
+// cannot be joined without the key, unlike the pseudonyms of Pseudonymizer.
+type EmailCipher struct {
+	aead cipher.AEAD
+}
+
+// NewEmailCipher creates the EmailCipher with the AES-128, AES-192 or AES-256 key.
+func NewEmailCipher(key []byte) (*EmailCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &EmailCipher{aead: aead}, nil
+}
+
+// LoadEmailCipher reads the key from the file, which contains the 16, 24 or 32 key bytes encoded
+// in hex or in base64, and creates the EmailCipher.
+func LoadEmailCipher(path string) (*EmailCipher, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	encoded := strings.TrimSpace(string(data))
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		if key, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("the email key in %s is neither hex nor base64", path)
+		}
+	}
+	return NewEmailCipher(key)
+}
+
+// Encrypt returns the email encrypted and encoded in base64 after EncryptedEmailPrefix.
+// The empty email stays empty.
+func (c *EmailCipher) Encrypt(email string) (string, error) {
+	if email == "" {
+		return "", nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(email), nil)
+	return EncryptedEmailPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverts Encrypt. The values without EncryptedEmailPrefix are returned as is.
+func (c *EmailCipher) Decrypt(value string) (string, error) {
+	if !strings.HasPrefix(value, EncryptedEmailPrefix) {
+		return value, nil
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value[len(EncryptedEmailPrefix):])
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", errors.New("the encrypted email is truncated")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	email, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the email: %v", err)
+	}
+	return string(email), nil
+}
+
+// EncryptEmails encrypts the emails of the people in place, including the merge evidence.
+// The people should be written and not matched after that.
+func (p People) EncryptEmails(c *EmailCipher) error {
+	return p.transformEmails(c.Encrypt)
+}
+
+// DecryptEmails reverts EncryptEmails.
+func (p People) DecryptEmails(c *EmailCipher) error {
+	return p.transformEmails(c.Decrypt)
+}
+
+// transformEmails replaces every email of the people. Each distinct email of a person is
+// transformed once, so that the random nonces of Encrypt still give the same ciphertext to
+// the same email in Emails, PrimaryEmail and the rest.
+func (p People) transformEmails(transform func(string) (string, error)) error {
+	var err error
+	var transformed map[string]string
+	email := func(value string) string {
+		if result, exists := transformed[value]; exists {
+			return result
+		}
+		result, errTransform := transform(value)
+		if errTransform != nil && err == nil {
+			err = errTransform
+		}
+		transformed[value] = result
+		return result
+	}
+	same := func(value string) string { return value }
+	for _, person := range p {
+		transformed = map[string]string{}
+		for i, value := range person.Emails {
+			person.Emails[i] = email(value)
+		}
+		sort.Strings(person.Emails)
+		person.PrimaryEmail = email(person.PrimaryEmail)
+		person.EmailSources = replaceSourceKeys(person.EmailSources, email)
+		person.AliasHistory.Emails = replaceSpanKeys(person.AliasHistory.Emails, email)
+		for i := range person.MergeEvidence {
+			evidence := person.MergeEvidence[i].Evidence
+			for j, ev := range evidence {
+				evidence[j].Value = replaceEvidenceValue(ev, email, same, same)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadIdentitiesFromParquet reads the people stored with People.WriteToParquet or with
+// People.WritePartitionedParquet if path is the directory, and decrypts their emails if c is not
+// nil. It returns the people and the external id provider.
+func ReadIdentitiesFromParquet(path string, c *EmailCipher) (People, string, error) {
+	var people People
+	var provider string
+	var err error
+	if isPartitionedParquet(path) {
+		people, provider, err = readPartitionedParquet(path, 0)
+	} else {
+		people, provider, err = readFromParquet(path)
+	}
+	if err != nil || c == nil {
+		return people, provider, err
+	}
+	return people, provider, people.DecryptEmails(c)
+}
+
+// EncryptEmailIssues returns the copy of the issues with the encrypted emails for
+// WriteEmailIssues.
+func EncryptEmailIssues(issues []EmailIssue, c *EmailCipher) ([]EmailIssue, error) {
+	result := make([]EmailIssue, len(issues))
+	for i, issue := range issues {
+		var err error
+		if issue.Email, err = c.Encrypt(issue.Email); err != nil {
+			return nil, err
+		}
+		if issue.Repaired, err = c.Encrypt(issue.Repaired); err != nil {
+			return nil, err
+		}
+		result[i] = issue
+	}
+	return result, nil
+}
+
+// DecryptCSV copies the CSV file from inputPath to outputPath and decrypts all the encrypted
+// values on the way, e.g. in the training pairs or in the email issues.
+func DecryptCSV(inputPath, outputPath string, c *EmailCipher) (err error) {
+	var input io.ReadCloser
+	var output io.WriteCloser
+	input, err = OpenPath(inputPath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := input.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	output, err = CreatePath(outputPath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := output.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	r := csv.NewReader(input)
+	writer := csv.NewWriter(output)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	for {
+		record, errRead := r.Read()
+		if errRead == io.EOF {
+			return
+		}
+		if errRead != nil {
+			return errRead
+		}
+		for i, value := range record {
+			// the training pairs join the emails of an identity with "|"
+			parts := strings.Split(value, "|")
+			for j, part := range parts {
+				if parts[j], err = c.Decrypt(part); err != nil {
+					return
+				}
+			}
+			record[i] = strings.Join(parts, "|")
+		}
+		if err = writer.Write(record); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package idmatch
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testEmailKey = "000102030405060708090a0b0c0d0e0f"
+
+func newTestEmailCipher(t *testing.T) *EmailCipher {
+	t.Helper()
+	key, err := hex.DecodeString(testEmailKey)
+	require.NoError(t, err)
+	c, err := NewEmailCipher(key)
+	require.NoError(t, err)
+	return c
+}
+
+func TestEmailCipher(t *testing.T) {
+	req := require.New(t)
+	c := newTestEmailCipher(t)
+	encrypted, err := c.Encrypt("bob@google.com")
+	req.NoError(err)
+	req.True(strings.HasPrefix(encrypted, EncryptedEmailPrefix))
+	req.NotContains(encrypted, "bob")
+	again, err := c.Encrypt("bob@google.com")
+	req.NoError(err)
+	req.NotEqual(encrypted, again)
+	for _, value := range []string{encrypted, again} {
+		email, err := c.Decrypt(value)
+		req.NoError(err)
+		req.Equal("bob@google.com", email)
+	}
+	email, err := c.Decrypt("plain@google.com")
+	req.NoError(err)
+	req.Equal("plain@google.com", email)
+	empty, err := c.Encrypt("")
+	req.NoError(err)
+	req.Equal("", empty)
+
+	other, err := NewEmailCipher([]byte("0123456789abcdef0123456789abcdef"))
+	req.NoError(err)
+	_, err = other.Decrypt(encrypted)
+	req.Error(err)
+	_, err = c.Decrypt(EncryptedEmailPrefix + "AAAA")
+	req.Error(err)
+	_, err = NewEmailCipher([]byte("short"))
+	req.Error(err)
+}
+
+func TestLoadEmailCipher(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.key")
+	defer cleanup()
+	for _, content := range []string{testEmailKey + "\n", "AAECAwQFBgcICQoLDA0ODw=="} {
+		req.NoError(ioutil.WriteFile(f.Name(), []byte(content), 0600))
+		c, err := LoadEmailCipher(f.Name())
+		req.NoError(err)
+		encrypted, err := c.Encrypt("bob@google.com")
+		req.NoError(err)
+		email, err := newTestEmailCipher(t).Decrypt(encrypted)
+		req.NoError(err)
+		req.Equal("bob@google.com", email)
+	}
+	req.NoError(ioutil.WriteFile(f.Name(), []byte("not a key!"), 0600))
+	_, err := LoadEmailCipher(f.Name())
+	req.Error(err)
+}
+
+func TestPeopleEncryptEmails(t *testing.T) {
+	req := require.New(t)
+	c := newTestEmailCipher(t)
+	dir, err := ioutil.TempDir("", "idmatch-encrypt")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "identities.parquet")
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}},
+			Emails: []string{"bob@google.com", "bob@home.org"}, PrimaryName: "bob",
+			PrimaryEmail: "bob@google.com",
+			EmailSources: map[string][]SourceKind{"bob@google.com": {SourceGit}},
+			MergeEvidence: []IdentityEdge{{From: 1, To: 2, Evidence: []Evidence{
+				{EvidenceEmail, "bob@google.com", 1}, {EvidenceName, "bob", 1}}}}},
+	}
+	req.NoError(people.EncryptEmails(c))
+	person := people[1]
+	encrypted := append([]string{person.PrimaryEmail, person.MergeEvidence[0].Evidence[0].Value},
+		person.Emails...)
+	for _, email := range encrypted {
+		req.True(strings.HasPrefix(email, EncryptedEmailPrefix), email)
+	}
+	req.Equal("bob", person.MergeEvidence[0].Evidence[1].Value)
+	req.Contains(person.Emails, person.PrimaryEmail)
+	req.Contains(person.EmailSources, person.PrimaryEmail)
+	req.Equal(person.PrimaryEmail, person.MergeEvidence[0].Evidence[0].Value)
+	req.NoError(people.WriteToParquet(path, ""))
+
+	stored, _, err := ReadIdentitiesFromParquet(path, c)
+	req.NoError(err)
+	req.Equal([]string{"bob@google.com", "bob@home.org"}, stored[1].Emails)
+	req.Equal("bob@google.com", stored[1].PrimaryEmail)
+	stored, _, err = ReadIdentitiesFromParquet(path, nil)
+	req.NoError(err)
+	req.True(strings.HasPrefix(stored[1].PrimaryEmail, EncryptedEmailPrefix))
+}
+
+func TestDecryptCSV(t *testing.T) {
+	req := require.New(t)
+	c := newTestEmailCipher(t)
+	issues, err := EncryptEmailIssues([]EmailIssue{
+		{Email: "bob@gamil.com", Repaired: "bob@gmail.com"},
+		{Email: "bad@domen", Reason: "unknown top-level domain"},
+	}, c)
+	req.NoError(err)
+	req.Equal("", issues[1].Repaired)
+	input, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	output, cleanup2 := tempFile(t, "*.csv")
+	defer cleanup2()
+	req.NoError(WriteEmailIssues(input.Name(), issues))
+	content, err := ioutil.ReadFile(input.Name())
+	req.NoError(err)
+	req.NotContains(string(content), "bob")
+	req.NoError(DecryptCSV(input.Name(), output.Name(), c))
+	content, err = ioutil.ReadFile(output.Name())
+	req.NoError(err)
+	req.Equal(`email,repaired,reason
+bob@gamil.com,bob@gmail.com,
+bad@domen,,unknown top-level domain
+`, string(content))
+}
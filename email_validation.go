@@ -0,0 +1,244 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// EmailRepairs map the misspelled email domains to the correct ones, e.g. gamil.com -> gmail.com.
+type EmailRepairs map[string]string
+
+// NewEmailRepairs returns the built-in repairs of the common typos in the popular domains.
+func NewEmailRepairs() EmailRepairs {
+	return EmailRepairs{
+		"gamil.com":   "gmail.com",
+		"gmial.com":   "gmail.com",
+		"gmai.com":    "gmail.com",
+		"gmal.com":    "gmail.com",
+		"gmail.co":    "gmail.com",
+		"gmail.con":   "gmail.com",
+		"gmail.cmo":   "gmail.com",
+		"hotmial.com": "hotmail.com",
+		"hotmai.com":  "hotmail.com",
+		"hotmail.con": "hotmail.com",
+		"yaho.com":    "yahoo.com",
+		"yahoo.con":   "yahoo.com",
+		"outlok.com":  "outlook.com",
+		"outlook.con": "outlook.com",
+	}
+}
+
+// ReadEmailRepairs loads the repairs from a CSV file with the columns "domain" and "replacement".
+func ReadEmailRepairs(path string) (repairs EmailRepairs, err error) {
+	repairs = EmailRepairs{}
+	err = readCSVRecords(path, "email repairs", []string{"domain", "replacement"},
+		func(header map[string]int, record []string) error {
+			domain := strings.ToLower(strings.TrimSpace(record[header["domain"]]))
+			replacement := strings.ToLower(strings.TrimSpace(record[header["replacement"]]))
+			if domain == "" || replacement == "" {
+				return fmt.Errorf("invalid CSV record: %s", strings.Join(record, ","))
+			}
+			repairs[domain] = replacement
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return repairs, nil
+}
+
+// Merge returns the union of both repair sets, the other repairs take precedence.
+func (repairs EmailRepairs) Merge(other EmailRepairs) EmailRepairs {
+	result := EmailRepairs{}
+	for domain, replacement := range repairs {
+		result[domain] = replacement
+	}
+	for domain, replacement := range other {
+		result[domain] = replacement
+	}
+	return result
+}
+
+// EmailIssue is an email which EmailValidator repaired or found invalid.
+type EmailIssue struct {
+	Email string
+	// Repaired is the email after the repair, empty if it was not repaired.
+	Repaired string
+	// Reason tells why the email is invalid, empty if it is valid.
+	Reason string
+}
+
+// EmailValidator checks the emails of the signatures, see ExtractionOptions.EmailValidator.
+// The emails are checked once and the results are kept for Issues, so the validator is not safe
+// for concurrent use.
+type EmailValidator struct {
+	// Repairs are applied before the checks. nil disables the repairs.
+	Repairs EmailRepairs
+	// CheckTLD requires the top-level domain to be in the public suffix list.
+	CheckTLD bool
+	// CheckMX requires the domain to have the MX records. The lookups are cached per domain,
+	// and the failed ones other than "not found" leave the emails valid.
+	CheckMX bool
+	// Exclude removes the signatures with the invalid emails, otherwise they are only reported.
+	Exclude bool
+
+	lookupMX func(domain string) ([]*net.MX, error)
+	mx       map[string]bool
+	issues   map[string]EmailIssue
+}
+
+// NewEmailValidator returns the validator which repairs the built-in typos and checks
+// the syntax and the top-level domains.
+func NewEmailValidator() *EmailValidator {
+	return &EmailValidator{Repairs: NewEmailRepairs(), CheckTLD: true}
+}
+
+// Validate returns the repaired email and the reason why it is invalid, which is empty if
+// the email is valid.
+func (v *EmailValidator) Validate(email string) (string, string) {
+	if issue, exists := v.issues[email]; exists {
+		if issue.Repaired != "" {
+			return issue.Repaired, issue.Reason
+		}
+		return email, issue.Reason
+	}
+	issue := EmailIssue{Email: email}
+	repaired := email
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		if replacement, exists := v.Repairs[strings.ToLower(email[at+1:])]; exists {
+			repaired = email[:at+1] + replacement
+			issue.Repaired = repaired
+		}
+	}
+	issue.Reason = v.check(repaired)
+	if issue.Repaired != "" || issue.Reason != "" {
+		if v.issues == nil {
+			v.issues = map[string]EmailIssue{}
+		}
+		v.issues[email] = issue
+	}
+	return repaired, issue.Reason
+}
+
+// check returns the reason why the email is invalid or an empty string.
+func (v *EmailValidator) check(email string) string {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != strings.TrimSpace(email) {
+		return "invalid syntax"
+	}
+	domain := strings.ToLower(address.Address[strings.LastIndex(address.Address, "@")+1:])
+	if strings.HasPrefix(domain, "[") {
+		return ""
+	}
+	if v.CheckTLD {
+		tld := domain[strings.LastIndex(domain, ".")+1:]
+		if _, icann := publicsuffix.PublicSuffix(tld); !icann {
+			return "unknown top-level domain"
+		}
+	}
+	if v.CheckMX && !v.hasMX(domain) {
+		return "no MX records"
+	}
+	return ""
+}
+
+// hasMX looks up the MX records of the domain once.
+func (v *EmailValidator) hasMX(domain string) bool {
+	if exists, cached := v.mx[domain]; cached {
+		return exists
+	}
+	lookup := v.lookupMX
+	if lookup == nil {
+		lookup = net.LookupMX
+	}
+	records, err := lookup(domain)
+	exists := len(records) > 0
+	if err != nil {
+		dnsErr, ok := err.(*net.DNSError)
+		exists = !ok || !dnsErr.IsNotFound
+		if exists {
+			reporter.Warnf("failed to look up the MX records of %s: %v", domain, err)
+		}
+	}
+	if v.mx == nil {
+		v.mx = map[string]bool{}
+	}
+	v.mx[domain] = exists
+	return exists
+}
+
+// validate repairs the emails of the signatures and reports the invalid ones, which are removed
+// if Exclude is set.
+func (v *EmailValidator) validate(commits []Signature) []Signature {
+	result := commits[:0]
+	invalid, repaired := 0, 0
+	for _, commit := range commits {
+		email, reason := v.Validate(commit.Email)
+		if email != commit.Email {
+			repaired++
+			commit.Email = email
+		}
+		if reason != "" {
+			invalid++
+			if v.Exclude {
+				continue
+			}
+		}
+		result = append(result, commit)
+	}
+	reporter.Commit("signatures with invalid emails", invalid)
+	reporter.Commit("signatures with repaired emails", repaired)
+	return result
+}
+
+// Issues returns the repaired and the invalid emails sorted by the email.
+func (v *EmailValidator) Issues() []EmailIssue {
+	issues := make([]EmailIssue, 0, len(v.issues))
+	for _, issue := range v.issues {
+		issues = append(issues, issue)
+	}
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Email < issues[j].Email })
+	return issues
+}
+
+// WriteEmailIssues stores the issues in a CSV file with the columns "email", "repaired" and
+// "reason".
+func WriteEmailIssues(path string, issues []EmailIssue) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write([]string{"email", "repaired", "reason"}); err != nil {
+		return
+	}
+	for _, issue := range issues {
+		if err = writer.Write([]string{issue.Email, issue.Repaired, issue.Reason}); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,125 @@
+package idmatch
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailValidatorValidate(t *testing.T) {
+	req := require.New(t)
+	validator := NewEmailValidator()
+	for _, testCase := range []struct {
+		email    string
+		repaired string
+		reason   string
+	}{
+		{"bob@google.com", "bob@google.com", ""},
+		{"bob@gamil.com", "bob@gmail.com", ""},
+		{"bad-email@domen", "bad-email@domen", "unknown top-level domain"},
+		{"bob@gmail.con", "bob@gmail.com", ""},
+		{"bob", "bob", "invalid syntax"},
+		{"Bob <bob@google.com>", "Bob <bob@google.com>", "invalid syntax"},
+		{"bob@[127.0.0.1]", "bob@[127.0.0.1]", ""},
+	} {
+		repaired, reason := validator.Validate(testCase.email)
+		req.Equal(testCase.repaired, repaired, testCase.email)
+		req.Equal(testCase.reason, reason, testCase.email)
+	}
+	req.Equal([]EmailIssue{
+		{"Bob <bob@google.com>", "", "invalid syntax"},
+		{"bad-email@domen", "", "unknown top-level domain"},
+		{"bob", "", "invalid syntax"},
+		{"bob@gamil.com", "bob@gmail.com", ""},
+		{"bob@gmail.con", "bob@gmail.com", ""},
+	}, validator.Issues())
+
+	validator = &EmailValidator{}
+	repaired, reason := validator.Validate("bad-email@domen")
+	req.Equal("bad-email@domen", repaired)
+	req.Empty(reason)
+	req.Empty(validator.Issues())
+}
+
+func TestEmailValidatorMX(t *testing.T) {
+	req := require.New(t)
+	lookups := 0
+	validator := &EmailValidator{CheckMX: true, lookupMX: func(domain string) ([]*net.MX, error) {
+		lookups++
+		switch domain {
+		case "google.com":
+			return []*net.MX{{Host: "smtp.google.com", Pref: 10}}, nil
+		case "broken.org":
+			return nil, errors.New("timeout")
+		default:
+			return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+		}
+	}}
+	_, reason := validator.Validate("bob@google.com")
+	req.Empty(reason)
+	_, reason = validator.Validate("alice@google.com")
+	req.Empty(reason)
+	_, reason = validator.Validate("bob@nowhere.org")
+	req.Equal("no MX records", reason)
+	_, reason = validator.Validate("bob@broken.org")
+	req.Empty(reason)
+	req.Equal(3, lookups)
+}
+
+func TestReadEmailRepairs(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(ioutil.WriteFile(f.Name(), []byte("domain,replacement\nGoogel.com, google.com\n"), 0666))
+	repairs, err := ReadEmailRepairs(f.Name())
+	req.NoError(err)
+	req.Equal(EmailRepairs{"googel.com": "google.com"}, repairs)
+	merged := NewEmailRepairs().Merge(repairs)
+	req.Equal("google.com", merged["googel.com"])
+	req.Equal("gmail.com", merged["gamil.com"])
+
+	for _, content := range []string{"domain\ngamil.com\n", "domain,replacement\ngamil.com,\n"} {
+		req.NoError(ioutil.WriteFile(f.Name(), []byte(content), 0666))
+		_, err = ReadEmailRepairs(f.Name())
+		req.Error(err, content)
+	}
+}
+
+func TestWriteEmailIssues(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(WriteEmailIssues(f.Name(), []EmailIssue{
+		{"bad-email@domen", "", "unknown top-level domain"},
+		{"bob@gamil.com", "bob@gmail.com", ""},
+	}))
+	content, err := ioutil.ReadFile(f.Name())
+	req.NoError(err)
+	req.Equal(`email,repaired,reason
+bad-email@domen,,unknown top-level domain
+bob@gamil.com,bob@gmail.com,
+`, string(content))
+}
+
+func TestPeopleFromSignaturesEmailValidation(t *testing.T) {
+	req := require.New(t)
+	signatures := []Signature{
+		{Repo: "repo1", Name: "Bob", Email: "bob@gamil.com", Hash: "aaa"},
+		{Repo: "repo1", Name: "Alice", Email: "alice@google.com", Hash: "bbb"},
+		{Repo: "repo1", Name: "Eve", Email: "eve@domen", Hash: "ccc"},
+	}
+	validator := NewEmailValidator()
+	validator.Exclude = true
+	people, _, emailFreqs, err := PeopleFromSignatures(context.Background(), signatures,
+		ExtractionOptions{EmailValidator: validator}, newTestBlacklist(t), PopularityThresholds{},
+		BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	req.Len(people, 2)
+	req.Contains(emailFreqs, "bob@gmail.com")
+	req.NotContains(emailFreqs, "eve@domen")
+	req.Len(validator.Issues(), 2)
+}
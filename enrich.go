@@ -0,0 +1,52 @@
+package idmatch
+
+import (
+	"context"
+
+	"github.com/src-d/identity-matching/external"
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// EnrichPeople sets the external ID of the provider, e.g. "github", to the people who do not have
+// it yet by querying the external matcher, without merging any identities. This way
+// the identities of a previous run gain the external ids without matching them again, also
+// the ids of another provider. The emails of each person are queried in order and the first
+// match wins. It returns the number of the enriched people.
+func EnrichPeople(ctx context.Context, people People, matcher external.Matcher,
+	provider string) (int, error) {
+	matcher = prefetchCommitMatches(ctx, people, matcher, func(person *Person) bool {
+		_, exists := person.ExternalIDs[provider]
+		return exists
+	})
+	enriched := 0
+	for _, id := range peopleIDs(people) {
+		if err := ctx.Err(); err != nil {
+			return enriched, err
+		}
+		person := people[id]
+		if _, exists := person.ExternalIDs[provider]; exists {
+			continue
+		}
+		for _, email := range person.Emails {
+			var username string
+			var err error
+			if matcher.SupportsMatchingByCommit() && person.SampleCommit != nil {
+				username, err = matcher.MatchByCommit(
+					ctx, email, person.SampleCommit.Repo, person.SampleCommit.Hash)
+			} else {
+				username, err = matcher.MatchByEmail(ctx, email)
+			}
+			if err != nil && err != external.ErrNoMatches {
+				reporter.Errorf("unexpected error for person %s: %v", person.String(), err)
+				continue
+			}
+			if err == nil && username != "" {
+				person.ExternalIDs = person.ExternalIDs.merge(ExternalIDs{provider: username})
+				enriched++
+				reporter.Increment("enriched people")
+				break
+			}
+		}
+	}
+	return enriched, matcher.OnIdle()
+}
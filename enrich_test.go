@@ -0,0 +1,33 @@
+package idmatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrichPeople(t *testing.T) {
+	req := require.New(t)
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"Bob", ""}},
+			Emails: []string{"bob@google.com", "Bob@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"Alice", ""}}, Emails: []string{"alice@google.com"},
+			ExternalIDs: ExternalIDs{"github": "alice"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"Eve", ""}}, Emails: []string{"eve@google.com"}},
+	}
+	enriched, err := EnrichPeople(context.Background(), people, TestMatcher{}, "github")
+	req.NoError(err)
+	req.Equal(1, enriched)
+	req.Equal("bob_username", people[1].ExternalIDs["github"])
+	req.Equal("alice", people[2].ExternalIDs["github"])
+	req.Nil(people[3].ExternalIDs)
+	req.Len(people, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	people[1].ExternalIDs = nil
+	_, err = EnrichPeople(ctx, people, TestMatcher{}, "github")
+	req.Equal(context.Canceled, err)
+	req.Nil(people[1].ExternalIDs)
+}
@@ -0,0 +1,374 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// Suppressions are the emails and the names which were erased on request, e.g. to honor
+// the right to be forgotten. They are kept in a file so that the signatures with them are
+// dropped in every subsequent run, see ExtractionOptions.Suppressions.
+type Suppressions struct {
+	Emails map[string]struct{}
+	Names  map[string]struct{}
+}
+
+// NewSuppressions cleans the identifiers the same way as the signatures. The identifiers
+// with "@" are emails and the rest are names.
+func NewSuppressions(identifiers ...string) (Suppressions, error) {
+	s := Suppressions{Emails: map[string]struct{}{}, Names: map[string]struct{}{}}
+	for _, identifier := range identifiers {
+		if strings.TrimSpace(identifier) == "" {
+			continue
+		}
+		if strings.Contains(identifier, "@") {
+			email, err := cleanEmail(identifier)
+			if err != nil {
+				return Suppressions{}, err
+			}
+			s.Emails[email] = struct{}{}
+			continue
+		}
+		name, err := cleanName(identifier)
+		if err != nil {
+			return Suppressions{}, err
+		}
+		s.Names[name] = struct{}{}
+	}
+	return s, nil
+}
+
+// ReadSuppressions loads the suppressions from a CSV file with the columns "kind" and "value",
+// where the kind is "email" or "name".
+func ReadSuppressions(path string) (s Suppressions, err error) {
+	s = Suppressions{Emails: map[string]struct{}{}, Names: map[string]struct{}{}}
+	err = readCSVRecords(path, "suppressions", []string{"kind", "value"},
+		func(header map[string]int, record []string) error {
+			switch kind := strings.TrimSpace(record[header["kind"]]); kind {
+			case "email":
+				s.Emails[record[header["value"]]] = struct{}{}
+			case "name":
+				s.Names[record[header["value"]]] = struct{}{}
+			default:
+				return fmt.Errorf("unknown suppression kind: %s", kind)
+			}
+			return nil
+		})
+	if err != nil {
+		return Suppressions{}, err
+	}
+	return s, nil
+}
+
+// Write stores the suppressions in a CSV file with the columns "kind" and "value".
+func (s Suppressions) Write(path string) (err error) {
+	var file *os.File
+	file, err = os.Create(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write([]string{"kind", "value"}); err != nil {
+		return
+	}
+	for _, kind := range []struct {
+		name   string
+		values map[string]struct{}
+	}{{"email", s.Emails}, {"name", s.Names}} {
+		for _, value := range sortedKeys(kind.values) {
+			if err = writer.Write([]string{kind.name, value}); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+// Merge returns the union of both suppressions.
+func (s Suppressions) Merge(other Suppressions) Suppressions {
+	union := func(sets ...map[string]struct{}) map[string]struct{} {
+		result := map[string]struct{}{}
+		for _, set := range sets {
+			for key := range set {
+				result[key] = struct{}{}
+			}
+		}
+		return result
+	}
+	return Suppressions{Emails: union(s.Emails, other.Emails), Names: union(s.Names, other.Names)}
+}
+
+// Len returns the number of the suppressed emails and names.
+func (s Suppressions) Len() int {
+	return len(s.Emails) + len(s.Names)
+}
+
+func (s Suppressions) isSuppressedEmail(email string) bool {
+	_, exists := s.Emails[email]
+	return exists
+}
+
+func (s Suppressions) isSuppressedName(name string) bool {
+	_, exists := s.Names[name]
+	return exists
+}
+
+// filter removes the signatures with the suppressed emails or names.
+func (s Suppressions) filter(commits []Signature) ([]Signature, error) {
+	if s.Len() == 0 {
+		return commits, nil
+	}
+	result := commits[:0]
+	for _, commit := range commits {
+		email, err := cleanEmail(commit.Email)
+		if err != nil {
+			return nil, err
+		}
+		name, err := cleanName(commit.Name)
+		if err != nil {
+			return nil, err
+		}
+		if s.isSuppressedEmail(email) || s.isSuppressedName(name) {
+			reporter.Increment("suppressed signatures")
+			continue
+		}
+		result = append(result, commit)
+	}
+	return result, nil
+}
+
+// Erase removes the given emails and names from the people, see NewSuppressions about
+// the identifiers. The people who are left without emails and names are deleted.
+// It returns the number of the removed emails and names.
+func (p People) Erase(identifiers ...string) (int, error) {
+	s, err := NewSuppressions(identifiers...)
+	if err != nil {
+		return 0, err
+	}
+	return p.erase(s), nil
+}
+
+func (p People) erase(s Suppressions) int {
+	erased := 0
+	for id, person := range p {
+		emails := person.Emails[:0]
+		for _, email := range person.Emails {
+			if s.isSuppressedEmail(email) {
+				delete(person.EmailSources, email)
+				delete(person.AliasHistory.Emails, email)
+				erased++
+				continue
+			}
+			emails = append(emails, email)
+		}
+		person.Emails = emails
+		names := person.NamesWithRepos[:0]
+		for _, name := range person.NamesWithRepos {
+			if s.isSuppressedName(name.Name) {
+				delete(person.NameSources, name.Name)
+				delete(person.AliasHistory.Names, name.Name)
+				erased++
+				continue
+			}
+			names = append(names, name)
+		}
+		person.NamesWithRepos = names
+		if len(person.Emails) == 0 && len(person.NamesWithRepos) == 0 {
+			delete(p, id)
+			continue
+		}
+		if s.isSuppressedEmail(person.PrimaryEmail) {
+			person.PrimaryEmail = ""
+			if len(person.Emails) > 0 {
+				person.PrimaryEmail = person.Emails[0]
+			}
+		}
+		if s.isSuppressedName(person.PrimaryName) {
+			person.PrimaryName = ""
+			if len(person.NamesWithRepos) > 0 {
+				person.PrimaryName = person.NamesWithRepos[0].Name
+			}
+		}
+		for i := range person.MergeEvidence {
+			edge := &person.MergeEvidence[i]
+			evidence := edge.Evidence[:0]
+			for _, ev := range edge.Evidence {
+				if s.isSuppressedEmail(ev.Value) || s.isSuppressedName(ev.Value) {
+					continue
+				}
+				evidence = append(evidence, ev)
+			}
+			edge.Evidence = evidence
+		}
+	}
+	return erased
+}
+
+// EraseSignatureCache removes the signatures with the suppressed emails or names from the CSV
+// or the parquet signatures cache of FindPeople; the commits tables are left intact. The partial
+// extraction next to the cache is deleted together with its checkpoint, so that the interrupted
+// extraction starts over. The chunks of the cache are rewritten one by one. It returns
+// the number of the removed signatures.
+func EraseSignatureCache(path string, s Suppressions) (int, error) {
+	partialPath, checkpointPath := checkpointPaths(path)
+	for _, extra := range []string{partialPath, checkpointPath} {
+		if err := os.Remove(extra); err != nil && !os.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	paths, err := signatureCachePaths(path)
+	if err != nil {
+		return 0, err
+	}
+	erased := 0
+	for _, path := range paths {
+		writable, err := isWritableSignatureCache(path)
+		if err != nil {
+			return erased, err
+		}
+		if !writable {
+			reporter.Warnf("the commits table %s is not erased", path)
+			continue
+		}
+		commits, err := readSignatureFile(nil, path, nil)
+		if err != nil {
+			return erased, err
+		}
+		size := len(commits)
+		if commits, err = s.filter(commits); err != nil {
+			return erased, err
+		}
+		if len(commits) == size {
+			continue
+		}
+		if err = storeSignatureFile(path, commits); err != nil {
+			return erased, err
+		}
+		erased += size - len(commits)
+	}
+	return erased, nil
+}
+
+// EraseIdentities removes the suppressed emails and names from the identities stored with
+// People.WriteToParquet. The merge evidence stored next to them is deleted because it refers to
+// the erased values. It returns the number of the removed emails and names, and the error
+// which satisfies os.IsNotExist if the identities are not stored. The partitioned identities of
+// People.WritePartitionedParquet are rewritten with the same partition size. The emails which
+// were encrypted with People.EncryptEmails are decrypted with c, erased and encrypted again;
+// the encrypted emails are refused if c is nil because they never equal the suppressed ones.
+func EraseIdentities(path string, s Suppressions, c *EmailCipher) (int, error) {
+	if err := os.Remove(mergesPath(path)); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	if isPartitionedParquet(path) {
+		return erasePartitionedIdentities(path, s, c)
+	}
+	pathAliases, pathIDs := preparePaths(path)
+	for _, stored := range []string{pathAliases, pathIDs} {
+		if _, err := os.Stat(stored); err != nil {
+			return 0, err
+		}
+	}
+	people, provider, err := readFromParquet(path)
+	if err != nil {
+		return 0, err
+	}
+	erased, err := people.eraseEncrypted(s, c)
+	if erased == 0 || err != nil {
+		return 0, err
+	}
+	return erased, people.WriteToParquet(path, provider)
+}
+
+// eraseEncrypted is erase for the people whose emails may be encrypted with c, see
+// EraseIdentities. The emails are encrypted again only if something was erased.
+func (p People) eraseEncrypted(s Suppressions, c *EmailCipher) (int, error) {
+	if c == nil {
+		for _, person := range p {
+			for _, email := range person.Emails {
+				if strings.HasPrefix(email, EncryptedEmailPrefix) {
+					return 0, errors.New("the emails of the identities are encrypted, " +
+						"the key is required to erase them")
+				}
+			}
+		}
+		return p.erase(s), nil
+	}
+	if err := p.DecryptEmails(c); err != nil {
+		return 0, err
+	}
+	erased := p.erase(s)
+	if erased == 0 {
+		return 0, nil
+	}
+	return erased, p.EncryptEmails(c)
+}
+
+func erasePartitionedIdentities(dir string, s Suppressions, c *EmailCipher) (int, error) {
+	manifest, err := ReadPartitionManifest(dir)
+	if err != nil {
+		return 0, err
+	}
+	people, provider, err := readPartitionedParquet(dir, 0)
+	if err != nil {
+		return 0, err
+	}
+	erased, err := people.eraseEncrypted(s, c)
+	if erased == 0 || err != nil {
+		return 0, err
+	}
+	opts := PartitionOptions{}
+	for _, partition := range manifest.Partitions {
+		if partition.People > opts.PeoplePerPartition {
+			opts.PeoplePerPartition = partition.People
+		}
+	}
+	if opts.PeoplePerPartition == 0 {
+		opts.PeoplePerPartition = 1
+	}
+	if err = people.WritePartitionedParquet(dir, provider, opts); err != nil {
+		return erased, err
+	}
+	// the people may be removed, then the last partitions are not needed anymore
+	written, err := ReadPartitionManifest(dir)
+	if err != nil {
+		return erased, err
+	}
+	for _, partition := range manifest.Partitions[len(written.Partitions):] {
+		pathAliases, pathIDs := preparePaths(partitionPath(dir, partition.Path))
+		for _, stale := range []string{pathAliases, pathIDs} {
+			if err = os.Remove(stale); err != nil && !os.IsNotExist(err) {
+				return erased, err
+			}
+		}
+	}
+	return erased, nil
+}
+
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
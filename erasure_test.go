@@ -0,0 +1,204 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSuppressions(t *testing.T) {
+	req := require.New(t)
+	s, err := NewSuppressions(" Bob@Google.com ", "Łukasz  Nowak", "")
+	req.NoError(err)
+	req.Equal(Suppressions{
+		Emails: map[string]struct{}{"bob@google.com": {}},
+		Names:  map[string]struct{}{"lukasz nowak": {}},
+	}, s)
+	req.Equal(2, s.Len())
+}
+
+func TestReadWriteSuppressions(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	s, err := NewSuppressions("bob@google.com", "alice")
+	req.NoError(err)
+	other, err := NewSuppressions("eve")
+	req.NoError(err)
+	s = s.Merge(other)
+	req.NoError(s.Write(f.Name()))
+	content, err := ioutil.ReadFile(f.Name())
+	req.NoError(err)
+	req.Equal("kind,value\nemail,bob@google.com\nname,alice\nname,eve\n", string(content))
+	read, err := ReadSuppressions(f.Name())
+	req.NoError(err)
+	req.Equal(s, read)
+
+	for _, content := range []string{"kind\nemail\n", "kind,value\nphone,123\n"} {
+		req.NoError(ioutil.WriteFile(f.Name(), []byte(content), 0666))
+		_, err = ReadSuppressions(f.Name())
+		req.Error(err, content)
+	}
+}
+
+func TestPeopleErase(t *testing.T) {
+	req := require.New(t)
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}, {"robert", ""}},
+			Emails: []string{"bob@google.com", "bob@home.org"}, PrimaryName: "robert",
+			PrimaryEmail: "bob@google.com",
+			EmailSources: map[string][]SourceKind{"bob@google.com": {SourceGit}},
+			MergeEvidence: []IdentityEdge{{From: 1, To: 2, Evidence: []Evidence{
+				{EvidenceEmail, "bob@google.com", 1}, {EvidenceName, "bob", 1}}}}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"eve", ""}}, Emails: []string{"eve@google.com"}},
+	}
+	erased, err := people.Erase("Bob@google.com", "Robert", "eve", "eve@google.com")
+	req.NoError(err)
+	req.Equal(4, erased)
+	req.Equal(People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@home.org"},
+			PrimaryName: "bob", PrimaryEmail: "bob@home.org", EmailSources: map[string][]SourceKind{},
+			MergeEvidence: []IdentityEdge{{From: 1, To: 2, Evidence: []Evidence{
+				{EvidenceName, "bob", 1}}}}},
+	}, people)
+}
+
+func TestFindPeopleSuppressions(t *testing.T) {
+	req := require.New(t)
+	cache, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(storeSignaturesOnDisk(cache.Name(), Signatures))
+	extraction := ExtractionOptions{}
+	var err error
+	extraction.Suppressions, err = NewSuppressions("alice@google.com")
+	req.NoError(err)
+	people, _, _, err := FindPeople(context.Background(), "", cache.Name(), extraction,
+		newTestBlacklist(t), PopularityThresholds{}, BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	for _, person := range people {
+		req.NotContains(person.Emails, "alice@google.com")
+	}
+	cached, err := readSignaturesFromDisk(nil, cache.Name())
+	req.NoError(err)
+	req.Len(cached, len(Signatures)-1)
+}
+
+func TestEraseSignatureCache(t *testing.T) {
+	req := require.New(t)
+	cache, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(storeSignaturesOnDisk(cache.Name(), Signatures))
+	partialPath, checkpointPath := checkpointPaths(cache.Name())
+	req.NoError(ioutil.WriteFile(checkpointPath, []byte("{}"), 0666))
+	s, err := NewSuppressions("bob")
+	req.NoError(err)
+	erased, err := EraseSignatureCache(cache.Name(), s)
+	req.NoError(err)
+	req.Equal(4, erased)
+	cached, err := readSignaturesFromDisk(nil, cache.Name())
+	req.NoError(err)
+	req.Len(cached, len(Signatures)-4)
+	for _, path := range []string{partialPath, checkpointPath} {
+		_, err = os.Stat(path)
+		req.True(os.IsNotExist(err))
+	}
+}
+
+func TestEraseIdentities(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-erase")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "identities.parquet")
+	s, err := NewSuppressions("bob@google.com")
+	req.NoError(err)
+	_, err = EraseIdentities(path, s, nil)
+	req.True(os.IsNotExist(err))
+
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}},
+			Emails: []string{"bob@google.com", "bob@home.org"}, PrimaryName: "bob",
+			PrimaryEmail: "bob@google.com"},
+	}
+	req.NoError(people.WriteToParquet(path, ""))
+	req.NoError(people.WriteMergesToParquet(path))
+	erased, err := EraseIdentities(path, s, nil)
+	req.NoError(err)
+	req.Equal(1, erased)
+	stored, _, err := readFromParquet(path)
+	req.NoError(err)
+	req.Equal([]string{"bob@home.org"}, stored[1].Emails)
+	req.Equal("bob@home.org", stored[1].PrimaryEmail)
+	_, err = os.Stat(mergesPath(path))
+	req.True(os.IsNotExist(err))
+}
+
+func TestEraseEncryptedIdentities(t *testing.T) {
+	req := require.New(t)
+	c := newTestEmailCipher(t)
+	dir, err := ioutil.TempDir("", "idmatch-erase")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "identities.parquet")
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}},
+			Emails: []string{"bob@google.com", "bob@home.org"}, PrimaryName: "bob",
+			PrimaryEmail: "bob@google.com"},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}},
+			Emails: []string{"alice@google.com"}, PrimaryName: "alice",
+			PrimaryEmail: "alice@google.com"},
+	}
+	req.NoError(people.EncryptEmails(c))
+	req.NoError(people.WriteToParquet(path, ""))
+	s, err := NewSuppressions("bob@google.com")
+	req.NoError(err)
+
+	_, err = EraseIdentities(path, s, nil)
+	req.Error(err)
+	erased, err := EraseIdentities(path, s, c)
+	req.NoError(err)
+	req.Equal(1, erased)
+	stored, _, err := readFromParquet(path)
+	req.NoError(err)
+	for _, email := range stored[1].Emails {
+		req.True(strings.HasPrefix(email, EncryptedEmailPrefix), email)
+	}
+	stored, _, err = ReadIdentitiesFromParquet(path, c)
+	req.NoError(err)
+	req.Equal([]string{"bob@home.org"}, stored[1].Emails)
+	req.Equal("bob@home.org", stored[1].PrimaryEmail)
+	req.Equal([]string{"alice@google.com"}, stored[2].Emails)
+}
+
+func TestErasePartitionedIdentities(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-erase")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+		3: {ID: 3, Emails: []string{"eve@google.com"}},
+	}
+	req.NoError(people.WritePartitionedParquet(dir, "", PartitionOptions{PeoplePerPartition: 2}))
+	s, err := NewSuppressions("eve@google.com")
+	req.NoError(err)
+	erased, err := EraseIdentities(dir, s, nil)
+	req.NoError(err)
+	req.Equal(1, erased)
+	manifest, err := ReadPartitionManifest(dir)
+	req.NoError(err)
+	req.Len(manifest.Partitions, 1)
+	req.Equal(2, manifest.People)
+	stored, _, err := ReadIdentitiesFromParquet(dir, nil)
+	req.NoError(err)
+	req.Len(stored, 2)
+	_, pathIDs := preparePaths(filepath.Join(dir, "part-00001.parquet"))
+	_, err = os.Stat(pathIDs)
+	req.True(os.IsNotExist(err))
+}
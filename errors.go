@@ -0,0 +1,51 @@
+package idmatch
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The kinds of the errors which the callers may handle differently. The returned errors wrap
+// them, so errors.Is(err, ErrSourceUnavailable) tells the transient failures which are worth
+// retrying from the others.
+var (
+	// ErrExternalIDConflict means that the identities with different external IDs of the same
+	// provider would be merged, see ExternalIDOptions.
+	ErrExternalIDConflict = errors.New("conflicting external IDs")
+	// ErrSourceUnavailable means that the signature source, e.g. gitbase or the GitHub API, or
+	// the external matcher kept failing with the transient errors after all the retries, see
+	// external.ErrUnavailable. The local sources such as git and mbox are never retried.
+	ErrSourceUnavailable = errors.New("the signature source is unavailable")
+	// ErrInvalidSignature means that a signature or the file with the signatures is malformed.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrBlacklisted means that the name or the email of a signature is ignored by the blacklist,
+	// see Blacklist.CheckSignature.
+	ErrBlacklisted = errors.New("blacklisted")
+)
+
+// kindError is the error of one of the kinds above which keeps the original error as the cause,
+// so that errors.Is matches both.
+type kindError struct {
+	kind error
+	err  error
+}
+
+// withKind wraps the error with the kind. nil stays nil.
+func withKind(kind, err error) error {
+	if err == nil || errors.Is(err, kind) {
+		return err
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+func (e *kindError) Error() string {
+	return fmt.Sprintf("%v: %v", e.kind, e.err)
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
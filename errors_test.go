@@ -0,0 +1,44 @@
+package idmatch
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithKind(t *testing.T) {
+	req := require.New(t)
+	req.NoError(withKind(ErrInvalidSignature, nil))
+	cause := errors.New("bad name")
+	err := withKind(ErrInvalidSignature, cause)
+	req.EqualError(err, "invalid signature: bad name")
+	req.ErrorIs(err, ErrInvalidSignature)
+	req.ErrorIs(err, cause)
+	req.False(errors.Is(err, ErrBlacklisted))
+	wrapped := fmt.Errorf("%w: bad name", ErrInvalidSignature)
+	req.Equal(wrapped, withKind(ErrInvalidSignature, wrapped))
+}
+
+func TestBlacklistCheckSignature(t *testing.T) {
+	req := require.New(t)
+	blacklist := newTestBlacklist(t)
+	req.NoError(blacklist.CheckSignature(Signature{Name: "Bob", Email: "bob@google.com"}))
+	err := blacklist.CheckSignature(Signature{Name: "Bob", Email: "Bob@Example.com"})
+	req.EqualError(err, "blacklisted: the email bob@example.com is ignored")
+	req.ErrorIs(err, ErrBlacklisted)
+	err = blacklist.CheckSignature(Signature{Name: "Admin", Email: "bob@google.com"})
+	req.EqualError(err, "blacklisted: the name admin is ignored")
+	req.ErrorIs(err, ErrBlacklisted)
+}
+
+func TestReadSignatureFileInvalid(t *testing.T) {
+	req := require.New(t)
+	file, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := file.WriteString("repo,name\nrepo1,bob\n")
+	req.NoError(err)
+	_, err = readSignatureFile(nil, file.Name(), nil)
+	req.ErrorIs(err, ErrInvalidSignature)
+}
@@ -0,0 +1,161 @@
+package idmatch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Metrics are the quality of the identity matching measured against the ground truth,
+// see Evaluate.
+type Metrics struct {
+	// Items is the number of the ground truth signatures found in the people.
+	Items int
+	// Missing is the number of the ground truth signatures which are not found in the people.
+	Missing int
+	// Identities is the number of the distinct persons of the found signatures.
+	Identities int
+	// TrueIdentities is the number of the distinct ground truth labels of the found signatures.
+	TrueIdentities int
+	// PairwisePrecision is the share of the pairs of signatures merged into the same person which
+	// have the same label.
+	PairwisePrecision float64
+	// PairwiseRecall is the share of the pairs of signatures with the same label which are merged
+	// into the same person.
+	PairwiseRecall float64
+	// PairwiseF1 is the harmonic mean of PairwisePrecision and PairwiseRecall.
+	PairwiseF1 float64
+	// BCubedPrecision is the mean share of the signatures with the same label in the person of
+	// each signature.
+	BCubedPrecision float64
+	// BCubedRecall is the mean share of the signatures in the same person among the signatures with
+	// the same label as each signature.
+	BCubedRecall float64
+	// BCubedF1 is the harmonic mean of BCubedPrecision and BCubedRecall.
+	BCubedF1 float64
+}
+
+// String formats the metrics as the human-readable report.
+func (m Metrics) String() string {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "signatures:          %d (%d missing)\n", m.Items, m.Missing)
+	fmt.Fprintf(builder, "identities:          %d (%d true)\n", m.Identities, m.TrueIdentities)
+	fmt.Fprintf(builder, "pairwise precision:  %.4f\n", m.PairwisePrecision)
+	fmt.Fprintf(builder, "pairwise recall:     %.4f\n", m.PairwiseRecall)
+	fmt.Fprintf(builder, "pairwise F1:         %.4f\n", m.PairwiseF1)
+	fmt.Fprintf(builder, "B-cubed precision:   %.4f\n", m.BCubedPrecision)
+	fmt.Fprintf(builder, "B-cubed recall:      %.4f\n", m.BCubedRecall)
+	fmt.Fprintf(builder, "B-cubed F1:          %.4f\n", m.BCubedF1)
+	return builder.String()
+}
+
+// Evaluate compares the people with the ground truth which maps the signatures to the true
+// identity labels. Only the names and the emails of the keys matter. A signature belongs to
+// the person which has both its name and its email; if there are several, to the one with
+// the smallest ID.
+func Evaluate(people People, truth map[signatureKey]string) Metrics {
+	ids := make([]int64, 0, len(people))
+	for id := range people {
+		ids = append(ids, id)
+	}
+	Int64Slice(ids).Sort()
+	index := map[signatureKey]int64{}
+	for _, id := range ids {
+		for _, name := range people[id].NamesWithRepos {
+			for _, email := range people[id].Emails {
+				key := signatureKey{name: name.Name, email: email}
+				if _, exists := index[key]; !exists {
+					index[key] = id
+				}
+			}
+		}
+	}
+	type cell struct {
+		id    int64
+		label string
+	}
+	var items []cell
+	metrics := Metrics{}
+	for key, label := range truth {
+		id, exists := index[signatureKey{name: key.name, email: key.email}]
+		if !exists {
+			metrics.Missing++
+			continue
+		}
+		items = append(items, cell{id, label})
+	}
+	metrics.Items = len(items)
+	if len(items) == 0 {
+		return metrics
+	}
+	predicted := map[int64]int{}
+	actual := map[string]int{}
+	cells := map[cell]int{}
+	for _, item := range items {
+		predicted[item.id]++
+		actual[item.label]++
+		cells[item]++
+	}
+	metrics.Identities = len(predicted)
+	metrics.TrueIdentities = len(actual)
+	pairs := func(n int) int {
+		return n * (n - 1) / 2
+	}
+	truePairs, predictedPairs, actualPairs := 0, 0, 0
+	for _, count := range cells {
+		truePairs += pairs(count)
+	}
+	for _, count := range predicted {
+		predictedPairs += pairs(count)
+	}
+	for _, count := range actual {
+		actualPairs += pairs(count)
+	}
+	metrics.PairwisePrecision = 1
+	if predictedPairs > 0 {
+		metrics.PairwisePrecision = float64(truePairs) / float64(predictedPairs)
+	}
+	metrics.PairwiseRecall = 1
+	if actualPairs > 0 {
+		metrics.PairwiseRecall = float64(truePairs) / float64(actualPairs)
+	}
+	metrics.PairwiseF1 = harmonicMean(metrics.PairwisePrecision, metrics.PairwiseRecall)
+	for _, item := range items {
+		metrics.BCubedPrecision += float64(cells[item]) / float64(predicted[item.id])
+		metrics.BCubedRecall += float64(cells[item]) / float64(actual[item.label])
+	}
+	metrics.BCubedPrecision /= float64(len(items))
+	metrics.BCubedRecall /= float64(len(items))
+	metrics.BCubedF1 = harmonicMean(metrics.BCubedPrecision, metrics.BCubedRecall)
+	return metrics
+}
+
+func harmonicMean(x, y float64) float64 {
+	if x+y == 0 {
+		return 0
+	}
+	return 2 * x * y / (x + y)
+}
+
+// ReadGroundTruth loads the ground truth for Evaluate from the CSV file with the columns
+// name, email and identity. The names and the emails are normalized the same way as
+// the signatures.
+func ReadGroundTruth(path string) (truth map[signatureKey]string, err error) {
+	truth = map[signatureKey]string{}
+	err = readCSVRecords(path, "ground truth", []string{"name", "email", "identity"},
+		func(header map[string]int, record []string) error {
+			name, err := normalizeSignatureValue(record[header["name"]])
+			if err != nil {
+				return err
+			}
+			email, err := normalizeSignatureValue(record[header["email"]])
+			if err != nil {
+				return err
+			}
+			truth[signatureKey{name: name, email: email}] = strings.TrimSpace(record[header["identity"]])
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return truth, nil
+}
@@ -0,0 +1,74 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	req := require.New(t)
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}, {"mallory", ""}},
+			Emails: []string{"bob@google.com", "mallory@google.com", "robert@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"al", ""}}, Emails: []string{"al@google.com"}},
+	}
+	truth := map[signatureKey]string{
+		{name: "bob", email: "bob@google.com"}:         "bob",
+		{name: "bob", email: "robert@google.com"}:      "bob",
+		{name: "mallory", email: "mallory@google.com"}: "mallory",
+		{name: "alice", email: "alice@google.com"}:     "alice",
+		{name: "al", email: "al@google.com"}:           "alice",
+		{name: "eve", email: "eve@google.com"}:         "eve",
+	}
+	metrics := Evaluate(people, truth)
+	req.Equal(5, metrics.Items)
+	req.Equal(1, metrics.Missing)
+	req.Equal(3, metrics.Identities)
+	req.Equal(3, metrics.TrueIdentities)
+	req.InDelta(1.0/3, metrics.PairwisePrecision, 1e-9)
+	req.InDelta(0.5, metrics.PairwiseRecall, 1e-9)
+	req.InDelta(0.4, metrics.PairwiseF1, 1e-9)
+	req.InDelta(11.0/15, metrics.BCubedPrecision, 1e-9)
+	req.InDelta(0.8, metrics.BCubedRecall, 1e-9)
+	req.InDelta(88.0/115, metrics.BCubedF1, 1e-9)
+	req.Contains(metrics.String(), "pairwise precision:  0.3333\n")
+
+	metrics = Evaluate(People{}, truth)
+	req.Equal(Metrics{Missing: 6}, metrics)
+
+	// perfect matching without pairs
+	metrics = Evaluate(people, map[signatureKey]string{{name: "alice", email: "alice@google.com"}: "alice"})
+	req.Equal(1.0, metrics.PairwisePrecision)
+	req.Equal(1.0, metrics.PairwiseRecall)
+	req.Equal(1.0, metrics.BCubedF1)
+}
+
+func TestReadGroundTruth(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-truth")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "truth.csv")
+	req.NoError(ioutil.WriteFile(path, []byte(
+		"identity,name,email\n1,Bob  Müller,Bob@Google.com\n2,Alice,alice@google.com\n"), 0666))
+	truth, err := ReadGroundTruth(path)
+	req.NoError(err)
+	req.Equal(map[signatureKey]string{
+		{name: "bob muller", email: "bob@google.com"}: "1",
+		{name: "alice", email: "alice@google.com"}:    "2",
+	}, truth)
+
+	req.NoError(ioutil.WriteFile(path, []byte("name,email\nbob,bob@google.com\n"), 0666))
+	_, err = ReadGroundTruth(path)
+	req.Error(err)
+	req.NoError(ioutil.WriteFile(path, []byte("name,email,identity\nbob,bob@google.com\n"), 0666))
+	_, err = ReadGroundTruth(path)
+	req.Error(err)
+	_, err = ReadGroundTruth(filepath.Join(dir, "missing.csv"))
+	req.Error(err)
+}
@@ -0,0 +1,90 @@
+package idmatch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExplainMerge returns the chain of edges which connects the signatures a and b inside the same
+// person: the first edge starts at a, the last edge ends at b and the edges in between pass
+// through the intermediate signatures. The edges are oriented along the chain. ExplainMerge
+// requires the evidence recorded with ReduceOptions.ExplainMerges.
+func (p People) ExplainMerge(a, b int64) ([]IdentityEdge, error) {
+	if a == b {
+		return nil, nil
+	}
+	for _, person := range p {
+		adjacency := map[int64][]IdentityEdge{}
+		for _, edge := range person.MergeEvidence {
+			adjacency[edge.From] = append(adjacency[edge.From], edge)
+			reversed := edge
+			reversed.From, reversed.To = edge.To, edge.From
+			adjacency[edge.To] = append(adjacency[edge.To], reversed)
+		}
+		if _, exists := adjacency[a]; !exists {
+			continue
+		}
+		if _, exists := adjacency[b]; !exists {
+			break
+		}
+		// breadth-first search yields the shortest chain
+		parents := map[int64]IdentityEdge{}
+		visited := map[int64]struct{}{a: {}}
+		queue := []int64{a}
+		for len(queue) > 0 && queue[0] != b {
+			current := queue[0]
+			queue = queue[1:]
+			for _, edge := range adjacency[current] {
+				if _, exists := visited[edge.To]; exists {
+					continue
+				}
+				visited[edge.To] = struct{}{}
+				parents[edge.To] = edge
+				queue = append(queue, edge.To)
+			}
+		}
+		if len(queue) == 0 {
+			break
+		}
+		var chain []IdentityEdge
+		for current := b; current != a; current = parents[current].From {
+			chain = append(chain, parents[current])
+		}
+		for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+			chain[i], chain[j] = chain[j], chain[i]
+		}
+		return chain, nil
+	}
+	return nil, fmt.Errorf("no merge evidence between identities %d and %d", a, b)
+}
+
+type parquetMergeEvidence struct {
+	ID       int64   `parquet:"name=id, type=INT_64"`
+	From     int64   `parquet:"name=from, type=INT_64"`
+	To       int64   `parquet:"name=to, type=INT_64"`
+	Evidence string  `parquet:"name=evidence, type=UTF8"`
+	Weight   float64 `parquet:"name=weight, type=DOUBLE"`
+}
+
+// mergesPath returns the path to the merge evidence table next to the identities table.
+func mergesPath(rawPath string) string {
+	return strings.TrimSuffix(rawPath, ".parquet") + "-merges.parquet"
+}
+
+// WriteMergesToParquet saves Person.MergeEvidence to the parquet file "<path>-merges.parquet".
+// Each row is an edge of the person's signature graph with the evidence formatted as
+// "kind:value" items joined with "; ".
+func (p People) WriteMergesToParquet(path string) (err error) {
+	pw, cleanup := newParquetWriter(mergesPath(path), new(parquetMergeEvidence))
+	defer cleanup()
+	p.ForEach(func(key int64, val *Person) bool {
+		for _, edge := range val.MergeEvidence {
+			if err = pw.Write(parquetMergeEvidence{
+				val.ID, edge.From, edge.To, formatEvidence(edge.Evidence), edge.Weight}); err != nil {
+				return true
+			}
+		}
+		return false
+	})
+	return
+}
@@ -0,0 +1,82 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func newExplainTestPeople(t *testing.T, explain bool) People {
+	t.Helper()
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"robert@google.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"robert", ""}}, Emails: []string{"robert@google.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+	}
+	err := ReducePeople(context.Background(), people, nil, newTestBlacklist(t), ReduceOptions{
+		MaxIdentities: 100,
+		ExplainMerges: explain,
+	})
+	require.NoError(t, err)
+	return people
+}
+
+func TestPeopleExplainMerge(t *testing.T) {
+	req := require.New(t)
+	people := newExplainTestPeople(t, true)
+	req.Len(people, 2)
+	req.Len(people[1].MergeEvidence, 2)
+	req.Nil(people[4].MergeEvidence)
+
+	chain, err := people.ExplainMerge(1, 3)
+	req.NoError(err)
+	req.Equal([]IdentityEdge{
+		{From: 1, To: 2, Evidence: []Evidence{{EvidenceName, "bob", 1}}, Weight: 1, Active: true},
+		{From: 2, To: 3, Evidence: []Evidence{{EvidenceEmail, "robert@google.com", 1}}, Weight: 1, Active: true},
+	}, chain)
+	chain, err = people.ExplainMerge(3, 1)
+	req.NoError(err)
+	req.Equal([]int64{3, 2}, []int64{chain[0].From, chain[1].From})
+	req.Equal([]int64{2, 1}, []int64{chain[0].To, chain[1].To})
+
+	chain, err = people.ExplainMerge(2, 2)
+	req.NoError(err)
+	req.Nil(chain)
+	_, err = people.ExplainMerge(1, 4)
+	req.EqualError(err, "no merge evidence between identities 1 and 4")
+
+	people = newExplainTestPeople(t, false)
+	req.Nil(people[1].MergeEvidence)
+	_, err = people.ExplainMerge(1, 3)
+	req.Error(err)
+}
+
+func TestPeopleWriteMergesToParquet(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-explain")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "identities.parquet")
+	req.NoError(newExplainTestPeople(t, true).WriteMergesToParquet(path))
+
+	fr, err := local.NewLocalFileReader(filepath.Join(dir, "identities-merges.parquet"))
+	req.NoError(err)
+	defer fr.Close()
+	pr, err := reader.NewParquetReader(fr, new(parquetMergeEvidence), int64(runtime.NumCPU()))
+	req.NoError(err)
+	rows := make([]parquetMergeEvidence, pr.GetNumRows())
+	req.NoError(pr.Read(&rows))
+	pr.ReadStop()
+	req.Equal([]parquetMergeEvidence{
+		{1, 1, 2, "name:bob", 1},
+		{1, 2, 3, "email:robert@google.com", 1},
+	}, rows)
+}
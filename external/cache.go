@@ -10,7 +10,7 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/sirupsen/logrus"
+	"github.com/src-d/identity-matching/reporter"
 )
 
 // CachedUser represents personal name and username of a person
@@ -50,9 +50,7 @@ func NewCachedMatcher(matcher Matcher, cachePath string) (*CachedMatcher, error)
 	if cachePath == "" {
 		panic("cachePath cannot be empty")
 	}
-	logrus.WithFields(logrus.Fields{
-		"cachePath": cachePath,
-	}).Info("caching the external identities")
+	reporter.Infof("caching the external identities in %s", cachePath)
 	cache := safeUserCache{cache: make(map[string]CachedUser), cachePath: cachePath, lock: sync.RWMutex{}}
 	cachedMatcher := &CachedMatcher{matcher: matcher, cache: cache}
 	var err error
@@ -105,6 +103,16 @@ func (m *CachedMatcher) MatchByEmail(ctx context.Context, email string) (user st
 	return user, err
 }
 
+// PreferredEmail forwards to the underlying Matcher if it implements PreferredEmailMatcher.
+// The preferred emails are not cached, so they are only known for the users queried
+// since the start.
+func (m *CachedMatcher) PreferredEmail(user string) (string, bool) {
+	if matcher, ok := m.matcher.(PreferredEmailMatcher); ok {
+		return matcher.PreferredEmail(user)
+	}
+	return "", false
+}
+
 // SupportsMatchingByCommit acts the same as the underlying Matcher.
 func (m *CachedMatcher) SupportsMatchingByCommit() bool {
 	return m.matcher.SupportsMatchingByCommit()
@@ -134,6 +142,53 @@ func (m *CachedMatcher) MatchByCommit(
 	return user, err
 }
 
+// MatchByCommits looks in the cache first and forwards the cache misses to the underlying Matcher
+// if it implements CommitBatchMatcher. The resolved users are cached by email the same way as
+// in MatchByCommit and the cache is saved on disk at once. There is no cache by the commit hash:
+// the login of the commit's email is the result, so the email cache answers every later commit
+// of the same email without a request.
+func (m *CachedMatcher) MatchByCommits(
+	ctx context.Context, queries []CommitQuery) (map[CommitQuery]string, error) {
+	users := map[CommitQuery]string{}
+	var misses []CommitQuery
+	for _, query := range queries {
+		if username, exists := m.cache.ReadUserFromCache(query.Email); exists {
+			users[query] = username.User
+			if !username.Matched {
+				users[query] = ""
+			}
+			continue
+		}
+		misses = append(misses, query)
+	}
+	matcher, ok := m.matcher.(CommitBatchMatcher)
+	if !ok || len(misses) == 0 {
+		return users, nil
+	}
+	resolved, err := matcher.MatchByCommits(ctx, misses)
+	for query, user := range resolved {
+		users[query] = user
+		if user != "" {
+			m.cache.AddUserToCache(query.Email, user, true)
+		}
+	}
+	// another commit of the same email may have matched
+	for query, user := range resolved {
+		if _, exists := m.cache.ReadUserFromCache(query.Email); !exists && user == "" {
+			m.cache.AddUserToCache(query.Email, "", false)
+		}
+	}
+	if len(resolved) > 0 {
+		m.cache.lock.Lock()
+		errDump := m.DumpCache()
+		m.cache.lock.Unlock()
+		if err == nil {
+			err = errDump
+		}
+	}
+	return users, err
+}
+
 // Add to cache safely
 func (m *safeUserCache) AddUserToCache(email string, user string, matched bool) {
 	m.lock.Lock()
@@ -201,13 +256,13 @@ func (m *safeUserCache) LoadFromDisk() error {
 
 // DumpOnDisk saves cache on disk
 func (m safeUserCache) DumpOnDisk() error {
-	logrus.Infof("writing the external identities cache to %s", m.cachePath)
+	reporter.Infof("writing the external identities cache to %s", m.cachePath)
 	var file *os.File
 	existing := safeUserCache{cache: make(map[string]CachedUser), cachePath: m.cachePath, lock: sync.RWMutex{}}
 	flag := os.O_CREATE | os.O_WRONLY
 	if existing.LoadFromDisk() == nil && len(existing.cache) > 0 {
 		flag |= os.O_APPEND
-		logrus.Infof("appending to existing %d records", len(existing.cache))
+		reporter.Infof("appending to existing %d records", len(existing.cache))
 	}
 	file, err := os.OpenFile(m.cachePath, flag, 0666)
 	if err != nil {
@@ -254,6 +309,29 @@ func (m safeUserCache) DumpOnDisk() error {
 		}
 		written++
 	}
-	logrus.Infof("written %d new records", written)
+	reporter.Infof("written %d new records", written)
 	return nil
 }
+
+// EraseCachedEmails removes the given emails from the cache file in cachePath and rewrites it.
+// It returns the number of the removed records.
+func EraseCachedEmails(cachePath string, emails map[string]struct{}) (int, error) {
+	cache := safeUserCache{cache: make(map[string]CachedUser), cachePath: cachePath, lock: sync.RWMutex{}}
+	if err := cache.LoadFromDisk(); err != nil {
+		return 0, err
+	}
+	erased := 0
+	for email := range cache.cache {
+		if _, exists := emails[email]; exists {
+			delete(cache.cache, email)
+			erased++
+		}
+	}
+	if erased == 0 {
+		return 0, nil
+	}
+	if err := os.Remove(cachePath); err != nil {
+		return 0, err
+	}
+	return erased, cache.DumpOnDisk()
+}
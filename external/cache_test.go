@@ -279,3 +279,81 @@ new@gmail.com,new_user,1
 	req.NoError(err)
 	req.Equal(expected, string(newCache))
 }
+
+func TestEraseCachedEmails(t *testing.T) {
+	req := require.New(t)
+	cache, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := cache.Write([]byte(
+		"email,user,match\n" +
+			"mcuadros@gmail.com,mcuadros,1\n" +
+			"mcuadros-clone@gmail.com,,0\n" +
+			"vadim@sourced.tech,vmarkovtsev,1\n"))
+	req.NoError(err)
+	erased, err := EraseCachedEmails(cache.Name(), map[string]struct{}{
+		"mcuadros@gmail.com": {}, "nobody@gmail.com": {}})
+	req.NoError(err)
+	req.Equal(1, erased)
+	txt, err := ioutil.ReadFile(cache.Name())
+	req.NoError(err)
+	req.Equal(`email,user,match
+mcuadros-clone@gmail.com,,0
+vadim@sourced.tech,vmarkovtsev,1
+`, string(txt))
+	erased, err = EraseCachedEmails(cache.Name(), map[string]struct{}{"nobody@gmail.com": {}})
+	req.NoError(err)
+	req.Equal(0, erased)
+}
+
+// testBatchMatcher resolves "bob@google.com" in the first commit and nothing else.
+type testBatchMatcher struct {
+	TestNoMatchMatcher
+	queries []CommitQuery
+}
+
+func (m *testBatchMatcher) MatchByCommits(
+	ctx context.Context, queries []CommitQuery) (map[CommitQuery]string, error) {
+	m.queries = append(m.queries, queries...)
+	users := map[CommitQuery]string{}
+	for _, query := range queries {
+		users[query] = ""
+		if query.Email == "bob@google.com" && query.Commit == "1" {
+			users[query] = "bob"
+		}
+	}
+	return users, nil
+}
+
+func TestCachedMatcherMatchByCommits(t *testing.T) {
+	req := require.New(t)
+	cache, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := cache.Write([]byte("email,user,match\nalice@google.com,alice,1\neve@google.com,,0\n"))
+	req.NoError(err)
+	matcher := &testBatchMatcher{}
+	cachedMatcher, err := NewCachedMatcher(matcher, cache.Name())
+	req.NoError(err)
+	queries := []CommitQuery{
+		{"alice@google.com", "r", "1"},
+		{"eve@google.com", "r", "1"},
+		{"bob@google.com", "r", "2"},
+		{"bob@google.com", "r", "1"},
+		{"carol@google.com", "r", "1"},
+	}
+	users, err := cachedMatcher.MatchByCommits(context.Background(), queries)
+	req.NoError(err)
+	req.Equal(map[CommitQuery]string{
+		queries[0]: "alice", queries[1]: "", queries[2]: "", queries[3]: "bob", queries[4]: "",
+	}, users)
+	req.Equal(queries[2:], matcher.queries)
+	cacheContent, err := ioutil.ReadFile(cache.Name())
+	req.NoError(err)
+	req.Equal("email,user,match\nalice@google.com,alice,1\neve@google.com,,0\n"+
+		"bob@google.com,bob,1\ncarol@google.com,,0\n", string(cacheContent))
+
+	matcher.queries = nil
+	users, err = cachedMatcher.MatchByCommits(context.Background(), queries[2:])
+	req.NoError(err)
+	req.Equal("bob", users[queries[2]])
+	req.Empty(matcher.queries)
+}
@@ -0,0 +1,140 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// gerritXSSIPrefix is prepended to every JSON response of the Gerrit REST API.
+const gerritXSSIPrefix = ")]}'"
+
+// GerritMatcher matches emails and Gerrit accounts. The user is the numeric account ID.
+type GerritMatcher struct {
+	client   *http.Client
+	apiURL   string
+	username string
+	password string
+	// preferred maps the account IDs to the preferred emails, guarded by lock
+	preferred map[string]string
+	lock      *sync.RWMutex
+}
+
+type gerritAccount struct {
+	ID    int64  `json:"_account_id"`
+	Email string `json:"email"`
+}
+
+// NewGerritMatcher creates a new matcher given the Gerrit server URL and the HTTP credentials
+// in the "username:password" format, see "Settings / HTTP Credentials" in Gerrit.
+// The blank token means anonymous access.
+func NewGerritMatcher(apiURL, token string) (Matcher, error) {
+	if apiURL == "" {
+		return nil, errors.New("the Gerrit server URL must be specified")
+	}
+	m := GerritMatcher{
+		client:    http.DefaultClient,
+		apiURL:    strings.TrimSuffix(apiURL, "/"),
+		preferred: map[string]string{},
+		lock:      &sync.RWMutex{},
+	}
+	if token != "" {
+		parts := strings.SplitN(token, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("the Gerrit token must be in the \"username:password\" format")
+		}
+		m.username, m.password = parts[0], parts[1]
+	}
+	return m, nil
+}
+
+// MatchByEmail returns the Gerrit account with the given email. If several accounts share
+// the email, the oldest is chosen.
+func (m GerritMatcher) MatchByEmail(ctx context.Context, email string) (user string, err error) {
+	endpoint := m.apiURL + "/accounts/"
+	if m.username != "" {
+		// the authenticated endpoints are prefixed with /a/
+		endpoint = m.apiURL + "/a/accounts/"
+	}
+	query := url.Values{"q": {"email:" + email}, "o": {"DETAILS"}}
+	request, err := http.NewRequest(http.MethodGet, endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if m.username != "" {
+		request.SetBasicAuth(m.username, m.password)
+	}
+	response, err := m.client.Do(request.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", context.Canceled
+		}
+		return "", err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode == http.StatusNotFound {
+		return "", ErrNoMatches
+	}
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Gerrit HTTP %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var accounts []gerritAccount
+	body = []byte(strings.TrimPrefix(string(body), gerritXSSIPrefix))
+	if err := json.Unmarshal(body, &accounts); err != nil {
+		return "", err
+	}
+	if len(accounts) == 0 {
+		reporter.Warnf("unable to find Gerrit accounts for email: %s", email)
+		return "", ErrNoMatches
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].ID < accounts[j].ID })
+	if len(accounts) > 1 {
+		reporter.Warnf("%d Gerrit accounts share email %s, choosing %d",
+			len(accounts), email, accounts[0].ID)
+	}
+	user = strconv.FormatInt(accounts[0].ID, 10)
+	if accounts[0].Email != "" {
+		m.lock.Lock()
+		m.preferred[user] = accounts[0].Email
+		m.lock.Unlock()
+	}
+	return user, nil
+}
+
+// PreferredEmail returns the preferred email of the Gerrit account matched before.
+func (m GerritMatcher) PreferredEmail(user string) (string, bool) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	email, exists := m.preferred[user]
+	return email, exists
+}
+
+// SupportsMatchingByCommit indicates whether this Matcher allows querying identities by commit metadata.
+func (m GerritMatcher) SupportsMatchingByCommit() bool {
+	return false
+}
+
+// MatchByCommit queries the identity of a given email address in a particular commit context.
+func (m GerritMatcher) MatchByCommit(
+	ctx context.Context, email, repo, commit string) (user string, err error) {
+	return "", errors.New("not implemented")
+}
+
+// OnIdle does nothing here.
+func (m GerritMatcher) OnIdle() error {
+	return nil
+}
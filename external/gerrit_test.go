@@ -0,0 +1,76 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestGerritServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/a/accounts/", r.URL.Path)
+		username, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", username)
+		require.Equal(t, "secret", password)
+		require.Equal(t, "DETAILS", r.URL.Query().Get("o"))
+		fmt.Fprintln(w, gerritXSSIPrefix)
+		switch r.URL.Query().Get("q") {
+		case "email:bob@google.com":
+			fmt.Fprint(w, `[{"_account_id": 1001, "email": "Bob@google.com"}]`)
+		case "email:alice@google.com":
+			fmt.Fprint(w, `[{"_account_id": 1003}, {"_account_id": 1002, "email": "alice@google.com"}]`)
+		case "email:error@google.com":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+}
+
+func TestGerritMatcherMatchByEmail(t *testing.T) {
+	req := require.New(t)
+	server := newTestGerritServer(t)
+	defer server.Close()
+	_, err := NewGerritMatcher("", "")
+	req.Error(err)
+	_, err = NewGerritMatcher(server.URL, "user")
+	req.Error(err)
+	m, err := NewGerritMatcher(server.URL+"/", "user:secret")
+	req.NoError(err)
+	req.False(m.SupportsMatchingByCommit())
+
+	user, err := m.MatchByEmail(context.Background(), "bob@google.com")
+	req.NoError(err)
+	req.Equal("1001", user)
+	user, err = m.MatchByEmail(context.Background(), "alice@google.com")
+	req.NoError(err)
+	req.Equal("1002", user)
+	_, err = m.MatchByEmail(context.Background(), "eve@google.com")
+	req.Equal(ErrNoMatches, err)
+	_, err = m.MatchByEmail(context.Background(), "error@google.com")
+	req.Error(err)
+
+	preferred := m.(PreferredEmailMatcher)
+	email, exists := preferred.PreferredEmail("1001")
+	req.True(exists)
+	req.Equal("Bob@google.com", email)
+	_, exists = preferred.PreferredEmail("1003")
+	req.False(exists)
+}
+
+func TestGerritMatcherCancel(t *testing.T) {
+	server := newTestGerritServer(t)
+	defer server.Close()
+	m, err := NewGerritMatcher(server.URL, "user:secret")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	user, err := m.MatchByEmail(ctx, "bob@google.com")
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, "", user)
+}
@@ -2,41 +2,90 @@ package external
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 	"gopkg.in/google/go-github.v15/github"
+
+	"github.com/src-d/identity-matching/reporter"
 )
 
 // GitHubMatcher matches emails and GitHub users.
 type GitHubMatcher struct {
-	client *github.Client
+	// clients is the token pool, one client per token
+	clients []*github.Client
+	// next is the index of the client for the next query
+	next *uint64
+	// graphQLPath is the GraphQL endpoint relative to the REST API URL
+	graphQLPath string
+	// commitSearch is the fallback of the commit lookups, nil if it is disabled
+	commitSearch *CommitSearchOptions
+}
+
+// CommitSearchOptions configure the fallback of the commit lookups to the commit search by
+// the author email. It resolves the emails whose commits do not exist on GitHub or are not
+// linked to a login, e.g. because they were rebased.
+type CommitSearchOptions struct {
+	// MinShare is the share of the found commits, from 0 to 1, which the most frequent author
+	// login must have to be accepted. A tie between the logins is never accepted.
+	MinShare float64
+	// MaxCommits is the number of the found commits which vote, 100 if it is 0.
+	MaxCommits int
 }
 
 // NewGitHubMatcher creates a new matcher given a GitHub token.
 // https://github.com/settings/tokens
+// Several tokens separated by commas make up the pool which the queries rotate, so that
+// the rate limit of each token is spent in turn and MatchByCommits queries in parallel.
 func NewGitHubMatcher(apiURL, token string) (Matcher, error) {
 	if apiURL == "" {
 		apiURL = "https://api.github.com/"
 	}
-	var c *http.Client
-	if token != "" {
-		c = oauth2.NewClient(
-			context.Background(),
-			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
-		)
+	m := GitHubMatcher{next: new(uint64), graphQLPath: "graphql"}
+	for _, token := range strings.Split(token, ",") {
+		token = strings.TrimSpace(token)
+		var c *http.Client
+		if token != "" {
+			c = oauth2.NewClient(
+				context.Background(),
+				oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+			)
+		}
+		// The actual upload URL does not matter - we are not going to upload anything.
+		client, err := github.NewEnterpriseClient(apiURL, apiURL, c)
+		if err != nil {
+			return GitHubMatcher{}, err
+		}
+		m.clients = append(m.clients, client)
+	}
+	// GitHub Enterprise serves REST at /api/v3/ and GraphQL at /api/graphql
+	if strings.HasSuffix(m.clients[0].BaseURL.Path, "/v3/") {
+		m.graphQLPath = "../graphql"
 	}
-	// The actual upload URL does not matter - we are not going to upload anything.
-	client, err := github.NewEnterpriseClient(apiURL, apiURL, c)
-	if err != nil {
-		return GitHubMatcher{}, err
+	return m, nil
+}
+
+// WithCommitSearch returns the matcher which falls back to the commit search when
+// MatchByCommit or MatchByCommits cannot resolve a login.
+func (m GitHubMatcher) WithCommitSearch(opts CommitSearchOptions) GitHubMatcher {
+	if opts.MaxCommits <= 0 {
+		opts.MaxCommits = 100
 	}
-	return GitHubMatcher{client}, nil
+	m.commitSearch = &opts
+	return m
+}
+
+// client returns the next client of the token pool.
+func (m GitHubMatcher) client() *github.Client {
+	return m.clients[(atomic.AddUint64(m.next, 1)-1)%uint64(len(m.clients))]
 }
 
 var searchOpts = &github.SearchOptions{
@@ -46,12 +95,22 @@ var searchOpts = &github.SearchOptions{
 var gitHubRepoRe = regexp.MustCompile(`(.*://|^)github.com/([^/]+)/([^/]+?)(?:\.git)?$`)
 
 const (
-	responseSuccess = 0
-	responseRetry   = 1
-	responseFail    = 2
-	maxNumFailures  = 8
+	responseSuccess     = 0
+	responseRetry       = 1
+	responseFail        = 2
+	responseUnavailable = 3
+	maxNumFailures      = 8
 )
 
+// unavailableError wraps the last error of the request which failed after all the retries
+// with ErrUnavailable.
+func unavailableError(response *github.Response, err error) error {
+	if err == nil {
+		err = fmt.Errorf("HTTP %d", response.StatusCode)
+	}
+	return fmt.Errorf("%w: GitHub API failed %d times: %v", ErrUnavailable, maxNumFailures+1, err)
+}
+
 // MatchByEmail returns the latest GitHub user with the given email.
 func (m GitHubMatcher) MatchByEmail(ctx context.Context, email string) (user string, err error) {
 	finished := make(chan struct{})
@@ -66,10 +125,13 @@ func (m GitHubMatcher) MatchByEmail(ctx context.Context, email string) (user str
 			} else {
 				var result *github.UsersSearchResult
 				var response *github.Response
-				result, response, err = m.client.Search.Users(ctx, query, searchOpts)
+				result, response, err = m.client().Search.Users(ctx, query, searchOpts)
 				status := checkResponse(response, err, &numFailures)
 				if status == responseRetry {
 					continue
+				} else if status == responseUnavailable {
+					err = unavailableError(response, err)
+					return
 				} else if status == responseFail {
 					return
 				}
@@ -79,7 +141,7 @@ func (m GitHubMatcher) MatchByEmail(ctx context.Context, email string) (user str
 						query = strings.Replace(query, "@", " ", 1)
 						continue
 					}
-					logrus.Warnf("unable to find users for email: %s", email)
+					reporter.Warnf("unable to find users for email: %s", email)
 					err = ErrNoMatches
 					return
 				}
@@ -124,21 +186,31 @@ func (m GitHubMatcher) MatchByCommit(
 			} else {
 				var c *github.RepositoryCommit
 				var response *github.Response
-				c, response, err = m.client.Repositories.GetCommit(ctx, repoUser, repoName, commit)
+				c, response, err = m.client().Repositories.GetCommit(ctx, repoUser, repoName, commit)
 				status := checkResponse(response, err, &numFailures)
 				if status == responseRetry {
 					continue
+				} else if status == responseUnavailable {
+					err = unavailableError(response, err)
+					return
 				} else if status == responseFail {
+					code := response.Response.StatusCode
+					if m.commitSearch != nil && (code == http.StatusNotFound ||
+						code == http.StatusUnprocessableEntity) {
+						user, err = m.searchCommits(ctx, email)
+					}
 					return
 				}
-				if c.Author != nil && c.Author.Login != nil && c.Commit.Author != nil &&
+				if m.commitSearch != nil && !commitHasLogin(c, email) {
+					user, err = m.searchCommits(ctx, email)
+				} else if c.Author != nil && c.Author.Login != nil && c.Commit.Author != nil &&
 					c.Commit.Author.Email != nil && *c.Commit.Author.Email == email {
 					user = *c.Author.Login
 				} else if c.Committer != nil && c.Committer.Login != nil && c.Commit.Committer != nil &&
 					c.Commit.Committer.Email != nil && *c.Commit.Committer.Email == email {
 					user = *c.Committer.Login
 				} else {
-					logrus.Warnf("unable to find users by commit for email: %s", email)
+					reporter.Warnf("unable to find users by commit for email: %s", email)
 					err = ErrNoMatches
 				}
 				break
@@ -153,6 +225,253 @@ func (m GitHubMatcher) MatchByCommit(
 	}
 }
 
+// commitHasLogin indicates whether the author or the committer with the email has a login.
+func commitHasLogin(c *github.RepositoryCommit, email string) bool {
+	return (c.GetAuthor().GetLogin() != "" && c.GetCommit().GetAuthor().GetEmail() == email) ||
+		(c.GetCommitter().GetLogin() != "" && c.GetCommit().GetCommitter().GetEmail() == email)
+}
+
+// searchCommits finds the commits by the author email and votes for the login among their
+// authors, see CommitSearchOptions.
+func (m GitHubMatcher) searchCommits(ctx context.Context, email string) (string, error) {
+	opts := &github.SearchOptions{ListOptions: github.ListOptions{PerPage: m.commitSearch.MaxCommits}}
+	var numFailures uint64
+	for { // api rate limit retry loop
+		result, response, err := m.client().Search.Commits(ctx, "author-email:"+email, opts)
+		status := checkResponse(response, err, &numFailures)
+		if status == responseRetry {
+			continue
+		} else if status == responseUnavailable {
+			return "", unavailableError(response, err)
+		} else if status == responseFail {
+			return "", err
+		}
+		votes := map[string]int{}
+		total := 0
+		for _, c := range result.Commits {
+			if !strings.EqualFold(c.GetCommit().GetAuthor().GetEmail(), email) {
+				continue
+			}
+			total++
+			if login := c.GetAuthor().GetLogin(); login != "" {
+				votes[login]++
+			}
+		}
+		user, share := majorityVote(votes, total)
+		if user == "" || share < m.commitSearch.MinShare {
+			reporter.Warnf("unable to find users by commit search for email: %s", email)
+			return "", ErrNoMatches
+		}
+		reporter.Increment("commit search matches")
+		return user, nil
+	}
+}
+
+// majorityVote returns the login with the most votes and its share of the total. The login is
+// blank if there are no votes or if the top is a tie.
+func majorityVote(votes map[string]int, total int) (string, float64) {
+	var winner string
+	best, tie := 0, false
+	for login, count := range votes {
+		if count > best {
+			winner, best, tie = login, count, false
+		} else if count == best {
+			tie = true
+		}
+	}
+	if best == 0 || tie {
+		return "", 0
+	}
+	return winner, float64(best) / float64(total)
+}
+
+// gitHubCommitBatchSize is the number of the commits in a single GraphQL query of
+// MatchByCommits. Each commit costs a node, and the query must stay below the node limit.
+const gitHubCommitBatchSize = 100
+
+// gitHubCommitActor is the commit author or committer in the GraphQL response.
+type gitHubCommitActor struct {
+	Email string `json:"email"`
+	User  *struct {
+		Login string `json:"login"`
+	} `json:"user"`
+}
+
+// gitHubCommitsResponse maps the aliases of the commits to the repositories with the commits,
+// see gitHubCommitsQuery.
+type gitHubCommitsResponse struct {
+	Data map[string]*struct {
+		Object *struct {
+			Author    *gitHubCommitActor `json:"author"`
+			Committer *gitHubCommitActor `json:"committer"`
+		} `json:"object"`
+	} `json:"data"`
+	Errors []struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// gitHubCommit is the commit which MatchByCommits looks up.
+type gitHubCommit struct {
+	owner, name, hash string
+}
+
+// gitHubCommitsQuery returns the GraphQL query which fetches all the commits at once,
+// the alias of each commit is "c" followed by its index.
+func gitHubCommitsQuery(commits []gitHubCommit) string {
+	var query strings.Builder
+	query.WriteString("query {\n")
+	for i, commit := range commits {
+		fmt.Fprintf(&query, "  c%d: repository(owner: %s, name: %s) { object(oid: %s) { ... on Commit { "+
+			"author { email user { login } } committer { email user { login } } } } }\n",
+			i, strconv.Quote(commit.owner), strconv.Quote(commit.name), strconv.Quote(commit.hash))
+	}
+	query.WriteString("}")
+	return query.String()
+}
+
+// MatchByCommits resolves the commits of the queries with GraphQL, gitHubCommitBatchSize commits
+// per request, and sends the requests in parallel, one per client in the token pool.
+// The queries of the other hosts and the invalid hashes are left to MatchByCommit. The emails
+// which are still not resolved fall back to the commit search, see WithCommitSearch.
+func (m GitHubMatcher) MatchByCommits(
+	ctx context.Context, queries []CommitQuery) (map[CommitQuery]string, error) {
+	users := map[CommitQuery]string{}
+	byCommit := map[gitHubCommit][]CommitQuery{}
+	var commits []gitHubCommit
+	for _, query := range queries {
+		if isNoReplyEmail(query.Email) {
+			users[query] = userFromEmail(query.Email)
+			continue
+		}
+		parsedRepo := gitHubRepoRe.FindStringSubmatch(query.Repo)
+		if len(parsedRepo) < 4 || len(query.Commit) != 40 {
+			continue
+		}
+		commit := gitHubCommit{parsedRepo[2], parsedRepo[3], query.Commit}
+		if _, exists := byCommit[commit]; !exists {
+			commits = append(commits, commit)
+		}
+		byCommit[commit] = append(byCommit[commit], query)
+	}
+	batches := make(chan []gitHubCommit)
+	go func() {
+		defer close(batches)
+		for start := 0; start < len(commits); start += gitHubCommitBatchSize {
+			end := start + gitHubCommitBatchSize
+			if end > len(commits) {
+				end = len(commits)
+			}
+			select {
+			case batches <- commits[start:end]:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var lock sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	for _, client := range m.clients {
+		wg.Add(1)
+		go func(client *github.Client) {
+			defer wg.Done()
+			for batch := range batches {
+				objects, err := m.queryCommits(ctx, client, batch)
+				lock.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					lock.Unlock()
+					continue
+				}
+				for i, commit := range batch {
+					object := objects.Data["c"+strconv.Itoa(i)]
+					for _, query := range byCommit[commit] {
+						users[query] = ""
+						if object == nil || object.Object == nil {
+							continue
+						}
+						if actor := object.Object.Author; actor != nil && actor.User != nil &&
+							actor.Email == query.Email {
+							users[query] = actor.User.Login
+						} else if actor := object.Object.Committer; actor != nil && actor.User != nil &&
+							actor.Email == query.Email {
+							users[query] = actor.User.Login
+						}
+					}
+				}
+				lock.Unlock()
+			}
+		}(client)
+	}
+	wg.Wait()
+	if m.commitSearch != nil {
+		searched := map[string]string{}
+		for _, query := range queries {
+			if user, exists := users[query]; !exists || user != "" || ctx.Err() != nil {
+				continue
+			}
+			user, exists := searched[query.Email]
+			if !exists {
+				var err error
+				user, err = m.searchCommits(ctx, query.Email)
+				if err != nil && err != ErrNoMatches {
+					if firstErr == nil {
+						firstErr = err
+					}
+					delete(users, query)
+					continue
+				}
+				searched[query.Email] = user
+			}
+			users[query] = user
+		}
+	}
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return users, firstErr
+}
+
+// queryCommits sends gitHubCommitsQuery with the client and retries on the rate limit.
+// The commits which do not exist are null, any other GraphQL error fails the whole batch.
+func (m GitHubMatcher) queryCommits(
+	ctx context.Context, client *github.Client, commits []gitHubCommit) (*gitHubCommitsResponse, error) {
+	var numFailures uint64
+	for { // api rate limit retry loop
+		request, err := client.NewRequest("POST", m.graphQLPath,
+			map[string]string{"query": gitHubCommitsQuery(commits)})
+		if err != nil {
+			return nil, err
+		}
+		result := &gitHubCommitsResponse{}
+		response, err := client.Do(ctx, request, result)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		status := checkResponse(response, err, &numFailures)
+		if status == responseRetry {
+			continue
+		} else if status == responseUnavailable {
+			return nil, unavailableError(response, err)
+		} else if status == responseFail {
+			if err == nil {
+				err = fmt.Errorf("GitHub GraphQL HTTP %d", response.StatusCode)
+			}
+			return nil, err
+		}
+		for _, e := range result.Errors {
+			if e.Type != "NOT_FOUND" {
+				return nil, fmt.Errorf("GitHub GraphQL: %s", e.Message)
+			}
+		}
+		return result, nil
+	}
+}
+
 // OnIdle does nothing here.
 func (m GitHubMatcher) OnIdle() error {
 	return nil
@@ -173,27 +492,32 @@ func checkResponse(response *github.Response, err error, numFailures *uint64) in
 		t, err := strconv.ParseInt(
 			response.Response.Header["X-Ratelimit-Reset"][0], 10, 64)
 		if err != nil {
-			logrus.Errorf("Bad X-Ratelimit-Reset header: %v", err)
+			reporter.Errorf("Bad X-Ratelimit-Reset header: %v", err)
 			return responseFail
 		}
 		resetTime := time.Unix(t, 0).Add(time.Second)
-		logrus.Warnf("rate limit was hit, waiting until %s", resetTime.String())
+		reporter.Warnf("rate limit was hit, waiting until %s", resetTime.String())
 		time.Sleep(resetTime.Sub(time.Now().UTC()))
 		return responseRetry
 	}
 
+	// the commit or the repository does not exist, repeating will not help
+	if code == http.StatusNotFound || code == http.StatusUnprocessableEntity {
+		reporter.Warnf("HTTP %d: %s", code, err)
+		return responseFail
+	}
 	if err != nil || code >= 500 && code < 600 || code == 408 || code == 429 {
 		sleepTime := time.Duration((1 << *numFailures) * int64(time.Second))
-		logrus.Warnf("HTTP %d: %s, sleeping until %s", code, err,
+		reporter.Warnf("HTTP %d: %s, sleeping until %s", code, err,
 			time.Now().UTC().Add(sleepTime))
 		time.Sleep(sleepTime)
 		*numFailures++
 		if *numFailures > maxNumFailures {
-			return responseFail
+			return responseUnavailable
 		}
 		return responseRetry
 	}
-	logrus.Warnf("HTTP %d: %s", code, err)
+	reporter.Warnf("HTTP %d: %s", code, err)
 	return responseFail
 }
 
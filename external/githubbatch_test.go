@@ -0,0 +1,81 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubMatcherMatchByCommits(t *testing.T) {
+	req := require.New(t)
+	hash1 := strings.Repeat("1", 40)
+	hash2 := strings.Repeat("2", 40)
+	hash3 := strings.Repeat("3", 40)
+	var tokens []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/graphql", r.URL.Path)
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		var body struct {
+			Query string `json:"query"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Contains(t, body.Query, `c0: repository(owner: "src-d", name: "go-git") { object(oid: "`+hash1)
+		require.Contains(t, body.Query, `c1: repository(owner: "src-d", name: "go-git") { object(oid: "`+hash2)
+		require.Contains(t, body.Query, `c2: repository(owner: "src-d", name: "enry") { object(oid: "`+hash3)
+		fmt.Fprint(w, `{"data": {
+  "c0": {"object": {"author": {"email": "bob@google.com", "user": {"login": "bob"}},
+                    "committer": {"email": "alice@google.com", "user": {"login": "alice"}}}},
+  "c1": {"object": null},
+  "c2": null},
+ "errors": [{"type": "NOT_FOUND", "message": "Could not resolve to a Repository"}]}`)
+	}))
+	defer server.Close()
+	matcher, err := NewGitHubMatcher(server.URL+"/api/v3/", "token1, token2")
+	req.NoError(err)
+	queries := []CommitQuery{
+		{"bob@google.com", "github.com/src-d/go-git", hash1},
+		{"alice@google.com", "https://github.com/src-d/go-git.git", hash1},
+		{"eve@google.com", "github.com/src-d/go-git", hash1},
+		{"bob@google.com", "github.com/src-d/go-git", hash2},
+		{"bob@google.com", "github.com/src-d/enry", hash3},
+		{"123+carol@users.noreply.github.com", "github.com/src-d/go-git", hash1},
+		{"bob@google.com", "gitlab.com/src-d/go-git", hash1},
+		{"bob@google.com", "github.com/src-d/go-git", "xxx"},
+	}
+	users, err := matcher.(CommitBatchMatcher).MatchByCommits(context.Background(), queries)
+	req.NoError(err)
+	req.Equal(map[CommitQuery]string{
+		queries[0]: "bob",
+		queries[1]: "alice",
+		queries[2]: "",
+		queries[3]: "",
+		queries[4]: "",
+		queries[5]: "carol",
+	}, users)
+	req.Len(tokens, 1)
+
+	gh := matcher.(GitHubMatcher)
+	req.Len(gh.clients, 2)
+	req.True(gh.client() != gh.client())
+}
+
+func TestGitHubMatcherMatchByCommitsError(t *testing.T) {
+	req := require.New(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/graphql", r.URL.Path)
+		fmt.Fprint(w, `{"errors": [{"type": "MAX_NODE_LIMIT_EXCEEDED", "message": "too many nodes"}]}`)
+	}))
+	defer server.Close()
+	matcher, err := NewGitHubMatcher(server.URL+"/", "")
+	req.NoError(err)
+	query := CommitQuery{"bob@google.com", "github.com/src-d/go-git", strings.Repeat("1", 40)}
+	users, err := matcher.(CommitBatchMatcher).MatchByCommits(context.Background(), []CommitQuery{query})
+	req.EqualError(err, "GitHub GraphQL: too many nodes")
+	req.Empty(users)
+}
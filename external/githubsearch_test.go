@@ -0,0 +1,91 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMajorityVote(t *testing.T) {
+	req := require.New(t)
+	user, share := majorityVote(map[string]int{"bob": 3, "alice": 1}, 5)
+	req.Equal("bob", user)
+	req.Equal(0.6, share)
+	user, _ = majorityVote(map[string]int{"bob": 2, "alice": 2}, 4)
+	req.Equal("", user)
+	user, _ = majorityVote(map[string]int{}, 3)
+	req.Equal("", user)
+}
+
+func newTestCommitSearchServer(t *testing.T) *httptest.Server {
+	commit := func(email, login string) string {
+		author := "null"
+		if login != "" {
+			author = fmt.Sprintf(`{"login": %q}`, login)
+		}
+		return fmt.Sprintf(`{"commit": {"author": {"email": %q}}, "author": %s}`, email, author)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/graphql":
+			fmt.Fprint(w, `{"data": {"c0": {"object": null}}}`)
+		case strings.HasPrefix(r.URL.Path, "/api/v3/repos/src-d/go-git/commits/"):
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message": "No commit found for SHA"}`)
+		case r.URL.Path == "/api/v3/search/commits":
+			require.Equal(t, "100", r.URL.Query().Get("per_page"))
+			var commits []string
+			switch r.URL.Query().Get("q") {
+			case "author-email:bob@google.com":
+				commits = []string{commit("bob@google.com", "bob"), commit("Bob@google.com", "bob"),
+					commit("bob@google.com", "robert"), commit("bob@google.com", ""),
+					commit("other@google.com", "other"), commit("other@google.com", "other")}
+			case "author-email:alice@google.com":
+				commits = []string{commit("alice@google.com", "alice"), commit("alice@google.com", "eve")}
+			}
+			fmt.Fprintf(w, `{"total_count": %d, "items": [%s]}`, len(commits), strings.Join(commits, ","))
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+		}
+	}))
+}
+
+func TestGitHubMatcherCommitSearch(t *testing.T) {
+	req := require.New(t)
+	server := newTestCommitSearchServer(t)
+	defer server.Close()
+	matcher, err := NewGitHubMatcher(server.URL+"/api/v3/", "")
+	req.NoError(err)
+	hash := strings.Repeat("1", 40)
+	ctx := context.Background()
+
+	_, err = matcher.MatchByCommit(ctx, "bob@google.com", "github.com/src-d/go-git", hash)
+	req.Error(err)
+	users, err := matcher.(CommitBatchMatcher).MatchByCommits(ctx, []CommitQuery{
+		{"bob@google.com", "github.com/src-d/go-git", hash}})
+	req.NoError(err)
+	req.Equal("", users[CommitQuery{"bob@google.com", "github.com/src-d/go-git", hash}])
+
+	matcher = matcher.(GitHubMatcher).WithCommitSearch(CommitSearchOptions{MinShare: 0.5})
+	user, err := matcher.MatchByCommit(ctx, "bob@google.com", "github.com/src-d/go-git", hash)
+	req.NoError(err)
+	req.Equal("bob", user)
+	_, err = matcher.MatchByCommit(ctx, "alice@google.com", "github.com/src-d/go-git", hash)
+	req.Equal(ErrNoMatches, err)
+	queries := []CommitQuery{
+		{"bob@google.com", "github.com/src-d/go-git", hash},
+		{"alice@google.com", "github.com/src-d/go-git", hash},
+	}
+	users, err = matcher.(CommitBatchMatcher).MatchByCommits(ctx, queries)
+	req.NoError(err)
+	req.Equal(map[CommitQuery]string{queries[0]: "bob", queries[1]: ""}, users)
+
+	matcher = matcher.(GitHubMatcher).WithCommitSearch(CommitSearchOptions{MinShare: 0.6})
+	_, err = matcher.MatchByCommit(ctx, "bob@google.com", "github.com/src-d/go-git", hash)
+	req.Equal(ErrNoMatches, err)
+}
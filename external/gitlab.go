@@ -4,8 +4,9 @@ import (
 	"context"
 	"errors"
 
-	"github.com/sirupsen/logrus"
 	"github.com/xanzy/go-gitlab"
+
+	"github.com/src-d/identity-matching/reporter"
 )
 
 // GitLabMatcher matches emails and GitLab users.
@@ -42,7 +43,7 @@ func (m GitLabMatcher) MatchByEmail(ctx context.Context, email string) (user str
 				return
 			}
 			if len(users) == 0 {
-				logrus.Warnf("unable to find users for email: %s", email)
+				reporter.Warnf("unable to find users for email: %s", email)
 				err = ErrNoMatches
 				return
 			}
@@ -0,0 +1,134 @@
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// JIRAMatcher matches emails and JIRA accounts. The user is the account ID in JIRA Cloud and
+// the user key in JIRA Server.
+type JIRAMatcher struct {
+	client   *http.Client
+	apiURL   string
+	username string
+	password string
+}
+
+type jiraUser struct {
+	AccountID string `json:"accountId"`
+	Key       string `json:"key"`
+}
+
+// NewJIRAMatcher creates a new matcher given the JIRA server URL and the credentials in the
+// "username:password" format, the password is the API token in JIRA Cloud.
+// The blank token means anonymous access.
+func NewJIRAMatcher(apiURL, token string) (Matcher, error) {
+	if apiURL == "" {
+		return nil, errors.New("the JIRA server URL must be specified")
+	}
+	m := JIRAMatcher{
+		client: http.DefaultClient,
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+	}
+	if token != "" {
+		parts := strings.SplitN(token, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("the JIRA token must be in the \"username:password\" format")
+		}
+		m.username, m.password = parts[0], parts[1]
+	}
+	return m, nil
+}
+
+// search requests the users which match the email. JIRA Cloud understands the "query" parameter
+// while JIRA Server understands the "username" parameter.
+func (m JIRAMatcher) search(ctx context.Context, param, email string) (
+	users []jiraUser, status int, err error) {
+	query := url.Values{param: {email}}
+	request, err := http.NewRequest(
+		http.MethodGet, m.apiURL+"/rest/api/2/user/search?"+query.Encode(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	request.Header.Set("Accept", "application/json")
+	if m.username != "" {
+		request.SetBasicAuth(m.username, m.password)
+	}
+	response, err := m.client.Do(request.WithContext(ctx))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, 0, context.Canceled
+		}
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, response.StatusCode, fmt.Errorf(
+			"JIRA HTTP %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+	err = json.Unmarshal(body, &users)
+	return users, response.StatusCode, err
+}
+
+// MatchByEmail returns the JIRA account with the given email. If several accounts share
+// the email, the one with the smallest ID is chosen.
+func (m JIRAMatcher) MatchByEmail(ctx context.Context, email string) (user string, err error) {
+	users, status, err := m.search(ctx, "query", email)
+	if status == http.StatusBadRequest {
+		users, status, err = m.search(ctx, "username", email)
+	}
+	if status == http.StatusNotFound {
+		return "", ErrNoMatches
+	}
+	if err != nil {
+		return "", err
+	}
+	var ids []string
+	for _, u := range users {
+		id := u.AccountID
+		if id == "" {
+			id = u.Key
+		}
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		reporter.Warnf("unable to find JIRA accounts for email: %s", email)
+		return "", ErrNoMatches
+	}
+	sort.Strings(ids)
+	if len(ids) > 1 {
+		reporter.Warnf("%d JIRA accounts share email %s, choosing %s", len(ids), email, ids[0])
+	}
+	return ids[0], nil
+}
+
+// SupportsMatchingByCommit indicates whether this Matcher allows querying identities by commit metadata.
+func (m JIRAMatcher) SupportsMatchingByCommit() bool {
+	return false
+}
+
+// MatchByCommit queries the identity of a given email address in a particular commit context.
+func (m JIRAMatcher) MatchByCommit(
+	ctx context.Context, email, repo, commit string) (user string, err error) {
+	return "", errors.New("not implemented")
+}
+
+// OnIdle does nothing here.
+func (m JIRAMatcher) OnIdle() error {
+	return nil
+}
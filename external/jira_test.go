@@ -0,0 +1,70 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestJIRAServer(t *testing.T, cloud bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/rest/api/2/user/search", r.URL.Path)
+		username, password, ok := r.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", username)
+		require.Equal(t, "secret", password)
+		email := r.URL.Query().Get("query")
+		if !cloud {
+			if email != "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			email = r.URL.Query().Get("username")
+		}
+		switch email {
+		case "bob@google.com":
+			fmt.Fprint(w, `[{"accountId": "5b10ac8d82e05b22cc7d4ef5", "key": "bob"}]`)
+		case "alice@google.com":
+			fmt.Fprint(w, `[{"key": "alice2"}, {"key": "alice"}]`)
+		case "error@google.com":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+}
+
+func TestJIRAMatcherMatchByEmail(t *testing.T) {
+	req := require.New(t)
+	_, err := NewJIRAMatcher("", "")
+	req.Error(err)
+	_, err = NewJIRAMatcher("https://jira.google.com", "user")
+	req.Error(err)
+	for _, cloud := range []bool{true, false} {
+		server := newTestJIRAServer(t, cloud)
+		m, err := NewJIRAMatcher(server.URL+"/", "user:secret")
+		req.NoError(err)
+		req.False(m.SupportsMatchingByCommit())
+
+		user, err := m.MatchByEmail(context.Background(), "bob@google.com")
+		req.NoError(err)
+		req.Equal("5b10ac8d82e05b22cc7d4ef5", user)
+		user, err = m.MatchByEmail(context.Background(), "alice@google.com")
+		req.NoError(err)
+		req.Equal("alice", user)
+		_, err = m.MatchByEmail(context.Background(), "eve@google.com")
+		req.Equal(ErrNoMatches, err)
+		_, err = m.MatchByEmail(context.Background(), "error@google.com")
+		req.Error(err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err = m.MatchByEmail(ctx, "bob@google.com")
+		req.Equal(context.Canceled, err)
+		server.Close()
+	}
+}
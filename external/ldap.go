@@ -0,0 +1,195 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// ldapEmailPlaceholder is replaced with the escaped email in the search filter.
+const ldapEmailPlaceholder = "{email}"
+
+// ldapConn is the subset of *ldap.Conn which LDAPMatcher uses.
+type ldapConn interface {
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close()
+}
+
+// LDAPMatcher matches emails and LDAP or Active Directory entries. The user is the value of
+// the configured attribute, e.g. employeeID, or the DN of the entry.
+type LDAPMatcher struct {
+	address     string
+	baseDN      string
+	idAttribute string
+	scope       int
+	filter      string
+	bindDN      string
+	password    string
+	dial        func(address string) (ldapConn, error)
+	// conn is connected lazily, guarded by lock
+	conn ldapConn
+	lock *sync.Mutex
+}
+
+// NewLDAPMatcher creates a new matcher given the LDAP URL (RFC 4516) and the bind credentials
+// in the "bindDN:password" format. The blank token means anonymous bind. The URL is
+//
+//	ldap[s]://host[:port]/<base DN>[?<ID attribute>[?<scope>[?<filter>]]]
+//
+// The ID attribute defaults to the DN, the scope - to "sub" and the filter - to "(mail={email})".
+func NewLDAPMatcher(apiURL, token string) (Matcher, error) {
+	if apiURL == "" {
+		return nil, errors.New("the LDAP URL must be specified")
+	}
+	parsed, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "ldap" && parsed.Scheme != "ldaps" {
+		return nil, fmt.Errorf("unsupported LDAP URL scheme: %s", parsed.Scheme)
+	}
+	m := &LDAPMatcher{
+		address: parsed.Scheme + "://" + parsed.Host,
+		baseDN:  strings.TrimPrefix(parsed.Path, "/"),
+		scope:   ldap.ScopeWholeSubtree,
+		filter:  "(mail=" + ldapEmailPlaceholder + ")",
+		dial: func(address string) (ldapConn, error) {
+			return ldap.DialURL(address)
+		},
+		lock: &sync.Mutex{},
+	}
+	var parts []string
+	if parsed.RawQuery != "" {
+		parts = strings.Split(parsed.RawQuery, "?")
+	}
+	for i := range parts {
+		if parts[i], err = url.QueryUnescape(parts[i]); err != nil {
+			return nil, err
+		}
+	}
+	if len(parts) > 0 {
+		m.idAttribute = parts[0]
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		scopes := map[string]int{
+			"base": ldap.ScopeBaseObject, "one": ldap.ScopeSingleLevel, "sub": ldap.ScopeWholeSubtree}
+		scope, exists := scopes[parts[1]]
+		if !exists {
+			return nil, fmt.Errorf("unsupported LDAP search scope: %s", parts[1])
+		}
+		m.scope = scope
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if !strings.Contains(parts[2], ldapEmailPlaceholder) {
+			return nil, fmt.Errorf("the LDAP filter must contain %s", ldapEmailPlaceholder)
+		}
+		m.filter = parts[2]
+	}
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("LDAP URL extensions are not supported: %s", parts[3])
+	}
+	if token != "" {
+		parts := strings.SplitN(token, ":", 2)
+		if len(parts) != 2 {
+			return nil, errors.New("the LDAP token must be in the \"bindDN:password\" format")
+		}
+		m.bindDN, m.password = parts[0], parts[1]
+	}
+	return m, nil
+}
+
+// connect returns the established LDAP connection or dials a new one.
+func (m *LDAPMatcher) connect() (ldapConn, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.conn != nil {
+		return m.conn, nil
+	}
+	conn, err := m.dial(m.address)
+	if err != nil {
+		return nil, err
+	}
+	if m.bindDN != "" {
+		if err := conn.Bind(m.bindDN, m.password); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	m.conn = conn
+	return conn, nil
+}
+
+// MatchByEmail returns the directory entry with the given email. If several entries share
+// the email, the one with the smallest user is chosen.
+func (m *LDAPMatcher) MatchByEmail(ctx context.Context, email string) (user string, err error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	conn, err := m.connect()
+	if err != nil {
+		return "", err
+	}
+	var attributes []string
+	if m.idAttribute != "" {
+		attributes = []string{m.idAttribute}
+	}
+	filter := strings.Replace(m.filter, ldapEmailPlaceholder, ldap.EscapeFilter(email), -1)
+	result, err := conn.Search(ldap.NewSearchRequest(
+		m.baseDN, m.scope, ldap.NeverDerefAliases, 0, 0, false, filter, attributes, nil))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return "", ErrNoMatches
+		}
+		return "", err
+	}
+	var users []string
+	for _, entry := range result.Entries {
+		user := entry.DN
+		if m.idAttribute != "" {
+			user = entry.GetAttributeValue(m.idAttribute)
+		}
+		if user != "" {
+			users = append(users, user)
+		}
+	}
+	if len(users) == 0 {
+		reporter.Warnf("unable to find LDAP entries for email: %s", email)
+		return "", ErrNoMatches
+	}
+	sort.Strings(users)
+	if len(users) > 1 {
+		reporter.Warnf("%d LDAP entries share email %s, choosing %s", len(users), email, users[0])
+	}
+	return users[0], nil
+}
+
+// SupportsMatchingByCommit indicates whether this Matcher allows querying identities by commit metadata.
+func (m *LDAPMatcher) SupportsMatchingByCommit() bool {
+	return false
+}
+
+// MatchByCommit queries the identity of a given email address in a particular commit context.
+func (m *LDAPMatcher) MatchByCommit(
+	ctx context.Context, email, repo, commit string) (user string, err error) {
+	return "", errors.New("not implemented")
+}
+
+// OnIdle closes the LDAP connection, the next query reconnects.
+func (m *LDAPMatcher) OnIdle() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.conn != nil {
+		m.conn.Close()
+		m.conn = nil
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package external
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLDAPConn struct {
+	binds    []string
+	requests []*ldap.SearchRequest
+	closed   bool
+}
+
+func (c *fakeLDAPConn) Bind(username, password string) error {
+	c.binds = append(c.binds, username+":"+password)
+	return nil
+}
+
+func (c *fakeLDAPConn) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.requests = append(c.requests, request)
+	entry := func(dn, employeeID string) *ldap.Entry {
+		return ldap.NewEntry(dn, map[string][]string{"employeeID": {employeeID}})
+	}
+	switch request.Filter {
+	case "(&(objectClass=person)(mail=bob@google.com))":
+		return &ldap.SearchResult{Entries: []*ldap.Entry{entry("cn=bob,dc=google,dc=com", "1001")}}, nil
+	case "(&(objectClass=person)(mail=alice\\28work\\29@google.com))":
+		return &ldap.SearchResult{Entries: []*ldap.Entry{
+			entry("cn=alice2,dc=google,dc=com", "1003"), entry("cn=alice,dc=google,dc=com", "1002")}}, nil
+	case "(&(objectClass=person)(mail=error@google.com))":
+		return nil, errors.New("LDAP failure")
+	case "(&(objectClass=person)(mail=missing@google.com))":
+		return nil, ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("no such object"))
+	}
+	return &ldap.SearchResult{}, nil
+}
+
+func (c *fakeLDAPConn) Close() {
+	c.closed = true
+}
+
+func TestNewLDAPMatcher(t *testing.T) {
+	req := require.New(t)
+	for _, apiURL := range []string{
+		"", "http://google.com", "ldap://google.com/dc=google?uid?tree",
+		"ldap://google.com/dc=google?uid?sub?(mail=x)", "ldap://google.com/dc=google?uid?sub??ext",
+	} {
+		_, err := NewLDAPMatcher(apiURL, "")
+		req.Error(err, apiURL)
+	}
+	_, err := NewLDAPMatcher("ldap://google.com", "admin")
+	req.Error(err)
+
+	m, err := NewLDAPMatcher("ldaps://google.com:636/dc=google,dc=com", "cn=admin:pass:word")
+	req.NoError(err)
+	ldapMatcher := m.(*LDAPMatcher)
+	req.Equal("ldaps://google.com:636", ldapMatcher.address)
+	req.Equal("dc=google,dc=com", ldapMatcher.baseDN)
+	req.Equal("", ldapMatcher.idAttribute)
+	req.Equal(ldap.ScopeWholeSubtree, ldapMatcher.scope)
+	req.Equal("(mail={email})", ldapMatcher.filter)
+	req.Equal("cn=admin", ldapMatcher.bindDN)
+	req.Equal("pass:word", ldapMatcher.password)
+	req.False(m.SupportsMatchingByCommit())
+}
+
+func TestLDAPMatcherMatchByEmail(t *testing.T) {
+	req := require.New(t)
+	m, err := NewLDAPMatcher("ldap://google.com/dc=google,dc=com?employeeID?one?"+
+		"(%26(objectClass=person)(mail={email}))", "cn=admin:secret")
+	req.NoError(err)
+	conn := &fakeLDAPConn{}
+	m.(*LDAPMatcher).dial = func(address string) (ldapConn, error) {
+		req.Equal("ldap://google.com", address)
+		return conn, nil
+	}
+
+	user, err := m.MatchByEmail(context.Background(), "bob@google.com")
+	req.NoError(err)
+	req.Equal("1001", user)
+	req.Equal("dc=google,dc=com", conn.requests[0].BaseDN)
+	req.Equal(ldap.ScopeSingleLevel, conn.requests[0].Scope)
+	req.Equal([]string{"employeeID"}, conn.requests[0].Attributes)
+	user, err = m.MatchByEmail(context.Background(), "alice(work)@google.com")
+	req.NoError(err)
+	req.Equal("1002", user)
+	req.Equal([]string{"cn=admin:secret"}, conn.binds)
+
+	_, err = m.MatchByEmail(context.Background(), "eve@google.com")
+	req.Equal(ErrNoMatches, err)
+	_, err = m.MatchByEmail(context.Background(), "missing@google.com")
+	req.Equal(ErrNoMatches, err)
+	_, err = m.MatchByEmail(context.Background(), "error@google.com")
+	req.EqualError(err, "LDAP failure")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = m.MatchByEmail(ctx, "bob@google.com")
+	req.Equal(context.Canceled, err)
+
+	req.NoError(m.OnIdle())
+	req.True(conn.closed)
+	m.(*LDAPMatcher).idAttribute = ""
+	user, err = m.MatchByEmail(context.Background(), "bob@google.com")
+	req.NoError(err)
+	req.Equal("cn=bob,dc=google,dc=com", user)
+	req.Len(conn.binds, 2)
+}
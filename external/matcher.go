@@ -17,15 +17,46 @@ type Matcher interface {
 	OnIdle() error
 }
 
+// PreferredEmailMatcher is the optional interface of the Matcher-s which also know
+// the preferred emails of the users.
+type PreferredEmailMatcher interface {
+	// PreferredEmail returns the preferred email of the user which was matched before.
+	PreferredEmail(user string) (email string, exists bool)
+}
+
+// CommitQuery is the email of the author or the committer of the commit in the repository,
+// see Matcher.MatchByCommit.
+type CommitQuery struct {
+	Email  string
+	Repo   string
+	Commit string
+}
+
+// CommitBatchMatcher is the optional interface of the Matcher-s which resolve many commits
+// in few requests.
+type CommitBatchMatcher interface {
+	// MatchByCommits resolves the queries the same way as MatchByCommit. The queries without
+	// a match map to "" and the queries which failed are absent, so that the caller may retry
+	// them one by one.
+	MatchByCommits(ctx context.Context, queries []CommitQuery) (map[CommitQuery]string, error)
+}
+
 // MatcherConstructor is the Matcher constructor function type.
 type MatcherConstructor func(apiURL, token string) (Matcher, error)
 
 // ErrNoMatches is returned when no matches were found.
 var ErrNoMatches = errors.New("no matches found")
 
+// ErrUnavailable is wrapped by the errors of the Matcher-s whose API kept failing with
+// the transient errors after all the retries.
+var ErrUnavailable = errors.New("the identity provider is unavailable")
+
 // Matchers is the registered external matcher constructors mapped to shorthands.
 var Matchers = map[string]MatcherConstructor{
 	"github":    NewGitHubMatcher,
 	"gitlab":    NewGitLabMatcher,
 	"bitbucket": NewBitBucketMatcher,
+	"gerrit":    NewGerritMatcher,
+	"jira":      NewJIRAMatcher,
+	"ldap":      NewLDAPMatcher,
 }
@@ -0,0 +1,383 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+	simplegraph "gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/traverse"
+)
+
+// ExternalIDs map the providers of the external identities, e.g. "github" or "ldap", to the IDs
+// of the person there. The empty provider means that it is unknown.
+type ExternalIDs map[string]string
+
+// Copy returns the copy of the IDs. nil stays nil.
+func (ids ExternalIDs) Copy() ExternalIDs {
+	if ids == nil {
+		return nil
+	}
+	result := make(ExternalIDs, len(ids))
+	for provider, id := range ids {
+		result[provider] = id
+	}
+	return result
+}
+
+// Primary returns the ID of the given provider if the person has it, otherwise the ID of
+// the first provider in the alphabetical order. It is written to the formats which keep a single
+// external ID per person. The result is empty if there are no IDs.
+func (ids ExternalIDs) Primary(provider string) (string, string) {
+	if id, exists := ids[provider]; exists {
+		return provider, id
+	}
+	providers := ids.providers()
+	if len(providers) == 0 {
+		return "", ""
+	}
+	return providers[0], ids[providers[0]]
+}
+
+// String formats the IDs as "provider:id" sorted by provider and joined with ",",
+// see formatExternalAccounts and parseExternalIDs.
+func (ids ExternalIDs) String() string {
+	return formatExternalAccounts(ids.accounts())
+}
+
+// parseExternalIDs is the inverse of ExternalIDs.String. The empty string is nil.
+func parseExternalIDs(str string) ExternalIDs {
+	var ids ExternalIDs
+	for _, account := range parseExternalAccounts(str) {
+		if ids == nil {
+			ids = ExternalIDs{}
+		}
+		ids[account.Provider] = account.ID
+	}
+	return ids
+}
+
+// providers returns the sorted providers.
+func (ids ExternalIDs) providers() []string {
+	providers := make([]string, 0, len(ids))
+	for provider := range ids {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// accounts returns the IDs as the accounts sorted by provider.
+func (ids ExternalIDs) accounts() []ExternalAccount {
+	var accounts []ExternalAccount
+	for _, provider := range ids.providers() {
+		accounts = append(accounts, ExternalAccount{provider, ids[provider]})
+	}
+	return accounts
+}
+
+// conflict returns the first provider in the alphabetical order with different IDs in both sets.
+func (ids ExternalIDs) conflict(other ExternalIDs) (string, bool) {
+	for _, provider := range ids.providers() {
+		if id, exists := other[provider]; exists && id != ids[provider] {
+			return provider, true
+		}
+	}
+	return "", false
+}
+
+// equal checks whether both sets have the same IDs. nil equals the empty set.
+func (ids ExternalIDs) equal(other ExternalIDs) bool {
+	if len(ids) != len(other) {
+		return false
+	}
+	for provider, id := range ids {
+		if otherID, exists := other[provider]; !exists || otherID != id {
+			return false
+		}
+	}
+	return true
+}
+
+// merge returns the union of the sets, the IDs of ids take precedence. It returns ids if other
+// adds nothing.
+func (ids ExternalIDs) merge(other ExternalIDs) ExternalIDs {
+	var result ExternalIDs
+	for provider, id := range other {
+		if _, exists := ids[provider]; exists {
+			continue
+		}
+		if result == nil {
+			result = ids.Copy()
+			if result == nil {
+				result = ExternalIDs{}
+			}
+		}
+		result[provider] = id
+	}
+	if result == nil {
+		return ids
+	}
+	return result
+}
+
+// ExternalIDConflictPolicy decides what happens when the evidence joins the identities with
+// different external IDs of the same provider, e.g. the same person with two GitHub accounts.
+type ExternalIDConflictPolicy string
+
+const (
+	// ExternalIDConflictRefuse fails the matching with an error. It is the default.
+	ExternalIDConflictRefuse ExternalIDConflictPolicy = "refuse"
+	// ExternalIDConflictPriority merges the identities and keeps the external ID of the identities
+	// with more commits. The other external ID is dropped.
+	ExternalIDConflictPriority ExternalIDConflictPolicy = "priority"
+	// ExternalIDConflictKeepBoth merges the identities like ExternalIDConflictPriority and keeps
+	// all the external IDs in Person.ExternalAccounts.
+	ExternalIDConflictKeepBoth ExternalIDConflictPolicy = "keep_both"
+	// ExternalIDConflictReview keeps the identities apart: the edge between them stays inactive
+	// and is recorded for the manual review, see IdentityGraph.ExternalIDConflicts.
+	ExternalIDConflictReview ExternalIDConflictPolicy = "review"
+)
+
+// ExternalIDConflictPolicies are all the supported policies.
+var ExternalIDConflictPolicies = []ExternalIDConflictPolicy{
+	ExternalIDConflictRefuse, ExternalIDConflictPriority, ExternalIDConflictKeepBoth,
+	ExternalIDConflictReview,
+}
+
+// ExternalIDOptions resolve the conflicts between the external IDs, see ReduceOptions.ExternalIDs.
+type ExternalIDOptions struct {
+	// Conflicts is the policy, the empty value means ExternalIDConflictRefuse.
+	Conflicts ExternalIDConflictPolicy
+	// Provider is the provider of the external matcher, e.g. "github", which becomes the key of
+	// the matched IDs in Person.ExternalIDs.
+	Provider string
+}
+
+// ExternalAccount is the external ID tagged with its provider.
+type ExternalAccount struct {
+	Provider string
+	ID       string
+}
+
+// String formats the account as "provider:id".
+func (account ExternalAccount) String() string {
+	return account.Provider + ":" + account.ID
+}
+
+// ExternalIDConflict is the edge which was not activated because it would join the identities
+// with different external IDs, see ExternalIDConflictReview.
+type ExternalIDConflict struct {
+	From int64
+	To   int64
+	// Provider is the provider of the conflicting external IDs.
+	Provider string
+	// ExternalIDs are the external IDs of From and To.
+	ExternalIDs [2]string
+	// Evidence is the evidence which would have activated the edge.
+	Evidence Evidence
+}
+
+// resolveExternalIDs sets the same external IDs to all the nodes in the components of node1
+// and node2, which have different external IDs of the same provider, according to
+// ExternalIDConflictPriority: each conflict is won by the component with more commits or by
+// the smaller ID if the commits are equal. ExternalIDConflictKeepBoth also gives them all
+// the external accounts of both components.
+func (g *IdentityGraph) resolveExternalIDs(node1, node2 node) {
+	var components [2][]node
+	var commits [2]int
+	var accounts []ExternalAccount
+	for side, start := range []node{node1, node2} {
+		var w traverse.DepthFirst
+		w.Walk(g.graph, start, func(sn simplegraph.Node) bool {
+			n := sn.(node)
+			components[side] = append(components[side], n)
+			commits[side] += n.Value.Commits
+			accounts = mergeExternalAccounts(accounts, n.Value.ExternalAccounts)
+			return false
+		})
+		accounts = mergeExternalAccounts(accounts, start.Value.ExternalIDs.accounts())
+	}
+	ids1, ids2 := node1.Value.ExternalIDs, node2.Value.ExternalIDs
+	winners := ids1.merge(ids2).Copy()
+	for provider, id1 := range ids1 {
+		id2, exists := ids2[provider]
+		if !exists || id1 == id2 {
+			continue
+		}
+		if commits[1] > commits[0] || (commits[1] == commits[0] && id2 < id1) {
+			winners[provider] = id2
+		}
+	}
+	if g.externalIDs.Conflicts != ExternalIDConflictKeepBoth {
+		accounts = nil
+	}
+	for _, component := range components {
+		for _, n := range component {
+			n.Value.ExternalIDs = winners.Copy()
+			if accounts != nil {
+				n.Value.ExternalAccounts = append([]ExternalAccount(nil), accounts...)
+			}
+		}
+	}
+	reporter.Increment("external id conflicts resolved")
+}
+
+// recordExternalIDConflict remembers the edge which ExternalIDConflictReview keeps inactive.
+// Each edge is recorded once.
+func (g *IdentityGraph) recordExternalIDConflict(node1, node2 node, provider string,
+	evidence Evidence) {
+	key := newEdgeKey(node1.ID(), node2.ID())
+	if g.externalIDConflictEdges == nil {
+		g.externalIDConflictEdges = map[edgeKey]struct{}{}
+	}
+	if _, exists := g.externalIDConflictEdges[key]; exists {
+		return
+	}
+	g.externalIDConflictEdges[key] = struct{}{}
+	externalIDs := [2]string{node1.Value.ExternalIDs[provider], node2.Value.ExternalIDs[provider]}
+	if node1.ID() != key.from {
+		externalIDs[0], externalIDs[1] = externalIDs[1], externalIDs[0]
+	}
+	g.externalIDConflicts = append(g.externalIDConflicts, ExternalIDConflict{
+		From: key.from, To: key.to, Provider: provider, ExternalIDs: externalIDs, Evidence: evidence})
+	reporter.Increment("external id conflicts recorded")
+}
+
+// ExternalIDConflicts returns the edges which were kept inactive because of the different
+// external IDs under ExternalIDConflictReview, sorted by the node IDs.
+func (g *IdentityGraph) ExternalIDConflicts() []ExternalIDConflict {
+	result := append([]ExternalIDConflict(nil), g.externalIDConflicts...)
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].From != result[j].From {
+			return result[i].From < result[j].From
+		}
+		return result[i].To < result[j].To
+	})
+	return result
+}
+
+var externalIDConflictsCSVHeader = []string{
+	"from", "to", "provider", "from_external_id", "to_external_id", "from_emails", "to_emails",
+	"evidence"}
+
+// WriteExternalIDConflicts saves the conflicts to the CSV file for the manual review. The emails
+// of each node are joined with "; ". The reviewers may turn the conflicts into
+// the constraints, see ReadConstraints.
+func (g *IdentityGraph) WriteExternalIDConflicts(path string) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write(externalIDConflictsCSVHeader); err != nil {
+		return
+	}
+	for _, conflict := range g.ExternalIDConflicts() {
+		if err = writer.Write([]string{
+			strconv.FormatInt(conflict.From, 10), strconv.FormatInt(conflict.To, 10), conflict.Provider,
+			conflict.ExternalIDs[0], conflict.ExternalIDs[1],
+			strings.Join(g.nodes[conflict.From].Emails, "; "),
+			strings.Join(g.nodes[conflict.To].Emails, "; "),
+			string(conflict.Evidence.Kind) + ":" + conflict.Evidence.Value,
+		}); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// sortExternalAccounts sorts the accounts by provider and ID.
+func sortExternalAccounts(accounts []ExternalAccount) {
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Provider != accounts[j].Provider {
+			return accounts[i].Provider < accounts[j].Provider
+		}
+		return accounts[i].ID < accounts[j].ID
+	})
+}
+
+// mergeExternalAccounts returns the sorted union of the accounts.
+func mergeExternalAccounts(accounts []ExternalAccount, other []ExternalAccount) []ExternalAccount {
+	if len(other) == 0 {
+		return accounts
+	}
+	seen := map[ExternalAccount]struct{}{}
+	var result []ExternalAccount
+	for _, list := range [][]ExternalAccount{accounts, other} {
+		for _, account := range list {
+			if _, exists := seen[account]; !exists {
+				seen[account] = struct{}{}
+				result = append(result, account)
+			}
+		}
+	}
+	sortExternalAccounts(result)
+	return result
+}
+
+// externalAccountEscaper escapes the separators of formatExternalAccounts in the providers and
+// the IDs, e.g. the commas of the LDAP distinguished names. The values without them stay the same.
+var externalAccountEscaper = strings.NewReplacer("%", "%25", ",", "%2C", ":", "%3A")
+
+// externalAccountUnescaper reverts externalAccountEscaper.
+var externalAccountUnescaper = strings.NewReplacer("%25", "%", "%2C", ",", "%3A", ":")
+
+// formatExternalAccounts joins the accounts as "provider:id" with ",", see parseExternalAccounts.
+// The ",", ":" and "%" inside the providers and the IDs are percent-encoded.
+func formatExternalAccounts(accounts []ExternalAccount) string {
+	strs := make([]string, len(accounts))
+	for i, account := range accounts {
+		strs[i] = externalAccountEscaper.Replace(account.Provider) + ":" +
+			externalAccountEscaper.Replace(account.ID)
+	}
+	return strings.Join(strs, ",")
+}
+
+// parseExternalAccounts is the inverse of formatExternalAccounts.
+func parseExternalAccounts(str string) []ExternalAccount {
+	if str == "" {
+		return nil
+	}
+	var accounts []ExternalAccount
+	for _, item := range strings.Split(str, ",") {
+		parts := strings.SplitN(item, ":", 2)
+		if len(parts) == 2 {
+			accounts = append(accounts, ExternalAccount{
+				externalAccountUnescaper.Replace(parts[0]), externalAccountUnescaper.Replace(parts[1])})
+		}
+	}
+	return accounts
+}
+
+// ParseExternalIDConflictPolicy validates the name of the policy. The empty name is
+// ExternalIDConflictRefuse.
+func ParseExternalIDConflictPolicy(name string) (ExternalIDConflictPolicy, error) {
+	if name == "" {
+		return ExternalIDConflictRefuse, nil
+	}
+	for _, policy := range ExternalIDConflictPolicies {
+		if string(policy) == name {
+			return policy, nil
+		}
+	}
+	return "", fmt.Errorf("unknown external ID conflict policy: %s", name)
+}
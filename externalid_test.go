@@ -0,0 +1,221 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newExternalIDTestGraph(opts ExternalIDOptions) (People, *IdentityGraph) {
+	people := newGraphTestPeople()
+	people[1].ExternalIDs = ExternalIDs{"github": "bob"}
+	people[1].Commits = 1
+	people[3].ExternalIDs = ExternalIDs{"github": "alice"}
+	people[3].Commits = 5
+	g := newIdentityGraph(people, nil, 0)
+	g.externalIDs = opts
+	return people, g
+}
+
+func TestParseExternalIDConflictPolicy(t *testing.T) {
+	req := require.New(t)
+	policy, err := ParseExternalIDConflictPolicy("")
+	req.NoError(err)
+	req.Equal(ExternalIDConflictRefuse, policy)
+	policy, err = ParseExternalIDConflictPolicy("keep_both")
+	req.NoError(err)
+	req.Equal(ExternalIDConflictKeepBoth, policy)
+	_, err = ParseExternalIDConflictPolicy("merge")
+	req.EqualError(err, "unknown external ID conflict policy: merge")
+}
+
+func TestExternalIDConflictRefuse(t *testing.T) {
+	_, g := newExternalIDTestGraph(ExternalIDOptions{Conflicts: ExternalIDConflictRefuse})
+	err := g.addEvidence(g.node(1), g.node(3), EvidenceName, "x")
+	require.EqualError(t, err,
+		"conflicting external IDs: cannot set edge between nodes with different ExternalIDs: bob alice")
+	require.ErrorIs(t, err, ErrExternalIDConflict)
+}
+
+func TestExternalIDConflictPriority(t *testing.T) {
+	req := require.New(t)
+	people, g := newExternalIDTestGraph(ExternalIDOptions{Conflicts: ExternalIDConflictPriority})
+	req.NoError(g.addEvidence(g.node(1), g.node(2), EvidenceName, "bob"))
+	req.NoError(g.addEvidence(g.node(2), g.node(3), EvidenceName, "x"))
+	edge, exists := g.Edge(2, 3)
+	req.True(exists)
+	req.True(edge.Active)
+	// alice has more commits
+	for _, id := range []int64{1, 2, 3} {
+		req.Equal(ExternalIDs{"github": "alice"}, people[id].ExternalIDs)
+		req.Nil(people[id].ExternalAccounts)
+	}
+
+	people, g = newExternalIDTestGraph(ExternalIDOptions{Conflicts: ExternalIDConflictPriority})
+	people[1].ExternalIDs["gitlab"] = "bobby"
+	people[1].Commits = 5
+	req.NoError(g.addEvidence(g.node(1), g.node(3), EvidenceName, "x"))
+	// the same commits, the smaller ID wins and the other providers are kept
+	req.Equal(ExternalIDs{"github": "alice", "gitlab": "bobby"}, people[1].ExternalIDs)
+	req.Equal(ExternalIDs{"github": "alice", "gitlab": "bobby"}, people[3].ExternalIDs)
+}
+
+func TestExternalIDsDifferentProviders(t *testing.T) {
+	req := require.New(t)
+	people, g := newExternalIDTestGraph(ExternalIDOptions{})
+	people[3].ExternalIDs = ExternalIDs{"ldap": "alice"}
+	req.NoError(g.addEvidence(g.node(3), g.node(4), EvidenceName, "alice"))
+	req.NoError(g.addEvidence(g.node(1), g.node(3), EvidenceName, "x"))
+	for _, id := range []int64{1, 3, 4} {
+		req.Equal(ExternalIDs{"github": "bob", "ldap": "alice"}, people[id].ExternalIDs)
+	}
+	req.NoError(g.Reduce(context.Background(), people))
+	req.Equal(ExternalIDs{"github": "bob", "ldap": "alice"}, people[1].ExternalIDs)
+}
+
+func TestExternalIDConflictKeepBoth(t *testing.T) {
+	req := require.New(t)
+	people, g := newExternalIDTestGraph(ExternalIDOptions{Conflicts: ExternalIDConflictKeepBoth,
+		Provider: "github"})
+	req.NoError(g.addEvidence(g.node(1), g.node(3), EvidenceName, "x"))
+	req.NoError(g.addEvidence(g.node(3), g.node(4), EvidenceName, "alice"))
+	accounts := []ExternalAccount{{"github", "alice"}, {"github", "bob"}}
+	for _, id := range []int64{1, 3, 4} {
+		req.Equal(ExternalIDs{"github": "alice"}, people[id].ExternalIDs)
+		req.Equal(accounts, people[id].ExternalAccounts)
+	}
+	req.NoError(g.Reduce(context.Background(), people))
+	req.Equal(accounts, people[1].ExternalAccounts)
+	req.Equal("alice", people[1].ExternalIDs["github"])
+}
+
+func TestExternalIDConflictReview(t *testing.T) {
+	req := require.New(t)
+	people, g := newExternalIDTestGraph(ExternalIDOptions{Conflicts: ExternalIDConflictReview})
+	req.NoError(g.addEvidence(g.node(3), g.node(1), EvidenceName, "x"))
+	req.NoError(g.addEvidence(g.node(1), g.node(3), EvidenceEmail, "y"))
+	edge, exists := g.Edge(1, 3)
+	req.True(exists)
+	req.False(edge.Active)
+	req.Equal([]ExternalIDConflict{{From: 1, To: 3, Provider: "github",
+		ExternalIDs: [2]string{"bob", "alice"}, Evidence: Evidence{EvidenceName, "x", 1}}},
+		g.ExternalIDConflicts())
+	req.Equal("bob", people[1].ExternalIDs["github"])
+
+	dir, err := ioutil.TempDir("", "externalid")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "conflicts.csv")
+	req.NoError(g.WriteExternalIDConflicts(path))
+	content, err := ioutil.ReadFile(path)
+	req.NoError(err)
+	req.Equal("from,to,provider,from_external_id,to_external_id,from_emails,to_emails,evidence\n"+
+		"1,3,github,bob,alice,bob@google.com,alice@google.com,name:x\n", string(content))
+}
+
+func TestExternalIDs(t *testing.T) {
+	req := require.New(t)
+	ids := ExternalIDs{"github": "bob", "ldap": "b.smith"}
+	req.Equal("github:bob,ldap:b.smith", ids.String())
+	req.Equal(ids, parseExternalIDs(ids.String()))
+	req.Nil(parseExternalIDs(""))
+	dn := ExternalIDs{"github": "bob", "ldap": "cn=Bob,ou=people,dc=example,dc=com", "x:y": "50%"}
+	req.Equal("github:bob,ldap:cn=Bob%2Cou=people%2Cdc=example%2Cdc=com,x%3Ay:50%25", dn.String())
+	req.Equal(dn, parseExternalIDs(dn.String()))
+	provider, id := ids.Primary("ldap")
+	req.Equal("ldap", provider)
+	req.Equal("b.smith", id)
+	provider, id = ids.Primary("gitlab")
+	req.Equal("github", provider)
+	req.Equal("bob", id)
+	provider, id = ExternalIDs(nil).Primary("gitlab")
+	req.Equal("", provider)
+	req.Equal("", id)
+	_, conflicts := ids.conflict(ExternalIDs{"gitlab": "bobby", "ldap": "b.smith"})
+	req.False(conflicts)
+	provider, conflicts = ids.conflict(ExternalIDs{"ldap": "bsmith"})
+	req.True(conflicts)
+	req.Equal("ldap", provider)
+	req.Equal(ExternalIDs{"github": "bob", "gitlab": "bobby", "ldap": "b.smith"},
+		ids.merge(ExternalIDs{"gitlab": "bobby", "ldap": "bsmith"}))
+	req.Equal(ExternalIDs{"github": "bob", "ldap": "b.smith"}, ids)
+	req.True(ids.equal(ids.Copy()))
+	req.True(ExternalIDs(nil).equal(ExternalIDs{}))
+}
+
+func TestExternalAccountsParquet(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "externalid")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people := People{
+		1: {ID: 1, Emails: []string{"bob@google.com"},
+			ExternalIDs: ExternalIDs{"github": "bob", "ldap": "cn=Bob,ou=people,dc=example,dc=com"},
+			ExternalAccounts: []ExternalAccount{{"github", "bob"}, {"gitlab", "bob2"},
+				{"ldap", "cn=Bob,ou=people,dc=example,dc=com"}, {"ldap", "uid=bob:1"}}},
+		2: {ID: 2, Emails: []string{"alice@google.com"}},
+	}
+	path := filepath.Join(dir, "identities.parquet")
+	req.NoError(people.WriteToParquet(path, "github"))
+	stored, _, err := readFromParquet(path)
+	req.NoError(err)
+	req.Equal(people[1].ExternalAccounts, stored[1].ExternalAccounts)
+	req.Nil(stored[2].ExternalAccounts)
+	req.Equal(people[1].ExternalIDs, stored[1].ExternalIDs)
+	req.Nil(stored[2].ExternalIDs)
+}
+
+// parquetPersonIdentityV1 is parquetPersonIdentity before the external IDs of several providers.
+type parquetPersonIdentityV1 struct {
+	ID                 int64  `parquet:"name=id, type=INT_64"`
+	PrimaryName        string `parquet:"name=primary_name, type=UTF8"`
+	PrimaryEmail       string `parquet:"name=primary_email, type=UTF8"`
+	ExternalIDProvider string `parquet:"name=external_id_provider, type=UTF8"`
+	ExternalID         string `parquet:"name=external_id, type=UTF8"`
+	IsBot              bool   `parquet:"name=is_bot, type=BOOLEAN"`
+	FirstCommit        int64  `parquet:"name=first_commit, type=TIMESTAMP_MILLIS"`
+	LastCommit         int64  `parquet:"name=last_commit, type=TIMESTAMP_MILLIS"`
+	Commits            int64  `parquet:"name=commits, type=INT_64"`
+	RecentCommits      int64  `parquet:"name=recent_commits, type=INT_64"`
+	Repositories       int64  `parquet:"name=repositories, type=INT_64"`
+}
+
+func TestReadParquetWithoutExternalIDs(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "externalid")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people := People{
+		1: {ID: 1, Emails: []string{"bob@google.com"}, PrimaryName: "bob", Commits: 3},
+		2: {ID: 2, Emails: []string{"alice@google.com"}},
+	}
+	path := filepath.Join(dir, "identities.parquet")
+	req.NoError(people.WriteToParquet(path, "github"))
+	_, pathIDs := preparePaths(path)
+	func() {
+		pw, cleanup := newParquetWriter(pathIDs, new(parquetPersonIdentityV1))
+		defer cleanup()
+		req.NoError(pw.Write(parquetPersonIdentityV1{ID: 1, PrimaryName: "bob",
+			ExternalIDProvider: "github", ExternalID: "bob", Commits: 3}))
+		req.NoError(pw.Write(parquetPersonIdentityV1{ID: 2}))
+	}()
+	stored, provider, err := readFromParquet(path)
+	req.NoError(err)
+	req.Equal("github", provider)
+	req.Equal(ExternalIDs{"github": "bob"}, stored[1].ExternalIDs)
+	req.Equal("bob", stored[1].PrimaryName)
+	req.Equal(3, stored[1].Commits)
+	req.Nil(stored[2].ExternalIDs)
+}
+
+func TestMergeExternalAccounts(t *testing.T) {
+	req := require.New(t)
+	req.Nil(mergeExternalAccounts(nil, nil))
+	req.Equal([]ExternalAccount{{"github", "a"}, {"github", "b"}, {"gitlab", "a"}},
+		mergeExternalAccounts([]ExternalAccount{{"gitlab", "a"}, {"github", "b"}},
+			[]ExternalAccount{{"github", "a"}, {"github", "b"}}))
+}
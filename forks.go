@@ -0,0 +1,118 @@
+package idmatch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// RepoForks map the forks and the mirrors to their upstream repositories, so that the same
+// commits of all of them count once and belong to the upstream, see collapseForks.
+type RepoForks map[string]string
+
+// ReadRepoForks loads the forks from a CSV file with the columns "fork" and "upstream".
+func ReadRepoForks(path string) (forks RepoForks, err error) {
+	forks = RepoForks{}
+	err = readCSVRecords(path, "repository forks", []string{"fork", "upstream"},
+		func(header map[string]int, record []string) error {
+			fork, upstream := record[header["fork"]], record[header["upstream"]]
+			if fork == "" || upstream == "" || fork == upstream {
+				return fmt.Errorf("invalid repository forks file %s: %s",
+					path, strings.Join(record, ","))
+			}
+			forks[fork] = upstream
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if _, err = forks.resolve(); err != nil {
+		return nil, fmt.Errorf("invalid repository forks file %s: %v", path, err)
+	}
+	return forks, nil
+}
+
+// resolve returns the mapping of each fork to the root of its chain of upstreams, e.g. the fork
+// of a fork to the original repository. It fails on the cycles.
+func (forks RepoForks) resolve() (map[string]string, error) {
+	roots := make(map[string]string, len(forks))
+	for fork := range forks {
+		root, steps := fork, 0
+		for {
+			upstream, exists := forks[root]
+			if !exists {
+				break
+			}
+			root = upstream
+			if steps++; steps > len(forks) {
+				return nil, fmt.Errorf("the forks of %s make a cycle", fork)
+			}
+		}
+		roots[fork] = root
+	}
+	return roots, nil
+}
+
+// collapseForks replaces the repositories of the signatures with their upstreams.
+func collapseForks(commits []Signature, forks RepoForks) ([]Signature, error) {
+	if len(forks) == 0 {
+		return commits, nil
+	}
+	roots, err := forks.resolve()
+	if err != nil {
+		return nil, err
+	}
+	collapsed := 0
+	for i, commit := range commits {
+		if root, exists := roots[commit.Repo]; exists {
+			commits[i].Repo = root
+			collapsed++
+		}
+	}
+	reporter.Commit("signatures collapsed into upstream repositories", collapsed)
+	return commits, nil
+}
+
+// dedupCommits keeps a single signature of each commit which is present in several repositories,
+// e.g. in the forks and the mirrors, so that it counts once in the frequencies and the activity.
+// The kept signature is the one in the first repository in the alphabetical order, and
+// Signature.Mirrors lists the others. The signatures without the hash are kept.
+func dedupCommits(commits []Signature) []Signature {
+	type commitKey struct {
+		hash, name, email string
+		role              SignatureRole
+	}
+	kept := map[commitKey]int{}
+	result := commits[:0]
+	for _, commit := range commits {
+		if commit.Hash == "" {
+			result = append(result, commit)
+			continue
+		}
+		key := commitKey{commit.Hash, commit.Name, commit.Email, commit.Role}
+		index, exists := kept[key]
+		if !exists {
+			kept[key] = len(result)
+			result = append(result, commit)
+			continue
+		}
+		first := &result[index]
+		if commit.Repo == first.Repo {
+			continue
+		}
+		if commit.Repo < first.Repo {
+			commit.Mirrors = append(first.Mirrors, first.Repo)
+			*first = commit
+		} else {
+			first.Mirrors = append(first.Mirrors, commit.Repo)
+		}
+	}
+	for i := range result {
+		if len(result[i].Mirrors) > 1 {
+			result[i].Mirrors = unique(result[i].Mirrors)
+		}
+	}
+	reporter.Commit("duplicate commit signatures", len(commits)-len(result))
+	return result
+}
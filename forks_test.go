@@ -0,0 +1,91 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRepoForks(t *testing.T) {
+	req := require.New(t)
+	file, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := file.WriteString("fork,upstream\nbob/repo,src-d/repo\nalice/repo,bob/repo\n")
+	req.NoError(err)
+	forks, err := ReadRepoForks(file.Name())
+	req.NoError(err)
+	req.Equal(RepoForks{"bob/repo": "src-d/repo", "alice/repo": "bob/repo"}, forks)
+	roots, err := forks.resolve()
+	req.NoError(err)
+	req.Equal(map[string]string{"bob/repo": "src-d/repo", "alice/repo": "src-d/repo"}, roots)
+
+	req.NoError(ioutil.WriteFile(file.Name(), []byte("fork,upstream\na,b\nb,a\n"), 0666))
+	_, err = ReadRepoForks(file.Name())
+	req.Error(err)
+	req.NoError(ioutil.WriteFile(file.Name(), []byte("fork\na\n"), 0666))
+	_, err = ReadRepoForks(file.Name())
+	req.EqualError(err, "invalid repository forks file "+file.Name()+": no upstream column")
+}
+
+func TestCollapseForks(t *testing.T) {
+	req := require.New(t)
+	commits := []Signature{{Repo: "alice/repo"}, {Repo: "src-d/repo"}, {Repo: "eve/other"}}
+	commits, err := collapseForks(commits, RepoForks{"bob/repo": "src-d/repo", "alice/repo": "bob/repo"})
+	req.NoError(err)
+	req.Equal([]Signature{{Repo: "src-d/repo"}, {Repo: "src-d/repo"}, {Repo: "eve/other"}}, commits)
+	_, err = collapseForks(commits, RepoForks{"a": "b", "b": "a"})
+	req.Error(err)
+}
+
+func TestDedupCommits(t *testing.T) {
+	req := require.New(t)
+	commits := dedupCommits([]Signature{
+		{Repo: "src-d/repo", Name: "bob", Email: "bob@google.com", Hash: "aaa"},
+		{Repo: "bob/repo", Name: "bob", Email: "bob@google.com", Hash: "aaa"},
+		{Repo: "bob/repo", Name: "bob", Email: "bob@google.com", Hash: "aaa"},
+		{Repo: "alice/repo", Name: "bob", Email: "bob@google.com", Hash: "aaa", Role: RoleCommitter},
+		{Repo: "mirror/repo", Name: "bob", Email: "bob@google.com", Hash: "aaa"},
+		{Repo: "src-d/repo", Name: "bob", Email: "bob@google.com", Hash: "bbb"},
+		{Repo: "src-d/repo", Name: "alice", Email: "alice@google.com"},
+		{Repo: "bob/repo", Name: "alice", Email: "alice@google.com"},
+	})
+	req.Equal([]Signature{
+		{Repo: "bob/repo", Name: "bob", Email: "bob@google.com", Hash: "aaa",
+			Mirrors: []string{"mirror/repo", "src-d/repo"}},
+		{Repo: "alice/repo", Name: "bob", Email: "bob@google.com", Hash: "aaa", Role: RoleCommitter},
+		{Repo: "src-d/repo", Name: "bob", Email: "bob@google.com", Hash: "bbb"},
+		{Repo: "src-d/repo", Name: "alice", Email: "alice@google.com"},
+		{Repo: "bob/repo", Name: "alice", Email: "alice@google.com"},
+	}, commits)
+}
+
+func TestPeopleFromSignaturesDedupCommits(t *testing.T) {
+	req := require.New(t)
+	signatures := func() []Signature {
+		return []Signature{
+			{Repo: "src-d/repo", Name: "bob", Email: "bob@google.com", Hash: "aaa"},
+			{Repo: "bob/repo", Name: "bob", Email: "bob@google.com", Hash: "aaa"},
+			{Repo: "src-d/repo", Name: "bob", Email: "bob@google.com", Hash: "bbb"},
+		}
+	}
+	people, _, emailFreqs, err := PeopleFromSignatures(context.Background(), signatures(),
+		ExtractionOptions{DedupCommits: true}, newTestBlacklist(t), PopularityThresholds{},
+		BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	req.Equal(2, emailFreqs["bob@google.com"].Total)
+	req.Len(people, 2)
+	req.Equal([]string{"bob/repo", "src-d/repo"}, people[1].Repositories)
+
+	_, _, emailFreqs, err = PeopleFromSignatures(context.Background(), signatures(),
+		ExtractionOptions{}, newTestBlacklist(t), PopularityThresholds{}, BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	req.Equal(3, emailFreqs["bob@google.com"].Total)
+
+	people, _, _, err = PeopleFromSignatures(context.Background(), signatures(),
+		ExtractionOptions{DedupCommits: true, Forks: RepoForks{"bob/repo": "src-d/repo"}},
+		newTestBlacklist(t), PopularityThresholds{}, BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	req.Equal([]string{"src-d/repo"}, people[1].Repositories)
+}
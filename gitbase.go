@@ -0,0 +1,306 @@
+package idmatch
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+const findRepositoriesSQL = `SELECT repository_id FROM repositories ORDER BY repository_id;`
+
+// ExtractionOptions configures querying the signatures from gitbase or the GitHub API or
+// reading them from the repositories or the mailing list archives on disk. The zero value
+// queries gitbase without timeouts and retries.
+type ExtractionOptions struct {
+	// Source is the origin of the signatures. The empty value means SourceGitbase.
+	Source SourceKind
+	// Repositories is the glob pattern which matches the repository directories for SourceGit.
+	Repositories string
+	// Workers is the number of repositories which are read concurrently with SourceGit.
+	Workers int
+	// GitHubOrg is the organization whose repositories are read with SourceGitHub.
+	GitHubOrg string
+	// GitHubToken authenticates the GitHub GraphQL API requests.
+	GitHubToken string
+	// GitHubAPIURL is the GitHub GraphQL API endpoint. The empty value means the public API.
+	GitHubAPIURL string
+	// GitHubRateLimitReserve is the number of rate limit points which are left unspent.
+	// The extraction waits for the rate limit reset instead of going below it.
+	GitHubRateLimitReserve int
+	// Mailboxes is the glob pattern which matches the mbox files and Maildir directories
+	// for SourceMbox.
+	Mailboxes string
+	// MailboxTag prefixes the repository identifiers of the mailboxes as "<tag>:<path>" so that
+	// the mailing list signatures are distinguished from the commit signatures.
+	MailboxTag string
+	// AccountsURL is the JIRA server URL for SourceJIRA or the accounts endpoint for SourceREST.
+	AccountsURL string
+	// AccountsToken authenticates the accounts requests: "username:password" means the basic
+	// authentication and any other non-empty value is the bearer token.
+	AccountsToken string
+	// AccountFields overrides the JSON paths of the account fields and the pagination query
+	// parameters, see accountSource.
+	AccountFields map[string]string
+	// Trailers are the optional roles of the people mentioned in the commit message trailers
+	// which are extracted in addition to the co-authors, see TrailerRoles.
+	Trailers []SignatureRole
+	// MatchCommitters feeds the committer signatures to the identity matching together with
+	// the authors. Otherwise the committers are only reported, see filterCommitters.
+	MatchCommitters bool
+	// ParquetColumns maps the signature fields "repo", "name", "email", "hash", "time", "source",
+	// "role", "signing_key" and "activity" to the column names of the parquet commits table, see
+	// findParquetColumns.
+	ParquetColumns map[string]string
+	// Resume continues the interrupted extraction from the last checkpoint, see extractSignatures.
+	Resume bool
+	// CacheChunkSize splits the CSV cache of the extracted signatures into the files of at most
+	// so many signatures each, which are read concurrently by Workers, see chunkPath.
+	// 0 writes a single file.
+	CacheChunkSize int
+	// QueryTimeout is the maximum duration of a single query. 0 means no timeout.
+	QueryTimeout time.Duration
+	// MaxRetries is the number of times a query is repeated after a transient error.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry. It doubles after each attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff limits the delay between the retries.
+	MaxBackoff time.Duration
+	// Strings interns the repositories, the names and the emails of the signatures and
+	// the people found by FindPeople, see StringTable. A new table is used if it is nil.
+	Strings *StringTable
+	// EmailValidator repairs and checks the emails of the signatures found by FindPeople.
+	// nil disables the validation.
+	EmailValidator *EmailValidator
+	// Suppressions are the erased emails and names whose signatures are dropped by FindPeople,
+	// which also removes them from the signatures cache.
+	Suppressions Suppressions
+	// Seeds merge the people with the emails of the same person of a previously curated identity
+	// map before the matching, see IdentitySeeds. nil disables them.
+	Seeds IdentitySeeds
+	// RepoGroups scope the popular names to the groups of repositories, e.g. organizations,
+	// instead of the single repositories. nil keeps the repositories.
+	RepoGroups RepoGroups
+	// Stats receives the statistics of the names and the emails of the signatures found by
+	// FindPeople, see ComputeStats. nil skips them.
+	Stats *Stats
+	// SharedMailboxes detects the team emails among the signatures found by FindPeople, see
+	// Blacklist.WithSharedMailboxes. nil disables the detection.
+	SharedMailboxes *SharedMailboxDetector
+	// Reproducible sorts the signatures before assigning the identity IDs, so that the same
+	// signatures in any order, e.g. read by the concurrent workers, yield byte-identical outputs.
+	Reproducible bool
+	// Forks replace the forks and the mirrors with their upstream repositories in the signatures.
+	// nil keeps the repositories.
+	Forks RepoForks
+	// Repos scope the signatures to the repositories which pass the filter. The signatures
+	// cache keeps all the repositories. nil passes all of them.
+	Repos *RepoFilter
+	// Window drops the signatures made outside of it while they are read, like Repos. The signatures of gitbase carry the time of
+	// the last commit with the same repository, name and email. The zero window keeps them all.
+	Window TimeWindow
+	// DedupCommits keeps a single signature of each commit in several repositories, so that
+	// the forks and the mirrors do not inflate the frequencies and the activity. The person keeps
+	// all the repositories of the commit.
+	DedupCommits bool
+}
+
+// NewExtractionOptions returns the default timeouts and retries.
+func NewExtractionOptions() ExtractionOptions {
+	return ExtractionOptions{
+		Source:         SourceGitbase,
+		QueryTimeout:   30 * time.Minute,
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Minute,
+		DedupCommits:   true,
+
+		GitHubRateLimitReserve: 100,
+	}
+}
+
+// transientMySQLErrors are the server error codes which may disappear after a retry:
+// lock wait timeout, deadlock, too many connections, query interrupted, server shutdown,
+// server has gone away and lost connection.
+var transientMySQLErrors = map[uint16]struct{}{
+	1205: {}, 1213: {}, 1040: {}, 1317: {}, 1053: {}, 2006: {}, 2013: {},
+}
+
+// isTransientError checks whether the query may succeed if it is repeated.
+func isTransientError(err error) bool {
+	switch err {
+	case nil:
+		return false
+	case driver.ErrBadConn, mysql.ErrInvalidConn, io.EOF, io.ErrUnexpectedEOF,
+		context.DeadlineExceeded:
+		return true
+	}
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok {
+		_, transient := transientMySQLErrors[mysqlErr.Number]
+		return transient
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	if temporary, ok := err.(interface{ Temporary() bool }); ok {
+		return temporary.Temporary()
+	}
+	return false
+}
+
+// retryQuery runs the query with the timeout and repeats it with exponential backoff after
+// the transient errors. The cancellation of ctx is never retried. The transient error which
+// remains after all the retries is ErrSourceUnavailable.
+func retryQuery(ctx context.Context, opts ExtractionOptions, name string,
+	query func(ctx context.Context) error) error {
+	backoff := opts.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		queryCtx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.QueryTimeout > 0 {
+			queryCtx, cancel = context.WithTimeout(ctx, opts.QueryTimeout)
+		}
+		err := query(queryCtx)
+		cancel()
+		if err == nil || ctx.Err() != nil || !isTransientError(err) {
+			return err
+		}
+		if attempt >= opts.MaxRetries {
+			return withKind(ErrSourceUnavailable,
+				fmt.Errorf("%s failed after %d attempts: %w", name, attempt+1, err))
+		}
+		reporter.Increment("gitbase query retries")
+		reporter.Warnf("%s failed, retrying in %s (%d/%d): %v",
+			name, backoff, attempt+1, opts.MaxRetries, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if opts.MaxBackoff > 0 && backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
+
+// GitbaseConnString returns the connection string of the gitbase database for FindPeople.
+// The blank values are the defaults of gitbase: root without the password at 0.0.0.0:3306.
+func GitbaseConnString(host string, port uint, user, password string) string {
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	if port == 0 {
+		port = 3306
+	}
+	if user == "" {
+		user = "root"
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/gitbase", user, password, host, port)
+}
+
+// gitbaseSource is the signatureSource which queries gitbase.
+type gitbaseSource struct {
+	db   *sql.DB
+	opts ExtractionOptions
+}
+
+func newGitbaseSource(conn string, opts ExtractionOptions) (*gitbaseSource, error) {
+	db, err := sql.Open("mysql", conn+"?parseTime=true&interpolateParams=true")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxIdleConns(0)
+	return &gitbaseSource{db: db, opts: opts}, nil
+}
+
+// Close closes the database connection.
+func (s *gitbaseSource) Close() error {
+	return s.db.Close()
+}
+
+func (s *gitbaseSource) Repositories(ctx context.Context) ([]string, error) {
+	var result []string
+	err := retryQuery(ctx, s.opts, "listing the repositories", func(ctx context.Context) error {
+		result = nil
+		rows, err := s.db.QueryContext(ctx, findRepositoriesSQL)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var repo string
+			if err := rows.Scan(&repo); err != nil {
+				return err
+			}
+			result = append(result, repo)
+		}
+		return rows.Err()
+	})
+	return result, err
+}
+
+// Signatures queries the authors, the committers and the people in the commit message trailers
+// of the repository.
+func (s *gitbaseSource) Signatures(ctx context.Context, repo string) ([]Signature, error) {
+	var result []Signature
+	for _, role := range []SignatureRole{RoleAuthor, RoleCommitter} {
+		query := findPeopleSQL
+		if role == RoleCommitter {
+			query = findCommittersSQL
+		}
+		var signatures []Signature
+		err := retryQuery(ctx, s.opts, "reading the "+string(role)+"s of "+repo,
+			func(ctx context.Context) error {
+				signatures = nil
+				rows, err := s.db.QueryContext(ctx, query, repo)
+				if err != nil {
+					return err
+				}
+				defer rows.Close()
+				for rows.Next() {
+					var repo, name, email, hash string
+					var time time.Time
+					if err := rows.Scan(&repo, &name, &email, &hash, &time); err != nil {
+						return err
+					}
+					signatures = append(signatures,
+						Signature{repo, name, email, hash, time, SourceGitbase, role, "", nil, nil})
+				}
+				return rows.Err()
+			})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, signatures...)
+	}
+	trailers := signatureAggregator{}
+	err := retryQuery(ctx, s.opts, "reading the trailers of "+repo, func(ctx context.Context) error {
+		trailers = signatureAggregator{}
+		rows, err := s.db.QueryContext(ctx, findTrailersSQL, repo)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var repo, hash, message string
+			var time time.Time
+			if err := rows.Scan(&repo, &hash, &time, &message); err != nil {
+				return err
+			}
+			trailers.addTrailers(message, Signature{
+				Repo: repo, Hash: hash, Time: time, Source: SourceGitbase}, s.opts.Trailers)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(result, trailers.signatures()...), nil
+}
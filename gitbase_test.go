@@ -0,0 +1,84 @@
+package idmatch
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTransientError(t *testing.T) {
+	req := require.New(t)
+	req.False(isTransientError(nil))
+	req.True(isTransientError(driver.ErrBadConn))
+	req.True(isTransientError(mysql.ErrInvalidConn))
+	req.True(isTransientError(context.DeadlineExceeded))
+	req.True(isTransientError(&mysql.MySQLError{Number: 2013, Message: "lost connection"}))
+	req.False(isTransientError(&mysql.MySQLError{Number: 1064, Message: "syntax error"}))
+	req.False(isTransientError(context.Canceled))
+	req.False(isTransientError(errors.New("unknown")))
+}
+
+func TestRetryQuery(t *testing.T) {
+	req := require.New(t)
+	opts := ExtractionOptions{MaxRetries: 3, InitialBackoff: time.Millisecond,
+		MaxBackoff: 2 * time.Millisecond}
+	attempts := 0
+	err := retryQuery(context.Background(), opts, "test", func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	req.NoError(err)
+	req.Equal(3, attempts)
+
+	attempts = 0
+	err = retryQuery(context.Background(), opts, "test", func(ctx context.Context) error {
+		attempts++
+		return driver.ErrBadConn
+	})
+	req.ErrorIs(err, driver.ErrBadConn)
+	req.ErrorIs(err, ErrSourceUnavailable)
+	req.Equal(4, attempts)
+
+	attempts = 0
+	syntaxErr := &mysql.MySQLError{Number: 1064, Message: "syntax error"}
+	err = retryQuery(context.Background(), opts, "test", func(ctx context.Context) error {
+		attempts++
+		return syntaxErr
+	})
+	req.Equal(syntaxErr, err)
+	req.False(errors.Is(err, ErrSourceUnavailable))
+	req.Equal(1, attempts)
+
+	attempts = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	err = retryQuery(ctx, opts, "test", func(ctx context.Context) error {
+		attempts++
+		cancel()
+		return driver.ErrBadConn
+	})
+	req.Equal(driver.ErrBadConn, err)
+	req.Equal(1, attempts)
+}
+
+func TestRetryQueryTimeout(t *testing.T) {
+	req := require.New(t)
+	opts := ExtractionOptions{QueryTimeout: time.Millisecond, MaxRetries: 1,
+		InitialBackoff: time.Millisecond}
+	attempts := 0
+	err := retryQuery(context.Background(), opts, "test", func(ctx context.Context) error {
+		attempts++
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	req.ErrorIs(err, context.DeadlineExceeded)
+	req.ErrorIs(err, ErrSourceUnavailable)
+	req.Equal(2, attempts)
+}
@@ -0,0 +1,101 @@
+package idmatch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// GitdmPersonal is the gitdm employer of PersonalAffiliation, the hobbyists in the gitdm reports.
+const GitdmPersonal = "(None)"
+
+// gitdmDateFormat is the format of the end dates in the gitdm email map.
+const gitdmDateFormat = "2006-01-02"
+
+// WriteGitdm exports the people and their affiliations, see Organizations.Affiliations, in
+// the formats of gitdm and cregit. The email map in emailMapPath has the domains of orgs and
+// then the affiliation timeline of the primary email of each person: every employer but the
+// current one ends with "< YYYY-MM-DD", the date when the next one starts. The aliases file in
+// aliasesPath maps every other email of each person to the primary one, so that gitdm applies
+// the timeline to all of them. The first email stands in for the missing primary one, see
+// SetPrimaryValues.
+func (p People) WriteGitdm(emailMapPath, aliasesPath string, orgs Organizations) error {
+	var emailMap, aliases []string
+	domains := make([]string, 0, len(orgs))
+	for domain := range orgs {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		emailMap = append(emailMap, fmt.Sprintf("%s\t%s", domain, gitdmEmployer(orgs[domain])))
+	}
+	for _, id := range peopleIDs(p) {
+		person := p[id]
+		if len(person.Emails) == 0 {
+			continue
+		}
+		emails := append([]string{}, person.Emails...)
+		sort.Strings(emails)
+		email := person.PrimaryEmail
+		if email == "" {
+			email = emails[0]
+		}
+		for _, alias := range emails {
+			if alias != email {
+				aliases = append(aliases, fmt.Sprintf("%s\t%s", alias, email))
+			}
+		}
+		affiliations := orgs.Affiliations(person)
+		for i, affiliation := range affiliations {
+			line := fmt.Sprintf("%s\t%s", email, gitdmEmployer(affiliation.Organization))
+			if i+1 < len(affiliations) {
+				line += " < " + affiliations[i+1].Since.UTC().Format(gitdmDateFormat)
+			}
+			emailMap = append(emailMap, line)
+		}
+	}
+	if err := writeGitdmFile(emailMapPath, emailMap); err != nil {
+		return err
+	}
+	return writeGitdmFile(aliasesPath, aliases)
+}
+
+// gitdmEmployer converts the organization to the gitdm employer.
+func gitdmEmployer(organization string) string {
+	if organization == PersonalAffiliation {
+		return GitdmPersonal
+	}
+	return organization
+}
+
+// writeGitdmFile writes the lines of the gitdm configuration file.
+func writeGitdmFile(path string, lines []string) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	writer := bufio.NewWriter(file)
+	defer func() {
+		errFlush := writer.Flush()
+		if err == nil {
+			err = errFlush
+		}
+	}()
+	if _, err = fmt.Fprintln(writer, "# Generated by src-d/identity-matching"); err != nil {
+		return
+	}
+	for _, line := range lines {
+		if _, err = fmt.Fprintln(writer, line); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,44 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteGitdm(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-gitdm")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people := newAffiliationTestPeople()
+	people[1].Emails = []string{"bob@gmail.com", "bob@corp.com", "bob@eu.corp.com", "bob@unknown.org",
+		"robert@googlemail.com"}
+	people[2].Emails = []string{"alice@unknown.org"}
+	people[3] = &Person{ID: 3}
+	orgs := Organizations{"corp.com": "Corp Inc", "gmail.com": PersonalAffiliation,
+		"googlemail.com": PersonalAffiliation}
+	emailMap, aliases := filepath.Join(dir, "domain-map"), filepath.Join(dir, "aliases")
+	req.NoError(people.WriteGitdm(emailMap, aliases, orgs))
+
+	data, err := ioutil.ReadFile(emailMap)
+	req.NoError(err)
+	req.Equal(`# Generated by src-d/identity-matching
+corp.com	Corp Inc
+gmail.com	(None)
+googlemail.com	(None)
+bob@corp.com	(None) < 2019-02-01
+bob@corp.com	Corp Inc
+`, string(data))
+	data, err = ioutil.ReadFile(aliases)
+	req.NoError(err)
+	req.Equal(`# Generated by src-d/identity-matching
+bob@eu.corp.com	bob@corp.com
+bob@gmail.com	bob@corp.com
+bob@unknown.org	bob@corp.com
+robert@googlemail.com	bob@corp.com
+`, string(data))
+}
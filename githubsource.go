@@ -0,0 +1,324 @@
+package idmatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// defaultGitHubGraphQLURL is the endpoint of the public GitHub GraphQL API.
+const defaultGitHubGraphQLURL = "https://api.github.com/graphql"
+
+const gitHubRepositoriesQuery = `
+query($org: String!, $cursor: String) {
+  rateLimit { cost remaining resetAt }
+  organization(login: $org) {
+    repositories(first: 100, after: $cursor, orderBy: {field: NAME, direction: ASC}) {
+      nodes { name isEmpty }
+      pageInfo { hasNextPage endCursor }
+    }
+  }
+}`
+
+const gitHubHistoryQuery = `
+query($owner: String!, $name: String!, $cursor: String) {
+  rateLimit { cost remaining resetAt }
+  repository(owner: $owner, name: $name) {
+    defaultBranchRef {
+      target {
+        ... on Commit {
+          history(first: 100, after: $cursor) {
+            nodes {
+              oid message author { name email date } committer { name email date }
+              signature { ... on GpgSignature { keyId } }
+            }
+            pageInfo { hasNextPage endCursor }
+          }
+        }
+      }
+    }
+  }
+}`
+
+type gitHubPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type gitHubRateLimit struct {
+	Cost      int       `json:"cost"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+type gitHubRepositoriesResponse struct {
+	RateLimit    gitHubRateLimit `json:"rateLimit"`
+	Organization *struct {
+		Repositories struct {
+			Nodes []struct {
+				Name    string `json:"name"`
+				IsEmpty bool   `json:"isEmpty"`
+			} `json:"nodes"`
+			PageInfo gitHubPageInfo `json:"pageInfo"`
+		} `json:"repositories"`
+	} `json:"organization"`
+}
+
+// gitHubGitActor is the commit author or committer.
+type gitHubGitActor struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+type gitHubHistoryResponse struct {
+	RateLimit  gitHubRateLimit `json:"rateLimit"`
+	Repository *struct {
+		DefaultBranchRef *struct {
+			Target struct {
+				History struct {
+					Nodes []struct {
+						Oid       string         `json:"oid"`
+						Message   string         `json:"message"`
+						Author    gitHubGitActor `json:"author"`
+						Committer gitHubGitActor `json:"committer"`
+						Signature *struct {
+							KeyID string `json:"keyId"`
+						} `json:"signature"`
+					} `json:"nodes"`
+					PageInfo gitHubPageInfo `json:"pageInfo"`
+				} `json:"history"`
+			} `json:"target"`
+		} `json:"defaultBranchRef"`
+	} `json:"repository"`
+}
+
+// httpStatusError is the unsuccessful HTTP response of the GitHub API or of another web service.
+type httpStatusError struct {
+	service string
+	code    int
+	body    string
+}
+
+func (e httpStatusError) Error() string {
+	return fmt.Sprintf("%s HTTP %d: %s", e.service, e.code, e.body)
+}
+
+// Temporary indicates whether the request may succeed if it is repeated.
+func (e httpStatusError) Temporary() bool {
+	return e.code >= 500 || e.code == http.StatusRequestTimeout || e.code == http.StatusTooManyRequests
+}
+
+// gitHubSource is the signatureSource which pulls the commit history of the default branches
+// of the organization's repositories from the GitHub GraphQL API. The repository identifier is
+// "github.com/<org>/<name>", the same as the repository URLs known to the external matchers.
+type gitHubSource struct {
+	client *http.Client
+	url    string
+	org    string
+	opts   ExtractionOptions
+
+	// the last observed rate limit, guarded by lock
+	lock      sync.Mutex
+	rateLimit *gitHubRateLimit
+}
+
+func newGitHubSource(opts ExtractionOptions) (*gitHubSource, error) {
+	if opts.GitHubOrg == "" {
+		return nil, fmt.Errorf("the organization must be specified for the %s source", SourceGitHub)
+	}
+	client := http.DefaultClient
+	if opts.GitHubToken != "" {
+		client = oauth2.NewClient(
+			context.Background(),
+			oauth2.StaticTokenSource(&oauth2.Token{AccessToken: opts.GitHubToken}),
+		)
+	}
+	url := opts.GitHubAPIURL
+	if url == "" {
+		url = defaultGitHubGraphQLURL
+	}
+	return &gitHubSource{client: client, url: url, org: opts.GitHubOrg, opts: opts}, nil
+}
+
+// waitForRateLimit sleeps until the rate limit resets if the remaining budget does not cover
+// the cost of the next query plus the reserved GitHubRateLimitReserve points.
+func (s *gitHubSource) waitForRateLimit(ctx context.Context) error {
+	s.lock.Lock()
+	rateLimit := s.rateLimit
+	s.lock.Unlock()
+	if rateLimit == nil || rateLimit.Remaining-rateLimit.Cost >= s.opts.GitHubRateLimitReserve {
+		return nil
+	}
+	reporter.Increment("GitHub rate limit waits")
+	delay := time.Until(rateLimit.ResetAt) + time.Second
+	reporter.Warnf("the GitHub rate limit budget is exhausted (%d points remaining), "+
+		"waiting until %s", rateLimit.Remaining, rateLimit.ResetAt)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+	}
+	s.lock.Lock()
+	s.rateLimit = nil
+	s.lock.Unlock()
+	return nil
+}
+
+// query runs the GraphQL query with retries and decodes the "data" field of the response to
+// result. The rate limit must be the "rateLimit" field of the result.
+func (s *gitHubSource) query(ctx context.Context, name, query string,
+	variables map[string]interface{}, result interface{}, rateLimit *gitHubRateLimit) error {
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	err = retryQuery(ctx, s.opts, name, func(ctx context.Context) error {
+		if err := s.waitForRateLimit(ctx); err != nil {
+			return err
+		}
+		request, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		response, err := s.client.Do(request.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return err
+		}
+		if response.StatusCode != http.StatusOK {
+			return httpStatusError{"GitHub API", response.StatusCode, strings.TrimSpace(string(data))}
+		}
+		var envelope struct {
+			Data   json.RawMessage `json:"data"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return err
+		}
+		if len(envelope.Errors) > 0 {
+			var messages []string
+			for _, e := range envelope.Errors {
+				messages = append(messages, e.Message)
+			}
+			return fmt.Errorf("GitHub GraphQL: %s", strings.Join(messages, "; "))
+		}
+		return json.Unmarshal(envelope.Data, result)
+	})
+	if err != nil {
+		return err
+	}
+	observed := *rateLimit
+	s.lock.Lock()
+	s.rateLimit = &observed
+	s.lock.Unlock()
+	return nil
+}
+
+func (s *gitHubSource) Repositories(ctx context.Context) ([]string, error) {
+	var result []string
+	variables := map[string]interface{}{"org": s.org, "cursor": nil}
+	for {
+		var response gitHubRepositoriesResponse
+		if err := s.query(ctx, "listing the repositories of "+s.org, gitHubRepositoriesQuery,
+			variables, &response, &response.RateLimit); err != nil {
+			return nil, err
+		}
+		if response.Organization == nil {
+			return nil, fmt.Errorf("GitHub organization %s does not exist", s.org)
+		}
+		repositories := response.Organization.Repositories
+		for _, repo := range repositories.Nodes {
+			if !repo.IsEmpty {
+				result = append(result, "github.com/"+s.org+"/"+repo.Name)
+			}
+		}
+		if !repositories.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = repositories.PageInfo.EndCursor
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Signatures pages through the commit history of the default branch and collects the authors,
+// the committers and the people in the message trailers. The signatures are deduplicated with
+// signatureAggregator.
+func (s *gitHubSource) Signatures(ctx context.Context, repo string) ([]Signature, error) {
+	parts := strings.Split(repo, "/")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("not a GitHub repository: %s", repo)
+	}
+	variables := map[string]interface{}{"owner": parts[1], "name": parts[2], "cursor": nil}
+	signatures := signatureAggregator{}
+	for {
+		var response gitHubHistoryResponse
+		if err := s.query(ctx, "reading the history of "+repo, gitHubHistoryQuery,
+			variables, &response, &response.RateLimit); err != nil {
+			return nil, err
+		}
+		if response.Repository == nil {
+			return nil, fmt.Errorf("GitHub repository %s does not exist", repo)
+		}
+		if response.Repository.DefaultBranchRef == nil {
+			break
+		}
+		history := response.Repository.DefaultBranchRef.Target.History
+		for _, commit := range history.Nodes {
+			var key string
+			if commit.Signature != nil {
+				key = strings.ToLower(commit.Signature.KeyID)
+			}
+			authorKey, committerKey := commitSigningKeys(key, commit.Author.Email, commit.Committer.Email)
+			signatures.add(Signature{
+				Repo:       repo,
+				Name:       commit.Author.Name,
+				Email:      commit.Author.Email,
+				Hash:       commit.Oid,
+				Time:       commit.Author.Date,
+				Source:     SourceGitHub,
+				Role:       RoleAuthor,
+				SigningKey: authorKey,
+				Activity:   newActivity(commit.Author.Date),
+			})
+			signatures.add(Signature{
+				Repo:       repo,
+				Name:       commit.Committer.Name,
+				Email:      commit.Committer.Email,
+				Hash:       commit.Oid,
+				Time:       commit.Committer.Date,
+				Source:     SourceGitHub,
+				Role:       RoleCommitter,
+				SigningKey: committerKey,
+				Activity:   newActivity(commit.Committer.Date),
+			})
+			signatures.addTrailers(commit.Message, Signature{
+				Repo: repo, Hash: commit.Oid, Time: commit.Author.Date, Source: SourceGitHub},
+				s.opts.Trailers)
+		}
+		if !history.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = history.PageInfo.EndCursor
+	}
+	return signatures.signatures(), nil
+}
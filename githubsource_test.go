@@ -0,0 +1,180 @@
+package idmatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testGitHubServer struct {
+	t         *testing.T
+	lock      sync.Mutex
+	requests  int
+	failures  int
+	remaining int
+}
+
+func (s *testGitHubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := require.New(s.t)
+	req.Equal("Bearer token", r.Header.Get("Authorization"))
+	var request struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	req.NoError(json.NewDecoder(r.Body).Decode(&request))
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests++
+	if s.failures > 0 {
+		s.failures--
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	rateLimit := fmt.Sprintf(`"rateLimit": {"cost": 1, "remaining": %d, "resetAt": "%s"}`,
+		s.remaining, time.Now().Add(-time.Second).UTC().Format(time.RFC3339))
+	cursor, _ := request.Variables["cursor"].(string)
+	var data string
+	switch {
+	case request.Variables["org"] == "missing":
+		data = `{"organization": null}`
+	case request.Variables["org"] == "broken":
+		fmt.Fprint(w, `{"errors": [{"message": "boom"}]}`)
+		return
+	case strings.Contains(request.Query, "organization") && cursor == "":
+		data = `{"organization": {"repositories": {"nodes": [
+			{"name": "repo2", "isEmpty": false}, {"name": "empty", "isEmpty": true}],
+			"pageInfo": {"hasNextPage": true, "endCursor": "page2"}}}}`
+	case strings.Contains(request.Query, "organization"):
+		data = `{"organization": {"repositories": {"nodes": [{"name": "repo1", "isEmpty": false}],
+			"pageInfo": {"hasNextPage": false, "endCursor": "end"}}}}`
+	case request.Variables["name"] == "repo2":
+		data = `{"repository": {"defaultBranchRef": null}}`
+	case cursor == "":
+		data = `{"repository": {"defaultBranchRef": {"target": {"history": {"nodes": [
+			{"oid": "aaa", "author": {"name": "alice", "email": "alice@google.com",
+			 "date": "2019-01-02T00:00:00+01:00"}, "committer": {"name": "alice",
+			 "email": "alice@google.com", "date": "2019-01-02T00:00:00+01:00"}},
+			{"oid": "bbb", "message": "Merge\n\nCo-authored-by: carol <carol@google.com>",
+			 "author": {"name": "bob", "email": "bob@google.com",
+			 "date": "2019-01-01T00:00:00Z"}, "committer": {"name": "github",
+			 "email": "noreply@github.com", "date": "2019-01-03T00:00:00Z"}}],
+			"pageInfo": {"hasNextPage": true, "endCursor": "page2"}}}}}}`
+	default:
+		data = `{"repository": {"defaultBranchRef": {"target": {"history": {"nodes": [
+			{"oid": "ccc", "author": {"name": "alice", "email": "alice@google.com",
+			 "date": "2018-01-01T00:00:00Z"}, "committer": {"name": "alice",
+			 "email": "alice@google.com", "date": "2018-01-01T00:00:00Z"},
+			 "signature": {"keyId": "4AEE18F83AFDEB23"}}],
+			"pageInfo": {"hasNextPage": false, "endCursor": "end"}}}}}}`
+	}
+	fmt.Fprintf(w, `{"data": {%s, %s}}`, rateLimit, data[1:len(data)-1])
+}
+
+func TestGitHubSource(t *testing.T) {
+	req := require.New(t)
+	server := &testGitHubServer{t: t, remaining: 5000}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+	opts := ExtractionOptions{
+		Source:         SourceGitHub,
+		GitHubOrg:      "src-d",
+		GitHubToken:    "token",
+		GitHubAPIURL:   httpServer.URL,
+		MaxRetries:     1,
+		InitialBackoff: time.Millisecond,
+	}
+	_, err := newGitHubSource(ExtractionOptions{})
+	req.Error(err)
+	source, err := newGitHubSource(opts)
+	req.NoError(err)
+
+	repos, err := source.Repositories(context.Background())
+	req.NoError(err)
+	req.Equal([]string{"github.com/src-d/repo1", "github.com/src-d/repo2"}, repos)
+	req.Equal(2, server.requests)
+	req.Equal(5000, source.rateLimit.Remaining)
+
+	aliceActivity := testActivity(time.Date(2019, 1, 2, 0, 0, 0, 0, time.FixedZone("", 3600)))
+	expected := []Signature{
+		{"github.com/src-d/repo1", "alice", "alice@google.com", "aaa",
+			time.Date(2019, 1, 1, 23, 0, 0, 0, time.UTC), SourceGitHub, RoleAuthor, "", aliceActivity, nil},
+		{"github.com/src-d/repo1", "alice", "alice@google.com", "ccc",
+			time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), SourceGitHub, RoleAuthor, "4aee18f83afdeb23",
+			testActivity(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)), nil},
+		{"github.com/src-d/repo1", "alice", "alice@google.com", "aaa",
+			time.Date(2019, 1, 1, 23, 0, 0, 0, time.UTC), SourceGitHub, RoleCommitter, "", aliceActivity, nil},
+		{"github.com/src-d/repo1", "alice", "alice@google.com", "ccc",
+			time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), SourceGitHub, RoleCommitter, "4aee18f83afdeb23",
+			testActivity(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)), nil},
+		{"github.com/src-d/repo1", "bob", "bob@google.com", "bbb",
+			time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), SourceGitHub, RoleAuthor, "",
+			testActivity(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)), nil},
+		{"github.com/src-d/repo1", "carol", "carol@google.com", "bbb",
+			time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), SourceGitHub, RoleCoAuthor, "", nil, nil},
+		{"github.com/src-d/repo1", "github", "noreply@github.com", "bbb",
+			time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC), SourceGitHub, RoleCommitter, "",
+			testActivity(time.Date(2019, 1, 3, 0, 0, 0, 0, time.UTC)), nil},
+	}
+	signatures, err := source.Signatures(context.Background(), "github.com/src-d/repo1")
+	req.NoError(err)
+	req.Equal(expected, signatures)
+	signatures, err = source.Signatures(context.Background(), "github.com/src-d/repo2")
+	req.NoError(err)
+	req.Empty(signatures)
+	_, err = source.Signatures(context.Background(), "repo1")
+	req.Error(err)
+
+	// transient errors are retried
+	server.failures = 1
+	server.requests = 0
+	_, err = source.Signatures(context.Background(), "github.com/src-d/repo2")
+	req.NoError(err)
+	req.Equal(2, server.requests)
+	server.failures = 2
+	_, err = source.Signatures(context.Background(), "github.com/src-d/repo2")
+	req.ErrorIs(err, ErrSourceUnavailable)
+	var statusErr httpStatusError
+	req.True(errors.As(err, &statusErr))
+	req.Equal(httpStatusError{"GitHub API", http.StatusBadGateway, ""}, statusErr)
+
+	// the budget is exhausted and the rate limit has already reset
+	server.failures = 0
+	server.remaining = 10
+	source.opts.GitHubRateLimitReserve = 100
+	_, err = source.Signatures(context.Background(), "github.com/src-d/repo2")
+	req.NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	source.rateLimit.ResetAt = time.Now().Add(time.Hour)
+	_, err = source.Signatures(ctx, "github.com/src-d/repo2")
+	req.Equal(context.Canceled, err)
+	source.rateLimit = nil
+
+	for _, org := range []string{"missing", "broken"} {
+		source.org = org
+		_, err = source.Repositories(context.Background())
+		req.Error(err)
+	}
+
+	dir, err := ioutil.TempDir("", "idmatch-github")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "cache.csv")
+	signatures, err = findSignatures(nil, "", cachePath, opts)
+	req.NoError(err)
+	req.Equal(expected, signatures)
+	_, err = os.Stat(cachePath)
+	req.NoError(err)
+}
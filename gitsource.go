@@ -0,0 +1,254 @@
+package idmatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// SourceKind is the origin of the raw Git signatures.
+type SourceKind string
+
+const (
+	// SourceGitbase queries the signatures from gitbase.
+	SourceGitbase SourceKind = "gitbase"
+	// SourceGit reads the commit logs of the repositories on disk with go-git.
+	SourceGit SourceKind = "git"
+	// SourceGitHub pulls the commit history of the organization's repositories from
+	// the GitHub GraphQL API.
+	SourceGitHub SourceKind = "github"
+	// SourceMbox reads the From: headers of the mailing list archives.
+	SourceMbox SourceKind = "mbox"
+	// SourceJIRA pulls the user accounts of the JIRA issue tracker.
+	SourceJIRA SourceKind = "jira"
+	// SourceREST pulls the user accounts from a generic REST API with the configured field mapping.
+	SourceREST SourceKind = "rest"
+)
+
+// SourceKinds lists the supported signature sources.
+var SourceKinds = []SourceKind{
+	SourceGitbase, SourceGit, SourceGitHub, SourceMbox, SourceJIRA, SourceREST}
+
+// gitSource is the signatureSource which opens the repositories matched by a glob pattern
+// and walks their commit logs. The repository identifier is the path to the repository.
+type gitSource struct {
+	pattern string
+	workers int
+	// trailers are the optional roles extracted from the commit message trailers
+	trailers []SignatureRole
+}
+
+func newGitSource(pattern string, workers int) (*gitSource, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("the repositories glob pattern must be specified for the %s source",
+			SourceGit)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid repositories glob pattern %s: %v", pattern, err)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return &gitSource{pattern: pattern, workers: workers}, nil
+}
+
+func (s *gitSource) Repositories(ctx context.Context) ([]string, error) {
+	matches, err := filepath.Glob(s.pattern)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err != nil {
+			return nil, err
+		} else if info.IsDir() {
+			result = append(result, filepath.Clean(match))
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Signatures walks the commits reachable from all the references of the repository and
+// collects the authors, the committers and the people in the message trailers. The signatures are
+// deduplicated with signatureAggregator.
+func (s *gitSource) Signatures(ctx context.Context, repo string) ([]Signature, error) {
+	r, err := git.PlainOpen(repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the repository %s: %v", repo, err)
+	}
+	commits, err := r.Log(&git.LogOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the commit log of %s: %v", repo, err)
+	}
+	defer commits.Close()
+	signatures := signatureAggregator{}
+	err = commits.ForEach(func(commit *object.Commit) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		authorKey, committerKey := commitSigningKeys(
+			pgpKeyID(commit.PGPSignature), commit.Author.Email, commit.Committer.Email)
+		signatures.add(Signature{
+			Repo:       repo,
+			Name:       commit.Author.Name,
+			Email:      commit.Author.Email,
+			Hash:       commit.Hash.String(),
+			Time:       commit.Author.When,
+			Source:     SourceGit,
+			Role:       RoleAuthor,
+			SigningKey: authorKey,
+			Activity:   newActivity(commit.Author.When),
+		})
+		signatures.add(Signature{
+			Repo:       repo,
+			Name:       commit.Committer.Name,
+			Email:      commit.Committer.Email,
+			Hash:       commit.Hash.String(),
+			Time:       commit.Committer.When,
+			Source:     SourceGit,
+			Role:       RoleCommitter,
+			SigningKey: committerKey,
+			Activity:   newActivity(commit.Committer.When),
+		})
+		signatures.addTrailers(commit.Message, Signature{
+			Repo: repo, Hash: commit.Hash.String(), Time: commit.Author.When, Source: SourceGit},
+			s.trailers)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signatures.signatures(), nil
+}
+
+type signatureKey struct {
+	name, email string
+	source      SourceKind
+	role        SignatureRole
+	signingKey  string
+}
+
+// signatureAggregator deduplicates the commit signatures of a repository by name, email,
+// source, role and signing key keeping the maximum commit hash and time, the same way as findPeopleSQL aggregates them,
+// and summing the activities.
+type signatureAggregator map[signatureKey]*Signature
+
+func (a signatureAggregator) add(commit Signature) {
+	key := signatureKey{commit.Name, commit.Email, commit.Source, commit.Role, commit.SigningKey}
+	commit.Time = commit.Time.UTC()
+	signature, exists := a[key]
+	if !exists {
+		commit.Activity = commit.Activity.copy()
+		a[key] = &commit
+		return
+	}
+	signature.Activity = mergeActivity(signature.Activity, commit.Activity)
+	if commit.Hash > signature.Hash {
+		signature.Hash = commit.Hash
+	}
+	if commit.Time.After(signature.Time) {
+		signature.Time = commit.Time
+	}
+}
+
+// signatures returns the deduplicated signatures sorted by name, email, role and signing key.
+func (a signatureAggregator) signatures() []Signature {
+	result := make([]Signature, 0, len(a))
+	for _, signature := range a {
+		result = append(result, *signature)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		if result[i].Email != result[j].Email {
+			return result[i].Email < result[j].Email
+		}
+		if result[i].Role != result[j].Role {
+			return result[i].Role < result[j].Role
+		}
+		return result[i].SigningKey < result[j].SigningKey
+	})
+	return result
+}
+
+// readSignaturesFromGit extracts the signatures of the repositories concurrently with
+// the configured number of workers. The result is ordered by repository.
+func readSignaturesFromGit(prog *progress, source *gitSource) ([]Signature, error) {
+	ctx, cancel := context.WithCancel(prog.context())
+	defer cancel()
+	repos, err := source.Repositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reporter.Infof("reading the signatures of %d repositories with %d workers",
+		len(repos), source.workers)
+
+	spin := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
+	spin.Start()
+	defer spin.Stop()
+	perRepo := make([][]Signature, len(repos))
+	indexes := make(chan int)
+	var lock sync.Mutex
+	var firstErr error
+	stage := prog.stage("reading signatures", 0)
+	var wg sync.WaitGroup
+	wg.Add(source.workers)
+	for i := 0; i < source.workers; i++ {
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				signatures, err := source.Signatures(ctx, repos[index])
+				lock.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					perRepo[index] = signatures
+					for range signatures {
+						// the cancellation is checked by the producer
+						_ = stage.tick()
+					}
+					// the spinner's goroutine reads Suffix under its own lock
+					spin.Lock()
+					spin.Suffix = fmt.Sprintf(" %d", stage.processed)
+					spin.Unlock()
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for index := range repos {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	stage.done()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := prog.err(); err != nil {
+		return nil, err
+	}
+	var result []Signature
+	for _, signatures := range perRepo {
+		result = append(result, signatures...)
+	}
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+// initTestGitRepository commits once per author. The committer is the author if it is nil.
+func initTestGitRepository(t *testing.T, path string, committer *object.Signature,
+	authors ...object.Signature) []string {
+	req := require.New(t)
+	repo, err := git.PlainInit(path, false)
+	req.NoError(err)
+	worktree, err := repo.Worktree()
+	req.NoError(err)
+	var hashes []string
+	for i, author := range authors {
+		author := author
+		req.NoError(ioutil.WriteFile(filepath.Join(path, "file"), []byte{byte(i)}, 0666))
+		_, err = worktree.Add("file")
+		req.NoError(err)
+		hash, err := worktree.Commit("commit", &git.CommitOptions{
+			Author: &author, Committer: committer})
+		req.NoError(err)
+		hashes = append(hashes, hash.String())
+	}
+	return hashes
+}
+
+func TestGitSource(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-git")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	alice := object.Signature{Name: "alice", Email: "alice@google.com", When: day}
+	bob := object.Signature{Name: "bob", Email: "bob@google.com", When: day}
+	repo1, repo2 := filepath.Join(dir, "repo1"), filepath.Join(dir, "repo2")
+	alice2 := alice
+	alice2.When = day.Add(time.Hour)
+	hashes1 := initTestGitRepository(t, repo1, nil, alice, bob, alice2)
+	mergeBot := object.Signature{Name: "merge-bot", Email: "bot@google.com", When: day}
+	hashes2 := initTestGitRepository(t, repo2, &mergeBot, bob)
+	req.NoError(ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0666))
+
+	_, err = newGitSource("", 1)
+	req.Error(err)
+	_, err = newGitSource("[", 1)
+	req.Error(err)
+	source, err := newGitSource(filepath.Join(dir, "*"), 2)
+	req.NoError(err)
+	repos, err := source.Repositories(context.Background())
+	req.NoError(err)
+	req.Equal([]string{repo1, repo2}, repos)
+
+	maxHash := hashes1[0]
+	if hashes1[2] > maxHash {
+		maxHash = hashes1[2]
+	}
+	expected := []Signature{
+		{repo1, "alice", "alice@google.com", maxHash, alice2.When, SourceGit, RoleAuthor, "",
+			testActivity(day, alice2.When), nil},
+		{repo1, "alice", "alice@google.com", maxHash, alice2.When, SourceGit, RoleCommitter, "",
+			testActivity(day, alice2.When), nil},
+		{repo1, "bob", "bob@google.com", hashes1[1], day, SourceGit, RoleAuthor, "", testActivity(day), nil},
+		{repo1, "bob", "bob@google.com", hashes1[1], day, SourceGit, RoleCommitter, "", testActivity(day), nil},
+		{repo2, "bob", "bob@google.com", hashes2[0], day, SourceGit, RoleAuthor, "", testActivity(day), nil},
+		{repo2, "merge-bot", "bot@google.com", hashes2[0], day, SourceGit, RoleCommitter, "",
+			testActivity(day), nil},
+	}
+	signatures, err := source.Signatures(context.Background(), repo1)
+	req.NoError(err)
+	req.Equal(expected[:4], signatures)
+
+	for _, workers := range []int{1, 2} {
+		source.workers = workers
+		signatures, err = readSignaturesFromGit(nil, source)
+		req.NoError(err)
+		req.Equal(expected, signatures)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = readSignaturesFromGit(&progress{ctx: ctx}, source)
+	req.Equal(context.Canceled, err)
+
+	cachePath := filepath.Join(dir, "cache.csv")
+	signatures, err = findSignatures(nil, "", cachePath, ExtractionOptions{
+		Source: SourceGit, Repositories: filepath.Join(dir, "repo*"), Workers: 2})
+	req.NoError(err)
+	req.Equal(expected, signatures)
+	signatures, err = readSignaturesFromDisk(nil, cachePath)
+	req.NoError(err)
+	req.Equal(expected, signatures)
+
+	source.pattern = filepath.Join(dir, "file*")
+	repos, err = source.Repositories(context.Background())
+	req.NoError(err)
+	req.Empty(repos)
+	_, err = source.Signatures(context.Background(), dir)
+	req.Error(err)
+
+	r, err := git.PlainOpen(repo2)
+	req.NoError(err)
+	worktree, err := r.Worktree()
+	req.NoError(err)
+	hash, err := worktree.Commit("pair\n\nCo-authored-by: carol <carol@google.com>\n",
+		&git.CommitOptions{Author: &bob})
+	req.NoError(err)
+	signatures, err = source.Signatures(context.Background(), repo2)
+	req.NoError(err)
+	req.Contains(signatures,
+		Signature{repo2, "carol", "carol@google.com", hash.String(), day, SourceGit, RoleCoAuthor, "", nil, nil})
+}
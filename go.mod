@@ -1,28 +1,44 @@
 module github.com/src-d/identity-matching
 
+go 1.16
+
 require (
+	cloud.google.com/go/bigquery v1.8.0
+	cloud.google.com/go/storage v1.8.0
+	github.com/apache/arrow/go/arrow v0.0.0-20200730104253-651201b0f516
 	github.com/apache/thrift v0.12.0 // indirect
+	github.com/aws/aws-sdk-go v1.25.48
 	github.com/briandowns/spinner v1.6.1
+	github.com/go-ldap/ldap/v3 v3.1.10
 	github.com/go-sql-driver/mysql v1.4.1
+	github.com/golang/protobuf v1.4.3
 	github.com/golang/snappy v0.0.1 // indirect
 	github.com/google/go-github v17.0.0+incompatible // indirect
+	github.com/klauspost/compress v1.9.8
+	github.com/linkedin/goavro/v2 v2.9.8
 	github.com/mjibson/esc v0.2.0
 	github.com/pkg/errors v0.8.1 // indirect
-	github.com/sirupsen/logrus v1.3.0
-	github.com/spf13/pflag v1.0.3
-	github.com/stretchr/testify v1.3.0
+	github.com/segmentio/kafka-go v0.4.10
+	github.com/sirupsen/logrus v1.7.0
+	github.com/snowflakedb/gosnowflake v1.3.13
+	github.com/spf13/cobra v1.8.0
+	github.com/spf13/pflag v1.0.5
+	github.com/stretchr/testify v1.7.0
 	github.com/wbrefvem/go-bitbucket v0.0.0-20190128183802-fc08fd046abb
 	github.com/xanzy/go-gitlab v0.18.0
 	github.com/xitongsys/parquet-go v1.3.0
 	github.com/xitongsys/parquet-go-source v0.0.0-20190611011107-a9b8f78bccbe
-	golang.org/x/crypto v0.0.0-20191001141032-4663e185863a // indirect
-	golang.org/x/lint v0.0.0-20190930215403-16217165b5de
-	golang.org/x/net v0.0.0-20190930134127-c5a3c61f89f3 // indirect
-	golang.org/x/oauth2 v0.0.0-20190219183015-4b83411ed2b3
-	golang.org/x/sync v0.0.0-20190911185100-cd5d95a43a6e // indirect
-	golang.org/x/sys v0.0.0-20190927073244-c990c680b611 // indirect
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	golang.org/x/lint v0.0.0-20200302205851-738671d3881b
+	golang.org/x/net v0.0.0-20200822124328-c89045814202
+	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
 	golang.org/x/text v0.3.2
-	golang.org/x/tools v0.0.0-20191010075000-0337d82405ff
+	golang.org/x/tools v0.0.0-20200515010526-7d3b6ebf133d
 	gonum.org/v1/gonum v0.0.0-20190624220246-e34e6b933b2b
+	google.golang.org/api v0.24.0
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.25.0
 	gopkg.in/google/go-github.v15 v15.0.0
+	gopkg.in/src-d/go-git.v4 v4.13.1
+	gopkg.in/yaml.v2 v2.2.8
 )
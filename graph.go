@@ -0,0 +1,468 @@
+package idmatch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gonum.org/v1/gonum/floats"
+	simplegraph "gonum.org/v1/gonum/graph"
+	"gonum.org/v1/gonum/graph/simple"
+	"gonum.org/v1/gonum/graph/traverse"
+	"gonum.org/v1/gonum/stat"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// EvidenceKind is the type of the evidence which connects two identities.
+type EvidenceKind string
+
+const (
+	// EvidenceExternalID means that both identities resolve to the same external user.
+	EvidenceExternalID EvidenceKind = "external_id"
+	// EvidenceEmail means that both identities share the email.
+	EvidenceEmail EvidenceKind = "email"
+	// EvidenceName means that both identities share the name.
+	EvidenceName EvidenceKind = "name"
+	// EvidenceSigningKey means that the commits of both identities are signed with the same GPG key.
+	EvidenceSigningKey EvidenceKind = "signing_key"
+	// EvidenceActivity means that both identities commit at similar hours in the same time zones.
+	EvidenceActivity EvidenceKind = "activity"
+	// EvidenceRepositories means that both identities contribute to the same rare repositories.
+	// Its weight is proportional to the similarity, see addRepositoryEvidence.
+	EvidenceRepositories EvidenceKind = "repositories"
+	// EvidenceClassifier means that PairScorer considers both identities the same person.
+	// Its weight is proportional to the probability, see addClassifierEvidence.
+	EvidenceClassifier EvidenceKind = "classifier"
+	// EvidenceDomainChange means that both identities have the same name and email local part
+	// at different corporate domains in consecutive periods, see addDomainChangeEvidence.
+	EvidenceDomainChange EvidenceKind = "domain_change"
+	// EvidenceStyle means that both identities write code in a similar style, see StyleOptions.
+	EvidenceStyle EvidenceKind = "style"
+)
+
+// Evidence is a single reason to consider two identities the same person.
+type Evidence struct {
+	Kind EvidenceKind
+	// Value is the shared external id, email, name or signing key.
+	Value string
+	// Weight is the contribution of the evidence to the edge weight.
+	Weight float64
+}
+
+// String formats the evidence as "kind:value".
+func (e Evidence) String() string {
+	return string(e.Kind) + ":" + e.Value
+}
+
+// formatEvidence joins the formatted evidence items with "; ".
+func formatEvidence(evidence []Evidence) string {
+	items := make([]string, len(evidence))
+	for i, ev := range evidence {
+		items[i] = ev.String()
+	}
+	return strings.Join(items, "; ")
+}
+
+// IdentityNode is a vertex of IdentityGraph: an identity as it was before any merges.
+type IdentityNode struct {
+	ID             int64
+	NamesWithRepos []NameWithRepo
+	Emails         []string
+}
+
+// IdentityEdge connects two identities in IdentityGraph.
+type IdentityEdge struct {
+	From     int64
+	To       int64
+	Evidence []Evidence
+	// Weight is the sum of the evidence weights.
+	Weight float64
+	// Active indicates that the weight reaches the threshold so that the edge joins the components.
+	Active bool
+}
+
+type edgeKey struct {
+	from int64
+	to   int64
+}
+
+func newEdgeKey(id1, id2 int64) edgeKey {
+	if id1 > id2 {
+		id1, id2 = id2, id1
+	}
+	return edgeKey{id1, id2}
+}
+
+type node struct {
+	Value *Person
+	id    int64
+}
+
+func (g node) ID() int64 {
+	return g.id
+}
+
+// IdentityGraph is the graph of identities. Each node is a person produced by newPeople, that is,
+// a (name, email) signature, and each edge carries the typed evidence that both nodes are
+// the same person. The edges whose summed evidence weight reaches the threshold are active;
+// the resulting people are the connected components formed by the active edges.
+type IdentityGraph struct {
+	graph     *simple.UndirectedGraph
+	nodes     map[int64]IdentityNode
+	edges     map[edgeKey]*IdentityEdge
+	weights   map[EvidenceKind]float64
+	threshold float64
+	// explain enables recording Person.MergeEvidence in Reduce.
+	explain bool
+	// sampleCommits choose Person.SampleCommits in Reduce.
+	sampleCommits SampleCommitOptions
+	// progress receives the progress of Reduce. May be nil.
+	progress ProgressReporter
+	// behavior enables the vetoes of the merges by name, see vetoed. May be nil.
+	behavior *BehaviorOptions
+	// rejected are the edges which the reviewers forbade, see ReviewDecisions. May be nil.
+	rejected map[edgeKey]struct{}
+	// conflicts are the constraints which could not be honored, see applyConstraints.
+	conflicts []ConstraintConflict
+	// overMerges are the edges kept inactive by the cluster size limits, see
+	// applyClusterLimits.
+	overMerges []OverMerge
+	// externalIDs resolve the edges between the identities with different external IDs.
+	externalIDs ExternalIDOptions
+	// externalIDConflicts are the edges kept inactive by ExternalIDConflictReview, recorded
+	// once per edge in externalIDConflictEdges.
+	externalIDConflicts     []ExternalIDConflict
+	externalIDConflictEdges map[edgeKey]struct{}
+	// candidates are the pairs of the identities which share a blocking key, see Blocker.
+	candidates map[edgeKey]struct{}
+	// style enables the vetoes of the merges by name by the coding styles in styles, see
+	// vetoed. May be nil.
+	style  *StyleOptions
+	styles map[int64]*StyleFingerprint
+}
+
+// newIdentityGraph creates a graph without edges. The evidence kinds which are missing in weights
+// have the weight 1.
+func newIdentityGraph(people People, weights map[EvidenceKind]float64,
+	threshold float64) *IdentityGraph {
+	g := &IdentityGraph{
+		graph:     simple.NewUndirectedGraph(),
+		nodes:     map[int64]IdentityNode{},
+		edges:     map[edgeKey]*IdentityEdge{},
+		weights:   weights,
+		threshold: threshold,
+	}
+	for index, person := range people {
+		g.graph.AddNode(node{person, index})
+		g.nodes[index] = IdentityNode{
+			ID:             index,
+			NamesWithRepos: append([]NameWithRepo{}, person.NamesWithRepos...),
+			Emails:         append([]string{}, person.Emails...),
+		}
+	}
+	return g
+}
+
+func (g *IdentityGraph) node(id int64) node {
+	return g.graph.Node(id).(node)
+}
+
+func (g *IdentityGraph) weight(kind EvidenceKind) float64 {
+	if weight, exists := g.weights[kind]; exists {
+		return weight
+	}
+	return 1
+}
+
+// addEvidence records the evidence between two nodes. Once the edge weight reaches the threshold,
+// the edge becomes active and the ExternalIDs are propagated over the joined components.
+// The email and name evidence which involves an identity known only from a commit message trailer
+// is recorded with the kind of the trailer role instead, so that it weighs as configured for
+// that trailer.
+func (g *IdentityGraph) addEvidence(node1, node2 node, kind EvidenceKind, value string) error {
+	return g.addScaledEvidence(node1, node2, kind, value, 1)
+}
+
+// addScaledEvidence is addEvidence which multiplies the weight of the evidence kind by scale,
+// so that the graded evidence, such as the similarity of the repositories, weighs proportionally.
+func (g *IdentityGraph) addScaledEvidence(node1, node2 node, kind EvidenceKind, value string,
+	scale float64) error {
+	if node1.ID() == node2.ID() {
+		return nil
+	}
+	if kind == EvidenceEmail || kind == EvidenceName {
+		if role := node1.Value.TrailerRole; role != "" {
+			kind = EvidenceKind(role)
+		} else if role := node2.Value.TrailerRole; role != "" {
+			kind = EvidenceKind(role)
+		}
+	}
+	evidenceWeight := g.weight(kind) * scale
+	key := newEdgeKey(node1.ID(), node2.ID())
+	edge, exists := g.edges[key]
+	if !exists {
+		edge = &IdentityEdge{From: key.from, To: key.to}
+	}
+	if edge.Active {
+		edge.Evidence = append(edge.Evidence, Evidence{kind, value, evidenceWeight})
+		edge.Weight += evidenceWeight
+		return nil
+	}
+	weight := edge.Weight + evidenceWeight
+	if weight >= g.threshold && !g.vetoed(edge, kind, node1, node2) {
+		activated, err := g.setEdge(node1, node2, Evidence{kind, value, evidenceWeight})
+		if err != nil {
+			return err
+		}
+		if activated {
+			edge.Active = true
+			mergesMetric.Add(1, string(kind))
+		}
+	}
+	edge.Evidence = append(edge.Evidence, Evidence{kind, value, evidenceWeight})
+	edge.Weight = weight
+	g.edges[key] = edge
+	return nil
+}
+
+// isNameEvidence checks whether the evidence kind is the shared name or the weak evidence of
+// a commit message trailer, which the behavioral matching may veto.
+func isNameEvidence(kind EvidenceKind) bool {
+	return kind == EvidenceName || isTrailerRole(SignatureRole(kind))
+}
+
+// vetoed checks whether the edge must stay inactive because a reviewer rejected it or because
+// all its evidence, including the new kind, is by name and the activities or the coding styles
+// of the nodes are incompatible, see BehaviorOptions and StyleOptions.
+func (g *IdentityGraph) vetoed(edge *IdentityEdge, kind EvidenceKind, node1, node2 node) bool {
+	if _, rejected := g.rejected[newEdgeKey(edge.From, edge.To)]; rejected {
+		reporter.Increment("rejected merges")
+		return true
+	}
+	if (g.behavior == nil && g.style == nil) || !isNameEvidence(kind) {
+		return false
+	}
+	for _, evidence := range edge.Evidence {
+		if !isNameEvidence(evidence.Kind) {
+			return false
+		}
+	}
+	if g.behavior != nil {
+		similarity, compared := g.behavior.compare(node1.Value.Activity, node2.Value.Activity)
+		if compared && similarity < g.behavior.VetoSimilarity {
+			reporter.Increment("behavioral vetoes")
+			return true
+		}
+	}
+	if g.style != nil {
+		similarity, compared := g.style.compare(g.styles[node1.ID()], g.styles[node2.ID()])
+		if compared && similarity < g.style.VetoSimilarity {
+			reporter.Increment("style vetoes")
+			return true
+		}
+	}
+	return false
+}
+
+// Nodes returns the identities in the graph sorted by ID.
+func (g *IdentityGraph) Nodes() []IdentityNode {
+	result := make([]IdentityNode, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		result = append(result, n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// Edges returns all the edges with the evidence, both active and inactive, sorted by the node IDs.
+func (g *IdentityGraph) Edges() []IdentityEdge {
+	result := make([]IdentityEdge, 0, len(g.edges))
+	for _, edge := range g.edges {
+		result = append(result, *edge)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].From != result[j].From {
+			return result[i].From < result[j].From
+		}
+		return result[i].To < result[j].To
+	})
+	return result
+}
+
+// addCandidate records the pair of the identities as the candidate for the pairwise evidence.
+func (g *IdentityGraph) addCandidate(id1, id2 int64) {
+	if id1 == id2 {
+		return
+	}
+	if g.candidates == nil {
+		g.candidates = map[edgeKey]struct{}{}
+	}
+	g.candidates[newEdgeKey(id1, id2)] = struct{}{}
+}
+
+// candidateEdges returns the edges, as Edges does, followed by the candidates without any edge
+// yet as the edges without evidence. Both are sorted by the node IDs.
+func (g *IdentityGraph) candidateEdges() []IdentityEdge {
+	result := g.Edges()
+	var candidates []IdentityEdge
+	for key := range g.candidates {
+		if _, exists := g.edges[key]; !exists {
+			candidates = append(candidates, IdentityEdge{From: key.from, To: key.to})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].From != candidates[j].From {
+			return candidates[i].From < candidates[j].From
+		}
+		return candidates[i].To < candidates[j].To
+	})
+	return append(result, candidates...)
+}
+
+// Edge returns the edge between two nodes if it exists.
+func (g *IdentityGraph) Edge(id1, id2 int64) (IdentityEdge, bool) {
+	edge, exists := g.edges[newEdgeKey(id1, id2)]
+	if !exists {
+		return IdentityEdge{}, false
+	}
+	return *edge, true
+}
+
+// Components returns the node IDs of each connected component formed by the active edges.
+// Both the components and the IDs inside are sorted.
+func (g *IdentityGraph) Components() [][]int64 {
+	sets := disjointSets{}
+	for _, edge := range g.edges {
+		if edge.Active {
+			sets.union(edge.From, edge.To)
+		}
+	}
+	ids := make([]int64, 0, len(g.nodes))
+	for id := range g.nodes {
+		ids = append(ids, id)
+	}
+	return sets.groups(ids)
+}
+
+// Reduce merges the people in each connected component. If the graph was built with
+// ReduceOptions.ExplainMerges, the active edges of each component are saved to
+// Person.MergeEvidence. Reduce stops early if ctx is cancelled, the people are partially
+// merged then.
+func (g *IdentityGraph) Reduce(ctx context.Context, people People) error {
+	merger := NewPeopleMerger(people)
+	merger.SampleCommits = g.sampleCommits
+	for id := range g.nodes {
+		merger.Add(id)
+	}
+	edges := g.Edges()
+	for _, edge := range edges {
+		if !edge.Active {
+			continue
+		}
+		if err := merger.Union(edge.From, edge.To); err != nil {
+			return err
+		}
+	}
+	componentEdges := map[int64][]IdentityEdge{}
+	if g.explain {
+		for _, edge := range edges {
+			if edge.Active {
+				root := merger.Find(edge.From)
+				componentEdges[root] = append(componentEdges[root], edge)
+			}
+		}
+	}
+	components, err := merger.apply(&progress{ctx, g.progress})
+	if err != nil {
+		return err
+	}
+	componentsSize := make([]float64, 0, len(components))
+	for _, component := range components {
+		componentsSize = append(componentsSize, float64(len(component)))
+		if edges := componentEdges[component[0]]; len(edges) > 0 {
+			people[component[0]].MergeEvidence = append(people[component[0]].MergeEvidence, edges...)
+		}
+	}
+	mean, std := stat.MeanStdDev(componentsSize, nil)
+	if mean != mean {
+		mean = 0
+	}
+	if std != std {
+		std = 0
+	}
+	reporter.Commit("connected component size mean", mean)
+	reporter.Commit("connected component size std", std)
+	reporter.Commit("connected component size max", floats.Max(componentsSize))
+	reporter.Commit("people after reduce", len(people))
+	return nil
+}
+
+// setEdge propagates ExternalIDs when you connect two components. The components with
+// different external IDs of the same provider are handled according to
+// ExternalIDOptions.Conflicts: the edge is either refused with an error, recorded for the review
+// and not set, or set after choosing the common external IDs. The evidence is the one which
+// activates the edge. setEdge returns whether the edge was set.
+func (g *IdentityGraph) setEdge(node1, node2 node, evidence Evidence) (bool, error) {
+	if provider, conflicts := node1.Value.ExternalIDs.conflict(node2.Value.ExternalIDs); conflicts {
+		switch g.externalIDs.Conflicts {
+		case ExternalIDConflictPriority, ExternalIDConflictKeepBoth:
+			g.resolveExternalIDs(node1, node2)
+		case ExternalIDConflictReview:
+			g.recordExternalIDConflict(node1, node2, provider, evidence)
+			return false, nil
+		default:
+			return false, fmt.Errorf(
+				"%w: cannot set edge between nodes with different ExternalIDs: %s %s",
+				ErrExternalIDConflict, node1.Value.ExternalIDs[provider], node2.Value.ExternalIDs[provider])
+		}
+	}
+	externalIDs1, externalIDs2 := node1.Value.ExternalIDs, node2.Value.ExternalIDs
+	if !externalIDs1.equal(externalIDs2) {
+		newExternalIDs := externalIDs1.merge(externalIDs2)
+		var accounts []ExternalAccount
+		if node1.Value.ExternalAccounts != nil || node2.Value.ExternalAccounts != nil {
+			accounts = mergeExternalAccounts(mergeExternalAccounts(
+				node1.Value.ExternalAccounts, node2.Value.ExternalAccounts), newExternalIDs.accounts())
+		}
+		for _, nodeToFix := range []node{node1, node2} {
+			var w traverse.DepthFirst
+			w.Walk(g.graph, nodeToFix, func(sn simplegraph.Node) bool {
+				n := sn.(node)
+				if provider, conflicts := n.Value.ExternalIDs.conflict(newExternalIDs); conflicts {
+					panic(fmt.Errorf(
+						"cannot set edge between components with different ExternalIDs: |%s| |%s|",
+						newExternalIDs[provider], n.Value.ExternalIDs[provider]))
+				}
+				n.Value.ExternalIDs = newExternalIDs.Copy()
+				if accounts != nil {
+					n.Value.ExternalAccounts = append([]ExternalAccount(nil), accounts...)
+				}
+				return false
+			})
+		}
+	}
+
+	g.graph.SetEdge(g.graph.NewEdge(node1, node2))
+	reporter.Increment("graph edges")
+	return true, nil
+}
+
+// componentUniqueEmailsAndNames calculates the number of unique emails and names in the component
+// with n node inside
+func componentUniqueEmailsAndNames(graph *simple.UndirectedGraph, n simplegraph.Node) (int, int) {
+	emails := map[string]struct{}{}
+	names := map[string]struct{}{}
+	var w traverse.DepthFirst
+	w.Walk(graph, n, func(sn simplegraph.Node) bool {
+		for _, email := range sn.(node).Value.Emails {
+			emails[email] = struct{}{}
+		}
+		for _, name := range sn.(node).Value.NamesWithRepos {
+			names[name.String()] = struct{}{}
+		}
+		return false
+	})
+	return len(emails), len(names)
+}
@@ -0,0 +1,217 @@
+package idmatch
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// GraphFormat is the file format of the exported IdentityGraph.
+type GraphFormat string
+
+const (
+	// GraphFormatGraphML is the XML-based format supported by Gephi, yEd, Cytoscape, etc.
+	GraphFormatGraphML GraphFormat = "graphml"
+	// GraphFormatDOT is the Graphviz format.
+	GraphFormatDOT GraphFormat = "dot"
+)
+
+// GraphFormats lists the supported formats of WriteGraph.
+var GraphFormats = []GraphFormat{GraphFormatGraphML, GraphFormatDOT}
+
+// graphAttr is a named attribute of a node or an edge.
+type graphAttr struct {
+	key   string
+	value string
+}
+
+// graphRecord is the textual representation of a node or an edge shared by all the formats.
+// The edges have source and target instead of id.
+type graphRecord struct {
+	id     string
+	source string
+	target string
+	attrs  []graphAttr
+}
+
+func (r graphRecord) attr(key string) string {
+	for _, attr := range r.attrs {
+		if attr.key == key {
+			return attr.value
+		}
+	}
+	return ""
+}
+
+// graphRecords converts the graph into the records. Each node is a signature which carries
+// the ID of the person it belongs to after the reduction; each edge carries the evidence.
+func (g *IdentityGraph) graphRecords() (nodes, edges []graphRecord) {
+	person := map[int64]int64{}
+	for _, component := range g.Components() {
+		for _, id := range component {
+			person[id] = component[0]
+		}
+	}
+	for _, n := range g.Nodes() {
+		names := make([]string, len(n.NamesWithRepos))
+		for i, name := range n.NamesWithRepos {
+			names[i] = name.String()
+		}
+		nodes = append(nodes, graphRecord{id: strconv.FormatInt(n.ID, 10), attrs: []graphAttr{
+			{"names", strings.Join(names, "; ")},
+			{"emails", strings.Join(n.Emails, "; ")},
+			{"person", strconv.FormatInt(person[n.ID], 10)},
+		}})
+	}
+	for _, e := range g.Edges() {
+		edges = append(edges, graphRecord{
+			source: strconv.FormatInt(e.From, 10),
+			target: strconv.FormatInt(e.To, 10),
+			attrs: []graphAttr{
+				{"evidence", formatEvidence(e.Evidence)},
+				{"weight", strconv.FormatFloat(e.Weight, 'g', -1, 64)},
+				{"active", strconv.FormatBool(e.Active)},
+			}})
+	}
+	return
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+func graphMLRecordData(record graphRecord) []graphMLData {
+	data := make([]graphMLData, len(record.attrs))
+	for i, attr := range record.attrs {
+		data[i] = graphMLData{attr.key, attr.value}
+	}
+	return data
+}
+
+func (g *IdentityGraph) writeGraphML(output io.Writer) error {
+	nodes, edges := g.graphRecords()
+	doc := graphMLDocument{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{"names", "node", "names", "string"},
+			{"emails", "node", "emails", "string"},
+			{"person", "node", "person", "long"},
+			{"evidence", "edge", "evidence", "string"},
+			{"weight", "edge", "weight", "double"},
+			{"active", "edge", "active", "boolean"},
+		},
+		Graph: graphMLGraph{ID: "identities", EdgeDefault: "undirected"},
+	}
+	for _, record := range nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{record.id, graphMLRecordData(record)})
+	}
+	for _, record := range edges {
+		doc.Graph.Edges = append(doc.Graph.Edges,
+			graphMLEdge{record.source, record.target, graphMLRecordData(record)})
+	}
+	if _, err := io.WriteString(output, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(output)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(output, "\n")
+	return err
+}
+
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func dotAttrs(record graphRecord, extra ...string) string {
+	attrs := make([]string, 0, len(record.attrs)+len(extra))
+	for _, attr := range record.attrs {
+		attrs = append(attrs, attr.key+`="`+dotEscaper.Replace(attr.value)+`"`)
+	}
+	return strings.Join(append(attrs, extra...), ", ")
+}
+
+func (g *IdentityGraph) writeDOT(output io.Writer) error {
+	nodes, edges := g.graphRecords()
+	buffer := bufio.NewWriter(output)
+	fmt.Fprintln(buffer, "graph identities {")
+	for _, record := range nodes {
+		label := record.attr("names") + "\n" + record.attr("emails")
+		fmt.Fprintf(buffer, "  %s [%s];\n", record.id,
+			dotAttrs(record, `label="`+dotEscaper.Replace(label)+`"`))
+	}
+	for _, record := range edges {
+		style := "solid"
+		if record.attr("active") != "true" {
+			style = "dashed"
+		}
+		fmt.Fprintf(buffer, "  %s -- %s [%s];\n", record.source, record.target,
+			dotAttrs(record, `label="`+dotEscaper.Replace(record.attr("evidence"))+`"`, "style="+style))
+	}
+	fmt.Fprintln(buffer, "}")
+	return buffer.Flush()
+}
+
+// WriteGraph saves the whole evidence graph to the file in the given format, so that
+// the clusters can be visualized in Gephi or Graphviz. The nodes are the signatures with
+// the names, the emails and the ID of the person after the reduction; the edges carry
+// the evidence, the weight and whether they joined the identities.
+func (g *IdentityGraph) WriteGraph(path string, format GraphFormat) (err error) {
+	var write func(io.Writer) error
+	switch format {
+	case GraphFormatGraphML:
+		write = g.writeGraphML
+	case GraphFormatDOT:
+		write = g.writeDOT
+	default:
+		return fmt.Errorf("unsupported graph format: %s", format)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	return write(file)
+}
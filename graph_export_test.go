@@ -0,0 +1,90 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newExportTestGraph(t *testing.T) *IdentityGraph {
+	t.Helper()
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bob \"b\"", "repo"}}, Emails: []string{"bob@google.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+	}
+	g, err := BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t),
+		ReduceOptions{MaxIdentities: 100})
+	require.NoError(t, err)
+	return g
+}
+
+func TestIdentityGraphWriteGraphML(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-graph")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "graph.graphml")
+	req.NoError(newExportTestGraph(t).WriteGraph(path, GraphFormatGraphML))
+	data, err := ioutil.ReadFile(path)
+	req.NoError(err)
+	req.Equal(`<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="names" for="node" attr.name="names" attr.type="string"></key>
+  <key id="emails" for="node" attr.name="emails" attr.type="string"></key>
+  <key id="person" for="node" attr.name="person" attr.type="long"></key>
+  <key id="evidence" for="edge" attr.name="evidence" attr.type="string"></key>
+  <key id="weight" for="edge" attr.name="weight" attr.type="double"></key>
+  <key id="active" for="edge" attr.name="active" attr.type="boolean"></key>
+  <graph id="identities" edgedefault="undirected">
+    <node id="1">
+      <data key="names">bob</data>
+      <data key="emails">bob@google.com</data>
+      <data key="person">1</data>
+    </node>
+    <node id="2">
+      <data key="names">{bob &#34;b&#34;, repo}</data>
+      <data key="emails">bob@google.com</data>
+      <data key="person">1</data>
+    </node>
+    <node id="3">
+      <data key="names">alice</data>
+      <data key="emails">alice@google.com</data>
+      <data key="person">3</data>
+    </node>
+    <edge source="1" target="2">
+      <data key="evidence">email:bob@google.com</data>
+      <data key="weight">1</data>
+      <data key="active">true</data>
+    </edge>
+  </graph>
+</graphml>
+`, string(data))
+}
+
+func TestIdentityGraphWriteDOT(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-graph")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "graph.dot")
+	req.NoError(newExportTestGraph(t).WriteGraph(path, GraphFormatDOT))
+	data, err := ioutil.ReadFile(path)
+	req.NoError(err)
+	req.Equal(`graph identities {
+  1 [names="bob", emails="bob@google.com", person="1", label="bob\nbob@google.com"];
+  2 [names="{bob \"b\", repo}", emails="bob@google.com", person="1", label="{bob \"b\", repo}\nbob@google.com"];
+  3 [names="alice", emails="alice@google.com", person="3", label="alice\nalice@google.com"];
+  1 -- 2 [evidence="email:bob@google.com", weight="1", active="true", label="email:bob@google.com", style=solid];
+}
+`, string(data))
+}
+
+func TestIdentityGraphWriteGraphUnsupportedFormat(t *testing.T) {
+	err := newExportTestGraph(t).WriteGraph(filepath.Join(os.TempDir(), "graph.txt"), "txt")
+	require.EqualError(t, err, "unsupported graph format: txt")
+}
@@ -0,0 +1,230 @@
+package idmatch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newGraphTestPeople() People {
+	return People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"al@google.com"}},
+		5: {ID: 5, NamesWithRepos: []NameWithRepo{{"eve", ""}}, Emails: []string{"eve@google.com"}},
+	}
+}
+
+func TestBuildIdentityGraph(t *testing.T) {
+	req := require.New(t)
+	people := newGraphTestPeople()
+	g, err := BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t),
+		ReduceOptions{MaxIdentities: 100})
+	req.NoError(err)
+	req.Len(g.Nodes(), 5)
+	req.Equal(int64(1), g.Nodes()[0].ID)
+	req.Equal([]string{"bob@google.com"}, g.Nodes()[0].Emails)
+
+	edges := g.Edges()
+	req.Len(edges, 2)
+	req.Equal(IdentityEdge{From: 1, To: 2, Evidence: []Evidence{
+		{EvidenceEmail, "bob@google.com", 1},
+		{EvidenceName, "bob", 1},
+	}, Weight: 2, Active: true}, edges[0])
+	req.Equal(IdentityEdge{From: 3, To: 4, Evidence: []Evidence{
+		{EvidenceName, "alice", 1},
+	}, Weight: 1, Active: true}, edges[1])
+	edge, exists := g.Edge(2, 1)
+	req.True(exists)
+	req.Equal(edges[0], edge)
+	_, exists = g.Edge(1, 5)
+	req.False(exists)
+	req.Equal([][]int64{{1, 2}, {3, 4}, {5}}, g.Components())
+
+	// the graph does not change the people
+	req.Equal(newGraphTestPeople(), people)
+	req.NoError(g.Reduce(context.Background(), people))
+	req.Len(people, 3)
+	req.Equal([]string{"al@google.com", "alice@google.com"}, people[3].Emails)
+}
+
+func TestBuildIdentityGraphMinEdgeWeight(t *testing.T) {
+	req := require.New(t)
+	people := newGraphTestPeople()
+	g, err := BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t), ReduceOptions{
+		MaxIdentities: 100,
+		MinEdgeWeight: 2,
+	})
+	req.NoError(err)
+	req.Len(g.Edges(), 2)
+	edge, _ := g.Edge(3, 4)
+	req.False(edge.Active)
+	req.Equal([][]int64{{1, 2}, {3}, {4}, {5}}, g.Components())
+
+	people = newGraphTestPeople()
+	g, err = BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t), ReduceOptions{
+		MaxIdentities:   100,
+		MinEdgeWeight:   2,
+		EvidenceWeights: map[EvidenceKind]float64{EvidenceName: 2, EvidenceEmail: 0.5},
+	})
+	req.NoError(err)
+	edge, _ = g.Edge(1, 2)
+	req.True(edge.Active)
+	req.Equal(2.5, edge.Weight)
+	req.Equal([][]int64{{1, 2}, {3, 4}, {5}}, g.Components())
+}
+
+func TestBuildIdentityGraphTrailers(t *testing.T) {
+	req := require.New(t)
+	people := newGraphTestPeople()
+	people[6] = &Person{ID: 6, NamesWithRepos: []NameWithRepo{{"bob", ""}},
+		Emails: []string{"bob@google.com"}, TrailerRole: RoleSignedOffBy}
+	people[7] = &Person{ID: 7, NamesWithRepos: []NameWithRepo{{"eve", ""}},
+		Emails: []string{"eve@google.com"}, TrailerRole: RoleReviewedBy}
+	g, err := BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t), ReduceOptions{
+		MaxIdentities: 100,
+		MinEdgeWeight: 1,
+		EvidenceWeights: map[EvidenceKind]float64{
+			EvidenceKind(RoleSignedOffBy): 0.25, EvidenceKind(RoleReviewedBy): 0.5},
+	})
+	req.NoError(err)
+	for _, edge := range g.Edges() {
+		if edge.To < 6 {
+			continue
+		}
+		for _, evidence := range edge.Evidence {
+			req.Contains([]EvidenceKind{EvidenceKind(RoleSignedOffBy), EvidenceKind(RoleReviewedBy)},
+				evidence.Kind)
+		}
+	}
+	edge, exists := g.Edge(5, 7)
+	req.True(exists)
+	req.Equal(1.0, edge.Weight)
+	req.True(edge.Active)
+	edge, exists = g.Edge(1, 2)
+	req.True(exists)
+	req.Equal(2.0, edge.Weight)
+	req.Equal([][]int64{{1, 2}, {3, 4}, {5, 7}, {6}}, g.Components())
+
+	req.NoError(g.Reduce(context.Background(), people))
+	req.Equal(SignatureRole(""), people[5].TrailerRole)
+	req.Equal(RoleSignedOffBy, people[6].TrailerRole)
+}
+
+func TestBuildIdentityGraphSigningKeys(t *testing.T) {
+	req := require.New(t)
+	people := newGraphTestPeople()
+	people[3].SigningKeys = []string{"0123456789abcdef"}
+	people[5].SigningKeys = []string{"0123456789abcdef", "fedcba9876543210"}
+	people[6] = &Person{ID: 6, NamesWithRepos: []NameWithRepo{{"mallory", ""}},
+		Emails: []string{"mallory@google.com"}, SigningKeys: []string{"fedcba9876543210"}}
+	g, err := BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t),
+		ReduceOptions{MaxIdentities: 100})
+	req.NoError(err)
+	edge, exists := g.Edge(3, 5)
+	req.True(exists)
+	req.Equal([]Evidence{{EvidenceSigningKey, "0123456789abcdef", 1}}, edge.Evidence)
+	req.Equal([][]int64{{1, 2}, {3, 4, 5, 6}}, g.Components())
+
+	req.NoError(g.Reduce(context.Background(), people))
+	req.Equal([]string{"0123456789abcdef", "fedcba9876543210"}, people[3].SigningKeys)
+}
+
+func TestBuildIdentityGraphBehavior(t *testing.T) {
+	req := require.New(t)
+	berlin := time.FixedZone("CET", 3600)
+	tokyo := time.FixedZone("JST", 9*3600)
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	var european, asian []time.Time
+	for i := 0; i < 10; i++ {
+		european = append(european, day.AddDate(0, 0, i).Add(10*time.Hour).In(berlin))
+		asian = append(asian, day.AddDate(0, 0, i).Add(time.Hour).In(tokyo))
+	}
+	newPeople := func() People {
+		people := newGraphTestPeople()
+		people[1].Activity = testActivity(european...)
+		people[2].Activity = testActivity(asian...)
+		people[3].Activity = testActivity(european...)
+		people[4].Activity = testActivity(asian...)
+		people[5].Activity = testActivity(european...)
+		people[6] = &Person{ID: 6, NamesWithRepos: []NameWithRepo{{"eve", ""}},
+			Emails: []string{"eve@gmail.com"}, Activity: testActivity(european...)}
+		return people
+	}
+	opts := ReduceOptions{
+		MaxIdentities: 100,
+		MinEdgeWeight: 2,
+		Behavior:      &BehaviorOptions{MinCommits: 5, VetoSimilarity: 0.3, MinSimilarity: 0.8},
+	}
+	g, err := BuildIdentityGraph(context.Background(), newPeople(), nil, newTestBlacklist(t), opts)
+	req.NoError(err)
+	edge, _ := g.Edge(5, 6)
+	req.Equal([]Evidence{{EvidenceName, "eve", 1}, {EvidenceActivity, "1.00", 1}}, edge.Evidence)
+	req.True(edge.Active)
+	req.Equal([][]int64{{1, 2}, {3}, {4}, {5, 6}}, g.Components())
+	people := newPeople()
+	req.NoError(g.Reduce(context.Background(), people))
+	req.Equal(20, people[5].Activity.Commits())
+
+	opts.MinEdgeWeight = 1
+	g, err = BuildIdentityGraph(context.Background(), newPeople(), nil, newTestBlacklist(t), opts)
+	req.NoError(err)
+	edge, _ = g.Edge(3, 4)
+	req.False(edge.Active)
+	edge, _ = g.Edge(1, 2)
+	req.True(edge.Active)
+	req.Equal([][]int64{{1, 2}, {3}, {4}, {5, 6}}, g.Components())
+
+	opts.Behavior.MinCommits = 20
+	g, err = BuildIdentityGraph(context.Background(), newPeople(), nil, newTestBlacklist(t), opts)
+	req.NoError(err)
+	req.Equal([][]int64{{1, 2}, {3, 4}, {5, 6}}, g.Components())
+	for _, edge := range g.Edges() {
+		for _, evidence := range edge.Evidence {
+			req.NotEqual(EvidenceActivity, evidence.Kind)
+		}
+	}
+}
+
+func TestIdentityGraphAddEvidenceExternalIDs(t *testing.T) {
+	req := require.New(t)
+	people := newGraphTestPeople()
+	people[1].ExternalIDs = ExternalIDs{"github": "bob"}
+	people[3].ExternalIDs = ExternalIDs{"github": "alice"}
+	g := newIdentityGraph(people, nil, 0)
+	req.NoError(g.addEvidence(g.node(1), g.node(2), EvidenceName, "bob"))
+	req.Equal("bob", people[2].ExternalIDs["github"])
+	req.Error(g.addEvidence(g.node(2), g.node(3), EvidenceName, "x"))
+	_, exists := g.Edge(2, 3)
+	req.False(exists)
+	req.NoError(g.addEvidence(g.node(1), g.node(1), EvidenceName, "bob"))
+	req.Len(g.Edges(), 1)
+}
+
+func BenchmarkIdentityGraphReduce(b *testing.B) {
+	blacklist, err := LoadBlacklist()
+	if err != nil {
+		b.Fatal(err)
+	}
+	runScaleBenchmarks(b, func(b *testing.B, signatures []Signature) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			people, err := newPeople(nil, signatures, blacklist)
+			if err != nil {
+				b.Fatal(err)
+			}
+			g, err := BuildIdentityGraph(context.Background(), people, nil, blacklist,
+				ReduceOptions{MaxIdentities: 20})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.StartTimer()
+			if err := g.Reduce(context.Background(), people); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -1,6 +1,6 @@
 #!/bin/sh -ex
 
-match-identities \
+match-identities match \
     --output "${IDENTITY_MATCHING_OUTPUT}" \
     --host "${IDENTITY_MATCHING_GITBASE_HOST}" \
     --port ${IDENTITY_MATCHING_GITBASE_PORT} \
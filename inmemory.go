@@ -0,0 +1,92 @@
+package idmatch
+
+import (
+	"context"
+
+	"github.com/src-d/identity-matching/external"
+)
+
+// MatchOptions configure MatchSignatures, see PeopleFromSignatures and ReducePeople.
+type MatchOptions struct {
+	// Extraction are the options of PeopleFromSignatures. The options which query gitbase or
+	// read the disk are ignored.
+	Extraction   ExtractionOptions
+	Blacklist    Blacklist
+	Popularity   PopularityThresholds
+	Bots         BotDetectionOptions
+	RecentMonths int
+	Reduce       ReduceOptions
+	// Matcher is the external matching service. nil keeps the matching in memory.
+	Matcher external.Matcher
+}
+
+// NewMatchOptions returns the same defaults as the match-identities command: the built-in
+// blacklist, the bot detection and the 12 recent months.
+func NewMatchOptions() (MatchOptions, error) {
+	blacklist, err := NewBlacklist()
+	if err != nil {
+		return MatchOptions{}, err
+	}
+	return MatchOptions{
+		Extraction:   NewExtractionOptions(),
+		Blacklist:    blacklist,
+		Bots:         NewBotDetectionOptions(),
+		RecentMonths: 12,
+		Reduce:       ReduceOptions{MaxIdentities: 20},
+	}, nil
+}
+
+// MatchStats summarize MatchSignatures.
+type MatchStats struct {
+	// Signatures is the number of the given signatures.
+	Signatures int
+	// Identities is the number of the people before the matching.
+	Identities int
+	// People is the number of the matched people.
+	People int
+	// Bots is the number of the matched people with Person.IsBot.
+	Bots int
+	// NameFrequencies and EmailFrequencies are the frequencies of the names and the emails
+	// in the signatures which decided the popular values.
+	NameFrequencies  map[string]*Frequency
+	EmailFrequencies map[string]*Frequency
+}
+
+// MatchSignatures matches the signatures into the people without gitbase and the disk, so that
+// the programs which collect the signatures themselves embed the matching as a library. It is
+// PeopleFromSignatures followed by ReducePeople with the blacklist extended by the popular and
+// the shared values. The signatures are not changed. Only opts.Matcher and the samplers of
+// opts.Reduce.Style leave the process, if they are set. opts.Extraction.Stats and
+// opts.Extraction.SharedMailboxes receive the results, so the concurrent calls must not share
+// them.
+func MatchSignatures(ctx context.Context, signatures []Signature, opts MatchOptions) (
+	People, MatchStats, error) {
+	recentMonths := opts.RecentMonths
+	if recentMonths == 0 {
+		recentMonths = 12
+	}
+	stats := MatchStats{Signatures: len(signatures)}
+	// PeopleFromSignatures may change the signatures
+	commits := append([]Signature(nil), signatures...)
+	people, nameFreqs, emailFreqs, err := PeopleFromSignatures(ctx, commits, opts.Extraction,
+		opts.Blacklist, opts.Popularity, opts.Bots, recentMonths, opts.Reduce.Progress)
+	if err != nil {
+		return nil, stats, err
+	}
+	stats.Identities = len(people)
+	stats.NameFrequencies, stats.EmailFrequencies = nameFreqs, emailFreqs
+	blacklist := opts.Blacklist.WithPopular(nameFreqs, emailFreqs, opts.Popularity)
+	if opts.Extraction.SharedMailboxes != nil {
+		blacklist = blacklist.WithSharedMailboxes(opts.Extraction.SharedMailboxes.Mailboxes())
+	}
+	if err = ReducePeople(ctx, people, opts.Matcher, blacklist, opts.Reduce); err != nil {
+		return nil, stats, err
+	}
+	stats.People = len(people)
+	for _, person := range people {
+		if person.IsBot {
+			stats.Bots++
+		}
+	}
+	return people, stats, nil
+}
@@ -0,0 +1,47 @@
+package idmatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchSignatures(t *testing.T) {
+	req := require.New(t)
+	signatures := []Signature{
+		{Repo: "repo1", Email: "bob@google.com", Name: "Bob"},
+		{Repo: "repo2", Email: "bob@google.com", Name: "Robert"},
+		{Repo: "repo1", Email: "alice@google.com", Name: "Alice"},
+		{Repo: "repo1", Email: "alice@gmail.com", Name: "Alice"},
+		{Repo: "repo1", Email: "eve@google.com", Name: "Eve"},
+	}
+	opts := MatchOptions{Blacklist: newTestBlacklist(t), Reduce: ReduceOptions{MaxIdentities: 20}}
+	people, stats, err := MatchSignatures(context.Background(), signatures, opts)
+	req.NoError(err)
+	req.Equal("Bob", signatures[0].Name)
+	req.Len(people, 3)
+	req.Equal(5, stats.Signatures)
+	req.Equal(5, stats.Identities)
+	req.Equal(3, stats.People)
+	req.Equal(0, stats.Bots)
+	req.Equal(2, stats.EmailFrequencies["bob@google.com"].Total)
+	req.Equal(2, stats.NameFrequencies["alice"].Total)
+	emails := map[string]int64{}
+	for id, person := range people {
+		for _, email := range person.Emails {
+			emails[email] = id
+		}
+	}
+	req.Equal(emails["alice@google.com"], emails["alice@gmail.com"])
+	req.NotEqual(emails["alice@google.com"], emails["bob@google.com"])
+}
+
+func TestNewMatchOptions(t *testing.T) {
+	req := require.New(t)
+	opts, err := NewMatchOptions()
+	req.NoError(err)
+	req.Equal(12, opts.RecentMonths)
+	req.Equal(20, opts.Reduce.MaxIdentities)
+	req.NotEmpty(opts.Blacklist.Names)
+}
@@ -0,0 +1,128 @@
+package idmatch
+
+import (
+	"sync"
+)
+
+// StringTable interns the strings: every distinct value is stored once and has the integer
+// handle. The names, the emails and the repositories repeat millions of times in the signatures
+// and the people, so keeping a single copy of each cuts the memory several times.
+// StringTable is safe for concurrent use.
+type StringTable struct {
+	lock    sync.RWMutex
+	handles map[string]uint32
+	values  []string
+}
+
+// NewStringTable creates the empty table.
+func NewStringTable() *StringTable {
+	return &StringTable{handles: map[string]uint32{}}
+}
+
+// Intern adds the string to the table if it is new and returns its handle.
+func (t *StringTable) Intern(value string) uint32 {
+	handle, _ := t.intern(value)
+	return handle
+}
+
+// String returns the interned copy of the string, which shares the memory with all the other
+// copies returned by the table.
+func (t *StringTable) String(value string) string {
+	_, interned := t.intern(value)
+	return interned
+}
+
+func (t *StringTable) intern(value string) (uint32, string) {
+	t.lock.RLock()
+	handle, exists := t.handles[value]
+	if exists {
+		value = t.values[handle]
+	}
+	t.lock.RUnlock()
+	if exists {
+		return handle, value
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if handle, exists := t.handles[value]; exists {
+		return handle, t.values[handle]
+	}
+	// the copy does not retain the larger string which the value may be a part of, e.g. a CSV line
+	value = string([]byte(value))
+	handle = uint32(len(t.values))
+	t.handles[value] = handle
+	t.values = append(t.values, value)
+	return handle, value
+}
+
+// Handle returns the handle of the string and whether it is interned.
+func (t *StringTable) Handle(value string) (uint32, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	handle, exists := t.handles[value]
+	return handle, exists
+}
+
+// Lookup returns the string with the handle. It panics if the handle does not exist.
+func (t *StringTable) Lookup(handle uint32) string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return t.values[handle]
+}
+
+// Len returns the number of the interned strings.
+func (t *StringTable) Len() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return len(t.values)
+}
+
+// internSignatures replaces the repositories, the names and the emails of the signatures with
+// the interned copies.
+func internSignatures(table *StringTable, signatures []Signature) {
+	for i := range signatures {
+		signature := &signatures[i]
+		signature.Repo = table.String(signature.Repo)
+		signature.Name = table.String(signature.Name)
+		signature.Email = table.String(signature.Email)
+	}
+}
+
+// internPeople replaces the names, the emails and the repositories of the people with
+// the interned copies.
+func internPeople(table *StringTable, people People) {
+	for _, person := range people {
+		for i := range person.NamesWithRepos {
+			name := &person.NamesWithRepos[i]
+			name.Name = table.String(name.Name)
+			name.Repo = table.String(name.Repo)
+		}
+		for i, email := range person.Emails {
+			person.Emails[i] = table.String(email)
+		}
+		for i, repo := range person.Repositories {
+			person.Repositories[i] = table.String(repo)
+		}
+		person.EmailSources = internSourceKeys(table, person.EmailSources)
+		person.NameSources = internSourceKeys(table, person.NameSources)
+		person.AliasHistory.Emails = replaceSpanKeys(person.AliasHistory.Emails, table.String)
+		person.AliasHistory.Names = replaceSpanKeys(person.AliasHistory.Names, table.String)
+		if person.SampleCommit != nil {
+			person.SampleCommit.Repo = table.String(person.SampleCommit.Repo)
+		}
+		for i := range person.SampleCommits {
+			person.SampleCommits[i].Repo = table.String(person.SampleCommits[i].Repo)
+		}
+	}
+}
+
+func internSourceKeys(table *StringTable, sources map[string][]SourceKind) map[string][]SourceKind {
+	if sources == nil {
+		return nil
+	}
+	result := make(map[string][]SourceKind, len(sources))
+	for key, kinds := range sources {
+		result[table.String(key)] = kinds
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package idmatch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringTable(t *testing.T) {
+	req := require.New(t)
+	table := NewStringTable()
+	req.Equal(uint32(0), table.Intern("bob"))
+	req.Equal(uint32(1), table.Intern("alice"))
+	req.Equal(uint32(0), table.Intern("bob"))
+	req.Equal("alice", table.String("alice"))
+	req.Equal("eve", table.String("eve"))
+	req.Equal(3, table.Len())
+	handle, exists := table.Handle("eve")
+	req.True(exists)
+	req.Equal(uint32(2), handle)
+	_, exists = table.Handle("mallory")
+	req.False(exists)
+	req.Equal("alice", table.Lookup(1))
+	req.Panics(func() { table.Lookup(3) })
+}
+
+func TestStringTableConcurrent(t *testing.T) {
+	req := require.New(t)
+	table := NewStringTable()
+	var wg sync.WaitGroup
+	for worker := 0; worker < 4; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				table.String(fmt.Sprint(i % 100))
+			}
+		}()
+	}
+	wg.Wait()
+	req.Equal(100, table.Len())
+	for i := 0; i < 100; i++ {
+		handle, exists := table.Handle(fmt.Sprint(i))
+		req.True(exists)
+		req.Equal(fmt.Sprint(i), table.Lookup(handle))
+	}
+}
+
+func TestFindPeopleInternsStrings(t *testing.T) {
+	req := require.New(t)
+	peopleFile, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(storeSignaturesOnDisk(peopleFile.Name(), Signatures))
+	table := NewStringTable()
+	people, _, _, err := FindPeople(context.Background(), "", peopleFile.Name(),
+		ExtractionOptions{Strings: table}, newTestBlacklist(t), PopularityThresholds{},
+		BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	req.Len(people, 4)
+	for _, value := range []string{"repo1", "repo2", "bob", "alice", "bob@google.com", "alice@google.com"} {
+		_, exists := table.Handle(value)
+		req.True(exists, value)
+	}
+	handle, _ := table.Handle("bob")
+	req.Equal(people[1].NamesWithRepos[0].Name, table.Lookup(handle))
+}
@@ -0,0 +1,235 @@
+package idmatch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// MailmapEntry is a line of the Git .mailmap file which maps the commit name and email to
+// the proper ones. Either the proper name or the proper email may be empty, and the commit name
+// is empty unless the entry applies only to the commits with that name.
+type MailmapEntry struct {
+	ProperName  string
+	ProperEmail string
+	CommitName  string
+	CommitEmail string
+}
+
+// Mailmap is the parsed Git .mailmap file, see `git help check-mailmap`.
+type Mailmap []MailmapEntry
+
+// ReadMailmap parses the .mailmap file. The forms of the lines are
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+//
+// The text after "#" is a comment.
+func ReadMailmap(path string) (Mailmap, error) {
+	file, err := OpenPath(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var mailmap Mailmap
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry, err := parseMailmapLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mailmap %s:%d: %v", path, lineNumber, err)
+		}
+		mailmap = append(mailmap, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mailmap, nil
+}
+
+// parseMailmapLine parses a line of .mailmap without the comment.
+func parseMailmapLine(line string) (MailmapEntry, error) {
+	var names, emails []string
+	for {
+		start := strings.Index(line, "<")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(line[start:], ">")
+		if end < 0 {
+			return MailmapEntry{}, fmt.Errorf("unclosed <")
+		}
+		names = append(names, strings.TrimSpace(line[:start]))
+		emails = append(emails, strings.TrimSpace(line[start+1:start+end]))
+		line = line[start+end+1:]
+	}
+	if strings.TrimSpace(line) != "" {
+		return MailmapEntry{}, fmt.Errorf("unexpected %q after the last email", strings.TrimSpace(line))
+	}
+	switch len(emails) {
+	case 1:
+		if names[0] == "" {
+			return MailmapEntry{}, fmt.Errorf("no proper name")
+		}
+		return MailmapEntry{ProperName: names[0], CommitEmail: emails[0]}, nil
+	case 2:
+		return MailmapEntry{ProperName: names[0], ProperEmail: emails[0],
+			CommitName: names[1], CommitEmail: emails[1]}, nil
+	default:
+		return MailmapEntry{}, fmt.Errorf("expected 1 or 2 emails, got %d", len(emails))
+	}
+}
+
+// Constraints returns the must-link constraints of the commit emails which map to the same
+// proper email, including the proper email itself. The entries without the proper email only
+// rename and do not link anything, because the same name does not imply the same person.
+func (m Mailmap) Constraints() (Constraints, error) {
+	groups := map[string][]string{}
+	var order []string
+	for _, entry := range m {
+		if entry.ProperEmail == "" {
+			continue
+		}
+		proper, err := cleanEmail(entry.ProperEmail)
+		if err != nil {
+			return nil, err
+		}
+		commit, err := cleanEmail(entry.CommitEmail)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := groups[proper]; !exists {
+			order = append(order, proper)
+			groups[proper] = []string{"email:" + proper}
+		}
+		groups[proper] = append(groups[proper], "email:"+commit)
+	}
+	var constraints Constraints
+	for _, proper := range order {
+		keys := groups[proper]
+		for i, key := range keys[1:] {
+			duplicate := false
+			for _, previous := range keys[:i+1] {
+				duplicate = duplicate || previous == key
+			}
+			if !duplicate {
+				constraints = append(constraints, Constraint{ConstraintMustLink, keys[0], key})
+			}
+		}
+	}
+	return constraints, nil
+}
+
+// SetPrimaryValues overrides the primary names and emails of the people who have the commit
+// emails, and the commit names if specified, of the entries with the proper ones. It should be
+// called after the package-level SetPrimaryValues.
+func (m Mailmap) SetPrimaryValues(people People) error {
+	type commitKey struct {
+		name  string
+		email string
+	}
+	entries := map[commitKey]MailmapEntry{}
+	for _, entry := range m {
+		var key commitKey
+		var err error
+		if key.email, err = cleanEmail(entry.CommitEmail); err != nil {
+			return err
+		}
+		if key.name, err = cleanName(entry.CommitName); err != nil {
+			return err
+		}
+		entries[key] = entry
+	}
+	for _, person := range people {
+		for _, email := range person.Emails {
+			matches := []MailmapEntry{}
+			if entry, exists := entries[commitKey{"", email}]; exists {
+				matches = append(matches, entry)
+			}
+			for _, name := range person.NamesWithRepos {
+				if entry, exists := entries[commitKey{name.Name, email}]; exists {
+					matches = append(matches, entry)
+				}
+			}
+			for _, entry := range matches {
+				if entry.ProperName != "" {
+					person.PrimaryName = entry.ProperName
+				}
+				if entry.ProperEmail != "" {
+					person.PrimaryEmail = entry.ProperEmail
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// WriteMailmap exports the people in the .mailmap format: every email of each person maps to
+// the primary name and email, so that the file can be put into the repositories as is.
+// The first name and email stand in for the missing primary values, see SetPrimaryValues.
+func (p People) WriteMailmap(path string) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	writer := bufio.NewWriter(file)
+	defer func() {
+		errFlush := writer.Flush()
+		if err == nil {
+			err = errFlush
+		}
+	}()
+	ids := make([]int64, 0, len(p))
+	for id := range p {
+		ids = append(ids, id)
+	}
+	Int64Slice(ids).Sort()
+	for _, id := range ids {
+		person := p[id]
+		if len(person.Emails) == 0 {
+			continue
+		}
+		emails := append([]string{}, person.Emails...)
+		sort.Strings(emails)
+		name, email := person.PrimaryName, person.PrimaryEmail
+		if name == "" && len(person.NamesWithRepos) > 0 {
+			name = person.NamesWithRepos[0].Name
+		}
+		if email == "" {
+			email = emails[0]
+		}
+		for _, commitEmail := range emails {
+			switch {
+			case commitEmail == email && name == "":
+				continue
+			case commitEmail == email:
+				_, err = fmt.Fprintf(writer, "%s <%s>\n", name, email)
+			case name == "":
+				_, err = fmt.Fprintf(writer, "<%s> <%s>\n", email, commitEmail)
+			default:
+				_, err = fmt.Fprintf(writer, "%s <%s> <%s>\n", name, email, commitEmail)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
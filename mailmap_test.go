@@ -0,0 +1,117 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testMailmap = `# the canonical identities
+Bob Smith <bob@google.com>
+<alice@google.com> <al@google.com>  # the old email
+Alice Liddell <alice@google.com> <alice@home.org>
+Eve <eve@google.com> eve <EVE@yahoo.com>
+
+`
+
+func TestReadMailmap(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, ".mailmap")
+	defer cleanup()
+	req.NoError(ioutil.WriteFile(f.Name(), []byte(testMailmap), 0666))
+	mailmap, err := ReadMailmap(f.Name())
+	req.NoError(err)
+	req.Equal(Mailmap{
+		{ProperName: "Bob Smith", CommitEmail: "bob@google.com"},
+		{ProperEmail: "alice@google.com", CommitEmail: "al@google.com"},
+		{ProperName: "Alice Liddell", ProperEmail: "alice@google.com", CommitEmail: "alice@home.org"},
+		{ProperName: "Eve", ProperEmail: "eve@google.com", CommitName: "eve", CommitEmail: "EVE@yahoo.com"},
+	}, mailmap)
+
+	for _, content := range []string{
+		"<bob@google.com>\n",
+		"Bob <bob@google.com\n",
+		"Bob <bob@google.com> trailing\n",
+		"Bob <a@google.com> <b@google.com> <c@google.com>\n",
+	} {
+		req.NoError(ioutil.WriteFile(f.Name(), []byte(content), 0666))
+		_, err = ReadMailmap(f.Name())
+		req.Error(err, content)
+	}
+}
+
+func TestMailmapConstraints(t *testing.T) {
+	req := require.New(t)
+	mailmap := Mailmap{
+		{ProperName: "Bob Smith", CommitEmail: "bob@google.com"},
+		{ProperEmail: "alice@google.com", CommitEmail: "al@google.com"},
+		{ProperName: "Alice Liddell", ProperEmail: "Alice@google.com", CommitEmail: "alice@home.org"},
+		{ProperName: "Alice", ProperEmail: "alice@google.com", CommitEmail: "alice@google.com"},
+		{ProperEmail: "eve@google.com", CommitName: "eve", CommitEmail: "EVE@yahoo.com"},
+	}
+	constraints, err := mailmap.Constraints()
+	req.NoError(err)
+	req.Equal(Constraints{
+		{ConstraintMustLink, "email:alice@google.com", "email:al@google.com"},
+		{ConstraintMustLink, "email:alice@google.com", "email:alice@home.org"},
+		{ConstraintMustLink, "email:eve@google.com", "email:eve@yahoo.com"},
+	}, constraints)
+
+	people := newGraphTestPeople()
+	g, err := BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t),
+		ReduceOptions{MaxIdentities: 100, MinEdgeWeight: 2, Constraints: constraints})
+	req.NoError(err)
+	req.Equal([][]int64{{1, 2}, {3, 4}, {5}}, g.Components())
+}
+
+func TestMailmapSetPrimaryValues(t *testing.T) {
+	req := require.New(t)
+	people := newGraphTestPeople()
+	people[5].NamesWithRepos = append(people[5].NamesWithRepos, NameWithRepo{"eve smith", ""})
+	people[5].Emails = append(people[5].Emails, "eve@yahoo.com")
+	for _, person := range people {
+		person.PrimaryName = person.NamesWithRepos[0].Name
+		person.PrimaryEmail = person.Emails[0]
+	}
+	mailmap := Mailmap{
+		{ProperName: "Bob Smith", CommitEmail: "Bob@google.com"},
+		{ProperEmail: "alice@google.com", CommitEmail: "al@google.com"},
+		{ProperName: "Eve", ProperEmail: "eve@google.com", CommitName: "Eve Smith", CommitEmail: "eve@yahoo.com"},
+		{ProperName: "Nobody", CommitName: "bob", CommitEmail: "eve@yahoo.com"},
+	}
+	req.NoError(mailmap.SetPrimaryValues(people))
+	req.Equal("Bob Smith", people[1].PrimaryName)
+	req.Equal("bob@google.com", people[1].PrimaryEmail)
+	req.Equal("alice", people[4].PrimaryName)
+	req.Equal("alice@google.com", people[4].PrimaryEmail)
+	req.Equal("alice@google.com", people[3].PrimaryEmail)
+	req.Equal("Eve", people[5].PrimaryName)
+	req.Equal("eve@google.com", people[5].PrimaryEmail)
+}
+
+func TestPeopleWriteMailmap(t *testing.T) {
+	req := require.New(t)
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com", "bob@home.org"},
+			PrimaryName: "Bob Smith", PrimaryEmail: "bob@google.com"},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"al@google.com", "alice@google.com"}},
+		3: {ID: 3, Emails: []string{"x@google.com", "y@google.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"nobody", ""}}},
+	}
+	f, cleanup := tempFile(t, ".mailmap")
+	defer cleanup()
+	req.NoError(people.WriteMailmap(f.Name()))
+	content, err := ioutil.ReadFile(f.Name())
+	req.NoError(err)
+	req.Equal(`Bob Smith <bob@google.com>
+Bob Smith <bob@google.com> <bob@home.org>
+alice <al@google.com>
+alice <al@google.com> <alice@google.com>
+<x@google.com> <y@google.com>
+`, string(content))
+	mailmap, err := ReadMailmap(f.Name())
+	req.NoError(err)
+	req.Len(mailmap, 5)
+}
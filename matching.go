@@ -2,30 +2,20 @@ package idmatch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/sirupsen/logrus"
-	"gonum.org/v1/gonum/floats"
-	simplegraph "gonum.org/v1/gonum/graph"
 	"gonum.org/v1/gonum/graph/simple"
-	"gonum.org/v1/gonum/graph/topo"
-	"gonum.org/v1/gonum/graph/traverse"
-	"gonum.org/v1/gonum/stat"
 
 	"github.com/src-d/identity-matching/external"
 	"github.com/src-d/identity-matching/reporter"
 )
 
-type node struct {
-	Value *Person
-	id    int64
-}
-
-func (g node) ID() int64 {
-	return g.id
-}
-
 // Int64Slice attaches the methods of Interface to []int64, sorting in increasing order.
 type Int64Slice []int64
 
@@ -37,18 +27,25 @@ func (p Int64Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 func (p Int64Slice) Sort() { sort.Sort(p) }
 
 // addEdgesWithMatcher adds edges by the ground truth from an external matcher.
-func addEdgesWithMatcher(people People, peopleGraph *simple.UndirectedGraph,
+func addEdgesWithMatcher(prog *progress, people People, peopleGraph *IdentityGraph,
 	matcher external.Matcher) (map[string]struct{}, error) {
 	unprocessedEmails := map[string]struct{}{}
 	// Add edges by the groundtruth fetched with external matcher.
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(prog.context())
 	defer cancel()
+	matcher = prefetchCommitMatches(ctx, people, matcher, nil)
 
 	username2extID := make(map[string]node)
 	var username string
 	var err error
 	noMatchWarned := map[string]struct{}{}
-	for index, person := range people {
+	stage := prog.stage("external matching", len(people))
+	defer stage.done()
+	for _, index := range peopleIDs(people) {
+		if err := stage.tick(); err != nil {
+			return unprocessedEmails, err
+		}
+		person := people[index]
 		for _, email := range person.Emails {
 			if matcher.SupportsMatchingByCommit() && person.SampleCommit != nil {
 				username, err = matcher.MatchByCommit(
@@ -56,31 +53,36 @@ func addEdgesWithMatcher(people People, peopleGraph *simple.UndirectedGraph,
 			} else {
 				username, err = matcher.MatchByEmail(ctx, email)
 			}
+			if errors.Is(err, external.ErrUnavailable) {
+				return unprocessedEmails, withKind(ErrSourceUnavailable, err)
+			}
 			if err != nil {
 				if err == external.ErrNoMatches {
 					pstr := person.String()
 					if _, exists := noMatchWarned[pstr]; !exists {
 						noMatchWarned[pstr] = struct{}{}
-						logrus.Warnf("no matches for person %s", pstr)
+						reporter.Warnf("no matches for person %s", pstr)
 					}
 				} else {
-					logrus.Errorf("unexpected error for person %s: %v", person.String(), err)
+					reporter.Errorf("unexpected error for person %s: %v", person.String(), err)
 				}
 				unprocessedEmails[email] = struct{}{}
 			} else {
-				if person.ExternalID != "" && username != person.ExternalID {
+				externalID, exists := person.ExternalIDs[peopleGraph.externalIDs.Provider]
+				if exists && username != externalID {
 					return unprocessedEmails, fmt.Errorf(
 						"person %s has emails with different external ids: %s %s",
-						person.String(), person.ExternalID, username)
+						person.String(), externalID, username)
 				}
-				person.ExternalID = username
+				person.ExternalIDs = person.ExternalIDs.merge(
+					ExternalIDs{peopleGraph.externalIDs.Provider: username})
 				if val, ok := username2extID[username]; ok {
-					err := setEdge(peopleGraph, val, peopleGraph.Node(index).(node))
+					err := peopleGraph.addEvidence(val, peopleGraph.node(index), EvidenceExternalID, username)
 					if err != nil {
 						return unprocessedEmails, nil
 					}
 				} else {
-					username2extID[username] = peopleGraph.Node(int64(index)).(node)
+					username2extID[username] = peopleGraph.node(index)
 				}
 				reporter.Increment("external API emails found")
 			}
@@ -92,6 +94,84 @@ func addEdgesWithMatcher(people People, peopleGraph *simple.UndirectedGraph,
 	return unprocessedEmails, err
 }
 
+// ReduceOptions contains the tunable parameters of ReducePeople.
+// The zero value disables all the optional heuristics.
+type ReduceOptions struct {
+	// MaxIdentities is the limit on the number of unique names and unique emails summed after
+	// which no more identities are merged by name.
+	MaxIdentities int
+	// MatchReorderedNames enables matching the names which consist of the same tokens in
+	// a different order, e.g. "john smith", "smith john" and "smith, john".
+	MatchReorderedNames bool
+	// MaxNameTokenFrequency is the maximum number of distinct names which may share a token
+	// so that the token still counts as rare. Reordered names are matched only if they contain
+	// at least one rare token. 0 means that every token is rare.
+	MaxNameTokenFrequency int
+	// MatchDomainChanges enables matching the identities with the same name and the same email
+	// local part at different corporate domains whose activity periods do not overlap, e.g.
+	// after a job change. It is riskier than the other heuristics, see addDomainChangeEvidence.
+	MatchDomainChanges bool
+	// NoReplyUsers extract the platform accounts from the noreply emails, e.g.
+	// 1234-bob@users.noreply.gitlab.com, and join the identities with the same account by
+	// EvidenceExternalID. nil disables it.
+	NoReplyUsers NoReplyUserPatterns
+	// EmailAliases canonicalizes the emails before matching them, e.g. bob+work@gmail.com and
+	// b.ob@gmail.com both become bob@gmail.com. nil disables the canonicalization.
+	EmailAliases EmailAliasRules
+	// DomainPolicies decide whether the same email at a particular domain implies the same
+	// person. nil applies DomainPolicyDefault to all the domains.
+	DomainPolicies DomainPolicies
+	// EvidenceWeights are the weights of each evidence kind. The missing kinds weigh 1.
+	EvidenceWeights map[EvidenceKind]float64
+	// MinEdgeWeight is the minimum summed evidence weight of an edge to join two identities.
+	MinEdgeWeight float64
+	// MinRepositorySimilarity is the cosine similarity of the repository vectors of two candidate
+	// identities starting from which their edge receives EvidenceRepositories, see
+	// addRepositoryEvidence. 0 disables the repository co-occurrence evidence.
+	MinRepositorySimilarity float64
+	// PairScorer is the learned matcher which scores the candidate identities, see
+	// addClassifierEvidence. nil disables it.
+	PairScorer PairScorer
+	// MinPairProbability is the minimum PairScorer probability to add EvidenceClassifier.
+	MinPairProbability float64
+	// Blockers generate the candidate pairs which PairScorer compares in addition to the pairs
+	// connected by the other evidence, see Blocker. They are ignored without PairScorer.
+	Blockers []Blocker
+	// MaxBlockSize is the maximum number of identities which share a blocking key so that
+	// they are still compared pairwise. 0 means no limit.
+	MaxBlockSize int
+	// Behavior enables the behavioral matching by Person.Activity. nil disables it.
+	Behavior *BehaviorOptions
+	// Style enables the expensive comparison of the coding styles, see StyleOptions.
+	// nil disables it.
+	Style *StyleOptions
+	// ExternalIDs decide how the identities with different external IDs are merged. The zero
+	// value fails the matching on the first conflict.
+	ExternalIDs ExternalIDOptions
+	// ExplainMerges records the evidence of each merge in Person.MergeEvidence.
+	ExplainMerges bool
+	// SampleCommits choose the representative commits of the merged people. The zero value
+	// keeps the earliest commit.
+	SampleCommits SampleCommitOptions
+	// Decisions are the verdicts of the reviewers which force or forbid the merges of
+	// the identity pairs, see ReviewDecisions. nil disables them.
+	Decisions ReviewDecisions
+	// Constraints are the hard must-link and cannot-link constraints which are applied after
+	// all the evidence, see IdentityGraph.ConstraintConflicts. nil disables them.
+	Constraints Constraints
+	// MaxClusterEmails and MaxClusterNames cap the numbers of the distinct emails and names of
+	// a person: the merges beyond them are refused and recorded for the review, see
+	// IdentityGraph.OverMerges. 0 disables the limit.
+	MaxClusterEmails int
+	MaxClusterNames  int
+	// Workers is the number of goroutines which compute the matching keys and match the shards
+	// of the people by email, see matchingKeys and matchEmailShards. 0 and 1 do it in
+	// the calling goroutine.
+	Workers int
+	// Progress receives the progress of the matching stages. May be nil.
+	Progress ProgressReporter
+}
+
 // ReducePeople merges the identities together by following the fixed set of rules.
 // 1. Run the external matching, if available.
 // 2. Run the series of heuristics on those items which were left untouched in the list (everything
@@ -99,25 +179,45 @@ func addEdgesWithMatcher(people People, peopleGraph *simple.UndirectedGraph,
 //
 // The heuristics are:
 // TODO(vmarkovtsev): describe the current approach
-func ReducePeople(people People, matcher external.Matcher, blacklist Blacklist,
-	maxIdentities int) error {
-	peopleGraph := simple.NewUndirectedGraph()
-	for index, person := range people {
-		peopleGraph.AddNode(node{person, index})
+//
+// ReducePeople is a shortcut for BuildIdentityGraph followed by IdentityGraph.Reduce.
+func ReducePeople(ctx context.Context, people People, matcher external.Matcher,
+	blacklist Blacklist, opts ReduceOptions) error {
+	peopleGraph, err := BuildIdentityGraph(ctx, people, matcher, blacklist, opts)
+	if err != nil {
+		return err
 	}
+	return peopleGraph.Reduce(ctx, people)
+}
 
+// BuildIdentityGraph connects the identities with the evidence found by the external matcher
+// and the heuristics, see ReducePeople. The people are not merged. The building stops early
+// if ctx is cancelled.
+func BuildIdentityGraph(ctx context.Context, people People, matcher external.Matcher,
+	blacklist Blacklist, opts ReduceOptions) (*IdentityGraph, error) {
+	prog := &progress{ctx, opts.Progress}
+	peopleGraph := newIdentityGraph(people, opts.EvidenceWeights, opts.MinEdgeWeight)
+	peopleGraph.explain = opts.ExplainMerges
+	peopleGraph.sampleCommits = opts.SampleCommits
+	peopleGraph.progress = opts.Progress
+	peopleGraph.behavior = opts.Behavior
+	peopleGraph.externalIDs = opts.ExternalIDs
+	approved := peopleGraph.resolveDecisions(opts.Decisions)
 	unmatchedEmails := map[string]struct{}{}
 	var err error
 	if matcher != nil {
-		unmatchedEmails, err = addEdgesWithMatcher(people, peopleGraph, matcher)
+		unmatchedEmails, err = addEdgesWithMatcher(prog, people, peopleGraph, matcher)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	// Add edges by the same unpopular email
-	email2id := make(map[string]node)
-	for index, person := range people {
+	var nameTokenFreqs map[string]int
+	if opts.MatchReorderedNames {
+		nameTokenFreqs = countNameTokens(people)
+	}
+	keys, err := matchingKeys(prog, people, opts.Workers, func(person *Person) personKeys {
+		var result personKeys
 		for _, email := range person.Emails {
 			if matcher != nil {
 				if _, unmatched := unmatchedEmails[email]; !unmatched {
@@ -125,80 +225,169 @@ func ReducePeople(people People, matcher external.Matcher, blacklist Blacklist,
 					continue
 				}
 			}
-			if blacklist.isPopularEmail(email) {
+			if blacklist.isUnmatchableEmail(email) {
+				reporter.Increment("noreply emails found")
+				continue
+			}
+			emailKey := opts.EmailAliases.canonicalEmail(email)
+			if emailKey != email {
+				reporter.Increment("email aliases found")
+			}
+			emailKey, policy := opts.DomainPolicies.resolve(emailKey)
+			if policy != DomainPolicyCorporate && blacklist.isPopularEmail(email) {
 				reporter.Increment("popular emails found")
 				continue
 			}
-			if val, ok := email2id[email]; ok {
-				err = setEdge(peopleGraph, val, peopleGraph.Node(index).(node))
-				if err != nil {
-					return err
-				}
-			} else {
-				email2id[email] = peopleGraph.Node(index).(node)
+			result.emails = append(result.emails, emailMatchKey{emailKey, policy})
+		}
+		for _, name := range person.NamesWithRepos {
+			if blacklist.isPopularName(name.String()) {
+				reporter.Increment("popular names found")
+				continue
+			}
+			nameKey := name.String()
+			if opts.MatchReorderedNames {
+				nameKey = reorderedNameKey(name, nameTokenFreqs, opts.MaxNameTokenFrequency)
 			}
+			result.names = append(result.names, nameKey)
 		}
+		return result
+	})
+	if err != nil {
+		return nil, err
 	}
-	reporter.Commit("people matched by email", len(email2id))
 
-	// Add edges by the same unpopular name
-	name2id := make(map[string]map[string][]node)
 	// We need to sort keys because the algorithm is order dependent
-	keys := make([]int64, 0, len(people))
-	for k := range people {
-		keys = append(keys, k)
+	ids := peopleIDs(people)
+
+	if opts.Style != nil {
+		peopleGraph.styles, err = fetchStyles(prog, people, borderlineIdentities(ids, keys), opts.Style)
+		if err != nil {
+			return nil, err
+		}
+		peopleGraph.style = opts.Style
 	}
-	Int64Slice(keys).Sort()
-	for _, index := range keys {
-		myNode := peopleGraph.Node(index).(node)
-		for _, name := range myNode.Value.NamesWithRepos {
-			if blacklist.isPopularName(name.String()) {
-				reporter.Increment("popular names found")
+
+	// Add edges by the same unpopular email; the shards are matched concurrently and the edges
+	// are added in the order of the sequential matching
+	emailEdges, emailKeys, err := matchEmailShards(prog, people, ids, keys, opts.Workers)
+	if err != nil {
+		return nil, err
+	}
+	for _, edge := range emailEdges {
+		err = peopleGraph.addEvidence(
+			peopleGraph.node(edge.from), peopleGraph.node(edge.to), EvidenceEmail, edge.key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	reporter.Commit("people matched by email", emailKeys)
+
+	// Add edges by the same GPG signing key
+	key2id := make(map[string]node)
+	for _, index := range ids {
+		myNode := peopleGraph.node(index)
+		for _, key := range people[index].SigningKeys {
+			if anchor, exists := key2id[key]; exists {
+				if err := peopleGraph.addEvidence(anchor, myNode, EvidenceSigningKey, key); err != nil {
+					return nil, err
+				}
 				continue
 			}
+			key2id[key] = myNode
+		}
+	}
+	reporter.Commit("people matched by signing key", len(key2id))
+
+	// Add edges by the same account in the noreply emails
+	account2id := make(map[string]node)
+	for _, index := range ids {
+		myNode := peopleGraph.node(index)
+		for _, email := range people[index].Emails {
+			provider, user, ok := opts.NoReplyUsers.User(email)
+			if !ok {
+				continue
+			}
+			account := ExternalAccount{Provider: provider, ID: user}.String()
+			if anchor, exists := account2id[account]; exists {
+				if err := peopleGraph.addEvidence(anchor, myNode, EvidenceExternalID, account); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			account2id[account] = myNode
+		}
+	}
+	reporter.Commit("people matched by noreply account", len(account2id))
+
+	// Add edges by the same unpopular name; the edges depend on the components joined so far,
+	// so the names are matched sequentially
+	name2id := make(map[string]map[string][]node)
+	stage := prog.stage("matching by name", len(ids))
+	for _, index := range ids {
+		if err := stage.tick(); err != nil {
+			return nil, err
+		}
+		myNode := peopleGraph.node(index)
+		for _, nameKey := range keys[index].names {
 			for { // this for is to exit with break from the block when required
-				sameNameIDNodes, exists := name2id[name.String()]
+				sameNameIDNodes, exists := name2id[nameKey]
 				if exists {
-					if sameNameAndExternalIDNodes, exists := sameNameIDNodes[myNode.Value.ExternalID]; exists {
+					if sameNameAndExternalIDNodes, exists := sameNameIDNodes[myNode.Value.ExternalIDs.String()]; exists {
 						for _, connectedNode := range sameNameAndExternalIDNodes {
-							if !passIdentitiesLimit(peopleGraph, maxIdentities, myNode, connectedNode) {
+							if !passIdentitiesLimit(peopleGraph.graph, opts.MaxIdentities, myNode, connectedNode) {
 								continue
 							}
-							err = setEdge(peopleGraph, connectedNode, myNode)
+							err = peopleGraph.addEvidence(connectedNode, myNode, EvidenceName, nameKey)
 							if err != nil {
-								return err
+								return nil, err
 							}
 						}
 						break
 					}
 				} else {
 					sameNameIDNodes = map[string][]node{}
-					name2id[name.String()] = sameNameIDNodes
+					name2id[nameKey] = sameNameIDNodes
 				}
-				sameNameIDNodes[myNode.Value.ExternalID] = append(sameNameIDNodes[myNode.Value.ExternalID], myNode)
+				externalIDs := myNode.Value.ExternalIDs.String()
+				sameNameIDNodes[externalIDs] = append(sameNameIDNodes[externalIDs], myNode)
 				break
 			}
 		}
 	}
 
+	stage.done()
+
 	// Merge names with only one found external id
-	for _, externalIDs := range name2id {
+	nameKeys := make([]string, 0, len(name2id))
+	for nameKey := range name2id {
+		nameKeys = append(nameKeys, nameKey)
+	}
+	sort.Strings(nameKeys)
+	for _, nameKey := range nameKeys {
+		externalIDs := name2id[nameKey]
 		if len(externalIDs) == 2 { // one should be empty => merge them
 			toMerge := false
 			var connected []node
-			for externalID, nodes := range externalIDs {
+			sortedExternalIDs := make([]string, 0, len(externalIDs))
+			for externalID := range externalIDs {
+				sortedExternalIDs = append(sortedExternalIDs, externalID)
+			}
+			// the nodes without the external id go first
+			sort.Strings(sortedExternalIDs)
+			for _, externalID := range sortedExternalIDs {
 				if externalID == "" {
 					toMerge = true
 				}
-				connected = append(connected, nodes...)
+				connected = append(connected, externalIDs[externalID]...)
 			}
 			if toMerge {
 				for x, edgeX := range connected {
 					for _, edgeY := range connected[x+1:] {
-						if !passIdentitiesLimit(peopleGraph, maxIdentities, edgeX, edgeY) {
+						if !passIdentitiesLimit(peopleGraph.graph, opts.MaxIdentities, edgeX, edgeY) {
 							continue
 						}
-						err = setEdge(peopleGraph, edgeX, edgeY)
+						err = peopleGraph.addEvidence(edgeX, edgeY, EvidenceName, nameKey)
 						// err can occur here and it is fine.
 					}
 				}
@@ -208,38 +397,117 @@ func ReducePeople(people People, matcher external.Matcher, blacklist Blacklist,
 
 	reporter.Commit("people matched by name", len(name2id))
 
-	var componentsSize []float64
-	for _, component := range topo.ConnectedComponents(peopleGraph) {
-		var toMerge []int64
-		for _, node := range component {
-			toMerge = append(toMerge, node.ID())
+	if opts.MatchDomainChanges {
+		addDomainChangeEvidence(peopleGraph, people, ids, blacklist, opts)
+	}
+
+	if opts.Behavior != nil {
+		addActivityEvidence(peopleGraph, opts)
+	}
+	if opts.Style != nil {
+		addStyleEvidence(peopleGraph, opts)
+	}
+	if opts.MinRepositorySimilarity > 0 {
+		addRepositoryEvidence(peopleGraph, people, opts)
+	}
+	if opts.PairScorer != nil {
+		if len(opts.Blockers) > 0 {
+			addBlockedCandidates(peopleGraph, people, ids, opts)
+		}
+		if err := addClassifierEvidence(peopleGraph, people, opts); err != nil {
+			return nil, err
+		}
+	}
+	for _, pair := range approved {
+		if err := peopleGraph.approve(pair[0], pair[1]); err != nil {
+			// the identities have different external ids
+			reporter.Increment("approved merges with different external ids")
+		}
+	}
+	peopleGraph.conflicts = peopleGraph.applyConstraints(opts.Constraints)
+	peopleGraph.overMerges = peopleGraph.applyClusterLimits(opts.MaxClusterEmails, opts.MaxClusterNames)
+	return peopleGraph, nil
+}
+
+// addActivityEvidence raises the confidence of the existing edges between the identities with
+// similar activities, see BehaviorOptions.MinSimilarity.
+func addActivityEvidence(peopleGraph *IdentityGraph, opts ReduceOptions) {
+	matched := 0
+	for _, edge := range peopleGraph.Edges() {
+		node1, node2 := peopleGraph.node(edge.From), peopleGraph.node(edge.To)
+		similarity, compared := opts.Behavior.compare(node1.Value.Activity, node2.Value.Activity)
+		if !compared || similarity < opts.Behavior.MinSimilarity {
+			continue
+		}
+		if !edge.Active && !passIdentitiesLimit(peopleGraph.graph, opts.MaxIdentities, node1, node2) {
+			continue
 		}
-		componentsSize = append(componentsSize, float64(len(toMerge)))
-		_, err := people.Merge(toMerge...)
+		err := peopleGraph.addEvidence(node1, node2, EvidenceActivity, fmt.Sprintf("%.2f", similarity))
 		if err != nil {
-			return err
+			// the identities have different external ids
+			continue
 		}
+		matched++
 	}
-	mean, std := stat.MeanStdDev(componentsSize, nil)
-	if mean != mean {
-		mean = 0
-	}
-	if std != std {
-		std = 0
+	reporter.Commit("people matched by activity", matched)
+}
+
+// splitNameTokens splits the name into words, treating commas as separators so that
+// "smith, john" yields the same tokens as "john smith".
+func splitNameTokens(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+}
+
+// countNameTokens calculates how many distinct names contain each name token.
+func countNameTokens(people People) map[string]int {
+	seen := map[string]struct{}{}
+	freqs := map[string]int{}
+	for _, person := range people {
+		for _, name := range person.NamesWithRepos {
+			if _, exists := seen[name.Name]; exists {
+				continue
+			}
+			seen[name.Name] = struct{}{}
+			for _, token := range unique(splitNameTokens(name.Name)) {
+				freqs[token]++
+			}
+		}
 	}
-	reporter.Commit("connected component size mean", mean)
-	reporter.Commit("connected component size std", std)
-	reporter.Commit("connected component size max", floats.Max(componentsSize))
-	reporter.Commit("people after reduce", len(people))
+	return freqs
+}
 
-	return nil
+// reorderedNameKey returns the key which is the same for all the permutations of the name tokens.
+// If all the tokens are shared by more than maxTokenFreq distinct names, the original name
+// is returned so that only the exact matches are allowed.
+func reorderedNameKey(name NameWithRepo, tokenFreqs map[string]int, maxTokenFreq int) string {
+	tokens := splitNameTokens(name.Name)
+	if len(tokens) < 2 {
+		return name.String()
+	}
+	if maxTokenFreq > 0 {
+		rare := false
+		for _, token := range tokens {
+			if tokenFreqs[token] <= maxTokenFreq {
+				rare = true
+				break
+			}
+		}
+		if !rare {
+			reporter.Increment("reordered names with common tokens")
+			return name.String()
+		}
+	}
+	sort.Strings(tokens)
+	return NameWithRepo{strings.Join(tokens, " "), name.Repo}.String()
 }
 
 func passIdentitiesLimit(graph *simple.UndirectedGraph, maxIdentities int, node1, node2 node) bool {
 	n1Emails, n1Names := componentUniqueEmailsAndNames(graph, node1)
 	n2Emails, n2Names := componentUniqueEmailsAndNames(graph, node2)
 	if n1Emails+n1Names >= maxIdentities || n2Names+n2Emails >= maxIdentities {
-		logrus.Debugf(
+		reporter.Debugf(
 			"above the identities limit: %s (%d emails, %d names) and %s (%d emails, %d names)",
 			node1.Value.String(), n1Emails, n1Names, node2.Value.String(), n2Emails, n2Names)
 		return false
@@ -247,69 +515,47 @@ func passIdentitiesLimit(graph *simple.UndirectedGraph, maxIdentities int, node1
 	return true
 }
 
-// setEdge propagates ExternalID when you connect two components
-func setEdge(graph *simple.UndirectedGraph, node1, node2 node) error {
-	externalID1 := node1.Value.ExternalID
-	externalID2 := node2.Value.ExternalID
-	if externalID1 != "" && externalID2 != "" && externalID1 != externalID2 {
-		return fmt.Errorf(
-			"cannot set edge between nodes with different ExternalIDs: %s %s",
-			externalID1, externalID2)
-	}
-	var nodeToFix node
-	newExternalID := ""
-	if externalID1 == "" && externalID2 != "" {
-		newExternalID = externalID2
-		nodeToFix = node1
-	} else if externalID1 != "" && externalID2 == "" {
-		newExternalID = externalID1
-		nodeToFix = node2
-	}
-	if newExternalID != "" {
-		var w traverse.DepthFirst
-		w.Walk(graph, nodeToFix, func(sn simplegraph.Node) bool {
-			n := sn.(node)
-			if n.Value.ExternalID != "" && n.Value.ExternalID != newExternalID {
-				panic(fmt.Errorf(
-					"cannot set edge between components with different ExternalIDs: |%s| |%s|",
-					newExternalID, n.Value.ExternalID))
-			}
-			n.Value.ExternalID = newExternalID
-			return false
-		})
-	}
+// PrimaryStrategy chooses the primary name and email of each person, see SetPrimaryValues.
+type PrimaryStrategy string
 
-	graph.SetEdge(graph.NewEdge(node1, node2))
-	reporter.Increment("graph edges")
-	return nil
-}
+const (
+	// PrimaryFrequent chooses the most frequent value in the recent period if the person has
+	// at least PrimaryOptions.MinRecentCount commits in that period, otherwise the most frequent
+	// value through all the time.
+	PrimaryFrequent PrimaryStrategy = "frequent"
+	// PrimaryRecent chooses the value of the latest commit.
+	PrimaryRecent PrimaryStrategy = "recent"
+	// PrimaryCorporate chooses the email like PrimaryFrequent but prefers the corporate domains
+	// to the other domains and the other domains to the freemail ones, see DomainPolicy.
+	// The names are chosen like PrimaryFrequent.
+	PrimaryCorporate PrimaryStrategy = "corporate"
+)
 
-// componentUniqueEmailsAndNames calculates the number of unique emails and names in the component
-// with n node inside
-func componentUniqueEmailsAndNames(graph *simple.UndirectedGraph, n simplegraph.Node) (int, int) {
-	emails := map[string]struct{}{}
-	names := map[string]struct{}{}
-	var w traverse.DepthFirst
-	w.Walk(graph, n, func(sn simplegraph.Node) bool {
-		for _, email := range sn.(node).Value.Emails {
-			emails[email] = struct{}{}
-		}
-		for _, name := range sn.(node).Value.NamesWithRepos {
-			names[name.String()] = struct{}{}
-		}
-		return false
-	})
-	return len(emails), len(names)
+// PrimaryStrategies lists the supported values of PrimaryOptions.Strategy.
+var PrimaryStrategies = []PrimaryStrategy{PrimaryFrequent, PrimaryRecent, PrimaryCorporate}
+
+// PrimaryOptions configure SetPrimaryValues.
+type PrimaryOptions struct {
+	// Strategy is PrimaryFrequent if empty.
+	Strategy PrimaryStrategy
+	// MinRecentCount is the number of the recent commits which PrimaryFrequent requires to use
+	// the recent stats.
+	MinRecentCount int
+	// DomainPolicies tell the corporate domains from the freemail ones for PrimaryCorporate.
+	// nil means NewDomainPolicies().
+	DomainPolicies DomainPolicies
 }
 
 func setPrimaryValue(people People, freqs map[string]*Frequency, getter func(*Person) []string,
-	setter func(*Person, string), minRecentCount int) {
+	setter func(*Person, string), opts PrimaryOptions) {
 	for _, p := range people {
 		recentMaxFreq := 0
 		totalMaxFreq := 0
 		sumRecentCount := 0
 		recentPrimaryValue := ""
 		totalPrimaryValue := ""
+		var lastTime time.Time
+		lastPrimaryValue := ""
 		for _, value := range getter(p) {
 			if freq, ok := freqs[value]; ok {
 				sumRecentCount += freq.Recent
@@ -321,11 +567,17 @@ func setPrimaryValue(people People, freqs map[string]*Frequency, getter func(*Pe
 					totalMaxFreq = freq.Total
 					totalPrimaryValue = value
 				}
+				if lastPrimaryValue == "" || freq.Last.After(lastTime) {
+					lastTime = freq.Last
+					lastPrimaryValue = value
+				}
 			} else {
 				logrus.Panicf("freqs does not contain %s key", value)
 			}
 		}
-		if sumRecentCount >= minRecentCount {
+		if opts.Strategy == PrimaryRecent && !lastTime.IsZero() {
+			setter(p, lastPrimaryValue)
+		} else if sumRecentCount >= opts.MinRecentCount {
 			setter(p, recentPrimaryValue)
 		} else {
 			setter(p, totalPrimaryValue)
@@ -333,19 +585,81 @@ func setPrimaryValue(people People, freqs map[string]*Frequency, getter func(*Pe
 	}
 }
 
-// SetPrimaryValues sets people primary name and email to the most frequent name and email of
-// the person's identity. Stats for the fixed recent period of time are used if there are at least
-// minRecentCount commits made by the person's identity in that period. Otherwise the stats
-// for all the time are used.
+// preferCorporateEmails returns the emails with the most preferred domain policy: corporate,
+// then default, then freemail.
+func preferCorporateEmails(emails []string, policies DomainPolicies) []string {
+	rank := func(email string) int {
+		switch _, policy := policies.resolve(email); policy {
+		case DomainPolicyCorporate:
+			return 0
+		case DomainPolicyFreemail:
+			return 2
+		default:
+			return 1
+		}
+	}
+	var result []string
+	best := 3
+	for _, email := range emails {
+		if r := rank(email); r < best {
+			best = r
+			result = []string{email}
+		} else if r == best {
+			result = append(result, email)
+		}
+	}
+	return result
+}
+
+// SetPreferredEmails sets the primary email of each person with the external ID of the provider
+// to the preferred email known to the external matcher of that provider, provided that
+// the person has that email. It does nothing if the matcher does not implement
+// external.PreferredEmailMatcher.
+func SetPreferredEmails(people People, matcher external.Matcher, provider string) {
+	preferred, ok := matcher.(external.PreferredEmailMatcher)
+	if !ok {
+		return
+	}
+	for _, person := range people {
+		externalID, exists := person.ExternalIDs[provider]
+		if !exists {
+			continue
+		}
+		email, exists := preferred.PreferredEmail(externalID)
+		if !exists {
+			continue
+		}
+		email = strings.ToLower(email)
+		for _, personEmail := range person.Emails {
+			if personEmail == email {
+				person.PrimaryEmail = email
+				reporter.Increment("preferred emails")
+				break
+			}
+		}
+	}
+}
+
+// SetPrimaryValues sets people primary name and email to the name and email of the person's
+// identity chosen by the strategy, see PrimaryStrategy. PrimaryRecent falls back to
+// PrimaryFrequent if the commit times are unknown.
 func SetPrimaryValues(people People, nameFreqs, emailFreqs map[string]*Frequency,
-	minRecentCount int) {
+	opts PrimaryOptions) {
 	setPrimaryValue(people, nameFreqs, func(p *Person) []string {
 		names := make([]string, len(p.NamesWithRepos))
 		for i, n := range p.NamesWithRepos {
 			names[i] = n.Name
 		}
 		return names
-	}, func(p *Person, name string) { p.PrimaryName = name }, minRecentCount)
-	setPrimaryValue(people, emailFreqs, func(p *Person) []string { return p.Emails },
-		func(p *Person, email string) { p.PrimaryEmail = email }, minRecentCount)
+	}, func(p *Person, name string) { p.PrimaryName = name }, opts)
+	emails := func(p *Person) []string { return p.Emails }
+	if opts.Strategy == PrimaryCorporate {
+		policies := opts.DomainPolicies
+		if policies == nil {
+			policies = NewDomainPolicies()
+		}
+		emails = func(p *Person) []string { return preferCorporateEmails(p.Emails, policies) }
+	}
+	setPrimaryValue(people, emailFreqs, emails,
+		func(p *Person, email string) { p.PrimaryEmail = email }, opts)
 }
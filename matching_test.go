@@ -2,12 +2,13 @@ package idmatch
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
-	"gonum.org/v1/gonum/graph/simple"
 
 	"github.com/src-d/identity-matching/external"
 )
@@ -15,7 +16,7 @@ import (
 var githubTestToken = os.Getenv("GITHUB_TEST_TOKEN")
 
 func TestReducePeople(t *testing.T) {
-	commit := &Commit{"xxx", "repo"}
+	commit := &Commit{Hash: "xxx", Repo: "repo"}
 	var people = People{
 		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"Bob 1", ""}}, Emails: []string{"Bob@google.com"}},
 		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"Bob 2", ""}}, Emails: []string{"Bob@google.com"}},
@@ -38,12 +39,15 @@ func TestReducePeople(t *testing.T) {
 		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"Alice", ""}}, Emails: []string{"alice@google.com", "popular@google.com"}},
 		6: {ID: 6, NamesWithRepos: []NameWithRepo{{"popular", ""}}, Emails: []string{"email@google.com"}},
 	}
+	for _, p := range reducedPeople {
+		p.SampleCommit = commit
+	}
 
 	blacklist := newTestBlacklist(t)
 
-	err := ReducePeople(people, nil, blacklist, 100)
+	err := ReducePeople(context.Background(), people, nil, blacklist, ReduceOptions{MaxIdentities: 100})
 	require.Equal(t, err, nil)
-	require.Equal(t, people, reducedPeople)
+	require.Equal(t, people, fillSampleCommits(reducedPeople))
 }
 
 func TestReducePeopleMaxIdentities(t *testing.T) {
@@ -78,7 +82,7 @@ func TestReducePeopleMaxIdentities(t *testing.T) {
 
 	blacklist := newTestBlacklist(t)
 
-	err := ReducePeople(people, nil, blacklist, 4)
+	err := ReducePeople(context.Background(), people, nil, blacklist, ReduceOptions{MaxIdentities: 4})
 	require.Equal(t, err, nil)
 	require.Equal(t, reducedPeople, people)
 }
@@ -108,18 +112,19 @@ func TestReducePeopleExternalMatching(t *testing.T) {
 		1: {ID: 1, NamesWithRepos: []NameWithRepo{
 			{"Máximo", ""},
 			{"Máximo Cuadros", ""}},
-			Emails:     []string{"mcuadros@gmail.com"},
-			ExternalID: "mcuadros"},
+			Emails:      []string{"mcuadros@gmail.com"},
+			ExternalIDs: ExternalIDs{"github": "mcuadros"}},
 		3: {ID: 3,
 			NamesWithRepos: []NameWithRepo{{"Konstantin Slavnov", ""}},
 			Emails:         []string{"kslavnov@gmail.com"},
-			ExternalID:     "zurk"},
+			ExternalIDs:    ExternalIDs{"github": "zurk"}},
 	}
 
 	blacklist := newTestBlacklist(t)
 	matcher, _ := external.NewGitHubMatcher("", githubTestToken)
 
-	err := ReducePeople(people, matcher, blacklist, 100)
+	err := ReducePeople(context.Background(), people, matcher, blacklist, ReduceOptions{
+		MaxIdentities: 100, ExternalIDs: ExternalIDOptions{Provider: "github"}})
 
 	require.Equal(t, err, nil)
 	require.Equal(t, people, reducedPeople)
@@ -157,13 +162,13 @@ func TestReducePeopleBothMatching(t *testing.T) {
 			ID:             0x1,
 			NamesWithRepos: []NameWithRepo{{Name: "Máximo", Repo: ""}, {Name: "Máximo Cuadros", Repo: ""}},
 			Emails:         []string{"mcuadros@gmail.com"},
-			ExternalID:     "mcuadros",
+			ExternalIDs:    ExternalIDs{"github": "mcuadros"},
 		},
 		3: {
 			ID:             0x3,
 			NamesWithRepos: []NameWithRepo{{Name: "Konstantin Slavnov", Repo: ""}},
 			Emails:         []string{"kslavnov@ggmail.com", "kslavnov@gmail.com"},
-			ExternalID:     "zurk",
+			ExternalIDs:    ExternalIDs{"github": "zurk"},
 		},
 		6: {
 			ID: 0x6,
@@ -184,7 +189,8 @@ func TestReducePeopleBothMatching(t *testing.T) {
 	blacklist := newTestBlacklist(t)
 	matcher, _ := external.NewGitHubMatcher("", githubTestToken)
 
-	err := ReducePeople(people, matcher, blacklist, 100)
+	err := ReducePeople(context.Background(), people, matcher, blacklist, ReduceOptions{
+		MaxIdentities: 100, ExternalIDs: ExternalIDOptions{Provider: "github"}})
 
 	require.Equal(t, err, nil)
 	require.Equal(t, people, reducedPeople)
@@ -222,16 +228,16 @@ func TestReducePeopleBothMatchingDifferentExternalIdsNoMerge(t *testing.T) {
 			ID:             0x1,
 			NamesWithRepos: []NameWithRepo{{Name: "Máximo", Repo: ""}, {Name: "Máximo Cuadros", Repo: ""}},
 			Emails:         []string{"mcuadros@gmail.com"},
-			ExternalID:     "mcuadros",
+			ExternalIDs:    ExternalIDs{"github": "mcuadros"},
 		},
 		3: {ID: 3,
 			NamesWithRepos: []NameWithRepo{{"Konstantin Slavnov", ""}},
 			Emails:         []string{"kslavnov@gmail.com"},
-			ExternalID:     "zurk"},
+			ExternalIDs:    ExternalIDs{"github": "zurk"}},
 		4: {ID: 4,
 			NamesWithRepos: []NameWithRepo{{"Konstantin Slavnov", ""}},
 			Emails:         []string{"vadim@sourced.tech"},
-			ExternalID:     "vmarkovtsev"},
+			ExternalIDs:    ExternalIDs{"github": "vmarkovtsev"}},
 		5: {ID: 5,
 			NamesWithRepos: []NameWithRepo{{"Konstantin Slavnov", ""}},
 			Emails:         []string{"kslavnov@ggmail.com"}},
@@ -254,7 +260,8 @@ func TestReducePeopleBothMatchingDifferentExternalIdsNoMerge(t *testing.T) {
 	blacklist := newTestBlacklist(t)
 	matcher, _ := external.NewGitHubMatcher("", githubTestToken)
 
-	err := ReducePeople(people, matcher, blacklist, 100)
+	err := ReducePeople(context.Background(), people, matcher, blacklist, ReduceOptions{
+		MaxIdentities: 100, ExternalIDs: ExternalIDOptions{Provider: "github"}})
 
 	require.Equal(t, err, nil)
 	require.Equal(t, people, reducedPeople)
@@ -296,24 +303,44 @@ func TestReducePeopleSameNameDifferentExternalIds(t *testing.T) {
 		1: {ID: 1,
 			NamesWithRepos: []NameWithRepo{{"Bob", ""}, {"Bob 2", ""}},
 			Emails:         []string{"Bob@google.com"},
-			ExternalID:     "bob_username"},
+			ExternalIDs:    ExternalIDs{"github": "bob_username"}},
 		2: {ID: 2,
 			NamesWithRepos: []NameWithRepo{{"Bob", ""}},
 			Emails:         []string{"Bob2@google.com"},
-			ExternalID:     "not_bob_username"},
+			ExternalIDs:    ExternalIDs{"github": "not_bob_username"}},
 		3: {ID: 3,
 			NamesWithRepos: []NameWithRepo{{"Alice", ""}},
 			Emails:         []string{"alice@google.com"},
-			ExternalID:     "alice_username"},
+			ExternalIDs:    ExternalIDs{"github": "alice_username"}},
 	}
 
 	blacklist := newTestBlacklist(t)
 
-	err := ReducePeople(people, TestMatcher{}, blacklist, 100)
+	err := ReducePeople(context.Background(), people, TestMatcher{}, blacklist,
+		ReduceOptions{MaxIdentities: 100, ExternalIDs: ExternalIDOptions{Provider: "github"}})
 	require.Equal(t, err, nil)
 	require.Equal(t, people, reducedPeople)
 }
 
+type testUnavailableMatcher struct {
+	TestMatcher
+}
+
+func (m testUnavailableMatcher) MatchByEmail(ctx context.Context, email string) (string, error) {
+	return "", fmt.Errorf("%w: HTTP 502", external.ErrUnavailable)
+}
+
+func TestReducePeopleMatcherUnavailable(t *testing.T) {
+	req := require.New(t)
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"Bob", ""}}, Emails: []string{"Bob@google.com"}},
+	}
+	err := ReducePeople(context.Background(), people, testUnavailableMatcher{}, newTestBlacklist(t),
+		ReduceOptions{MaxIdentities: 100, ExternalIDs: ExternalIDOptions{Provider: "github"}})
+	req.True(errors.Is(err, ErrSourceUnavailable))
+	req.True(errors.Is(err, external.ErrUnavailable))
+}
+
 func TestSetPrimaryValue(t *testing.T) {
 	people := People{
 		1: {ID: 1, NamesWithRepos: []NameWithRepo{
@@ -328,13 +355,13 @@ func TestSetPrimaryValue(t *testing.T) {
 			Emails: []string{"email@google.com"}},
 	}
 	emailFreqs := map[string]*Frequency{
-		"Bob@google.com":   {5, 8},
-		"bobby@google.com": {2, 4},
-		"12345@gmail.com":  {1, 1},
-		"email@google.com": {2, 4},
-		"alice@google.com": {1, 5},
-		"al@google.com":    {3, 3},
-		"admin@google.com": {6, 6},
+		"Bob@google.com":   {Recent: 5, Total: 8},
+		"bobby@google.com": {Recent: 2, Total: 4},
+		"12345@gmail.com":  {Recent: 1, Total: 1},
+		"email@google.com": {Recent: 2, Total: 4},
+		"alice@google.com": {Recent: 1, Total: 5},
+		"al@google.com":    {Recent: 3, Total: 3},
+		"admin@google.com": {Recent: 6, Total: 6},
 	}
 	expected := People{
 		1: {ID: 1, NamesWithRepos: []NameWithRepo{
@@ -352,7 +379,7 @@ func TestSetPrimaryValue(t *testing.T) {
 			PrimaryEmail: "email@google.com"},
 	}
 	setPrimaryValue(people, emailFreqs, func(p *Person) []string { return p.Emails },
-		func(p *Person, email string) { p.PrimaryEmail = email }, 2)
+		func(p *Person, email string) { p.PrimaryEmail = email }, PrimaryOptions{MinRecentCount: 2})
 	require.Equal(t, expected, people)
 }
 
@@ -370,22 +397,22 @@ func TestSetPrimaryValues(t *testing.T) {
 			Emails: []string{"email@google.com"}},
 	}
 	nameFreqs := map[string]*Frequency{
-		"Bob":     {5, 10},
-		"Bob 1":   {1, 3},
-		"Bob 2":   {1, 1},
-		"popular": {4, 20},
-		"Alice":   {3, 4},
-		"Alice 1": {1, 5},
-		"admin":   {3, 5},
+		"Bob":     {Recent: 5, Total: 10},
+		"Bob 1":   {Recent: 1, Total: 3},
+		"Bob 2":   {Recent: 1, Total: 1},
+		"popular": {Recent: 4, Total: 20},
+		"Alice":   {Recent: 3, Total: 4},
+		"Alice 1": {Recent: 1, Total: 5},
+		"admin":   {Recent: 3, Total: 5},
 	}
 	emailFreqs := map[string]*Frequency{
-		"Bob@google.com":   {5, 8},
-		"bobby@google.com": {2, 4},
-		"12345@gmail.com":  {1, 1},
-		"email@google.com": {2, 4},
-		"alice@google.com": {1, 5},
-		"al@google.com":    {3, 3},
-		"admin@google.com": {6, 6},
+		"Bob@google.com":   {Recent: 5, Total: 8},
+		"bobby@google.com": {Recent: 2, Total: 4},
+		"12345@gmail.com":  {Recent: 1, Total: 1},
+		"email@google.com": {Recent: 2, Total: 4},
+		"alice@google.com": {Recent: 1, Total: 5},
+		"al@google.com":    {Recent: 3, Total: 3},
+		"admin@google.com": {Recent: 6, Total: 6},
 	}
 	expected := People{
 		1: {ID: 1, NamesWithRepos: []NameWithRepo{
@@ -402,10 +429,91 @@ func TestSetPrimaryValues(t *testing.T) {
 			Emails:      []string{"email@google.com"},
 			PrimaryName: "popular", PrimaryEmail: "email@google.com"},
 	}
-	SetPrimaryValues(people, nameFreqs, emailFreqs, 5)
+	SetPrimaryValues(people, nameFreqs, emailFreqs, PrimaryOptions{MinRecentCount: 5})
 	require.Equal(t, expected, people)
 }
 
+func TestSetPrimaryValuesStrategies(t *testing.T) {
+	req := require.New(t)
+	newPeople := func() People {
+		return People{
+			1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}, {"bob smith", ""}},
+				Emails: []string{"bob@gmail.com", "bob@google.com", "bob@home.org"}},
+			2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@gmail.com"}},
+		}
+	}
+	now := time.Now()
+	nameFreqs := map[string]*Frequency{
+		"bob":       {Recent: 1, Total: 10, Last: now.AddDate(0, -2, 0)},
+		"bob smith": {Recent: 1, Total: 2, Last: now.AddDate(0, -1, 0)},
+		"alice":     {Recent: 0, Total: 1, Last: time.Time{}},
+	}
+	emailFreqs := map[string]*Frequency{
+		"bob@gmail.com":   {Recent: 1, Total: 10, Last: now.AddDate(0, -1, 0)},
+		"bob@google.com":  {Recent: 0, Total: 1, Last: now.AddDate(-2, 0, 0)},
+		"bob@home.org":    {Recent: 1, Total: 3, Last: now.AddDate(0, -3, 0)},
+		"alice@gmail.com": {Recent: 0, Total: 1, Last: time.Time{}},
+	}
+
+	people := newPeople()
+	SetPrimaryValues(people, nameFreqs, emailFreqs, PrimaryOptions{MinRecentCount: 5})
+	req.Equal("bob", people[1].PrimaryName)
+	req.Equal("bob@gmail.com", people[1].PrimaryEmail)
+
+	people = newPeople()
+	SetPrimaryValues(people, nameFreqs, emailFreqs,
+		PrimaryOptions{Strategy: PrimaryRecent, MinRecentCount: 5})
+	req.Equal("bob smith", people[1].PrimaryName)
+	req.Equal("bob@gmail.com", people[1].PrimaryEmail)
+	req.Equal("alice", people[2].PrimaryName)
+	req.Equal("alice@gmail.com", people[2].PrimaryEmail)
+
+	people = newPeople()
+	SetPrimaryValues(people, nameFreqs, emailFreqs,
+		PrimaryOptions{Strategy: PrimaryCorporate, MinRecentCount: 5})
+	req.Equal("bob", people[1].PrimaryName)
+	req.Equal("bob@home.org", people[1].PrimaryEmail)
+	req.Equal("alice@gmail.com", people[2].PrimaryEmail)
+
+	people = newPeople()
+	SetPrimaryValues(people, nameFreqs, emailFreqs, PrimaryOptions{
+		Strategy:       PrimaryCorporate,
+		MinRecentCount: 5,
+		DomainPolicies: NewDomainPolicies().Merge(DomainPolicies{"google.com": DomainPolicyCorporate}),
+	})
+	req.Equal("bob@google.com", people[1].PrimaryEmail)
+}
+
+type testPreferredEmailMatcher struct {
+	TestMatcher
+}
+
+func (m testPreferredEmailMatcher) PreferredEmail(user string) (string, bool) {
+	emails := map[string]string{
+		"bob_username":   "Bobby@google.com",
+		"alice_username": "alice@gmail.com",
+	}
+	email, exists := emails[user]
+	return email, exists
+}
+
+func TestSetPreferredEmails(t *testing.T) {
+	req := require.New(t)
+	people := People{
+		1: {ID: 1, Emails: []string{"bob@google.com", "bobby@google.com"},
+			ExternalIDs: ExternalIDs{"github": "bob_username"}, PrimaryEmail: "bob@google.com"},
+		2: {ID: 2, Emails: []string{"alice@google.com"},
+			ExternalIDs: ExternalIDs{"github": "alice_username"}, PrimaryEmail: "alice@google.com"},
+		3: {ID: 3, Emails: []string{"eve@google.com"}, PrimaryEmail: "eve@google.com"},
+	}
+	SetPreferredEmails(people, TestMatcher{}, "github")
+	req.Equal("bob@google.com", people[1].PrimaryEmail)
+	SetPreferredEmails(people, testPreferredEmailMatcher{}, "github")
+	req.Equal("bobby@google.com", people[1].PrimaryEmail)
+	req.Equal("alice@google.com", people[2].PrimaryEmail)
+	req.Equal("eve@google.com", people[3].PrimaryEmail)
+}
+
 func TestAddEdgesWithMatcherCommits(t *testing.T) {
 	people := People{}
 	people[1] = &Person{ID: 1, NamesWithRepos: []NameWithRepo{{"Vadim", ""}},
@@ -414,13 +522,113 @@ func TestAddEdgesWithMatcherCommits(t *testing.T) {
 			Repo: "git://github.com/src-d/hercules.git",
 		}}
 	matcher, _ := external.NewGitHubMatcher("", githubTestToken)
-	peopleGraph := simple.NewUndirectedGraph()
-	for index, person := range people {
-		peopleGraph.AddNode(node{person, index})
-	}
-	unprocessedEmails, err := addEdgesWithMatcher(people, peopleGraph, matcher)
+	peopleGraph := newIdentityGraph(people, nil, 0)
+	peopleGraph.externalIDs.Provider = "github"
+	unprocessedEmails, err := addEdgesWithMatcher(nil, people, peopleGraph, matcher)
 	req := require.New(t)
 	req.NoError(err)
 	req.Equal(0, len(unprocessedEmails))
-	req.Equal("vmarkovtsev", people[1].ExternalID)
+	req.Equal("vmarkovtsev", people[1].ExternalIDs["github"])
+}
+
+func TestReducePeopleReorderedNames(t *testing.T) {
+	newPeople := func() People {
+		return People{
+			1: {ID: 1, NamesWithRepos: []NameWithRepo{{"john smith", ""}}, Emails: []string{"js@google.com"}},
+			2: {ID: 2, NamesWithRepos: []NameWithRepo{{"smith, john", ""}}, Emails: []string{"john@gmail.com"}},
+			3: {ID: 3, NamesWithRepos: []NameWithRepo{{"smith john", ""}}, Emails: []string{"smith@gmail.com"}},
+			4: {ID: 4, NamesWithRepos: []NameWithRepo{{"john doe", ""}}, Emails: []string{"doe@gmail.com"}},
+		}
+	}
+	blacklist := newTestBlacklist(t)
+
+	people := newPeople()
+	err := ReducePeople(context.Background(), people, nil, blacklist, ReduceOptions{MaxIdentities: 100})
+	require.NoError(t, err)
+	require.Len(t, people, 4)
+
+	people = newPeople()
+	err = ReducePeople(context.Background(), people, nil, blacklist, ReduceOptions{
+		MaxIdentities: 100, MatchReorderedNames: true})
+	require.NoError(t, err)
+	require.Equal(t, People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"john smith", ""}, {"smith john", ""}, {"smith, john", ""}},
+			Emails: []string{"john@gmail.com", "js@google.com", "smith@gmail.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"john doe", ""}}, Emails: []string{"doe@gmail.com"}},
+	}, people)
+
+	// "john" is used in 4 names and "smith" in 3, both are too common
+	people = newPeople()
+	err = ReducePeople(context.Background(), people, nil, blacklist, ReduceOptions{
+		MaxIdentities: 100, MatchReorderedNames: true, MaxNameTokenFrequency: 2})
+	require.NoError(t, err)
+	require.Len(t, people, 4)
+}
+
+func TestReorderedNameKey(t *testing.T) {
+	freqs := map[string]int{"john": 10, "smith": 2}
+	require.Equal(t, "john smith", reorderedNameKey(NameWithRepo{"smith, john", ""}, freqs, 0))
+	require.Equal(t, "john smith", reorderedNameKey(NameWithRepo{"smith john", ""}, freqs, 5))
+	require.Equal(t, "smith john", reorderedNameKey(NameWithRepo{"smith john", ""}, freqs, 1))
+	require.Equal(t, "{john smith, repo}", reorderedNameKey(NameWithRepo{"smith john", "repo"}, freqs, 0))
+	require.Equal(t, "john", reorderedNameKey(NameWithRepo{"john", ""}, freqs, 0))
+}
+
+func TestReducePeopleEmailAliases(t *testing.T) {
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob+work@gmail.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"robert", ""}}, Emails: []string{"b.ob@gmail.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"bobby", ""}}, Emails: []string{"b.ob@company.com"}},
+	}
+	err := ReducePeople(context.Background(), people, nil, newTestBlacklist(t), ReduceOptions{
+		MaxIdentities: 100, EmailAliases: NewEmailAliasRules()})
+	require.NoError(t, err)
+	require.Equal(t, People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}, {"robert", ""}},
+			Emails: []string{"b.ob@gmail.com", "bob+work@gmail.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"bobby", ""}}, Emails: []string{"b.ob@company.com"}},
+	}, people)
+}
+
+func TestReducePeopleNoReplyEmails(t *testing.T) {
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}},
+			Emails: []string{"123+bob@users.noreply.github.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}},
+			Emails: []string{"123+bob@users.noreply.github.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"eve", ""}}, Emails: []string{"eve@mailinator.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"eve", ""}}, Emails: []string{"eve@mailinator.com"}},
+	}
+	blacklist, err := NewBlacklist()
+	require.NoError(t, err)
+	err = ReducePeople(context.Background(), people, nil, blacklist, ReduceOptions{MaxIdentities: 100})
+	require.NoError(t, err)
+	require.Equal(t, People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}},
+			Emails: []string{"123+bob@users.noreply.github.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}},
+			Emails: []string{"123+bob@users.noreply.github.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"eve", ""}}, Emails: []string{"eve@mailinator.com"}},
+	}, people)
+}
+
+func TestReducePeopleDomainPolicies(t *testing.T) {
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob smith", ""}}, Emails: []string{"test@gmail.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"test@gmail.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"smith", ""}}, Emails: []string{"test@gmail.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"popular", ""}}, Emails: []string{"popular@email.com"}},
+		5: {ID: 5, NamesWithRepos: []NameWithRepo{{"xyz", ""}}, Emails: []string{"popular@eng.email.com"}},
+	}
+	policies := NewDomainPolicies().Merge(DomainPolicies{"email.com": DomainPolicyCorporate})
+	err := ReducePeople(context.Background(), people, nil, newTestBlacklist(t), ReduceOptions{
+		MaxIdentities: 100, DomainPolicies: policies})
+	require.NoError(t, err)
+	require.Equal(t, People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob smith", ""}, {"smith", ""}},
+			Emails: []string{"test@gmail.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"test@gmail.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"popular", ""}, {"xyz", ""}},
+			Emails: []string{"popular@email.com", "popular@eng.email.com"}},
+	}, people)
 }
@@ -0,0 +1,214 @@
+package idmatch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// mboxSource is the signatureSource which reads the From: headers of the mailing list archives
+// matched by a glob pattern. Each archive is either an mbox file or a Maildir directory and plays
+// the role of the repository: the repository identifier is the archive path, prefixed with
+// "<tag>:" if the tag is not empty. Message-Id is the pseudo commit hash and Date is the time.
+type mboxSource struct {
+	pattern string
+	tag     string
+}
+
+func newMboxSource(pattern, tag string) (*mboxSource, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("the mailboxes glob pattern must be specified for the %s source",
+			SourceMbox)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid mailboxes glob pattern %s: %v", pattern, err)
+	}
+	return &mboxSource{pattern: pattern, tag: tag}, nil
+}
+
+func (s *mboxSource) Repositories(ctx context.Context) ([]string, error) {
+	matches, err := filepath.Glob(s.pattern)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(matches))
+	for _, match := range matches {
+		result = append(result, s.repository(filepath.Clean(match)))
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// repository returns the repository identifier of the archive.
+func (s *mboxSource) repository(path string) string {
+	if s.tag == "" {
+		return path
+	}
+	return s.tag + ":" + path
+}
+
+// Signatures reads the headers of every message in the archive. The signatures are deduplicated
+// with signatureAggregator.
+func (s *mboxSource) Signatures(ctx context.Context, repo string) ([]Signature, error) {
+	path := repo
+	if s.tag != "" {
+		path = strings.TrimPrefix(repo, s.tag+":")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	signatures := signatureAggregator{}
+	add := func(header mail.Header) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		from, err := mail.ParseAddress(header.Get("From"))
+		if err != nil {
+			reporter.Increment("skipped mailbox messages")
+			return nil
+		}
+		when, err := header.Date()
+		hash := strings.Trim(strings.TrimSpace(header.Get("Message-Id")), "<>")
+		if err != nil || hash == "" {
+			reporter.Increment("skipped mailbox messages")
+			return nil
+		}
+		signatures.add(Signature{
+			Repo:   repo,
+			Name:   from.Name,
+			Email:  from.Address,
+			Hash:   hash,
+			Time:   when,
+			Source: SourceMbox,
+			Role:   RoleAuthor,
+
+			Activity: newActivity(when),
+		})
+		return nil
+	}
+	if info.IsDir() {
+		err = readMaildirHeaders(path, add)
+	} else {
+		err = readMboxFileHeaders(path, add)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return signatures.signatures(), nil
+}
+
+// readMboxFileHeaders calls fn with the headers of each message in the mbox file.
+func readMboxFileHeaders(path string, fn func(mail.Header) error) (err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	return readMboxHeaders(file, fn)
+}
+
+// readMboxHeaders splits the mbox stream into the messages by the "From " separator lines and
+// calls fn with the headers of each message. The message bodies are skipped.
+func readMboxHeaders(r io.Reader, fn func(mail.Header) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	var header bytes.Buffer
+	inHeader := false
+	flush := func() error {
+		if !inHeader {
+			return nil
+		}
+		inHeader = false
+		header.WriteString("\r\n")
+		msg, err := mail.ReadMessage(&header)
+		header.Reset()
+		if err != nil {
+			reporter.Increment("skipped mailbox messages")
+			return nil
+		}
+		return fn(msg.Header)
+	}
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if bytes.HasPrefix(line, []byte("From ")) {
+			if err := flush(); err != nil {
+				return err
+			}
+			inHeader = true
+			continue
+		}
+		if !inHeader {
+			continue
+		}
+		if len(bytes.TrimRight(line, "\r")) == 0 {
+			if err := flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		header.Write(line)
+		header.WriteString("\r\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// readMaildirHeaders calls fn with the headers of each message in the "cur" and "new"
+// subdirectories of the Maildir.
+func readMaildirHeaders(path string, fn func(mail.Header) error) error {
+	for _, subdir := range []string{"cur", "new"} {
+		files, err := ioutil.ReadDir(filepath.Join(path, subdir))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, info := range files {
+			if info.IsDir() {
+				continue
+			}
+			if err := readMaildirMessageHeader(filepath.Join(path, subdir, info.Name()), fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func readMaildirMessageHeader(path string, fn func(mail.Header) error) (err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	msg, err := mail.ReadMessage(file)
+	if err != nil {
+		reporter.Increment("skipped mailbox messages")
+		return nil
+	}
+	return fn(msg.Header)
+}
@@ -0,0 +1,89 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testMbox = `From alice@google.com Tue Jan  1 00:00:00 2019
+From: Alice <alice@google.com>
+Message-Id: <1@google.com>
+Date: Tue, 1 Jan 2019 00:00:00 +0000
+
+From bob Tue Jan  1 00:00:00 2019
+>From the body which is not a separator.
+From bob@google.com Tue Jan  1 00:00:00 2019
+From: =?UTF-8?Q?Bob_M=C3=BCller?= <bob@google.com>
+Message-Id: <2@google.com>
+Date: Wed, 2 Jan 2019 01:00:00 +0100
+
+body
+From alice@google.com Tue Jan  1 00:00:00 2019
+From: Alice <alice@google.com>
+Message-Id: <3@google.com>
+Date: Thu, 3 Jan 2019 00:00:00 +0000
+
+From eve@google.com Tue Jan  1 00:00:00 2019
+From: Eve <eve@google.com>
+Date: Thu, 3 Jan 2019 00:00:00 +0000
+`
+
+func TestMboxSource(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-mbox")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	mboxPath := filepath.Join(dir, "list.mbox")
+	req.NoError(ioutil.WriteFile(mboxPath, []byte(testMbox), 0666))
+	maildirPath := filepath.Join(dir, "maildir")
+	req.NoError(os.MkdirAll(filepath.Join(maildirPath, "new"), 0777))
+	req.NoError(ioutil.WriteFile(filepath.Join(maildirPath, "new", "1"), []byte(
+		"From: Carol <carol@google.com>\r\nMessage-Id: <4@google.com>\r\n"+
+			"Date: Tue, 1 Jan 2019 00:00:00 +0000\r\n\r\nbody\r\n"), 0666))
+
+	_, err = newMboxSource("", "")
+	req.Error(err)
+	_, err = newMboxSource("[", "")
+	req.Error(err)
+	source, err := newMboxSource(filepath.Join(dir, "*"), "ml")
+	req.NoError(err)
+	repos, err := source.Repositories(context.Background())
+	req.NoError(err)
+	mboxRepo, maildirRepo := "ml:"+mboxPath, "ml:"+maildirPath
+	req.Equal([]string{mboxRepo, maildirRepo}, repos)
+
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	signatures, err := source.Signatures(context.Background(), mboxRepo)
+	req.NoError(err)
+	req.Equal([]Signature{
+		{mboxRepo, "Alice", "alice@google.com", "3@google.com", day.Add(48 * time.Hour), SourceMbox, RoleAuthor, "",
+			testActivity(day, day.Add(48*time.Hour)), nil},
+		{mboxRepo, "Bob Müller", "bob@google.com", "2@google.com", day.Add(24 * time.Hour), SourceMbox, RoleAuthor, "",
+			testActivity(day.Add(24 * time.Hour).In(time.FixedZone("", 3600))), nil},
+	}, signatures)
+	signatures, err = source.Signatures(context.Background(), maildirRepo)
+	req.NoError(err)
+	req.Equal([]Signature{
+		{maildirRepo, "Carol", "carol@google.com", "4@google.com", day, SourceMbox, RoleAuthor, "",
+			testActivity(day), nil},
+	}, signatures)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = source.Signatures(ctx, mboxRepo)
+	req.Equal(context.Canceled, err)
+	_, err = source.Signatures(context.Background(), "ml:"+filepath.Join(dir, "missing"))
+	req.Error(err)
+
+	signatures, err = findSignatures(nil, "", filepath.Join(dir, "cache.csv"), ExtractionOptions{
+		Source: SourceMbox, Mailboxes: filepath.Join(dir, "*.mbox")})
+	req.NoError(err)
+	req.Len(signatures, 2)
+	req.Equal("bob muller", signatures[1].Name)
+}
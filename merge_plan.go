@@ -0,0 +1,118 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ProposedMerge is an active edge of IdentityGraph, that is, the merge of two signatures which
+// Reduce would apply.
+type ProposedMerge struct {
+	// Person is the ID of the resulting person, the smallest signature ID in the component.
+	Person int64
+	From   IdentityNode
+	To     IdentityNode
+	// Reasons are the distinct evidence kinds of the edge, sorted.
+	Reasons  []EvidenceKind
+	Evidence []Evidence
+	Weight   float64
+	// Confidence is the edge weight relative to the merge threshold: 1 is a borderline merge,
+	// 2 is a merge with twice as much evidence as needed. The threshold below 1 counts as 1.
+	Confidence float64
+}
+
+// MergePlan returns the merges which Reduce would apply without applying them, grouped by
+// the resulting person and sorted by the signature IDs inside.
+func (g *IdentityGraph) MergePlan() []ProposedMerge {
+	person := map[int64]int64{}
+	for _, component := range g.Components() {
+		for _, id := range component {
+			person[id] = component[0]
+		}
+	}
+	threshold := math.Max(g.threshold, 1)
+	var plan []ProposedMerge
+	for _, edge := range g.Edges() {
+		if !edge.Active {
+			continue
+		}
+		kinds := map[EvidenceKind]struct{}{}
+		for _, ev := range edge.Evidence {
+			kinds[ev.Kind] = struct{}{}
+		}
+		reasons := make([]EvidenceKind, 0, len(kinds))
+		for kind := range kinds {
+			reasons = append(reasons, kind)
+		}
+		sort.Slice(reasons, func(i, j int) bool { return reasons[i] < reasons[j] })
+		plan = append(plan, ProposedMerge{
+			Person:     person[edge.From],
+			From:       g.nodes[edge.From],
+			To:         g.nodes[edge.To],
+			Reasons:    reasons,
+			Evidence:   edge.Evidence,
+			Weight:     edge.Weight,
+			Confidence: edge.Weight / threshold,
+		})
+	}
+	sort.SliceStable(plan, func(i, j int) bool { return plan[i].Person < plan[j].Person })
+	return plan
+}
+
+var mergePlanHeader = []string{
+	"person", "from_id", "from_names", "from_emails", "to_id", "to_names", "to_emails",
+	"reasons", "evidence", "weight", "confidence"}
+
+// WriteMergePlan stores the proposed merges to the CSV file for the review before the actual
+// matching. The names and the emails of each signature are joined with "; ".
+func WriteMergePlan(path string, plan []ProposedMerge) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write(mergePlanHeader); err != nil {
+		return
+	}
+	for _, merge := range plan {
+		reasons := make([]string, len(merge.Reasons))
+		for i, reason := range merge.Reasons {
+			reasons[i] = string(reason)
+		}
+		record := append([]string{strconv.FormatInt(merge.Person, 10)}, mergePlanNode(merge.From)...)
+		record = append(record, mergePlanNode(merge.To)...)
+		record = append(record, strings.Join(reasons, "; "), formatEvidence(merge.Evidence),
+			strconv.FormatFloat(merge.Weight, 'g', -1, 64),
+			strconv.FormatFloat(merge.Confidence, 'f', 2, 64))
+		if err = writer.Write(record); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func mergePlanNode(n IdentityNode) []string {
+	names := make([]string, len(n.NamesWithRepos))
+	for i, name := range n.NamesWithRepos {
+		names[i] = name.String()
+	}
+	return []string{strconv.FormatInt(n.ID, 10), strings.Join(names, "; "), strings.Join(n.Emails, "; ")}
+}
@@ -0,0 +1,45 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePlan(t *testing.T) {
+	req := require.New(t)
+	people := newGraphTestPeople()
+	g, err := BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t),
+		ReduceOptions{MaxIdentities: 100})
+	req.NoError(err)
+	plan := g.MergePlan()
+	req.Len(plan, 2)
+	req.Equal(ProposedMerge{
+		Person:  1,
+		From:    IdentityNode{ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		To:      IdentityNode{ID: 2, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		Reasons: []EvidenceKind{EvidenceEmail, EvidenceName},
+		Evidence: []Evidence{
+			{EvidenceEmail, "bob@google.com", 1},
+			{EvidenceName, "bob", 1},
+		},
+		Weight:     2,
+		Confidence: 2,
+	}, plan[0])
+	req.Equal(int64(3), plan[1].Person)
+	req.Equal([]EvidenceKind{EvidenceName}, plan[1].Reasons)
+	// the dry run does not change the people
+	req.Equal(newGraphTestPeople(), people)
+
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(WriteMergePlan(f.Name(), plan))
+	content, err := ioutil.ReadFile(f.Name())
+	req.NoError(err)
+	req.Equal(`person,from_id,from_names,from_emails,to_id,to_names,to_emails,reasons,evidence,weight,confidence
+1,1,bob,bob@google.com,2,bob,bob@google.com,email; name,email:bob@google.com; name:bob,2,2.00
+3,3,alice,alice@google.com,4,alice,al@google.com,name,name:alice,1,1.00
+`, string(content))
+}
@@ -0,0 +1,198 @@
+package idmatch
+
+import (
+	"fmt"
+	"sort"
+)
+
+// disjointSets is the union-find forest of the person IDs which maps each ID to its parent.
+// The IDs which are missing are the roots of their own sets. The root of each set is its
+// smallest ID.
+type disjointSets map[int64]int64
+
+// find returns the root of the set with the ID and compresses the path to it.
+func (s disjointSets) find(id int64) int64 {
+	root := id
+	for {
+		parent, exists := s[root]
+		if !exists || parent == root {
+			break
+		}
+		root = parent
+	}
+	for id != root {
+		next := s[id]
+		s[id] = root
+		id = next
+	}
+	return root
+}
+
+// union joins the sets with the IDs and returns the root of the joined set.
+func (s disjointSets) union(id1, id2 int64) int64 {
+	root1, root2 := s.find(id1), s.find(id2)
+	if root1 == root2 {
+		return root1
+	}
+	if root2 < root1 {
+		root1, root2 = root2, root1
+	}
+	s[root2] = root1
+	return root1
+}
+
+// groups splits the IDs by their sets. Both the groups and the IDs inside are sorted.
+func (s disjointSets) groups(ids []int64) [][]int64 {
+	byRoot := map[int64][]int64{}
+	for _, id := range ids {
+		root := s.find(id)
+		byRoot[root] = append(byRoot[root], id)
+	}
+	result := make([][]int64, 0, len(byRoot))
+	for _, group := range byRoot {
+		Int64Slice(group).Sort()
+		result = append(result, group)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i][0] < result[j][0] })
+	return result
+}
+
+// PeopleMerger accumulates the merges of the people in the disjoint sets and applies them
+// at once, so that each merged person is built only once no matter how many merges lead to it.
+type PeopleMerger struct {
+	// SampleCommits choose Person.SampleCommits of the merged people.
+	SampleCommits SampleCommitOptions
+
+	people      People
+	sets        disjointSets
+	ids         map[int64]struct{}
+	externalIDs map[int64]ExternalIDs
+}
+
+// NewPeopleMerger creates the merger of the people, which are changed only by Apply.
+func NewPeopleMerger(people People) *PeopleMerger {
+	return &PeopleMerger{
+		people:      people,
+		sets:        disjointSets{},
+		ids:         map[int64]struct{}{},
+		externalIDs: map[int64]ExternalIDs{},
+	}
+}
+
+// Add schedules the person to be finalized by Apply even if it is not merged with anybody.
+func (m *PeopleMerger) Add(id int64) {
+	if _, exists := m.ids[id]; exists {
+		return
+	}
+	m.ids[id] = struct{}{}
+	if externalIDs := m.people[id].ExternalIDs; len(externalIDs) > 0 {
+		m.externalIDs[m.sets.find(id)] = externalIDs
+	}
+}
+
+// Find returns the ID of the person which the person with the given ID will be merged into.
+func (m *PeopleMerger) Find(id int64) int64 {
+	return m.sets.find(id)
+}
+
+// Union schedules merging the persons with the given IDs and everybody already scheduled to be
+// merged with them. It fails if the persons have different external IDs of the same provider.
+func (m *PeopleMerger) Union(id1, id2 int64) error {
+	m.Add(id1)
+	m.Add(id2)
+	root1, root2 := m.sets.find(id1), m.sets.find(id2)
+	if root1 == root2 {
+		return nil
+	}
+	externalIDs1, externalIDs2 := m.externalIDs[root1], m.externalIDs[root2]
+	if provider, conflicts := externalIDs1.conflict(externalIDs2); conflicts {
+		return fmt.Errorf("%w: cannot merge ids %d and %d with different ExternalIDs: %s %s",
+			ErrExternalIDConflict, id1, id2, externalIDs1[provider], externalIDs2[provider])
+	}
+	root := m.sets.union(root1, root2)
+	delete(m.externalIDs, root1)
+	delete(m.externalIDs, root2)
+	if externalIDs := externalIDs1.merge(externalIDs2); len(externalIDs) > 0 {
+		m.externalIDs[root] = externalIDs
+	}
+	return nil
+}
+
+// Apply merges the people as scheduled and returns the merged groups of the IDs, see
+// disjointSets.groups. Each group is merged into its smallest ID.
+func (m *PeopleMerger) Apply() ([][]int64, error) {
+	return m.apply(nil)
+}
+
+func (m *PeopleMerger) apply(prog *progress) ([][]int64, error) {
+	ids := make([]int64, 0, len(m.ids))
+	for id := range m.ids {
+		ids = append(ids, id)
+	}
+	groups := m.sets.groups(ids)
+	stage := prog.stage("merging", len(groups))
+	defer stage.done()
+	for _, group := range groups {
+		if err := stage.tick(); err != nil {
+			return nil, err
+		}
+		m.merge(group)
+	}
+	m.sets = disjointSets{}
+	m.ids = map[int64]struct{}{}
+	m.externalIDs = map[int64]ExternalIDs{}
+	return groups, nil
+}
+
+// merge unites the persons with the sorted IDs into the first one.
+func (m *PeopleMerger) merge(ids []int64) {
+	p0 := m.people[ids[0]]
+	samples := p0.sampleCommits(nil)
+	for _, id := range ids[1:] {
+		person := m.people[id]
+		samples = person.sampleCommits(samples)
+		p0.ExternalIDs = p0.ExternalIDs.merge(person.ExternalIDs)
+		p0.ExternalAccounts = mergeExternalAccounts(p0.ExternalAccounts, person.ExternalAccounts)
+		p0.IsBot = p0.IsBot || person.IsBot
+		if person.TrailerRole != p0.TrailerRole {
+			p0.TrailerRole = ""
+		}
+		p0.Emails = append(p0.Emails, person.Emails...)
+		p0.SigningKeys = append(p0.SigningKeys, person.SigningKeys...)
+		p0.Activity = mergeActivity(p0.Activity, person.Activity)
+		p0.Repositories = append(p0.Repositories, person.Repositories...)
+		first := person.FirstCommit
+		if !first.IsZero() && (p0.FirstCommit.IsZero() || first.Before(p0.FirstCommit)) {
+			p0.FirstCommit = first
+		}
+		if person.LastCommit.After(p0.LastCommit) {
+			p0.LastCommit = person.LastCommit
+		}
+		p0.Commits += person.Commits
+		p0.RecentCommits += person.RecentCommits
+		p0.NamesWithRepos = append(p0.NamesWithRepos, person.NamesWithRepos...)
+		p0.MergeEvidence = append(p0.MergeEvidence, person.MergeEvidence...)
+		for email, kinds := range person.EmailSources {
+			p0.EmailSources = addSources(p0.EmailSources, email, kinds...)
+		}
+		for name, kinds := range person.NameSources {
+			p0.NameSources = addSources(p0.NameSources, name, kinds...)
+		}
+		p0.AliasHistory.merge(person.AliasHistory)
+		delete(m.people, id)
+	}
+	p0.Emails = unique(p0.Emails)
+	if len(p0.SigningKeys) > 0 {
+		p0.SigningKeys = unique(p0.SigningKeys)
+	}
+	if len(p0.Repositories) > 0 {
+		p0.Repositories = unique(p0.Repositories)
+	}
+	p0.NamesWithRepos = uniqueNamesWithRepo(p0.NamesWithRepos)
+	p0.SampleCommits = chooseSampleCommits(samples, m.SampleCommits)
+	p0.SampleCommit = nil
+	if len(p0.SampleCommits) > 0 {
+		commit := p0.SampleCommits[0]
+		p0.SampleCommit = &commit
+	}
+}
@@ -0,0 +1,53 @@
+package idmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisjointSets(t *testing.T) {
+	req := require.New(t)
+	sets := disjointSets{}
+	req.Equal(int64(5), sets.find(5))
+	req.Equal(int64(3), sets.union(5, 3))
+	req.Equal(int64(3), sets.union(7, 5))
+	req.Equal(int64(1), sets.union(9, 1))
+	req.Equal(int64(1), sets.union(7, 9))
+	req.Equal(int64(1), sets.find(5))
+	req.Equal(int64(1), sets[5])
+	req.Equal([][]int64{{1, 3, 5, 7, 9}, {2}}, sets.groups([]int64{9, 2, 7, 5, 3, 1}))
+}
+
+func TestPeopleMerger(t *testing.T) {
+	req := require.New(t)
+	people := newGraphTestPeople()
+	people[1].ExternalIDs = ExternalIDs{"github": "bob"}
+	people[5].ExternalIDs = ExternalIDs{"github": "eve"}
+	merger := NewPeopleMerger(people)
+	req.NoError(merger.Union(2, 1))
+	req.NoError(merger.Union(4, 3))
+	req.NoError(merger.Union(2, 4))
+	merger.Add(5)
+	req.Error(merger.Union(3, 5))
+	req.Equal(int64(1), merger.Find(4))
+	req.Len(people, 5)
+	groups, err := merger.Apply()
+	req.NoError(err)
+	req.Equal([][]int64{{1, 2, 3, 4}, {5}}, groups)
+	req.Len(people, 2)
+	req.Equal("bob", people[1].ExternalIDs["github"])
+	req.Equal([]string{"al@google.com", "alice@google.com", "bob@google.com"}, people[1].Emails)
+	req.Equal([]NameWithRepo{{"alice", ""}, {"bob", ""}}, people[1].NamesWithRepos)
+
+	groups, err = merger.Apply()
+	req.NoError(err)
+	req.Empty(groups)
+	req.Error(merger.Union(1, 5))
+	people[5].ExternalIDs = ExternalIDs{"github": "bob"}
+	merger = NewPeopleMerger(people)
+	req.NoError(merger.Union(1, 5))
+	_, err = merger.Apply()
+	req.NoError(err)
+	req.Len(people, 1)
+}
@@ -0,0 +1,33 @@
+package idmatch
+
+import (
+	"github.com/src-d/identity-matching/reporter"
+)
+
+var (
+	// signaturesReadMetric counts the signatures which reach the matching by their source.
+	signaturesReadMetric = reporter.NewCounter("idmatch_signatures_read_total",
+		"Number of the signatures read by the source.", "source")
+	// mergesMetric counts the activated identity graph edges by the evidence kind which
+	// activated them.
+	mergesMetric = reporter.NewCounter("idmatch_merges_total",
+		"Number of the merges of the identities by the reason.", "reason")
+	// stageDurationMetric measures the duration of each pipeline stage.
+	stageDurationMetric = reporter.NewHistogram("idmatch_stage_duration_seconds",
+		"Duration of the pipeline stages in seconds.", reporter.DurationBuckets, "stage")
+)
+
+// countSignaturesRead updates signaturesReadMetric. The signatures without Signature.Source
+// are counted as "unknown".
+func countSignaturesRead(commits []Signature) {
+	counts := map[SourceKind]int{}
+	for _, commit := range commits {
+		counts[commit.Source]++
+	}
+	for source, count := range counts {
+		if source == "" {
+			source = "unknown"
+		}
+		signaturesReadMetric.Add(float64(count), string(source))
+	}
+}
@@ -0,0 +1,28 @@
+package idmatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	req := require.New(t)
+	emailMerges := mergesMetric.Value(string(EvidenceEmail))
+	nameMerges := mergesMetric.Value(string(EvidenceName))
+	_, err := BuildIdentityGraph(context.Background(), newGraphTestPeople(), nil,
+		newTestBlacklist(t), ReduceOptions{MaxIdentities: 100})
+	req.NoError(err)
+	// bob is merged by email and alice by name
+	req.Equal(emailMerges+1, mergesMetric.Value(string(EvidenceEmail)))
+	req.Equal(nameMerges+1, mergesMetric.Value(string(EvidenceName)))
+
+	read := signaturesReadMetric.Value("unknown")
+	durations := stageDurationMetric.Count("counting name frequencies")
+	_, _, _, err = PeopleFromSignatures(context.Background(), Signatures, ExtractionOptions{},
+		newTestBlacklist(t), PopularityThresholds{}, BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	req.Equal(read+float64(len(Signatures)), signaturesReadMetric.Value("unknown"))
+	req.Equal(durations+1, stageDurationMetric.Count("counting name frequencies"))
+}
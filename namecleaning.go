@@ -0,0 +1,121 @@
+package idmatch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NameCleaningStepKind is the kind of the transformation of NameCleaningStep.
+type NameCleaningStepKind string
+
+const (
+	// NameTransliterate removes the diacritics and converts the letters to the Latin script,
+	// see transliterate.
+	NameTransliterate NameCleaningStepKind = "transliterate"
+	// NameDiacritics only removes the diacritics and keeps the other scripts intact.
+	NameDiacritics NameCleaningStepKind = "diacritics"
+	// NameLowercase converts the name to lower case.
+	NameLowercase NameCleaningStepKind = "lowercase"
+	// NameParens removes the text in the parentheses after the name, e.g. "Bob (work)".
+	NameParens NameCleaningStepKind = "parens"
+	// NameSuffixes removes the generational and academic suffixes, e.g. "Jr." or "PhD".
+	NameSuffixes NameCleaningStepKind = "suffixes"
+	// NameReplace replaces the matches of NameCleaningStep.Pattern with
+	// NameCleaningStep.Replacement.
+	NameReplace NameCleaningStepKind = "replace"
+)
+
+// NameCleaningStep is a single transformation of NameCleaner.
+type NameCleaningStep struct {
+	Kind NameCleaningStepKind
+	// Pattern and Replacement are set only for NameReplace. The replacement may refer to
+	// the groups of the pattern as $1, $2, etc.
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// NameCleaner is the pipeline which normalizes the names before matching them. The steps are
+// applied in order, then the spaces are collapsed and trimmed.
+type NameCleaner []NameCleaningStep
+
+var nameSuffixRegex = regexp.MustCompile(
+	`(?i)[\s,]+(jr|sr|ii|iii|iv|ph\.?\s?d|m\.?d|esq)\.?\s*$`)
+
+// NewNameCleaner returns the default pipeline: transliterate and lowercase.
+func NewNameCleaner() NameCleaner {
+	return NameCleaner{{Kind: NameTransliterate}, {Kind: NameLowercase}}
+}
+
+// ReadNameCleaner loads the pipeline from a CSV file with the columns "step", "pattern" and
+// "replacement". The step is one of the NameCleaningStepKind values; the pattern and
+// the replacement are used only by "replace" and the pattern is a Go regular expression.
+func ReadNameCleaner(path string) (cleaner NameCleaner, err error) {
+	cleaner = NameCleaner{}
+	err = readCSVRecords(path, "name cleaning", []string{"step", "pattern", "replacement"},
+		func(header map[string]int, record []string) error {
+			step := NameCleaningStep{
+				Kind: NameCleaningStepKind(strings.ToLower(strings.TrimSpace(record[header["step"]]))),
+			}
+			switch step.Kind {
+			case NameTransliterate, NameDiacritics, NameLowercase, NameParens, NameSuffixes:
+			case NameReplace:
+				var err error
+				step.Pattern, err = regexp.Compile(record[header["pattern"]])
+				if err != nil {
+					return fmt.Errorf("invalid name cleaning pattern %q: %v",
+						record[header["pattern"]], err)
+				}
+				step.Replacement = record[header["replacement"]]
+			default:
+				return fmt.Errorf("unknown name cleaning step: %s", step.Kind)
+			}
+			cleaner = append(cleaner, step)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return cleaner, nil
+}
+
+// Clean applies the pipeline to the name.
+func (c NameCleaner) Clean(name string) (string, error) {
+	var err error
+	for _, step := range c {
+		switch step.Kind {
+		case NameTransliterate:
+			name, err = transliterate(name)
+		case NameDiacritics:
+			name, _, err = removeDiacritical(name)
+		case NameLowercase:
+			name = strings.ToLower(name)
+		case NameParens:
+			name = removeParens(name)
+		case NameSuffixes:
+			for {
+				stripped := nameSuffixRegex.ReplaceAllString(name, "")
+				if stripped == name || strings.TrimSpace(stripped) == "" {
+					break
+				}
+				name = stripped
+			}
+		case NameReplace:
+			name = step.Pattern.ReplaceAllString(name, step.Replacement)
+		}
+		if err != nil {
+			return name, err
+		}
+	}
+	return strings.TrimSpace(normalizeSpaces(name)), nil
+}
+
+// nameCleaner is the pipeline of cleanName.
+var nameCleaner = NewNameCleaner()
+
+// SetNameCleaner replaces the pipeline which cleans all the names, including the names in
+// the constraints and the mailmap. It must be called before reading or matching anything
+// because the names cleaned differently do not match.
+func SetNameCleaner(cleaner NameCleaner) {
+	nameCleaner = cleaner
+}
@@ -0,0 +1,70 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNameCleanerClean(t *testing.T) {
+	req := require.New(t)
+	for _, testCase := range []struct {
+		cleaner  NameCleaner
+		name     string
+		expected string
+	}{
+		{NewNameCleaner(), "  Łukasz   Nowak ", "lukasz nowak"},
+		{NewNameCleaner(), "Иван (work)", "ivan (work)"},
+		{NameCleaner{{Kind: NameDiacritics}}, "José Иван", "Jose Иван"},
+		{NameCleaner{{Kind: NameParens}, {Kind: NameLowercase}}, "Bob Smith (work)", "bob smith"},
+		{NameCleaner{{Kind: NameSuffixes}}, "Bob Smith, Jr. PhD", "Bob Smith"},
+		{NameCleaner{{Kind: NameSuffixes}}, "Bob Smith III", "Bob Smith"},
+		{NameCleaner{{Kind: NameSuffixes}}, "Ivy", "Ivy"},
+		{NameCleaner{{Kind: NameReplace, Pattern: regexp.MustCompile(`^(?i)dr\.?\s+`)}}, "Dr. Bob", "Bob"},
+		{NameCleaner{}, " Bob\tSmith ", "Bob Smith"},
+	} {
+		name, err := testCase.cleaner.Clean(testCase.name)
+		req.NoError(err)
+		req.Equal(testCase.expected, name, testCase.name)
+	}
+}
+
+func TestReadNameCleaner(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(ioutil.WriteFile(f.Name(), []byte(`step,pattern,replacement
+diacritics,,
+Suffixes,,
+replace,"^(\w+), (\w+)$",$2 $1
+lowercase,,
+`), 0666))
+	cleaner, err := ReadNameCleaner(f.Name())
+	req.NoError(err)
+	req.Len(cleaner, 4)
+	req.Equal(NameReplace, cleaner[2].Kind)
+	name, err := cleaner.Clean("Müller, Hans Jr.")
+	req.NoError(err)
+	req.Equal("hans muller", name)
+
+	for _, content := range []string{
+		"step,pattern\nlowercase,\n",
+		"step,pattern,replacement\nuppercase,,\n",
+		"step,pattern,replacement\nreplace,(,\n",
+	} {
+		req.NoError(ioutil.WriteFile(f.Name(), []byte(content), 0666))
+		_, err = ReadNameCleaner(f.Name())
+		req.Error(err, content)
+	}
+}
+
+func TestSetNameCleaner(t *testing.T) {
+	req := require.New(t)
+	defer SetNameCleaner(NewNameCleaner())
+	SetNameCleaner(NameCleaner{{Kind: NameParens}})
+	name, err := cleanName("Bob (work)")
+	req.NoError(err)
+	req.Equal("Bob", name)
+}
@@ -0,0 +1,51 @@
+package idmatch
+
+import "strings"
+
+// disposableDomains are the popular throwaway mailbox providers. Anybody can receive the mail
+// at any address there, so the same email does not imply the same person.
+var disposableDomains = []string{
+	"mailinator.com", "guerrillamail.com", "guerrillamail.net", "guerrillamail.org",
+	"sharklasers.com", "grr.la", "10minutemail.com", "10minutemail.net", "tempmail.com",
+	"temp-mail.org", "temp-mail.io", "tempmailo.com", "throwawaymail.com", "yopmail.com",
+	"yopmail.net", "trashmail.com", "trashmail.de", "getnada.com", "nada.email",
+	"dispostable.com", "maildrop.cc", "mailnesia.com", "mintemail.com", "mohmal.com",
+	"fakeinbox.com", "spamgourmet.com", "mytemp.email", "emailondeck.com", "moakt.com",
+	"burnermail.io", "33mail.com", "discard.email", "mailcatch.com", "spambox.us",
+}
+
+// noreplyPatterns match the emails which are generated by the platforms or the tools instead of
+// belonging to a person: the noreply addresses and the addresses at the local machines.
+var noreplyPatterns = []string{
+	`@users\.noreply\.github\.com$`,
+	`@noreply\.github\.com$`,
+	`@users\.noreply\.gitlab\.com$`,
+	`@noreply\.gitlab\.com$`,
+	`@users\.noreply\.bitbucket\.org$`,
+	`^(no-?reply|do-?not-?reply)([+_-][^@]*)?@`,
+	`@localhost(\.localdomain)?$`,
+	`@[^@]+\.(local|localdomain)$`,
+}
+
+// isUnmatchableEmail checks whether the email is disposable, a shared mailbox or matches
+// NoReplyPatterns, see Blacklist.DisposableDomains. Such emails stay with the identities but are
+// never the evidence to merge them.
+func (b Blacklist) isUnmatchableEmail(email string) bool {
+	if b.NoReplyPatterns.MatchString(email) {
+		return true
+	}
+	if _, exists := b.SharedMailboxes[email]; exists {
+		return true
+	}
+	domain := email[strings.LastIndex(email, "@")+1:]
+	for {
+		if _, exists := b.DisposableDomains[domain]; exists {
+			return true
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			return false
+		}
+		domain = domain[dot+1:]
+	}
+}
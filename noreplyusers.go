@@ -0,0 +1,88 @@
+package idmatch
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// NoReplyUserTemplates are the templates of the noreply emails of the public platforms by
+// provider, see NewNoReplyUserPattern.
+var NoReplyUserTemplates = map[string]string{
+	"github": `({id}\+)?{user}@users\.noreply\.github\.com`,
+	"gitlab": `({id}-)?{user}@users\.noreply\.gitlab\.com`,
+}
+
+// NoReplyUserPattern extracts the username of the platform account from its noreply email,
+// e.g. "1234-bob@users.noreply.gitlab.com" belongs to "bob" at GitLab.
+type NoReplyUserPattern struct {
+	// Provider is the platform of the accounts, e.g. "gitlab", the same as in Person.ExternalIDs.
+	Provider string
+	// Pattern matches the whole email and captures the username in the group "user".
+	Pattern *regexp.Regexp
+}
+
+// NewNoReplyUserPattern compiles the regular expression template of the noreply emails of
+// the provider. The template matches the whole email regardless of the case, "{user}" stands
+// for the username and "{id}" for the numeric account ID, e.g. `{user}@noreply\.git\.corp\.com`
+// for a self-hosted Gitea.
+func NewNoReplyUserPattern(provider, template string) (NoReplyUserPattern, error) {
+	if provider == "" {
+		return NoReplyUserPattern{}, fmt.Errorf("the provider of %s is empty", template)
+	}
+	if strings.Count(template, "{user}") != 1 {
+		return NoReplyUserPattern{}, fmt.Errorf("%s must have a single {user}", template)
+	}
+	expr := strings.NewReplacer(
+		"{user}", `(?P<user>[a-z0-9][a-z0-9._-]*)`,
+		"{id}", `[0-9]+`,
+	).Replace(template)
+	pattern, err := regexp.Compile("(?i)^(?:" + expr + ")$")
+	if err != nil {
+		return NoReplyUserPattern{}, err
+	}
+	return NoReplyUserPattern{Provider: provider, Pattern: pattern}, nil
+}
+
+// NoReplyUserPatterns extract the usernames from the noreply emails of several platforms.
+type NoReplyUserPatterns []NoReplyUserPattern
+
+// ParseNoReplyUserPatterns returns the patterns of the specs, which are either the providers
+// of NoReplyUserTemplates or "provider=template", see NewNoReplyUserPattern.
+func ParseNoReplyUserPatterns(specs []string) (NoReplyUserPatterns, error) {
+	var patterns NoReplyUserPatterns
+	for _, spec := range specs {
+		provider, template := spec, NoReplyUserTemplates[spec]
+		if eq := strings.Index(spec, "="); eq >= 0 {
+			provider, template = spec[:eq], spec[eq+1:]
+		} else if template == "" {
+			var known []string
+			for name := range NoReplyUserTemplates {
+				known = append(known, name)
+			}
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown noreply provider %s, the built-in are %s, "+
+				"the others must be provider=template", spec, strings.Join(known, ", "))
+		}
+		pattern, err := NewNoReplyUserPattern(provider, template)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// User returns the provider and the lowercase username of the noreply email. The first matching
+// pattern wins.
+func (patterns NoReplyUserPatterns) User(email string) (provider, user string, ok bool) {
+	for _, pattern := range patterns {
+		match := pattern.Pattern.FindStringSubmatch(email)
+		if match == nil {
+			continue
+		}
+		return pattern.Provider, strings.ToLower(match[pattern.Pattern.SubexpIndex("user")]), true
+	}
+	return "", "", false
+}
@@ -0,0 +1,71 @@
+package idmatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoReplyUserPatterns(t *testing.T) {
+	req := require.New(t)
+	patterns, err := ParseNoReplyUserPatterns([]string{
+		"github", "gitlab", `gitea={user}@noreply\.git\.corp\.com`})
+	req.NoError(err)
+	for email, expected := range map[string][2]string{
+		"123+Bob@users.noreply.github.com":        {"github", "bob"},
+		"bob@users.noreply.github.com":            {"github", "bob"},
+		"1234-bob-smith@users.noreply.gitlab.com": {"gitlab", "bob-smith"},
+		"bob.smith@users.noreply.gitlab.com":      {"gitlab", "bob.smith"},
+		"bob@noreply.git.corp.com":                {"gitea", "bob"},
+	} {
+		provider, user, ok := patterns.User(email)
+		req.True(ok, email)
+		req.Equal(expected, [2]string{provider, user}, email)
+	}
+	for _, email := range []string{
+		"bob@google.com", "bob@noreply.gitlab.com", "bob@noreply.git.corp.com.evil.com"} {
+		_, _, ok := patterns.User(email)
+		req.False(ok, email)
+	}
+	_, _, ok := NoReplyUserPatterns(nil).User("bob@users.noreply.github.com")
+	req.False(ok)
+
+	_, err = ParseNoReplyUserPatterns([]string{"gitea"})
+	req.EqualError(err, "unknown noreply provider gitea, the built-in are github, gitlab, "+
+		"the others must be provider=template")
+	_, err = ParseNoReplyUserPatterns([]string{"gitea=bob@noreply.git.corp.com"})
+	req.EqualError(err, "bob@noreply.git.corp.com must have a single {user}")
+	_, err = ParseNoReplyUserPatterns([]string{"={user}@noreply.git.corp.com"})
+	req.Error(err)
+	_, err = ParseNoReplyUserPatterns([]string{"gitea={user}@(noreply"})
+	req.Error(err)
+}
+
+func TestReducePeopleNoReplyUsers(t *testing.T) {
+	req := require.New(t)
+	newPeople := func() People {
+		return People{
+			1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}},
+				Emails: []string{"1234-bob@users.noreply.gitlab.com"}},
+			2: {ID: 2, NamesWithRepos: []NameWithRepo{{"robert", ""}},
+				Emails: []string{"bob@users.noreply.gitlab.com"}},
+			3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}},
+				Emails: []string{"bob@users.noreply.github.com"}},
+		}
+	}
+	blacklist := newTestBlacklist(t)
+	people := newPeople()
+	req.NoError(ReducePeople(context.Background(), people, nil, blacklist, ReduceOptions{}))
+	req.Len(people, 3)
+
+	patterns, err := ParseNoReplyUserPatterns([]string{"github", "gitlab"})
+	req.NoError(err)
+	people = newPeople()
+	req.NoError(ReducePeople(context.Background(), people, nil, blacklist,
+		ReduceOptions{NoReplyUsers: patterns, ExplainMerges: true}))
+	req.Len(people, 2)
+	req.Equal([]string{"1234-bob@users.noreply.gitlab.com", "bob@users.noreply.gitlab.com"},
+		people[1].Emails)
+	req.Equal([]Evidence{{EvidenceExternalID, "gitlab:bob", 1}}, people[1].MergeEvidence[0].Evidence)
+}
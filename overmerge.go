@@ -0,0 +1,187 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// OverMerge is the edge which was not activated because it would join the identities into
+// a person with too many emails or names, see ReduceOptions.MaxClusterEmails and
+// ReduceOptions.MaxClusterNames. The typical cause is a team email shared by many people.
+type OverMerge struct {
+	From int64
+	To   int64
+	// Emails and Names are the numbers of the distinct emails and names of the person which
+	// the edge would have created.
+	Emails int
+	Names  int
+	// Evidence is the evidence of the edge.
+	Evidence []Evidence
+}
+
+// clusterValues are the distinct emails and names of a growing person in applyClusterLimits.
+type clusterValues struct {
+	emails map[string]struct{}
+	names  map[string]struct{}
+}
+
+// isForced returns true if the edge was activated by the reviewers or by a must-link
+// constraint, which the limits never break.
+func isForced(edge IdentityEdge) bool {
+	for _, evidence := range edge.Evidence {
+		if evidence.Kind == EvidenceReview || evidence.Kind == EvidenceConstraint {
+			return true
+		}
+	}
+	return false
+}
+
+// applyClusterLimits deactivates the edges which would join the identities into a person with
+// more than maxEmails distinct emails or more than maxNames distinct names. The forced edges are
+// joined first, then the other active edges from the heaviest, so that the strongest evidence
+// wins and the chain of the weaker merges stops at the limit. 0 disables the limit. The refused
+// edges are returned for the review.
+func (g *IdentityGraph) applyClusterLimits(maxEmails, maxNames int) []OverMerge {
+	if maxEmails <= 0 && maxNames <= 0 {
+		return nil
+	}
+	edges := g.Edges()
+	sort.SliceStable(edges, func(i, j int) bool {
+		forced1, forced2 := isForced(edges[i]), isForced(edges[j])
+		if forced1 != forced2 {
+			return forced1
+		}
+		return edges[i].Weight > edges[j].Weight
+	})
+	sets := disjointSets{}
+	clusters := map[int64]*clusterValues{}
+	cluster := func(root int64) *clusterValues {
+		if values := clusters[root]; values != nil {
+			return values
+		}
+		values := &clusterValues{map[string]struct{}{}, map[string]struct{}{}}
+		person := g.nodes[root]
+		for _, email := range person.Emails {
+			values.emails[email] = struct{}{}
+		}
+		for _, name := range person.NamesWithRepos {
+			values.names[name.Name] = struct{}{}
+		}
+		clusters[root] = values
+		return values
+	}
+	// union counts the distinct values of both sets without copying the larger one
+	union := func(set1, set2 map[string]struct{}) int {
+		if len(set1) < len(set2) {
+			set1, set2 = set2, set1
+		}
+		size := len(set1)
+		for value := range set2 {
+			if _, exists := set1[value]; !exists {
+				size++
+			}
+		}
+		return size
+	}
+	var overMerges []OverMerge
+	for _, edge := range edges {
+		if !edge.Active {
+			continue
+		}
+		root1, root2 := sets.find(edge.From), sets.find(edge.To)
+		if root1 == root2 {
+			continue
+		}
+		values1, values2 := cluster(root1), cluster(root2)
+		emails, names := union(values1.emails, values2.emails), union(values1.names, values2.names)
+		if !isForced(edge) && (maxEmails > 0 && emails > maxEmails || maxNames > 0 && names > maxNames) {
+			g.edges[newEdgeKey(edge.From, edge.To)].Active = false
+			overMerges = append(overMerges, OverMerge{
+				From: edge.From, To: edge.To, Emails: emails, Names: names, Evidence: edge.Evidence})
+			continue
+		}
+		if len(values1.emails)+len(values1.names) < len(values2.emails)+len(values2.names) {
+			values1, values2 = values2, values1
+		}
+		for email := range values2.emails {
+			values1.emails[email] = struct{}{}
+		}
+		for name := range values2.names {
+			values1.names[name] = struct{}{}
+		}
+		delete(clusters, root1)
+		delete(clusters, root2)
+		clusters[sets.union(root1, root2)] = values1
+	}
+	if len(overMerges) > 0 {
+		reporter.Warnf("%d merges were refused because the people would have more than %d emails "+
+			"or %d names", len(overMerges), maxEmails, maxNames)
+	}
+	reporter.Commit("over-merges refused", len(overMerges))
+	return overMerges
+}
+
+// OverMerges returns the edges which were kept inactive by the cluster size limits, see
+// ReduceOptions.MaxClusterEmails, sorted by the node IDs.
+func (g *IdentityGraph) OverMerges() []OverMerge {
+	result := append([]OverMerge(nil), g.overMerges...)
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].From != result[j].From {
+			return result[i].From < result[j].From
+		}
+		return result[i].To < result[j].To
+	})
+	return result
+}
+
+var overMergesCSVHeader = []string{
+	"from", "to", "emails", "names", "from_emails", "to_emails", "evidence"}
+
+// WriteOverMerges saves the refused merges to the CSV file for the manual review. The emails of
+// each node and the evidence are joined with "; ". The reviewers may approve the merges with
+// ReviewDecisions.
+func (g *IdentityGraph) WriteOverMerges(path string) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write(overMergesCSVHeader); err != nil {
+		return
+	}
+	for _, overMerge := range g.OverMerges() {
+		evidence := make([]string, 0, len(overMerge.Evidence))
+		for _, e := range overMerge.Evidence {
+			evidence = append(evidence, string(e.Kind)+":"+e.Value)
+		}
+		if err = writer.Write([]string{
+			strconv.FormatInt(overMerge.From, 10), strconv.FormatInt(overMerge.To, 10),
+			strconv.Itoa(overMerge.Emails), strconv.Itoa(overMerge.Names),
+			strings.Join(g.nodes[overMerge.From].Emails, "; "),
+			strings.Join(g.nodes[overMerge.To].Emails, "; "),
+			strings.Join(evidence, "; "),
+		}); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newOverMergeTestPeople() People {
+	return People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"team@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"team@google.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"eve", ""}}, Emails: []string{"team@google.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+	}
+}
+
+func TestClusterLimits(t *testing.T) {
+	req := require.New(t)
+	opts := ReduceOptions{MaxIdentities: 20}
+	g, err := BuildIdentityGraph(context.Background(), newOverMergeTestPeople(), nil,
+		newTestBlacklist(t), opts)
+	req.NoError(err)
+	req.Empty(g.OverMerges())
+	req.Equal([][]int64{{1, 2, 3, 4}}, g.Components())
+
+	opts.MaxClusterNames = 2
+	people := newOverMergeTestPeople()
+	g, err = BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t), opts)
+	req.NoError(err)
+	req.Equal([][]int64{{1, 2, 4}, {3}}, g.Components())
+	req.Equal([]OverMerge{{From: 1, To: 3, Emails: 1, Names: 3,
+		Evidence: []Evidence{{EvidenceEmail, "team@google.com", 1}}}}, g.OverMerges())
+
+	path, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(g.WriteOverMerges(path.Name()))
+	content, err := ioutil.ReadFile(path.Name())
+	req.NoError(err)
+	req.Equal("from,to,emails,names,from_emails,to_emails,evidence\n"+
+		"1,3,1,3,team@google.com,team@google.com,email:team@google.com\n", string(content))
+
+	req.NoError(g.Reduce(context.Background(), people))
+	req.Len(people, 2)
+
+	opts = ReduceOptions{MaxIdentities: 20, MaxClusterEmails: 1}
+	g, err = BuildIdentityGraph(context.Background(), newOverMergeTestPeople(), nil,
+		newTestBlacklist(t), opts)
+	req.NoError(err)
+	req.Equal([][]int64{{1, 2, 3}, {4}}, g.Components())
+}
+
+func TestIsForced(t *testing.T) {
+	req := require.New(t)
+	req.False(isForced(IdentityEdge{Evidence: []Evidence{{EvidenceEmail, "x", 1}}}))
+	req.True(isForced(IdentityEdge{Evidence: []Evidence{{EvidenceName, "x", 1},
+		{EvidenceConstraint, "y", 1}}}))
+	req.True(isForced(IdentityEdge{Evidence: []Evidence{{EvidenceReview, "approve", 1}}}))
+}
@@ -0,0 +1,313 @@
+package idmatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// PairFeatures are the engineered features of two candidate identities which PairScorer
+// turns into the probability that both are the same person.
+type PairFeatures struct {
+	// NameDistance is the normalized edit distance between the closest names from 0 (same)
+	// to 1 (nothing in common).
+	NameDistance float64
+	// NameTokenOverlap is the Jaccard index of the name tokens.
+	NameTokenOverlap float64
+	// EmailLocalDistance is the normalized edit distance between the closest email local parts.
+	EmailLocalDistance float64
+	// SameEmailDomain is 1 if the identities share an email domain and 0 otherwise.
+	SameEmailDomain float64
+	// RepositorySimilarity is the cosine similarity of the repository vectors,
+	// see repositoryVectors.
+	RepositorySimilarity float64
+	// ActivitySimilarity is the similarity of the commit hours and time zones, see Activity.
+	ActivitySimilarity float64
+}
+
+// PairFeatureNames are the names of the PairFeatures fields in the model files.
+var PairFeatureNames = []string{
+	"name_distance", "name_token_overlap", "email_local_distance", "same_email_domain",
+	"repository_similarity", "activity_similarity",
+}
+
+// Values maps PairFeatureNames to the feature values.
+func (f PairFeatures) Values() map[string]float64 {
+	return map[string]float64{
+		"name_distance":         f.NameDistance,
+		"name_token_overlap":    f.NameTokenOverlap,
+		"email_local_distance":  f.EmailLocalDistance,
+		"same_email_domain":     f.SameEmailDomain,
+		"repository_similarity": f.RepositorySimilarity,
+		"activity_similarity":   f.ActivitySimilarity,
+	}
+}
+
+// PairScorer is the learned matcher which estimates the probability that two candidate identities
+// are the same person. See LoadPairScorer for the default implementations.
+type PairScorer interface {
+	// Score returns the probability from 0 to 1.
+	Score(features PairFeatures) (float64, error)
+}
+
+// LogisticRegression is the PairScorer which applies the sigmoid to the linear combination of
+// the features.
+type LogisticRegression struct {
+	Intercept float64            `json:"intercept"`
+	Weights   map[string]float64 `json:"weights"`
+}
+
+// Score implements PairScorer.
+func (m *LogisticRegression) Score(features PairFeatures) (float64, error) {
+	values := features.Values()
+	sum := m.Intercept
+	for name, weight := range m.Weights {
+		sum += weight * values[name]
+	}
+	return sigmoid(sum), nil
+}
+
+// RegressionTreeNode is a node of RegressionTree. The leaves have the empty Feature.
+// The inner nodes go to Left if the feature value is less than Threshold and to Right otherwise.
+type RegressionTreeNode struct {
+	Feature   string  `json:"feature,omitempty"`
+	Threshold float64 `json:"threshold,omitempty"`
+	Left      int     `json:"left,omitempty"`
+	Right     int     `json:"right,omitempty"`
+	Value     float64 `json:"value,omitempty"`
+}
+
+// RegressionTree is the decision tree whose root is the first node.
+type RegressionTree struct {
+	Nodes []RegressionTreeNode `json:"nodes"`
+}
+
+func (t RegressionTree) predict(values map[string]float64) (float64, error) {
+	index := 0
+	for steps := 0; steps <= len(t.Nodes); steps++ {
+		if index < 0 || index >= len(t.Nodes) {
+			return 0, fmt.Errorf("tree node %d does not exist", index)
+		}
+		node := t.Nodes[index]
+		if node.Feature == "" {
+			return node.Value, nil
+		}
+		if values[node.Feature] < node.Threshold {
+			index = node.Left
+		} else {
+			index = node.Right
+		}
+	}
+	return 0, fmt.Errorf("the tree has a cycle")
+}
+
+// GradientBoosting is the PairScorer which applies the sigmoid to the sum of the predictions of
+// the regression trees, like the binary classifiers of XGBoost and LightGBM.
+type GradientBoosting struct {
+	Intercept float64          `json:"intercept"`
+	Trees     []RegressionTree `json:"trees"`
+}
+
+// Score implements PairScorer.
+func (m *GradientBoosting) Score(features PairFeatures) (float64, error) {
+	values := features.Values()
+	sum := m.Intercept
+	for i, tree := range m.Trees {
+		prediction, err := tree.predict(values)
+		if err != nil {
+			return 0, fmt.Errorf("tree %d: %v", i, err)
+		}
+		sum += prediction
+	}
+	return sigmoid(sum), nil
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
+
+// pairModel is the JSON file with the model: {"type": "logistic", "intercept": ..., "weights":
+// {"<feature>": ...}} or {"type": "gbm", "intercept": ..., "trees": [{"nodes": [...]}]}.
+type pairModel struct {
+	Type string `json:"type"`
+	LogisticRegression
+	Trees []RegressionTree `json:"trees"`
+}
+
+// LoadPairScorer reads the logistic regression or the gradient boosting model from the JSON file,
+// see pairModel. The features must be from PairFeatureNames.
+func LoadPairScorer(path string) (PairScorer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var model pairModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("failed to parse the model %s: %v", path, err)
+	}
+	known := PairFeatures{}.Values()
+	check := func(feature string) error {
+		if _, exists := known[feature]; !exists {
+			return fmt.Errorf("unknown feature %s in the model %s, the supported features are %v",
+				feature, path, PairFeatureNames)
+		}
+		return nil
+	}
+	switch model.Type {
+	case "logistic":
+		for feature := range model.Weights {
+			if err := check(feature); err != nil {
+				return nil, err
+			}
+		}
+		return &model.LogisticRegression, nil
+	case "gbm":
+		for _, tree := range model.Trees {
+			if len(tree.Nodes) == 0 {
+				return nil, fmt.Errorf("empty tree in the model %s", path)
+			}
+			for _, node := range tree.Nodes {
+				if node.Feature == "" {
+					continue
+				}
+				if err := check(node.Feature); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return &GradientBoosting{Intercept: model.Intercept, Trees: model.Trees}, nil
+	default:
+		return nil, fmt.Errorf("unsupported model type %q in %s, must be \"logistic\" or \"gbm\"",
+			model.Type, path)
+	}
+}
+
+// computePairFeatures calculates the features of two identities. repoVectors are the result of
+// repositoryVectors for the persons' IDs.
+func computePairFeatures(person1, person2 *Person,
+	repoVectors map[int64]map[string]float64) PairFeatures {
+	features := PairFeatures{NameDistance: 1, EmailLocalDistance: 1}
+	tokens1, tokens2 := map[string]struct{}{}, map[string]struct{}{}
+	for _, name1 := range person1.NamesWithRepos {
+		for _, token := range splitNameTokens(name1.Name) {
+			tokens1[token] = struct{}{}
+		}
+		for _, name2 := range person2.NamesWithRepos {
+			features.NameDistance = math.Min(
+				features.NameDistance, normalizedEditDistance(name1.Name, name2.Name))
+		}
+	}
+	for _, name2 := range person2.NamesWithRepos {
+		for _, token := range splitNameTokens(name2.Name) {
+			tokens2[token] = struct{}{}
+		}
+	}
+	shared := 0
+	for token := range tokens1 {
+		if _, exists := tokens2[token]; exists {
+			shared++
+		}
+	}
+	if union := len(tokens1) + len(tokens2) - shared; union > 0 {
+		features.NameTokenOverlap = float64(shared) / float64(union)
+	}
+	for _, email1 := range person1.Emails {
+		local1, domain1 := splitEmailAddress(email1)
+		for _, email2 := range person2.Emails {
+			local2, domain2 := splitEmailAddress(email2)
+			features.EmailLocalDistance = math.Min(
+				features.EmailLocalDistance, normalizedEditDistance(local1, local2))
+			if domain1 != "" && domain1 == domain2 {
+				features.SameEmailDomain = 1
+			}
+		}
+	}
+	features.RepositorySimilarity = cosineSimilarity(repoVectors[person1.ID], repoVectors[person2.ID])
+	features.ActivitySimilarity = person1.Activity.Similarity(person2.Activity)
+	return features
+}
+
+// splitEmailAddress returns the local part and the domain of the email.
+func splitEmailAddress(email string) (string, string) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return email, ""
+	}
+	return email[:at], email[at+1:]
+}
+
+// normalizedEditDistance returns the Levenshtein distance between the strings divided by
+// the length of the longest, from 0 (equal) to 1.
+func normalizedEditDistance(a, b string) float64 {
+	runesA, runesB := []rune(a), []rune(b)
+	if len(runesA) == 0 && len(runesB) == 0 {
+		return 0
+	}
+	longest := len(runesA)
+	if len(runesB) > longest {
+		longest = len(runesB)
+	}
+	return float64(editDistance(runesA, runesB)) / float64(longest)
+}
+
+// editDistance returns the Levenshtein distance between the strings.
+func editDistance(runesA, runesB []rune) int {
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(runesB)]
+}
+
+func minInt(x, y int) int {
+	if x < y {
+		return x
+	}
+	return y
+}
+
+// addClassifierEvidence scores the existing edges and the blocked candidates, see Blocker, with
+// ReduceOptions.PairScorer and adds EvidenceClassifier weighted by the probability to the pairs
+// whose probability reaches ReduceOptions.MinPairProbability.
+func addClassifierEvidence(peopleGraph *IdentityGraph, people People, opts ReduceOptions) error {
+	vectors := repositoryVectors(people)
+	matched := 0
+	for _, edge := range peopleGraph.candidateEdges() {
+		node1, node2 := peopleGraph.node(edge.From), peopleGraph.node(edge.To)
+		probability, err := opts.PairScorer.Score(computePairFeatures(node1.Value, node2.Value, vectors))
+		if err != nil {
+			return err
+		}
+		if probability < opts.MinPairProbability {
+			continue
+		}
+		if !edge.Active && !passIdentitiesLimit(peopleGraph.graph, opts.MaxIdentities, node1, node2) {
+			continue
+		}
+		err = peopleGraph.addScaledEvidence(node1, node2, EvidenceClassifier,
+			fmt.Sprintf("%.2f", probability), probability)
+		if err != nil {
+			// the identities have different external ids
+			continue
+		}
+		matched++
+	}
+	reporter.Commit("people matched by the classifier", matched)
+	return nil
+}
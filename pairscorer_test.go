@@ -0,0 +1,110 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizedEditDistance(t *testing.T) {
+	req := require.New(t)
+	req.Equal(0.0, normalizedEditDistance("", ""))
+	req.Equal(0.0, normalizedEditDistance("bob", "bob"))
+	req.Equal(1.0, normalizedEditDistance("bob", ""))
+	req.InDelta(0.25, normalizedEditDistance("bobs", "bob"), 1e-9)
+	req.InDelta(1.0/6, normalizedEditDistance("müller", "muller"), 1e-9)
+}
+
+func TestComputePairFeatures(t *testing.T) {
+	req := require.New(t)
+	people := newRepositoryTestPeople()
+	people[2].NamesWithRepos = []NameWithRepo{{"alice smith", ""}}
+	features := computePairFeatures(people[1], people[2], repositoryVectors(people))
+	req.InDelta(6.0/11, features.NameDistance, 1e-9)
+	req.InDelta(0.5, features.NameTokenOverlap, 1e-9)
+	req.InDelta(3.0/5, features.EmailLocalDistance, 1e-9)
+	req.Equal(1.0, features.SameEmailDomain)
+	req.InDelta(1, features.RepositorySimilarity, 1e-9)
+	req.Equal(0.0, features.ActivitySimilarity)
+	req.Len(features.Values(), len(PairFeatureNames))
+	for _, name := range PairFeatureNames {
+		req.Contains(features.Values(), name)
+	}
+}
+
+func writeTestModel(t *testing.T, dir, model string) string {
+	path := filepath.Join(dir, "model.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(model), 0666))
+	return path
+}
+
+func TestLoadPairScorer(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-model")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	scorer, err := LoadPairScorer(writeTestModel(t, dir,
+		`{"type": "logistic", "intercept": -1, "weights": {"repository_similarity": 2}}`))
+	req.NoError(err)
+	probability, err := scorer.Score(PairFeatures{RepositorySimilarity: 0.5})
+	req.NoError(err)
+	req.InDelta(0.5, probability, 1e-9)
+
+	scorer, err = LoadPairScorer(writeTestModel(t, dir, `{"type": "gbm", "intercept": 0.5, "trees": [
+		{"nodes": [{"feature": "name_distance", "threshold": 0.3, "left": 1, "right": 2},
+			{"value": 1}, {"value": -2}]},
+		{"nodes": [{"value": -0.5}]}]}`))
+	req.NoError(err)
+	probability, err = scorer.Score(PairFeatures{NameDistance: 0.1})
+	req.NoError(err)
+	req.InDelta(sigmoid(1), probability, 1e-9)
+	probability, err = scorer.Score(PairFeatures{NameDistance: 0.5})
+	req.NoError(err)
+	req.InDelta(sigmoid(-2), probability, 1e-9)
+
+	scorer, err = LoadPairScorer(writeTestModel(t, dir, `{"type": "gbm", "trees": [
+		{"nodes": [{"feature": "name_distance", "threshold": 0.3, "left": 0, "right": 5}]}]}`))
+	req.NoError(err)
+	_, err = scorer.Score(PairFeatures{NameDistance: 0.5})
+	req.Error(err)
+	_, err = scorer.Score(PairFeatures{NameDistance: 0.1})
+	req.Error(err)
+
+	for _, invalid := range []string{
+		`{"type": "svm"}`,
+		`{"type": "logistic", "weights": {"height": 1}}`,
+		`{"type": "gbm", "trees": [{"nodes": []}]}`,
+		`{"type": "gbm", "trees": [{"nodes": [{"feature": "height"}]}]}`,
+		`{`,
+	} {
+		_, err = LoadPairScorer(writeTestModel(t, dir, invalid))
+		req.Error(err, invalid)
+	}
+	_, err = LoadPairScorer(filepath.Join(dir, "missing.json"))
+	req.Error(err)
+}
+
+func TestBuildIdentityGraphClassifier(t *testing.T) {
+	req := require.New(t)
+	opts := ReduceOptions{
+		MaxIdentities:      100,
+		MinEdgeWeight:      1.5,
+		MinPairProbability: 0.6,
+		PairScorer: &LogisticRegression{
+			Intercept: -2, Weights: map[string]float64{"repository_similarity": 4}},
+	}
+	g, err := BuildIdentityGraph(context.Background(), newRepositoryTestPeople(), nil,
+		newTestBlacklist(t), opts)
+	req.NoError(err)
+	edge, _ := g.Edge(1, 2)
+	req.Equal([]Evidence{{EvidenceName, "alice", 1}, {EvidenceClassifier, "0.88", sigmoid(2)}},
+		edge.Evidence)
+	edge, _ = g.Edge(3, 4)
+	req.Equal([]Evidence{{EvidenceName, "bob", 1}}, edge.Evidence)
+	req.Equal([][]int64{{1, 2}, {3}, {4}, {5}, {6}}, g.Components())
+}
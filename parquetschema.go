@@ -0,0 +1,81 @@
+package idmatch
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/src-d/identity-matching/reporter"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetSchemaVersion is the version of the schema of the identities parquet files which
+// People.WriteToParquet writes. Increment it together with a new migration in parquetMigrations
+// whenever the meaning of the columns changes. The new trailing columns alone do not need
+// a version because newCompatibleParquetReader reads the files without them.
+const parquetSchemaVersion = 1
+
+// parquetSchemaVersionKey is the key of the file metadata with the schema version. The files
+// written before the key existed have version 0.
+const parquetSchemaVersionKey = "identity_matching.schema_version"
+
+// parquetMigration upgrades the rows read from the files of a schema version to the next one.
+type parquetMigration func(aliases []parquetPersonAlias, identities []parquetPersonIdentity) error
+
+// parquetMigrations[v] upgrades version v to v+1, so that readFromParquet reads all the older
+// versions.
+var parquetMigrations = []parquetMigration{
+	migrateParquetExternalIDs,
+}
+
+// migrateParquetExternalIDs fills the external_ids column from the primary external ID of
+// the files written before the column existed.
+func migrateParquetExternalIDs(_ []parquetPersonAlias, identities []parquetPersonIdentity) error {
+	for i, identity := range identities {
+		if identity.ExternalIDs == "" && identity.ExternalID != "" {
+			identities[i].ExternalIDs = ExternalIDs{identity.ExternalIDProvider: identity.ExternalID}.String()
+		}
+	}
+	return nil
+}
+
+// setParquetSchemaVersion records the schema version in the metadata of the file.
+func setParquetSchemaVersion(pw *writer.ParquetWriter, version int) {
+	value := strconv.Itoa(version)
+	pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata,
+		&parquet.KeyValue{Key: parquetSchemaVersionKey, Value: &value})
+}
+
+// getParquetSchemaVersion returns the schema version recorded in the metadata of the file or 0.
+func getParquetSchemaVersion(footer *parquet.FileMetaData) (int, error) {
+	for _, kv := range footer.GetKeyValueMetadata() {
+		if kv.Key != parquetSchemaVersionKey || kv.Value == nil {
+			continue
+		}
+		version, err := strconv.Atoi(*kv.Value)
+		if err != nil || version < 0 {
+			return 0, fmt.Errorf("invalid parquet schema version: %s", *kv.Value)
+		}
+		return version, nil
+	}
+	return 0, nil
+}
+
+// migrateParquetPeople upgrades the rows of the file of the given schema version to
+// parquetSchemaVersion. The files of the newer versions are read as far as their columns are
+// known.
+func migrateParquetPeople(path string, version int, aliases []parquetPersonAlias,
+	identities []parquetPersonIdentity) error {
+	if version > parquetSchemaVersion {
+		reporter.Warnf("%s has the parquet schema version %d which is newer than %d, the unknown "+
+			"columns are ignored", path, version, parquetSchemaVersion)
+		return nil
+	}
+	for ; version < parquetSchemaVersion; version++ {
+		if err := parquetMigrations[version](aliases, identities); err != nil {
+			return fmt.Errorf("failed to migrate %s from the parquet schema version %d: %v",
+				path, version, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// parquetPersonAliasFuture is parquetPersonAlias with a column of a future schema version.
+type parquetPersonAliasFuture struct {
+	ID        int64  `parquet:"name=id, type=INT_64"`
+	Email     string `parquet:"name=email, type=UTF8"`
+	Name      string `parquet:"name=name, type=UTF8"`
+	Repo      string `parquet:"name=repo, type=UTF8"`
+	Sources   string `parquet:"name=sources, type=UTF8"`
+	FirstSeen int64  `parquet:"name=first_seen, type=TIMESTAMP_MILLIS"`
+	LastSeen  int64  `parquet:"name=last_seen, type=TIMESTAMP_MILLIS"`
+	Verified  bool   `parquet:"name=verified, type=BOOLEAN"`
+}
+
+func readTestParquetSchemaVersion(t *testing.T, path string) int {
+	file, err := openParquetFile(path)
+	require.NoError(t, err)
+	defer file.Close()
+	pr, err := reader.NewParquetReader(file, nil, 1)
+	require.NoError(t, err)
+	version, err := getParquetSchemaVersion(pr.Footer)
+	require.NoError(t, err)
+	return version
+}
+
+func TestParquetSchemaVersion(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-schema")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people := People{1: {ID: 1, Emails: []string{"bob@google.com"}, ExternalIDs: ExternalIDs{"github": "bob"}}}
+	path := filepath.Join(dir, "identities.parquet")
+	req.NoError(people.WriteToParquet(path, "github"))
+	pathAliases, pathIDs := preparePaths(path)
+	req.Equal(parquetSchemaVersion, readTestParquetSchemaVersion(t, pathAliases))
+	req.Equal(parquetSchemaVersion, readTestParquetSchemaVersion(t, pathIDs))
+
+	version := "x"
+	_, err = getParquetSchemaVersion(&parquet.FileMetaData{
+		KeyValueMetadata: []*parquet.KeyValue{{Key: parquetSchemaVersionKey, Value: &version}}})
+	req.EqualError(err, "invalid parquet schema version: x")
+	number, err := getParquetSchemaVersion(&parquet.FileMetaData{})
+	req.NoError(err)
+	req.Equal(0, number)
+}
+
+func TestReadParquetFromTheFuture(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-schema")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people := People{
+		1: {ID: 1, Emails: []string{"bob@google.com"}, PrimaryName: "bob", ExternalIDs: ExternalIDs{"github": "bob"}},
+	}
+	path := filepath.Join(dir, "identities.parquet")
+	req.NoError(people.WriteToParquet(path, "github"))
+	pathAliases, _ := preparePaths(path)
+	func() {
+		pw, cleanup := newParquetWriter(pathAliases, new(parquetPersonAliasFuture))
+		defer cleanup()
+		setParquetSchemaVersion(pw, parquetSchemaVersion+1)
+		req.NoError(pw.Write(parquetPersonAliasFuture{ID: 1, Email: "bob@google.com", Verified: true}))
+		req.NoError(pw.Write(parquetPersonAliasFuture{ID: 1, Name: "bob"}))
+	}()
+	stored, provider, err := readFromParquet(path)
+	req.NoError(err)
+	req.Equal("github", provider)
+	req.Len(stored, 1)
+	req.Equal([]string{"bob@google.com"}, stored[1].Emails)
+	req.Equal([]NameWithRepo{{"bob", ""}}, stored[1].NamesWithRepos)
+	req.Equal(ExternalIDs{"github": "bob"}, stored[1].ExternalIDs)
+}
+
+func TestMigrateParquetPeople(t *testing.T) {
+	req := require.New(t)
+	req.Len(parquetMigrations, parquetSchemaVersion)
+	identities := []parquetPersonIdentity{
+		{ID: 1, ExternalIDProvider: "github", ExternalID: "bob"},
+		{ID: 2},
+		{ID: 3, ExternalIDProvider: "github", ExternalID: "eve", ExternalIDs: "github:eve,gitlab:eve2"},
+	}
+	req.NoError(migrateParquetPeople("test", 0, nil, identities))
+	req.Equal("github:bob", identities[0].ExternalIDs)
+	req.Equal("", identities[1].ExternalIDs)
+	req.Equal("github:eve,gitlab:eve2", identities[2].ExternalIDs)
+
+	identities = []parquetPersonIdentity{{ID: 1, ExternalIDProvider: "github", ExternalID: "bob"}}
+	req.NoError(migrateParquetPeople("test", parquetSchemaVersion, nil, identities))
+	req.Equal("", identities[0].ExternalIDs)
+	req.NoError(migrateParquetPeople("test", parquetSchemaVersion+1, nil, identities))
+}
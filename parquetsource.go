@@ -0,0 +1,226 @@
+package idmatch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// parquetBatchSize is the number of rows which are read from each column at once.
+const parquetBatchSize = 10 * progressInterval
+
+// julianDayOfUnixEpoch is the Julian day number of 1970-01-01, used by INT96 timestamps.
+const julianDayOfUnixEpoch = 2440588
+
+// parquetColumn is a top level column of the parquet file.
+type parquetColumn struct {
+	path    string
+	element *parquet.SchemaElement
+}
+
+// findParquetColumns resolves the signature fields to the columns of the parquet file.
+// columnMapping maps the fields - the columns of the signatures cache "repo", "name", "email",
+// "hash", "time", "source" and "role" - to the column names in the file. The fields which are not
+// mapped are expected to have the same names. The "source" and "role" columns are optional unless
+// they are mapped.
+func findParquetColumns(pr *reader.ParquetReader, columnMapping map[string]string) (
+	map[string]parquetColumn, error) {
+	for field := range columnMapping {
+		known := false
+		for _, name := range signatureCSVHeader {
+			known = known || name == field
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown signature field %s, the supported fields are %v",
+				field, signatureCSVHeader)
+		}
+	}
+	elements := pr.SchemaHandler.SchemaElements
+	byName := map[string]parquetColumn{}
+	// the first element is the root and its children are the top level columns
+	for i := 1; i < len(elements); i++ {
+		if elements[i].GetNumChildren() == 0 {
+			byName[elements[i].GetName()] = parquetColumn{
+				path: pr.SchemaHandler.IndexMap[int32(i)], element: elements[i]}
+		}
+	}
+	columns := map[string]parquetColumn{}
+	for index, field := range signatureCSVHeader {
+		name, mapped := columnMapping[field]
+		if !mapped {
+			name = field
+		}
+		column, exists := byName[name]
+		// the fields after "time" are optional
+		if !exists && index >= requiredSignatureFields && !mapped {
+			continue
+		}
+		if !exists {
+			return nil, fmt.Errorf("column %s of the signature field %s does not exist", name, field)
+		}
+		columns[field] = column
+	}
+	return columns, nil
+}
+
+// parquetString converts the value of a BYTE_ARRAY column.
+func parquetString(value interface{}) (string, bool) {
+	str, ok := value.(string)
+	return str, ok
+}
+
+// parquetTime converts the value of a timestamp column. TIMESTAMP_MILLIS, TIMESTAMP_MICROS,
+// INT96, RFC3339 strings and plain INT64 Unix seconds are supported.
+func parquetTime(value interface{}, element *parquet.SchemaElement) (time.Time, bool) {
+	switch typed := value.(type) {
+	case int64:
+		if !element.IsSetConvertedType() {
+			return time.Unix(typed, 0).UTC(), true
+		}
+		switch element.GetConvertedType() {
+		case parquet.ConvertedType_TIMESTAMP_MILLIS:
+			return time.Unix(typed/1000, typed%1000*int64(time.Millisecond)).UTC(), true
+		case parquet.ConvertedType_TIMESTAMP_MICROS:
+			return time.Unix(typed/1000000, typed%1000000*int64(time.Microsecond)).UTC(), true
+		}
+	case string:
+		if element.GetType() == parquet.Type_INT96 {
+			if len(typed) != 12 {
+				return time.Time{}, false
+			}
+			nanos := int64(binary.LittleEndian.Uint64([]byte(typed[:8])))
+			days := int64(binary.LittleEndian.Uint32([]byte(typed[8:])))
+			return time.Unix((days-julianDayOfUnixEpoch)*24*60*60, nanos).UTC(), true
+		}
+		parsed, err := time.Parse(time.RFC3339, typed)
+		return parsed, err == nil
+	}
+	return time.Time{}, false
+}
+
+// readSignaturesFromParquet ingests the commits table stored in the parquet file.
+// columnMapping configures the names of the columns, see findParquetColumns. The rows are
+// normalized the same way as the cached signatures and deduplicated by repository, name and
+// email with signatureAggregator, so the table may contain a row per commit. The parquet
+// signatures cache written by storeSignaturesToParquet is read as is.
+func readSignaturesFromParquet(prog *progress, path string, columnMapping map[string]string) (
+	[]Signature, error) {
+	file, err := openParquetFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			reporter.Warnf("failed to close %s: %v", path, err)
+		}
+	}()
+	pr, err := reader.NewParquetColumnReader(file, int64(runtime.NumCPU()))
+	if err != nil {
+		return nil, err
+	}
+	defer pr.ReadStop()
+	normalized := isParquetSignatureCache(pr)
+	if normalized {
+		columnMapping = nil
+	}
+	columns, err := findParquetColumns(pr, columnMapping)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	repos := map[string]signatureAggregator{}
+	var signatures []Signature
+	stage := prog.stage("reading signatures", int(pr.GetNumRows()))
+	defer stage.done()
+	for offset := 0; offset < int(pr.GetNumRows()); offset += parquetBatchSize {
+		size := int(pr.GetNumRows()) - offset
+		if size > parquetBatchSize {
+			size = parquetBatchSize
+		}
+		batch := map[string][]interface{}{}
+		for field, column := range columns {
+			// ReadColumnByPath swallows the read errors and returns fewer values instead
+			batch[field], _, _ = pr.ReadColumnByPath(column.path, parquetBatchSize)
+			if len(batch[field]) < size {
+				return nil, withKind(ErrInvalidSignature, fmt.Errorf(
+					"%s: failed to read column %s at row %d", path, column.path, offset))
+			}
+		}
+		for i := range batch["repo"] {
+			if err := stage.tick(); err != nil {
+				return nil, err
+			}
+			cell := func(field string) interface{} {
+				if i < len(batch[field]) {
+					return batch[field][i]
+				}
+				return nil
+			}
+			var values [4]string
+			valid := true
+			for j, field := range signatureCSVHeader[:4] {
+				if values[j], valid = parquetString(cell(field)); !valid {
+					break
+				}
+				if !normalized {
+					if values[j], err = normalizeSignatureValue(values[j]); err != nil {
+						return nil, err
+					}
+				}
+				if valid = values[j] != ""; !valid {
+					break
+				}
+			}
+			var when time.Time
+			if valid {
+				when, valid = parquetTime(cell("time"), columns["time"].element)
+			}
+			if !valid {
+				reporter.Warnf("invalid parquet row %d in %s: %v", offset+i, path, values)
+				continue
+			}
+			source, _ := parquetString(cell("source"))
+			role, _ := parquetString(cell("role"))
+			signingKey, _ := parquetString(cell("signing_key"))
+			rawActivity, _ := parquetString(cell("activity"))
+			activity, err := parseActivity(strings.TrimSpace(rawActivity))
+			if err != nil {
+				reporter.Warnf("invalid parquet row %d in %s: %v", offset+i, path, err)
+				continue
+			}
+			signature := Signature{
+				Repo:   values[0],
+				Name:   values[1],
+				Email:  values[2],
+				Hash:   values[3],
+				Time:   when,
+				Source: SourceKind(strings.TrimSpace(source)),
+				Role:   SignatureRole(strings.TrimSpace(role)),
+
+				SigningKey: strings.ToLower(strings.TrimSpace(signingKey)),
+				Activity:   activity,
+			}
+			if normalized {
+				signatures = append(signatures, signature)
+				continue
+			}
+			aggregator := repos[values[0]]
+			if aggregator == nil {
+				aggregator = signatureAggregator{}
+				repos[values[0]] = aggregator
+			}
+			aggregator.add(signature)
+		}
+	}
+	if normalized {
+		return signatures, nil
+	}
+	return aggregatedSignatures(repos), nil
+}
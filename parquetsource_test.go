@@ -0,0 +1,113 @@
+package idmatch
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go/parquet"
+)
+
+type testParquetCommit struct {
+	Repo  string `parquet:"name=repository_id, type=UTF8"`
+	Name  string `parquet:"name=commit_author_name, type=UTF8"`
+	Email string `parquet:"name=commit_author_email, type=UTF8"`
+	Hash  string `parquet:"name=hash, type=UTF8"`
+	When  int64  `parquet:"name=commit_author_when, type=TIMESTAMP_MILLIS"`
+}
+
+func TestReadSignaturesFromParquet(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-parquet")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "commits.parquet")
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	millis := func(t time.Time) int64 {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+	pw, cleanup := newParquetWriter(path, new(testParquetCommit))
+	for _, commit := range []testParquetCommit{
+		{"repo2", "Bob", "bob@google.com", "bbb", millis(day)},
+		{"repo1", "Alice ", "alice@google.com", "aaa", millis(day)},
+		{"repo1", "alice", "Alice@google.com", "ccc", millis(day.Add(-time.Hour))},
+		{"repo1", "", "eve@google.com", "ddd", millis(day)},
+	} {
+		req.NoError(pw.Write(commit))
+	}
+	cleanup()
+
+	mapping := map[string]string{
+		"repo": "repository_id", "name": "commit_author_name", "email": "commit_author_email",
+		"time": "commit_author_when",
+	}
+	signatures, err := readSignaturesFromParquet(nil, path, mapping)
+	req.NoError(err)
+	req.Equal([]Signature{
+		{"repo1", "alice", "alice@google.com", "ccc", day, "", "", "", nil, nil},
+		{"repo2", "bob", "bob@google.com", "bbb", day, "", "", "", nil, nil},
+	}, signatures)
+
+	signatures, err = findSignatures(nil, "", path, ExtractionOptions{ParquetColumns: mapping})
+	req.NoError(err)
+	req.Len(signatures, 2)
+
+	_, err = readSignaturesFromParquet(nil, path, nil)
+	req.EqualError(err, path+": column repo of the signature field repo does not exist")
+	_, err = readSignaturesFromParquet(nil, path, map[string]string{"author": "x"})
+	req.Error(err)
+	_, err = readSignaturesFromParquet(nil, filepath.Join(dir, "missing.parquet"), mapping)
+	req.Error(err)
+
+	// the footer is intact, so the column pages fail only when they are read
+	data, err := ioutil.ReadFile(path)
+	req.NoError(err)
+	for i := 4; i < 64; i++ {
+		data[i] = 0xff
+	}
+	corrupted := filepath.Join(dir, "corrupted.parquet")
+	req.NoError(ioutil.WriteFile(corrupted, data, 0666))
+	_, err = readSignaturesFromParquet(nil, corrupted, mapping)
+	req.Error(err)
+	req.True(errors.Is(err, ErrInvalidSignature))
+}
+
+func TestParquetTime(t *testing.T) {
+	req := require.New(t)
+	moment := time.Date(2019, 3, 4, 5, 6, 7, 8000, time.UTC)
+	element := func(pt parquet.Type, ct *parquet.ConvertedType) *parquet.SchemaElement {
+		return &parquet.SchemaElement{Type: &pt, ConvertedType: ct}
+	}
+
+	parsed, ok := parquetTime(moment.UnixNano()/int64(time.Microsecond),
+		element(parquet.Type_INT64, parquet.ConvertedTypePtr(parquet.ConvertedType_TIMESTAMP_MICROS)))
+	req.True(ok)
+	req.Equal(moment, parsed)
+
+	parsed, ok = parquetTime(moment.Unix(), element(parquet.Type_INT64, nil))
+	req.True(ok)
+	req.Equal(moment.Truncate(time.Second), parsed)
+
+	int96 := make([]byte, 12)
+	binary.LittleEndian.PutUint64(int96, uint64((5*60+6)*60+7)*uint64(time.Second)+8000)
+	binary.LittleEndian.PutUint32(int96[8:], uint32(julianDayOfUnixEpoch+moment.Unix()/(24*60*60)))
+	parsed, ok = parquetTime(string(int96), element(parquet.Type_INT96, nil))
+	req.True(ok)
+	req.Equal(moment, parsed)
+
+	parsed, ok = parquetTime("2019-03-04T05:06:07Z", element(parquet.Type_BYTE_ARRAY, nil))
+	req.True(ok)
+	req.Equal(moment.Truncate(time.Second), parsed)
+
+	_, ok = parquetTime("yesterday", element(parquet.Type_BYTE_ARRAY, nil))
+	req.False(ok)
+	_, ok = parquetTime(int32(1), element(parquet.Type_INT32, nil))
+	req.False(ok)
+	_, ok = parquetTime(nil, element(parquet.Type_INT64, nil))
+	req.False(ok)
+}
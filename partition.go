@@ -0,0 +1,204 @@
+package idmatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// partitionManifestName is the file in the directory of the partitioned people which lists
+// the partitions.
+const partitionManifestName = "manifest.json"
+
+// PartitionOptions configure People.WritePartitionedParquet.
+type PartitionOptions struct {
+	// PeoplePerPartition is the maximum number of the people in each partition.
+	PeoplePerPartition int
+	// Workers is the number of the partitions written or read at the same time. 0 means
+	// the number of CPUs.
+	Workers int
+}
+
+// Partition is a pair of the parquet files of People.WriteToParquet with the people whose IDs
+// are between MinID and MaxID inclusive.
+type Partition struct {
+	// Path is relative to the directory of the manifest.
+	Path   string `json:"path"`
+	MinID  int64  `json:"min_id"`
+	MaxID  int64  `json:"max_id"`
+	People int    `json:"people"`
+}
+
+// PartitionManifest lists the partitions of the people in the order of their IDs.
+type PartitionManifest struct {
+	SchemaVersion      int         `json:"schema_version"`
+	ExternalIDProvider string      `json:"external_id_provider,omitempty"`
+	People             int         `json:"people"`
+	Partitions         []Partition `json:"partitions"`
+}
+
+// partitionPath joins the directory, which may be a blob URL, and the name.
+func partitionPath(dir, name string) string {
+	return strings.TrimSuffix(dir, "/") + "/" + name
+}
+
+// isPartitionedParquet returns whether the path is the directory written by
+// People.WritePartitionedParquet.
+func isPartitionedParquet(path string) bool {
+	exists, err := pathExists(partitionPath(path, partitionManifestName))
+	return err == nil && exists
+}
+
+// WritePartitionedParquet splits the people into the ranges of their IDs with at most
+// opts.PeoplePerPartition people each and writes every range with People.WriteToParquet to
+// the directory, together with manifest.json, see PartitionManifest. The partitions are written
+// in parallel, and ReadIdentitiesFromParquet reads them in parallel, too. The directory may be
+// a blob URL.
+func (p People) WritePartitionedParquet(dir string, externalIDProvider string,
+	opts PartitionOptions) (err error) {
+	if opts.PeoplePerPartition <= 0 {
+		return fmt.Errorf("the number of people per partition must be positive: %d",
+			opts.PeoplePerPartition)
+	}
+	if !isBlobURL(dir) {
+		if err = os.MkdirAll(dir, 0777); err != nil {
+			return err
+		}
+	}
+	ids := peopleIDs(p)
+	manifest := PartitionManifest{
+		SchemaVersion:      parquetSchemaVersion,
+		ExternalIDProvider: externalIDProvider,
+		People:             len(p),
+	}
+	var parts []People
+	for start := 0; start < len(ids); start += opts.PeoplePerPartition {
+		end := start + opts.PeoplePerPartition
+		if end > len(ids) {
+			end = len(ids)
+		}
+		part := make(People, end-start)
+		for _, id := range ids[start:end] {
+			part[id] = p[id]
+		}
+		parts = append(parts, part)
+		manifest.Partitions = append(manifest.Partitions, Partition{
+			Path:   fmt.Sprintf("part-%05d.parquet", len(manifest.Partitions)),
+			MinID:  ids[start],
+			MaxID:  ids[end-1],
+			People: end - start,
+		})
+	}
+	err = forEachPartition(len(parts), opts.Workers, func(i int) error {
+		return parts[i].WriteToParquet(partitionPath(dir, manifest.Partitions[i].Path), externalIDProvider)
+	})
+	if err != nil {
+		return err
+	}
+	// the manifest is written last, so that the incomplete directory is never read
+	var file io.WriteCloser
+	file, err = CreatePath(partitionPath(dir, partitionManifestName))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
+
+// ReadPartitionManifest reads manifest.json of the directory written by
+// People.WritePartitionedParquet.
+func ReadPartitionManifest(dir string) (manifest PartitionManifest, err error) {
+	var file io.ReadCloser
+	file, err = OpenPath(partitionPath(dir, partitionManifestName))
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	var data []byte
+	if data, err = ioutil.ReadAll(file); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		err = fmt.Errorf("%s: %v", partitionPath(dir, partitionManifestName), err)
+	}
+	return
+}
+
+// readPartitionedParquet reads all the partitions in the directory with the given number of
+// workers, 0 means the number of CPUs, and returns the people and the external ID provider.
+func readPartitionedParquet(dir string, workers int) (People, string, error) {
+	manifest, err := ReadPartitionManifest(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	parts := make([]People, len(manifest.Partitions))
+	err = forEachPartition(len(parts), workers, func(i int) error {
+		var err error
+		parts[i], _, err = readFromParquet(partitionPath(dir, manifest.Partitions[i].Path))
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	people := make(People, manifest.People)
+	for i, part := range parts {
+		for id, person := range part {
+			if _, exists := people[id]; exists {
+				return nil, "", fmt.Errorf("person %d is in several partitions, the last is %s",
+					id, manifest.Partitions[i].Path)
+			}
+			people[id] = person
+		}
+	}
+	return people, manifest.ExternalIDProvider, nil
+}
+
+// forEachPartition calls f with the indexes from 0 to n-1 in the given number of goroutines,
+// 0 means the number of CPUs, and returns the first error.
+func forEachPartition(n, workers int, f func(int) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	indexes := make(chan int, n)
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+	for w := 0; w < workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if err := f(i); err != nil {
+					lock.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return firstErr
+}
@@ -0,0 +1,78 @@
+package idmatch
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePartitionedParquet(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-partitions")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people := People{}
+	for _, id := range []int64{1, 2, 5, 7, 10} {
+		people[id] = &Person{ID: id, Emails: []string{string(rune('a'+id)) + "@google.com"},
+			NamesWithRepos: []NameWithRepo{{"bob", ""}}, ExternalIDs: ExternalIDs{"github": "bob"}}
+	}
+	path := filepath.Join(dir, "identities")
+	req.Error(people.WritePartitionedParquet(path, "github", PartitionOptions{}))
+	req.NoError(people.WritePartitionedParquet(path, "github", PartitionOptions{PeoplePerPartition: 2}))
+
+	manifest, err := ReadPartitionManifest(path)
+	req.NoError(err)
+	req.Equal(PartitionManifest{SchemaVersion: parquetSchemaVersion, ExternalIDProvider: "github", People: 5,
+		Partitions: []Partition{
+			{Path: "part-00000.parquet", MinID: 1, MaxID: 2, People: 2},
+			{Path: "part-00001.parquet", MinID: 5, MaxID: 7, People: 2},
+			{Path: "part-00002.parquet", MinID: 10, MaxID: 10, People: 1},
+		}}, manifest)
+	_, err = os.Stat(filepath.Join(path, "part-00001-identities.parquet"))
+	req.NoError(err)
+
+	stored, provider, err := ReadIdentitiesFromParquet(path, nil)
+	req.NoError(err)
+	req.Equal("github", provider)
+	req.Len(stored, 5)
+	for id, person := range people {
+		req.Equal(person.Emails, stored[id].Emails)
+		req.Equal(person.ExternalIDs, stored[id].ExternalIDs)
+	}
+	req.False(isPartitionedParquet(filepath.Join(dir, "missing")))
+}
+
+func TestWritePartitionedParquetEmpty(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-partitions")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	req.NoError(People{}.WritePartitionedParquet(dir, "", PartitionOptions{PeoplePerPartition: 10}))
+	stored, _, err := ReadIdentitiesFromParquet(dir, nil)
+	req.NoError(err)
+	req.Empty(stored)
+}
+
+func TestForEachPartition(t *testing.T) {
+	req := require.New(t)
+	done := make([]bool, 10)
+	req.NoError(forEachPartition(len(done), 3, func(i int) error {
+		done[i] = true
+		return nil
+	}))
+	for _, d := range done {
+		req.True(d)
+	}
+	failure := errors.New("failed")
+	req.Equal(failure, forEachPartition(5, 0, func(i int) error {
+		if i == 3 {
+			return failure
+		}
+		return nil
+	}))
+	req.NoError(forEachPartition(0, 2, func(int) error { return failure }))
+}
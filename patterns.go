@@ -0,0 +1,63 @@
+package idmatch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PatternSet is a set of regular expressions compiled into a single automaton, so that checking
+// a string against all the patterns costs one match.
+type PatternSet struct {
+	patterns []string
+	regexp   *regexp.Regexp
+}
+
+// NewPatternSet compiles the given regular expressions. The matching is case-insensitive and
+// not anchored, e.g. "^jenkins" matches "Jenkins CI" and "-bot@" matches "ci-bot@company.com".
+func NewPatternSet(patterns ...string) (*PatternSet, error) {
+	patterns = unique(patterns)
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	groups := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		groups[i] = "(?:" + pattern + ")"
+	}
+	re, err := regexp.Compile("(?i)" + strings.Join(groups, "|"))
+	if err != nil {
+		return nil, err
+	}
+	return &PatternSet{patterns: patterns, regexp: re}, nil
+}
+
+// Patterns returns the sorted source regular expressions.
+func (s *PatternSet) Patterns() []string {
+	if s == nil {
+		return nil
+	}
+	return s.patterns
+}
+
+// MatchString reports whether the string matches any of the patterns. A nil set matches nothing.
+func (s *PatternSet) MatchString(str string) bool {
+	if s == nil {
+		return false
+	}
+	return s.regexp.MatchString(str)
+}
+
+// Merge returns the set which contains the patterns of both sets.
+func (s *PatternSet) Merge(other *PatternSet) *PatternSet {
+	merged, err := NewPatternSet(append(append([]string{}, s.Patterns()...), other.Patterns()...)...)
+	if err != nil {
+		// both sets have already been compiled so this cannot happen
+		logrus.Panicf("failed to merge the pattern sets: %v", err)
+	}
+	return merged
+}
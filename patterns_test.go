@@ -0,0 +1,44 @@
+package idmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternSet(t *testing.T) {
+	require := require.New(t)
+	set, err := NewPatternSet(`.*-bot@.*`, `^jenkins`, `^noreply@`, `^jenkins`)
+	require.NoError(err)
+	require.Equal([]string{`.*-bot@.*`, `^jenkins`, `^noreply@`}, set.Patterns())
+	for _, s := range []string{"ci-bot@company.com", "Jenkins CI", "noreply@github.com"} {
+		require.True(set.MatchString(s), s)
+	}
+	for _, s := range []string{"bot@company.com", "mr jenkins", "x-noreply@github.com"} {
+		require.False(set.MatchString(s), s)
+	}
+
+	var empty *PatternSet
+	require.False(empty.MatchString("anything"))
+	require.Nil(empty.Patterns())
+	set, err = NewPatternSet()
+	require.NoError(err)
+	require.Nil(set)
+
+	_, err = NewPatternSet(`(unclosed`)
+	require.Error(err)
+}
+
+func TestPatternSetMerge(t *testing.T) {
+	require := require.New(t)
+	set1, err := NewPatternSet(`^a`)
+	require.NoError(err)
+	set2, err := NewPatternSet(`^b`)
+	require.NoError(err)
+	merged := set1.Merge(set2)
+	require.Equal([]string{`^a`, `^b`}, merged.Patterns())
+	require.True(merged.MatchString("bob"))
+	require.Equal([]string{`^a`}, set1.Merge(nil).Patterns())
+	var empty *PatternSet
+	require.Nil(empty.Merge(nil))
+}
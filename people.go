@@ -2,56 +2,91 @@ package idmatch
 
 import (
 	"context"
-	"database/sql"
 	"encoding/csv"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"hash/fnv"
 	"io"
+	"math"
 	"os"
+	"reflect"
 	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/briandowns/spinner"
 	"github.com/sirupsen/logrus"
-	"github.com/xitongsys/parquet-go-source/local"
 	"github.com/xitongsys/parquet-go/parquet"
 	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
 	"github.com/xitongsys/parquet-go/writer"
 
 	"github.com/src-d/identity-matching/reporter"
 )
 
-// signatureWithRepo is a Git commit signature in a particular repository.
-type signatureWithRepo struct {
-	repo  string
-	name  string
-	email string
-	hash  string
-	time  time.Time
+// Signature is the name and the email of a commit author or of another contribution, such as
+// a mailing list message, in a particular repository.
+type Signature struct {
+	Repo  string
+	Name  string
+	Email string
+	Hash  string
+	Time  time.Time
+	// Source is the kind of the origin of the signature. It is empty if unknown.
+	Source SourceKind
+	// Role distinguishes the commit authors from the committers. The empty value means RoleAuthor.
+	Role SignatureRole
+	// SigningKey is the ID of the GPG key which signed the commit, see pgpKeyID. It is empty if
+	// the commit is not signed or the key does not belong to this person.
+	SigningKey string
+	// Activity is the histogram of the local commit hours and UTC offsets. It is nil if
+	// the source does not know the time zones.
+	Activity *Activity
+	// Mirrors are the other repositories with the same commit, see
+	// ExtractionOptions.DedupCommits. They are not stored in the signatures cache.
+	Mirrors []string
 }
 
-func (swr signatureWithRepo) String() string {
-	repo := swr.repo
+// SignatureRole is the role of the person in the commit.
+type SignatureRole string
+
+const (
+	// RoleAuthor is the author of the change.
+	RoleAuthor SignatureRole = "author"
+	// RoleCommitter applied the change, e.g. merged the pull request. Committers are often bots.
+	RoleCommitter SignatureRole = "committer"
+	// RoleCoAuthor is mentioned in the "Co-authored-by:" trailer of the commit message.
+	RoleCoAuthor SignatureRole = "co-author"
+	// RoleSignedOffBy is mentioned in the "Signed-off-by:" trailer of the commit message.
+	RoleSignedOffBy SignatureRole = "signed-off-by"
+	// RoleReviewedBy is mentioned in the "Reviewed-by:" trailer of the commit message.
+	RoleReviewedBy SignatureRole = "reviewed-by"
+	// RoleTestedBy is mentioned in the "Tested-by:" trailer of the commit message.
+	RoleTestedBy SignatureRole = "tested-by"
+)
+
+func (swr Signature) String() string {
+	repo := swr.Repo
 	if repo == "" {
 		repo = "<no repo>"
 	}
-	name := swr.name
+	name := swr.Name
 	if name == "" {
 		name = "<no name>"
 	}
-	email := swr.email
+	email := swr.Email
 	if email == "" {
 		email = "<no email>"
 	}
-	hash := swr.hash
+	hash := swr.Hash
 	if hash == "" {
 		hash = "<no hash>"
 	}
-	return "[" + strings.Join([]string{repo, name, email, hash, swr.time.String()}, " ") + "]"
+	return "[" + strings.Join([]string{repo, name, email, hash, swr.Time.String()}, " ") + "]"
 }
 
 // NameWithRepo is a Name that can be linked to a specific repo.
@@ -64,6 +99,8 @@ type NameWithRepo struct {
 type Commit struct {
 	Hash string
 	Repo string
+	// Time is the time of the signature with the commit. May be zero.
+	Time time.Time
 }
 
 // Person is a single individual that can have multiple names and emails.
@@ -73,9 +110,129 @@ type Person struct {
 	Emails         []string
 	// SampleCommit in an example Git commit which mentions this identity. May be nil.
 	SampleCommit *Commit
-	ExternalID   string
+	// SampleCommits are the representative commits of the merged identities, see
+	// SampleCommitOptions. The first is SampleCommit.
+	SampleCommits []Commit
+	// ExternalIDs are the IDs of the person at the external identity providers. May be nil.
+	ExternalIDs  ExternalIDs
 	PrimaryName  string
 	PrimaryEmail string
+	// IsBot indicates that the identity belongs to an automated account.
+	IsBot bool
+	// ExternalAccounts are all the external IDs of the person with their providers, including
+	// ExternalIDs and the IDs of the same providers which lost the conflicts, if the conflicting
+	// external IDs are kept, see ExternalIDConflictKeepBoth. Otherwise it is nil.
+	ExternalAccounts []ExternalAccount
+	// MergeEvidence are the edges between the signatures which were merged into this person.
+	// It is recorded only if ReduceOptions.ExplainMerges is set, see People.ExplainMerge.
+	MergeEvidence []IdentityEdge
+	// EmailSources and NameSources map the emails and the names to the sorted kinds of
+	// the sources which contributed them, so that the evidence can be weighed per source.
+	// The signatures without Signature.Source are not tracked.
+	EmailSources map[string][]SourceKind
+	NameSources  map[string][]SourceKind
+	// TrailerRole is set if the identity is known only from an optional commit message trailer,
+	// such as "Signed-off-by:". The evidence which involves such identities is weak and has
+	// the kind of the role, see IdentityGraph.addEvidence. Merge keeps it only if all the merged
+	// identities have the same role.
+	TrailerRole SignatureRole
+	// SigningKeys are the sorted IDs of the GPG keys which signed the commits of this person,
+	// see Signature.SigningKey.
+	SigningKeys []string
+	// Activity is the summed activity of the signatures, see Signature.Activity. May be nil.
+	Activity *Activity
+	// Repositories are the sorted repositories of the signatures.
+	Repositories []string
+	// FirstCommit and LastCommit are the times of the earliest and the latest signatures.
+	// The sources aggregate the commits with the same signature and keep the latest time, so
+	// FirstCommit is the latest commit of the earliest signature.
+	FirstCommit time.Time
+	LastCommit  time.Time
+	// Commits is the number of the commits of the signatures: Activity.Commits() if known,
+	// otherwise one per signature. RecentCommits counts the signatures in the recent months,
+	// see FindPeople.
+	Commits       int
+	RecentCommits int
+	// AliasHistory is the time range of each email and name, see AliasHistory.
+	AliasHistory AliasHistory
+}
+
+// Copy returns the deep copy of the person which is not affected by merging the original.
+func (p *Person) Copy() *Person {
+	clone := *p
+	clone.NamesWithRepos = append([]NameWithRepo(nil), p.NamesWithRepos...)
+	clone.Emails = append([]string(nil), p.Emails...)
+	clone.ExternalIDs = p.ExternalIDs.Copy()
+	clone.ExternalAccounts = append([]ExternalAccount(nil), p.ExternalAccounts...)
+	clone.MergeEvidence = append([]IdentityEdge(nil), p.MergeEvidence...)
+	clone.EmailSources = copySources(p.EmailSources)
+	clone.NameSources = copySources(p.NameSources)
+	clone.SigningKeys = append([]string(nil), p.SigningKeys...)
+	clone.Activity = p.Activity.copy()
+	clone.Repositories = append([]string(nil), p.Repositories...)
+	clone.AliasHistory = p.AliasHistory.copy()
+	if p.SampleCommit != nil {
+		commit := *p.SampleCommit
+		clone.SampleCommit = &commit
+	}
+	clone.SampleCommits = append([]Commit(nil), p.SampleCommits...)
+	return &clone
+}
+
+func copySources(sources map[string][]SourceKind) map[string][]SourceKind {
+	if sources == nil {
+		return nil
+	}
+	result := make(map[string][]SourceKind, len(sources))
+	for key, kinds := range sources {
+		result[key] = append([]SourceKind(nil), kinds...)
+	}
+	return result
+}
+
+// addSources records that the sources contributed the key. It returns the updated map,
+// which is created if it is nil and there is anything to record.
+func addSources(sources map[string][]SourceKind, key string,
+	kinds ...SourceKind) map[string][]SourceKind {
+	for _, kind := range kinds {
+		if kind == "" {
+			continue
+		}
+		if sources == nil {
+			sources = map[string][]SourceKind{}
+		}
+		existing := sources[key]
+		index := sort.Search(len(existing), func(i int) bool { return existing[i] >= kind })
+		if index < len(existing) && existing[index] == kind {
+			continue
+		}
+		existing = append(existing, "")
+		copy(existing[index+1:], existing[index:])
+		existing[index] = kind
+		sources[key] = existing
+	}
+	return sources
+}
+
+// formatSources joins the source kinds with commas.
+func formatSources(kinds []SourceKind) string {
+	strs := make([]string, len(kinds))
+	for i, kind := range kinds {
+		strs[i] = string(kind)
+	}
+	return strings.Join(strs, ",")
+}
+
+// parseSources is the inverse of formatSources.
+func parseSources(str string) []SourceKind {
+	if str == "" {
+		return nil
+	}
+	var kinds []SourceKind
+	for _, kind := range strings.Split(str, ",") {
+		kinds = append(kinds, SourceKind(kind))
+	}
+	return kinds
 }
 
 func uniqueNamesWithRepo(names []NameWithRepo) []NameWithRepo {
@@ -112,7 +269,7 @@ func (p Person) String() string {
 	}
 	sort.Strings(namesWithRepos)
 	sort.Strings(p.Emails)
-	extid := p.ExternalID
+	extid := p.ExternalIDs.String()
 	if extid == "" {
 		extid = "<no external id>"
 	}
@@ -123,23 +280,28 @@ func (p Person) String() string {
 // People is a map of persons indexed by their ID.
 type People map[int64]*Person
 
-func newPeople(commits []signatureWithRepo, blacklist Blacklist) (People, error) {
+func newPeople(prog *progress, commits []Signature, blacklist Blacklist) (People, error) {
 	result := make(People)
 	var id int64
 	var nameWithRepo NameWithRepo
 
+	stage := prog.stage("filtering signatures", len(commits))
+	defer stage.done()
 	for _, p := range commits {
-		name, err := cleanName(p.name)
-		if err != nil {
+		if err := stage.tick(); err != nil {
 			return nil, err
 		}
-		email, err := cleanEmail(p.email)
+		name, err := cleanName(p.Name)
 		if err != nil {
-			return nil, err
+			return nil, withKind(ErrInvalidSignature, err)
+		}
+		email, err := cleanEmail(p.Email)
+		if err != nil {
+			return nil, withKind(ErrInvalidSignature, err)
 		}
 		if blacklist.isPopularName(name) {
 			reporter.Increment("popular names")
-			nameWithRepo = NameWithRepo{name, p.repo}
+			nameWithRepo = NameWithRepo{name, p.Repo}
 		} else {
 			nameWithRepo = NameWithRepo{name, ""}
 		}
@@ -161,7 +323,29 @@ func newPeople(commits []signatureWithRepo, blacklist Blacklist) (People, error)
 			ID:             id,
 			NamesWithRepos: []NameWithRepo{nameWithRepo},
 			Emails:         []string{email},
-			SampleCommit:   &Commit{p.hash, p.repo},
+			SampleCommit:   &Commit{p.Hash, p.Repo, p.Time},
+			SampleCommits:  []Commit{{p.Hash, p.Repo, p.Time}},
+			EmailSources:   addSources(nil, email, p.Source),
+			NameSources:    addSources(nil, name, p.Source),
+			AliasHistory:   newAliasHistory(email, name, p.Time),
+		}
+		if isTrailerRole(p.Role) {
+			result[id].TrailerRole = p.Role
+		}
+		if p.SigningKey != "" {
+			result[id].SigningKeys = []string{p.SigningKey}
+		}
+		result[id].Activity = p.Activity.copy()
+		result[id].FirstCommit, result[id].LastCommit = p.Time, p.Time
+		result[id].Commits = p.Activity.Commits()
+		if result[id].Commits == 0 {
+			result[id].Commits = 1
+		}
+		if p.Repo != "" {
+			result[id].Repositories = []string{p.Repo}
+		}
+		if len(p.Mirrors) > 0 {
+			result[id].Repositories = unique(append(result[id].Repositories, p.Mirrors...))
 		}
 	}
 	reporter.Commit("people after filtering", len(result))
@@ -169,10 +353,18 @@ func newPeople(commits []signatureWithRepo, blacklist Blacklist) (People, error)
 }
 
 type parquetPersonAlias struct {
-	ID    int64  `parquet:"name=id, type=INT_64"`
-	Email string `parquet:"name=email, type=UTF8"`
-	Name  string `parquet:"name=name, type=UTF8"`
-	Repo  string `parquet:"name=repo, type=UTF8"`
+	ID      int64  `parquet:"name=id, type=INT_64"`
+	Email   string `parquet:"name=email, type=UTF8"`
+	Name    string `parquet:"name=name, type=UTF8"`
+	Repo    string `parquet:"name=repo, type=UTF8"`
+	Sources string `parquet:"name=sources, type=UTF8"`
+	// FirstSeen and LastSeen are the span of the email or the name in Person.AliasHistory.
+	FirstSeen int64 `parquet:"name=first_seen, type=TIMESTAMP_MILLIS"`
+	LastSeen  int64 `parquet:"name=last_seen, type=TIMESTAMP_MILLIS"`
+}
+
+func (alias parquetPersonAlias) span() AliasSpan {
+	return AliasSpan{millisToTime(alias.FirstSeen), millisToTime(alias.LastSeen)}
 }
 
 type parquetPersonIdentity struct {
@@ -181,12 +373,42 @@ type parquetPersonIdentity struct {
 	PrimaryEmail       string `parquet:"name=primary_email, type=UTF8"`
 	ExternalIDProvider string `parquet:"name=external_id_provider, type=UTF8"`
 	ExternalID         string `parquet:"name=external_id, type=UTF8"`
+	IsBot              bool   `parquet:"name=is_bot, type=BOOLEAN"`
+	FirstCommit        int64  `parquet:"name=first_commit, type=TIMESTAMP_MILLIS"`
+	LastCommit         int64  `parquet:"name=last_commit, type=TIMESTAMP_MILLIS"`
+	Commits            int64  `parquet:"name=commits, type=INT_64"`
+	RecentCommits      int64  `parquet:"name=recent_commits, type=INT_64"`
+	Repositories       int64  `parquet:"name=repositories, type=INT_64"`
+	// ExternalAccounts are Person.ExternalAccounts, see formatExternalAccounts.
+	ExternalAccounts string `parquet:"name=external_accounts, type=UTF8"`
+	// ExternalIDs are Person.ExternalIDs, see ExternalIDs.String. ExternalIDProvider and
+	// ExternalID keep the primary one, see ExternalIDs.Primary. The files written before
+	// the column existed have only those, see migrateParquetExternalIDs.
+	ExternalIDs string `parquet:"name=external_ids, type=UTF8"`
 }
 
+// timeToMillis converts the time to TIMESTAMP_MILLIS. The zero time becomes 0.
+func timeToMillis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// millisToTime is the inverse of timeToMillis.
+func millisToTime(millis int64) time.Time {
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, millis*int64(time.Millisecond)).UTC()
+}
+
+// readFromParquet reads the people written by People.WriteToParquet with any schema version,
+// see parquetMigrations.
 func readFromParquet(pathAliases string) (People, string, error) {
 	pathAliases, pathIDs := preparePaths(pathAliases)
 	getParquetReader := func(path string, obj interface{}) (*reader.ParquetReader, func()) {
-		fr, err := local.NewLocalFileReader(path)
+		fr, err := openParquetFile(path)
 		if err != nil {
 			logrus.Fatal("read error", err)
 		}
@@ -197,7 +419,7 @@ func readFromParquet(pathAliases string) (People, string, error) {
 			}
 		}
 
-		pr, err := reader.NewParquetReader(fr, obj, int64(runtime.NumCPU()))
+		pr, err := newCompatibleParquetReader(fr, obj, int64(runtime.NumCPU()))
 		if err != nil {
 			logrus.Fatal("read error", err)
 		}
@@ -209,7 +431,7 @@ func readFromParquet(pathAliases string) (People, string, error) {
 	num := int(pr.GetNumRows())
 	parquetPersonAliases := make([]parquetPersonAlias, num)
 	if err := pr.Read(&parquetPersonAliases); err != nil {
-		logrus.Printf("read error in %s: %v", pathAliases, err)
+		reporter.Infof("read error in %s: %v", pathAliases, err)
 		return nil, "", err
 	}
 	pr.ReadStop()
@@ -219,99 +441,172 @@ func readFromParquet(pathAliases string) (People, string, error) {
 	numIds := int(prIDs.GetNumRows())
 	parquetPersonsIDs := make([]parquetPersonIdentity, numIds)
 	if err := prIDs.Read(&parquetPersonsIDs); err != nil {
-		logrus.Printf("read error in %s: %v", pathIDs, err)
+		reporter.Infof("read error in %s: %v", pathIDs, err)
 		return nil, "", err
 	}
 	prIDs.ReadStop()
+	version, err := getParquetSchemaVersion(prIDs.Footer)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %v", pathIDs, err)
+	}
+	err = migrateParquetPeople(pathIDs, version, parquetPersonAliases, parquetPersonsIDs)
+	if err != nil {
+		return nil, "", err
+	}
 	id2PersonID := map[int64]parquetPersonIdentity{}
 	for _, pp := range parquetPersonsIDs {
 		id2PersonID[pp.ID] = pp
 	}
 
 	people := make(People)
-	var externalIDProvider, curExternalIDProvider string
 	for _, person := range parquetPersonAliases {
 		if _, ok := people[person.ID]; !ok {
-			people[person.ID] = &Person{person.ID, nil, nil, nil, "", "", ""}
+			people[person.ID] = &Person{ID: person.ID}
 		}
+		p := people[person.ID]
 		if person.Email != "" {
-			people[person.ID].Emails = append(people[person.ID].Emails, person.Email)
+			p.Emails = append(p.Emails, person.Email)
+			p.EmailSources = addSources(p.EmailSources, person.Email, parseSources(person.Sources)...)
+			p.AliasHistory.Emails = addAliasSpan(p.AliasHistory.Emails, person.Email, person.span())
 		}
 		if person.Name != "" {
-			people[person.ID].NamesWithRepos = append(people[person.ID].NamesWithRepos,
-				NameWithRepo{person.Name, person.Repo})
+			p.NamesWithRepos = append(p.NamesWithRepos, NameWithRepo{person.Name, person.Repo})
+			p.NameSources = addSources(p.NameSources, person.Name, parseSources(person.Sources)...)
+			p.AliasHistory.Names = addAliasSpan(p.AliasHistory.Names, person.Name, person.span())
 		}
 	}
 	for _, p := range people {
 		people[p.ID].PrimaryName = id2PersonID[p.ID].PrimaryName
 		people[p.ID].PrimaryEmail = id2PersonID[p.ID].PrimaryEmail
-		people[p.ID].ExternalID = id2PersonID[p.ID].ExternalID
-		curExternalIDProvider = id2PersonID[p.ID].ExternalIDProvider
-		if people[p.ID].ExternalID != "" {
-			if externalIDProvider != "" && externalIDProvider != curExternalIDProvider {
-				return people, externalIDProvider, fmt.Errorf(
-					"there are multiple ExternalIDProvider-s for %s: %s %s",
-					people[p.ID].String(), externalIDProvider, curExternalIDProvider)
-			}
-			externalIDProvider = curExternalIDProvider
+		people[p.ID].IsBot = id2PersonID[p.ID].IsBot
+		people[p.ID].FirstCommit = millisToTime(id2PersonID[p.ID].FirstCommit)
+		people[p.ID].LastCommit = millisToTime(id2PersonID[p.ID].LastCommit)
+		people[p.ID].Commits = int(id2PersonID[p.ID].Commits)
+		people[p.ID].RecentCommits = int(id2PersonID[p.ID].RecentCommits)
+		people[p.ID].ExternalAccounts = parseExternalAccounts(id2PersonID[p.ID].ExternalAccounts)
+		people[p.ID].ExternalIDs = parseExternalIDs(id2PersonID[p.ID].ExternalIDs)
+	}
+	return people, mainExternalIDProvider(parquetPersonsIDs), nil
+}
+
+// mainExternalIDProvider returns the most common provider of the primary external IDs, which
+// People.WriteToParquet preferred. The ties are broken in the alphabetical order.
+func mainExternalIDProvider(identities []parquetPersonIdentity) string {
+	counts := map[string]int{}
+	for _, identity := range identities {
+		if identity.ExternalID != "" {
+			counts[identity.ExternalIDProvider]++
+		}
+	}
+	var result string
+	best := 0
+	for provider, count := range counts {
+		if count > best || (count == best && provider < result) {
+			result, best = provider, count
 		}
 	}
-	return people, externalIDProvider, nil
+	return result
 }
 
-// WriteToParquet saves People structure to parquet file.
-func (p People) WriteToParquet(path string, externalIDProvider string) (err error) {
-	path, pathIDs := preparePaths(path)
-	getParquetWriter := func(path string, obj interface{}) (*writer.ParquetWriter, func()) {
-		pf, err := local.NewLocalFileWriter(path)
-		if err != nil {
-			logrus.Fatalf("failed to create a new local file writer at %s: %v", path, err)
+// newCompatibleParquetReader is reader.NewParquetReader which also reads the files written
+// before the trailing columns were appended to obj: those columns keep the zero values.
+func newCompatibleParquetReader(file source.ParquetFile, obj interface{}, np int64) (
+	*reader.ParquetReader, error) {
+	pr, err := reader.NewParquetReader(file, nil, np)
+	if err != nil {
+		return nil, err
+	}
+	objType := reflect.TypeOf(obj).Elem()
+	// the first schema element is the root
+	columns := len(pr.Footer.Schema) - 1
+	if columns >= objType.NumField() {
+		return reader.NewParquetReader(file, obj, np)
+	}
+	type schemaField struct {
+		Tag string
+	}
+	fields := make([]schemaField, columns)
+	for i := range fields {
+		field := objType.Field(i)
+		if !strings.HasPrefix(field.Tag.Get("parquet"), "name="+pr.Footer.Schema[i+1].Name+",") {
+			return nil, fmt.Errorf("unsupported parquet schema: column %d is %s instead of %s",
+				i, pr.Footer.Schema[i+1].Name, field.Tag.Get("parquet"))
 		}
-		pw, err := writer.NewParquetWriter(pf, obj, int64(runtime.NumCPU()))
+		fields[i].Tag = field.Tag.Get("parquet") + ", inname=" + field.Name
+	}
+	schema, err := json.Marshal(struct {
+		Tag    string
+		Fields []schemaField
+	}{"name=parquet_go_root, repetitiontype=REQUIRED", fields})
+	if err != nil {
+		return nil, err
+	}
+	if err = pr.SetSchemaHandlerFromJSON(string(schema)); err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// newParquetWriter creates the uncompressed parquet writer of obj-s and the function
+// which finalizes the file.
+func newParquetWriter(path string, obj interface{}) (*writer.ParquetWriter, func()) {
+	pf, err := createParquetFile(path)
+	if err != nil {
+		logrus.Fatalf("failed to create a new file writer at %s: %v", path, err)
+	}
+	pw, err := writer.NewParquetWriter(pf, obj, int64(runtime.NumCPU()))
+	if err != nil {
+		logrus.Fatalf("failed to create a new parquet writer: %v", err)
+	}
+	pw.CompressionType = parquet.CompressionCodec_UNCOMPRESSED
+	cleanup := func() {
+		err = pw.WriteStop()
 		if err != nil {
-			logrus.Fatalf("failed to create a new parquet writer: %v", err)
+			logrus.Fatal("failed to stop write to parquet", err)
 		}
-		pw.CompressionType = parquet.CompressionCodec_UNCOMPRESSED
-		cleanup := func() {
-			err = pw.WriteStop()
-			if err != nil {
-				logrus.Fatal("failed to stop write to parquet", err)
-			}
-			errClose := pf.Close()
-			if err == nil {
-				err = errClose
-			}
-			if err != nil {
-				logrus.Errorf("failed to store the matches to %s: %v", path, err)
-			}
+		errClose := pf.Close()
+		if err == nil {
+			err = errClose
+		}
+		if err != nil {
+			reporter.Errorf("failed to store the matches to %s: %v", path, err)
 		}
-		return pw, cleanup
 	}
+	return pw, cleanup
+}
 
-	pw, cleanup := getParquetWriter(path, new(parquetPersonAlias))
+// WriteToParquet saves People structure to parquet file.
+func (p People) WriteToParquet(path string, externalIDProvider string) (err error) {
+	path, pathIDs := preparePaths(path)
+	pw, cleanup := newParquetWriter(path, new(parquetPersonAlias))
 	defer cleanup()
-	pwIDs, cleanupIDs := getParquetWriter(pathIDs, new(parquetPersonIdentity))
+	pwIDs, cleanupIDs := newParquetWriter(pathIDs, new(parquetPersonIdentity))
 	defer cleanupIDs()
+	setParquetSchemaVersion(pw, parquetSchemaVersion)
+	setParquetSchemaVersion(pwIDs, parquetSchemaVersion)
 
 	p.ForEach(func(key int64, val *Person) bool {
-		provider := ""
-		if val.ExternalID != "" {
-			provider = externalIDProvider
-		}
+		provider, externalID := val.ExternalIDs.Primary(externalIDProvider)
 		if err := pwIDs.Write(parquetPersonIdentity{
 			val.ID, val.PrimaryName, val.PrimaryEmail, provider,
-			val.ExternalID}); err != nil {
+			externalID, val.IsBot, timeToMillis(val.FirstCommit), timeToMillis(val.LastCommit),
+			int64(val.Commits), int64(val.RecentCommits), int64(len(val.Repositories)),
+			formatExternalAccounts(val.ExternalAccounts), val.ExternalIDs.String()}); err != nil {
 			return true
 		}
 		for _, email := range val.Emails {
+			span := val.AliasHistory.Emails[email]
 			if err := pw.Write(parquetPersonAlias{
-				val.ID, email, "", ""}); err != nil {
+				val.ID, email, "", "", formatSources(val.EmailSources[email]),
+				timeToMillis(span.FirstSeen), timeToMillis(span.LastSeen)}); err != nil {
 				return true
 			}
 		}
 		for _, name := range val.NamesWithRepos {
+			span := val.AliasHistory.Names[name.Name]
 			if err = pw.Write(parquetPersonAlias{
-				val.ID, "", name.Name, name.Repo}); err != nil {
+				val.ID, "", name.Name, name.Repo, formatSources(val.NameSources[name.Name]),
+				timeToMillis(span.FirstSeen), timeToMillis(span.LastSeen)}); err != nil {
 				return true
 			}
 		}
@@ -320,6 +615,52 @@ func (p People) WriteToParquet(path string, externalIDProvider string) (err erro
 	return
 }
 
+var identitiesCSVHeader = []string{
+	"id", "primary_name", "primary_email", "external_id_provider", "external_id", "is_bot",
+	"commits", "names", "emails"}
+
+// WriteToCSV saves the people to the CSV file, one row per person sorted by ID. The names and
+// the emails are joined with "; ".
+func (p People) WriteToCSV(path string, externalIDProvider string) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write(identitiesCSVHeader); err != nil {
+		return
+	}
+	for _, id := range peopleIDs(p) {
+		person := p[id]
+		provider, externalID := person.ExternalIDs.Primary(externalIDProvider)
+		names := make([]string, len(person.NamesWithRepos))
+		for i, name := range person.NamesWithRepos {
+			names[i] = name.Name
+		}
+		err = writer.Write([]string{strconv.FormatInt(id, 10), person.PrimaryName,
+			person.PrimaryEmail, provider, externalID, strconv.FormatBool(person.IsBot),
+			strconv.Itoa(person.Commits), strings.Join(names, "; "), strings.Join(person.Emails, "; ")})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
 func preparePaths(rawPath string) (pathAliases, pathIDs string) {
 	if strings.HasSuffix(rawPath, ".parquet") {
 		rawPath = rawPath[:len(rawPath)-len(".parquet")]
@@ -329,27 +670,37 @@ func preparePaths(rawPath string) (pathAliases, pathIDs string) {
 	return
 }
 
-// Merge several persons with the given ids.
+// Merge several persons with the given ids. It returns the smallest id, which the others are
+// merged into. Use PeopleMerger to merge many groups of persons efficiently.
 func (p People) Merge(ids ...int64) (int64, error) {
-	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
-	p0 := p[ids[0]]
-	newExternalID := p0.ExternalID
+	merger := NewPeopleMerger(p)
+	merger.Add(ids[0])
 	for _, id := range ids[1:] {
-		if newExternalID == "" {
-			newExternalID = p[id].ExternalID
-		} else if p[id].ExternalID != "" && p[id].ExternalID != newExternalID {
-			return -1, fmt.Errorf("cannot merge ids %v with different ExternalIDs: %s %s",
-				ids, newExternalID, p[id].ExternalID)
+		if err := merger.Union(ids[0], id); err != nil {
+			return -1, err
 		}
-		p0.Emails = append(p0.Emails, p[id].Emails...)
-		p0.NamesWithRepos = append(p0.NamesWithRepos, p[id].NamesWithRepos...)
-		delete(p, id)
 	}
-	p0.Emails = unique(p0.Emails)
-	p0.NamesWithRepos = uniqueNamesWithRepo(p0.NamesWithRepos)
-	p0.SampleCommit = nil
+	groups, err := merger.Apply()
+	if err != nil {
+		return -1, err
+	}
+	return groups[0][0], nil
+}
 
-	return ids[0], nil
+// markBots sets IsBot for the people with the given emails or removes them if exclude is true.
+func (p People) markBots(botEmails map[string]struct{}, exclude bool) {
+	for id, person := range p {
+		for _, email := range person.Emails {
+			if _, isBot := botEmails[email]; isBot {
+				if exclude {
+					delete(p, id)
+				} else {
+					person.IsBot = true
+				}
+				break
+			}
+		}
+	}
 }
 
 // ForEach executes a function over each person in the collection.
@@ -367,36 +718,327 @@ func (p People) ForEach(f func(int64, *Person) bool) {
 	}
 }
 
-// FindPeople returns all the people in the database or from the disk cache.
-func FindPeople(ctx context.Context, connString string, cachePath string, blacklist Blacklist,
-	recentMonths int) (People, map[string]*Frequency, map[string]*Frequency, error) {
+// peopleIDs returns the sorted IDs of the people.
+func peopleIDs(people People) []int64 {
+	ids := make([]int64, 0, len(people))
+	for id := range people {
+		ids = append(ids, id)
+	}
+	sort.Sort(Int64Slice(ids))
+	return ids
+}
+
+// FindPeopleOptions configure FindPeopleWithOptions.
+type FindPeopleOptions struct {
+	// ConnString is the gitbase connection string. It is not used if the signatures are read
+	// from CachePath or from another source, see ExtractionOptions.Source.
+	ConnString string
+	// CachePath is the file with the extracted signatures. If it exists, the signatures are read
+	// from it, otherwise they are extracted and written there. The blank value disables the cache.
+	CachePath string
+	// Extraction configure the extraction of the signatures and the concurrency.
+	Extraction ExtractionOptions
+	// Workers is the number of goroutines of the concurrent stages which do not set their own,
+	// e.g. ExtractionOptions.Workers. 0 leaves the defaults of the stages.
+	Workers int
+	// Blacklist are the values which never match. ReducePeople should receive it extended with
+	// Blacklist.WithPopular to be consistent.
+	Blacklist Blacklist
+	// Popularity are the thresholds of the popular names and emails.
+	Popularity PopularityThresholds
+	// Bots decide whether the automated accounts are marked with IsBot or excluded.
+	Bots BotDetectionOptions
+	// RecentMonths is the number of the last months whose commits are recent. 0 means 12.
+	RecentMonths int
+	// Progress receives the progress of each stage. May be nil.
+	Progress ProgressReporter
+}
+
+// NewFindPeopleOptions returns the same defaults as the match-identities command: the local
+// gitbase, see GitbaseConnString, the built-in blacklist, the bot detection, one worker per CPU
+// and the 12 recent months.
+func NewFindPeopleOptions() (FindPeopleOptions, error) {
+	blacklist, err := NewBlacklist()
+	if err != nil {
+		return FindPeopleOptions{}, err
+	}
+	return FindPeopleOptions{
+		ConnString:   GitbaseConnString("", 0, "", ""),
+		Extraction:   NewExtractionOptions(),
+		Workers:      runtime.NumCPU(),
+		Blacklist:    blacklist,
+		Bots:         NewBotDetectionOptions(),
+		RecentMonths: 12,
+	}, nil
+}
+
+// FindPeople is FindPeopleWithOptions with the options as the arguments. recentMonths must be
+// positive.
+func FindPeople(ctx context.Context, connString string, cachePath string,
+	extraction ExtractionOptions, blacklist Blacklist, popularity PopularityThresholds,
+	bots BotDetectionOptions, recentMonths int, progressReporter ProgressReporter) (
+	People, map[string]*Frequency, map[string]*Frequency, error) {
 	if recentMonths == 0 {
 		logrus.Panicf("recentMonths should be a positive integer")
 	}
-	commits, err := findSignatures(ctx, connString, cachePath)
+	return FindPeopleWithOptions(ctx, FindPeopleOptions{
+		ConnString:   connString,
+		CachePath:    cachePath,
+		Extraction:   extraction,
+		Blacklist:    blacklist,
+		Popularity:   popularity,
+		Bots:         bots,
+		RecentMonths: recentMonths,
+		Progress:     progressReporter,
+	})
+}
+
+// FindPeopleWithOptions returns all the people in the database or from the disk cache.
+// The people which belong to automated accounts are either marked with IsBot or excluded,
+// depending on the bot detection options. The names and emails which pass the popularity
+// thresholds are treated as popular, ReducePeople should receive the blacklist extended
+// with Blacklist.WithPopular to be consistent. The progress of each stage is passed to
+// opts.Progress if it is not nil; all the stages stop early if ctx is cancelled.
+// The signatures are queried from gitbase according to the extraction options.
+func FindPeopleWithOptions(ctx context.Context, opts FindPeopleOptions) (
+	People, map[string]*Frequency, map[string]*Frequency, error) {
+	recentMonths := opts.RecentMonths
+	if recentMonths == 0 {
+		recentMonths = 12
+	}
+	extraction, cachePath := opts.Extraction, opts.CachePath
+	if extraction.Workers == 0 {
+		extraction.Workers = opts.Workers
+	}
+	prog := &progress{ctx, opts.Progress}
+	commits, err := findSignatures(prog, opts.ConnString, cachePath, extraction)
 	reporter.Commit("people found", len(commits))
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	people, err := newPeople(commits, blacklist)
+	if extraction.Suppressions.Len() > 0 {
+		size := len(commits)
+		if commits, err = extraction.Suppressions.filter(commits); err != nil {
+			return nil, nil, nil, err
+		}
+		if len(commits) < size && cachePath != "" && !strings.HasSuffix(cachePath, ".arrow") {
+			reporter.Infof("erasing %d suppressed signatures from the cache %s",
+				size-len(commits), cachePath)
+			if _, err = EraseSignatureCache(cachePath, extraction.Suppressions); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+	return PeopleFromSignatures(ctx, commits, extraction, opts.Blacklist, opts.Popularity, opts.Bots,
+		recentMonths, opts.Progress)
+}
+
+// PeopleFromSignatures is FindPeople for the signatures which were extracted elsewhere, e.g.
+// received by a service. Only ExtractionOptions.MatchCommitters, ExtractionOptions.Strings,
+// ExtractionOptions.EmailValidator, ExtractionOptions.Suppressions, ExtractionOptions.Seeds,
+// ExtractionOptions.Stats, ExtractionOptions.SharedMailboxes, ExtractionOptions.Forks,
+// ExtractionOptions.DedupCommits and ExtractionOptions.Reproducible of the extraction options
+// matter. The signatures may be changed.
+func PeopleFromSignatures(ctx context.Context, commits []Signature, extraction ExtractionOptions,
+	blacklist Blacklist, popularity PopularityThresholds, bots BotDetectionOptions, recentMonths int,
+	progressReporter ProgressReporter) (People, map[string]*Frequency, map[string]*Frequency, error) {
+	if recentMonths == 0 {
+		logrus.Panicf("recentMonths should be a positive integer")
+	}
+	prog := &progress{ctx, progressReporter}
+	table := extraction.Strings
+	if table == nil {
+		table = NewStringTable()
+	}
+	countSignaturesRead(commits)
+	if extraction.Reproducible {
+		sortSignatures(commits)
+	}
+	commits, err := extraction.Suppressions.filter(commits)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	if commits, err = collapseForks(commits, extraction.Forks); err != nil {
+		return nil, nil, nil, err
+	}
+	if extraction.DedupCommits {
+		commits = dedupCommits(commits)
+	}
+	if extraction.EmailValidator != nil {
+		commits = extraction.EmailValidator.validate(commits)
+	}
+	internSignatures(table, commits)
+	commits = filterCommitters(commits, extraction.MatchCommitters)
 	recentStartTime := time.Now().AddDate(0, -recentMonths, 0)
-	nameFreqs, emailFreqs, err := getStats(commits, recentStartTime)
-	return people, nameFreqs, emailFreqs, err
+	nameFreqs, emailFreqs, err := getStats(prog, commits, recentStartTime, popularity.Frequencies)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if extraction.Stats != nil {
+		if err = extraction.Stats.fill(prog, commits, nameFreqs, emailFreqs); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if extraction.SharedMailboxes != nil {
+		if err = extraction.SharedMailboxes.detect(prog, commits); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	people, err := newPeople(prog, commits, blacklist.withPopular(nameFreqs, emailFreqs, popularity, false))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	people.scopeNames(extraction.RepoGroups)
+	internPeople(table, people)
+	reporter.Commit("interned strings", table.Len())
+	botEmails, err := detectBots(prog, commits, bots)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	people.markBots(botEmails, bots.Exclude)
+	people.countRecentCommits(recentStartTime)
+	if err = people.applySeeds(extraction.Seeds); err != nil {
+		return nil, nil, nil, err
+	}
+	return people, nameFreqs, emailFreqs, nil
+}
+
+// countRecentCommits sets RecentCommits of the people who have not been merged yet, so that
+// each of them has a single signature.
+func (p People) countRecentCommits(recentStartTime time.Time) {
+	for _, person := range p {
+		if person.LastCommit.After(recentStartTime) {
+			person.RecentCommits = person.Commits
+		}
+	}
+}
+
+// filterCommitters reports the committer signatures and removes them unless match is true.
+// The committers who never authored a commit are logged separately because they are often
+// merge bots.
+func filterCommitters(commits []Signature, match bool) []Signature {
+	authors := map[string]struct{}{}
+	for _, commit := range commits {
+		if commit.Role != RoleCommitter {
+			authors[strings.ToLower(commit.Email)] = struct{}{}
+		}
+	}
+	committers := 0
+	committerOnly := map[string]struct{}{}
+	result := commits[:0:0]
+	for _, commit := range commits {
+		if commit.Role != RoleCommitter {
+			result = append(result, commit)
+			continue
+		}
+		committers++
+		if _, exists := authors[strings.ToLower(commit.Email)]; !exists {
+			committerOnly[strings.ToLower(commit.Email)] = struct{}{}
+		}
+		if match {
+			result = append(result, commit)
+		}
+	}
+	reporter.Commit("committer signatures", committers)
+	reporter.Commit("committer-only emails", len(committerOnly))
+	if len(committerOnly) > 0 {
+		emails := make([]string, 0, len(committerOnly))
+		for email := range committerOnly {
+			emails = append(emails, email)
+		}
+		sort.Strings(emails)
+		sample := emails
+		if len(sample) > 10 {
+			sample = sample[:10]
+		}
+		reporter.Infof("%d emails only commit and never author, e.g. %s",
+			len(emails), strings.Join(sample, ", "))
+	}
+	return result
 }
 
 // Frequency is a pair of word frequencies for a certain recent period of time and for all the time
+// together with the time of the latest commit.
 type Frequency struct {
 	Recent int
 	Total  int
+	Last   time.Time
+
+	// Windows are the frequencies for each of FrequencyOptions.Windows.
+	Windows []int `json:",omitempty"`
+	// Decayed is the frequency where each signature weighs 0.5^(age / FrequencyOptions.HalfLife).
+	// It is 0 if the half-life is not set.
+	Decayed float64 `json:",omitempty"`
+}
+
+// FrequencyOptions extend the binary recent/total split of Frequency with the counts which
+// reflect the current activity better.
+type FrequencyOptions struct {
+	// Windows are the periods of time before now to count the signatures within, e.g. a week,
+	// a month and a year. Each of them fills the corresponding item of Frequency.Windows.
+	Windows []time.Duration
+	// HalfLife is the age at which a signature weighs half as much as a new one in
+	// Frequency.Decayed. 0 disables the decay. The signatures without the time weigh 1.
+	HalfLife time.Duration
+}
+
+// add counts the signature made at the given time in the windows and the decayed frequency.
+func (opts FrequencyOptions) add(freq *Frequency, when, now time.Time) {
+	if len(opts.Windows) > 0 && freq.Windows == nil {
+		freq.Windows = make([]int, len(opts.Windows))
+	}
+	for i, window := range opts.Windows {
+		if when.After(now.Add(-window)) {
+			freq.Windows[i]++
+		}
+	}
+	if opts.HalfLife <= 0 {
+		return
+	}
+	weight := 1.0
+	if !when.IsZero() && when.Before(now) {
+		weight = math.Exp2(-float64(now.Sub(when)) / float64(opts.HalfLife))
+	}
+	freq.Decayed += weight
+}
+
+// MergeFrequencies adds the frequencies to total, e.g. those of a different shard or of
+// the next batch of the signatures.
+func MergeFrequencies(total, freqs map[string]*Frequency) {
+	for key, freq := range freqs {
+		if total[key] == nil {
+			total[key] = &Frequency{}
+		}
+		total[key].merge(freq)
+	}
+}
+
+// merge adds the other frequency of the same value, e.g. from a different shard.
+func (freq *Frequency) merge(other *Frequency) {
+	freq.Recent += other.Recent
+	freq.Total += other.Total
+	if other.Last.After(freq.Last) {
+		freq.Last = other.Last
+	}
+	for len(freq.Windows) < len(other.Windows) {
+		freq.Windows = append(freq.Windows, 0)
+	}
+	for i, count := range other.Windows {
+		freq.Windows[i] += count
+	}
+	freq.Decayed += other.Decayed
 }
 
-func countFreqs(commits []signatureWithRepo, getter func(signatureWithRepo) string, cleaner func(string) (string, error),
-	recentStartTime time.Time) (map[string]*Frequency, error) {
+func countFreqs(stage *stageProgress, commits []Signature, getter func(Signature) string,
+	cleaner func(string) (string, error), recentStartTime time.Time, opts FrequencyOptions) (
+	map[string]*Frequency, error) {
 	freqs := map[string]*Frequency{}
+	now := time.Now()
+	defer stage.done()
 	for _, commit := range commits {
+		if err := stage.tick(); err != nil {
+			return nil, err
+		}
 		value, err := cleaner(getter(commit))
 		if err != nil {
 			return nil, err
@@ -405,45 +1047,79 @@ func countFreqs(commits []signatureWithRepo, getter func(signatureWithRepo) stri
 			freqs[value] = &Frequency{}
 		}
 		freqs[value].Total++
-		if commit.time.After(recentStartTime) {
+		if commit.Time.After(freqs[value].Last) {
+			freqs[value].Last = commit.Time
+		}
+		if commit.Time.After(recentStartTime) {
 			freqs[value].Recent++
 		}
+		opts.add(freqs[value], commit.Time, now)
 	}
 	return freqs, nil
 }
 
 // getStats calculates frequencies of names and emails in commits for future primary names and
 // emails detection. Stats are collected both for the given recent period of time and for all
-// the time.
-func getStats(commits []signatureWithRepo, recentStartTime time.Time) (
+// the time, and optionally for the windows and with the time decay.
+func getStats(prog *progress, commits []Signature, recentStartTime time.Time, opts FrequencyOptions) (
 	nameFreqs, emailFreqs map[string]*Frequency, err error) {
-	nameFreqs, err = countFreqs(commits, func(c signatureWithRepo) string { return c.name }, cleanName, recentStartTime)
+	nameFreqs, err = countFreqs(prog.stage("counting name frequencies", len(commits)), commits,
+		func(c Signature) string { return c.Name }, cleanName, recentStartTime, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	emailFreqs, err = countFreqs(prog.stage("counting email frequencies", len(commits)), commits,
+		func(c Signature) string { return c.Email }, cleanEmail, recentStartTime, opts)
 	if err != nil {
 		return nil, nil, err
 	}
-	emailFreqs, err = countFreqs(commits, func(c signatureWithRepo) string { return c.email }, cleanEmail, recentStartTime)
 	return nameFreqs, emailFreqs, nil
 }
 
 const findPeopleSQL = `
 SELECT repository_id, commit_author_name, commit_author_email, MAX(commit_hash), MAX(commit_author_when)
 FROM commits
+WHERE repository_id = ?
 GROUP BY repository_id, commit_author_name, commit_author_email;
 `
 
+const findTrailersSQL = `
+SELECT repository_id, commit_hash, commit_author_when, commit_message
+FROM commits
+WHERE repository_id = ? AND LOWER(commit_message) LIKE '%-by:%';
+`
+
+const findCommittersSQL = `
+SELECT repository_id, committer_name, committer_email, MAX(commit_hash), MAX(committer_when)
+FROM commits
+WHERE repository_id = ?
+GROUP BY repository_id, committer_name, committer_email;
+`
+
 // HashPeopleDiscoverySQL returns the hashsum of the SQL used to fetch the raw Git signatures.
 func HashPeopleDiscoverySQL() string {
 	h := fnv.New32a()
-	n, err := h.Write([]byte(findPeopleSQL))
-	if err != nil || n != len(findPeopleSQL) {
-		logrus.Panicf("HashPeopleDiscoverySQL: %d %d %v", n, len(findPeopleSQL), err)
+	for _, query := range []string{findPeopleSQL, findCommittersSQL, findTrailersSQL} {
+		n, err := h.Write([]byte(query))
+		if err != nil || n != len(query) {
+			logrus.Panicf("HashPeopleDiscoverySQL: %d %d %v", n, len(query), err)
+		}
 	}
 	return hex.EncodeToString(h.Sum(nil))
 }
 
-func readSignaturesFromDisk(filePath string) (commits []signatureWithRepo, err error) {
-	var file *os.File
-	file, err = os.Open(filePath)
+// readSignaturesFromDisk reads the CSV file with the cached signatures which is compressed with
+// gzip or zstd if the path ends with ".gz" or ".zst".
+func readSignaturesFromDisk(prog *progress, filePath string) ([]Signature, error) {
+	return readFilteredSignaturesFromDisk(prog, filePath, nil)
+}
+
+// readFilteredSignaturesFromDisk is readSignaturesFromDisk which skips the signatures which do not
+// pass the filter while reading, so that they never take memory. The nil filter passes all of them.
+func readFilteredSignaturesFromDisk(prog *progress, filePath string, filter *signatureFilter) (
+	commits []Signature, err error) {
+	var file io.ReadCloser
+	file, err = openCompressed(filePath)
 	if err != nil {
 		return nil, err
 	}
@@ -457,6 +1133,8 @@ func readSignaturesFromDisk(filePath string) (commits []signatureWithRepo, err e
 	r := csv.NewReader(file)
 	header := make(map[string]int)
 	rowIndex := 0
+	stage := prog.stage("reading signatures", 0)
+	defer stage.done()
 	for {
 		record, err := r.Read()
 		rowIndex++
@@ -468,45 +1146,68 @@ func readSignaturesFromDisk(filePath string) (commits []signatureWithRepo, err e
 			return nil, err
 		}
 		if len(header) == 0 {
-			if len(record) != 5 {
-				return nil, fmt.Errorf(
-					"invalid CSV file: should have 5 columns instead of %d", len(record))
+			if len(record) < requiredSignatureFields || len(record) > len(signatureCSVHeader) {
+				return nil, fmt.Errorf("%w: invalid CSV file: should have %d to %d columns instead of %d",
+					ErrInvalidSignature, requiredSignatureFields, len(signatureCSVHeader), len(record))
 			}
 			for index, name := range record {
 				header[name] = index
 			}
 		} else {
+			if err := stage.tick(); err != nil {
+				return nil, err
+			}
 			if len(record) != len(header) {
-				return nil, fmt.Errorf("invalid CSV record: %s", strings.Join(record, ","))
+				return nil, fmt.Errorf("%w: invalid CSV record: %s", ErrInvalidSignature,
+					strings.Join(record, ","))
 			}
 
 			for key := range header {
 				if key != "time" {
-					normValue, _, err := removeDiacritical(record[header[key]])
+					record[header[key]], err = normalizeSignatureValue(record[header[key]])
 					if err != nil {
-						return nil, err
+						return nil, withKind(ErrInvalidSignature, err)
 					}
-					record[header[key]] = strings.TrimSpace(normalizeSpaces(strings.ToLower(normValue)))
 				} else {
 					record[header[key]] = strings.TrimSpace(record[header[key]])
 				}
 			}
 
-			person := signatureWithRepo{
-				repo:  record[header["repo"]],
-				name:  record[header["name"]],
-				email: record[header["email"]],
-				hash:  record[header["hash"]],
+			person := Signature{
+				Repo:  record[header["repo"]],
+				Name:  record[header["name"]],
+				Email: record[header["email"]],
+				Hash:  record[header["hash"]],
+			}
+			if index, exists := header["source"]; exists {
+				person.Source = SourceKind(record[index])
 			}
-			person.time, err = time.Parse(time.RFC3339, record[header["time"]])
-			if err != nil || person.repo == "" || person.email == "" || person.name == "" ||
-				person.hash == "" {
-				logrus.Warnf("invalid cache item: %v: %v", person.String(), err)
+			if index, exists := header["role"]; exists {
+				person.Role = SignatureRole(record[index])
+			}
+			if index, exists := header["signing_key"]; exists {
+				person.SigningKey = record[index]
+			}
+			var errActivity error
+			if index, exists := header["activity"]; exists {
+				person.Activity, errActivity = parseActivity(record[index])
+			}
+			person.Time, err = time.Parse(time.RFC3339, record[header["time"]])
+			if err == nil {
+				err = errActivity
+			}
+			if err != nil || person.Repo == "" || person.Email == "" || person.Name == "" ||
+				person.Hash == "" {
+				reporter.Warnf("invalid cache item: %v: %v", person.String(), err)
+				continue
+			}
+			if !filter.keep(person) {
 				continue
 			}
 			commits = append(commits, person)
 		}
 	}
+	filter.report()
 
 	if err == io.EOF {
 		err = nil
@@ -514,14 +1215,18 @@ func readSignaturesFromDisk(filePath string) (commits []signatureWithRepo, err e
 	return
 }
 
-func readSignaturesFromDatabase(ctx context.Context, conn string) ([]signatureWithRepo, error) {
-	db, err := sql.Open("mysql", conn+"?parseTime=true")
+// normalizeSignatureValue brings the raw signature value to the canonical form used in the cache.
+func normalizeSignatureValue(value string) (string, error) {
+	value, _, err := removeDiacritical(value)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	db.SetMaxIdleConns(0)
+	return strings.TrimSpace(normalizeSpaces(strings.ToLower(value))), nil
+}
 
-	rows, err := db.QueryContext(ctx, findPeopleSQL)
+// readSignaturesFromDatabase fetches the signatures repository by repository.
+func readSignaturesFromDatabase(prog *progress, source signatureSource) ([]Signature, error) {
+	repos, err := source.Repositories(prog.context())
 	if err != nil {
 		return nil, err
 	}
@@ -529,25 +1234,32 @@ func readSignaturesFromDatabase(ctx context.Context, conn string) ([]signatureWi
 	spin := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
 	spin.Start()
 	defer spin.Stop()
-	var result []signatureWithRepo
-	i := 0
-	for rows.Next() {
-		spin.Suffix = fmt.Sprintf(" %d", i+1)
-		i++
-		var repo, name, email, hash string
-		var time time.Time
-		if err := rows.Scan(&repo, &name, &email, &hash, &time); err != nil {
+	var result []Signature
+	stage := prog.stage("reading signatures", 0)
+	defer stage.done()
+	for _, repo := range repos {
+		signatures, err := source.Signatures(prog.context(), repo)
+		if err != nil {
 			return nil, err
 		}
-		result = append(result, signatureWithRepo{repo, name, email, hash, time})
+		for _, signature := range signatures {
+			if err := stage.tick(); err != nil {
+				return nil, err
+			}
+			result = append(result, signature)
+		}
+		spin.Lock()
+		spin.Suffix = fmt.Sprintf(" %d", len(result))
+		spin.Unlock()
 	}
-
-	return result, rows.Err()
+	return result, nil
 }
 
-func storeSignaturesOnDisk(filePath string, result []signatureWithRepo) (err error) {
-	var file *os.File
-	file, err = os.Create(filePath)
+// storeSignaturesOnDisk writes the CSV file with the cached signatures which is compressed with
+// gzip or zstd if the path ends with ".gz" or ".zst".
+func storeSignaturesOnDisk(filePath string, result []Signature) (err error) {
+	var file io.WriteCloser
+	file, err = createCompressed(filePath)
 	if err != nil {
 		return
 	}
@@ -565,12 +1277,12 @@ func storeSignaturesOnDisk(filePath string, result []signatureWithRepo) (err err
 			err = writer.Error()
 		}
 	}()
-	err = writer.Write([]string{"repo", "name", "email", "hash", "time"})
+	err = writer.Write(signatureCSVHeader)
 	if err != nil {
 		return
 	}
 	for _, p := range result {
-		err = writer.Write([]string{p.repo, p.name, p.email, p.hash, p.time.Format(time.RFC3339)})
+		err = writer.Write(signatureRecord(p))
 		if err != nil {
 			return
 		}
@@ -578,40 +1290,169 @@ func storeSignaturesOnDisk(filePath string, result []signatureWithRepo) (err err
 	return
 }
 
-func findSignatures(ctx context.Context, connStr string, path string) ([]signatureWithRepo, error) {
-	if _, err := os.Stat(path); err == nil {
-		logrus.Printf("reading signatures from the cache: %s", path)
-		return readSignaturesFromDisk(path)
-	} else if !os.IsNotExist(err) {
-		return nil, err
+// signatureCSVHeader is the header of the CSV file with the cached signatures.
+// The "source", "role", "signing_key" and "activity" columns are optional when the cache is read.
+var signatureCSVHeader = []string{
+	"repo", "name", "email", "hash", "time", "source", "role", "signing_key", "activity"}
+
+// requiredSignatureFields is the number of the leading signatureCSVHeader columns which are required.
+const requiredSignatureFields = 5
+
+// sortSignatures orders the signatures by all their fields, so that the result does not depend
+// on the order in which the sources produced them.
+func sortSignatures(commits []Signature) {
+	less := func(a, b Signature) bool {
+		switch {
+		case a.Repo != b.Repo:
+			return a.Repo < b.Repo
+		case a.Name != b.Name:
+			return a.Name < b.Name
+		case a.Email != b.Email:
+			return a.Email < b.Email
+		case a.Hash != b.Hash:
+			return a.Hash < b.Hash
+		case !a.Time.Equal(b.Time):
+			return a.Time.Before(b.Time)
+		case a.Source != b.Source:
+			return a.Source < b.Source
+		case a.Role != b.Role:
+			return a.Role < b.Role
+		case a.SigningKey != b.SigningKey:
+			return a.SigningKey < b.SigningKey
+		}
+		return a.Activity.String() < b.Activity.String()
+	}
+	if sort.SliceIsSorted(commits, func(i, j int) bool { return less(commits[i], commits[j]) }) {
+		return
 	}
+	sort.Slice(commits, func(i, j int) bool { return less(commits[i], commits[j]) })
+}
 
-	logrus.Printf("signatures are not cached in %s, loading them from the database", path)
-	result, err := readSignaturesFromDatabase(ctx, connStr)
-	if err != nil {
+// signatureRecord converts the signature to the CSV record.
+func signatureRecord(p Signature) []string {
+	return []string{p.Repo, p.Name, p.Email, p.Hash, p.Time.Format(time.RFC3339), string(p.Source),
+		string(p.Role), p.SigningKey, p.Activity.String()}
+}
+
+// findSignatures reads the signatures from the cache in path, which is either the CSV file,
+// the parquet commits table or signatures cache if path ends with ".parquet" or the Arrow IPC
+// stream with SignaturesArrowSchema if path ends with ".arrow". Otherwise, the signatures are
+// read from the repositories on disk if opts.Source is SourceGit and cached in path as a whole,
+// or queried from the database, the GitHub API (opts.Source is SourceGitHub), the mailing list
+// archives (opts.Source is SourceMbox) or the issue tracker accounts (opts.Source is SourceJIRA
+// or SourceREST) repository by repository and cached in path with checkpoints, so that
+// an interrupted extraction continues from the last checkpoint if opts.Resume is true.
+// The cache is the parquet signatures cache if path ends with ".parquet", otherwise the CSV file
+// which is compressed if path ends with ".gz" or ".zst". It is split into the chunks of
+// opts.CacheChunkSize signatures, which are read concurrently, if it is positive.
+func findSignatures(prog *progress, connStr string, path string, opts ExtractionOptions) (
+	[]Signature, error) {
+	filter := newSignatureFilter(opts)
+	if exists, err := pathExists(path); exists {
+		if strings.HasSuffix(path, ".parquet") {
+			reporter.Infof("reading signatures from the parquet file: %s", path)
+			return filter.apply(readSignaturesFromParquet(prog, path, opts.ParquetColumns))
+		}
+		if strings.HasSuffix(path, ".arrow") {
+			reporter.Infof("reading signatures from the Arrow IPC stream: %s", path)
+			return filter.apply(readSignaturesFromArrowFile(path))
+		}
+		reporter.Infof("reading signatures from the cache: %s", path)
+		return readFilteredSignaturesFromDisk(prog, path, filter)
+	} else if err != nil {
 		return nil, err
+	} else if strings.HasSuffix(path, ".arrow") {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	if chunks, err := signatureChunks(path); err != nil {
+		return nil, err
+	} else if len(chunks) > 0 {
+		reporter.Infof("reading signatures from %d chunks of the cache: %s", len(chunks), path)
+		return filter.apply(readSignatureChunks(prog, chunks, opts.Workers))
 	}
 
-	if path != "" {
-		logrus.Printf("writing the signatures cache to %s", path)
-		if err := storeSignaturesOnDisk(path, result); err != nil {
+	if opts.Source == SourceGit {
+		reporter.Infof("signatures are not cached in %s, reading them from %s", path, opts.Repositories)
+		source, err := newGitSource(opts.Repositories, opts.Workers)
+		if err != nil {
 			return nil, err
 		}
+		source.trailers = opts.Trailers
+		commits, err := readSignaturesFromGit(prog, source)
+		if err != nil || path == "" {
+			return filter.apply(commits, err)
+		}
+		if opts.Reproducible {
+			// the workers finish the repositories in a random order
+			sortSignatures(commits)
+		}
+		reporter.Infof("writing the signatures cache to %s", path)
+		return filter.apply(commits, storeSignatureCache(path, commits, opts.CacheChunkSize))
 	}
-
-	return result, nil
+	var source signatureSource
+	switch opts.Source {
+	case SourceGitHub:
+		reporter.Infof("signatures are not cached in %s, loading them from the GitHub organization %s",
+			path, opts.GitHubOrg)
+		gitHub, err := newGitHubSource(opts)
+		if err != nil {
+			return nil, err
+		}
+		source = gitHub
+	case SourceMbox:
+		reporter.Infof("signatures are not cached in %s, reading them from the mailboxes %s",
+			path, opts.Mailboxes)
+		mbox, err := newMboxSource(opts.Mailboxes, opts.MailboxTag)
+		if err != nil {
+			return nil, err
+		}
+		source = mbox
+	case SourceJIRA, SourceREST:
+		reporter.Infof("signatures are not cached in %s, loading the accounts from %s",
+			path, opts.AccountsURL)
+		accounts, err := newAccountSource(opts.Source, opts)
+		if err != nil {
+			return nil, err
+		}
+		source = accounts
+	default:
+		reporter.Infof("signatures are not cached in %s, loading them from the database", path)
+		gitbase, err := newGitbaseSource(connStr, opts)
+		if err != nil {
+			return nil, err
+		}
+		defer gitbase.Close()
+		source = gitbase
+	}
+	if path == "" {
+		if opts.Repos != nil {
+			source = filteredSource{source, opts.Repos}
+		}
+		return filter.apply(readSignaturesFromDatabase(prog, source))
+	}
+	// the cache keeps all the signatures, so that the other filters do not need to extract again
+	if isBlobURL(path) {
+		// the objects cannot be appended to, so there are no checkpoints
+		commits, err := readSignaturesFromDatabase(prog, source)
+		if err != nil {
+			return nil, err
+		}
+		reporter.Infof("writing the signatures cache to %s", path)
+		return filter.apply(commits, storeSignatureCache(path, commits, opts.CacheChunkSize))
+	}
+	reporter.Infof("writing the signatures cache to %s", path)
+	return filter.apply(extractSignatures(prog, source, path, opts.Resume, opts.CacheChunkSize))
 }
 
 func cleanName(name string) (string, error) {
-	name, _, err := removeDiacritical(name)
+	cleanName, err := nameCleaner.Clean(name)
 	if err != nil {
 		return name, err
 	}
-	cleanName := strings.TrimSpace(normalizeSpaces(strings.ToLower(name)))
 	if cleanName == name {
 		reporter.Increment("clean names")
 	}
-	return cleanName, err
+	return cleanName, nil
 }
 
 func cleanEmail(email string) (string, error) {
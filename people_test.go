@@ -4,6 +4,7 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"runtime"
 	"testing"
 	"time"
 
@@ -11,100 +12,181 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-var Signatures = []signatureWithRepo{
-	{repo: "repo1", name: "Bob", email: "Bob@google.com", hash: "aaa",
-		time: time.Now().AddDate(0, -6, 0).Truncate(time.Second).UTC()},
-	{repo: "repo2", name: "Bob", email: "Bob@google.com", hash: "bbb",
-		time: time.Now().AddDate(0, -18, 0).Truncate(time.Second).UTC()},
-	{repo: "repo1", name: "Alice", email: "alice@google.com", hash: "ccc",
-		time: time.Now().AddDate(0, -15, 0).Truncate(time.Second).UTC()},
-	{repo: "repo1", name: "Bob", email: "Bob@google.com", hash: "ddd",
-		time: time.Now().AddDate(0, -2, 0).Truncate(time.Second).UTC()},
-	{repo: "repo1", name: "Bob", email: "bad-email@domen", hash: "eee",
-		time: time.Now().AddDate(0, -20, 0).Truncate(time.Second).UTC()},
-	{repo: "repo1", name: "admin", email: "someone@google.com", hash: "fff",
-		time: time.Now().AddDate(0, -4, 0).Truncate(time.Second).UTC()},
+var Signatures = []Signature{
+	{Repo: "repo1", Name: "Bob", Email: "Bob@google.com", Hash: "aaa",
+		Time: time.Now().AddDate(0, -6, 0).Truncate(time.Second).UTC()},
+	{Repo: "repo2", Name: "Bob", Email: "Bob@google.com", Hash: "bbb",
+		Time: time.Now().AddDate(0, -18, 0).Truncate(time.Second).UTC()},
+	{Repo: "repo1", Name: "Alice", Email: "alice@google.com", Hash: "ccc",
+		Time: time.Now().AddDate(0, -15, 0).Truncate(time.Second).UTC()},
+	{Repo: "repo1", Name: "Bob", Email: "Bob@google.com", Hash: "ddd",
+		Time: time.Now().AddDate(0, -2, 0).Truncate(time.Second).UTC()},
+	{Repo: "repo1", Name: "Bob", Email: "bad-email@domen", Hash: "eee",
+		Time: time.Now().AddDate(0, -20, 0).Truncate(time.Second).UTC()},
+	{Repo: "repo1", Name: "admin", Email: "someone@google.com", Hash: "fff",
+		Time: time.Now().AddDate(0, -4, 0).Truncate(time.Second).UTC()},
 }
 
 func TestPeopleNew(t *testing.T) {
 	expected := People{
 		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
-			SampleCommit: &Commit{"aaa", "repo1"}},
+			SampleCommit: &Commit{"aaa", "repo1", Signatures[0].Time}, Repositories: []string{"repo1"},
+			FirstCommit: Signatures[0].Time, LastCommit: Signatures[0].Time, Commits: 1,
+			AliasHistory: testAliasHistory("bob@google.com", "bob", Signatures[0].Time, Signatures[0].Time)},
 		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
-			SampleCommit: &Commit{"bbb", "repo2"}},
+			SampleCommit: &Commit{"bbb", "repo2", Signatures[1].Time}, Repositories: []string{"repo2"},
+			FirstCommit: Signatures[1].Time, LastCommit: Signatures[1].Time, Commits: 1,
+			AliasHistory: testAliasHistory("bob@google.com", "bob", Signatures[1].Time, Signatures[1].Time)},
 		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"},
-			SampleCommit: &Commit{"ccc", "repo1"}},
+			SampleCommit: &Commit{"ccc", "repo1", Signatures[2].Time}, Repositories: []string{"repo1"},
+			FirstCommit: Signatures[2].Time, LastCommit: Signatures[2].Time, Commits: 1,
+			AliasHistory: testAliasHistory("alice@google.com", "alice", Signatures[2].Time, Signatures[2].Time)},
 		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
-			SampleCommit: &Commit{"ddd", "repo1"}},
+			SampleCommit: &Commit{"ddd", "repo1", Signatures[3].Time}, Repositories: []string{"repo1"},
+			FirstCommit: Signatures[3].Time, LastCommit: Signatures[3].Time, Commits: 1,
+			AliasHistory: testAliasHistory("bob@google.com", "bob", Signatures[3].Time, Signatures[3].Time)},
 	}
-	people, err := newPeople(Signatures, newTestBlacklist(t))
+	people, err := newPeople(nil, Signatures, newTestBlacklist(t))
 	require.NoError(t, err)
-	require.Equal(t, expected, people)
+	require.Equal(t, fillSampleCommits(expected), people)
+}
+
+// fillSampleCommits sets Person.SampleCommits to the only Person.SampleCommit.
+func fillSampleCommits(people People) People {
+	for _, p := range people {
+		if p.SampleCommit != nil {
+			p.SampleCommits = []Commit{*p.SampleCommit}
+		}
+	}
+	return people
 }
 
 func TestTwoPeopleMerge(t *testing.T) {
 	require := require.New(t)
-	people, err := newPeople(Signatures, newTestBlacklist(t))
+	people, err := newPeople(nil, Signatures, newTestBlacklist(t))
 	require.NoError(err)
 	mergedID, err := people.Merge(1, 2)
 	expected := People{
-		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
+			SampleCommit: &Commit{"bbb", "repo2", Signatures[1].Time}, Repositories: []string{"repo1", "repo2"},
+			FirstCommit: Signatures[1].Time, LastCommit: Signatures[0].Time, Commits: 2,
+			AliasHistory: testAliasHistory("bob@google.com", "bob", Signatures[1].Time, Signatures[0].Time)},
 		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"},
-			SampleCommit: &Commit{"ccc", "repo1"}},
+			SampleCommit: &Commit{"ccc", "repo1", Signatures[2].Time}, Repositories: []string{"repo1"},
+			FirstCommit: Signatures[2].Time, LastCommit: Signatures[2].Time, Commits: 1,
+			AliasHistory: testAliasHistory("alice@google.com", "alice", Signatures[2].Time, Signatures[2].Time)},
 		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
-			SampleCommit: &Commit{"ddd", "repo1"}},
+			SampleCommit: &Commit{"ddd", "repo1", Signatures[3].Time}, Repositories: []string{"repo1"},
+			FirstCommit: Signatures[3].Time, LastCommit: Signatures[3].Time, Commits: 1,
+			AliasHistory: testAliasHistory("bob@google.com", "bob", Signatures[3].Time, Signatures[3].Time)},
 	}
 	require.Equal(int64(1), mergedID)
-	require.Equal(expected, people)
+	require.Equal(fillSampleCommits(expected), people)
 	require.NoError(err)
 
 	mergedID, err = people.Merge(3, 4)
 	expected = People{
-		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
+			SampleCommit: &Commit{"bbb", "repo2", Signatures[1].Time}, Repositories: []string{"repo1", "repo2"},
+			FirstCommit: Signatures[1].Time, LastCommit: Signatures[0].Time, Commits: 2,
+			AliasHistory: testAliasHistory("bob@google.com", "bob", Signatures[1].Time, Signatures[0].Time)},
 		3: {ID: 3,
 			NamesWithRepos: []NameWithRepo{{"alice", ""}, {"bob", ""}},
-			Emails:         []string{"alice@google.com", "bob@google.com"}},
+			Emails:         []string{"alice@google.com", "bob@google.com"},
+			SampleCommit:   &Commit{"ccc", "repo1", Signatures[2].Time},
+			Repositories:   []string{"repo1"},
+			FirstCommit:    Signatures[2].Time,
+			LastCommit:     Signatures[3].Time,
+			Commits:        2,
+			AliasHistory: AliasHistory{
+				Emails: map[string]AliasSpan{
+					"alice@google.com": {Signatures[2].Time, Signatures[2].Time},
+					"bob@google.com":   {Signatures[3].Time, Signatures[3].Time}},
+				Names: map[string]AliasSpan{
+					"alice": {Signatures[2].Time, Signatures[2].Time},
+					"bob":   {Signatures[3].Time, Signatures[3].Time}},
+			}},
 	}
 	require.Equal(int64(3), mergedID)
-	require.Equal(expected, people)
+	require.Equal(fillSampleCommits(expected), people)
 	require.NoError(err)
 
 	mergedID, err = people.Merge(1, 3)
 	expected = People{
 		1: {ID: 1,
 			NamesWithRepos: []NameWithRepo{{"alice", ""}, {"bob", ""}},
-			Emails:         []string{"alice@google.com", "bob@google.com"}},
+			Emails:         []string{"alice@google.com", "bob@google.com"},
+			SampleCommit:   &Commit{"bbb", "repo2", Signatures[1].Time},
+			Repositories:   []string{"repo1", "repo2"},
+			FirstCommit:    Signatures[1].Time,
+			LastCommit:     Signatures[3].Time,
+			Commits:        4,
+			AliasHistory: AliasHistory{
+				Emails: map[string]AliasSpan{
+					"alice@google.com": {Signatures[2].Time, Signatures[2].Time},
+					"bob@google.com":   {Signatures[1].Time, Signatures[3].Time}},
+				Names: map[string]AliasSpan{
+					"alice": {Signatures[2].Time, Signatures[2].Time},
+					"bob":   {Signatures[1].Time, Signatures[3].Time}},
+			}},
 	}
 	require.Equal(int64(1), mergedID)
-	require.Equal(expected, people)
+	require.Equal(fillSampleCommits(expected), people)
 	require.NoError(err)
 }
 
 func TestFourPeopleMerge(t *testing.T) {
-	people, err := newPeople(Signatures, newTestBlacklist(t))
+	people, err := newPeople(nil, Signatures, newTestBlacklist(t))
 	require.NoError(t, err)
 	mergedID, err := people.Merge(1, 2, 3, 4)
 	expected := People{
 		1: {ID: 1,
 			NamesWithRepos: []NameWithRepo{{"alice", ""}, {"bob", ""}},
-			Emails:         []string{"alice@google.com", "bob@google.com"}},
+			Emails:         []string{"alice@google.com", "bob@google.com"},
+			SampleCommit:   &Commit{"bbb", "repo2", Signatures[1].Time},
+			Repositories:   []string{"repo1", "repo2"},
+			FirstCommit:    Signatures[1].Time,
+			LastCommit:     Signatures[3].Time,
+			Commits:        4,
+			AliasHistory: AliasHistory{
+				Emails: map[string]AliasSpan{
+					"alice@google.com": {Signatures[2].Time, Signatures[2].Time},
+					"bob@google.com":   {Signatures[1].Time, Signatures[3].Time}},
+				Names: map[string]AliasSpan{
+					"alice": {Signatures[2].Time, Signatures[2].Time},
+					"bob":   {Signatures[1].Time, Signatures[3].Time}},
+			}},
 	}
 	require.Equal(t, int64(1), mergedID)
-	require.Equal(t, expected, people)
+	require.Equal(t, fillSampleCommits(expected), people)
 	require.NoError(t, err)
 }
 
 func TestDifferentExternalIdsMerge(t *testing.T) {
-	people, err := newPeople(Signatures, newTestBlacklist(t))
+	people, err := newPeople(nil, Signatures, newTestBlacklist(t))
 	require.NoError(t, err)
-	people[1].ExternalID = "id1"
-	people[2].ExternalID = "id2"
+	people[1].ExternalIDs = ExternalIDs{"github": "id1"}
+	people[2].ExternalIDs = ExternalIDs{"github": "id2"}
 	_, err = people.Merge(1, 2)
 	require.Error(t, err)
 }
 
+func TestPersonCopy(t *testing.T) {
+	people, err := newPeople(nil, Signatures, newTestBlacklist(t))
+	require.NoError(t, err)
+	people[1].Activity = testActivity(time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC))
+	people[2].Activity = testActivity(time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC))
+	original := people[1].Copy()
+	require.Equal(t, people[1], original)
+	_, err = people.Merge(1, 2)
+	require.NoError(t, err)
+	require.NotEqual(t, people[1], original)
+	require.Len(t, original.Emails, 1)
+	require.Equal(t, 1, original.Activity.Commits())
+}
+
 func TestPeopleForEach(t *testing.T) {
-	people, err := newPeople(Signatures, newTestBlacklist(t))
+	people, err := newPeople(nil, Signatures, newTestBlacklist(t))
 	require.NoError(t, err)
 	var keys = make([]int64, 0, len(people))
 	people.ForEach(func(key int64, val *Person) bool {
@@ -114,6 +196,14 @@ func TestPeopleForEach(t *testing.T) {
 	require.Equal(t, []int64{1, 2, 3, 4}, keys)
 }
 
+// testAliasHistory is Person.AliasHistory with the single email and name.
+func testAliasHistory(email, name string, first, last time.Time) AliasHistory {
+	return AliasHistory{
+		Emails: map[string]AliasSpan{email: {first, last}},
+		Names:  map[string]AliasSpan{name: {first, last}},
+	}
+}
+
 func tempFile(t *testing.T, pattern string) (*os.File, func()) {
 	t.Helper()
 	f, err := ioutil.TempFile("", pattern)
@@ -131,15 +221,15 @@ func TestFindSignatures(t *testing.T) {
 
 	err := storeSignaturesOnDisk(peopleFile.Name(), Signatures)
 	req.NoError(err)
-	people, err := findSignatures(context.TODO(), "0.0.0.0:3306", peopleFile.Name())
+	people, err := findSignatures(nil, "0.0.0.0:3306", peopleFile.Name(), ExtractionOptions{})
 	req.NoError(err)
-	req.Equal([]signatureWithRepo{
-		{repo: "repo1", name: "bob", email: "bob@google.com", hash: "aaa", time: Signatures[0].time},
-		{repo: "repo2", name: "bob", email: "bob@google.com", hash: "bbb", time: Signatures[1].time},
-		{repo: "repo1", name: "alice", email: "alice@google.com", hash: "ccc", time: Signatures[2].time},
-		{repo: "repo1", name: "bob", email: "bob@google.com", hash: "ddd", time: Signatures[3].time},
-		{repo: "repo1", name: "bob", email: "bad-email@domen", hash: "eee", time: Signatures[4].time},
-		{repo: "repo1", name: "admin", email: "someone@google.com", hash: "fff", time: Signatures[5].time},
+	req.Equal([]Signature{
+		{Repo: "repo1", Name: "bob", Email: "bob@google.com", Hash: "aaa", Time: Signatures[0].Time},
+		{Repo: "repo2", Name: "bob", Email: "bob@google.com", Hash: "bbb", Time: Signatures[1].Time},
+		{Repo: "repo1", Name: "alice", Email: "alice@google.com", Hash: "ccc", Time: Signatures[2].Time},
+		{Repo: "repo1", Name: "bob", Email: "bob@google.com", Hash: "ddd", Time: Signatures[3].Time},
+		{Repo: "repo1", Name: "bob", Email: "bad-email@domen", Hash: "eee", Time: Signatures[4].Time},
+		{Repo: "repo1", Name: "admin", Email: "someone@google.com", Hash: "fff", Time: Signatures[5].Time},
 	}, people)
 }
 
@@ -152,26 +242,60 @@ func TestFindPeople(t *testing.T) {
 		return
 	}
 	people, nameFreqs, emailFreqs, err := FindPeople(
-		context.TODO(), "0.0.0.0:3306", peopleFile.Name(), newTestBlacklist(t), 12)
+		context.TODO(), "0.0.0.0:3306", peopleFile.Name(), ExtractionOptions{}, newTestBlacklist(t),
+		PopularityThresholds{}, BotDetectionOptions{}, 12, nil)
 	if err != nil {
 		return
 	}
 	expected := People{
 		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
-			SampleCommit: &Commit{"aaa", "repo1"}},
+			SampleCommit: &Commit{"aaa", "repo1", Signatures[0].Time}, Repositories: []string{"repo1"},
+			FirstCommit: Signatures[0].Time, LastCommit: Signatures[0].Time, Commits: 1, RecentCommits: 1,
+			AliasHistory: testAliasHistory("bob@google.com", "bob", Signatures[0].Time, Signatures[0].Time)},
 		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
-			SampleCommit: &Commit{"bbb", "repo2"}},
+			SampleCommit: &Commit{"bbb", "repo2", Signatures[1].Time}, Repositories: []string{"repo2"},
+			FirstCommit: Signatures[1].Time, LastCommit: Signatures[1].Time, Commits: 1, RecentCommits: 0,
+			AliasHistory: testAliasHistory("bob@google.com", "bob", Signatures[1].Time, Signatures[1].Time)},
 		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"},
-			SampleCommit: &Commit{"ccc", "repo1"}},
+			SampleCommit: &Commit{"ccc", "repo1", Signatures[2].Time}, Repositories: []string{"repo1"},
+			FirstCommit: Signatures[2].Time, LastCommit: Signatures[2].Time, Commits: 1, RecentCommits: 0,
+			AliasHistory: testAliasHistory("alice@google.com", "alice", Signatures[2].Time, Signatures[2].Time)},
 		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
-			SampleCommit: &Commit{"ddd", "repo1"}},
+			SampleCommit: &Commit{"ddd", "repo1", Signatures[3].Time}, Repositories: []string{"repo1"},
+			FirstCommit: Signatures[3].Time, LastCommit: Signatures[3].Time, Commits: 1, RecentCommits: 1,
+			AliasHistory: testAliasHistory("bob@google.com", "bob", Signatures[3].Time, Signatures[3].Time)},
 	}
-	require.Equal(t, expected, people)
-	require.Equal(t, map[string]*Frequency{"alice": {0, 1},
-		"admin": {1, 1}, "bob": {2, 4}}, nameFreqs)
-	require.Equal(t, map[string]*Frequency{"bob@google.com": {2, 3},
-		"alice@google.com": {0, 1}, "bad-email@domen": {0, 1},
-		"someone@google.com": {1, 1}}, emailFreqs)
+	require.Equal(t, fillSampleCommits(expected), people)
+	require.Equal(t, map[string]*Frequency{"alice": {Recent: 0, Total: 1, Last: Signatures[2].Time},
+		"admin": {Recent: 1, Total: 1, Last: Signatures[5].Time}, "bob": {Recent: 2, Total: 4, Last: Signatures[3].Time}}, nameFreqs)
+	require.Equal(t, map[string]*Frequency{"bob@google.com": {Recent: 2, Total: 3, Last: Signatures[3].Time},
+		"alice@google.com": {Recent: 0, Total: 1, Last: Signatures[2].Time}, "bad-email@domen": {Recent: 0, Total: 1, Last: Signatures[4].Time},
+		"someone@google.com": {Recent: 1, Total: 1, Last: Signatures[5].Time}}, emailFreqs)
+}
+
+func TestFindPeopleWithOptions(t *testing.T) {
+	req := require.New(t)
+	peopleFile, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(storeSignaturesOnDisk(peopleFile.Name(), Signatures))
+	expected, _, expectedFreqs, err := FindPeople(context.Background(), "", peopleFile.Name(),
+		ExtractionOptions{}, newTestBlacklist(t), PopularityThresholds{}, BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	people, _, emailFreqs, err := FindPeopleWithOptions(context.Background(), FindPeopleOptions{
+		CachePath: peopleFile.Name(),
+		Blacklist: newTestBlacklist(t),
+	})
+	req.NoError(err)
+	req.Equal(expected, people)
+	req.Equal(expectedFreqs, emailFreqs)
+
+	opts, err := NewFindPeopleOptions()
+	req.NoError(err)
+	req.Equal(12, opts.RecentMonths)
+	req.Equal(SourceGitbase, opts.Extraction.Source)
+	req.Equal("root:@tcp(0.0.0.0:3306)/gitbase", opts.ConnString)
+	req.Equal(runtime.NumCPU(), opts.Workers)
+	req.NotEmpty(opts.Blacklist.Names)
 }
 
 func TestReadPeopleFromDatabase(t *testing.T) {
@@ -187,25 +311,25 @@ func TestStoreAndReadPeopleOnDisk(t *testing.T) {
 	req.NoError(err)
 	peopleFileContent, err := ioutil.ReadFile(peopleFile.Name())
 	req.NoError(err)
-	expectedContent := `repo,name,email,hash,time
-repo1,Bob,Bob@google.com,aaa,` + Signatures[0].time.Format(time.RFC3339) + `
-repo2,Bob,Bob@google.com,bbb,` + Signatures[1].time.Format(time.RFC3339) + `
-repo1,Alice,alice@google.com,ccc,` + Signatures[2].time.Format(time.RFC3339) + `
-repo1,Bob,Bob@google.com,ddd,` + Signatures[3].time.Format(time.RFC3339) + `
-repo1,Bob,bad-email@domen,eee,` + Signatures[4].time.Format(time.RFC3339) + `
-repo1,admin,someone@google.com,fff,` + Signatures[5].time.Format(time.RFC3339) + `
+	expectedContent := `repo,name,email,hash,time,source,role,signing_key,activity
+repo1,Bob,Bob@google.com,aaa,` + Signatures[0].Time.Format(time.RFC3339) + `,,,,
+repo2,Bob,Bob@google.com,bbb,` + Signatures[1].Time.Format(time.RFC3339) + `,,,,
+repo1,Alice,alice@google.com,ccc,` + Signatures[2].Time.Format(time.RFC3339) + `,,,,
+repo1,Bob,Bob@google.com,ddd,` + Signatures[3].Time.Format(time.RFC3339) + `,,,,
+repo1,Bob,bad-email@domen,eee,` + Signatures[4].Time.Format(time.RFC3339) + `,,,,
+repo1,admin,someone@google.com,fff,` + Signatures[5].Time.Format(time.RFC3339) + `,,,,
 `
 	req.Equal(expectedContent, string(peopleFileContent))
 
-	commitsRead, err := readSignaturesFromDisk(peopleFile.Name())
+	commitsRead, err := readSignaturesFromDisk(nil, peopleFile.Name())
 	req.NoError(err)
-	expectedPersonsRead := []signatureWithRepo{
-		0: {repo: "repo1", name: "bob", email: "bob@google.com", hash: "aaa", time: Signatures[0].time},
-		1: {repo: "repo2", name: "bob", email: "bob@google.com", hash: "bbb", time: Signatures[1].time},
-		2: {repo: "repo1", name: "alice", email: "alice@google.com", hash: "ccc", time: Signatures[2].time},
-		3: {repo: "repo1", name: "bob", email: "bob@google.com", hash: "ddd", time: Signatures[3].time},
-		4: {repo: "repo1", name: "bob", email: "bad-email@domen", hash: "eee", time: Signatures[4].time},
-		5: {repo: "repo1", name: "admin", email: "someone@google.com", hash: "fff", time: Signatures[5].time},
+	expectedPersonsRead := []Signature{
+		0: {Repo: "repo1", Name: "bob", Email: "bob@google.com", Hash: "aaa", Time: Signatures[0].Time},
+		1: {Repo: "repo2", Name: "bob", Email: "bob@google.com", Hash: "bbb", Time: Signatures[1].Time},
+		2: {Repo: "repo1", Name: "alice", Email: "alice@google.com", Hash: "ccc", Time: Signatures[2].Time},
+		3: {Repo: "repo1", Name: "bob", Email: "bob@google.com", Hash: "ddd", Time: Signatures[3].Time},
+		4: {Repo: "repo1", Name: "bob", Email: "bad-email@domen", Hash: "eee", Time: Signatures[4].Time},
+		5: {Repo: "repo1", Name: "admin", Email: "someone@google.com", Hash: "fff", Time: Signatures[5].Time},
 	}
 	req.Equal(expectedPersonsRead, commitsRead)
 }
@@ -214,10 +338,12 @@ func TestWriteAndReadParquet(t *testing.T) {
 	tmpfile, cleanup := tempFile(t, "*.parquet")
 	defer cleanup()
 
-	expectedPeople, err := newPeople(Signatures, newTestBlacklist(t))
+	expectedPeople, err := newPeople(nil, Signatures, newTestBlacklist(t))
 	require.NoError(t, err)
 	for _, p := range expectedPeople {
 		p.SampleCommit = nil
+		p.SampleCommits = nil
+		p.Repositories = nil
 	}
 
 	err = expectedPeople.WriteToParquet(tmpfile.Name(), "")
@@ -233,15 +359,18 @@ func TestWriteAndReadParquetWithExternalID(t *testing.T) {
 	tmpfile, cleanup := tempFile(t, "*.parquet")
 	defer cleanup()
 
-	expectedPeople, err := newPeople(Signatures, newTestBlacklist(t))
+	expectedPeople, err := newPeople(nil, Signatures, newTestBlacklist(t))
 	require.NoError(t, err)
 	for _, p := range expectedPeople {
 		p.SampleCommit = nil
+		p.SampleCommits = nil
+		p.Repositories = nil
 	}
 
 	expectedIDProvider := "test"
-	expectedPeople[1].ExternalID = "username1"
-	expectedPeople[2].ExternalID = "username2"
+	expectedPeople[1].ExternalIDs = ExternalIDs{"test": "username1"}
+	expectedPeople[2].ExternalIDs = ExternalIDs{"test": "username2", "ldap": "user2"}
+	expectedPeople[3].IsBot = true
 
 	err = expectedPeople.WriteToParquet(tmpfile.Name(), expectedIDProvider)
 	require.NoError(t, err)
@@ -250,6 +379,90 @@ func TestWriteAndReadParquetWithExternalID(t *testing.T) {
 	require.Equal(t, expectedIDProvider, provider)
 }
 
+func TestWriteToCSV(t *testing.T) {
+	req := require.New(t)
+	tmpfile, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+
+	people := People{
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", "repo1"}}, Emails: []string{"alice@google.com"},
+			PrimaryName: "alice", PrimaryEmail: "alice@google.com", Commits: 3},
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}, {"Bob Smith", ""}},
+			Emails: []string{"bob@google.com", "bob@gmail.com"}, ExternalIDs: ExternalIDs{"github": "bob"}, IsBot: true, Commits: 7},
+	}
+	req.NoError(people.WriteToCSV(tmpfile.Name(), "github"))
+	data, err := ioutil.ReadFile(tmpfile.Name())
+	req.NoError(err)
+	req.Equal(`id,primary_name,primary_email,external_id_provider,external_id,is_bot,commits,names,emails
+1,,,github,bob,true,7,bob; Bob Smith,bob@google.com; bob@gmail.com
+2,alice,alice@google.com,,,false,3,alice,alice@google.com
+`, string(data))
+}
+
+func TestSignatureSources(t *testing.T) {
+	req := require.New(t)
+	signatures := []Signature{
+		{Repo: "repo1", Name: "Bob", Email: "bob@google.com", Hash: "aaa", Source: SourceGit},
+		{Repo: "list", Name: "Bob", Email: "bob@google.com", Hash: "bbb", Source: SourceMbox},
+		{Repo: "repo1", Name: "Bob", Email: "bob@gmail.com", Hash: "ccc"},
+	}
+	people, err := newPeople(nil, signatures, newTestBlacklist(t))
+	req.NoError(err)
+	req.Equal(map[string][]SourceKind{"bob@google.com": {SourceGit}}, people[1].EmailSources)
+	req.Equal(map[string][]SourceKind{"bob": {SourceGit}}, people[1].NameSources)
+	req.Nil(people[3].EmailSources)
+	_, err = people.Merge(1, 2, 3)
+	req.NoError(err)
+	req.Equal(map[string][]SourceKind{"bob@google.com": {SourceGit, SourceMbox}},
+		people[1].EmailSources)
+	req.Equal(map[string][]SourceKind{"bob": {SourceGit, SourceMbox}}, people[1].NameSources)
+
+	tmpfile, cleanup := tempFile(t, "*.parquet")
+	defer cleanup()
+	req.NoError(people.WriteToParquet(tmpfile.Name(), ""))
+	peopleRead, _, err := readFromParquet(tmpfile.Name())
+	req.NoError(err)
+	req.Equal(people[1].EmailSources, peopleRead[1].EmailSources)
+	req.Equal(people[1].NameSources, peopleRead[1].NameSources)
+
+	// the caches without the source column are still supported
+	csvFile, cleanupCSV := tempFile(t, "*.csv")
+	defer cleanupCSV()
+	_, err = csvFile.WriteString("repo,name,email,hash,time\n" +
+		"repo1,Bob,bob@google.com,aaa,2019-01-01T00:00:00Z\n")
+	req.NoError(err)
+	signatures, err = readSignaturesFromDisk(nil, csvFile.Name())
+	req.NoError(err)
+	req.Len(signatures, 1)
+	req.Equal(SourceKind(""), signatures[0].Source)
+	req.NoError(storeSignaturesOnDisk(csvFile.Name(), []Signature{{
+		Repo: "repo1", Name: "bob", Email: "bob@google.com", Hash: "aaa", Source: SourceGitbase}}))
+	signatures, err = readSignaturesFromDisk(nil, csvFile.Name())
+	req.NoError(err)
+	req.Equal(SourceGitbase, signatures[0].Source)
+}
+
+func TestFilterCommitters(t *testing.T) {
+	req := require.New(t)
+	commits := []Signature{
+		{Repo: "repo1", Name: "bob", Email: "bob@google.com", Hash: "aaa", Role: RoleAuthor},
+		{Repo: "repo1", Name: "bob", Email: "Bob@google.com", Hash: "aaa", Role: RoleCommitter},
+		{Repo: "repo1", Name: "alice", Email: "alice@google.com", Hash: "bbb"},
+		{Repo: "repo1", Name: "github", Email: "noreply@github.com", Hash: "bbb", Role: RoleCommitter},
+	}
+	req.Equal([]Signature{commits[0], commits[2]}, filterCommitters(commits, false))
+	req.Equal(commits, filterCommitters(commits, true))
+	req.Empty(filterCommitters(nil, false))
+
+	csvFile, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(storeSignaturesOnDisk(csvFile.Name(), commits))
+	signatures, err := readSignaturesFromDisk(nil, csvFile.Name())
+	req.NoError(err)
+	req.Equal([]SignatureRole{RoleAuthor, RoleCommitter, "", RoleCommitter},
+		[]SignatureRole{signatures[0].Role, signatures[1].Role, signatures[2].Role, signatures[3].Role})
+}
+
 func TestCleanName(t *testing.T) {
 	require := require.New(t)
 	for _, names := range [][]string{
@@ -257,6 +470,8 @@ func TestCleanName(t *testing.T) {
 		{"name  	name  ", "name name"},
 		{"name  	name\nsurname", "name name surname"},
 		{"name　name", "name name"}, // special space %u3000
+		{"Łukasz", "lukasz"},
+		{"Иван Петров", "ivan petrov"},
 	} {
 		cName, err := cleanName(names[0])
 		require.NoError(err)
@@ -280,18 +495,70 @@ func TestNormalizeSpaces(t *testing.T) {
 }
 
 func TestCountFreqs(t *testing.T) {
-	freqs, err := countFreqs(Signatures, func(c signatureWithRepo) string { return c.name },
-		cleanName, time.Now().AddDate(0, -19, 0))
+	freqs, err := countFreqs(nil, Signatures, func(c Signature) string { return c.Name },
+		cleanName, time.Now().AddDate(0, -19, 0), FrequencyOptions{})
 	require.NoError(t, err)
-	require.Equal(t, map[string]*Frequency{"alice": {1, 1}, "admin": {1, 1}, "bob": {3, 4}}, freqs)
+	require.Equal(t, map[string]*Frequency{"alice": {Recent: 1, Total: 1, Last: Signatures[2].Time},
+		"admin": {Recent: 1, Total: 1, Last: Signatures[5].Time}, "bob": {Recent: 3, Total: 4, Last: Signatures[3].Time}}, freqs)
 }
 
 func TestGetStats(t *testing.T) {
-	nameFreqs, emailFreqs, err := getStats(Signatures, time.Now().AddDate(0, -12, 0))
+	nameFreqs, emailFreqs, err := getStats(nil, Signatures, time.Now().AddDate(0, -12, 0), FrequencyOptions{})
 	require.NoError(t, err)
-	require.Equal(t, map[string]*Frequency{"alice": {0, 1}, "admin": {1, 1}, "bob": {2, 4}},
-		nameFreqs)
-	require.Equal(t, map[string]*Frequency{"bob@google.com": {2, 3},
-		"alice@google.com": {0, 1}, "bad-email@domen": {0, 1},
-		"someone@google.com": {1, 1}}, emailFreqs)
+	require.Equal(t, map[string]*Frequency{"alice": {Recent: 0, Total: 1, Last: Signatures[2].Time},
+		"admin": {Recent: 1, Total: 1, Last: Signatures[5].Time}, "bob": {Recent: 2, Total: 4, Last: Signatures[3].Time}}, nameFreqs)
+	require.Equal(t, map[string]*Frequency{"bob@google.com": {Recent: 2, Total: 3, Last: Signatures[3].Time},
+		"alice@google.com": {Recent: 0, Total: 1, Last: Signatures[2].Time}, "bad-email@domen": {Recent: 0, Total: 1, Last: Signatures[4].Time},
+		"someone@google.com": {Recent: 1, Total: 1, Last: Signatures[5].Time}}, emailFreqs)
+}
+
+func TestCountFreqsWindowsDecay(t *testing.T) {
+	req := require.New(t)
+	const year = 365 * 24 * time.Hour
+	opts := FrequencyOptions{Windows: []time.Duration{year / 4, year}, HalfLife: year}
+	freqs, err := countFreqs(nil, Signatures, func(c Signature) string { return c.Name },
+		cleanName, time.Now().AddDate(0, -12, 0), opts)
+	req.NoError(err)
+	req.Equal([]int{1, 2}, freqs["bob"].Windows)
+	req.Equal([]int{0, 0}, freqs["alice"].Windows)
+	req.Equal([]int{0, 1}, freqs["admin"].Windows)
+	// 6, 18, 2 and 20 months ago
+	req.InDelta(2.27, freqs["bob"].Decayed, 0.01)
+	req.InDelta(0.42, freqs["alice"].Decayed, 0.01)
+
+	freqs, err = countFreqs(nil, []Signature{{Name: "bob"}}, func(c Signature) string { return c.Name },
+		cleanName, time.Now(), FrequencyOptions{HalfLife: year})
+	req.NoError(err)
+	req.Equal(&Frequency{Total: 1, Decayed: 1}, freqs["bob"])
+
+	freq := &Frequency{Recent: 1, Total: 2, Windows: []int{1}, Decayed: 1.5}
+	freq.merge(&Frequency{Total: 1, Windows: []int{2, 3}, Decayed: 0.5})
+	req.Equal(&Frequency{Recent: 1, Total: 3, Windows: []int{3, 3}, Decayed: 2}, freq)
+}
+
+func BenchmarkNewPeople(b *testing.B) {
+	blacklist, err := LoadBlacklist()
+	if err != nil {
+		b.Fatal(err)
+	}
+	runScaleBenchmarks(b, func(b *testing.B, signatures []Signature) {
+		for i := 0; i < b.N; i++ {
+			if _, err := newPeople(nil, signatures, blacklist); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCountFreqs(b *testing.B) {
+	recentStartTime := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	runScaleBenchmarks(b, func(b *testing.B, signatures []Signature) {
+		for i := 0; i < b.N; i++ {
+			_, err := countFreqs(nil, signatures, func(c Signature) string { return c.Name },
+				cleanName, recentStartTime, FrequencyOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
 }
@@ -0,0 +1,81 @@
+package idmatch
+
+import (
+	"context"
+	"time"
+)
+
+// ProgressReporter receives the progress of a long stage of the pipeline: the stage name,
+// the number of processed items and the total number of items. total is 0 if it is not known
+// in advance. The last call of each stage has processed equal to the final number of items.
+type ProgressReporter func(stage string, processed, total int)
+
+// progressInterval is the number of items between two consecutive progress reports and
+// context cancellation checks.
+const progressInterval = 1000
+
+// progress carries the context and the progress reporter of the pipeline through the stages.
+// A nil *progress never cancels and reports nothing.
+type progress struct {
+	ctx    context.Context
+	report ProgressReporter
+}
+
+// stageProgress tracks a single stage, see progress.stage.
+type stageProgress struct {
+	*progress
+	name      string
+	total     int
+	processed int
+	started   time.Time
+}
+
+// stage starts tracking the stage with the given name and total number of items.
+func (p *progress) stage(name string, total int) *stageProgress {
+	return &stageProgress{progress: p, name: name, total: total, started: time.Now()}
+}
+
+// context returns the context of the pipeline.
+func (p *progress) context() context.Context {
+	if p == nil || p.ctx == nil {
+		return context.Background()
+	}
+	return p.ctx
+}
+
+// err returns the context error if the pipeline was cancelled.
+func (p *progress) err() error {
+	if p == nil || p.ctx == nil {
+		return nil
+	}
+	return p.ctx.Err()
+}
+
+// tick counts one processed item. Every progressInterval items the progress is reported and
+// the context is checked: the returned error is not nil if the pipeline was cancelled.
+// A nil *stageProgress tracks nothing.
+func (s *stageProgress) tick() error {
+	if s == nil {
+		return nil
+	}
+	s.processed++
+	if s.processed%progressInterval != 0 {
+		return nil
+	}
+	if s.progress != nil && s.report != nil {
+		s.report(s.name, s.processed, s.total)
+	}
+	return s.err()
+}
+
+// done reports the final number of processed items and records the stage duration in
+// stageDurationMetric.
+func (s *stageProgress) done() {
+	if s == nil {
+		return
+	}
+	stageDurationMetric.Observe(time.Since(s.started).Seconds(), s.name)
+	if s.progress != nil && s.report != nil {
+		s.report(s.name, s.processed, s.total)
+	}
+}
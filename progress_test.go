@@ -0,0 +1,68 @@
+package idmatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type progressRecord struct {
+	stage            string
+	processed, total int
+}
+
+func TestStageProgress(t *testing.T) {
+	req := require.New(t)
+	var records []progressRecord
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	prog := &progress{ctx, func(stage string, processed, total int) {
+		records = append(records, progressRecord{stage, processed, total})
+	}}
+	stage := prog.stage("test", 2500)
+	for i := 0; i < 2500; i++ {
+		req.NoError(stage.tick())
+	}
+	stage.done()
+	req.Equal([]progressRecord{{"test", 1000, 2500}, {"test", 2000, 2500}, {"test", 2500, 2500}}, records)
+
+	cancel()
+	stage = prog.stage("cancelled", 0)
+	for i := 0; i < progressInterval-1; i++ {
+		req.NoError(stage.tick())
+	}
+	req.Equal(context.Canceled, stage.tick())
+
+	var nilProgress *progress
+	stage = nilProgress.stage("nil", 0)
+	for i := 0; i < progressInterval; i++ {
+		req.NoError(stage.tick())
+	}
+	stage.done()
+	var nilStage *stageProgress
+	req.NoError(nilStage.tick())
+	nilStage.done()
+}
+
+func TestReducePeopleProgress(t *testing.T) {
+	req := require.New(t)
+	people, err := newPeople(nil, Signatures, newTestBlacklist(t))
+	req.NoError(err)
+	var stages []string
+	opts := ReduceOptions{MaxIdentities: 100, Progress: func(stage string, processed, total int) {
+		stages = append(stages, stage)
+	}}
+	req.NoError(ReducePeople(context.Background(), people, nil, newTestBlacklist(t), opts))
+	req.Equal([]string{"computing matching keys", "matching by email", "matching by name", "merging"}, stages)
+
+	people, err = newPeople(nil, Signatures, newTestBlacklist(t))
+	req.NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := int64(0); i < progressInterval; i++ {
+		people[1000+i] = &Person{ID: 1000 + i, NamesWithRepos: []NameWithRepo{{"name", ""}},
+			Emails: []string{"email@google.com"}}
+	}
+	req.Equal(context.Canceled, ReducePeople(ctx, people, nil, newTestBlacklist(t), opts))
+}
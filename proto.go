@@ -0,0 +1,61 @@
+package idmatch
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// MarshalProto encodes the people as the People message of people.proto, one Person per person
+// sorted by ID. The external ID of externalIDProvider is preferred, see ExternalIDs.Primary.
+func (p People) MarshalProto(externalIDProvider string) []byte {
+	var result []byte
+	for _, id := range peopleIDs(p) {
+		person := p[id]
+		provider, externalID := person.ExternalIDs.Primary(externalIDProvider)
+		var message []byte
+		message = appendProtoInt(message, 1, id)
+		for _, name := range person.NamesWithRepos {
+			var item []byte
+			item = appendProtoString(item, 1, name.Name)
+			item = appendProtoString(item, 2, name.Repo)
+			message = protowire.AppendTag(message, 2, protowire.BytesType)
+			message = protowire.AppendBytes(message, item)
+		}
+		for _, email := range person.Emails {
+			message = protowire.AppendTag(message, 3, protowire.BytesType)
+			message = protowire.AppendString(message, email)
+		}
+		message = appendProtoString(message, 4, person.PrimaryName)
+		message = appendProtoString(message, 5, person.PrimaryEmail)
+		message = appendProtoString(message, 6, provider)
+		message = appendProtoString(message, 7, externalID)
+		if person.IsBot {
+			message = appendProtoInt(message, 8, 1)
+		}
+		message = appendProtoInt(message, 9, timeToMillis(person.FirstCommit))
+		message = appendProtoInt(message, 10, timeToMillis(person.LastCommit))
+		message = appendProtoInt(message, 11, int64(person.Commits))
+		message = appendProtoInt(message, 12, int64(person.RecentCommits))
+		message = appendProtoInt(message, 13, int64(len(person.Repositories)))
+		result = protowire.AppendTag(result, 1, protowire.BytesType)
+		result = protowire.AppendBytes(result, message)
+	}
+	return result
+}
+
+// appendProtoString appends the singular string field, omitting the default value like proto3.
+func appendProtoString(b []byte, number protowire.Number, value string) []byte {
+	if value == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, number, protowire.BytesType)
+	return protowire.AppendString(b, value)
+}
+
+// appendProtoInt appends the singular int64 or bool field, omitting the default value like proto3.
+func appendProtoInt(b []byte, number protowire.Number, value int64) []byte {
+	if value == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, number, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(value))
+}
@@ -0,0 +1,65 @@
+package idmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// parseTestProto decodes the message to the values of each field: the varints are uint64 and
+// the length-delimited fields are []byte.
+func parseTestProto(t *testing.T, b []byte) map[protowire.Number][]interface{} {
+	fields := map[protowire.Number][]interface{}{}
+	for len(b) > 0 {
+		number, kind, n := protowire.ConsumeTag(b)
+		require.True(t, n > 0)
+		b = b[n:]
+		switch kind {
+		case protowire.VarintType:
+			value, n := protowire.ConsumeVarint(b)
+			require.True(t, n > 0)
+			fields[number] = append(fields[number], value)
+			b = b[n:]
+		case protowire.BytesType:
+			value, n := protowire.ConsumeBytes(b)
+			require.True(t, n > 0)
+			fields[number] = append(fields[number], value)
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", kind)
+		}
+	}
+	return fields
+}
+
+func TestPeopleMarshalProto(t *testing.T) {
+	req := require.New(t)
+	people := parseTestProto(t, newEncoderTestPeople().MarshalProto("github"))
+	req.Len(people, 1)
+	req.Len(people[1], 2)
+
+	alice := parseTestProto(t, people[1][0].([]byte))
+	req.Equal([]interface{}{uint64(1)}, alice[1])
+	req.Len(alice[2], 2)
+	name := parseTestProto(t, alice[2][1].([]byte))
+	req.Equal([]interface{}{[]byte("al")}, name[1])
+	req.Equal([]interface{}{[]byte("repo1")}, name[2])
+	req.Equal([]interface{}{[]byte("alice@google.com"), []byte("al@google.com")}, alice[3])
+	req.Nil(alice[6])
+	req.Nil(alice[7])
+	req.Equal([]interface{}{uint64(1)}, alice[8])
+	req.Nil(alice[9])
+
+	bob := parseTestProto(t, people[1][1].([]byte))
+	req.Equal([]interface{}{uint64(2)}, bob[1])
+	req.Equal([]interface{}{[]byte("bob")}, bob[4])
+	req.Equal([]interface{}{[]byte("github")}, bob[6])
+	req.Equal([]interface{}{[]byte("bob_username")}, bob[7])
+	req.Nil(bob[8])
+	req.Equal([]interface{}{uint64(1546300800000)}, bob[9])
+	req.Equal([]interface{}{uint64(3)}, bob[11])
+	req.Equal([]interface{}{uint64(2)}, bob[13])
+
+	req.Empty(People{}.MarshalProto("github"))
+}
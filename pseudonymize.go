@@ -0,0 +1,158 @@
+package idmatch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// PseudonymizedDomain is the domain of the pseudonymized emails, which is reserved to never
+// resolve.
+const PseudonymizedDomain = "pseudonymized.invalid"
+
+// Pseudonymizer replaces the names and the emails with their salted HMAC-SHA256 digests.
+// The same value is always replaced with the same digest, so the pseudonymized identities can
+// still be joined together, while the original values cannot be recovered without the salt.
+type Pseudonymizer struct {
+	salt []byte
+}
+
+// NewPseudonymizer creates the Pseudonymizer with the secret salt, which must not be empty.
+func NewPseudonymizer(salt string) (*Pseudonymizer, error) {
+	if salt == "" {
+		return nil, errors.New("the pseudonymization salt is empty")
+	}
+	return &Pseudonymizer{salt: []byte(salt)}, nil
+}
+
+// digest returns the hex HMAC of the value. The kind separates the names from the emails so
+// that the equal strings of different kinds are not linked.
+func (p *Pseudonymizer) digest(kind, value string) string {
+	mac := hmac.New(sha256.New, p.salt)
+	mac.Write([]byte(kind))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Name returns the pseudonym of the name. The empty name stays empty.
+func (p *Pseudonymizer) Name(name string) string {
+	if name == "" {
+		return ""
+	}
+	return p.digest("name", name)
+}
+
+// ExternalID returns the pseudonym of the external user name. The empty name stays empty.
+func (p *Pseudonymizer) ExternalID(id string) string {
+	if id == "" {
+		return ""
+	}
+	return p.digest("external_id", id)
+}
+
+// Email returns the pseudonym of the email at PseudonymizedDomain, so that it remains
+// a syntactically valid email. The emails are compared case-insensitively. The empty email
+// stays empty.
+func (p *Pseudonymizer) Email(email string) string {
+	if email == "" {
+		return ""
+	}
+	return p.digest("email", strings.ToLower(email)) + "@" + PseudonymizedDomain
+}
+
+// Pseudonymize replaces the names, the emails and the external user names of the people with
+// their pseudonyms, including the merge evidence. The sample commits are removed because they
+// lead back to the original signatures. The other fields, such as the repositories, are kept.
+func (p People) Pseudonymize(pseudonymizer *Pseudonymizer) {
+	for _, person := range p {
+		for i, name := range person.NamesWithRepos {
+			person.NamesWithRepos[i].Name = pseudonymizer.Name(name.Name)
+		}
+		sort.Slice(person.NamesWithRepos, func(i, j int) bool {
+			return person.NamesWithRepos[i].String() < person.NamesWithRepos[j].String()
+		})
+		for i, email := range person.Emails {
+			person.Emails[i] = pseudonymizer.Email(email)
+		}
+		sort.Strings(person.Emails)
+		person.SampleCommit = nil
+		person.SampleCommits = nil
+		person.PrimaryName = pseudonymizer.Name(person.PrimaryName)
+		person.PrimaryEmail = pseudonymizer.Email(person.PrimaryEmail)
+		for provider, id := range person.ExternalIDs {
+			person.ExternalIDs[provider] = pseudonymizer.ExternalID(id)
+		}
+		for i := range person.ExternalAccounts {
+			person.ExternalAccounts[i].ID = pseudonymizer.ExternalID(person.ExternalAccounts[i].ID)
+		}
+		person.NameSources = replaceSourceKeys(person.NameSources, pseudonymizer.Name)
+		person.EmailSources = replaceSourceKeys(person.EmailSources, pseudonymizer.Email)
+		person.AliasHistory.Names = replaceSpanKeys(person.AliasHistory.Names, pseudonymizer.Name)
+		person.AliasHistory.Emails = replaceSpanKeys(person.AliasHistory.Emails, pseudonymizer.Email)
+		for i := range person.MergeEvidence {
+			evidence := person.MergeEvidence[i].Evidence
+			for j, ev := range evidence {
+				evidence[j].Value = replaceEvidenceValue(
+					ev, pseudonymizer.Email, pseudonymizer.Name, pseudonymizer.ExternalID)
+			}
+		}
+	}
+}
+
+// replaceEvidenceValue returns the value of the evidence with the emails, the names and
+// the external ids replaced by the given functions. The evidence of the trailer roles is either
+// an email or a name, and the evidence of the constraints joins two constraint keys.
+func replaceEvidenceValue(ev Evidence, email, name, externalID func(string) string) string {
+	switch ev.Kind {
+	case EvidenceEmail:
+		return email(ev.Value)
+	case EvidenceName:
+		return name(ev.Value)
+	case EvidenceExternalID:
+		return externalID(ev.Value)
+	case EvidenceSigningKey, EvidenceActivity, EvidenceRepositories, EvidenceClassifier,
+		EvidenceReview, EvidenceStyle:
+		return ev.Value
+	case EvidenceDomainChange:
+		emails := strings.Split(ev.Value, " -> ")
+		for i, value := range emails {
+			emails[i] = email(value)
+		}
+		return strings.Join(emails, " -> ")
+	case EvidenceConstraint:
+		keys := strings.Split(ev.Value, " = ")
+		for i, key := range keys {
+			if kind := strings.SplitN(key, ":", 2); len(kind) == 2 {
+				switch kind[0] {
+				case "email":
+					keys[i] = "email:" + email(kind[1])
+				case "name":
+					keys[i] = "name:" + name(kind[1])
+				}
+			}
+		}
+		return strings.Join(keys, " = ")
+	}
+	if strings.Contains(ev.Value, "@") {
+		return email(ev.Value)
+	}
+	return name(ev.Value)
+}
+
+// replaceSourceKeys returns the copy of Person.EmailSources or Person.NameSources with
+// the keys replaced by the given function.
+func replaceSourceKeys(sources map[string][]SourceKind,
+	replace func(string) string) map[string][]SourceKind {
+	if sources == nil {
+		return nil
+	}
+	result := make(map[string][]SourceKind, len(sources))
+	for key, kinds := range sources {
+		result[replace(key)] = kinds
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+package idmatch
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPseudonymizer(t *testing.T) {
+	req := require.New(t)
+	_, err := NewPseudonymizer("")
+	req.Error(err)
+	p1, err := NewPseudonymizer("salt")
+	req.NoError(err)
+	p2, err := NewPseudonymizer("pepper")
+	req.NoError(err)
+
+	email := p1.Email("bob@google.com")
+	req.True(strings.HasSuffix(email, "@"+PseudonymizedDomain))
+	req.NotContains(email, "bob")
+	req.Equal(email, p1.Email("Bob@Google.com"))
+	req.NotEqual(email, p2.Email("bob@google.com"))
+	req.NotEqual(p1.Name("bob"), p2.Name("bob"))
+	req.Equal(p1.Name("bob"), p1.Name("bob"))
+	req.NotEqual(p1.Name("bob"), p1.ExternalID("bob"))
+	req.Len(p1.Name("bob"), 32)
+	req.Equal("", p1.Name(""))
+	req.Equal("", p1.Email(""))
+}
+
+func TestPeoplePseudonymize(t *testing.T) {
+	req := require.New(t)
+	p, err := NewPseudonymizer("salt")
+	req.NoError(err)
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", "repo1"}}, Emails: []string{"bob@google.com"},
+			PrimaryName: "bob", PrimaryEmail: "bob@google.com", ExternalIDs: ExternalIDs{"github": "bobby"},
+			SampleCommit: &Commit{Hash: "abc", Repo: "repo1"}, Repositories: []string{"repo1"},
+			EmailSources: map[string][]SourceKind{"bob@google.com": {SourceGit}},
+			MergeEvidence: []IdentityEdge{{From: 1, To: 2, Evidence: []Evidence{
+				{EvidenceEmail, "bob@google.com", 1}, {EvidenceSigningKey, "ABCD", 1},
+				{EvidenceConstraint, "email:bob@google.com = name:bob", 1},
+				{EvidenceKind(RoleSignedOffBy), "bob", 0.5}}}}},
+	}
+	people.Pseudonymize(p)
+	person := people[1]
+	req.Equal([]NameWithRepo{{p.Name("bob"), "repo1"}}, person.NamesWithRepos)
+	req.Equal([]string{p.Email("bob@google.com")}, person.Emails)
+	req.Equal(p.Name("bob"), person.PrimaryName)
+	req.Equal(p.Email("bob@google.com"), person.PrimaryEmail)
+	req.Equal(p.ExternalID("bobby"), person.ExternalIDs["github"])
+	req.Nil(person.SampleCommit)
+	req.Equal([]string{"repo1"}, person.Repositories)
+	req.Equal(map[string][]SourceKind{p.Email("bob@google.com"): {SourceGit}}, person.EmailSources)
+	req.Equal([]Evidence{
+		{EvidenceEmail, p.Email("bob@google.com"), 1}, {EvidenceSigningKey, "ABCD", 1},
+		{EvidenceConstraint, "email:" + p.Email("bob@google.com") + " = name:" + p.Name("bob"), 1},
+		{EvidenceKind(RoleSignedOffBy), p.Name("bob"), 0.5},
+	}, person.MergeEvidence[0].Evidence)
+}
@@ -0,0 +1,147 @@
+package idmatch
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PeopleIndex answers the queries about the people without scanning all of them. Each index is
+// built on the first query which needs it, so the people must not change after People.Index.
+// The queries are safe to call concurrently.
+type PeopleIndex struct {
+	people People
+
+	emailsOnce      sync.Once
+	emails          map[string][]*Person
+	namesOnce       sync.Once
+	names           map[NameWithRepo][]*Person
+	externalIDsOnce sync.Once
+	externalIDs     map[ExternalAccount]*Person
+	trigramsOnce    sync.Once
+	trigrams        map[string][]int
+	aliases         []searchAlias
+}
+
+// Index returns the read-only query helpers of the people, see PeopleIndex.
+func (p People) Index() *PeopleIndex {
+	return &PeopleIndex{people: p}
+}
+
+// FindByEmail returns the person with the email or nil. The email is lowercased the same way
+// as the emails of the signatures.
+func (index *PeopleIndex) FindByEmail(email string) *Person {
+	if people := index.findAllByEmail(email); len(people) > 0 {
+		return people[0]
+	}
+	return nil
+}
+
+// FindBySignature returns the person whom the signature was matched to or nil. Several people
+// can share an email which was not merged by, e.g. a popular one, so the person who has both
+// the email and the name wins over the first person with the email, see FindByEmail.
+func (index *PeopleIndex) FindBySignature(name, repo, email string) *Person {
+	people := index.findAllByEmail(email)
+	if len(people) < 2 {
+		if len(people) == 0 {
+			return nil
+		}
+		return people[0]
+	}
+	for _, named := range index.FindByName(name, repo) {
+		for _, person := range people {
+			if person == named {
+				return person
+			}
+		}
+	}
+	return people[0]
+}
+
+// findAllByEmail returns the people with the email sorted by ID.
+func (index *PeopleIndex) findAllByEmail(email string) []*Person {
+	index.emailsOnce.Do(func() {
+		index.emails = map[string][]*Person{}
+		for _, id := range peopleIDs(index.people) {
+			person := index.people[id]
+			for _, email := range person.Emails {
+				people := index.emails[email]
+				if len(people) > 0 && people[len(people)-1] == person {
+					continue
+				}
+				index.emails[email] = append(people, person)
+			}
+		}
+	})
+	if people := index.emails[email]; len(people) > 0 {
+		return people
+	}
+	return index.emails[strings.TrimSpace(normalizeSpaces(strings.ToLower(email)))]
+}
+
+// FindByName returns the people with the name sorted by ID. The popular names are scoped to
+// the repositories, see Person.NamesWithRepos, so repo selects the people who used the name in
+// that repository in addition to the people whose name is not popular. The name is cleaned
+// the same way as the names of the signatures.
+func (index *PeopleIndex) FindByName(name, repo string) []*Person {
+	index.namesOnce.Do(func() {
+		index.names = map[NameWithRepo][]*Person{}
+		for _, id := range peopleIDs(index.people) {
+			person := index.people[id]
+			seen := map[NameWithRepo]struct{}{}
+			for _, nameWithRepo := range person.NamesWithRepos {
+				if _, exists := seen[nameWithRepo]; exists {
+					continue
+				}
+				seen[nameWithRepo] = struct{}{}
+				index.names[nameWithRepo] = append(index.names[nameWithRepo], person)
+			}
+		}
+	})
+	find := func(name string) []*Person {
+		result := append([]*Person(nil), index.names[NameWithRepo{name, ""}]...)
+		if repo == "" {
+			return result
+		}
+		for _, person := range index.names[NameWithRepo{name, repo}] {
+			i := sort.Search(len(result), func(i int) bool { return result[i].ID >= person.ID })
+			if i < len(result) && result[i].ID == person.ID {
+				continue
+			}
+			result = append(result, nil)
+			copy(result[i+1:], result[i:])
+			result[i] = person
+		}
+		return result
+	}
+	if result := find(name); len(result) > 0 {
+		return result
+	}
+	if cleaned, err := nameCleaner.Clean(name); err == nil && cleaned != name {
+		return find(cleaned)
+	}
+	return nil
+}
+
+// FindByExternalID returns the person with the ID at the external identity provider or nil.
+// Both Person.ExternalIDs and Person.ExternalAccounts are searched.
+func (index *PeopleIndex) FindByExternalID(provider, id string) *Person {
+	index.externalIDsOnce.Do(func() {
+		index.externalIDs = map[ExternalAccount]*Person{}
+		add := func(account ExternalAccount, person *Person) {
+			if _, exists := index.externalIDs[account]; !exists {
+				index.externalIDs[account] = person
+			}
+		}
+		for _, personID := range peopleIDs(index.people) {
+			person := index.people[personID]
+			for provider, id := range person.ExternalIDs {
+				add(ExternalAccount{Provider: provider, ID: id}, person)
+			}
+			for _, account := range person.ExternalAccounts {
+				add(account, person)
+			}
+		}
+	})
+	return index.externalIDs[ExternalAccount{Provider: provider, ID: id}]
+}
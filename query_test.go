@@ -0,0 +1,66 @@
+package idmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newQueryTestPeople() People {
+	return People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}, {"robert", ""}},
+			Emails: []string{"bob@google.com", "bob@gmail.com"}, ExternalIDs: ExternalIDs{"github": "bob"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alex", "repo1"}}, Emails: []string{"alex@google.com"},
+			ExternalAccounts: []ExternalAccount{{"github", "alex"}, {"github", "alex2"}}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alex", "repo2"}, {"alex", ""}},
+			Emails: []string{"alex@gmail.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"alex", "repo1"}}, Emails: []string{"alex@yahoo.com"}},
+	}
+}
+
+func TestPeopleIndexFindByEmail(t *testing.T) {
+	req := require.New(t)
+	people := newQueryTestPeople()
+	index := people.Index()
+	req.Equal(people[1], index.FindByEmail("bob@gmail.com"))
+	req.Equal(people[1], index.FindByEmail(" Bob@Google.com"))
+	req.Equal(people[3], index.FindByEmail("alex@gmail.com"))
+	req.Nil(index.FindByEmail("eve@google.com"))
+}
+
+func TestPeopleIndexFindBySignature(t *testing.T) {
+	req := require.New(t)
+	people := newQueryTestPeople()
+	people[5] = &Person{ID: 5, NamesWithRepos: []NameWithRepo{{"carol", ""}},
+		Emails: []string{"dev@localhost", "carol@google.com"}}
+	people[6] = &Person{ID: 6, NamesWithRepos: []NameWithRepo{{"dave", ""}},
+		Emails: []string{"dev@localhost"}}
+	index := people.Index()
+	req.Equal(people[5], index.FindBySignature("Carol", "repo1", "dev@localhost"))
+	req.Equal(people[6], index.FindBySignature("dave", "repo1", "dev@localhost"))
+	req.Equal(people[5], index.FindBySignature("eve", "repo1", "dev@localhost"))
+	req.Equal(people[1], index.FindBySignature("robert", "repo1", "Bob@Google.com"))
+	req.Nil(index.FindBySignature("eve", "repo1", "eve@google.com"))
+}
+
+func TestPeopleIndexFindByName(t *testing.T) {
+	req := require.New(t)
+	people := newQueryTestPeople()
+	index := people.Index()
+	req.Equal([]*Person{people[1]}, index.FindByName("robert", ""))
+	req.Equal([]*Person{people[1]}, index.FindByName("Robert", "repo1"))
+	req.Equal([]*Person{people[3]}, index.FindByName("alex", ""))
+	req.Equal([]*Person{people[2], people[3], people[4]}, index.FindByName("alex", "repo1"))
+	req.Equal([]*Person{people[3]}, index.FindByName("alex", "repo2"))
+	req.Empty(index.FindByName("eve", "repo1"))
+}
+
+func TestPeopleIndexFindByExternalID(t *testing.T) {
+	req := require.New(t)
+	people := newQueryTestPeople()
+	index := people.Index()
+	req.Equal(people[1], index.FindByExternalID("github", "bob"))
+	req.Equal(people[2], index.FindByExternalID("github", "alex2"))
+	req.Nil(index.FindByExternalID("gitlab", "bob"))
+	req.Nil(index.FindByExternalID("github", "eve"))
+}
@@ -0,0 +1,99 @@
+package idmatch
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// repoPatternRegexpPrefix marks the RepoFilter patterns which are regular expressions instead of
+// the glob patterns.
+const repoPatternRegexpPrefix = "re:"
+
+// RepoFilter scopes the matching to a subset of the repositories, e.g. without the vendored
+// mirrors and the archived repositories. A repository passes if it matches any of the included
+// patterns, or there are none, and none of the excluded ones. The nil filter passes all
+// the repositories.
+type RepoFilter struct {
+	include []func(repo string) bool
+	exclude []func(repo string) bool
+}
+
+// NewRepoFilter compiles the patterns. Each pattern is either a glob of path.Match, where "*"
+// does not match "/", e.g. "github.com/src-d/*", or a regular expression after "re:", e.g.
+// "re:-archive$", which matches any part of the repository.
+func NewRepoFilter(include, exclude []string) (*RepoFilter, error) {
+	compile := func(patterns []string) ([]func(string) bool, error) {
+		var result []func(string) bool
+		for _, pattern := range patterns {
+			if strings.HasPrefix(pattern, repoPatternRegexpPrefix) {
+				re, err := regexp.Compile(strings.TrimPrefix(pattern, repoPatternRegexpPrefix))
+				if err != nil {
+					return nil, fmt.Errorf("invalid repository pattern %s: %v", pattern, err)
+				}
+				result = append(result, re.MatchString)
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid repository pattern %s: %v", pattern, err)
+			}
+			glob := pattern
+			result = append(result, func(repo string) bool {
+				matched, _ := path.Match(glob, repo)
+				return matched
+			})
+		}
+		return result, nil
+	}
+	f := &RepoFilter{}
+	var err error
+	if f.include, err = compile(include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compile(exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Match returns whether the repository passes the filter.
+func (f *RepoFilter) Match(repo string) bool {
+	if f == nil {
+		return true
+	}
+	matchesAny := func(patterns []func(string) bool) bool {
+		for _, match := range patterns {
+			if match(repo) {
+				return true
+			}
+		}
+		return false
+	}
+	return (len(f.include) == 0 || matchesAny(f.include)) && !matchesAny(f.exclude)
+}
+
+// filteredSource is the signatureSource which lists only the repositories which pass the filter,
+// so that the others are never queried.
+type filteredSource struct {
+	signatureSource
+	filter *RepoFilter
+}
+
+func (s filteredSource) Repositories(ctx context.Context) ([]string, error) {
+	repos, err := s.signatureSource.Repositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := repos[:0]
+	for _, repo := range repos {
+		if s.filter.Match(repo) {
+			result = append(result, repo)
+		}
+	}
+	reporter.Commit("filtered out repositories", len(repos)-len(result))
+	return result, nil
+}
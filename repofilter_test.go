@@ -0,0 +1,68 @@
+package idmatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepoFilterMatch(t *testing.T) {
+	req := require.New(t)
+	var empty *RepoFilter
+	req.True(empty.Match("github.com/src-d/gitbase"))
+
+	f, err := NewRepoFilter([]string{"github.com/src-d/*", "re:^gitlab\\.com/"},
+		[]string{"*/*/*-archive", "re:vendor"})
+	req.NoError(err)
+	req.True(f.Match("github.com/src-d/gitbase"))
+	req.True(f.Match("gitlab.com/group/subgroup/project"))
+	req.False(f.Match("github.com/src-d/gitbase/nested"))
+	req.False(f.Match("github.com/bob/gitbase"))
+	req.False(f.Match("github.com/src-d/engine-archive"))
+	req.False(f.Match("gitlab.com/group/vendored"))
+
+	f, err = NewRepoFilter(nil, []string{"repo2"})
+	req.NoError(err)
+	req.True(f.Match("repo1"))
+	req.False(f.Match("repo2"))
+
+	_, err = NewRepoFilter([]string{"re:("}, nil)
+	req.Error(err)
+	_, err = NewRepoFilter(nil, []string{"[a-"})
+	req.Error(err)
+}
+
+func TestFilteredSource(t *testing.T) {
+	req := require.New(t)
+	f, err := NewRepoFilter([]string{"re:[13]$"}, nil)
+	req.NoError(err)
+	source := newTestSignatureSource()
+	commits, err := readSignaturesFromDatabase(nil, filteredSource{source, f})
+	req.NoError(err)
+	req.Equal([]string{"repo1", "repo3"}, source.fetched)
+	for _, commit := range commits {
+		req.NotEqual("repo2", commit.Repo)
+	}
+	repos, err := filteredSource{source, f}.Repositories(context.Background())
+	req.NoError(err)
+	req.Equal([]string{"repo1", "repo3"}, repos)
+}
+
+func TestReadFilteredSignaturesFromDisk(t *testing.T) {
+	req := require.New(t)
+	file, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(storeSignaturesOnDisk(file.Name(), Signatures))
+	f, err := NewRepoFilter([]string{"repo1"}, nil)
+	req.NoError(err)
+	commits, err := readFilteredSignaturesFromDisk(nil, file.Name(), &signatureFilter{repos: f})
+	req.NoError(err)
+	req.NotEmpty(commits)
+	for _, commit := range commits {
+		req.Equal("repo1", commit.Repo)
+	}
+	all, err := readSignaturesFromDisk(nil, file.Name())
+	req.NoError(err)
+	req.Len(all, len(Signatures))
+}
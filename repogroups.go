@@ -0,0 +1,78 @@
+package idmatch
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// RepoGroup joins the repositories which match Pattern into a single scope of the popular names.
+type RepoGroup struct {
+	Pattern *regexp.Regexp
+	// Group is the name of the scope. It may refer to the groups of the pattern as $1, $2, etc.,
+	// e.g. `^github\.com/([^/]+)/` with "$1" makes each GitHub organization a separate scope.
+	Group string
+}
+
+// RepoGroups scope the popular names to the groups of repositories instead of the single
+// repositories: a name which is common globally, and thus is matched only within the same
+// repository, still matches across all the repositories of the same group, e.g. of the same
+// organization. The first matching group wins; the repositories without a group remain
+// separate scopes.
+type RepoGroups []RepoGroup
+
+// ReadRepoGroups loads the groups from a CSV file with the columns "pattern" and "group".
+// The pattern is a Go regular expression.
+func ReadRepoGroups(path string) (groups RepoGroups, err error) {
+	err = readCSVRecords(path, "repository groups", []string{"pattern", "group"},
+		func(header map[string]int, record []string) error {
+			pattern, err := regexp.Compile(record[header["pattern"]])
+			if err != nil {
+				return fmt.Errorf("invalid repository groups file %s: %v", path, err)
+			}
+			groups = append(groups, RepoGroup{Pattern: pattern, Group: record[header["group"]]})
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// scope returns the group of the repository or the repository itself if there is none.
+func (groups RepoGroups) scope(repo string) string {
+	for _, group := range groups {
+		if match := group.Pattern.FindStringSubmatchIndex(repo); match != nil {
+			return string(group.Pattern.ExpandString(nil, group.Group, repo, match))
+		}
+	}
+	return repo
+}
+
+// scopeNames replaces the repositories of the popular names, see newPeople, with their groups,
+// so that the same popular name is matched within the whole group.
+func (p People) scopeNames(groups RepoGroups) {
+	if len(groups) == 0 {
+		return
+	}
+	scopes := map[string]string{}
+	scoped := 0
+	for _, person := range p {
+		for i, name := range person.NamesWithRepos {
+			if name.Repo == "" {
+				continue
+			}
+			scope, exists := scopes[name.Repo]
+			if !exists {
+				scope = groups.scope(name.Repo)
+				scopes[name.Repo] = scope
+			}
+			if scope != name.Repo {
+				person.NamesWithRepos[i].Repo = scope
+				scoped++
+			}
+		}
+	}
+	reporter.Commit("popular names scoped to repository groups", scoped)
+}
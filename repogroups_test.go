@@ -0,0 +1,67 @@
+package idmatch
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRepoGroups(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := f.WriteString("pattern,group\n^github\\.com/([^/]+)/,$1\n^gitlab\\.com/,gitlab\n")
+	req.NoError(err)
+	groups, err := ReadRepoGroups(f.Name())
+	req.NoError(err)
+	req.Len(groups, 2)
+	req.Equal("src-d", groups.scope("github.com/src-d/gitbase"))
+	req.Equal("gitlab", groups.scope("gitlab.com/gitlab-org/gitlab"))
+	req.Equal("bitbucket.org/bob/repo", groups.scope("bitbucket.org/bob/repo"))
+	req.Equal("repo", RepoGroups(nil).scope("repo"))
+
+	f2, cleanup2 := tempFile(t, "*.csv")
+	defer cleanup2()
+	_, err = f2.WriteString("pattern,group\n(,x\n")
+	req.NoError(err)
+	_, err = ReadRepoGroups(f2.Name())
+	req.Error(err)
+
+	f3, cleanup3 := tempFile(t, "*.csv")
+	defer cleanup3()
+	_, err = f3.WriteString("repo,group\n")
+	req.NoError(err)
+	_, err = ReadRepoGroups(f3.Name())
+	req.EqualError(err, "invalid repository groups file "+f3.Name()+": no pattern column")
+}
+
+func TestScopeNames(t *testing.T) {
+	req := require.New(t)
+	newTestPeople := func() People {
+		return People{
+			1: {ID: 1, NamesWithRepos: []NameWithRepo{{"john smith", "github.com/src-d/gitbase"}},
+				Emails: []string{"john@google.com"}},
+			2: {ID: 2, NamesWithRepos: []NameWithRepo{{"john smith", "github.com/src-d/hercules"}},
+				Emails: []string{"jsmith@gmail.com"}},
+			3: {ID: 3, NamesWithRepos: []NameWithRepo{{"john smith", "github.com/bblfsh/sdk"}},
+				Emails: []string{"smith@yahoo.com"}},
+			4: {ID: 4, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+		}
+	}
+	opts := ReduceOptions{MaxIdentities: 20}
+	people := newTestPeople()
+	req.NoError(ReducePeople(context.Background(), people, nil, newTestBlacklist(t), opts))
+	req.Len(people, 4)
+
+	groups := RepoGroups{{Pattern: regexp.MustCompile(`^github\.com/([^/]+)/`), Group: "$1"}}
+	people = newTestPeople()
+	people.scopeNames(groups)
+	req.Equal([]NameWithRepo{{"john smith", "src-d"}}, people[1].NamesWithRepos)
+	req.Equal([]NameWithRepo{{"john smith", "bblfsh"}}, people[3].NamesWithRepos)
+	req.Equal([]NameWithRepo{{"alice", ""}}, people[4].NamesWithRepos)
+	req.NoError(ReducePeople(context.Background(), people, nil, newTestBlacklist(t), opts))
+	req.Len(people, 3)
+	req.Equal([]string{"john@google.com", "jsmith@gmail.com"}, people[1].Emails)
+}
@@ -0,0 +1,65 @@
+package reporter
+
+import (
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Logger receives the log messages of the identity matching packages. *logrus.Logger and
+// *logrus.Entry implement it, and so can the adapters to the other logging libraries.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+var (
+	logger     Logger = logrus.StandardLogger()
+	loggerLock sync.RWMutex
+)
+
+// SetLogger replaces the logger, which is the standard logrus logger by default. nil discards
+// all the messages.
+func SetLogger(l Logger) {
+	if l == nil {
+		l = discardLogger{}
+	}
+	loggerLock.Lock()
+	defer loggerLock.Unlock()
+	logger = l
+}
+
+func currentLogger() Logger {
+	loggerLock.RLock()
+	defer loggerLock.RUnlock()
+	return logger
+}
+
+// Debugf logs the message which is useful only to debug the matching.
+func Debugf(format string, args ...interface{}) {
+	currentLogger().Debugf(format, args...)
+}
+
+// Infof logs the progress of the pipeline.
+func Infof(format string, args ...interface{}) {
+	currentLogger().Infof(format, args...)
+}
+
+// Warnf logs the problem which does not stop the pipeline.
+func Warnf(format string, args ...interface{}) {
+	currentLogger().Warnf(format, args...)
+}
+
+// Errorf logs the failure which the pipeline recovers from.
+func Errorf(format string, args ...interface{}) {
+	currentLogger().Errorf(format, args...)
+}
+
+type discardLogger struct{}
+
+func (discardLogger) Debugf(string, ...interface{}) {}
+func (discardLogger) Infof(string, ...interface{})  {}
+func (discardLogger) Warnf(string, ...interface{})  {}
+func (discardLogger) Errorf(string, ...interface{}) {}
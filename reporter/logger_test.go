@@ -0,0 +1,35 @@
+package reporter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) { l.record("debug", format) }
+func (l *recordingLogger) Infof(format string, args ...interface{})  { l.record("info", format) }
+func (l *recordingLogger) Warnf(format string, args ...interface{})  { l.record("warn", format) }
+func (l *recordingLogger) Errorf(format string, args ...interface{}) { l.record("error", format) }
+
+func (l *recordingLogger) record(level, format string) {
+	l.messages = append(l.messages, level+" "+format)
+}
+
+func TestSetLogger(t *testing.T) {
+	req := require.New(t)
+	defer SetLogger(currentLogger())
+	l := &recordingLogger{}
+	SetLogger(l)
+	Debugf("1")
+	Infof("2")
+	Warnf("3")
+	Errorf("4")
+	req.Equal([]string{"debug 1", "info 2", "warn 3", "error 4"}, l.messages)
+	SetLogger(nil)
+	Infof("5")
+	req.Len(l.messages, 4)
+}
@@ -0,0 +1,201 @@
+package reporter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DurationBuckets are the upper bounds of the histogram buckets for the durations in seconds.
+var DurationBuckets = []float64{
+	0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600, 4 * 3600}
+
+// metricFamily is a registered metric together with all its label combinations.
+type metricFamily struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+	buckets    []float64
+	series     map[string]*metricSeries
+}
+
+// metricSeries is the state of a single label combination: the value of a counter or
+// the bucket counts, the sum and the count of a histogram.
+type metricSeries struct {
+	labels  string
+	value   float64
+	buckets []uint64
+	count   uint64
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+var (
+	families    = map[string]*metricFamily{}
+	metricsLock sync.Mutex
+)
+
+// Counter is a Prometheus-style metric which only grows, e.g. the number of processed items.
+type Counter struct {
+	family *metricFamily
+}
+
+// Histogram is a Prometheus-style metric which counts the observations in the buckets,
+// e.g. the durations.
+type Histogram struct {
+	family *metricFamily
+}
+
+// NewCounter registers the counter with the given label names. It panics if the name
+// is already registered.
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{register(name, help, "counter", nil, labelNames)}
+}
+
+// NewHistogram registers the histogram with the sorted bucket upper bounds and the given label
+// names. It panics if the name is already registered.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return &Histogram{register(name, help, "histogram", buckets, labelNames)}
+}
+
+func register(name, help, kind string, buckets []float64, labelNames []string) *metricFamily {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	if _, exists := families[name]; exists {
+		panic(fmt.Sprintf("metric %s is already registered", name))
+	}
+	family := &metricFamily{name: name, help: help, kind: kind, labelNames: labelNames,
+		buckets: buckets, series: map[string]*metricSeries{}}
+	families[name] = family
+	return family
+}
+
+// get returns the series of the label values. It must be called with metricsLock held.
+func (f *metricFamily) get(labelValues []string) *metricSeries {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("metric %s has %d labels, got %d values",
+			f.name, len(f.labelNames), len(labelValues)))
+	}
+	pairs := make([]string, len(labelValues))
+	for i, value := range labelValues {
+		pairs[i] = f.labelNames[i] + `="` + labelValueEscaper.Replace(value) + `"`
+	}
+	labels := strings.Join(pairs, ",")
+	series, exists := f.series[labels]
+	if !exists {
+		series = &metricSeries{labels: labels, buckets: make([]uint64, len(f.buckets))}
+		f.series[labels] = series
+	}
+	return series
+}
+
+// Add increases the counter of the label values, which follow the order of the label names.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	c.family.get(labelValues).value += value
+}
+
+// Value returns the current value of the counter of the label values.
+func (c *Counter) Value(labelValues ...string) float64 {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	return c.family.get(labelValues).value
+}
+
+// Observe records the value in the histogram of the label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	series := h.family.get(labelValues)
+	for i, bound := range h.family.buckets {
+		if value <= bound {
+			series.buckets[i]++
+		}
+	}
+	series.value += value
+	series.count++
+}
+
+// Count returns the number of the observations in the histogram of the label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	return h.family.get(labelValues).count
+}
+
+// WriteMetrics writes all the metrics in the Prometheus text exposition format.
+func WriteMetrics(w io.Writer) error {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	writer := bufio.NewWriter(w)
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		family := families[name]
+		fmt.Fprintf(writer, "# HELP %s %s\n# TYPE %s %s\n", name,
+			strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(family.help), name, family.kind)
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			series := family.series[key]
+			if family.kind == "counter" {
+				fmt.Fprintf(writer, "%s%s %s\n", name, braces(series.labels), formatFloat(series.value))
+				continue
+			}
+			for i, bound := range family.buckets {
+				fmt.Fprintf(writer, "%s_bucket%s %d\n", name,
+					braces(joinLabels(series.labels, `le="`+formatFloat(bound)+`"`)), series.buckets[i])
+			}
+			fmt.Fprintf(writer, "%s_bucket%s %d\n", name,
+				braces(joinLabels(series.labels, `le="+Inf"`)), series.count)
+			fmt.Fprintf(writer, "%s_sum%s %s\n", name, braces(series.labels), formatFloat(series.value))
+			fmt.Fprintf(writer, "%s_count%s %d\n", name, braces(series.labels), series.count)
+		}
+	}
+	return writer.Flush()
+}
+
+// MetricsHandler serves WriteMetrics over HTTP, usually at /metrics.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := WriteMetrics(w); err != nil {
+			Errorf("failed to write the metrics: %v", err)
+		}
+	})
+}
+
+func joinLabels(labels, extra string) string {
+	if labels == "" {
+		return extra
+	}
+	return labels + "," + extra
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
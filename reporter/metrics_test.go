@@ -0,0 +1,46 @@
+package reporter
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	req := require.New(t)
+	counter := NewCounter("test_counter_total", "Test\ncounter.", "kind")
+	counter.Add(1, "a")
+	counter.Add(2, `b"\`)
+	counter.Add(0.5, "a")
+	req.Equal(1.5, counter.Value("a"))
+	histogram := NewHistogram("test_histogram_seconds", "Test histogram.", []float64{1, 10})
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	histogram.Observe(50)
+	req.Equal(uint64(3), histogram.Count())
+	req.Panics(func() { NewCounter("test_counter_total", "") })
+	req.Panics(func() { counter.Add(1) })
+
+	buffer := &bytes.Buffer{}
+	req.NoError(WriteMetrics(buffer))
+	req.Contains(buffer.String(), `# HELP test_counter_total Test\ncounter.
+# TYPE test_counter_total counter
+test_counter_total{kind="a"} 1.5
+test_counter_total{kind="b\"\\"} 2
+`)
+	req.Contains(buffer.String(), `# HELP test_histogram_seconds Test histogram.
+# TYPE test_histogram_seconds histogram
+test_histogram_seconds_bucket{le="1"} 1
+test_histogram_seconds_bucket{le="10"} 2
+test_histogram_seconds_bucket{le="+Inf"} 3
+test_histogram_seconds_sum 55.5
+test_histogram_seconds_count 3
+`)
+
+	recorder := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	req.Equal(200, recorder.Code)
+	req.Equal(buffer.String(), recorder.Body.String())
+}
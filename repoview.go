@@ -0,0 +1,220 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// RepoContributor is a person as seen in a single repository: the names and the emails of
+// the signatures in that repository instead of all the aliases of the person.
+type RepoContributor struct {
+	ID           int64  `json:"id"`
+	PrimaryName  string `json:"primary_name"`
+	PrimaryEmail string `json:"primary_email"`
+	// Names and Emails are the sorted local aliases.
+	Names  []string `json:"names"`
+	Emails []string `json:"emails"`
+	// FirstCommit and LastCommit span the signatures in the repository, see Person.FirstCommit.
+	FirstCommit time.Time `json:"first_commit"`
+	LastCommit  time.Time `json:"last_commit"`
+	// Commits counts the commits in the repository the same way as Person.Commits.
+	Commits int  `json:"commits"`
+	IsBot   bool `json:"is_bot,omitempty"`
+}
+
+// RepoView is the projection of the people onto a single repository.
+type RepoView struct {
+	Repo string `json:"repo"`
+	// Contributors are sorted by ID.
+	Contributors []RepoContributor `json:"contributors"`
+}
+
+// RepoViews projects the people onto each repository of the signatures which they were matched
+// from, e.g. the signatures cache of FindPeople. A signature belongs to the person with its email
+// and name, see PeopleIndex.FindBySignature, and to Signature.Mirrors in addition to
+// Signature.Repo. The signatures of the people who are not there, such as the blacklisted ones,
+// are skipped. The views are sorted by the repository.
+func (p People) RepoViews(signatures []Signature) []RepoView {
+	type contributorKey struct {
+		repo string
+		id   int64
+	}
+	contributors := map[contributorKey]*RepoContributor{}
+	index := p.Index()
+	skipped := 0
+	for _, sig := range signatures {
+		person := index.FindBySignature(sig.Name, sig.Repo, sig.Email)
+		if person == nil {
+			skipped++
+			continue
+		}
+		commits := sig.Activity.Commits()
+		if commits == 0 {
+			commits = 1
+		}
+		for _, repo := range append([]string{sig.Repo}, sig.Mirrors...) {
+			if repo == "" {
+				continue
+			}
+			key := contributorKey{repo, person.ID}
+			contributor := contributors[key]
+			if contributor == nil {
+				contributor = &RepoContributor{
+					ID:           person.ID,
+					PrimaryName:  person.PrimaryName,
+					PrimaryEmail: person.PrimaryEmail,
+					FirstCommit:  sig.Time,
+					LastCommit:   sig.Time,
+					IsBot:        person.IsBot,
+				}
+				contributors[key] = contributor
+			}
+			contributor.Names = append(contributor.Names, sig.Name)
+			contributor.Emails = append(contributor.Emails, sig.Email)
+			if sig.Time.Before(contributor.FirstCommit) {
+				contributor.FirstCommit = sig.Time
+			}
+			if sig.Time.After(contributor.LastCommit) {
+				contributor.LastCommit = sig.Time
+			}
+			contributor.Commits += commits
+		}
+	}
+	reporter.Commit("repository view signatures without people", skipped)
+	views := map[string]*RepoView{}
+	for key, contributor := range contributors {
+		contributor.Names = unique(contributor.Names)
+		contributor.Emails = unique(contributor.Emails)
+		view := views[key.repo]
+		if view == nil {
+			view = &RepoView{Repo: key.repo}
+			views[key.repo] = view
+		}
+		view.Contributors = append(view.Contributors, *contributor)
+	}
+	result := make([]RepoView, 0, len(views))
+	for _, view := range views {
+		sort.Slice(view.Contributors, func(i, j int) bool {
+			return view.Contributors[i].ID < view.Contributors[j].ID
+		})
+		result = append(result, *view)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Repo < result[j].Repo })
+	return result
+}
+
+// RepoViewFileName is the name of the file of the repository in the directory of WriteRepoViews,
+// e.g. "github.com%2Fsrc-d%2Fgitbase.json". The repository is escaped so that it does not
+// create the subdirectories.
+func RepoViewFileName(repo, format string) string {
+	return url.PathEscape(repo) + "." + format
+}
+
+// WriteRepoViews writes each view to its own file in the directory, see RepoViewFileName.
+// The format is "json", which writes the RepoView object, or "csv", which writes a row per
+// contributor with the aliases joined by "|". The directory may be a blob URL.
+func WriteRepoViews(dir string, views []RepoView, format string) error {
+	var write func(path string, view RepoView) error
+	switch format {
+	case "json":
+		write = writeRepoViewJSON
+	case "csv":
+		write = writeRepoViewCSV
+	default:
+		return fmt.Errorf("unsupported repository view format: %s", format)
+	}
+	if !isBlobURL(dir) {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return err
+		}
+	}
+	for _, view := range views {
+		if err := write(partitionPath(dir, RepoViewFileName(view.Repo, format)), view); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeRepoViewJSON(path string, view RepoView) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(view)
+}
+
+func writeRepoViewCSV(path string, view RepoView) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	err = writer.Write([]string{"id", "primary_name", "primary_email", "names", "emails",
+		"first_commit", "last_commit", "commits", "is_bot"})
+	if err != nil {
+		return
+	}
+	for _, c := range view.Contributors {
+		err = writer.Write([]string{strconv.FormatInt(c.ID, 10), c.PrimaryName, c.PrimaryEmail,
+			strings.Join(c.Names, "|"), strings.Join(c.Emails, "|"),
+			c.FirstCommit.UTC().Format(time.RFC3339), c.LastCommit.UTC().Format(time.RFC3339),
+			strconv.Itoa(c.Commits), strconv.FormatBool(c.IsBot)})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ReadSignatures reads the signatures cache of FindPeople in any of its formats: CSV, parquet,
+// Arrow or the chunks of ExtractionOptions.CacheChunkSize. It fails if there is no cache.
+func ReadSignatures(path string) ([]Signature, error) {
+	exists, err := pathExists(path)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		chunks, err := signatureChunks(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks) == 0 {
+			return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+		}
+	}
+	return findSignatures(nil, "", path, NewExtractionOptions())
+}
@@ -0,0 +1,95 @@
+package idmatch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRepoViewPeople() (People, []Signature) {
+	t1 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	people := People{
+		1: {ID: 1, PrimaryName: "bob", PrimaryEmail: "bob@google.com",
+			Emails: []string{"bob@google.com", "bob@apple.com"}},
+		2: {ID: 2, PrimaryName: "alice", PrimaryEmail: "alice@google.com",
+			Emails: []string{"alice@google.com"}, IsBot: true},
+	}
+	signatures := []Signature{
+		{Repo: "src-d/gitbase", Name: "bob", Email: "bob@google.com", Hash: "aaa", Time: t2},
+		{Repo: "src-d/gitbase", Name: "bobby", Email: "bob@apple.com", Hash: "bbb", Time: t1},
+		{Repo: "src-d/engine", Name: "bob", Email: "bob@apple.com", Hash: "ccc", Time: t1,
+			Mirrors: []string{"bob/engine"}},
+		{Repo: "src-d/engine", Name: "alice", Email: "alice@google.com", Hash: "ddd", Time: t2},
+		{Repo: "src-d/engine", Name: "eve", Email: "eve@google.com", Hash: "eee", Time: t2},
+	}
+	return people, signatures
+}
+
+func TestRepoViews(t *testing.T) {
+	req := require.New(t)
+	people, signatures := testRepoViewPeople()
+	t1, t2 := signatures[1].Time, signatures[0].Time
+	views := people.RepoViews(signatures)
+	req.Equal([]RepoView{
+		{Repo: "bob/engine", Contributors: []RepoContributor{
+			{ID: 1, PrimaryName: "bob", PrimaryEmail: "bob@google.com", Names: []string{"bob"},
+				Emails: []string{"bob@apple.com"}, FirstCommit: t1, LastCommit: t1, Commits: 1},
+		}},
+		{Repo: "src-d/engine", Contributors: []RepoContributor{
+			{ID: 1, PrimaryName: "bob", PrimaryEmail: "bob@google.com", Names: []string{"bob"},
+				Emails: []string{"bob@apple.com"}, FirstCommit: t1, LastCommit: t1, Commits: 1},
+			{ID: 2, PrimaryName: "alice", PrimaryEmail: "alice@google.com", Names: []string{"alice"},
+				Emails: []string{"alice@google.com"}, FirstCommit: t2, LastCommit: t2, Commits: 1,
+				IsBot: true},
+		}},
+		{Repo: "src-d/gitbase", Contributors: []RepoContributor{
+			{ID: 1, PrimaryName: "bob", PrimaryEmail: "bob@google.com", Names: []string{"bob", "bobby"},
+				Emails: []string{"bob@apple.com", "bob@google.com"}, FirstCommit: t1, LastCommit: t2,
+				Commits: 2},
+		}},
+	}, views)
+}
+
+func TestWriteRepoViews(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-repoviews")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people, signatures := testRepoViewPeople()
+	views := people.RepoViews(signatures)
+
+	req.NoError(WriteRepoViews(dir, views, "json"))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "src-d%2Fgitbase.json"))
+	req.NoError(err)
+	var view RepoView
+	req.NoError(json.Unmarshal(data, &view))
+	req.Equal(views[2], view)
+
+	req.NoError(WriteRepoViews(dir, views, "csv"))
+	data, err = ioutil.ReadFile(filepath.Join(dir, RepoViewFileName("src-d/engine", "csv")))
+	req.NoError(err)
+	req.Equal(`id,primary_name,primary_email,names,emails,first_commit,last_commit,commits,is_bot
+1,bob,bob@google.com,bob,bob@apple.com,2019-01-01T00:00:00Z,2019-01-01T00:00:00Z,1,false
+2,alice,alice@google.com,alice,alice@google.com,2019-06-01T00:00:00Z,2019-06-01T00:00:00Z,1,true
+`, string(data))
+
+	req.Error(WriteRepoViews(dir, views, "xml"))
+}
+
+func TestReadSignatures(t *testing.T) {
+	req := require.New(t)
+	file, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(storeSignaturesOnDisk(file.Name(), Signatures))
+	signatures, err := ReadSignatures(file.Name())
+	req.NoError(err)
+	req.Len(signatures, len(Signatures))
+	_, err = ReadSignatures(file.Name() + ".missing")
+	req.True(os.IsNotExist(err))
+}
@@ -0,0 +1,58 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// matchReproducibly runs the whole pipeline on the shuffled signatures and returns the bytes of
+// the identities and the merges tables.
+func matchReproducibly(t *testing.T, signatures []Signature, seed int64) []byte {
+	req := require.New(t)
+	shuffled := append([]Signature{}, signatures...)
+	rand.New(rand.NewSource(seed)).Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	blacklist := newTestBlacklist(t)
+	extraction := ExtractionOptions{Reproducible: true}
+	people, nameFreqs, emailFreqs, err := PeopleFromSignatures(context.Background(), shuffled,
+		extraction, blacklist, PopularityThresholds{}, BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	err = ReducePeople(context.Background(), people, nil, blacklist, ReduceOptions{
+		MaxIdentities: 20, MatchReorderedNames: true, MinRepositorySimilarity: 0.1,
+		ExplainMerges: true, Workers: 4})
+	req.NoError(err)
+	SetPrimaryValues(people, nameFreqs, emailFreqs, PrimaryOptions{MinRecentCount: 5})
+
+	dir, err := ioutil.TempDir("", "idmatch-reproducible")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "identities.parquet")
+	req.NoError(people.WriteToParquet(path, ""))
+	req.NoError(people.WriteMergesToParquet(path))
+	var result []byte
+	pathAliases, pathIDs := preparePaths(path)
+	for _, file := range []string{pathAliases, pathIDs, mergesPath(path)} {
+		content, err := ioutil.ReadFile(file)
+		req.NoError(err)
+		result = append(result, content...)
+	}
+	return result
+}
+
+func TestReproducible(t *testing.T) {
+	opts := NewSyntheticOptions()
+	opts.People = 300
+	opts.Repositories = 30
+	signatures := GenerateSyntheticDataset(opts).Signatures
+	expected := matchReproducibly(t, signatures, 1)
+	for seed := int64(2); seed < 5; seed++ {
+		require.Equal(t, expected, matchReproducibly(t, signatures, seed), seed)
+	}
+}
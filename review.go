@@ -0,0 +1,259 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// EvidenceReview means that a reviewer approved merging both identities, see ReviewDecisions.
+const EvidenceReview EvidenceKind = "review"
+
+// ReviewVerdict is the decision of a reviewer about merging two identities.
+type ReviewVerdict string
+
+const (
+	// ReviewApprove forces merging the identities.
+	ReviewApprove ReviewVerdict = "approve"
+	// ReviewReject forbids the edge between the identities, whatever evidence it has.
+	ReviewReject ReviewVerdict = "reject"
+)
+
+// ReviewIdentity is an identity in IdentityGraph as it was before any merges, which is stable
+// between the runs unlike the node IDs.
+type ReviewIdentity struct {
+	Name  string
+	Repo  string
+	Email string
+}
+
+// newReviewIdentity converts the node of IdentityGraph. The nodes have a single name and email.
+func newReviewIdentity(node IdentityNode) ReviewIdentity {
+	var identity ReviewIdentity
+	if len(node.NamesWithRepos) > 0 {
+		identity.Name, identity.Repo = node.NamesWithRepos[0].Name, node.NamesWithRepos[0].Repo
+	}
+	if len(node.Emails) > 0 {
+		identity.Email = node.Emails[0]
+	}
+	return identity
+}
+
+func (i ReviewIdentity) less(other ReviewIdentity) bool {
+	if i.Name != other.Name {
+		return i.Name < other.Name
+	}
+	if i.Repo != other.Repo {
+		return i.Repo < other.Repo
+	}
+	return i.Email < other.Email
+}
+
+type reviewPair struct {
+	identity1 ReviewIdentity
+	identity2 ReviewIdentity
+}
+
+func newReviewPair(identity1, identity2 ReviewIdentity) reviewPair {
+	if identity2.less(identity1) {
+		identity1, identity2 = identity2, identity1
+	}
+	return reviewPair{identity1, identity2}
+}
+
+// ReviewDecisions are the verdicts of the reviewers about the merges of the identity pairs,
+// which constrain the next matching through ReduceOptions.Decisions. A rejection deactivates
+// only the edge between the pair: the identities may still join the same person through
+// the other identities.
+type ReviewDecisions map[reviewPair]ReviewVerdict
+
+// Set records the verdict about the pair of identities, replacing the previous one.
+func (d ReviewDecisions) Set(identity1, identity2 ReviewIdentity, verdict ReviewVerdict) {
+	d[newReviewPair(identity1, identity2)] = verdict
+}
+
+// Verdict returns the verdict about the pair of identities and whether it exists.
+func (d ReviewDecisions) Verdict(identity1, identity2 ReviewIdentity) (ReviewVerdict, bool) {
+	verdict, exists := d[newReviewPair(identity1, identity2)]
+	return verdict, exists
+}
+
+// reviewDecisionsHeader is the header of the CSV file with the review decisions.
+var reviewDecisionsHeader = []string{"name1", "repo1", "email1", "name2", "repo2", "email2", "verdict"}
+
+// ReadReviewDecisions loads the decisions written by ReviewDecisions.Write.
+func ReadReviewDecisions(path string) (decisions ReviewDecisions, err error) {
+	decisions = ReviewDecisions{}
+	err = readCSVRecords(path, "review decisions", reviewDecisionsHeader,
+		func(header map[string]int, record []string) error {
+			field := func(name string) string {
+				return record[header[name]]
+			}
+			verdict := ReviewVerdict(field("verdict"))
+			if verdict != ReviewApprove && verdict != ReviewReject {
+				return fmt.Errorf("unknown review verdict: %s", verdict)
+			}
+			decisions.Set(ReviewIdentity{field("name1"), field("repo1"), field("email1")},
+				ReviewIdentity{field("name2"), field("repo2"), field("email2")}, verdict)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return decisions, nil
+}
+
+// Write stores the decisions in the CSV file sorted by the identities.
+func (d ReviewDecisions) Write(path string) (err error) {
+	pairs := make([]reviewPair, 0, len(d))
+	for pair := range d {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].identity1 != pairs[j].identity1 {
+			return pairs[i].identity1.less(pairs[j].identity1)
+		}
+		return pairs[i].identity2.less(pairs[j].identity2)
+	})
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write(reviewDecisionsHeader); err != nil {
+		return
+	}
+	for _, pair := range pairs {
+		i1, i2 := pair.identity1, pair.identity2
+		err = writer.Write([]string{i1.Name, i1.Repo, i1.Email, i2.Name, i2.Repo, i2.Email, string(d[pair])})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ReviewIdentity returns the stable identity of the node with the ID.
+func (g *IdentityGraph) ReviewIdentity(id int64) (ReviewIdentity, bool) {
+	node, exists := g.nodes[id]
+	if !exists {
+		return ReviewIdentity{}, false
+	}
+	return newReviewIdentity(node), true
+}
+
+// resolveDecisions maps the decisions to the node pairs of the graph: the rejected edges are
+// remembered to veto them, and the approved ones are returned to be added by approve after
+// all the other evidence.
+func (g *IdentityGraph) resolveDecisions(decisions ReviewDecisions) [][2]int64 {
+	if len(decisions) == 0 {
+		return nil
+	}
+	byIdentity := map[ReviewIdentity][]int64{}
+	for id, node := range g.nodes {
+		identity := newReviewIdentity(node)
+		byIdentity[identity] = append(byIdentity[identity], id)
+	}
+	var approved [][2]int64
+	g.rejected = map[edgeKey]struct{}{}
+	for pair, verdict := range decisions {
+		for _, id1 := range byIdentity[pair.identity1] {
+			for _, id2 := range byIdentity[pair.identity2] {
+				if verdict == ReviewReject {
+					g.rejected[newEdgeKey(id1, id2)] = struct{}{}
+				} else {
+					approved = append(approved, [2]int64{id1, id2})
+				}
+			}
+		}
+	}
+	sort.Slice(approved, func(i, j int) bool {
+		if approved[i][0] != approved[j][0] {
+			return approved[i][0] < approved[j][0]
+		}
+		return approved[i][1] < approved[j][1]
+	})
+	return approved
+}
+
+// approve adds EvidenceReview to the edge between the nodes which weighs at least enough
+// to activate it.
+func (g *IdentityGraph) approve(id1, id2 int64) error {
+	if err := g.force(id1, id2, EvidenceReview, string(ReviewApprove)); err != nil {
+		return err
+	}
+	reporter.Increment("approved merges")
+	return nil
+}
+
+// force adds the evidence to the edge between the nodes which weighs at least enough
+// to activate it.
+func (g *IdentityGraph) force(id1, id2 int64, kind EvidenceKind, value string) error {
+	if id1 == id2 {
+		return nil
+	}
+	key := newEdgeKey(id1, id2)
+	edge, exists := g.edges[key]
+	if !exists {
+		edge = &IdentityEdge{From: key.from, To: key.to}
+	}
+	weight := math.Max(g.weight(kind), g.threshold-edge.Weight)
+	if !edge.Active {
+		activated, err := g.setEdge(g.node(id1), g.node(id2), Evidence{kind, value, weight})
+		if err != nil {
+			return err
+		}
+		if !activated {
+			return nil
+		}
+		edge.Active = true
+		mergesMetric.Add(1, string(kind))
+	}
+	edge.Evidence = append(edge.Evidence, Evidence{kind, value, weight})
+	edge.Weight += weight
+	g.edges[key] = edge
+	return nil
+}
+
+// ReviewCandidates returns the edges which are the least certain: their weight differs from
+// the threshold by less than the margin. The threshold below 1 counts as 1 because any single
+// piece of evidence merges then. The edges approved by a reviewer are excluded. The result
+// is sorted by the distance from the threshold.
+func (g *IdentityGraph) ReviewCandidates(margin float64) []IdentityEdge {
+	threshold := math.Max(g.threshold, 1)
+	var candidates []IdentityEdge
+	for _, edge := range g.Edges() {
+		if math.Abs(edge.Weight-threshold) >= margin {
+			continue
+		}
+		reviewed := false
+		for _, evidence := range edge.Evidence {
+			reviewed = reviewed || evidence.Kind == EvidenceReview
+		}
+		if !reviewed {
+			candidates = append(candidates, edge)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return math.Abs(candidates[i].Weight-threshold) < math.Abs(candidates[j].Weight-threshold)
+	})
+	return candidates
+}
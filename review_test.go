@@ -0,0 +1,102 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewDecisionsReadWrite(t *testing.T) {
+	req := require.New(t)
+	decisions := ReviewDecisions{}
+	alice := ReviewIdentity{"alice", "", "alice@google.com"}
+	al := ReviewIdentity{"alice", "", "al@google.com"}
+	bob := ReviewIdentity{"bob", "src-d/go-git", "bob@google.com"}
+	decisions.Set(alice, al, ReviewApprove)
+	decisions.Set(bob, alice, ReviewApprove)
+	decisions.Set(alice, bob, ReviewReject)
+	verdict, exists := decisions.Verdict(bob, alice)
+	req.True(exists)
+	req.Equal(ReviewReject, verdict)
+	_, exists = decisions.Verdict(bob, al)
+	req.False(exists)
+
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(decisions.Write(f.Name()))
+	content, err := ioutil.ReadFile(f.Name())
+	req.NoError(err)
+	req.Equal(`name1,repo1,email1,name2,repo2,email2,verdict
+alice,,al@google.com,alice,,alice@google.com,approve
+alice,,alice@google.com,bob,src-d/go-git,bob@google.com,reject
+`, string(content))
+	read, err := ReadReviewDecisions(f.Name())
+	req.NoError(err)
+	req.Equal(decisions, read)
+
+	req.NoError(ioutil.WriteFile(f.Name(), []byte("name1,email1\nalice,alice@google.com\n"), 0666))
+	_, err = ReadReviewDecisions(f.Name())
+	req.Error(err)
+	req.NoError(ioutil.WriteFile(f.Name(), []byte(
+		"name1,repo1,email1,name2,repo2,email2,verdict\na,,a@google.com,b,,b@google.com,maybe\n"), 0666))
+	_, err = ReadReviewDecisions(f.Name())
+	req.Error(err)
+	_, err = ReadReviewDecisions(f.Name() + ".missing")
+	req.True(os.IsNotExist(err))
+}
+
+func TestBuildIdentityGraphDecisions(t *testing.T) {
+	req := require.New(t)
+	decisions := ReviewDecisions{}
+	decisions.Set(ReviewIdentity{"alice", "", "alice@google.com"},
+		ReviewIdentity{"alice", "", "al@google.com"}, ReviewReject)
+	decisions.Set(ReviewIdentity{"bob", "", "bob@google.com"},
+		ReviewIdentity{"eve", "", "eve@google.com"}, ReviewApprove)
+	people := newGraphTestPeople()
+	g, err := BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t), ReduceOptions{
+		MaxIdentities: 100,
+		MinEdgeWeight: 2,
+		Decisions:     decisions,
+	})
+	req.NoError(err)
+	edge, _ := g.Edge(3, 4)
+	req.False(edge.Active)
+	req.Equal(1.0, edge.Weight)
+	edge, _ = g.Edge(1, 5)
+	req.True(edge.Active)
+	req.Equal([]Evidence{{EvidenceReview, "approve", 2}}, edge.Evidence)
+	edge, _ = g.Edge(2, 5)
+	req.True(edge.Active)
+	req.Equal([][]int64{{1, 2, 5}, {3}, {4}}, g.Components())
+
+	identity, exists := g.ReviewIdentity(5)
+	req.True(exists)
+	req.Equal(ReviewIdentity{"eve", "", "eve@google.com"}, identity)
+	_, exists = g.ReviewIdentity(10)
+	req.False(exists)
+}
+
+func TestIdentityGraphReviewCandidates(t *testing.T) {
+	req := require.New(t)
+	people := newGraphTestPeople()
+	g, err := BuildIdentityGraph(context.Background(), people, nil, newTestBlacklist(t),
+		ReduceOptions{MaxIdentities: 100})
+	req.NoError(err)
+	candidates := g.ReviewCandidates(0.5)
+	req.Len(candidates, 1)
+	req.Equal(int64(3), candidates[0].From)
+	req.Equal(int64(4), candidates[0].To)
+	req.Len(g.ReviewCandidates(1.5), 2)
+	req.Len(g.ReviewCandidates(0), 0)
+
+	decisions := ReviewDecisions{}
+	decisions.Set(ReviewIdentity{"alice", "", "alice@google.com"},
+		ReviewIdentity{"alice", "", "al@google.com"}, ReviewApprove)
+	g, err = BuildIdentityGraph(context.Background(), newGraphTestPeople(), nil, newTestBlacklist(t),
+		ReduceOptions{MaxIdentities: 100, Decisions: decisions})
+	req.NoError(err)
+	req.Len(g.ReviewCandidates(0.5), 0)
+}
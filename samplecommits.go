@@ -0,0 +1,100 @@
+package idmatch
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// SampleCommitOptions choose the representative commits which the merged people keep in
+// Person.SampleCommits, so that every person has concrete commits to verify.
+type SampleCommitOptions struct {
+	// Max is the number of the kept commits: the earliest, the latest and then the commits in
+	// the repositories with the biggest RepoWeights. 0 keeps only the earliest.
+	Max int
+	// RepoWeights rank the repositories, e.g. by the stars. May be nil.
+	RepoWeights RepoWeights
+}
+
+// RepoWeights map the repositories to their importance, e.g. the number of stars.
+type RepoWeights map[string]float64
+
+// ReadRepoWeights loads the weights from a CSV file with the columns "repo" and "weight".
+func ReadRepoWeights(path string) (weights RepoWeights, err error) {
+	weights = RepoWeights{}
+	err = readCSVRecords(path, "repository weights", []string{"repo", "weight"},
+		func(header map[string]int, record []string) error {
+			weight, err := strconv.ParseFloat(record[header["weight"]], 64)
+			if err != nil {
+				return fmt.Errorf("invalid repository weights file %s: %v", path, err)
+			}
+			weights[record[header["repo"]]] = weight
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return weights, nil
+}
+
+// sampleCommits appends the candidates of chooseSampleCommits: Person.SampleCommits or
+// Person.SampleCommit if the former is empty.
+func (p *Person) sampleCommits(candidates []Commit) []Commit {
+	if len(p.SampleCommits) > 0 {
+		return append(candidates, p.SampleCommits...)
+	}
+	if p.SampleCommit != nil {
+		return append(candidates, *p.SampleCommit)
+	}
+	return candidates
+}
+
+// chooseSampleCommits returns at most opts.Max unique commits out of the candidates, see
+// SampleCommitOptions. The commits without the time are the last resort.
+func chooseSampleCommits(candidates []Commit, opts SampleCommitOptions) []Commit {
+	limit := opts.Max
+	if limit <= 0 {
+		limit = 1
+	}
+	type commitKey struct{ hash, repo string }
+	seen := map[commitKey]struct{}{}
+	var commits []Commit
+	for _, commit := range candidates {
+		key := commitKey{commit.Hash, commit.Repo}
+		if _, exists := seen[key]; !exists {
+			seen[key] = struct{}{}
+			commits = append(commits, commit)
+		}
+	}
+	// the earliest first, the commits without the time last
+	sort.Slice(commits, func(i, j int) bool {
+		ti, tj := commits[i].Time, commits[j].Time
+		if ti.IsZero() != tj.IsZero() {
+			return tj.IsZero()
+		}
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		if commits[i].Repo != commits[j].Repo {
+			return commits[i].Repo < commits[j].Repo
+		}
+		return commits[i].Hash < commits[j].Hash
+	})
+	if len(commits) <= limit {
+		return commits
+	}
+	result := []Commit{commits[0]}
+	rest := commits[1:]
+	if latest := len(rest) - 1; limit > 1 {
+		for latest > 0 && rest[latest].Time.IsZero() {
+			latest--
+		}
+		result = append(result, rest[latest])
+		rest = append(rest[:latest:latest], rest[latest+1:]...)
+	}
+	// stable, so that the equal weights keep the earliest commits
+	sort.SliceStable(rest, func(i, j int) bool {
+		return opts.RepoWeights[rest[i].Repo] > opts.RepoWeights[rest[j].Repo]
+	})
+	return append(result, rest[:limit-len(result)]...)
+}
@@ -0,0 +1,70 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRepoWeights(t *testing.T) {
+	req := require.New(t)
+	file, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := file.WriteString("repo,weight\nsrc-d/engine,100\nsrc-d/gitbase,2.5\n")
+	req.NoError(err)
+	weights, err := ReadRepoWeights(file.Name())
+	req.NoError(err)
+	req.Equal(RepoWeights{"src-d/engine": 100, "src-d/gitbase": 2.5}, weights)
+
+	req.NoError(ioutil.WriteFile(file.Name(), []byte("repo\nsrc-d/engine\n"), 0666))
+	_, err = ReadRepoWeights(file.Name())
+	req.EqualError(err, "invalid repository weights file "+file.Name()+": no weight column")
+}
+
+func TestChooseSampleCommits(t *testing.T) {
+	req := require.New(t)
+	day := func(d int) time.Time { return time.Date(2019, 1, d, 0, 0, 0, 0, time.UTC) }
+	candidates := []Commit{
+		{Hash: "c", Repo: "repo2", Time: day(3)},
+		{Hash: "x", Repo: "repo3"},
+		{Hash: "a", Repo: "repo1", Time: day(1)},
+		{Hash: "d", Repo: "repo1", Time: day(4)},
+		{Hash: "b", Repo: "repo3", Time: day(2)},
+		{Hash: "a", Repo: "repo1", Time: day(1)},
+	}
+	req.Equal([]Commit{candidates[2]}, chooseSampleCommits(candidates, SampleCommitOptions{}))
+	req.Equal([]Commit{candidates[2], candidates[3], candidates[4]},
+		chooseSampleCommits(candidates, SampleCommitOptions{Max: 3}))
+	req.Equal([]Commit{candidates[2], candidates[3], candidates[0]},
+		chooseSampleCommits(candidates, SampleCommitOptions{
+			Max: 3, RepoWeights: RepoWeights{"repo2": 10, "repo3": 1}}))
+	req.Equal([]Commit{candidates[2], candidates[4], candidates[0], candidates[3], candidates[1]},
+		chooseSampleCommits(candidates, SampleCommitOptions{Max: 10}))
+	req.Empty(chooseSampleCommits(nil, SampleCommitOptions{Max: 2}))
+}
+
+func TestPeopleMergerSampleCommits(t *testing.T) {
+	req := require.New(t)
+	day := func(d int) time.Time { return time.Date(2019, 1, d, 0, 0, 0, 0, time.UTC) }
+	people := People{
+		1: {ID: 1, SampleCommit: &Commit{Hash: "b", Repo: "repo1", Time: day(2)}},
+		2: {ID: 2, SampleCommits: []Commit{{Hash: "a", Repo: "repo2", Time: day(1)},
+			{Hash: "d", Repo: "repo2", Time: day(4)}}},
+		3: {ID: 3, SampleCommits: []Commit{{Hash: "c", Repo: "repo1", Time: day(3)}}},
+	}
+	merger := NewPeopleMerger(people)
+	merger.SampleCommits = SampleCommitOptions{Max: 2}
+	for id := int64(1); id <= 3; id++ {
+		merger.Add(id)
+	}
+	req.NoError(merger.Union(1, 2))
+	req.NoError(merger.Union(2, 3))
+	_, err := merger.Apply()
+	req.NoError(err)
+	req.Len(people, 1)
+	req.Equal([]Commit{{Hash: "a", Repo: "repo2", Time: day(1)},
+		{Hash: "d", Repo: "repo2", Time: day(4)}}, people[1].SampleCommits)
+	req.Equal(&people[1].SampleCommits[0], people[1].SampleCommit)
+}
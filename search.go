@@ -0,0 +1,105 @@
+package idmatch
+
+import (
+	"sort"
+	"strings"
+)
+
+// SearchResult is a person found by PeopleIndex.Search.
+type SearchResult struct {
+	Person *Person
+	// Alias is the name or the email of the person which is the most similar to the query.
+	Alias string
+	// Score is the similarity of Alias and the query from 0 to 1, see PeopleIndex.Search.
+	Score float64
+}
+
+// searchAlias is a name or an email in the trigram index.
+type searchAlias struct {
+	value    string
+	trigrams []string
+	person   *Person
+}
+
+// trigrams returns the unique trigrams of the value padded with two spaces at the start and one
+// at the end, so that the short values and the prefixes weigh more.
+func trigrams(value string) []string {
+	runes := []rune("  " + value + " ")
+	seen := map[string]struct{}{}
+	var result []string
+	for i := 0; i+3 <= len(runes); i++ {
+		trigram := string(runes[i : i+3])
+		if _, exists := seen[trigram]; !exists {
+			seen[trigram] = struct{}{}
+			result = append(result, trigram)
+		}
+	}
+	return result
+}
+
+// Search returns at most limit people whose names or emails are the most similar to the query,
+// e.g. "jon smth" finds "john smith", sorted by the decreasing score and then by ID. The score
+// is the share of the trigrams which the query and the alias have in common, see trigrams, and
+// the results score at least minScore. The query is cleaned the same way as the names unless it
+// is an email.
+func (index *PeopleIndex) Search(query string, limit int, minScore float64) []SearchResult {
+	index.trigramsOnce.Do(func() {
+		index.trigrams = map[string][]int{}
+		for _, id := range peopleIDs(index.people) {
+			person := index.people[id]
+			var values []string
+			for _, name := range person.NamesWithRepos {
+				values = append(values, name.Name)
+			}
+			values = append(values, person.Emails...)
+			for _, value := range unique(values) {
+				alias := searchAlias{value: value, trigrams: trigrams(value), person: person}
+				for _, trigram := range alias.trigrams {
+					index.trigrams[trigram] = append(index.trigrams[trigram], len(index.aliases))
+				}
+				index.aliases = append(index.aliases, alias)
+			}
+		}
+	})
+	query = strings.TrimSpace(normalizeSpaces(strings.ToLower(query)))
+	if !strings.Contains(query, "@") {
+		if cleaned, err := nameCleaner.Clean(query); err == nil && cleaned != "" {
+			query = cleaned
+		}
+	}
+	queryTrigrams := trigrams(query)
+	common := map[int]int{}
+	for _, trigram := range queryTrigrams {
+		for _, alias := range index.trigrams[trigram] {
+			common[alias]++
+		}
+	}
+	best := map[*Person]SearchResult{}
+	for alias, count := range common {
+		entry := index.aliases[alias]
+		// Jaccard similarity of the trigram sets
+		score := float64(count) / float64(len(queryTrigrams)+len(entry.trigrams)-count)
+		if score < minScore {
+			continue
+		}
+		previous, exists := best[entry.person]
+		if !exists || score > previous.Score ||
+			(score == previous.Score && entry.value < previous.Alias) {
+			best[entry.person] = SearchResult{Person: entry.person, Alias: entry.value, Score: score}
+		}
+	}
+	results := make([]SearchResult, 0, len(best))
+	for _, result := range best {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Person.ID < results[j].Person.ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
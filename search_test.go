@@ -0,0 +1,42 @@
+package idmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrigrams(t *testing.T) {
+	require.Equal(t, []string{"  b", " bo", "bob", "ob "}, trigrams("bob"))
+	require.Equal(t, []string{"  a", " aa", "aaa", "aa "}, trigrams("aaaa"))
+}
+
+func TestPeopleIndexSearch(t *testing.T) {
+	req := require.New(t)
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"john smith", ""}},
+			Emails: []string{"john@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"jane smith", ""}},
+			Emails: []string{"jane@google.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+	}
+	index := people.Index()
+
+	results := index.Search("Jon Smth", 10, 0.2)
+	req.NotEmpty(results)
+	req.Equal(people[1], results[0].Person)
+	req.Equal("john smith", results[0].Alias)
+	for i := 1; i < len(results); i++ {
+		req.True(results[i-1].Score >= results[i].Score)
+		req.NotEqual(people[3], results[i].Person)
+	}
+
+	results = index.Search("alice@google.com", 10, 0)
+	req.Equal(people[3], results[0].Person)
+	req.Equal("alice@google.com", results[0].Alias)
+	req.Equal(1.0, results[0].Score)
+	// all the people share the trigrams of the domain
+	req.Len(results, 3)
+	req.Len(index.Search("alice@google.com", 1, 0), 1)
+	req.Empty(index.Search("zzz", 10, 0))
+}
@@ -0,0 +1,91 @@
+package idmatch
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// IdentitySeeds map the emails to the IDs of the people in a previously curated identity map.
+// The signatures with the emails of the same seed person start as a single identity, so that
+// the curation is not lost and the new signatures attach to the existing people. The seed IDs
+// only group the emails and do not become the IDs of the matched people.
+type IdentitySeeds map[string]string
+
+// ReadIdentitySeeds loads the seeds from a CSV file with the columns "email" and "id".
+// The emails are cleaned the same way as the signatures.
+func ReadIdentitySeeds(path string) (seeds IdentitySeeds, err error) {
+	seeds = IdentitySeeds{}
+	err = readCSVRecords(path, "identity seeds", []string{"email", "id"},
+		func(header map[string]int, record []string) error {
+			email, err := cleanEmail(record[header["email"]])
+			if err != nil {
+				return err
+			}
+			id := strings.TrimSpace(record[header["id"]])
+			if email == "" || id == "" {
+				return nil
+			}
+			if previous, exists := seeds[email]; exists && previous != id {
+				return fmt.Errorf("invalid identity seeds file %s: %s belongs to %s and %s",
+					path, email, previous, id)
+			}
+			seeds[email] = id
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return seeds, nil
+}
+
+// SeedsFromPeople returns the seeds of the people matched before, e.g. read from
+// the identities parquet of a prior run.
+func SeedsFromPeople(people People) IdentitySeeds {
+	seeds := IdentitySeeds{}
+	for id, person := range people {
+		for _, email := range person.Emails {
+			seeds[email] = strconv.FormatInt(id, 10)
+		}
+	}
+	return seeds
+}
+
+// applySeeds merges the people whose emails belong to the same seed person.
+func (p People) applySeeds(seeds IdentitySeeds) error {
+	if len(seeds) == 0 {
+		return nil
+	}
+	bySeed := map[string][]int64{}
+	for id, person := range p {
+		for _, email := range person.Emails {
+			if seed, exists := seeds[email]; exists {
+				bySeed[seed] = append(bySeed[seed], id)
+				break
+			}
+		}
+	}
+	keys := make([]string, 0, len(bySeed))
+	for seed := range bySeed {
+		keys = append(keys, seed)
+	}
+	sort.Strings(keys)
+	merger := NewPeopleMerger(p)
+	for _, seed := range keys {
+		ids := bySeed[seed]
+		Int64Slice(ids).Sort()
+		for _, id := range ids[1:] {
+			if err := merger.Union(ids[0], id); err != nil {
+				return fmt.Errorf("seed %s: %w", seed, err)
+			}
+		}
+	}
+	if _, err := merger.Apply(); err != nil {
+		return err
+	}
+	reporter.Commit("seed people", len(bySeed))
+	return nil
+}
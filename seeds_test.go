@@ -0,0 +1,76 @@
+package idmatch
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadIdentitySeeds(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := f.WriteString("email,id\nBob@Google.com,bob\nalice@google.com, 7 \n,8\n")
+	req.NoError(err)
+	seeds, err := ReadIdentitySeeds(f.Name())
+	req.NoError(err)
+	req.Equal(IdentitySeeds{"bob@google.com": "bob", "alice@google.com": "7"}, seeds)
+
+	f2, cleanup2 := tempFile(t, "*.csv")
+	defer cleanup2()
+	_, err = f2.WriteString("email,person\nbob@google.com,1\n")
+	req.NoError(err)
+	_, err = ReadIdentitySeeds(f2.Name())
+	req.EqualError(err, "invalid identity seeds file "+f2.Name()+": no id column")
+
+	f3, cleanup3 := tempFile(t, "*.csv")
+	defer cleanup3()
+	_, err = f3.WriteString("email,id\nbob@google.com,1\nbob@google.com,2\n")
+	req.NoError(err)
+	_, err = ReadIdentitySeeds(f3.Name())
+	req.EqualError(err, "invalid identity seeds file "+f3.Name()+": bob@google.com belongs to 1 and 2")
+}
+
+func TestSeedsFromPeople(t *testing.T) {
+	people := People{
+		3: {ID: 3, Emails: []string{"bob@google.com", "bob@gmail.com"}},
+		5: {ID: 5, Emails: []string{"alice@google.com"}},
+	}
+	require.Equal(t, IdentitySeeds{"bob@google.com": "3", "bob@gmail.com": "3",
+		"alice@google.com": "5"}, SeedsFromPeople(people))
+}
+
+func TestApplySeeds(t *testing.T) {
+	req := require.New(t)
+	people, err := newPeople(nil, Signatures, newTestBlacklist(t))
+	req.NoError(err)
+	req.Len(people, 4)
+	req.NoError(people.applySeeds(nil))
+	req.Len(people, 4)
+
+	req.NoError(people.applySeeds(IdentitySeeds{
+		"bob@google.com": "curated", "alice@google.com": "curated", "unknown@google.com": "other"}))
+	req.Len(people, 1)
+	req.Equal([]string{"alice@google.com", "bob@google.com"}, people[1].Emails)
+	req.Equal(4, people[1].Commits)
+
+	people, err = newPeople(nil, Signatures, newTestBlacklist(t))
+	req.NoError(err)
+	people[1].ExternalIDs = ExternalIDs{"github": "bob"}
+	people[3].ExternalIDs = ExternalIDs{"github": "alice"}
+	err = people.applySeeds(IdentitySeeds{"bob@google.com": "1", "alice@google.com": "1"})
+	req.EqualError(err,
+		"seed 1: conflicting external IDs: cannot merge ids 1 and 3 with different ExternalIDs: bob alice")
+	req.ErrorIs(err, ErrExternalIDConflict)
+}
+
+func TestPeopleFromSignaturesSeeds(t *testing.T) {
+	req := require.New(t)
+	extraction := ExtractionOptions{Seeds: IdentitySeeds{"bob@google.com": "1", "alice@google.com": "1"}}
+	people, _, _, err := PeopleFromSignatures(context.Background(),
+		append([]Signature{}, Signatures...), extraction, newTestBlacklist(t), PopularityThresholds{},
+		BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	req.Len(people, 1)
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	idmatch "github.com/src-d/identity-matching"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Stats summarize the state of the Server for the dashboards.
+type Stats struct {
+	// Signatures is the number of the submitted signatures.
+	Signatures int `json:"signatures"`
+	// People is the number of the matched people.
+	People int `json:"people"`
+	// MergedPeople is the number of the matched people which consist of several original people.
+	MergedPeople int `json:"merged_people"`
+	// Bots is the number of the matched people which are bots.
+	Bots int `json:"bots"`
+	// ExternalIDs is the number of the matched people with an external ID.
+	ExternalIDs int `json:"external_ids"`
+}
+
+// NewHTTPHandler exposes the Server as the REST API with JSON bodies:
+//
+//	GET  /people       lists the matched people sorted by ID.
+//	GET  /people/{id}  returns the matched person, see GetPerson.
+//	POST /match        submits the array of signatures in the body, which may be empty, and
+//	                   matches everything submitted so far, see Match.
+//	POST /merge        merges {"ids": [...]}, see MergePeople.
+//	POST /split        splits {"id": ...}, see SplitPerson.
+//	GET  /stats        returns Stats.
+//	GET  /review       renders the review UI of the uncertain merges.
+//	GET  /review/candidates   lists the uncertain merges without a verdict, see ReviewCandidate.
+//	POST /review/decisions    records ReviewDecision in the body.
+//	POST /review/decide       records the verdict of the review UI form and redirects back.
+//
+// The errors are returned as {"error": "..."} with the HTTP status of the gRPC code.
+func NewHTTPHandler(server *Server) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/people", httpMethod(http.MethodGet, func(r *http.Request) (interface{}, error) {
+		return server.listPeople(), nil
+	}))
+	mux.HandleFunc("/people/", httpMethod(http.MethodGet, func(r *http.Request) (interface{}, error) {
+		id, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/people/"), 10, 64)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid person id: %v", err)
+		}
+		return server.GetPerson(r.Context(), &GetPersonRequest{Id: id})
+	}))
+	mux.HandleFunc("/match", httpMethod(http.MethodPost, func(r *http.Request) (interface{}, error) {
+		var messages []*Signature
+		if err := decodeHTTPBody(r, &messages); err != nil {
+			return nil, err
+		}
+		signatures := make([]idmatch.Signature, 0, len(messages))
+		for i, message := range messages {
+			signature, err := signatureFromMessage(message)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "signature %d: %v", i+1, err)
+			}
+			signatures = append(signatures, signature)
+		}
+		if len(signatures) > 0 {
+			server.submit(signatures)
+		}
+		return server.Match(r.Context(), &MatchRequest{})
+	}))
+	mux.HandleFunc("/merge", httpMethod(http.MethodPost, func(r *http.Request) (interface{}, error) {
+		request := &MergePeopleRequest{}
+		if err := decodeHTTPBody(r, request); err != nil {
+			return nil, err
+		}
+		return server.MergePeople(r.Context(), request)
+	}))
+	mux.HandleFunc("/split", httpMethod(http.MethodPost, func(r *http.Request) (interface{}, error) {
+		request := &SplitPersonRequest{}
+		if err := decodeHTTPBody(r, request); err != nil {
+			return nil, err
+		}
+		return server.SplitPerson(r.Context(), request)
+	}))
+	mux.HandleFunc("/stats", httpMethod(http.MethodGet, func(r *http.Request) (interface{}, error) {
+		return server.stats(), nil
+	}))
+	mux.HandleFunc("/review", server.handleReviewPage)
+	mux.HandleFunc("/review/candidates", httpMethod(http.MethodGet, func(r *http.Request) (interface{}, error) {
+		return server.reviewCandidates(), nil
+	}))
+	mux.HandleFunc("/review/decisions", httpMethod(http.MethodPost, func(r *http.Request) (interface{}, error) {
+		var decision ReviewDecision
+		if err := decodeHTTPBody(r, &decision); err != nil {
+			return nil, err
+		}
+		if err := server.decide(decision); err != nil {
+			return nil, err
+		}
+		return decision, nil
+	}))
+	mux.HandleFunc("/review/decide", server.handleReviewDecision)
+	return mux
+}
+
+// httpMethod wraps the handler which accepts only the HTTP method and writes the JSON response.
+func httpMethod(method string, handler func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeHTTPError(w, http.StatusMethodNotAllowed, r.Method+" is not allowed")
+			return
+		}
+		response, err := handler(r)
+		if err != nil {
+			writeHTTPError(w, httpStatus(status.Code(err)), status.Convert(err).Message())
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// decodeHTTPBody parses the JSON body of the request. The empty body leaves the value intact.
+func decodeHTTPBody(r *http.Request, value interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(value); err != nil && err != io.EOF {
+		return status.Errorf(codes.InvalidArgument, "invalid body: %v", err)
+	}
+	return nil
+}
+
+func writeHTTPError(w http.ResponseWriter, code int, message string) {
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// httpStatus converts the gRPC code of the Server error.
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.FailedPrecondition:
+		return http.StatusConflict
+	case codes.Canceled:
+		return http.StatusRequestTimeout
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// listPeople returns all the matched people sorted by ID.
+func (s *Server) listPeople() []*Person {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	snapshot := s.people.Snapshot()
+	people := make([]*Person, 0, len(snapshot))
+	for _, person := range snapshot {
+		people = append(people, s.personMessage(person))
+	}
+	sort.Slice(people, func(i, j int) bool { return people[i].Id < people[j].Id })
+	return people
+}
+
+func (s *Server) stats() Stats {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	people := s.people.Snapshot()
+	stats := Stats{Signatures: len(s.signatures), People: len(people)}
+	for id, person := range people {
+		if len(s.members[id]) > 1 {
+			stats.MergedPeople++
+		}
+		if person.IsBot {
+			stats.Bots++
+		}
+		if len(person.ExternalIDs) > 0 {
+			stats.ExternalIDs++
+		}
+	}
+	return stats
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func newTestHTTPServer(t *testing.T) *httptest.Server {
+	blacklist, err := idmatch.NewBlacklist()
+	require.NoError(t, err)
+	server := httptest.NewServer(NewHTTPHandler(NewServer(Options{
+		Blacklist:    blacklist,
+		RecentMonths: 12,
+		Primary:      idmatch.PrimaryOptions{MinRecentCount: 5},
+		Reduce:       idmatch.ReduceOptions{MaxIdentities: 20},
+	})))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doHTTP(t *testing.T, method, url, body string, response interface{}) int {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	if response != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(response))
+	}
+	return resp.StatusCode
+}
+
+func TestHTTPHandler(t *testing.T) {
+	req := require.New(t)
+	server := newTestHTTPServer(t)
+
+	var match MatchResponse
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodPost, server.URL+"/match", `[
+		{"repo": "repo1", "name": "Alice Smith", "email": "alice@alicesmith.dev", "time": "2019-01-01T10:00:00Z"},
+		{"repo": "repo2", "name": "Alice Smith", "email": "alice.smith@example-home.org"},
+		{"repo": "repo1", "name": "Bob Jones", "email": "bob@bobjones.dev"}]`, &match))
+	req.Equal(int64(2), match.People)
+
+	var people []*Person
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodGet, server.URL+"/people", "", &people))
+	req.Len(people, 2)
+	req.True(people[0].Id < people[1].Id)
+	alice := people[0]
+	if len(alice.Emails) == 1 {
+		alice = people[1]
+	}
+	req.Len(alice.Signatures, 2)
+
+	var person Person
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodGet,
+		server.URL+"/people/"+strconv.FormatInt(alice.Id, 10), "", &person))
+	req.Equal(alice.Emails, person.Emails)
+
+	var stats Stats
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodGet, server.URL+"/stats", "", &stats))
+	req.Equal(Stats{Signatures: 3, People: 2, MergedPeople: 1}, stats)
+
+	var split SplitPersonResponse
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodPost, server.URL+"/split",
+		`{"id": `+strconv.FormatInt(alice.Id, 10)+`}`, &split))
+	req.Len(split.People, 2)
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodGet, server.URL+"/stats", "", &stats))
+	req.Equal(Stats{Signatures: 3, People: 3}, stats)
+
+	var merged Person
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodPost, server.URL+"/merge", `{"ids": [1, 2, 3]}`, &merged))
+	req.Len(merged.Emails, 3)
+
+	// the empty body matches again and discards the merge
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodPost, server.URL+"/match", "", &match))
+	req.Equal(int64(2), match.People)
+}
+
+func TestHTTPHandlerErrors(t *testing.T) {
+	req := require.New(t)
+	server := newTestHTTPServer(t)
+	var response map[string]string
+	req.Equal(http.StatusNotFound, doHTTP(t, http.MethodGet, server.URL+"/people/1", "", &response))
+	req.Equal("person 1 does not exist", response["error"])
+	req.Equal(http.StatusBadRequest, doHTTP(t, http.MethodGet, server.URL+"/people/x", "", &response))
+	req.Equal(http.StatusMethodNotAllowed, doHTTP(t, http.MethodGet, server.URL+"/match", "", &response))
+	req.Equal("GET is not allowed", response["error"])
+	req.Equal(http.StatusBadRequest, doHTTP(t, http.MethodPost, server.URL+"/match", "{", &response))
+	req.Equal(http.StatusBadRequest, doHTTP(t, http.MethodPost, server.URL+"/match",
+		`[{"name": "x", "time": "yesterday"}]`, &response))
+	req.Equal(http.StatusBadRequest, doHTTP(t, http.MethodPost, server.URL+"/merge", `{"ids": []}`, &response))
+}
+
+func TestHTTPStatus(t *testing.T) {
+	req := require.New(t)
+	req.Equal(http.StatusBadRequest, httpStatus(codes.InvalidArgument))
+	req.Equal(http.StatusConflict, httpStatus(codes.FailedPrecondition))
+	req.Equal(http.StatusServiceUnavailable, httpStatus(codes.Unavailable))
+	req.Equal(http.StatusServiceUnavailable, httpStatus(errorCode(idmatch.ErrSourceUnavailable)))
+	req.Equal(http.StatusInternalServerError, httpStatus(codes.Internal))
+}
@@ -0,0 +1,328 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package service
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// IdentityMatchingClient is the client API for IdentityMatching service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type IdentityMatchingClient interface {
+	// SubmitSignatures adds the streamed signatures to the pending dataset.
+	SubmitSignatures(ctx context.Context, opts ...grpc.CallOption) (IdentityMatching_SubmitSignaturesClient, error)
+	// Match matches all the submitted signatures into the people, replacing the previous result.
+	Match(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error)
+	// GetPerson returns the matched person by ID.
+	GetPerson(ctx context.Context, in *GetPersonRequest, opts ...grpc.CallOption) (*Person, error)
+	// MergePeople merges the matched people into the one with the smallest ID.
+	MergePeople(ctx context.Context, in *MergePeopleRequest, opts ...grpc.CallOption) (*Person, error)
+	// SplitPerson splits the matched person back into the identities of its signatures.
+	SplitPerson(ctx context.Context, in *SplitPersonRequest, opts ...grpc.CallOption) (*SplitPersonResponse, error)
+	// ExportParquet writes the matched people to the parquet files on the server.
+	ExportParquet(ctx context.Context, in *ExportParquetRequest, opts ...grpc.CallOption) (*ExportParquetResponse, error)
+}
+
+type identityMatchingClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIdentityMatchingClient(cc grpc.ClientConnInterface) IdentityMatchingClient {
+	return &identityMatchingClient{cc}
+}
+
+func (c *identityMatchingClient) SubmitSignatures(ctx context.Context, opts ...grpc.CallOption) (IdentityMatching_SubmitSignaturesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &IdentityMatching_ServiceDesc.Streams[0], "/identitymatching.IdentityMatching/SubmitSignatures", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &identityMatchingSubmitSignaturesClient{stream}
+	return x, nil
+}
+
+type IdentityMatching_SubmitSignaturesClient interface {
+	Send(*Signature) error
+	CloseAndRecv() (*SubmitSignaturesResponse, error)
+	grpc.ClientStream
+}
+
+type identityMatchingSubmitSignaturesClient struct {
+	grpc.ClientStream
+}
+
+func (x *identityMatchingSubmitSignaturesClient) Send(m *Signature) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *identityMatchingSubmitSignaturesClient) CloseAndRecv() (*SubmitSignaturesResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(SubmitSignaturesResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *identityMatchingClient) Match(ctx context.Context, in *MatchRequest, opts ...grpc.CallOption) (*MatchResponse, error) {
+	out := new(MatchResponse)
+	err := c.cc.Invoke(ctx, "/identitymatching.IdentityMatching/Match", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityMatchingClient) GetPerson(ctx context.Context, in *GetPersonRequest, opts ...grpc.CallOption) (*Person, error) {
+	out := new(Person)
+	err := c.cc.Invoke(ctx, "/identitymatching.IdentityMatching/GetPerson", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityMatchingClient) MergePeople(ctx context.Context, in *MergePeopleRequest, opts ...grpc.CallOption) (*Person, error) {
+	out := new(Person)
+	err := c.cc.Invoke(ctx, "/identitymatching.IdentityMatching/MergePeople", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityMatchingClient) SplitPerson(ctx context.Context, in *SplitPersonRequest, opts ...grpc.CallOption) (*SplitPersonResponse, error) {
+	out := new(SplitPersonResponse)
+	err := c.cc.Invoke(ctx, "/identitymatching.IdentityMatching/SplitPerson", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *identityMatchingClient) ExportParquet(ctx context.Context, in *ExportParquetRequest, opts ...grpc.CallOption) (*ExportParquetResponse, error) {
+	out := new(ExportParquetResponse)
+	err := c.cc.Invoke(ctx, "/identitymatching.IdentityMatching/ExportParquet", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IdentityMatchingServer is the server API for IdentityMatching service.
+// All implementations must embed UnimplementedIdentityMatchingServer
+// for forward compatibility
+type IdentityMatchingServer interface {
+	// SubmitSignatures adds the streamed signatures to the pending dataset.
+	SubmitSignatures(IdentityMatching_SubmitSignaturesServer) error
+	// Match matches all the submitted signatures into the people, replacing the previous result.
+	Match(context.Context, *MatchRequest) (*MatchResponse, error)
+	// GetPerson returns the matched person by ID.
+	GetPerson(context.Context, *GetPersonRequest) (*Person, error)
+	// MergePeople merges the matched people into the one with the smallest ID.
+	MergePeople(context.Context, *MergePeopleRequest) (*Person, error)
+	// SplitPerson splits the matched person back into the identities of its signatures.
+	SplitPerson(context.Context, *SplitPersonRequest) (*SplitPersonResponse, error)
+	// ExportParquet writes the matched people to the parquet files on the server.
+	ExportParquet(context.Context, *ExportParquetRequest) (*ExportParquetResponse, error)
+	mustEmbedUnimplementedIdentityMatchingServer()
+}
+
+// UnimplementedIdentityMatchingServer must be embedded to have forward compatible implementations.
+type UnimplementedIdentityMatchingServer struct {
+}
+
+func (UnimplementedIdentityMatchingServer) SubmitSignatures(IdentityMatching_SubmitSignaturesServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubmitSignatures not implemented")
+}
+func (UnimplementedIdentityMatchingServer) Match(context.Context, *MatchRequest) (*MatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Match not implemented")
+}
+func (UnimplementedIdentityMatchingServer) GetPerson(context.Context, *GetPersonRequest) (*Person, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPerson not implemented")
+}
+func (UnimplementedIdentityMatchingServer) MergePeople(context.Context, *MergePeopleRequest) (*Person, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MergePeople not implemented")
+}
+func (UnimplementedIdentityMatchingServer) SplitPerson(context.Context, *SplitPersonRequest) (*SplitPersonResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SplitPerson not implemented")
+}
+func (UnimplementedIdentityMatchingServer) ExportParquet(context.Context, *ExportParquetRequest) (*ExportParquetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportParquet not implemented")
+}
+func (UnimplementedIdentityMatchingServer) mustEmbedUnimplementedIdentityMatchingServer() {}
+
+// UnsafeIdentityMatchingServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IdentityMatchingServer will
+// result in compilation errors.
+type UnsafeIdentityMatchingServer interface {
+	mustEmbedUnimplementedIdentityMatchingServer()
+}
+
+func RegisterIdentityMatchingServer(s grpc.ServiceRegistrar, srv IdentityMatchingServer) {
+	s.RegisterService(&IdentityMatching_ServiceDesc, srv)
+}
+
+func _IdentityMatching_SubmitSignatures_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IdentityMatchingServer).SubmitSignatures(&identityMatchingSubmitSignaturesServer{stream})
+}
+
+type IdentityMatching_SubmitSignaturesServer interface {
+	SendAndClose(*SubmitSignaturesResponse) error
+	Recv() (*Signature, error)
+	grpc.ServerStream
+}
+
+type identityMatchingSubmitSignaturesServer struct {
+	grpc.ServerStream
+}
+
+func (x *identityMatchingSubmitSignaturesServer) SendAndClose(m *SubmitSignaturesResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *identityMatchingSubmitSignaturesServer) Recv() (*Signature, error) {
+	m := new(Signature)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _IdentityMatching_Match_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityMatchingServer).Match(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/identitymatching.IdentityMatching/Match",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityMatchingServer).Match(ctx, req.(*MatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdentityMatching_GetPerson_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPersonRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityMatchingServer).GetPerson(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/identitymatching.IdentityMatching/GetPerson",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityMatchingServer).GetPerson(ctx, req.(*GetPersonRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdentityMatching_MergePeople_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MergePeopleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityMatchingServer).MergePeople(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/identitymatching.IdentityMatching/MergePeople",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityMatchingServer).MergePeople(ctx, req.(*MergePeopleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdentityMatching_SplitPerson_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SplitPersonRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityMatchingServer).SplitPerson(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/identitymatching.IdentityMatching/SplitPerson",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityMatchingServer).SplitPerson(ctx, req.(*SplitPersonRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _IdentityMatching_ExportParquet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportParquetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IdentityMatchingServer).ExportParquet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/identitymatching.IdentityMatching/ExportParquet",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IdentityMatchingServer).ExportParquet(ctx, req.(*ExportParquetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// IdentityMatching_ServiceDesc is the grpc.ServiceDesc for IdentityMatching service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var IdentityMatching_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "identitymatching.IdentityMatching",
+	HandlerType: (*IdentityMatchingServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Match",
+			Handler:    _IdentityMatching_Match_Handler,
+		},
+		{
+			MethodName: "GetPerson",
+			Handler:    _IdentityMatching_GetPerson_Handler,
+		},
+		{
+			MethodName: "MergePeople",
+			Handler:    _IdentityMatching_MergePeople_Handler,
+		},
+		{
+			MethodName: "SplitPerson",
+			Handler:    _IdentityMatching_SplitPerson_Handler,
+		},
+		{
+			MethodName: "ExportParquet",
+			Handler:    _IdentityMatching_ExportParquet_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubmitSignatures",
+			Handler:       _IdentityMatching_SubmitSignatures_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "identity.proto",
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	idmatch "github.com/src-d/identity-matching"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ReviewIdentity is a side of ReviewCandidate: an identity as it was before any merges.
+type ReviewIdentity struct {
+	ID     int64    `json:"id"`
+	Names  []string `json:"names"`
+	Emails []string `json:"emails"`
+	// Person is the ID of the matched person which contains the identity.
+	Person int64 `json:"person"`
+}
+
+// ReviewCandidate is an uncertain edge between two identities which awaits the verdict of
+// a reviewer, see idmatch.IdentityGraph.ReviewCandidates.
+type ReviewCandidate struct {
+	From     ReviewIdentity `json:"from"`
+	To       ReviewIdentity `json:"to"`
+	Evidence []string       `json:"evidence"`
+	Weight   float64        `json:"weight"`
+	// Merged indicates that the edge is active, so the identities were merged.
+	Merged bool `json:"merged"`
+}
+
+// ReviewDecision is the verdict about a ReviewCandidate.
+type ReviewDecision struct {
+	From    int64                 `json:"from"`
+	To      int64                 `json:"to"`
+	Verdict idmatch.ReviewVerdict `json:"verdict"`
+}
+
+// reviewCandidates returns the candidates of the last Match without a verdict.
+func (s *Server) reviewCandidates() []ReviewCandidate {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if s.graph == nil {
+		return []ReviewCandidate{}
+	}
+	persons := map[int64]int64{}
+	for person, members := range s.members {
+		for _, id := range members {
+			persons[id] = person
+		}
+	}
+	nodes := map[int64]idmatch.IdentityNode{}
+	for _, node := range s.graph.Nodes() {
+		nodes[node.ID] = node
+	}
+	side := func(id int64) ReviewIdentity {
+		node := nodes[id]
+		identity := ReviewIdentity{ID: id, Emails: node.Emails, Person: persons[id]}
+		for _, name := range node.NamesWithRepos {
+			identity.Names = append(identity.Names, name.String())
+		}
+		return identity
+	}
+	candidates := []ReviewCandidate{}
+	for _, edge := range s.graph.ReviewCandidates(s.options.ReviewMargin) {
+		identity1, _ := s.graph.ReviewIdentity(edge.From)
+		identity2, _ := s.graph.ReviewIdentity(edge.To)
+		if _, decided := s.decisions.Verdict(identity1, identity2); decided {
+			continue
+		}
+		candidate := ReviewCandidate{
+			From:   side(edge.From),
+			To:     side(edge.To),
+			Weight: edge.Weight,
+			Merged: edge.Active,
+		}
+		for _, evidence := range edge.Evidence {
+			candidate.Evidence = append(candidate.Evidence, evidence.String())
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// decide records the verdict about the edge between the identities of the last Match and
+// persists all the decisions to Options.ReviewDecisionsPath. The verdict applies from
+// the next Match.
+func (s *Server) decide(decision ReviewDecision) error {
+	if decision.Verdict != idmatch.ReviewApprove && decision.Verdict != idmatch.ReviewReject {
+		return status.Errorf(codes.InvalidArgument, "unknown verdict: %s", decision.Verdict)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.graph == nil {
+		return status.Error(codes.FailedPrecondition, "nothing is matched yet")
+	}
+	identity1, exists1 := s.graph.ReviewIdentity(decision.From)
+	identity2, exists2 := s.graph.ReviewIdentity(decision.To)
+	if !exists1 || !exists2 || decision.From == decision.To {
+		return status.Errorf(codes.NotFound, "identities %d and %d do not exist",
+			decision.From, decision.To)
+	}
+	s.decisions.Set(identity1, identity2, decision.Verdict)
+	if s.options.ReviewDecisionsPath != "" {
+		if err := s.decisions.Write(s.options.ReviewDecisionsPath); err != nil {
+			return status.Errorf(codes.Internal, "failed to store the decisions: %v", err)
+		}
+	}
+	return nil
+}
+
+// handleReviewPage renders the review UI.
+func (s *Server) handleReviewPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, r.Method+" is not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := reviewTemplate.Execute(w, s.reviewCandidates()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleReviewDecision records the verdict posted by the review UI form and redirects back.
+func (s *Server) handleReviewDecision(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, r.Method+" is not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	decision := ReviewDecision{Verdict: idmatch.ReviewVerdict(r.FormValue("verdict"))}
+	var err error
+	if decision.From, err = strconv.ParseInt(r.FormValue("from"), 10, 64); err != nil {
+		http.Error(w, fmt.Sprintf("invalid from: %v", err), http.StatusBadRequest)
+		return
+	}
+	if decision.To, err = strconv.ParseInt(r.FormValue("to"), 10, 64); err != nil {
+		http.Error(w, fmt.Sprintf("invalid to: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.decide(decision); err != nil {
+		http.Error(w, status.Convert(err).Message(), httpStatus(status.Code(err)))
+		return
+	}
+	http.Redirect(w, r, "/review", http.StatusSeeOther)
+}
+
+var reviewTemplate = template.Must(template.New("review").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Identity matching review</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+td, th { border: 1px solid #ccc; padding: 0.5em; vertical-align: top; text-align: left; }
+.merged { color: #060; }
+.separate { color: #a00; }
+form { display: inline; }
+</style>
+</head>
+<body>
+<h1>Uncertain merges</h1>
+<p>{{len .}} candidates. The verdicts apply from the next match.</p>
+<table>
+<tr><th>Identity</th><th>Evidence</th><th>Identity</th><th>Verdict</th></tr>
+{{range .}}
+<tr>
+<td>#{{.From.ID}} (person {{.From.Person}})<br>{{range .From.Names}}{{.}}<br>{{end}}{{range .From.Emails}}{{.}}<br>{{end}}</td>
+<td>{{range .Evidence}}{{.}}<br>{{end}}weight {{printf "%.2f" .Weight}}<br>
+{{if .Merged}}<span class="merged">merged</span>{{else}}<span class="separate">separate</span>{{end}}</td>
+<td>#{{.To.ID}} (person {{.To.Person}})<br>{{range .To.Names}}{{.}}<br>{{end}}{{range .To.Emails}}{{.}}<br>{{end}}</td>
+<td>
+<form method="post" action="/review/decide"><input type="hidden" name="from" value="{{.From.ID}}"><input type="hidden" name="to" value="{{.To.ID}}"><button name="verdict" value="approve">Approve</button></form>
+<form method="post" action="/review/decide"><input type="hidden" name="from" value="{{.From.ID}}"><input type="hidden" name="to" value="{{.To.ID}}"><button name="verdict" value="reject">Reject</button></form>
+</td>
+</tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReview(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-review")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "decisions.csv")
+	blacklist, err := idmatch.NewBlacklist()
+	req.NoError(err)
+	server := httptest.NewServer(NewHTTPHandler(NewServer(Options{
+		Blacklist:           blacklist,
+		RecentMonths:        12,
+		Primary:             idmatch.PrimaryOptions{MinRecentCount: 5},
+		Reduce:              idmatch.ReduceOptions{MaxIdentities: 20},
+		ReviewMargin:        0.5,
+		ReviewDecisionsPath: path,
+	})))
+	defer server.Close()
+
+	var candidates []ReviewCandidate
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodGet, server.URL+"/review/candidates", "", &candidates))
+	req.Len(candidates, 0)
+	var response map[string]string
+	req.Equal(http.StatusConflict, doHTTP(t, http.MethodPost, server.URL+"/review/decisions",
+		`{"from": 1, "to": 2, "verdict": "approve"}`, &response))
+
+	var match MatchResponse
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodPost, server.URL+"/match", `[
+		{"repo": "repo1", "name": "Alice Smith", "email": "alice@alicesmith.dev"},
+		{"repo": "repo2", "name": "Alice Smith", "email": "alice.smith@example-home.org"},
+		{"repo": "repo1", "name": "Bob Jones", "email": "bob@bobjones.dev"}]`, &match))
+	req.Equal(int64(2), match.People)
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodGet, server.URL+"/review/candidates", "", &candidates))
+	req.Len(candidates, 1)
+	candidate := candidates[0]
+	req.True(candidate.Merged)
+	req.Equal([]string{"name:alice smith"}, candidate.Evidence)
+	req.Equal([]string{"alice smith"}, candidate.From.Names)
+	req.Equal(candidate.From.Person, candidate.To.Person)
+
+	resp, err := http.Get(server.URL + "/review")
+	req.NoError(err)
+	page, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	req.NoError(err)
+	req.Equal(http.StatusOK, resp.StatusCode)
+	req.Contains(string(page), "alice.smith@example-home.org")
+	req.Contains(string(page), `value="reject"`)
+
+	req.Equal(http.StatusBadRequest, doHTTP(t, http.MethodPost, server.URL+"/review/decisions",
+		`{"from": 1, "to": 2, "verdict": "maybe"}`, &response))
+	req.Equal(http.StatusNotFound, doHTTP(t, http.MethodPost, server.URL+"/review/decisions",
+		`{"from": 1, "to": 100, "verdict": "reject"}`, &response))
+
+	// the form of the UI redirects back to the page
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err = client.PostForm(server.URL+"/review/decide", url.Values{
+		"from":    {strconv.FormatInt(candidate.From.ID, 10)},
+		"to":      {strconv.FormatInt(candidate.To.ID, 10)},
+		"verdict": {"reject"},
+	})
+	req.NoError(err)
+	resp.Body.Close()
+	req.Equal(http.StatusSeeOther, resp.StatusCode)
+	req.Equal("/review", resp.Header.Get("Location"))
+
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodGet, server.URL+"/review/candidates", "", &candidates))
+	req.Len(candidates, 0)
+	decisions, err := idmatch.ReadReviewDecisions(path)
+	req.NoError(err)
+	req.Len(decisions, 1)
+	content, err := ioutil.ReadFile(path)
+	req.NoError(err)
+	req.True(strings.HasSuffix(string(content), ",reject\n"))
+
+	// the rejection applies from the next match
+	req.Equal(http.StatusOK, doHTTP(t, http.MethodPost, server.URL+"/match", "", &match))
+	req.Equal(int64(3), match.People)
+}
@@ -0,0 +1,354 @@
+// Package service exposes the identity matching as a long-running gRPC service which collects
+// the signatures, matches them on demand and lets the operators fix the result.
+package service
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative identity.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/src-d/identity-matching/external"
+	"github.com/src-d/identity-matching/reporter"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Options configure the matching of the Server, see idmatch.PeopleFromSignatures and
+// idmatch.ReducePeople.
+type Options struct {
+	Extraction   idmatch.ExtractionOptions
+	Blacklist    idmatch.Blacklist
+	Popularity   idmatch.PopularityThresholds
+	Bots         idmatch.BotDetectionOptions
+	RecentMonths int
+	Reduce       idmatch.ReduceOptions
+	// Primary chooses the primary names and emails which ExportParquet writes.
+	Primary idmatch.PrimaryOptions
+	// Matcher is the external matching service. May be nil.
+	Matcher external.Matcher
+	// ExternalIDProvider is the name of Matcher which ExportParquet writes.
+	ExternalIDProvider string
+	// ReviewMargin is the margin of the edge weights around the threshold which makes the merges
+	// uncertain, see idmatch.IdentityGraph.ReviewCandidates.
+	ReviewMargin float64
+	// ReviewDecisionsPath is the CSV file where the review verdicts are stored after each
+	// decision, see idmatch.ReviewDecisions. The blank value keeps them only in memory.
+	// The initial decisions are Reduce.Decisions.
+	ReviewDecisionsPath string
+	// ExportDir is the directory where ExportParquet writes the parquet files. The paths in
+	// the requests are relative to it and may not leave it. The blank value disables ExportParquet.
+	ExportDir string
+}
+
+// Server implements IdentityMatchingServer. The signatures are accumulated by SubmitSignatures
+// and matched by Match; the other RPCs work with the result of the last Match. All the methods
+// are safe for concurrent use. The people are idmatch.ConcurrentPeople: the readers take
+// the snapshots and the changes replace them. lock guards the rest of the state, and the changes
+// of the people hold it exclusively so that the snapshot always agrees with members; Match holds
+// it only to replace the result, so the people are served while the next Match runs, and
+// ExportParquet writes the snapshot without holding it.
+type Server struct {
+	UnimplementedIdentityMatchingServer
+
+	options Options
+	lock    sync.RWMutex
+	// matchLock serializes Match without blocking the other methods.
+	matchLock  sync.Mutex
+	signatures []idmatch.Signature
+	people     *idmatch.ConcurrentPeople
+	nameFreqs  map[string]*idmatch.Frequency
+	emailFreqs map[string]*idmatch.Frequency
+	// original are the people before the reduction which SplitPerson restores.
+	original idmatch.People
+	// members map the IDs of the matched people to the IDs of the original people in them.
+	members map[int64][]int64
+	// graph is the identity graph of the last Match which lists the review candidates.
+	graph     *idmatch.IdentityGraph
+	decisions idmatch.ReviewDecisions
+}
+
+// NewServer creates the Server without any signatures.
+func NewServer(options Options) *Server {
+	decisions := idmatch.ReviewDecisions{}
+	for pair, verdict := range options.Reduce.Decisions {
+		decisions[pair] = verdict
+	}
+	return &Server{
+		options:   options,
+		people:    idmatch.NewConcurrentPeople(nil),
+		members:   map[int64][]int64{},
+		decisions: decisions,
+	}
+}
+
+// SubmitSignatures adds the streamed signatures to the pending dataset.
+func (s *Server) SubmitSignatures(stream IdentityMatching_SubmitSignaturesServer) error {
+	var received []idmatch.Signature
+	for {
+		message, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		signature, err := signatureFromMessage(message)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "signature %d: %v", len(received)+1, err)
+		}
+		received = append(received, signature)
+	}
+	return stream.SendAndClose(&SubmitSignaturesResponse{
+		Received: int64(len(received)),
+		Total:    int64(s.submit(received)),
+	})
+}
+
+// submit adds the signatures to the pending dataset and returns the size of the dataset.
+func (s *Server) submit(signatures []idmatch.Signature) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.signatures = append(s.signatures, signatures...)
+	reporter.Increment("service signatures received")
+	return len(s.signatures)
+}
+
+// Match matches all the submitted signatures into the people, replacing the previous result
+// together with all the manual merges and splits.
+func (s *Server) Match(ctx context.Context, request *MatchRequest) (*MatchResponse, error) {
+	s.matchLock.Lock()
+	defer s.matchLock.Unlock()
+	s.lock.RLock()
+	// PeopleFromSignatures may change the signatures
+	signatures := append([]idmatch.Signature(nil), s.signatures...)
+	decisions := make(idmatch.ReviewDecisions, len(s.decisions))
+	for pair, verdict := range s.decisions {
+		decisions[pair] = verdict
+	}
+	s.lock.RUnlock()
+	people, nameFreqs, emailFreqs, err := idmatch.PeopleFromSignatures(ctx, signatures,
+		s.options.Extraction, s.options.Blacklist, s.options.Popularity, s.options.Bots,
+		s.options.RecentMonths, s.options.Reduce.Progress)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err), "failed to find the people: %v", err)
+	}
+	original := make(idmatch.People, len(people))
+	for id, person := range people {
+		original[id] = person.Copy()
+	}
+	blacklist := s.options.Blacklist.WithPopular(nameFreqs, emailFreqs, s.options.Popularity)
+	reduceOpts := s.options.Reduce
+	reduceOpts.Decisions = decisions
+	graph, err := idmatch.BuildIdentityGraph(ctx, people, s.options.Matcher, blacklist, reduceOpts)
+	if err != nil {
+		return nil, status.Errorf(errorCode(err), "failed to reduce the people: %v", err)
+	}
+	components := graph.Components()
+	if err := graph.Reduce(ctx, people); err != nil {
+		return nil, status.Errorf(errorCode(err), "failed to reduce the people: %v", err)
+	}
+	members := make(map[int64][]int64, len(components))
+	for _, component := range components {
+		members[component[0]] = component
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.people.Store(people)
+	s.original, s.members, s.graph = original, members, graph
+	s.nameFreqs, s.emailFreqs = nameFreqs, emailFreqs
+	return &MatchResponse{People: int64(len(people))}, nil
+}
+
+// GetPerson returns the matched person by ID.
+func (s *Server) GetPerson(ctx context.Context, request *GetPersonRequest) (*Person, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	person, exists := s.people.Snapshot()[request.Id]
+	if !exists {
+		return nil, status.Errorf(codes.NotFound, "person %d does not exist", request.Id)
+	}
+	return s.personMessage(person), nil
+}
+
+// MergePeople merges the matched people into the one with the smallest ID.
+func (s *Server) MergePeople(ctx context.Context, request *MergePeopleRequest) (*Person, error) {
+	if len(request.Ids) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no people to merge")
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	ids := append([]int64(nil), request.Ids...)
+	idmatch.Int64Slice(ids).Sort()
+	var root int64
+	err := s.people.Update(func(people idmatch.People) error {
+		for _, id := range ids {
+			if _, exists := people[id]; !exists {
+				return status.Errorf(codes.NotFound, "person %d does not exist", id)
+			}
+		}
+		var err error
+		if root, err = people.Merge(ids...); err != nil {
+			return status.Errorf(codes.FailedPrecondition, "failed to merge: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if id != root {
+			s.members[root] = append(s.members[root], s.members[id]...)
+			delete(s.members, id)
+		}
+	}
+	idmatch.Int64Slice(s.members[root]).Sort()
+	return s.personMessage(s.people.Snapshot()[root]), nil
+}
+
+// SplitPerson splits the matched person back into the people found in the signatures before
+// the matching.
+func (s *Server) SplitPerson(ctx context.Context, request *SplitPersonRequest) (
+	*SplitPersonResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	err := s.people.Update(func(people idmatch.People) error {
+		if _, exists := people[request.Id]; !exists {
+			return status.Errorf(codes.NotFound, "person %d does not exist", request.Id)
+		}
+		for _, id := range s.members[request.Id] {
+			people[id] = s.original[id].Copy()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	people := s.people.Snapshot()
+	response := &SplitPersonResponse{}
+	for _, id := range s.members[request.Id] {
+		s.members[id] = []int64{id}
+		response.People = append(response.People, s.personMessage(people[id]))
+	}
+	return response, nil
+}
+
+// ExportParquet sets the primary names and emails of the matched people and writes them to
+// the parquet files in Options.ExportDir, see idmatch.People.WriteToParquet.
+func (s *Server) ExportParquet(ctx context.Context, request *ExportParquetRequest) (
+	*ExportParquetResponse, error) {
+	path, err := s.exportPath(request.Path)
+	if err != nil {
+		return nil, err
+	}
+	s.lock.Lock()
+	err = s.people.Update(func(people idmatch.People) error {
+		idmatch.SetPrimaryValues(people, s.nameFreqs, s.emailFreqs, s.options.Primary)
+		if s.options.Matcher != nil {
+			idmatch.SetPreferredEmails(people, s.options.Matcher, s.options.ExternalIDProvider)
+		}
+		return nil
+	})
+	people := s.people.Snapshot()
+	s.lock.Unlock()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to set the primary values: %v", err)
+	}
+	// the snapshot is immutable, so the other methods proceed while it is written
+	if err := people.WriteToParquet(path, s.options.ExternalIDProvider); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to write the parquet files: %v", err)
+	}
+	return &ExportParquetResponse{People: int64(len(people))}, nil
+}
+
+// exportPath resolves the path of ExportParquetRequest in Options.ExportDir. The clients may
+// not write anywhere else on the server, so the absolute paths and those which climb out of
+// the directory with ".." are refused.
+func (s *Server) exportPath(path string) (string, error) {
+	if s.options.ExportDir == "" {
+		return "", status.Error(codes.FailedPrecondition, "the export directory is not configured")
+	}
+	if path == "" {
+		return "", status.Error(codes.InvalidArgument, "the path is empty")
+	}
+	cleaned := filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(cleaned) || cleaned == "." || cleaned == ".." ||
+		strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", status.Errorf(codes.InvalidArgument,
+			"the path %s is outside of the export directory", path)
+	}
+	return filepath.Join(s.options.ExportDir, cleaned), nil
+}
+
+// signatureFromMessage converts the received signature.
+func signatureFromMessage(message *Signature) (idmatch.Signature, error) {
+	signature := idmatch.Signature{
+		Repo:       message.Repo,
+		Name:       message.Name,
+		Email:      message.Email,
+		Hash:       message.Hash,
+		Source:     idmatch.SourceKind(message.Source),
+		Role:       idmatch.SignatureRole(message.Role),
+		SigningKey: message.SigningKey,
+	}
+	if message.Time != "" {
+		var err error
+		if signature.Time, err = time.Parse(time.RFC3339, message.Time); err != nil {
+			return idmatch.Signature{}, fmt.Errorf("%w: invalid time: %v", idmatch.ErrInvalidSignature, err)
+		}
+	}
+	return signature, nil
+}
+
+// errorCode returns the gRPC code of the matching error according to its kind.
+func errorCode(err error) codes.Code {
+	switch {
+	case errors.Is(err, idmatch.ErrExternalIDConflict):
+		return codes.FailedPrecondition
+	case errors.Is(err, idmatch.ErrInvalidSignature):
+		return codes.InvalidArgument
+	case errors.Is(err, idmatch.ErrSourceUnavailable):
+		return codes.Unavailable
+	}
+	return codes.Internal
+}
+
+// personMessage converts the matched person to send it.
+func (s *Server) personMessage(person *idmatch.Person) *Person {
+	_, externalID := person.ExternalIDs.Primary(s.options.ExternalIDProvider)
+	message := &Person{
+		Id:            person.ID,
+		Emails:        append([]string(nil), person.Emails...),
+		PrimaryName:   person.PrimaryName,
+		PrimaryEmail:  person.PrimaryEmail,
+		ExternalId:    externalID,
+		IsBot:         person.IsBot,
+		Signatures:    append([]int64(nil), s.members[person.ID]...),
+		Commits:       int64(person.Commits),
+		RecentCommits: int64(person.RecentCommits),
+		Repositories:  int64(len(person.Repositories)),
+	}
+	if !person.FirstCommit.IsZero() {
+		message.FirstCommit = person.FirstCommit.Format(time.RFC3339)
+		message.LastCommit = person.LastCommit.Format(time.RFC3339)
+	}
+	sort.Strings(message.Emails)
+	for _, name := range person.NamesWithRepos {
+		message.Names = append(message.Names, &NameWithRepo{Name: name.Name, Repo: name.Repo})
+	}
+	sort.Slice(message.Names, func(i, j int) bool {
+		if message.Names[i].Name != message.Names[j].Name {
+			return message.Names[i].Name < message.Names[j].Name
+		}
+		return message.Names[i].Repo < message.Names[j].Repo
+	})
+	return message
+}
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T) IdentityMatchingClient {
+	return newTestExportClient(t, "")
+}
+
+// newTestExportClient is newTestClient which exports the parquet files to the directory.
+func newTestExportClient(t *testing.T, exportDir string) IdentityMatchingClient {
+	blacklist, err := idmatch.NewBlacklist()
+	require.NoError(t, err)
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	RegisterIdentityMatchingServer(server, NewServer(Options{
+		Blacklist:    blacklist,
+		RecentMonths: 12,
+		Primary:      idmatch.PrimaryOptions{MinRecentCount: 5},
+		Reduce:       idmatch.ReduceOptions{MaxIdentities: 20},
+		ExportDir:    exportDir,
+	}))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.Dial()
+		}), grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewIdentityMatchingClient(conn)
+}
+
+func submitTestSignatures(t *testing.T, client IdentityMatchingClient) {
+	stream, err := client.SubmitSignatures(context.Background())
+	require.NoError(t, err)
+	for _, signature := range []*Signature{
+		{Repo: "repo1", Name: "Alice Smith", Email: "alice@alicesmith.dev", Time: "2019-01-01T10:00:00Z"},
+		{Repo: "repo2", Name: "Alice Smith", Email: "alice.smith@example-home.org", Time: "2019-02-01T10:00:00Z"},
+		{Repo: "repo1", Name: "Bob Jones", Email: "bob@bobjones.dev", Time: "2019-03-01T10:00:00Z"},
+	} {
+		require.NoError(t, stream.Send(signature))
+	}
+	response, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	require.Equal(t, int64(3), response.Received)
+	require.Equal(t, int64(3), response.Total)
+}
+
+func findTestPerson(t *testing.T, client IdentityMatchingClient, email string) *Person {
+	for id := int64(1); id <= 3; id++ {
+		person, err := client.GetPerson(context.Background(), &GetPersonRequest{Id: id})
+		if err != nil {
+			continue
+		}
+		for _, personEmail := range person.Emails {
+			if personEmail == email {
+				return person
+			}
+		}
+	}
+	t.Fatalf("no person with %s", email)
+	return nil
+}
+
+func TestServerMatch(t *testing.T) {
+	req := require.New(t)
+	client := newTestClient(t)
+	ctx := context.Background()
+	submitTestSignatures(t, client)
+	response, err := client.Match(ctx, &MatchRequest{})
+	req.NoError(err)
+	req.Equal(int64(2), response.People)
+
+	alice := findTestPerson(t, client, "alice@alicesmith.dev")
+	req.Equal([]string{"alice.smith@example-home.org", "alice@alicesmith.dev"}, alice.Emails)
+	req.Len(alice.Names, 1)
+	req.Equal("alice smith", alice.Names[0].Name)
+	req.Len(alice.Signatures, 2)
+	req.Equal("2019-01-01T10:00:00Z", alice.FirstCommit)
+	req.Equal("2019-02-01T10:00:00Z", alice.LastCommit)
+	req.Equal(int64(2), alice.Commits)
+	req.Equal(int64(0), alice.RecentCommits)
+	req.Equal(int64(2), alice.Repositories)
+	bob := findTestPerson(t, client, "bob@bobjones.dev")
+	req.Len(bob.Signatures, 1)
+
+	_, err = client.GetPerson(ctx, &GetPersonRequest{Id: 100})
+	req.Equal(codes.NotFound, status.Code(err))
+}
+
+func TestServerConcurrentMatch(t *testing.T) {
+	req := require.New(t)
+	blacklist, err := idmatch.NewBlacklist()
+	req.NoError(err)
+	server := NewServer(Options{Blacklist: blacklist, RecentMonths: 12,
+		Reduce: idmatch.ReduceOptions{MaxIdentities: 20}})
+	server.submit([]idmatch.Signature{
+		{Repo: "repo1", Name: "Alice Smith", Email: "alice@alicesmith.dev"},
+		{Repo: "repo2", Name: "Alice Smith", Email: "alice.smith@example-home.org"},
+	})
+	ctx := context.Background()
+	_, err = server.Match(ctx, &MatchRequest{})
+	req.NoError(err)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			server.submit([]idmatch.Signature{{Repo: "repo1", Name: "Bob Jones", Email: "bob@bobjones.dev"}})
+			_, err := server.Match(ctx, &MatchRequest{})
+			require.NoError(t, err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			people := server.listPeople()
+			require.NotEmpty(t, people)
+			_, err := server.GetPerson(ctx, &GetPersonRequest{Id: people[0].Id})
+			require.NoError(t, err)
+			server.stats()
+		}
+	}()
+	wg.Wait()
+	req.Equal(2, server.stats().People)
+}
+
+func TestServerPeopleSnapshots(t *testing.T) {
+	req := require.New(t)
+	blacklist, err := idmatch.NewBlacklist()
+	req.NoError(err)
+	server := NewServer(Options{Blacklist: blacklist, RecentMonths: 12,
+		Reduce: idmatch.ReduceOptions{MaxIdentities: 20}})
+	server.submit([]idmatch.Signature{
+		{Repo: "repo1", Name: "Alice Smith", Email: "alice@alicesmith.dev"},
+		{Repo: "repo1", Name: "Bob Jones", Email: "bob@bobjones.dev"},
+	})
+	ctx := context.Background()
+	_, err = server.Match(ctx, &MatchRequest{})
+	req.NoError(err)
+	before := server.people.Snapshot()
+	req.Len(before, 2)
+	merged, err := server.MergePeople(ctx, &MergePeopleRequest{Ids: []int64{1, 2}})
+	req.NoError(err)
+	req.Len(merged.Emails, 2)
+	// the readers of the previous snapshot never see the merge
+	req.Len(before, 2)
+	req.Len(before[1].Emails, 1)
+	req.Len(server.people.Snapshot(), 1)
+
+	_, err = server.SplitPerson(ctx, &SplitPersonRequest{Id: 1})
+	req.NoError(err)
+	req.Len(server.people.Snapshot(), 2)
+	_, err = server.MergePeople(ctx, &MergePeopleRequest{Ids: []int64{1, 3}})
+	req.Equal(codes.NotFound, status.Code(err))
+	req.Len(server.people.Snapshot(), 2)
+}
+
+func TestServerSplitMerge(t *testing.T) {
+	req := require.New(t)
+	client := newTestClient(t)
+	ctx := context.Background()
+	submitTestSignatures(t, client)
+	_, err := client.Match(ctx, &MatchRequest{})
+	req.NoError(err)
+	alice := findTestPerson(t, client, "alice@alicesmith.dev")
+	bob := findTestPerson(t, client, "bob@bobjones.dev")
+
+	split, err := client.SplitPerson(ctx, &SplitPersonRequest{Id: alice.Id})
+	req.NoError(err)
+	req.Len(split.People, 2)
+	for i, person := range split.People {
+		req.Equal(alice.Signatures[i], person.Id)
+		req.Len(person.Emails, 1)
+	}
+
+	merged, err := client.MergePeople(ctx, &MergePeopleRequest{
+		Ids: []int64{split.People[1].Id, bob.Id, split.People[0].Id}})
+	req.NoError(err)
+	req.Len(merged.Emails, 3)
+	req.Len(merged.Names, 2)
+	req.Equal([]int64{1, 2, 3}, merged.Signatures)
+	req.Equal(int64(1), merged.Id)
+
+	_, err = client.MergePeople(ctx, &MergePeopleRequest{Ids: []int64{1, 100}})
+	req.Equal(codes.NotFound, status.Code(err))
+	_, err = client.MergePeople(ctx, &MergePeopleRequest{})
+	req.Equal(codes.InvalidArgument, status.Code(err))
+
+	split, err = client.SplitPerson(ctx, &SplitPersonRequest{Id: 1})
+	req.NoError(err)
+	req.Len(split.People, 3)
+}
+
+func TestServerExportParquet(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-service")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	exportDir := filepath.Join(dir, "exports")
+	req.NoError(os.Mkdir(exportDir, 0777))
+	client := newTestExportClient(t, exportDir)
+	ctx := context.Background()
+	submitTestSignatures(t, client)
+	_, err = client.Match(ctx, &MatchRequest{})
+	req.NoError(err)
+	response, err := client.ExportParquet(ctx, &ExportParquetRequest{Path: "people"})
+	req.NoError(err)
+	req.Equal(int64(2), response.People)
+	for _, suffix := range []string{"-aliases.parquet", "-identities.parquet"} {
+		_, err := os.Stat(filepath.Join(exportDir, "people"+suffix))
+		req.NoError(err)
+	}
+	for _, path := range []string{"", ".", "../people", "nested/../../people", filepath.Join(dir, "people")} {
+		_, err = client.ExportParquet(ctx, &ExportParquetRequest{Path: path})
+		req.Equal(codes.InvalidArgument, status.Code(err), path)
+	}
+	entries, err := ioutil.ReadDir(dir)
+	req.NoError(err)
+	req.Len(entries, 1)
+
+	_, err = newTestClient(t).ExportParquet(ctx, &ExportParquetRequest{Path: "people"})
+	req.Equal(codes.FailedPrecondition, status.Code(err))
+}
+
+func TestSignatureFromMessage(t *testing.T) {
+	req := require.New(t)
+	signature, err := signatureFromMessage(&Signature{
+		Repo: "repo", Name: "name", Email: "email", Time: "2019-01-01T10:00:00+02:00",
+		Source: "git", Role: "author", SigningKey: "key"})
+	req.NoError(err)
+	req.Equal("repo", signature.Repo)
+	req.Equal(idmatch.SourceKind("git"), signature.Source)
+	req.Equal(idmatch.SignatureRole("author"), signature.Role)
+	req.Equal("key", signature.SigningKey)
+	req.Equal(int64(1546329600), signature.Time.Unix())
+	_, err = signatureFromMessage(&Signature{Time: "yesterday"})
+	req.ErrorIs(err, idmatch.ErrInvalidSignature)
+}
+
+func TestErrorCode(t *testing.T) {
+	req := require.New(t)
+	req.Equal(codes.FailedPrecondition, errorCode(fmt.Errorf("seed 1: %w", idmatch.ErrExternalIDConflict)))
+	req.Equal(codes.InvalidArgument, errorCode(idmatch.ErrInvalidSignature))
+	req.Equal(codes.Unavailable, errorCode(idmatch.ErrSourceUnavailable))
+	req.Equal(codes.Internal, errorCode(errors.New("failure")))
+}
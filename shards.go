@@ -0,0 +1,263 @@
+package idmatch
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// emailMatchKey is the canonical email by which the identities are matched together with
+// the policy of its domain.
+type emailMatchKey struct {
+	email  string
+	policy DomainPolicy
+}
+
+// personKeys are the unpopular email and name keys of a person.
+type personKeys struct {
+	emails []emailMatchKey
+	names  []string
+}
+
+// partitionKey returns the cheap shard key of the person: the domain of the first email
+// and the first letter of the first name.
+func partitionKey(person *Person) string {
+	var domain, letter string
+	if len(person.Emails) > 0 {
+		email := person.Emails[0]
+		domain = email[strings.LastIndex(email, "@")+1:]
+	}
+	if len(person.NamesWithRepos) > 0 {
+		if r, size := utf8.DecodeRuneInString(person.NamesWithRepos[0].Name); size > 0 {
+			letter = string(r)
+		}
+	}
+	return domain + "/" + letter
+}
+
+// shardPeople splits the person IDs by partitionKey.
+func shardPeople(people People) [][]int64 {
+	shards := map[string][]int64{}
+	for id, person := range people {
+		key := partitionKey(person)
+		shards[key] = append(shards[key], id)
+	}
+	result := make([][]int64, 0, len(shards))
+	for _, shard := range shards {
+		result = append(result, shard)
+	}
+	return result
+}
+
+// matchingKeys calculates the keys of every person. The people are sharded by partitionKey and
+// the shards are processed concurrently by the given number of workers. compute must be safe
+// for concurrent use.
+func matchingKeys(prog *progress, people People, workers int, compute func(*Person) personKeys) (
+	map[int64]personKeys, error) {
+	keys := make(map[int64]personKeys, len(people))
+	stage := prog.stage("computing matching keys", len(people))
+	defer stage.done()
+	var lock sync.Mutex
+	err := forEachShard(prog, shardPeople(people), workers, func(shard []int64) {
+		shardKeys := make([]personKeys, len(shard))
+		for i, id := range shard {
+			shardKeys[i] = compute(people[id])
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		for i, id := range shard {
+			keys[id] = shardKeys[i]
+			// the cancellation is checked by forEachShard
+			_ = stage.tick()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// emailOccurrence is the email key of a person: position is the index of the person in
+// the sorted IDs and index is the index of the key in personKeys.emails.
+type emailOccurrence struct {
+	id       int64
+	position int
+	index    int
+	policy   DomainPolicy
+}
+
+// emailEdge is the email evidence between the person with an earlier occurrence of the key and
+// the person with a later one. The edges sorted by order are the same as the sequential
+// matching by email would add: by the position of the later person, by the index of the key
+// and by the index of the earlier occurrence.
+type emailEdge struct {
+	from, to int64
+	key      string
+	order    [3]int
+}
+
+// emailKeyEdges are the email evidence of a single key and the number of the freemail pairs
+// which were skipped because the names differ.
+type emailKeyEdges struct {
+	occurrences []emailOccurrence
+	edges       []emailEdge
+	skipped     int
+}
+
+// matchEmailShards finds the email evidence between the people, see BuildIdentityGraph.
+// The shards of shardPeople are matched concurrently by the given number of workers: each
+// shard groups its email keys and connects the people of every key. The keys which occur in
+// several shards are reconciled afterwards: their occurrences are joined and their edges are
+// found again. The returned edges are sorted by emailEdge.order, so adding them one by one
+// gives the same identity graph as the sequential matching no matter how many workers there are.
+// The second value is the number of the distinct email keys.
+func matchEmailShards(prog *progress, people People, ids []int64, keys map[int64]personKeys,
+	workers int) ([]emailEdge, int, error) {
+	positions := make(map[int64]int, len(ids))
+	for position, id := range ids {
+		positions[id] = position
+	}
+	stage := prog.stage("matching by email", len(people))
+	defer stage.done()
+	var lock sync.Mutex
+	var shardKeys []map[string]*emailKeyEdges
+	err := forEachShard(prog, shardPeople(people), workers, func(shard []int64) {
+		byKey := map[string]*emailKeyEdges{}
+		for _, id := range shard {
+			for index, key := range keys[id].emails {
+				group := byKey[key.email]
+				if group == nil {
+					group = &emailKeyEdges{}
+					byKey[key.email] = group
+				}
+				group.occurrences = append(group.occurrences,
+					emailOccurrence{id: id, position: positions[id], index: index, policy: key.policy})
+			}
+		}
+		for key, group := range byKey {
+			group.match(people, key)
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		shardKeys = append(shardKeys, byKey)
+		for range shard {
+			// the cancellation is checked by forEachShard
+			_ = stage.tick()
+		}
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	// reconcile the keys which the shards share
+	merged := map[string]*emailKeyEdges{}
+	boundary := map[string]struct{}{}
+	for _, byKey := range shardKeys {
+		for key, group := range byKey {
+			if other, exists := merged[key]; exists {
+				other.occurrences = append(other.occurrences, group.occurrences...)
+				boundary[key] = struct{}{}
+				continue
+			}
+			merged[key] = group
+		}
+	}
+	for key := range boundary {
+		merged[key].match(people, key)
+	}
+	reporter.Commit("email keys across shards", len(boundary))
+	var edges []emailEdge
+	for _, group := range merged {
+		edges = append(edges, group.edges...)
+		for i := 0; i < group.skipped; i++ {
+			reporter.Increment("freemail emails with different names")
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		for k := range edges[i].order {
+			if edges[i].order[k] != edges[j].order[k] {
+				return edges[i].order[k] < edges[j].order[k]
+			}
+		}
+		return false
+	})
+	return edges, len(merged), nil
+}
+
+// match finds the edges of the key from scratch. Each occurrence is connected to the earlier
+// ones: to all of them with the shared name tokens if the domain is a freemail, otherwise to
+// the first which is not only a trailer, or to the first at all.
+func (group *emailKeyEdges) match(people People, key string) {
+	occurrences := group.occurrences
+	sort.Slice(occurrences, func(i, j int) bool {
+		if occurrences[i].position != occurrences[j].position {
+			return occurrences[i].position < occurrences[j].position
+		}
+		return occurrences[i].index < occurrences[j].index
+	})
+	group.edges, group.skipped = nil, 0
+	for i, occurrence := range occurrences {
+		earlier := occurrences[:i]
+		if len(earlier) == 0 {
+			continue
+		}
+		order := [3]int{occurrence.position, occurrence.index, 0}
+		if occurrence.policy == DomainPolicyFreemail {
+			for j, other := range earlier {
+				if !shareNameTokens(people[other.id], people[occurrence.id]) {
+					group.skipped++
+					continue
+				}
+				order[2] = j
+				group.edges = append(group.edges, emailEdge{other.id, occurrence.id, key, order})
+			}
+			continue
+		}
+		// prefer the strong evidence with the identities which are not only trailers
+		anchor := earlier[0]
+		for _, other := range earlier {
+			if people[other.id].TrailerRole == "" {
+				anchor = other
+				break
+			}
+		}
+		group.edges = append(group.edges, emailEdge{anchor.id, occurrence.id, key, order})
+	}
+}
+
+// forEachShard calls fn with every shard in the given number of goroutines, or in the calling
+// goroutine if workers is 0 or 1. It stops early and returns the error if the pipeline is
+// cancelled.
+func forEachShard(prog *progress, shards [][]int64, workers int, fn func(shard []int64)) error {
+	if workers <= 1 {
+		for _, shard := range shards {
+			if err := prog.err(); err != nil {
+				return err
+			}
+			fn(shard)
+		}
+		return prog.err()
+	}
+	queue := make(chan []int64)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for shard := range queue {
+				fn(shard)
+			}
+		}()
+	}
+	for _, shard := range shards {
+		if prog.err() != nil {
+			break
+		}
+		queue <- shard
+	}
+	close(queue)
+	wg.Wait()
+	return prog.err()
+}
@@ -0,0 +1,114 @@
+package idmatch
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionKey(t *testing.T) {
+	req := require.New(t)
+	req.Equal("google.com/b", partitionKey(&Person{
+		NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}}))
+	req.Equal("mail.ru/и", partitionKey(&Person{
+		NamesWithRepos: []NameWithRepo{{"иван", ""}}, Emails: []string{"ivan@mail.ru"}}))
+	req.Equal("/", partitionKey(&Person{}))
+}
+
+func TestShardPeople(t *testing.T) {
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bobby", ""}}, Emails: []string{"bobby@google.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@gmail.com"}},
+	}
+	shards := shardPeople(people)
+	for _, shard := range shards {
+		Int64Slice(shard).Sort()
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i][0] < shards[j][0] })
+	require.Equal(t, [][]int64{{1, 2}, {3}}, shards)
+}
+
+func TestMatchingKeysWorkers(t *testing.T) {
+	people, err := newPeople(nil, Signatures, newTestBlacklist(t))
+	require.NoError(t, err)
+	compute := func(person *Person) personKeys {
+		return personKeys{names: []string{person.NamesWithRepos[0].String()}}
+	}
+	sequential, err := matchingKeys(nil, people, 1, compute)
+	require.NoError(t, err)
+	parallel, err := matchingKeys(nil, people, 4, compute)
+	require.NoError(t, err)
+	require.Equal(t, sequential, parallel)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = matchingKeys(&progress{ctx, nil}, people, 4, compute)
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestReducePeopleWorkers(t *testing.T) {
+	newTestPeople := func() People {
+		people, err := newPeople(nil, Signatures, newTestBlacklist(t))
+		require.NoError(t, err)
+		return people
+	}
+	opts := ReduceOptions{
+		MaxIdentities:       100,
+		MatchReorderedNames: true,
+		EmailAliases:        NewEmailAliasRules(),
+		DomainPolicies:      NewDomainPolicies(),
+	}
+	expected := newTestPeople()
+	require.NoError(t, ReducePeople(context.Background(), expected, nil, newTestBlacklist(t), opts))
+	opts.Workers = 4
+	people := newTestPeople()
+	require.NoError(t, ReducePeople(context.Background(), people, nil, newTestBlacklist(t), opts))
+	require.Equal(t, expected, people)
+}
+
+func TestMatchEmailShards(t *testing.T) {
+	req := require.New(t)
+	people := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"robert", ""}},
+			Emails: []string{"robert@google.com", "bob@google.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"bob smith", ""}}, Emails: []string{"bob@gmail.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"bob@gmail.com"}},
+		5: {ID: 5, NamesWithRepos: []NameWithRepo{{"bob jones", ""}}, Emails: []string{"bob@gmail.com"}},
+		6: {ID: 6, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"},
+			TrailerRole: "co-author"},
+	}
+	// both keys occur in two shards: {1, 6} and {2}, {3, 5} and {4}
+	req.Len(shardPeople(people), 4)
+	keys := map[int64]personKeys{}
+	for id, person := range people {
+		for _, email := range person.Emails {
+			policy := DomainPolicyCorporate
+			if strings.HasSuffix(email, "@gmail.com") {
+				policy = DomainPolicyFreemail
+			}
+			keys[id] = personKeys{emails: append(keys[id].emails, emailMatchKey{email, policy})}
+		}
+	}
+	ids := peopleIDs(people)
+	expected := []emailEdge{
+		{1, 2, "bob@google.com", [3]int{1, 1, 0}},
+		{3, 5, "bob@gmail.com", [3]int{4, 0, 0}},
+		{1, 6, "bob@google.com", [3]int{5, 0, 0}},
+	}
+	for _, workers := range []int{1, 4} {
+		edges, emailKeys, err := matchEmailShards(nil, people, ids, keys, workers)
+		req.NoError(err)
+		req.Equal(expected, edges)
+		req.Equal(3, emailKeys)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := matchEmailShards(&progress{ctx, nil}, people, ids, keys, 4)
+	req.Equal(context.Canceled, err)
+}
@@ -0,0 +1,156 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// SharedMailbox is the email which many different people commit with, e.g. dev@company.com.
+type SharedMailbox struct {
+	Email string
+	// Names are the distinct names of the signatures with the email.
+	Names []string
+	// Groups is the number of the names which do not share a word, see countNameGroups.
+	Groups int
+	// First and Last are the times of the earliest and the latest signatures with the email.
+	First time.Time
+	Last  time.Time
+}
+
+// SharedMailboxDetector finds the team mailboxes among the signatures found by FindPeople, see
+// ExtractionOptions.SharedMailboxes. The same email does not imply the same person for them,
+// so Blacklist.WithSharedMailboxes demotes them from the merge evidence to the attributes of
+// the identities, the same as the noreply emails.
+type SharedMailboxDetector struct {
+	// MinNames is the minimum number of the names which do not share a word, e.g. "alice smith",
+	// "bob" and "eve", of a shared mailbox. "bob" and "bob smith" count once because they are
+	// likely the same person.
+	MinNames int
+
+	mailboxes []SharedMailbox
+}
+
+// NewSharedMailboxDetector returns the detector with the default threshold.
+func NewSharedMailboxDetector() *SharedMailboxDetector {
+	return &SharedMailboxDetector{MinNames: 5}
+}
+
+// detect remembers the emails of the signatures which are used by at least MinNames different
+// names.
+func (d *SharedMailboxDetector) detect(prog *progress, commits []Signature) error {
+	type emailNames struct {
+		names       map[string]struct{}
+		first, last time.Time
+	}
+	emails := map[string]*emailNames{}
+	stage := prog.stage("detecting shared mailboxes", len(commits))
+	defer stage.done()
+	for _, commit := range commits {
+		if err := stage.tick(); err != nil {
+			return err
+		}
+		email, err := cleanEmail(commit.Email)
+		if err != nil {
+			return err
+		}
+		name, err := cleanName(commit.Name)
+		if err != nil {
+			return err
+		}
+		entry := emails[email]
+		if entry == nil {
+			entry = &emailNames{names: map[string]struct{}{}}
+			emails[email] = entry
+		}
+		entry.names[name] = struct{}{}
+		if !commit.Time.IsZero() && (entry.first.IsZero() || commit.Time.Before(entry.first)) {
+			entry.first = commit.Time
+		}
+		if commit.Time.After(entry.last) {
+			entry.last = commit.Time
+		}
+	}
+	d.mailboxes = nil
+	for email, entry := range emails {
+		if len(entry.names) < d.MinNames {
+			continue
+		}
+		names := sortedKeys(entry.names)
+		if groups := countNameGroups(names); groups >= d.MinNames {
+			d.mailboxes = append(d.mailboxes, SharedMailbox{
+				Email: email, Names: names, Groups: groups, First: entry.first, Last: entry.last})
+		}
+	}
+	sort.Slice(d.mailboxes, func(i, j int) bool { return d.mailboxes[i].Email < d.mailboxes[j].Email })
+	reporter.Commit("shared mailboxes", len(d.mailboxes))
+	return nil
+}
+
+// Mailboxes returns the detected shared mailboxes sorted by email.
+func (d *SharedMailboxDetector) Mailboxes() []SharedMailbox {
+	return d.mailboxes
+}
+
+// WithSharedMailboxes returns the copy of the blacklist where the emails of the mailboxes are
+// never the evidence to merge the identities, see isUnmatchableEmail.
+func (b Blacklist) WithSharedMailboxes(mailboxes []SharedMailbox) Blacklist {
+	if len(mailboxes) == 0 {
+		return b
+	}
+	extended := b
+	extended.SharedMailboxes = map[string]struct{}{}
+	for email := range b.SharedMailboxes {
+		extended.SharedMailboxes[email] = struct{}{}
+	}
+	for _, mailbox := range mailboxes {
+		extended.SharedMailboxes[mailbox.Email] = struct{}{}
+	}
+	return extended
+}
+
+// WriteSharedMailboxes saves the mailboxes to the CSV file with the columns "email", "groups",
+// "names", "first" and "last". The names are joined with "; ".
+func WriteSharedMailboxes(path string, mailboxes []SharedMailbox) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write([]string{"email", "groups", "names", "first", "last"}); err != nil {
+		return
+	}
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+	for _, mailbox := range mailboxes {
+		err = writer.Write([]string{mailbox.Email, strconv.Itoa(mailbox.Groups),
+			strings.Join(mailbox.Names, "; "), formatTime(mailbox.First), formatTime(mailbox.Last)})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
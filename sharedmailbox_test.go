@@ -0,0 +1,82 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newSharedMailboxSignatures() []Signature {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	return []Signature{
+		{Repo: "repo1", Email: "team@google.com", Name: "Alice", Time: now.AddDate(0, -3, 0)},
+		{Repo: "repo1", Email: "team@google.com", Name: "Bob Smith", Time: now.AddDate(0, -2, 0)},
+		{Repo: "repo1", Email: "team@google.com", Name: "Bob", Time: now.AddDate(0, -1, 0)},
+		{Repo: "repo2", Email: "team@google.com", Name: "Eve", Time: now},
+		{Repo: "repo1", Email: "alice@google.com", Name: "Alice", Time: now},
+		{Repo: "repo2", Email: "eve@google.com", Name: "Eve", Time: now},
+	}
+}
+
+func TestSharedMailboxDetector(t *testing.T) {
+	req := require.New(t)
+	detector := &SharedMailboxDetector{MinNames: 3}
+	req.NoError(detector.detect(&progress{context.Background(), nil}, newSharedMailboxSignatures()))
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	req.Equal([]SharedMailbox{{Email: "team@google.com", Names: []string{"alice", "bob", "bob smith", "eve"},
+		Groups: 3, First: now.AddDate(0, -3, 0), Last: now}}, detector.Mailboxes())
+
+	detector.MinNames = 4
+	req.NoError(detector.detect(&progress{context.Background(), nil}, newSharedMailboxSignatures()))
+	req.Empty(detector.Mailboxes())
+	req.Equal(5, NewSharedMailboxDetector().MinNames)
+}
+
+func TestSharedMailboxesUnmatchable(t *testing.T) {
+	req := require.New(t)
+	detector := &SharedMailboxDetector{MinNames: 3}
+	people, _, _, err := PeopleFromSignatures(context.Background(), newSharedMailboxSignatures(),
+		ExtractionOptions{SharedMailboxes: detector}, newTestBlacklist(t), PopularityThresholds{},
+		BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	blacklist := newTestBlacklist(t)
+	req.Equal(blacklist, blacklist.WithSharedMailboxes(nil))
+	blacklist = blacklist.WithSharedMailboxes(detector.Mailboxes())
+	req.True(blacklist.isUnmatchableEmail("team@google.com"))
+	req.False(blacklist.isUnmatchableEmail("alice@google.com"))
+	req.True(blacklist.Merge(newTestBlacklist(t)).isUnmatchableEmail("team@google.com"))
+
+	withTeam := 0
+	for _, person := range people {
+		for _, email := range person.Emails {
+			if email == "team@google.com" {
+				withTeam++
+			}
+		}
+	}
+	req.Equal(4, withTeam)
+	g, err := BuildIdentityGraph(context.Background(), people, nil, blacklist, ReduceOptions{MaxIdentities: 20})
+	req.NoError(err)
+	for _, edge := range g.Edges() {
+		for _, evidence := range edge.Evidence {
+			req.NotEqual(Evidence{EvidenceEmail, "team@google.com", evidence.Weight}, evidence)
+		}
+	}
+}
+
+func TestWriteSharedMailboxes(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(WriteSharedMailboxes(f.Name(), []SharedMailbox{
+		{Email: "team@google.com", Names: []string{"alice", "bob"}, Groups: 2,
+			First: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}))
+	data, err := ioutil.ReadFile(f.Name())
+	req.NoError(err)
+	req.Equal("email,groups,names,first,last\n"+
+		"team@google.com,2,alice; bob,2019-01-01T00:00:00Z,\n", string(data))
+}
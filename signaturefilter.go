@@ -0,0 +1,66 @@
+package idmatch
+
+import "github.com/src-d/identity-matching/reporter"
+
+// signatureFilter drops the signatures of the repositories which do not pass
+// ExtractionOptions.Repos and the signatures outside ExtractionOptions.Window while they are read,
+// so that they never take memory.
+type signatureFilter struct {
+	repos  *RepoFilter
+	window TimeWindow
+	// outsideRepos and outsideWindow count the dropped signatures.
+	outsideRepos  int
+	outsideWindow int
+}
+
+// newSignatureFilter returns nil if the options keep all the signatures.
+func newSignatureFilter(opts ExtractionOptions) *signatureFilter {
+	if opts.Repos == nil && opts.Window.IsZero() {
+		return nil
+	}
+	return &signatureFilter{repos: opts.Repos, window: opts.Window}
+}
+
+// keep returns whether the signature passes the filter. The nil filter passes all of them.
+func (f *signatureFilter) keep(sig Signature) bool {
+	if f == nil {
+		return true
+	}
+	if !f.repos.Match(sig.Repo) {
+		f.outsideRepos++
+		return false
+	}
+	if !f.window.Contains(sig.Time) {
+		f.outsideWindow++
+		return false
+	}
+	return true
+}
+
+// report commits the numbers of the dropped signatures.
+func (f *signatureFilter) report() {
+	if f == nil {
+		return
+	}
+	if f.repos != nil {
+		reporter.Commit("signatures of filtered out repositories", f.outsideRepos)
+	}
+	if !f.window.IsZero() {
+		reporter.Commit("signatures outside the time window", f.outsideWindow)
+	}
+}
+
+// apply removes the filtered out signatures from the result of reading them.
+func (f *signatureFilter) apply(commits []Signature, err error) ([]Signature, error) {
+	if f == nil || err != nil {
+		return commits, err
+	}
+	result := commits[:0]
+	for _, commit := range commits {
+		if f.keep(commit) {
+			result = append(result, commit)
+		}
+	}
+	f.report()
+	return result, nil
+}
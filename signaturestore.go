@@ -0,0 +1,342 @@
+package idmatch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// StoreKeyKind is the normalized signature field which SignatureStore groups the signatures by.
+type StoreKeyKind string
+
+const (
+	// StoreKeyEmail groups the signatures by the clean email.
+	StoreKeyEmail StoreKeyKind = "email"
+	// StoreKeyName groups the signatures by the clean name.
+	StoreKeyName StoreKeyKind = "name"
+)
+
+// StoreMember is a signature in the group of SignatureStore.Groups.
+type StoreMember struct {
+	ID   int64
+	Repo string
+}
+
+// SignatureStore keeps the signatures on disk so that the datasets which do not fit in RAM can
+// be matched, see MatchStoredSignatures. The signatures are appended to a CSV file, and their IDs
+// are additionally written to the bucket files by the hash of each key, so that the groups of
+// the signatures with the same key are read one bucket at a time.
+type SignatureStore struct {
+	dir     string
+	data    *os.File
+	writer  *bufio.Writer
+	size    int64
+	offsets []int64
+	buckets map[StoreKeyKind][]*storeBucket
+}
+
+type storeBucket struct {
+	path   string
+	file   *os.File
+	writer *csv.Writer
+}
+
+// NewSignatureStore creates the store in the directory with the given number of buckets for each
+// key kind. More buckets mean less memory to read a bucket and more open files.
+func NewSignatureStore(dir string, buckets int) (*SignatureStore, error) {
+	if buckets < 1 {
+		return nil, fmt.Errorf("the number of buckets must be positive: %d", buckets)
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	data, err := os.Create(filepath.Join(dir, "signatures.csv"))
+	if err != nil {
+		return nil, err
+	}
+	store := &SignatureStore{
+		dir:     dir,
+		data:    data,
+		writer:  bufio.NewWriter(data),
+		buckets: map[StoreKeyKind][]*storeBucket{},
+	}
+	for _, kind := range []StoreKeyKind{StoreKeyEmail, StoreKeyName} {
+		for i := 0; i < buckets; i++ {
+			path := filepath.Join(dir, fmt.Sprintf("%s-%04d.csv", kind, i))
+			file, err := os.Create(path)
+			if err != nil {
+				store.Close()
+				return nil, err
+			}
+			store.buckets[kind] = append(store.buckets[kind],
+				&storeBucket{path: path, file: file, writer: csv.NewWriter(file)})
+		}
+	}
+	return store, nil
+}
+
+// Len returns the number of the stored signatures.
+func (s *SignatureStore) Len() int {
+	return len(s.offsets)
+}
+
+// Add stores the signature and returns its ID. The IDs start from 1 and increase by one.
+func (s *SignatureStore) Add(signature Signature) (int64, error) {
+	name, err := cleanName(signature.Name)
+	if err != nil {
+		return 0, err
+	}
+	email, err := cleanEmail(signature.Email)
+	if err != nil {
+		return 0, err
+	}
+	buffer := &bytes.Buffer{}
+	writer := csv.NewWriter(buffer)
+	if err := writer.Write(signatureRecord(signature)); err != nil {
+		return 0, err
+	}
+	writer.Flush()
+	if _, err := s.writer.Write(buffer.Bytes()); err != nil {
+		return 0, err
+	}
+	s.offsets = append(s.offsets, s.size)
+	s.size += int64(buffer.Len())
+	id := int64(len(s.offsets))
+	for kind, key := range map[StoreKeyKind]string{StoreKeyEmail: email, StoreKeyName: name} {
+		buckets := s.buckets[kind]
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		bucket := buckets[int(hash.Sum32()%uint32(len(buckets)))]
+		err := bucket.writer.Write([]string{key, strconv.FormatInt(id, 10), signature.Repo})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+// flush writes the buffered signatures and keys to the files.
+func (s *SignatureStore) flush() error {
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	for _, buckets := range s.buckets {
+		for _, bucket := range buckets {
+			bucket.writer.Flush()
+			if err := bucket.writer.Error(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Signature reads the stored signature with the ID.
+func (s *SignatureStore) Signature(id int64) (Signature, error) {
+	if id < 1 || id > int64(len(s.offsets)) {
+		return Signature{}, fmt.Errorf("signature %d does not exist", id)
+	}
+	if err := s.flush(); err != nil {
+		return Signature{}, err
+	}
+	end := s.size
+	if id < int64(len(s.offsets)) {
+		end = s.offsets[id]
+	}
+	reader := csv.NewReader(io.NewSectionReader(s.data, s.offsets[id-1], end-s.offsets[id-1]))
+	record, err := reader.Read()
+	if err != nil {
+		return Signature{}, err
+	}
+	signature := Signature{Repo: record[0], Name: record[1], Email: record[2], Hash: record[3],
+		Source: SourceKind(record[5]), Role: SignatureRole(record[6]), SigningKey: record[7]}
+	if signature.Time, err = time.Parse(time.RFC3339, record[4]); err != nil {
+		return Signature{}, err
+	}
+	if signature.Activity, err = parseActivity(record[8]); err != nil {
+		return Signature{}, err
+	}
+	return signature, nil
+}
+
+// Groups reads the buckets of the key kind one by one and calls f for each key with the sorted
+// members which have it. The keys are visited in the increasing order inside each bucket.
+// f may return an error to stop.
+func (s *SignatureStore) Groups(kind StoreKeyKind, f func(key string, members []StoreMember) error) error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+	for _, bucket := range s.buckets[kind] {
+		groups, err := bucket.read()
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(groups))
+		for key := range groups {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := f(key, groups[key]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// read loads the members of the bucket grouped by the key.
+func (b *storeBucket) read() (map[string][]StoreMember, error) {
+	file, err := os.Open(b.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.FieldsPerRecord = 3
+	groups := map[string][]StoreMember{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %s: %v", b.path, err)
+		}
+		id, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket %s: %v", b.path, err)
+		}
+		groups[record[0]] = append(groups[record[0]], StoreMember{id, record[2]})
+	}
+	return groups, nil
+}
+
+// Close closes the files of the store. The files stay in the directory.
+func (s *SignatureStore) Close() error {
+	err := s.flush()
+	if errClose := s.data.Close(); err == nil {
+		err = errClose
+	}
+	for _, buckets := range s.buckets {
+		for _, bucket := range buckets {
+			if errClose := bucket.file.Close(); err == nil {
+				err = errClose
+			}
+		}
+	}
+	return err
+}
+
+// MatchStoredSignatures groups the signatures in the store by the same unpopular email or name,
+// the same way as ReducePeople matches them by default, while only the union-find forest of
+// the IDs is kept in memory. The signatures with the ignored names or emails are skipped.
+// The popular names match only inside the same repository. The result is sorted like
+// disjointSets.groups.
+func MatchStoredSignatures(store *SignatureStore, blacklist Blacklist) ([][]int64, error) {
+	sets := disjointSets{}
+	ignored := map[int64]struct{}{}
+	err := store.Groups(StoreKeyEmail, func(email string, members []StoreMember) error {
+		if blacklist.isIgnoredEmail(email) {
+			for _, member := range members {
+				ignored[member.ID] = struct{}{}
+			}
+			return nil
+		}
+		if blacklist.isPopularEmail(email) {
+			reporter.Increment("popular emails found")
+			return nil
+		}
+		if blacklist.isUnmatchableEmail(email) {
+			reporter.Increment("noreply emails found")
+			return nil
+		}
+		for _, member := range members[1:] {
+			sets.union(members[0].ID, member.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = store.Groups(StoreKeyName, func(name string, members []StoreMember) error {
+		if blacklist.isIgnoredName(name) {
+			for _, member := range members {
+				ignored[member.ID] = struct{}{}
+			}
+			return nil
+		}
+		if !blacklist.isPopularName(name) {
+			for _, member := range members[1:] {
+				sets.union(members[0].ID, member.ID)
+			}
+			return nil
+		}
+		reporter.Increment("popular names found")
+		byRepo := map[string]int64{}
+		for _, member := range members {
+			if first, exists := byRepo[member.Repo]; exists {
+				sets.union(first, member.ID)
+			} else {
+				byRepo[member.Repo] = member.ID
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, store.Len()-len(ignored))
+	for id := int64(1); id <= int64(store.Len()); id++ {
+		if _, exists := ignored[id]; !exists {
+			ids = append(ids, id)
+		}
+	}
+	groups := sets.groups(ids)
+	reporter.Commit("people after reduce", len(groups))
+	return groups, nil
+}
+
+// Person reads the signatures with the IDs from the store and merges them into a single person
+// with the smallest ID, e.g. a group returned by MatchStoredSignatures.
+func (s *SignatureStore) Person(ids []int64, blacklist Blacklist) (*Person, error) {
+	signatures := make([]Signature, 0, len(ids))
+	for _, id := range ids {
+		signature, err := s.Signature(id)
+		if err != nil {
+			return nil, err
+		}
+		signatures = append(signatures, signature)
+	}
+	people, err := newPeople(nil, signatures, blacklist)
+	if err != nil {
+		return nil, err
+	}
+	if len(people) == 0 {
+		return nil, fmt.Errorf("signatures %v are ignored", ids)
+	}
+	merger := NewPeopleMerger(people)
+	for id := range people {
+		merger.Add(id)
+		if err := merger.Union(1, id); err != nil {
+			return nil, err
+		}
+	}
+	groups, err := merger.Apply()
+	if err != nil {
+		return nil, err
+	}
+	person := people[groups[0][0]]
+	person.ID = ids[0]
+	return person, nil
+}
@@ -0,0 +1,78 @@
+package idmatch
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureStore(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-store")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	_, err = NewSignatureStore(dir, 0)
+	req.Error(err)
+	store, err := NewSignatureStore(dir, 3)
+	req.NoError(err)
+	day := time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC)
+	signatures := []Signature{
+		{"repo1", "Bob", "Bob@google.com", "aaa", day, SourceGit, RoleAuthor, "", testActivity(day), nil},
+		{"repo2", "Robert", "bob@google.com", "bbb", day, SourceGit, RoleAuthor, "0123456789abcdef", nil, nil},
+		{"repo1", "Robert", "robert@google.com", "ccc", day, "", "", "", nil, nil},
+		{"repo1", "Alice", "alice@google.com", "ddd", day, "", "", "", nil, nil},
+		{"repo2", "Alice", "alice@yahoo.com", "eee", day, "", "", "", nil, nil},
+		{"repo1", "admin", "admin@google.com", "fff", day, "", "", "", nil, nil},
+	}
+	for i, signature := range signatures {
+		id, err := store.Add(signature)
+		req.NoError(err)
+		req.Equal(int64(i+1), id)
+	}
+	req.Equal(6, store.Len())
+	signature, err := store.Signature(1)
+	req.NoError(err)
+	req.Equal(signatures[0], signature)
+	signature, err = store.Signature(6)
+	req.NoError(err)
+	req.Equal(signatures[5], signature)
+	_, err = store.Signature(7)
+	req.Error(err)
+
+	groups := map[string][]StoreMember{}
+	req.NoError(store.Groups(StoreKeyEmail, func(key string, members []StoreMember) error {
+		groups[key] = members
+		return nil
+	}))
+	req.Len(groups, 5)
+	req.Equal([]StoreMember{{1, "repo1"}, {2, "repo2"}}, groups["bob@google.com"])
+	stop := errors.New("stop")
+	req.Equal(stop, store.Groups(StoreKeyName, func(string, []StoreMember) error { return stop }))
+
+	blacklist := newTestBlacklist(t)
+	matched, err := MatchStoredSignatures(store, blacklist)
+	req.NoError(err)
+	req.Equal([][]int64{{1, 2, 3}, {4, 5}}, matched)
+	blacklist.PopularNames = map[string]struct{}{"alice": {}}
+	matched, err = MatchStoredSignatures(store, blacklist)
+	req.NoError(err)
+	req.Equal([][]int64{{1, 2, 3}, {4}, {5}}, matched)
+
+	person, err := store.Person(matched[0], blacklist)
+	req.NoError(err)
+	req.Equal(int64(1), person.ID)
+	req.Equal([]string{"bob@google.com", "robert@google.com"}, person.Emails)
+	req.Equal([]NameWithRepo{{"bob", ""}, {"robert", ""}}, person.NamesWithRepos)
+	req.Equal([]string{"0123456789abcdef"}, person.SigningKeys)
+	req.Equal([]string{"repo1", "repo2"}, person.Repositories)
+	person, err = store.Person(matched[1], blacklist)
+	req.NoError(err)
+	req.Equal([]NameWithRepo{{"alice", "repo1"}}, person.NamesWithRepos)
+	_, err = store.Person([]int64{6}, blacklist)
+	req.Error(err)
+	req.NoError(store.Close())
+}
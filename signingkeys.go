@@ -0,0 +1,47 @@
+package idmatch
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+)
+
+// pgpKeyID returns the issuer key ID of the ASCII-armored OpenPGP commit signature as 16 lowercase
+// hex digits, that is, the low 64 bits of the signing key fingerprint. The empty string means that
+// the commit is not signed or that the signature is not OpenPGP, e.g. X.509 or SSH.
+func pgpKeyID(armored string) string {
+	if strings.TrimSpace(armored) == "" {
+		return ""
+	}
+	block, err := armor.Decode(strings.NewReader(armored))
+	if err != nil {
+		return ""
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return ""
+	}
+	switch signature := p.(type) {
+	case *packet.Signature:
+		if signature.IssuerKeyId == nil {
+			return ""
+		}
+		return fmt.Sprintf("%016x", *signature.IssuerKeyId)
+	case *packet.SignatureV3:
+		return fmt.Sprintf("%016x", signature.IssuerKeyId)
+	default:
+		return ""
+	}
+}
+
+// commitSigningKeys returns the signing keys of the commit author and of the committer.
+// Git signs the commit on behalf of the committer, so the author shares the key only if
+// the emails are the same.
+func commitSigningKeys(key, authorEmail, committerEmail string) (author, committer string) {
+	if strings.EqualFold(strings.TrimSpace(authorEmail), strings.TrimSpace(committerEmail)) {
+		author = key
+	}
+	return author, key
+}
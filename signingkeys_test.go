@@ -0,0 +1,69 @@
+package idmatch
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+)
+
+func TestPGPKeyID(t *testing.T) {
+	req := require.New(t)
+	entity, err := openpgp.NewEntity("alice", "", "alice@google.com", nil)
+	req.NoError(err)
+	var signature bytes.Buffer
+	req.NoError(openpgp.ArmoredDetachSign(&signature, entity, strings.NewReader("commit"), nil))
+	req.Equal(fmt.Sprintf("%016x", entity.PrimaryKey.KeyId), pgpKeyID(signature.String()))
+	req.Equal("", pgpKeyID(""))
+	req.Equal("", pgpKeyID("-----BEGIN SIGNED MESSAGE-----\nMIIE\n-----END SIGNED MESSAGE-----\n"))
+}
+
+func TestCommitSigningKeys(t *testing.T) {
+	req := require.New(t)
+	author, committer := commitSigningKeys("abc", "Alice@google.com", "alice@google.com")
+	req.Equal("abc", author)
+	req.Equal("abc", committer)
+	author, committer = commitSigningKeys("abc", "alice@google.com", "bot@google.com")
+	req.Equal("", author)
+	req.Equal("abc", committer)
+}
+
+func TestGitSourceSigningKey(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-git")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+
+	entity, err := openpgp.NewEntity("alice", "", "alice@google.com", nil)
+	req.NoError(err)
+	repo, err := git.PlainInit(dir, false)
+	req.NoError(err)
+	worktree, err := repo.Worktree()
+	req.NoError(err)
+	req.NoError(ioutil.WriteFile(filepath.Join(dir, "file"), nil, 0666))
+	_, err = worktree.Add("file")
+	req.NoError(err)
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	alice := object.Signature{Name: "alice", Email: "alice@google.com", When: day}
+	hash, err := worktree.Commit("commit", &git.CommitOptions{Author: &alice, SignKey: entity})
+	req.NoError(err)
+
+	source, err := newGitSource(dir, 1)
+	req.NoError(err)
+	signatures, err := readSignaturesFromGit(nil, source)
+	req.NoError(err)
+	key := fmt.Sprintf("%016x", entity.PrimaryKey.KeyId)
+	req.Equal([]Signature{
+		{dir, "alice", "alice@google.com", hash.String(), day, SourceGit, RoleAuthor, key, testActivity(day), nil},
+		{dir, "alice", "alice@google.com", hash.String(), day, SourceGit, RoleCommitter, key, testActivity(day), nil},
+	}, signatures)
+}
@@ -0,0 +1,190 @@
+// Package snowflake exports the matched people to the Snowflake tables.
+package snowflake
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	// the "snowflake" database/sql driver
+	_ "github.com/snowflakedb/gosnowflake"
+
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// batchSize is the number of the people inserted by a single statement.
+const batchSize = 1000
+
+// stagingSuffix is appended to the name of the table to name the staging table.
+const stagingSuffix = "_idmatch_staging"
+
+// tableRegexp matches the table name which may be qualified with the database and the schema.
+var tableRegexp = regexp.MustCompile(
+	`^[A-Za-z_][A-Za-z0-9_$]*(\.[A-Za-z_][A-Za-z0-9_$]*){0,2}$`)
+
+// columns are the columns of the people table, the same as in idmatch.PeopleAvroSchema.
+// "names" are the objects with "name" and "repo".
+const columns = `id NUMBER(19, 0),
+  names ARRAY,
+  emails ARRAY,
+  primary_name VARCHAR,
+  primary_email VARCHAR,
+  external_id_provider VARCHAR,
+  external_id VARCHAR,
+  is_bot BOOLEAN,
+  first_commit TIMESTAMP_NTZ,
+  last_commit TIMESTAMP_NTZ,
+  commits NUMBER(19, 0),
+  recent_commits NUMBER(19, 0),
+  repositories NUMBER(19, 0)`
+
+// values converts the columns of the inserted rows: the arrays are bound as JSON and the times
+// as milliseconds.
+const values = `column1, PARSE_JSON(column2), PARSE_JSON(column3), column4, column5,
+  column6, column7, column8, TO_TIMESTAMP_NTZ(column9, 3), TO_TIMESTAMP_NTZ(column10, 3),
+  column11, column12, column13`
+
+// statement is the SQL statement with the bound arguments.
+type statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// plan are the statements of Export in the order of execution.
+type plan struct {
+	// Stage creates the table if it does not exist and replaces the staging table.
+	Stage []statement
+	// Inserts fill the staging table with the people sorted by ID.
+	Inserts []statement
+	// Swap exchanges the staging table with the table and drops the old people.
+	Swap []statement
+	// Cleanup drops the staging table after a failed insert.
+	Cleanup statement
+}
+
+// Export creates or replaces the Snowflake table with the people. db is opened with
+// the "snowflake" driver, see github.com/snowflakedb/gosnowflake. Snowflake commits every DDL
+// statement at once, so the people are inserted in batches into the staging table next to
+// the table, which is swapped with the table only after all the inserts succeed. The table is
+// either replaced completely or left as it was.
+func Export(ctx context.Context, db *sql.DB, table string, people idmatch.People,
+	externalIDProvider string) (err error) {
+	p, err := newPlan(table, people, externalIDProvider)
+	if err != nil {
+		return err
+	}
+	for _, s := range p.Stage {
+		if _, err = db.ExecContext(ctx, s.Query); err != nil {
+			return fmt.Errorf("failed to create %s: %v", table, err)
+		}
+	}
+	defer func() {
+		if err != nil {
+			// the original error matters more than the failure to clean up
+			db.ExecContext(context.Background(), p.Cleanup.Query)
+		}
+	}()
+	for _, s := range p.Inserts {
+		if _, err = db.ExecContext(ctx, s.Query, s.Args...); err != nil {
+			return fmt.Errorf("failed to insert into %s: %v", table+stagingSuffix, err)
+		}
+		reporter.Increment("snowflake insert statements")
+	}
+	for _, s := range p.Swap {
+		if _, err = db.ExecContext(ctx, s.Query); err != nil {
+			return fmt.Errorf("failed to replace %s: %v", table, err)
+		}
+	}
+	reporter.Commit("snowflake people", len(people))
+	return nil
+}
+
+// newPlan returns the statements which stage the people and swap them into the table.
+func newPlan(table string, people idmatch.People, externalIDProvider string) (plan, error) {
+	if !tableRegexp.MatchString(table) {
+		return plan{}, fmt.Errorf("invalid Snowflake table name: %s", table)
+	}
+	staging := table + stagingSuffix
+	p := plan{
+		Stage: []statement{
+			{Query: fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n  %s\n)", table, columns)},
+			{Query: fmt.Sprintf("CREATE OR REPLACE TABLE %s (\n  %s\n)", staging, columns)},
+		},
+		Swap: []statement{
+			{Query: fmt.Sprintf("ALTER TABLE %s SWAP WITH %s", staging, table)},
+			{Query: fmt.Sprintf("DROP TABLE %s", staging)},
+		},
+		Cleanup: statement{Query: fmt.Sprintf("DROP TABLE IF EXISTS %s", staging)},
+	}
+	ids := make([]int64, 0, len(people))
+	for id := range people {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		rows := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*13)
+		for _, id := range ids[start:end] {
+			row, err := personRow(people[id], externalIDProvider)
+			if err != nil {
+				return plan{}, err
+			}
+			rows = append(rows, "(?"+strings.Repeat(", ?", len(row)-1)+")")
+			args = append(args, row...)
+		}
+		p.Inserts = append(p.Inserts, statement{
+			Query: fmt.Sprintf("INSERT INTO %s SELECT %s FROM VALUES %s",
+				staging, values, strings.Join(rows, ", ")),
+			Args: args,
+		})
+	}
+	return p, nil
+}
+
+// personRow returns the bound values of the person, see values.
+func personRow(p *idmatch.Person, externalIDProvider string) ([]interface{}, error) {
+	provider, externalID := p.ExternalIDs.Primary(externalIDProvider)
+	type name struct {
+		Name string `json:"name"`
+		Repo string `json:"repo"`
+	}
+	names := make([]name, len(p.NamesWithRepos))
+	for i, nameWithRepo := range p.NamesWithRepos {
+		names[i] = name{nameWithRepo.Name, nameWithRepo.Repo}
+	}
+	namesJSON, err := json.Marshal(names)
+	if err != nil {
+		return nil, err
+	}
+	emails := p.Emails
+	if emails == nil {
+		emails = []string{}
+	}
+	emailsJSON, err := json.Marshal(emails)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		p.ID, string(namesJSON), string(emailsJSON), p.PrimaryName, p.PrimaryEmail, provider,
+		externalID, p.IsBot, millis(p.FirstCommit), millis(p.LastCommit),
+		int64(p.Commits), int64(p.RecentCommits), int64(len(p.Repositories)),
+	}, nil
+}
+
+// millis returns the Unix time in milliseconds, 0 for the zero time.
+func millis(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,181 @@
+package snowflake
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPeople() idmatch.People {
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	return idmatch.People{
+		2: {ID: 2, PrimaryName: "bob", PrimaryEmail: "bob@google.com",
+			ExternalIDs:    idmatch.ExternalIDs{"github": "bob_username"},
+			NamesWithRepos: []idmatch.NameWithRepo{{Name: "bob"}}, Emails: []string{"bob@google.com"},
+			Commits: 3, FirstCommit: day, LastCommit: day.Add(time.Hour),
+			Repositories: []string{"repo1", "repo2"}},
+		1: {ID: 1, PrimaryName: "alice", PrimaryEmail: "alice@google.com",
+			NamesWithRepos: []idmatch.NameWithRepo{{Name: "alice"}, {Name: "al", Repo: "repo1"}},
+			Emails:         []string{"alice@google.com", "al@google.com"}, IsBot: true},
+	}
+}
+
+func TestNewPlan(t *testing.T) {
+	req := require.New(t)
+	p, err := newPlan("analytics.public.people", newTestPeople(), "github")
+	req.NoError(err)
+	req.Len(p.Stage, 2)
+	req.True(strings.HasPrefix(p.Stage[0].Query,
+		"CREATE TABLE IF NOT EXISTS analytics.public.people (\n  id NUMBER(19, 0),\n"))
+	req.True(strings.HasPrefix(p.Stage[1].Query,
+		"CREATE OR REPLACE TABLE analytics.public.people_idmatch_staging (\n  id NUMBER(19, 0),\n"))
+	req.Len(p.Inserts, 1)
+	req.True(strings.HasPrefix(p.Inserts[0].Query,
+		"INSERT INTO analytics.public.people_idmatch_staging SELECT column1, PARSE_JSON(column2)"))
+	req.True(strings.HasSuffix(p.Inserts[0].Query, " FROM VALUES "+
+		"(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"))
+	req.Equal([]interface{}{
+		int64(1), `[{"name":"alice","repo":""},{"name":"al","repo":"repo1"}]`,
+		`["alice@google.com","al@google.com"]`, "alice", "alice@google.com", "", "", true,
+		int64(0), int64(0), int64(0), int64(0), int64(0),
+		int64(2), `[{"name":"bob","repo":""}]`, `["bob@google.com"]`, "bob", "bob@google.com",
+		"github", "bob_username", false, int64(1546300800000), int64(1546304400000),
+		int64(3), int64(0), int64(2),
+	}, p.Inserts[0].Args)
+	req.Equal([]statement{
+		{Query: "ALTER TABLE analytics.public.people_idmatch_staging SWAP WITH analytics.public.people"},
+		{Query: "DROP TABLE analytics.public.people_idmatch_staging"},
+	}, p.Swap)
+	req.Equal("DROP TABLE IF EXISTS analytics.public.people_idmatch_staging", p.Cleanup.Query)
+
+	people := idmatch.People{}
+	for id := int64(1); id <= batchSize+1; id++ {
+		people[id] = &idmatch.Person{ID: id}
+	}
+	p, err = newPlan("people", people, "")
+	req.NoError(err)
+	req.Len(p.Inserts, 2)
+	req.Len(p.Inserts[0].Args, batchSize*13)
+	req.Len(p.Inserts[1].Args, 13)
+	req.Equal("[]", p.Inserts[1].Args[2])
+
+	p, err = newPlan("people", idmatch.People{}, "")
+	req.NoError(err)
+	req.Empty(p.Inserts)
+
+	_, err = newPlan("people; DROP TABLE users", people, "")
+	req.EqualError(err, "invalid Snowflake table name: people; DROP TABLE users")
+	_, err = newPlan("a.b.c.d", people, "")
+	req.Error(err)
+}
+
+// testDriver records the executed statements and fails the one with failQuery in it.
+type testDriver struct {
+	lock      sync.Mutex
+	queries   []string
+	failQuery string
+}
+
+func (d *testDriver) Open(string) (driver.Conn, error) {
+	return testConn{d}, nil
+}
+
+type testConn struct {
+	driver *testDriver
+}
+
+func (c testConn) Prepare(query string) (driver.Stmt, error) {
+	return testStmt{c.driver, query}, nil
+}
+
+func (c testConn) Close() error {
+	return nil
+}
+
+func (c testConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type testStmt struct {
+	driver *testDriver
+	query  string
+}
+
+func (s testStmt) Close() error {
+	return nil
+}
+
+func (s testStmt) NumInput() int {
+	return -1
+}
+
+func (s testStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.driver.lock.Lock()
+	defer s.driver.lock.Unlock()
+	s.driver.queries = append(s.driver.queries, strings.SplitN(s.query, " (", 2)[0])
+	if s.driver.failQuery != "" && strings.Contains(s.query, s.driver.failQuery) {
+		return nil, errors.New("failure")
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s testStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("queries are not supported")
+}
+
+// testDrivers are the drivers which the "snowflake-test" DSNs name.
+var testDrivers = map[string]*testDriver{}
+
+// testDriverSwitch opens the testDriver named by the DSN.
+type testDriverSwitch struct{}
+
+func (testDriverSwitch) Open(name string) (driver.Conn, error) {
+	return testDrivers[name].Open(name)
+}
+
+func init() {
+	sql.Register("snowflake-test", testDriverSwitch{})
+}
+
+func TestExport(t *testing.T) {
+	req := require.New(t)
+	run := func(failQuery string) ([]string, error) {
+		d := &testDriver{failQuery: failQuery}
+		testDrivers[failQuery] = d
+		db, err := sql.Open("snowflake-test", failQuery)
+		req.NoError(err)
+		defer db.Close()
+		err = Export(context.Background(), db, "people", newTestPeople(), "")
+		return d.queries, err
+	}
+
+	queries, err := run("")
+	req.NoError(err)
+	req.Equal([]string{
+		"CREATE TABLE IF NOT EXISTS people",
+		"CREATE OR REPLACE TABLE people_idmatch_staging",
+		"INSERT INTO people_idmatch_staging SELECT column1, PARSE_JSON(column2), PARSE_JSON(column3), " +
+			"column4, column5,\n  column6, column7, column8, TO_TIMESTAMP_NTZ(column9, 3), " +
+			"TO_TIMESTAMP_NTZ(column10, 3),\n  column11, column12, column13 FROM VALUES",
+		"ALTER TABLE people_idmatch_staging SWAP WITH people",
+		"DROP TABLE people_idmatch_staging",
+	}, queries)
+
+	// the table is never touched when an insert fails
+	queries, err = run("INSERT")
+	req.EqualError(err, "failed to insert into people_idmatch_staging: failure")
+	req.Equal([]string{
+		"CREATE TABLE IF NOT EXISTS people",
+		"CREATE OR REPLACE TABLE people_idmatch_staging",
+		queries[2],
+		"DROP TABLE IF EXISTS people_idmatch_staging",
+	}, queries)
+}
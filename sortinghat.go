@@ -0,0 +1,268 @@
+package idmatch
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortingHatProvider is the external id provider of the people imported from SortingHat:
+// the external id is the uuid of the unique identity, which WriteSortingHat keeps.
+const SortingHatProvider = "sortinghat"
+
+// sortingHatSource is the SortingHat source of the identities written by WriteSortingHat.
+const sortingHatSource = "git"
+
+// sortingHatTimeFormat is the format of the enrollment dates in the SortingHat JSON file.
+const sortingHatTimeFormat = "2006-01-02T15:04:05"
+
+// sortingHatFile is the JSON file of "sortinghat export" and "sortinghat load".
+type sortingHatFile struct {
+	Time             string                              `json:"time"`
+	Source           *string                             `json:"source"`
+	Blacklist        []string                            `json:"blacklist"`
+	Organizations    map[string][]sortingHatDomain       `json:"organizations"`
+	UniqueIdentities map[string]sortingHatUniqueIdentity `json:"uidentities"`
+}
+
+type sortingHatDomain struct {
+	Domain string `json:"domain"`
+	IsTop  bool   `json:"is_top"`
+}
+
+type sortingHatUniqueIdentity struct {
+	UUID        string                 `json:"uuid"`
+	Profile     *sortingHatProfile     `json:"profile"`
+	Identities  []sortingHatIdentity   `json:"identities"`
+	Enrollments []sortingHatEnrollment `json:"enrollments"`
+}
+
+type sortingHatProfile struct {
+	UUID      string      `json:"uuid"`
+	Name      *string     `json:"name"`
+	Email     *string     `json:"email"`
+	Gender    *string     `json:"gender"`
+	GenderAcc *int        `json:"gender_acc"`
+	IsBot     bool        `json:"is_bot"`
+	Country   interface{} `json:"country"`
+}
+
+type sortingHatIdentity struct {
+	ID       string  `json:"id"`
+	Name     *string `json:"name"`
+	Email    *string `json:"email"`
+	Username *string `json:"username"`
+	Source   string  `json:"source"`
+	UUID     string  `json:"uuid"`
+}
+
+type sortingHatEnrollment struct {
+	Start        string `json:"start"`
+	End          string `json:"end"`
+	Organization string `json:"organization"`
+	UUID         string `json:"uuid"`
+}
+
+// sortingHatNullable converts the empty string to JSON null.
+func sortingHatNullable(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// sortingHatIdentityID generates the identity id like SortingHat does: the hex SHA1 of
+// the source, the email, the name and the username joined with ":", where "None" stands for
+// the missing values. The names are already free of the accents which SortingHat strips.
+func sortingHatIdentityID(source, email, name, username string) string {
+	values := []string{source, email, name, username}
+	for i, value := range values {
+		if value == "" {
+			values[i] = "None"
+		}
+	}
+	hash := sha1.Sum([]byte(strings.Join(values, ":")))
+	return hex.EncodeToString(hash[:])
+}
+
+// ReadSortingHat loads the unique identities and the organizations from the JSON file of
+// "sortinghat export". Each unique identity becomes a person whose external id is the uuid,
+// see SortingHatProvider, and whose names and emails are normalized the same way as
+// the signatures. The people are numbered in the order of the uuids. The enrollments are
+// ignored.
+func ReadSortingHat(path string) (people People, orgs Organizations, err error) {
+	var file io.ReadCloser
+	file, err = OpenPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	var export sortingHatFile
+	if err = json.NewDecoder(file).Decode(&export); err != nil {
+		return nil, nil, fmt.Errorf("invalid SortingHat file %s: %v", path, err)
+	}
+	orgs = Organizations{}
+	for organization, domains := range export.Organizations {
+		for _, domain := range domains {
+			orgs[strings.ToLower(strings.TrimSpace(domain.Domain))] = organization
+		}
+	}
+	uuids := make([]string, 0, len(export.UniqueIdentities))
+	for uuid := range export.UniqueIdentities {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	people = People{}
+	for index, uuid := range uuids {
+		unique := export.UniqueIdentities[uuid]
+		person := &Person{ID: int64(index + 1), ExternalIDs: ExternalIDs{SortingHatProvider: uuid}}
+		if unique.Profile != nil {
+			person.IsBot = unique.Profile.IsBot
+			if unique.Profile.Name != nil {
+				if person.PrimaryName, err = normalizeSignatureValue(*unique.Profile.Name); err != nil {
+					return nil, nil, err
+				}
+			}
+			if unique.Profile.Email != nil {
+				if person.PrimaryEmail, err = normalizeSignatureValue(*unique.Profile.Email); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		emails, names := map[string]bool{}, map[string]bool{}
+		for _, identity := range unique.Identities {
+			if identity.Email != nil {
+				email, err := normalizeSignatureValue(*identity.Email)
+				if err != nil {
+					return nil, nil, err
+				}
+				if email != "" && !emails[email] {
+					emails[email] = true
+					person.Emails = append(person.Emails, email)
+				}
+			}
+			if identity.Name != nil {
+				name, err := normalizeSignatureValue(*identity.Name)
+				if err != nil {
+					return nil, nil, err
+				}
+				if name != "" && !names[name] {
+					names[name] = true
+					person.NamesWithRepos = append(person.NamesWithRepos, NameWithRepo{Name: name})
+				}
+			}
+		}
+		people[person.ID] = person
+	}
+	return people, orgs, nil
+}
+
+// WriteSortingHat exports the people to the JSON file of "sortinghat load". The uuid of each
+// unique identity is the external id of SortingHatProvider if the person has it, otherwise the id
+// of its first identity. Each email becomes the identity with the primary name and each other name
+// the identity without an email, see SetPrimaryValues. The organizations other than
+// PersonalAffiliation are the organizations and the enrollments, see Organizations.Affiliations.
+func (p People) WriteSortingHat(path string, orgs Organizations) (err error) {
+	export := sortingHatFile{
+		Time:             time.Now().UTC().Format("2006-01-02 15:04:05.000000"),
+		Blacklist:        []string{},
+		Organizations:    map[string][]sortingHatDomain{},
+		UniqueIdentities: map[string]sortingHatUniqueIdentity{},
+	}
+	domains := make([]string, 0, len(orgs))
+	for domain := range orgs {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	for _, domain := range domains {
+		if organization := orgs[domain]; organization != PersonalAffiliation {
+			export.Organizations[organization] = append(export.Organizations[organization],
+				sortingHatDomain{Domain: domain})
+		}
+	}
+	for _, id := range peopleIDs(p) {
+		person := p[id]
+		name := person.PrimaryName
+		if name == "" && len(person.NamesWithRepos) > 0 {
+			name = person.NamesWithRepos[0].Name
+		}
+		var identities []sortingHatIdentity
+		emails := append([]string{}, person.Emails...)
+		sort.Strings(emails)
+		for _, email := range emails {
+			identities = append(identities, sortingHatIdentity{
+				ID:    sortingHatIdentityID(sortingHatSource, email, name, ""),
+				Name:  sortingHatNullable(name),
+				Email: sortingHatNullable(email),
+			})
+		}
+		seen := map[string]bool{name: len(emails) > 0}
+		for _, alias := range person.NamesWithRepos {
+			if !seen[alias.Name] {
+				seen[alias.Name] = true
+				identities = append(identities, sortingHatIdentity{
+					ID:   sortingHatIdentityID(sortingHatSource, "", alias.Name, ""),
+					Name: sortingHatNullable(alias.Name),
+				})
+			}
+		}
+		if len(identities) == 0 {
+			continue
+		}
+		uuid := identities[0].ID
+		if externalID, exists := person.ExternalIDs[SortingHatProvider]; exists {
+			uuid = externalID
+		}
+		for i := range identities {
+			identities[i].Source = sortingHatSource
+			identities[i].UUID = uuid
+		}
+		email := person.PrimaryEmail
+		if email == "" && len(emails) > 0 {
+			email = emails[0]
+		}
+		unique := sortingHatUniqueIdentity{
+			UUID: uuid,
+			Profile: &sortingHatProfile{UUID: uuid, Name: sortingHatNullable(name),
+				Email: sortingHatNullable(email), IsBot: person.IsBot},
+			Identities:  identities,
+			Enrollments: []sortingHatEnrollment{},
+		}
+		for _, affiliation := range orgs.Affiliations(person) {
+			if affiliation.Organization == PersonalAffiliation {
+				continue
+			}
+			unique.Enrollments = append(unique.Enrollments, sortingHatEnrollment{
+				Start:        affiliation.Since.UTC().Format(sortingHatTimeFormat),
+				End:          affiliation.Until.UTC().Format(sortingHatTimeFormat),
+				Organization: affiliation.Organization,
+				UUID:         uuid,
+			})
+		}
+		export.UniqueIdentities[uuid] = unique
+	}
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(export)
+}
@@ -0,0 +1,126 @@
+package idmatch
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortingHatIdentityID(t *testing.T) {
+	require.Equal(t, "03e12d00e37fd45593c49a5a5a1652deca4cf302",
+		sortingHatIdentityID("scm", "jsmith@example.com", "John Smith", "jsmith"))
+	require.Equal(t, sortingHatIdentityID("git", "None", "bob", "None"),
+		sortingHatIdentityID("git", "", "bob", ""))
+}
+
+func TestReadSortingHat(t *testing.T) {
+	req := require.New(t)
+	f, cleanup := tempFile(t, "*.json")
+	defer cleanup()
+	_, err := f.WriteString(`{
+    "time": "2019-01-01 00:00:00.000000",
+    "source": null,
+    "blacklist": [],
+    "organizations": {"Corp Inc": [{"domain": "Corp.com", "is_top": false}]},
+    "uidentities": {
+        "bbb": {
+            "uuid": "bbb",
+            "profile": {"uuid": "bbb", "name": "Bob Smith", "email": "Bob@corp.com", "is_bot": false},
+            "identities": [
+                {"id": "1", "name": "Bob Smith", "email": "Bob@corp.com", "username": null, "source": "git", "uuid": "bbb"},
+                {"id": "2", "name": "Bob Smith", "email": "bob@gmail.com", "username": null, "source": "git", "uuid": "bbb"},
+                {"id": "3", "name": "bsmith", "email": null, "username": "bsmith", "source": "github", "uuid": "bbb"}
+            ],
+            "enrollments": []
+        },
+        "aaa": {
+            "uuid": "aaa",
+            "profile": {"uuid": "aaa", "name": null, "email": null, "is_bot": true},
+            "identities": [{"id": "4", "name": null, "email": "ci@corp.com", "source": "git", "uuid": "aaa"}],
+            "enrollments": []
+        }
+    }
+}`)
+	req.NoError(err)
+	people, orgs, err := ReadSortingHat(f.Name())
+	req.NoError(err)
+	req.Equal(Organizations{"corp.com": "Corp Inc"}, orgs)
+	req.Equal(People{
+		1: {ID: 1, ExternalIDs: ExternalIDs{SortingHatProvider: "aaa"}, IsBot: true,
+			Emails: []string{"ci@corp.com"}},
+		2: {ID: 2, ExternalIDs: ExternalIDs{SortingHatProvider: "bbb"}, PrimaryName: "bob smith",
+			PrimaryEmail:   "bob@corp.com",
+			Emails:         []string{"bob@corp.com", "bob@gmail.com"},
+			NamesWithRepos: []NameWithRepo{{"bob smith", ""}, {"bsmith", ""}}},
+	}, people)
+
+	_, err = f.Seek(0, 0)
+	req.NoError(err)
+	req.NoError(f.Truncate(0))
+	_, err = f.WriteString("[]")
+	req.NoError(err)
+	_, _, err = ReadSortingHat(f.Name())
+	req.Error(err)
+}
+
+func TestWriteSortingHat(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-sortinghat")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people := newAffiliationTestPeople()
+	people[1].Emails = []string{"bob@gmail.com", "bob@corp.com"}
+	people[1].NamesWithRepos = []NameWithRepo{{"bob", ""}, {"robert", ""}}
+	people[1].ExternalIDs = ExternalIDs{"github": "bob", SortingHatProvider: "bob-uuid"}
+	people[2].Emails = nil
+	people[2].NamesWithRepos = []NameWithRepo{{"alice", ""}}
+	people[2].IsBot = true
+	people[3] = &Person{ID: 3}
+	orgs := NewOrganizations().Merge(Organizations{"corp.com": "Corp Inc"})
+	path := filepath.Join(dir, "sortinghat.json")
+	req.NoError(people.WriteSortingHat(path, orgs))
+
+	data, err := ioutil.ReadFile(path)
+	req.NoError(err)
+	var export sortingHatFile
+	req.NoError(json.Unmarshal(data, &export))
+	req.Equal(map[string][]sortingHatDomain{"Corp Inc": {{"corp.com", false}}}, export.Organizations)
+	req.Len(export.UniqueIdentities, 2)
+	bob := export.UniqueIdentities["bob-uuid"]
+	req.Equal("bob@corp.com", *bob.Profile.Email)
+	req.Equal("bob", *bob.Profile.Name)
+	req.Len(bob.Identities, 3)
+	req.Equal(sortingHatIdentityID("git", "bob@corp.com", "bob", ""), bob.Identities[0].ID)
+	req.Equal("bob@gmail.com", *bob.Identities[1].Email)
+	req.Equal("robert", *bob.Identities[2].Name)
+	req.Nil(bob.Identities[2].Email)
+	for _, identity := range bob.Identities {
+		req.Equal("git", identity.Source)
+		req.Equal("bob-uuid", identity.UUID)
+	}
+	req.Equal([]sortingHatEnrollment{
+		{"2019-02-01T00:00:00", "2019-08-01T00:00:00", "Corp Inc", "bob-uuid"}}, bob.Enrollments)
+	aliceUUID := sortingHatIdentityID("git", "", "alice", "")
+	alice := export.UniqueIdentities[aliceUUID]
+	req.True(alice.Profile.IsBot)
+	req.Nil(alice.Profile.Email)
+	req.Empty(alice.Enrollments)
+
+	people[1].ExternalIDs = ExternalIDs{"github": "bob"}
+	req.NoError(people.WriteSortingHat(path, orgs))
+	imported, importedOrgs, err := ReadSortingHat(path)
+	req.NoError(err)
+	req.Equal(Organizations{"corp.com": "Corp Inc"}, importedOrgs)
+	req.Len(imported, 2)
+	for _, person := range imported {
+		if person.PrimaryEmail == "bob@corp.com" {
+			req.Equal(sortingHatIdentityID("git", "bob@corp.com", "bob", ""), person.ExternalIDs[SortingHatProvider])
+			req.Equal([]string{"bob@corp.com", "bob@gmail.com"}, person.Emails)
+			req.Equal([]NameWithRepo{{"bob", ""}, {"robert", ""}}, person.NamesWithRepos)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package idmatch
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// ValueStats are the statistics of a single cleaned name or email in the signatures.
+type ValueStats struct {
+	Frequency
+	// Repositories is the number of the distinct repositories with the value.
+	Repositories int
+	// First is the time of the earliest signature with the value.
+	First time.Time
+	// Concentration is the Gini coefficient of the signatures with the value across all
+	// the repositories of the dataset: 0 means that they are spread evenly, which is typical
+	// for the popular names, and close to 1 means that they are all in a single repository.
+	Concentration float64
+}
+
+// Stats are the statistics of the names and the emails in the signatures which the matching
+// uses to detect the popular values and to choose the primary ones. They are useful to analyze
+// the popularity of the names independently of the matching, see Stats.Write.
+type Stats struct {
+	Names  map[string]*ValueStats
+	Emails map[string]*ValueStats
+	// Repositories is the number of the distinct repositories in the signatures.
+	Repositories int
+}
+
+// ComputeStats calculates the statistics of the names and the emails in the signatures. The
+// recent frequencies count the signatures after recentStartTime.
+func ComputeStats(ctx context.Context, commits []Signature, recentStartTime time.Time,
+	opts FrequencyOptions, progressReporter ProgressReporter) (*Stats, error) {
+	prog := &progress{ctx, progressReporter}
+	nameFreqs, emailFreqs, err := getStats(prog, commits, recentStartTime, opts)
+	if err != nil {
+		return nil, err
+	}
+	stats := &Stats{}
+	if err = stats.fill(prog, commits, nameFreqs, emailFreqs); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// fill sets the statistics from the frequencies calculated by getStats and the signatures.
+func (s *Stats) fill(prog *progress, commits []Signature,
+	nameFreqs, emailFreqs map[string]*Frequency) error {
+	newValueStats := func(freqs map[string]*Frequency) map[string]*ValueStats {
+		result := make(map[string]*ValueStats, len(freqs))
+		for value, freq := range freqs {
+			result[value] = &ValueStats{Frequency: *freq}
+		}
+		return result
+	}
+	s.Names, s.Emails = newValueStats(nameFreqs), newValueStats(emailFreqs)
+	repos := map[string]struct{}{}
+	nameRepos, emailRepos := map[string]map[string]int{}, map[string]map[string]int{}
+	count := func(counts map[string]map[string]int, stats map[string]*ValueStats,
+		value string, commit Signature) {
+		if counts[value] == nil {
+			counts[value] = map[string]int{}
+		}
+		counts[value][commit.Repo]++
+		if vs := stats[value]; vs != nil && !commit.Time.IsZero() &&
+			(vs.First.IsZero() || commit.Time.Before(vs.First)) {
+			vs.First = commit.Time
+		}
+	}
+	stage := prog.stage("counting repositories of the names and emails", len(commits))
+	defer stage.done()
+	for _, commit := range commits {
+		if err := stage.tick(); err != nil {
+			return err
+		}
+		repos[commit.Repo] = struct{}{}
+		name, err := cleanName(commit.Name)
+		if err != nil {
+			return err
+		}
+		email, err := cleanEmail(commit.Email)
+		if err != nil {
+			return err
+		}
+		count(nameRepos, s.Names, name, commit)
+		count(emailRepos, s.Emails, email, commit)
+	}
+	s.Repositories = len(repos)
+	for _, pair := range []struct {
+		counts map[string]map[string]int
+		stats  map[string]*ValueStats
+	}{{nameRepos, s.Names}, {emailRepos, s.Emails}} {
+		for value, counts := range pair.counts {
+			if vs := pair.stats[value]; vs != nil {
+				vs.Repositories = len(counts)
+				vs.Concentration = gini(counts, s.Repositories)
+			}
+		}
+	}
+	return nil
+}
+
+// gini returns the Gini coefficient of the counts among n items, the items which are missing
+// in the counts count 0.
+func gini(counts map[string]int, n int) float64 {
+	if n <= 1 {
+		return 0
+	}
+	values := make([]int, 0, len(counts))
+	total := 0
+	for _, count := range counts {
+		values = append(values, count)
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	sort.Ints(values)
+	weighted := 0.0
+	for i, value := range values {
+		// the zeros occupy the first n - len(values) positions
+		weighted += float64(n-len(values)+i+1) * float64(value)
+	}
+	return 2*weighted/(float64(n)*float64(total)) - float64(n+1)/float64(n)
+}
+
+// statsRecord is the row of the statistics file.
+type statsRecord struct {
+	Kind          string  `parquet:"name=kind, type=UTF8, encoding=PLAIN_DICTIONARY"`
+	Value         string  `parquet:"name=value, type=UTF8"`
+	Total         int64   `parquet:"name=total, type=INT_64"`
+	Recent        int64   `parquet:"name=recent, type=INT_64"`
+	Decayed       float64 `parquet:"name=decayed, type=DOUBLE"`
+	Repositories  int64   `parquet:"name=repositories, type=INT_64"`
+	FirstSeen     int64   `parquet:"name=first_seen, type=TIMESTAMP_MILLIS"`
+	LastSeen      int64   `parquet:"name=last_seen, type=TIMESTAMP_MILLIS"`
+	Concentration float64 `parquet:"name=concentration, type=DOUBLE"`
+}
+
+// records returns the rows of the statistics file: the names and then the emails, each sorted
+// by the total frequency in the descending order and then by the value.
+func (s *Stats) records() []statsRecord {
+	var records []statsRecord
+	for _, kind := range []struct {
+		name  string
+		stats map[string]*ValueStats
+	}{{"name", s.Names}, {"email", s.Emails}} {
+		values := make([]string, 0, len(kind.stats))
+		for value := range kind.stats {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			vi, vj := kind.stats[values[i]], kind.stats[values[j]]
+			if vi.Total != vj.Total {
+				return vi.Total > vj.Total
+			}
+			return values[i] < values[j]
+		})
+		for _, value := range values {
+			vs := kind.stats[value]
+			records = append(records, statsRecord{
+				kind.name, value, int64(vs.Total), int64(vs.Recent), vs.Decayed,
+				int64(vs.Repositories), timeToMillis(vs.First), timeToMillis(vs.Last), vs.Concentration})
+		}
+	}
+	return records
+}
+
+// Write saves the statistics to the parquet file if path ends with ".parquet", otherwise to
+// the CSV file, one row per name or email with the columns kind, value, total, recent, decayed,
+// repositories, first_seen, last_seen and concentration.
+func (s *Stats) Write(path string) error {
+	if strings.HasSuffix(path, ".parquet") {
+		return s.writeParquet(path)
+	}
+	return s.writeCSV(path)
+}
+
+func (s *Stats) writeCSV(path string) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	err = writer.Write([]string{"kind", "value", "total", "recent", "decayed", "repositories",
+		"first_seen", "last_seen", "concentration"})
+	if err != nil {
+		return
+	}
+	formatTime := func(millis int64) string {
+		if millis == 0 {
+			return ""
+		}
+		return time.Unix(0, millis*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+	}
+	for _, record := range s.records() {
+		err = writer.Write([]string{record.Kind, record.Value,
+			strconv.FormatInt(record.Total, 10), strconv.FormatInt(record.Recent, 10),
+			strconv.FormatFloat(record.Decayed, 'f', -1, 64),
+			strconv.FormatInt(record.Repositories, 10),
+			formatTime(record.FirstSeen), formatTime(record.LastSeen),
+			strconv.FormatFloat(record.Concentration, 'f', 4, 64)})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (s *Stats) writeParquet(path string) (err error) {
+	file, err := createParquetFile(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	pw, err := writer.NewParquetWriter(file, new(statsRecord), int64(runtime.NumCPU()))
+	if err != nil {
+		return err
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	for _, record := range s.records() {
+		if err = pw.Write(record); err != nil {
+			return err
+		}
+	}
+	return pw.WriteStop()
+}
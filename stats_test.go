@@ -0,0 +1,79 @@
+package idmatch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeStats(t *testing.T) {
+	req := require.New(t)
+	stats, err := ComputeStats(context.Background(), Signatures, time.Now().AddDate(0, -12, 0),
+		FrequencyOptions{}, nil)
+	req.NoError(err)
+	req.Equal(2, stats.Repositories)
+	req.Len(stats.Names, 3)
+	req.Len(stats.Emails, 4)
+	bob := stats.Names["bob"]
+	req.Equal(Frequency{Recent: 2, Total: 4, Last: Signatures[3].Time}, bob.Frequency)
+	req.Equal(2, bob.Repositories)
+	req.Equal(Signatures[4].Time, bob.First)
+	req.InDelta(0.25, bob.Concentration, 1e-9)
+	alice := stats.Names["alice"]
+	req.Equal(1, alice.Repositories)
+	req.Equal(Signatures[2].Time, alice.First)
+	req.InDelta(0.5, alice.Concentration, 1e-9)
+	req.Equal(2, stats.Emails["bob@google.com"].Repositories)
+	req.Equal(1, stats.Emails["alice@google.com"].Repositories)
+}
+
+func TestGini(t *testing.T) {
+	req := require.New(t)
+	req.Equal(0.0, gini(map[string]int{"a": 5}, 1))
+	req.Equal(0.0, gini(map[string]int{}, 3))
+	req.InDelta(0.0, gini(map[string]int{"a": 2, "b": 2, "c": 2}, 3), 1e-9)
+	req.InDelta(0.75, gini(map[string]int{"a": 7}, 4), 1e-9)
+}
+
+func TestStatsWrite(t *testing.T) {
+	req := require.New(t)
+	stats, err := ComputeStats(context.Background(), Signatures, time.Now().AddDate(0, -12, 0),
+		FrequencyOptions{}, nil)
+	req.NoError(err)
+	f, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	req.NoError(stats.Write(f.Name()))
+	data, err := ioutil.ReadFile(f.Name())
+	req.NoError(err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	req.Len(lines, 8)
+	req.Equal("kind,value,total,recent,decayed,repositories,first_seen,last_seen,concentration",
+		lines[0])
+	req.True(strings.HasPrefix(lines[1], "name,bob,4,2,0,2,"), lines[1])
+	req.True(strings.HasSuffix(lines[1], ",0.2500"), lines[1])
+	req.True(strings.HasPrefix(lines[4], "email,bob@google.com,3,2,0,2,"), lines[4])
+
+	f2, cleanup2 := tempFile(t, "*.parquet")
+	defer cleanup2()
+	req.NoError(stats.Write(f2.Name()))
+	info, err := os.Stat(f2.Name())
+	req.NoError(err)
+	req.NotZero(info.Size())
+}
+
+func TestPeopleFromSignaturesStats(t *testing.T) {
+	req := require.New(t)
+	stats := &Stats{}
+	_, nameFreqs, _, err := PeopleFromSignatures(context.Background(),
+		append([]Signature{}, Signatures...), ExtractionOptions{Stats: stats}, newTestBlacklist(t),
+		PopularityThresholds{}, BotDetectionOptions{}, 12, nil)
+	req.NoError(err)
+	req.Equal(2, stats.Repositories)
+	req.Equal(*nameFreqs["bob"], stats.Names["bob"].Frequency)
+	req.Equal(2, stats.Names["bob"].Repositories)
+}
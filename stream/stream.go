@@ -0,0 +1,298 @@
+// Package stream matches the identities continuously: it consumes the signatures from a Kafka
+// topic, keeps the people matched from all the signatures consumed so far and publishes
+// the changes of the people to another topic.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/src-d/identity-matching/reporter"
+	"github.com/src-d/identity-matching/service"
+)
+
+// Options configure the matching of the Processor and the batching of the signatures.
+// The review options of service.Options are not used.
+type Options struct {
+	service.Options
+	// BatchSize is the maximum number of the signatures which are matched together.
+	BatchSize int
+	// FlushInterval is the maximum time the consumed signatures wait for the matching.
+	FlushInterval time.Duration
+}
+
+// Reader consumes the signature events, *kafka.Reader implements it.
+type Reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, messages ...kafka.Message) error
+}
+
+// Writer publishes the identity update events, *kafka.Writer implements it.
+type Writer interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+}
+
+// SignatureEvent is the JSON value of the consumed message, see idmatch.Signature. The time is
+// in RFC 3339 format.
+type SignatureEvent struct {
+	Repo       string `json:"repo"`
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Hash       string `json:"hash"`
+	Time       string `json:"time,omitempty"`
+	Source     string `json:"source,omitempty"`
+	Role       string `json:"role,omitempty"`
+	SigningKey string `json:"signing_key,omitempty"`
+}
+
+// Processor keeps the people matched from all the signatures it received. Each batch is matched
+// only together with the people who share an email, a name or an external ID with it, and
+// the merged people keep the smallest ID, so the IDs are stable: a person is never renumbered,
+// only merged into an older one. The people are never split by the later batches, and only
+// the people and the frequencies of the names and the emails are kept in memory rather than
+// the signatures. People and Index may be called concurrently with Add and Run, which must not
+// run concurrently with each other.
+type Processor struct {
+	options    Options
+	people     *idmatch.ConcurrentPeople
+	nameFreqs  map[string]*idmatch.Frequency
+	emailFreqs map[string]*idmatch.Frequency
+	// lastID is the largest ID ever given to a person.
+	lastID     int64
+	signatures int
+}
+
+// NewProcessor creates the Processor without any people.
+func NewProcessor(options Options) *Processor {
+	return &Processor{
+		options:    options,
+		people:     idmatch.NewConcurrentPeople(nil),
+		nameFreqs:  map[string]*idmatch.Frequency{},
+		emailFreqs: map[string]*idmatch.Frequency{},
+	}
+}
+
+// People returns the people matched after the last batch. They must not be changed.
+func (p *Processor) People() idmatch.People {
+	return p.people.Snapshot()
+}
+
+// Index returns the query helpers of the people matched after the last batch.
+func (p *Processor) Index() *idmatch.PeopleIndex {
+	return p.people.Index()
+}
+
+// Add matches the signatures into the existing people and returns the changes of the people:
+// the created and merged people, the added emails and the changed external IDs, see
+// idmatch.DiffPeople. The new people receive the IDs after all the previous ones.
+func (p *Processor) Add(ctx context.Context, signatures []idmatch.Signature) (
+	[]idmatch.IdentityChange, error) {
+	// PeopleFromSignatures may change the signatures
+	batch, nameFreqs, emailFreqs, err := idmatch.PeopleFromSignatures(ctx,
+		append([]idmatch.Signature(nil), signatures...), p.options.Extraction, p.options.Blacklist,
+		p.options.Popularity, p.options.Bots, p.options.RecentMonths, p.options.Reduce.Progress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the people: %v", err)
+	}
+	idmatch.MergeFrequencies(p.nameFreqs, nameFreqs)
+	idmatch.MergeFrequencies(p.emailFreqs, emailFreqs)
+	p.signatures += len(signatures)
+	reporter.Commit("stream signatures", p.signatures)
+	if len(batch) == 0 {
+		return nil, nil
+	}
+	blacklist := p.options.Blacklist.WithPopular(p.nameFreqs, p.emailFreqs, p.options.Popularity)
+	var changes []idmatch.IdentityChange
+	err = p.people.Update(func(people idmatch.People) error {
+		previous, touched := p.related(batch, people)
+		err := idmatch.ReducePeople(ctx, touched, p.options.Matcher, blacklist, p.options.Reduce)
+		if err != nil {
+			return fmt.Errorf("failed to reduce the people: %v", err)
+		}
+		idmatch.SetPrimaryValues(touched, p.nameFreqs, p.emailFreqs, p.options.Primary)
+		for id := range previous {
+			delete(people, id)
+		}
+		for id, person := range touched {
+			people[id] = person
+		}
+		changes = idmatch.DiffPeople(previous, touched)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	reporter.Commit("stream people", len(p.People()))
+	return changes, nil
+}
+
+// related numbers the people of the batch after all the previous ones and returns them together
+// with the existing people who share an email, a name or an external ID with them: previous are
+// the current snapshots of the existing people and touched are their copies from people, which
+// are the ones to change.
+func (p *Processor) related(batch, people idmatch.People) (previous, touched idmatch.People) {
+	index := p.people.Index()
+	previous, touched = idmatch.People{}, idmatch.People{}
+	add := func(person *idmatch.Person) {
+		if person != nil {
+			previous[person.ID] = person
+			touched[person.ID] = people[person.ID]
+		}
+	}
+	ids := make([]int64, 0, len(batch))
+	for id := range batch {
+		ids = append(ids, id)
+	}
+	idmatch.Int64Slice(ids).Sort()
+	for _, id := range ids {
+		person := batch[id]
+		p.lastID++
+		person.ID = p.lastID
+		touched[person.ID] = person
+		for _, email := range person.Emails {
+			add(index.FindByEmail(email))
+		}
+		for _, name := range person.NamesWithRepos {
+			for _, existing := range index.FindByName(name.Name, name.Repo) {
+				add(existing)
+			}
+		}
+		for provider, externalID := range person.ExternalIDs {
+			add(index.FindByExternalID(provider, externalID))
+		}
+	}
+	return previous, touched
+}
+
+// Run consumes the signature events from reader in batches of at most Options.BatchSize which
+// wait at most Options.FlushInterval, matches each batch with Add and publishes the changes
+// to writer. The consumed messages are committed after the changes are published. The invalid
+// messages are skipped. Run returns when ctx is cancelled or reading fails.
+func (p *Processor) Run(ctx context.Context, reader Reader, writer Writer) error {
+	for {
+		batch, messages, err := p.fetchBatch(ctx, reader)
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			continue
+		}
+		changes, err := p.Add(ctx, batch)
+		if err != nil {
+			return err
+		}
+		events, err := ChangeMessages(changes)
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			if err = writer.WriteMessages(ctx, events...); err != nil {
+				return fmt.Errorf("failed to publish %d identity updates: %v", len(events), err)
+			}
+		}
+		if err = reader.CommitMessages(ctx, messages...); err != nil {
+			return fmt.Errorf("failed to commit %d signature events: %v", len(messages), err)
+		}
+		reporter.Infof("matched %d signatures into %d people, published %d identity updates",
+			len(batch), len(p.People()), len(events))
+	}
+}
+
+// fetchBatch reads the messages until the batch is full or Options.FlushInterval passes since
+// the first message.
+func (p *Processor) fetchBatch(ctx context.Context, reader Reader) (
+	[]idmatch.Signature, []kafka.Message, error) {
+	var signatures []idmatch.Signature
+	var messages []kafka.Message
+	fetchCtx := ctx
+	for p.options.BatchSize <= 0 || len(messages) < p.options.BatchSize {
+		message, err := reader.FetchMessage(fetchCtx)
+		if err != nil {
+			if ctx.Err() == nil && fetchCtx.Err() != nil {
+				// the flush interval has passed
+				break
+			}
+			return nil, nil, err
+		}
+		if len(messages) == 0 && p.options.FlushInterval > 0 {
+			var cancel context.CancelFunc
+			fetchCtx, cancel = context.WithTimeout(ctx, p.options.FlushInterval)
+			defer cancel()
+		}
+		messages = append(messages, message)
+		signature, err := ParseSignatureEvent(message.Value)
+		if err == nil {
+			// the ignored signatures would never become identities but would stay in memory
+			err = p.options.Blacklist.CheckSignature(signature)
+		}
+		if errors.Is(err, idmatch.ErrBlacklisted) {
+			reporter.Increment("stream blacklisted signature events")
+			continue
+		}
+		if err != nil {
+			reporter.Warnf("skipped the signature event at offset %d of partition %d: %v",
+				message.Offset, message.Partition, err)
+			reporter.Increment("stream invalid signature events")
+			continue
+		}
+		signatures = append(signatures, signature)
+	}
+	return signatures, messages, nil
+}
+
+// ParseSignatureEvent converts the JSON SignatureEvent to the signature. The errors satisfy
+// errors.Is(err, idmatch.ErrInvalidSignature).
+func ParseSignatureEvent(value []byte) (idmatch.Signature, error) {
+	var event SignatureEvent
+	if err := json.Unmarshal(value, &event); err != nil {
+		return idmatch.Signature{}, fmt.Errorf("%w: %v", idmatch.ErrInvalidSignature, err)
+	}
+	if event.Repo == "" || (event.Name == "" && event.Email == "") {
+		return idmatch.Signature{}, fmt.Errorf("%w: repo and either name or email are required",
+			idmatch.ErrInvalidSignature)
+	}
+	signature := idmatch.Signature{
+		Repo:       event.Repo,
+		Name:       event.Name,
+		Email:      event.Email,
+		Hash:       event.Hash,
+		Source:     idmatch.SourceKind(event.Source),
+		Role:       idmatch.SignatureRole(event.Role),
+		SigningKey: event.SigningKey,
+	}
+	if event.Time != "" {
+		var err error
+		if signature.Time, err = time.Parse(time.RFC3339, event.Time); err != nil {
+			return idmatch.Signature{}, fmt.Errorf("%w: invalid time: %v", idmatch.ErrInvalidSignature, err)
+		}
+	}
+	return signature, nil
+}
+
+// ChangeMessages converts the changes to the identity update events: the values are
+// the JSON idmatch.IdentityChange-s and the keys are the IDs of the changed people, the first
+// new ID or else the first old one, so that the updates of the same person go to the same
+// partition. The IDs are stable, see Processor, unlike the emails which the people gain.
+func ChangeMessages(changes []idmatch.IdentityChange) ([]kafka.Message, error) {
+	messages := make([]kafka.Message, len(changes))
+	for i, change := range changes {
+		value, err := json.Marshal(change)
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		if len(change.NewIDs) > 0 {
+			key = strconv.FormatInt(change.NewIDs[0], 10)
+		} else if len(change.OldIDs) > 0 {
+			key = strconv.FormatInt(change.OldIDs[0], 10)
+		}
+		messages[i] = kafka.Message{Key: []byte(key), Value: value}
+	}
+	return messages, nil
+}
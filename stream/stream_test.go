@@ -0,0 +1,173 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	idmatch "github.com/src-d/identity-matching"
+	"github.com/src-d/identity-matching/service"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestProcessor(t *testing.T, batchSize int) *Processor {
+	blacklist, err := idmatch.NewBlacklist()
+	require.NoError(t, err)
+	return NewProcessor(Options{
+		Options: service.Options{
+			Blacklist:    blacklist,
+			RecentMonths: 12,
+			Primary:      idmatch.PrimaryOptions{MinRecentCount: 5},
+			Reduce:       idmatch.ReduceOptions{MaxIdentities: 20},
+		},
+		BatchSize:     batchSize,
+		FlushInterval: 50 * time.Millisecond,
+	})
+}
+
+// testReader returns the messages and then blocks until the context is done.
+type testReader struct {
+	lock      sync.Mutex
+	messages  []kafka.Message
+	committed []kafka.Message
+}
+
+func (r *testReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	r.lock.Lock()
+	if len(r.messages) > 0 {
+		message := r.messages[0]
+		r.messages = r.messages[1:]
+		r.lock.Unlock()
+		return message, nil
+	}
+	r.lock.Unlock()
+	<-ctx.Done()
+	return kafka.Message{}, ctx.Err()
+}
+
+func (r *testReader) CommitMessages(ctx context.Context, messages ...kafka.Message) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.committed = append(r.committed, messages...)
+	return nil
+}
+
+type testWriter struct {
+	lock     sync.Mutex
+	messages []kafka.Message
+}
+
+func (w *testWriter) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.messages = append(w.messages, messages...)
+	return nil
+}
+
+func TestProcessorAdd(t *testing.T) {
+	req := require.New(t)
+	processor := newTestProcessor(t, 0)
+	changes, err := processor.Add(context.Background(), []idmatch.Signature{
+		{Repo: "repo1", Name: "Bob Jones", Email: "bob@bobjones.dev"},
+		{Repo: "repo1", Name: "Alice Smith", Email: "alice@alicesmith.dev"},
+	})
+	req.NoError(err)
+	req.Len(changes, 2)
+	for _, change := range changes {
+		req.Equal(idmatch.IdentityAdded, change.Kind)
+	}
+	req.Len(processor.People(), 2)
+	bob := processor.Index().FindByEmail("bob@bobjones.dev")
+	req.NotNil(bob)
+	before := processor.People()
+
+	changes, err = processor.Add(context.Background(), []idmatch.Signature{
+		{Repo: "repo2", Name: "Bob Jones", Email: "bob.jones@example-home.org"},
+	})
+	req.NoError(err)
+	req.Equal([]idmatch.IdentityChange{{
+		Kind:   idmatch.IdentityGainedEmails,
+		OldIDs: []int64{bob.ID},
+		NewIDs: []int64{bob.ID},
+		Emails: []string{"bob.jones@example-home.org"},
+	}}, changes)
+	req.Len(processor.People(), 2)
+	req.Len(processor.People()[bob.ID].Emails, 2)
+	// the readers of the previous people do not see the batch
+	req.Len(before[bob.ID].Emails, 1)
+
+	changes, err = processor.Add(context.Background(), []idmatch.Signature{
+		{Repo: "repo3", Name: "Carol White", Email: "carol@carolwhite.dev"},
+	})
+	req.NoError(err)
+	req.Len(changes, 1)
+	carol := changes[0].NewIDs[0]
+	req.Equal(int64(4), carol)
+
+	// the signature links Bob and Carol, the older ID survives
+	changes, err = processor.Add(context.Background(), []idmatch.Signature{
+		{Repo: "repo3", Name: "Carol White", Email: "bob.jones@example-home.org"},
+	})
+	req.NoError(err)
+	req.Len(changes, 1)
+	req.Equal(idmatch.IdentityMerged, changes[0].Kind)
+	req.Equal([]int64{bob.ID, carol}, changes[0].OldIDs)
+	req.Equal([]int64{bob.ID}, changes[0].NewIDs)
+	req.Len(processor.People(), 2)
+	req.NotNil(processor.People()[bob.ID])
+
+	changes, err = processor.Add(context.Background(), nil)
+	req.NoError(err)
+	req.Empty(changes)
+}
+
+func TestProcessorRun(t *testing.T) {
+	req := require.New(t)
+	reader := &testReader{}
+	for _, value := range []string{
+		`{"repo": "repo1", "name": "Bob Jones", "email": "bob@bobjones.dev", "time": "2019-01-01T00:00:00Z"}`,
+		`{"repo": "repo1", "name": "Alice Smith", "email": "alice@alicesmith.dev"}`,
+		`{"repo": "repo1"}`,
+		`{"repo": "repo1", "name": "Bob Jones", "email": "bob@localhost"}`,
+		`{"repo": "repo2", "name": "Bob Jones", "email": "bob.jones@example-home.org"}`,
+	} {
+		reader.messages = append(reader.messages, kafka.Message{Value: []byte(value)})
+	}
+	writer := &testWriter{}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := newTestProcessor(t, 3).Run(ctx, reader, writer)
+	req.Equal(context.DeadlineExceeded, err)
+	req.Len(reader.committed, 5)
+	var kinds []idmatch.IdentityChangeKind
+	for _, message := range writer.messages {
+		var change idmatch.IdentityChange
+		req.NoError(json.Unmarshal(message.Value, &change))
+		req.Equal(strconv.FormatInt(change.NewIDs[0], 10), string(message.Key))
+		kinds = append(kinds, change.Kind)
+	}
+	req.Equal([]idmatch.IdentityChangeKind{
+		idmatch.IdentityAdded, idmatch.IdentityAdded, idmatch.IdentityGainedEmails}, kinds)
+}
+
+func TestParseSignatureEvent(t *testing.T) {
+	req := require.New(t)
+	signature, err := ParseSignatureEvent([]byte(`{"repo": "repo1", "name": "Bob", ` +
+		`"email": "bob@google.com", "hash": "aaa", "time": "2019-01-01T00:00:00Z", ` +
+		`"source": "git", "signing_key": "key"}`))
+	req.NoError(err)
+	req.Equal(idmatch.Signature{
+		Repo: "repo1", Name: "Bob", Email: "bob@google.com", Hash: "aaa",
+		Time: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), Source: "git", SigningKey: "key",
+	}, signature)
+	_, err = ParseSignatureEvent([]byte(`{"repo": "repo1"}`))
+	req.EqualError(err, "invalid signature: repo and either name or email are required")
+	_, err = ParseSignatureEvent([]byte(`{"repo": "repo1", "name": "Bob", "time": "yesterday"}`))
+	req.ErrorIs(err, idmatch.ErrInvalidSignature)
+	_, err = ParseSignatureEvent([]byte(`not json`))
+	req.ErrorIs(err, idmatch.ErrInvalidSignature)
+}
@@ -0,0 +1,375 @@
+package idmatch
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// StyleFingerprint is the coding style of an identity counted over the lines it added:
+// the indentation, the identifier casing, the comment density and the line lengths.
+type StyleFingerprint struct {
+	// Lines is the number of the non-empty lines.
+	Lines int
+	// Tabs and Spaces are the numbers of the lines indented with tabs and with spaces.
+	// FourSpaces counts the space-indented lines whose indentation is a multiple of 4.
+	Tabs       int
+	Spaces     int
+	FourSpaces int
+	// SnakeCase and CamelCase are the numbers of the multi-word identifiers such as
+	// "user_name" and "userName".
+	SnakeCase int
+	CamelCase int
+	// Comments is the number of the lines which start with a comment.
+	Comments int
+	// LongLines is the number of the lines longer than 100 characters.
+	LongLines int
+}
+
+// StyleOptions configure the optional stylometry which compares the coding styles of
+// the identities with the borderline evidence, see BuildIdentityGraph. Fetching the samples is
+// expensive, so only the identities which share a name with some other identity are sampled.
+type StyleOptions struct {
+	// Sampler fetches the lines added by each identity.
+	Sampler DiffSampler
+	// SampleSize is the maximum number of the changed files sampled per identity.
+	SampleSize int
+	// MinLines is the minimum number of the sampled lines of both identities to compare them.
+	MinLines int
+	// VetoSimilarity is the style similarity below which the identities are not merged by
+	// the names alone, see IdentityGraph.vetoed.
+	VetoSimilarity float64
+	// MinSimilarity is the style similarity starting from which the connected identities
+	// receive EvidenceStyle.
+	MinSimilarity float64
+	// Workers is the number of goroutines which fetch the samples. 0 means 1.
+	Workers int
+}
+
+// DiffSampler fetches a sample of the code changed by the person.
+type DiffSampler interface {
+	// SampleDiffs returns the lines added by the commits of the person, at most limit changed
+	// files.
+	SampleDiffs(ctx context.Context, person *Person, limit int) ([]string, error)
+}
+
+var identifierRegexp = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+var commentPrefixes = []string{"//", "#", "/*", "*", "--", ";", "%"}
+
+// newStyleFingerprint counts the style of the added code.
+func newStyleFingerprint(samples []string) *StyleFingerprint {
+	fp := &StyleFingerprint{}
+	for _, sample := range samples {
+		for _, line := range strings.Split(sample, "\n") {
+			line = strings.TrimRightFunc(line, unicode.IsSpace)
+			trimmed := strings.TrimLeftFunc(line, unicode.IsSpace)
+			if trimmed == "" {
+				continue
+			}
+			fp.Lines++
+			if len(line) > 100 {
+				fp.LongLines++
+			}
+			switch indent := line[:len(line)-len(trimmed)]; {
+			case strings.HasPrefix(indent, "\t"):
+				fp.Tabs++
+			case strings.HasPrefix(indent, " "):
+				fp.Spaces++
+				if len(strings.TrimLeft(indent, " ")) == 0 && len(indent)%4 == 0 {
+					fp.FourSpaces++
+				}
+			}
+			for _, prefix := range commentPrefixes {
+				if strings.HasPrefix(trimmed, prefix) {
+					fp.Comments++
+					break
+				}
+			}
+			for _, identifier := range identifierRegexp.FindAllString(trimmed, -1) {
+				switch identifierCase(identifier) {
+				case "snake":
+					fp.SnakeCase++
+				case "camel":
+					fp.CamelCase++
+				}
+			}
+		}
+	}
+	return fp
+}
+
+// identifierCase returns "snake" for the lowercase identifiers with underscores inside,
+// "camel" for the identifiers with a lowercase letter followed by an uppercase one and ""
+// for the rest, e.g. the single words and the constants.
+func identifierCase(identifier string) string {
+	trimmed := strings.Trim(identifier, "_")
+	if strings.Contains(trimmed, "_") {
+		if strings.ToLower(trimmed) == trimmed && strings.ToUpper(trimmed) != trimmed {
+			return "snake"
+		}
+		return ""
+	}
+	for i := 1; i < len(trimmed); i++ {
+		if unicode.IsLower(rune(trimmed[i-1])) && unicode.IsUpper(rune(trimmed[i])) {
+			return "camel"
+		}
+	}
+	return ""
+}
+
+// features returns the shares which describe the style: NaN if the share is not defined,
+// e.g. the share of the tab-indented lines if none is indented.
+func (fp *StyleFingerprint) features() []float64 {
+	share := func(count, total int) float64 {
+		if total == 0 {
+			return math.NaN()
+		}
+		return float64(count) / float64(total)
+	}
+	return []float64{
+		share(fp.Tabs, fp.Tabs+fp.Spaces),
+		share(fp.FourSpaces, fp.Spaces),
+		share(fp.SnakeCase, fp.SnakeCase+fp.CamelCase),
+		share(fp.Comments, fp.Lines),
+		share(fp.LongLines, fp.Lines),
+	}
+}
+
+// Similarity returns 1 minus the mean absolute difference of the style features which are
+// defined for both fingerprints, from 0 (opposite) to 1 (same). It is 0 if nothing is comparable.
+func (fp *StyleFingerprint) Similarity(other *StyleFingerprint) float64 {
+	if fp == nil || other == nil {
+		return 0
+	}
+	features, otherFeatures := fp.features(), other.features()
+	var sum float64
+	compared := 0
+	for i, feature := range features {
+		if math.IsNaN(feature) || math.IsNaN(otherFeatures[i]) {
+			continue
+		}
+		sum += math.Abs(feature - otherFeatures[i])
+		compared++
+	}
+	if compared == 0 {
+		return 0
+	}
+	return 1 - sum/float64(compared)
+}
+
+// compare returns the similarity of the styles and whether both have enough lines.
+func (opts *StyleOptions) compare(a, b *StyleFingerprint) (float64, bool) {
+	minLines := opts.MinLines
+	if minLines < 1 {
+		minLines = 1
+	}
+	if a == nil || b == nil || a.Lines < minLines || b.Lines < minLines {
+		return 0, false
+	}
+	return a.Similarity(b), true
+}
+
+// borderlineIdentities returns the sorted IDs of the identities which share a name key with
+// another identity, that is, which may be merged by the names alone.
+func borderlineIdentities(ids []int64, keys map[int64]personKeys) []int64 {
+	counts := map[string]int{}
+	for _, id := range ids {
+		for _, name := range keys[id].names {
+			counts[name]++
+		}
+	}
+	var result []int64
+	for _, id := range ids {
+		for _, name := range keys[id].names {
+			if counts[name] > 1 {
+				result = append(result, id)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// fetchStyles samples the added code of the identities with opts.Workers goroutines and
+// returns their fingerprints.
+func fetchStyles(prog *progress, people People, ids []int64, opts *StyleOptions) (
+	map[int64]*StyleFingerprint, error) {
+	styles := make(map[int64]*StyleFingerprint, len(ids))
+	stage := prog.stage("sampling the coding styles", len(ids))
+	defer stage.done()
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(prog.context())
+	defer cancel()
+	queue := make(chan int64)
+	var lock sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for id := range queue {
+				samples, err := opts.Sampler.SampleDiffs(ctx, people[id], opts.SampleSize)
+				lock.Lock()
+				if err == nil {
+					styles[id] = newStyleFingerprint(samples)
+					err = stage.tick()
+				}
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case queue <- id:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := prog.err(); err != nil {
+		return nil, err
+	}
+	reporter.Commit("people with sampled styles", len(styles))
+	return styles, nil
+}
+
+// addStyleEvidence adds EvidenceStyle to the existing edges between the identities with
+// similar coding styles, see StyleOptions.MinSimilarity.
+func addStyleEvidence(peopleGraph *IdentityGraph, opts ReduceOptions) {
+	matched := 0
+	for _, edge := range peopleGraph.Edges() {
+		similarity, compared := opts.Style.compare(peopleGraph.styles[edge.From],
+			peopleGraph.styles[edge.To])
+		if !compared || similarity < opts.Style.MinSimilarity {
+			continue
+		}
+		node1, node2 := peopleGraph.node(edge.From), peopleGraph.node(edge.To)
+		if !edge.Active && !passIdentitiesLimit(peopleGraph.graph, opts.MaxIdentities, node1, node2) {
+			continue
+		}
+		err := peopleGraph.addEvidence(node1, node2, EvidenceStyle, fmt.Sprintf("%.2f", similarity))
+		if err != nil {
+			// the identities have different external ids
+			continue
+		}
+		matched++
+	}
+	reporter.Commit("people matched by style", matched)
+}
+
+// findAddedCodeSQL returns the new and the previous contents of the text files changed by
+// the commits of the author, at most the given number. gitbase does not compute the diffs, so
+// the files are compared with the first parent commit and addedLines extracts the difference.
+const findAddedCodeSQL = `
+SELECT f.blob_content, IFNULL(pf.blob_content, '')
+FROM commits c
+INNER JOIN commit_files cf
+	ON cf.repository_id = c.repository_id AND cf.commit_hash = c.commit_hash
+INNER JOIN files f
+	ON f.repository_id = cf.repository_id AND f.file_path = cf.file_path
+	AND f.blob_hash = cf.blob_hash AND f.tree_hash = cf.tree_hash
+LEFT JOIN commit_files pcf
+	ON pcf.repository_id = c.repository_id AND pcf.file_path = cf.file_path
+	AND pcf.commit_hash = JSON_UNQUOTE(JSON_EXTRACT(c.commit_parents, '$[0]'))
+LEFT JOIN files pf
+	ON pf.repository_id = pcf.repository_id AND pf.file_path = pcf.file_path
+	AND pf.blob_hash = pcf.blob_hash AND pf.tree_hash = pcf.tree_hash
+WHERE c.commit_author_email = ? AND (pcf.blob_hash IS NULL OR pcf.blob_hash <> cf.blob_hash)
+	AND f.blob_size < 262144 AND NOT IS_BINARY(f.blob_content)
+LIMIT ?;
+`
+
+// GitbaseDiffSampler is the DiffSampler which queries gitbase.
+type GitbaseDiffSampler struct {
+	source *gitbaseSource
+}
+
+// NewGitbaseDiffSampler connects to gitbase. The queries are retried the same way as
+// the signature queries, see ExtractionOptions.
+func NewGitbaseDiffSampler(conn string, opts ExtractionOptions) (*GitbaseDiffSampler, error) {
+	source, err := newGitbaseSource(conn, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &GitbaseDiffSampler{source}, nil
+}
+
+// Close closes the database connection.
+func (s *GitbaseDiffSampler) Close() error {
+	return s.source.Close()
+}
+
+// SampleDiffs queries the files changed by the commits of each email of the person.
+func (s *GitbaseDiffSampler) SampleDiffs(ctx context.Context, person *Person, limit int) (
+	[]string, error) {
+	var samples []string
+	for _, email := range person.Emails {
+		if len(samples) >= limit {
+			break
+		}
+		var emailSamples []string
+		err := retryQuery(ctx, s.source.opts, "sampling the code of "+email,
+			func(ctx context.Context) error {
+				emailSamples = nil
+				rows, err := s.source.db.QueryContext(ctx, findAddedCodeSQL, email,
+					limit-len(samples))
+				if err != nil {
+					return err
+				}
+				defer rows.Close()
+				for rows.Next() {
+					var content, previous sql.RawBytes
+					if err := rows.Scan(&content, &previous); err != nil {
+						return err
+					}
+					emailSamples = append(emailSamples, addedLines(string(previous), string(content)))
+				}
+				return rows.Err()
+			})
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, emailSamples...)
+	}
+	return samples, nil
+}
+
+// addedLines returns the lines of the new content which are missing in the previous one,
+// counting the repeated lines.
+func addedLines(previous, content string) string {
+	counts := map[string]int{}
+	for _, line := range strings.Split(previous, "\n") {
+		counts[line]++
+	}
+	var added []string
+	for _, line := range strings.Split(content, "\n") {
+		if counts[line] > 0 {
+			counts[line]--
+			continue
+		}
+		added = append(added, line)
+	}
+	return strings.Join(added, "\n")
+}
@@ -0,0 +1,112 @@
+package idmatch
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testGoStyle = `
+func (s *server) handleRequest(userName string) {
+	// look up the user
+	userID := s.lookupUser(userName)
+	s.sendResponse(userID)
+}`
+
+const testPythonStyle = `
+def handle_request(user_name):
+    user_id = lookup_user(user_name)
+    send_response(user_id)`
+
+type testDiffSampler struct {
+	lock    sync.Mutex
+	samples map[string]string
+	sampled []int64
+	err     error
+}
+
+func (s *testDiffSampler) SampleDiffs(ctx context.Context, person *Person, limit int) (
+	[]string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.sampled = append(s.sampled, person.ID)
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []string{s.samples[person.Emails[0]]}, nil
+}
+
+func TestNewStyleFingerprint(t *testing.T) {
+	req := require.New(t)
+	fp := newStyleFingerprint([]string{testGoStyle})
+	req.Equal(&StyleFingerprint{Lines: 5, Tabs: 3, CamelCase: 7, Comments: 1}, fp)
+	fp = newStyleFingerprint([]string{testPythonStyle, ""})
+	req.Equal(&StyleFingerprint{Lines: 3, Spaces: 2, FourSpaces: 2, SnakeCase: 7}, fp)
+	req.Equal("snake", identifierCase("user_name"))
+	req.Equal("camel", identifierCase("userName"))
+	req.Equal("", identifierCase("MAX_SIZE"))
+	req.Equal("", identifierCase("_private"))
+}
+
+func TestStyleSimilarity(t *testing.T) {
+	req := require.New(t)
+	goStyle := newStyleFingerprint([]string{testGoStyle})
+	pythonStyle := newStyleFingerprint([]string{testPythonStyle})
+	req.Equal(1.0, goStyle.Similarity(goStyle))
+	req.InDelta(0.45, goStyle.Similarity(pythonStyle), 0.01)
+	req.Equal(goStyle.Similarity(pythonStyle), pythonStyle.Similarity(goStyle))
+	req.Equal(0.0, goStyle.Similarity(nil))
+	req.Equal(0.0, (&StyleFingerprint{}).Similarity(goStyle))
+
+	opts := StyleOptions{MinLines: 4}
+	_, compared := opts.compare(goStyle, pythonStyle)
+	req.False(compared)
+	similarity, compared := opts.compare(goStyle, goStyle)
+	req.True(compared)
+	req.Equal(1.0, similarity)
+}
+
+func TestAddedLines(t *testing.T) {
+	req := require.New(t)
+	req.Equal("c\nb", addedLines("a\nb", "a\nc\nb\nb"))
+	req.Equal("a\nb", addedLines("", "a\nb"))
+	req.Equal("", addedLines("a\nb", "b\na"))
+}
+
+func TestBuildIdentityGraphStyle(t *testing.T) {
+	req := require.New(t)
+	newTestPeople := func() People {
+		return People{
+			1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+			2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@gmail.com"}},
+			3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+			4: {ID: 4, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"al@google.com"}},
+			5: {ID: 5, NamesWithRepos: []NameWithRepo{{"eve", ""}}, Emails: []string{"eve@google.com"}},
+		}
+	}
+	sampler := &testDiffSampler{samples: map[string]string{
+		"bob@google.com": testGoStyle, "bob@gmail.com": testPythonStyle,
+		"alice@google.com": testGoStyle, "al@google.com": testGoStyle,
+	}}
+	opts := ReduceOptions{MaxIdentities: 20, Style: &StyleOptions{
+		Sampler: sampler, SampleSize: 10, MinLines: 3, VetoSimilarity: 0.5, MinSimilarity: 0.9,
+		Workers: 2}}
+	g, err := BuildIdentityGraph(context.Background(), newTestPeople(), nil, newTestBlacklist(t), opts)
+	req.NoError(err)
+	sort.Slice(sampler.sampled, func(i, j int) bool { return sampler.sampled[i] < sampler.sampled[j] })
+	req.Equal([]int64{1, 2, 3, 4}, sampler.sampled)
+	edge, _ := g.Edge(1, 2)
+	req.False(edge.Active)
+	edge, _ = g.Edge(3, 4)
+	req.True(edge.Active)
+	req.Equal([]Evidence{{EvidenceName, "alice", 1}, {EvidenceStyle, "1.00", 1}}, edge.Evidence)
+	req.Equal([][]int64{{1}, {2}, {3, 4}, {5}}, g.Components())
+
+	sampler.err = errors.New("gitbase is down")
+	_, err = BuildIdentityGraph(context.Background(), newTestPeople(), nil, newTestBlacklist(t), opts)
+	req.EqualError(err, "gitbase is down")
+}
@@ -0,0 +1,255 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SyntheticOptions configure GenerateSyntheticDataset.
+type SyntheticOptions struct {
+	// People is the number of the generated persons including the bots.
+	People int
+	// Repositories is the number of the repositories which the persons commit to.
+	Repositories int
+	// MaxEmails is the maximum number of the emails of a person.
+	MaxEmails int
+	// MaxCommits is the maximum number of the commits of a person.
+	MaxCommits int
+	// Noise is the probability from 0 to 1 that a commit is signed with a name variant:
+	// a typo, the reordered words, the initial instead of the first name or the first name alone.
+	Noise float64
+	// PopularNameShare is the share of the persons who are named the same as some others.
+	PopularNameShare float64
+	// BotShare is the share of the persons who are automated accounts.
+	BotShare float64
+	// Seed initializes the random generator so that the same options give the same dataset.
+	Seed int64
+}
+
+// NewSyntheticOptions returns the options of a small dataset with moderate noise.
+func NewSyntheticOptions() SyntheticOptions {
+	return SyntheticOptions{
+		People:           1000,
+		Repositories:     100,
+		MaxEmails:        3,
+		MaxCommits:       50,
+		Noise:            0.1,
+		PopularNameShare: 0.05,
+		BotShare:         0.01,
+	}
+}
+
+// SyntheticDataset is the generated signatures with the known identities.
+type SyntheticDataset struct {
+	// Signatures are aggregated per repository the same way as the extracted signatures.
+	Signatures []Signature
+	// Truth maps the names and the emails of the signatures to the person labels, see Evaluate.
+	Truth map[signatureKey]string
+}
+
+var syntheticFirstNames = []string{
+	"alice", "bob", "carol", "dave", "eve", "frank", "grace", "heidi", "ivan", "judy", "mallory",
+	"niaj", "olivia", "peggy", "rupert", "sybil", "trent", "victor", "walter", "yusuf", "zoe",
+	"maria", "jose", "wei", "fatima", "olga", "hiroshi", "amara", "lars", "priya",
+}
+
+var syntheticLastNames = []string{
+	"smith", "johnson", "williams", "brown", "jones", "garcia", "miller", "davis", "martinez",
+	"lopez", "wilson", "anderson", "thomas", "taylor", "moore", "jackson", "martin", "lee", "chen",
+	"wang", "kumar", "novak", "ivanov", "schmidt", "rossi", "tanaka", "kim", "silva", "nguyen",
+	"okafor",
+}
+
+var syntheticSyllables = []string{
+	"ka", "ro", "mi", "ten", "sa", "vel", "do", "ri", "an", "bur", "lo", "zen", "te", "mar", "fi", "gu",
+}
+
+var syntheticPopularNames = []string{"john smith", "wei wang", "maria garcia"}
+
+var syntheticDomains = []string{
+	"gmail.com", "yahoo.com", "outlook.com", "google.com", "microsoft.com", "redhat.com",
+	"users.noreply.github.com",
+}
+
+var syntheticBotNames = []string{"release bot", "acme ci", "docs automation", "deploy bot"}
+
+// GenerateSyntheticDataset fabricates the realistic signatures of opts.People persons with
+// several emails, noisy name variants, the popular names and the bots, to benchmark the speed
+// and the accuracy of the matching without the real data.
+func GenerateSyntheticDataset(opts SyntheticOptions) SyntheticDataset {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	intn := func(n int) int {
+		if n < 1 {
+			return 0
+		}
+		return rng.Intn(n)
+	}
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	usedEmails := map[string]struct{}{}
+	uniqueEmail := func(local, domain string) string {
+		email := local + "@" + domain
+		for i := 2; ; i++ {
+			if _, exists := usedEmails[email]; !exists {
+				break
+			}
+			email = fmt.Sprintf("%s%d@%s", local, i, domain)
+		}
+		usedEmails[email] = struct{}{}
+		return email
+	}
+	aggregators := map[string]signatureAggregator{}
+	dataset := SyntheticDataset{Truth: map[signatureKey]string{}}
+	var hash uint64
+	for person := 0; person < opts.People; person++ {
+		label := fmt.Sprintf("person-%d", person)
+		isBot := rng.Float64() < opts.BotShare
+		var first, last, name string
+		if isBot {
+			name = syntheticBotNames[intn(len(syntheticBotNames))]
+		} else if rng.Float64() < opts.PopularNameShare {
+			name = syntheticPopularNames[intn(len(syntheticPopularNames))]
+		} else {
+			first = syntheticFirstNames[intn(len(syntheticFirstNames))]
+			if rng.Float64() < 0.5 {
+				last = syntheticLastNames[intn(len(syntheticLastNames))]
+			} else {
+				// the rare last names make most of the persons distinguishable
+				for i := 2 + intn(2); i > 0; i-- {
+					last += syntheticSyllables[intn(len(syntheticSyllables))]
+				}
+			}
+			name = first + " " + last
+		}
+		if first == "" {
+			parts := strings.SplitN(name, " ", 2)
+			first, last = parts[0], parts[1]
+		}
+		emails := make([]string, 1+intn(opts.MaxEmails))
+		for i := range emails {
+			domain := syntheticDomains[intn(len(syntheticDomains))]
+			var local string
+			switch intn(3) {
+			case 0:
+				local = first + "." + last
+			case 1:
+				local = first[:1] + last
+			default:
+				local = first + last
+			}
+			emails[i] = uniqueEmail(local, domain)
+		}
+		repos := make([]string, 1+intn(3))
+		for i := range repos {
+			repos[i] = fmt.Sprintf("repo-%d", intn(opts.Repositories))
+		}
+		zone := time.FixedZone("", (intn(24)-11)*3600)
+		hour := intn(24)
+		commits := 1 + intn(opts.MaxCommits)
+		for commit := 0; commit < commits; commit++ {
+			signatureName := name
+			if !isBot && rng.Float64() < opts.Noise {
+				signatureName = syntheticNameVariant(rng, first, last)
+			}
+			when := start.Add(time.Duration(intn(365*24)) * time.Hour).In(zone)
+			if !isBot {
+				// humans commit at the similar hours of the day, bots around the clock
+				when = when.Add(time.Duration((hour-when.Hour()+24)%24+intn(3)) * time.Hour)
+			}
+			hash++
+			repo := repos[intn(len(repos))]
+			signature := Signature{
+				Repo:     repo,
+				Name:     signatureName,
+				Email:    emails[intn(len(emails))],
+				Hash:     fmt.Sprintf("%040x", hash),
+				Time:     when,
+				Source:   SourceGit,
+				Role:     RoleAuthor,
+				Activity: newActivity(when),
+			}
+			if aggregators[repo] == nil {
+				aggregators[repo] = signatureAggregator{}
+			}
+			aggregators[repo].add(signature)
+			dataset.Truth[signatureKey{name: signature.Name, email: signature.Email}] = label
+		}
+	}
+	repos := make([]string, 0, len(aggregators))
+	for repo := range aggregators {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		dataset.Signatures = append(dataset.Signatures, aggregators[repo].signatures()...)
+	}
+	return dataset
+}
+
+// syntheticNameVariant returns a noisy variant of the person's name.
+func syntheticNameVariant(rng *rand.Rand, first, last string) string {
+	switch rng.Intn(4) {
+	case 0:
+		runes := []rune(first + " " + last)
+		pos := rng.Intn(len(runes))
+		if runes[pos] == ' ' {
+			return first + last
+		}
+		return string(append(runes[:pos:pos], runes[pos+1:]...))
+	case 1:
+		return last + " " + first
+	case 2:
+		return first[:1] + " " + last
+	default:
+		return first
+	}
+}
+
+// Write stores the signatures in the cache CSV file at cachePath which is read by FindPeople and
+// the ground truth CSV file at truthPath which is read by ReadGroundTruth.
+func (d SyntheticDataset) Write(cachePath, truthPath string) (err error) {
+	if err = storeSignaturesOnDisk(cachePath, d.Signatures); err != nil {
+		return
+	}
+	var file *os.File
+	file, err = os.Create(truthPath)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	if err = writer.Write([]string{"name", "email", "identity"}); err != nil {
+		return
+	}
+	keys := make([]signatureKey, 0, len(d.Truth))
+	for key := range d.Truth {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].email != keys[j].email {
+			return keys[i].email < keys[j].email
+		}
+		return keys[i].name < keys[j].name
+	})
+	for _, key := range keys {
+		if err = writer.Write([]string{key.name, key.email, d.Truth[key]}); err != nil {
+			return
+		}
+	}
+	return
+}
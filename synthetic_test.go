@@ -0,0 +1,145 @@
+package idmatch
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newSyntheticTestOptions() SyntheticOptions {
+	opts := NewSyntheticOptions()
+	opts.People = 100
+	opts.Repositories = 10
+	opts.Noise = 0.2
+	opts.PopularNameShare = 0.1
+	opts.BotShare = 0.05
+	opts.Seed = 7
+	return opts
+}
+
+func TestGenerateSyntheticDataset(t *testing.T) {
+	req := require.New(t)
+	opts := newSyntheticTestOptions()
+	dataset := GenerateSyntheticDataset(opts)
+	req.Equal(dataset, GenerateSyntheticDataset(opts))
+	req.NotEmpty(dataset.Signatures)
+	labels := map[string]struct{}{}
+	for _, signature := range dataset.Signatures {
+		label, exists := dataset.Truth[signatureKey{name: signature.Name, email: signature.Email}]
+		req.True(exists, signature.String())
+		labels[label] = struct{}{}
+		name, err := cleanName(signature.Name)
+		req.NoError(err)
+		req.Equal(name, signature.Name)
+		req.NotNil(signature.Activity)
+	}
+	req.Len(labels, opts.People)
+	opts.Seed++
+	req.NotEqual(dataset, GenerateSyntheticDataset(opts))
+}
+
+func TestSyntheticDatasetWrite(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-synthetic")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	dataset := GenerateSyntheticDataset(newSyntheticTestOptions())
+	cachePath, truthPath := filepath.Join(dir, "cache.csv"), filepath.Join(dir, "truth.csv")
+	req.NoError(dataset.Write(cachePath, truthPath))
+	signatures, err := readSignaturesFromDisk(nil, cachePath)
+	req.NoError(err)
+	req.Len(signatures, len(dataset.Signatures))
+	truth, err := ReadGroundTruth(truthPath)
+	req.NoError(err)
+	req.Equal(dataset.Truth, truth)
+
+	people, err := newPeople(nil, signatures, newTestBlacklist(t))
+	req.NoError(err)
+	req.NoError(ReducePeople(context.Background(), people, nil, newTestBlacklist(t),
+		ReduceOptions{MaxIdentities: 20}))
+	metrics := Evaluate(people, truth)
+	req.Equal(0, metrics.Missing)
+	req.True(metrics.PairwiseRecall > 0.5, metrics.String())
+	req.True(metrics.BCubedPrecision > 0.5, metrics.String())
+}
+
+func BenchmarkReducePeople(b *testing.B) {
+	opts := NewSyntheticOptions()
+	opts.People = 10000
+	opts.Repositories = 1000
+	dataset := GenerateSyntheticDataset(opts)
+	blacklist, err := LoadBlacklist()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		people, err := newPeople(nil, dataset.Signatures, blacklist)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		err = ReducePeople(context.Background(), people, nil, blacklist, ReduceOptions{MaxIdentities: 20})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if i == 0 {
+			b.Log("\n" + Evaluate(people, dataset.Truth).String())
+		}
+	}
+}
+
+// benchmarkScales are the numbers of the signatures in the scalability benchmarks.
+var benchmarkScales = []struct {
+	name string
+	size int
+}{{"1M", 1000000}, {"10M", 10000000}}
+
+// benchmarkSignaturesCache keeps the generated signatures between the rounds of the benchmarks.
+var benchmarkSignaturesCache = map[int][]Signature{}
+
+// benchmarkSignatures returns size signatures made of the copies of a synthetic dataset with
+// the distinct emails and repositories of each copy.
+func benchmarkSignatures(size int) []Signature {
+	if signatures, exists := benchmarkSignaturesCache[size]; exists {
+		return signatures
+	}
+	opts := NewSyntheticOptions()
+	opts.People = 10000
+	opts.Repositories = 1000
+	base := GenerateSyntheticDataset(opts).Signatures
+	result := make([]Signature, size)
+	for i := range result {
+		signature := base[i%len(base)]
+		if copyIndex := i / len(base); copyIndex > 0 {
+			signature.Repo = fmt.Sprintf("%s-%d", signature.Repo, copyIndex)
+			signature.Email = fmt.Sprintf("%d.%s", copyIndex, signature.Email)
+		}
+		result[i] = signature
+	}
+	benchmarkSignaturesCache[size] = result
+	return result
+}
+
+// runScaleBenchmarks runs the benchmark for each of benchmarkScales. The largest scale is
+// skipped with -short.
+func runScaleBenchmarks(b *testing.B, run func(b *testing.B, signatures []Signature)) {
+	for _, scale := range benchmarkScales {
+		b.Run(scale.name, func(b *testing.B) {
+			if testing.Short() && scale.size > 1000000 {
+				b.Skip("skipping the largest scale in short mode")
+			}
+			b.StopTimer()
+			signatures := benchmarkSignatures(scale.size)
+			b.ReportAllocs()
+			b.StartTimer()
+			run(b, signatures)
+		})
+	}
+}
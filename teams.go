@@ -0,0 +1,234 @@
+package idmatch
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// Teams map the emails of the people to their teams, e.g. from the LDAP groups. A person
+// belongs to the teams of all their emails.
+type Teams map[string][]string
+
+// ReadTeams loads the teams from a CSV file with the columns "email" and "team". An email may be
+// in several teams, one row each.
+func ReadTeams(path string) (teams Teams, err error) {
+	teams = Teams{}
+	err = readCSVRecords(path, "teams", []string{"email", "team"},
+		func(header map[string]int, record []string) error {
+			email := strings.TrimSpace(normalizeSpaces(strings.ToLower(record[header["email"]])))
+			team := strings.TrimSpace(record[header["team"]])
+			if email == "" || team == "" {
+				return fmt.Errorf("invalid teams file %s: empty email or team in %s",
+					path, strings.Join(record, ","))
+			}
+			teams[email] = append(teams[email], team)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+// PersonTeams returns the sorted teams of the person's emails.
+func (teams Teams) PersonTeams(person *Person) []string {
+	var result []string
+	for _, email := range person.Emails {
+		result = append(result, teams[email]...)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return unique(result)
+}
+
+// TeamSummary is the contribution of a team: its members among the people and their summed
+// activity, see Person.Commits.
+type TeamSummary struct {
+	Team string `json:"team"`
+	// Members are the sorted IDs of the people.
+	Members []int64 `json:"members"`
+	// Repositories are the sorted repositories of the members.
+	Repositories []string  `json:"repositories,omitempty"`
+	Commits      int       `json:"commits"`
+	FirstCommit  time.Time `json:"first_commit"`
+	LastCommit   time.Time `json:"last_commit"`
+}
+
+// add accumulates the member with the activity in the summary.
+func (s *TeamSummary) add(id int64, commits int, first, last time.Time) {
+	s.Members = append(s.Members, id)
+	s.Commits += commits
+	if s.FirstCommit.IsZero() || (!first.IsZero() && first.Before(s.FirstCommit)) {
+		s.FirstCommit = first
+	}
+	if last.After(s.LastCommit) {
+		s.LastCommit = last
+	}
+}
+
+// TeamSummaries rolls the people up to their teams, see Teams.PersonTeams. The people without
+// teams are skipped. The summaries are sorted by the team.
+func (p People) TeamSummaries(teams Teams) []TeamSummary {
+	contributions := map[string]*TeamSummary{}
+	skipped := 0
+	for _, id := range peopleIDs(p) {
+		person := p[id]
+		personTeams := teams.PersonTeams(person)
+		if len(personTeams) == 0 {
+			skipped++
+			continue
+		}
+		for _, team := range personTeams {
+			c := contributions[team]
+			if c == nil {
+				c = &TeamSummary{Team: team}
+				contributions[team] = c
+			}
+			c.add(person.ID, person.Commits, person.FirstCommit, person.LastCommit)
+			c.Repositories = append(c.Repositories, person.Repositories...)
+		}
+	}
+	reporter.Commit("people without teams", skipped)
+	result := make([]TeamSummary, 0, len(contributions))
+	for _, team := range sortedTeams(contributions) {
+		summary := *contributions[team]
+		if len(summary.Repositories) > 0 {
+			summary.Repositories = unique(summary.Repositories)
+		}
+		result = append(result, summary)
+	}
+	return result
+}
+
+// RepoTeams is the roll-up of RepoView to the teams of the contributors.
+type RepoTeams struct {
+	Repo string `json:"repo"`
+	// Teams are sorted by the team and keep the members and the activity in the repository.
+	// Repositories are empty.
+	Teams []TeamSummary `json:"teams"`
+}
+
+// RepoTeams answers which teams touched each repository of the views, see People.RepoViews.
+// The contributors without teams are skipped.
+func (teams Teams) RepoTeams(people People, views []RepoView) []RepoTeams {
+	var result []RepoTeams
+	for _, view := range views {
+		contributions := map[string]*TeamSummary{}
+		for _, contributor := range view.Contributors {
+			person := people[contributor.ID]
+			if person == nil {
+				continue
+			}
+			for _, team := range teams.PersonTeams(person) {
+				c := contributions[team]
+				if c == nil {
+					c = &TeamSummary{Team: team}
+					contributions[team] = c
+				}
+				c.add(contributor.ID, contributor.Commits, contributor.FirstCommit,
+					contributor.LastCommit)
+			}
+		}
+		if len(contributions) == 0 {
+			continue
+		}
+		repoTeams := RepoTeams{Repo: view.Repo}
+		for _, team := range sortedTeams(contributions) {
+			repoTeams.Teams = append(repoTeams.Teams, *contributions[team])
+		}
+		result = append(result, repoTeams)
+	}
+	return result
+}
+
+func sortedTeams(contributions map[string]*TeamSummary) []string {
+	keys := make([]string, 0, len(contributions))
+	for key := range contributions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteTeamSummaries saves the summaries as JSON lines.
+func WriteTeamSummaries(path string, summaries []TeamSummary) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	writer := bufio.NewWriter(file)
+	defer func() {
+		errFlush := writer.Flush()
+		if err == nil {
+			err = errFlush
+		}
+	}()
+	encoder := json.NewEncoder(writer)
+	for _, summary := range summaries {
+		if err = encoder.Encode(summary); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// WriteRepoTeams saves the teams of the repositories as a CSV row per repository and team with
+// the columns "repo", "team", "members", "commits", "first_commit" and "last_commit". The members
+// are the IDs joined by "|".
+func WriteRepoTeams(path string, repoTeams []RepoTeams) (err error) {
+	var file io.WriteCloser
+	file, err = CreatePath(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	err = writer.Write([]string{"repo", "team", "members", "commits", "first_commit", "last_commit"})
+	if err != nil {
+		return
+	}
+	for _, repo := range repoTeams {
+		for _, team := range repo.Teams {
+			members := make([]string, len(team.Members))
+			for i, id := range team.Members {
+				members[i] = strconv.FormatInt(id, 10)
+			}
+			err = writer.Write([]string{repo.Repo, team.Team, strings.Join(members, "|"),
+				strconv.Itoa(team.Commits), team.FirstCommit.UTC().Format(time.RFC3339),
+				team.LastCommit.UTC().Format(time.RFC3339)})
+			if err != nil {
+				return
+			}
+		}
+	}
+	return
+}
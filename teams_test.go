@@ -0,0 +1,100 @@
+package idmatch
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTeams(t *testing.T) {
+	req := require.New(t)
+	file, cleanup := tempFile(t, "*.csv")
+	defer cleanup()
+	_, err := file.WriteString("email,team\nBob@Google.com,infra\nbob@google.com,ml\n" +
+		"alice@google.com,ml\n")
+	req.NoError(err)
+	teams, err := ReadTeams(file.Name())
+	req.NoError(err)
+	req.Equal(Teams{"bob@google.com": {"infra", "ml"}, "alice@google.com": {"ml"}}, teams)
+	req.Equal([]string{"infra", "ml"}, teams.PersonTeams(&Person{
+		Emails: []string{"alice@google.com", "bob@google.com"}}))
+	req.Nil(teams.PersonTeams(&Person{Emails: []string{"eve@google.com"}}))
+
+	req.NoError(ioutil.WriteFile(file.Name(), []byte("email\nbob@google.com\n"), 0666))
+	_, err = ReadTeams(file.Name())
+	req.EqualError(err, "invalid teams file "+file.Name()+": no team column")
+}
+
+func TestTeamSummaries(t *testing.T) {
+	req := require.New(t)
+	t1 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	people := People{
+		1: {ID: 1, Emails: []string{"bob@google.com"}, Repositories: []string{"repo1", "repo2"},
+			Commits: 3, FirstCommit: t1, LastCommit: t1},
+		2: {ID: 2, Emails: []string{"alice@google.com"}, Repositories: []string{"repo2"},
+			Commits: 2, FirstCommit: t2, LastCommit: t2},
+		3: {ID: 3, Emails: []string{"eve@google.com"}, Repositories: []string{"repo3"}, Commits: 1},
+	}
+	teams := Teams{"bob@google.com": {"infra", "ml"}, "alice@google.com": {"ml"}}
+	req.Equal([]TeamSummary{
+		{Team: "infra", Members: []int64{1}, Repositories: []string{"repo1", "repo2"}, Commits: 3,
+			FirstCommit: t1, LastCommit: t1},
+		{Team: "ml", Members: []int64{1, 2}, Repositories: []string{"repo1", "repo2"}, Commits: 5,
+			FirstCommit: t1, LastCommit: t2},
+	}, people.TeamSummaries(teams))
+
+	dir, err := ioutil.TempDir("", "idmatch-teams")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := dir + "/teams.json"
+	req.NoError(WriteTeamSummaries(path, people.TeamSummaries(teams)))
+	file, err := os.Open(path)
+	req.NoError(err)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	var teamNames []string
+	for scanner.Scan() {
+		var summary TeamSummary
+		req.NoError(json.Unmarshal(scanner.Bytes(), &summary))
+		teamNames = append(teamNames, summary.Team)
+	}
+	req.Equal([]string{"infra", "ml"}, teamNames)
+}
+
+func TestRepoTeams(t *testing.T) {
+	req := require.New(t)
+	people, signatures := testRepoViewPeople()
+	teams := Teams{"bob@apple.com": {"infra"}, "alice@google.com": {"infra", "ml"}}
+	t1, t2 := signatures[1].Time, signatures[0].Time
+	repoTeams := teams.RepoTeams(people, people.RepoViews(signatures))
+	req.Equal([]RepoTeams{
+		{Repo: "bob/engine", Teams: []TeamSummary{
+			{Team: "infra", Members: []int64{1}, Commits: 1, FirstCommit: t1, LastCommit: t1},
+		}},
+		{Repo: "src-d/engine", Teams: []TeamSummary{
+			{Team: "infra", Members: []int64{1, 2}, Commits: 2, FirstCommit: t1, LastCommit: t2},
+			{Team: "ml", Members: []int64{2}, Commits: 1, FirstCommit: t2, LastCommit: t2},
+		}},
+		{Repo: "src-d/gitbase", Teams: []TeamSummary{
+			{Team: "infra", Members: []int64{1}, Commits: 2, FirstCommit: t1, LastCommit: t2},
+		}},
+	}, repoTeams)
+
+	dir, err := ioutil.TempDir("", "idmatch-teams")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	path := dir + "/repo-teams.csv"
+	req.NoError(WriteRepoTeams(path, repoTeams[1:2]))
+	data, err := ioutil.ReadFile(path)
+	req.NoError(err)
+	req.Equal(`repo,team,members,commits,first_commit,last_commit
+src-d/engine,infra,1|2,2,2019-01-01T00:00:00Z,2019-06-01T00:00:00Z
+src-d/engine,ml,2,1,2019-06-01T00:00:00Z,2019-06-01T00:00:00Z
+`, string(data))
+}
@@ -0,0 +1,59 @@
+package idmatch
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeBoundLayouts are the formats of ParseTimeBound from the most to the least precise.
+var timeBoundLayouts = []string{time.RFC3339, "2006-01-02", "2006-01", "2006"}
+
+// TimeWindow keeps the signatures made since Since inclusive until Until exclusive, e.g.
+// the identity snapshot of a single year. The zero Since or Until leave that side open.
+type TimeWindow struct {
+	Since time.Time
+	Until time.Time
+}
+
+// ParseTimeBound parses the bound of TimeWindow in RFC3339, e.g. "2019-04-01T00:00:00Z", or
+// the UTC date without the time, e.g. "2019-04-01", "2019-04" or "2019", which all mean the start
+// of that period. The empty string is the zero time.
+func ParseTimeBound(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeBoundLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %s: expected RFC3339, YYYY-MM-DD, YYYY-MM or YYYY",
+		value)
+}
+
+// NewTimeWindow parses both bounds with ParseTimeBound and checks that the window is not empty.
+func NewTimeWindow(since, until string) (TimeWindow, error) {
+	var w TimeWindow
+	var err error
+	if w.Since, err = ParseTimeBound(since); err != nil {
+		return TimeWindow{}, err
+	}
+	if w.Until, err = ParseTimeBound(until); err != nil {
+		return TimeWindow{}, err
+	}
+	if !w.Since.IsZero() && !w.Until.IsZero() && !w.Since.Before(w.Until) {
+		return TimeWindow{}, fmt.Errorf("the time window is empty: %s is not before %s",
+			since, until)
+	}
+	return w, nil
+}
+
+// IsZero returns whether the window is open on both sides and keeps all the signatures.
+func (w TimeWindow) IsZero() bool {
+	return w.Since.IsZero() && w.Until.IsZero()
+}
+
+// Contains returns whether the time is within the window.
+func (w TimeWindow) Contains(t time.Time) bool {
+	return (w.Since.IsZero() || !t.Before(w.Since)) && (w.Until.IsZero() || t.Before(w.Until))
+}
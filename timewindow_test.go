@@ -0,0 +1,74 @@
+package idmatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeBound(t *testing.T) {
+	req := require.New(t)
+	for value, expected := range map[string]time.Time{
+		"":                     {},
+		"2019":                 time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		"2019-04":              time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC),
+		"2019-04-15":           time.Date(2019, 4, 15, 0, 0, 0, 0, time.UTC),
+		"2019-04-15T10:00:00Z": time.Date(2019, 4, 15, 10, 0, 0, 0, time.UTC),
+	} {
+		parsed, err := ParseTimeBound(value)
+		req.NoError(err, value)
+		req.True(expected.Equal(parsed), value)
+	}
+	_, err := ParseTimeBound("last year")
+	req.Error(err)
+}
+
+func TestTimeWindow(t *testing.T) {
+	req := require.New(t)
+	w, err := NewTimeWindow("2019", "2020")
+	req.NoError(err)
+	req.False(w.IsZero())
+	req.True(w.Contains(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)))
+	req.True(w.Contains(time.Date(2019, 12, 31, 23, 59, 59, 0, time.UTC)))
+	req.False(w.Contains(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)))
+	req.False(w.Contains(time.Date(2018, 12, 31, 0, 0, 0, 0, time.UTC)))
+
+	w, err = NewTimeWindow("2019", "")
+	req.NoError(err)
+	req.True(w.Contains(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+	w, err = NewTimeWindow("", "")
+	req.NoError(err)
+	req.True(w.IsZero())
+	req.True(w.Contains(time.Time{}))
+
+	_, err = NewTimeWindow("2020", "2019")
+	req.Error(err)
+	_, err = NewTimeWindow("2019", "2019")
+	req.Error(err)
+}
+
+func TestSignatureFilter(t *testing.T) {
+	req := require.New(t)
+	req.Nil(newSignatureFilter(NewExtractionOptions()))
+	repos, err := NewRepoFilter(nil, []string{"repo2"})
+	req.NoError(err)
+	opts := NewExtractionOptions()
+	opts.Repos = repos
+	opts.Window = TimeWindow{Since: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)}
+	old := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	filter := newSignatureFilter(opts)
+	commits, err := filter.apply([]Signature{
+		{Repo: "repo1", Time: recent}, {Repo: "repo2", Time: recent}, {Repo: "repo1", Time: old},
+	}, nil)
+	req.NoError(err)
+	req.Equal([]Signature{{Repo: "repo1", Time: recent}}, commits)
+	req.Equal(1, filter.outsideRepos)
+	req.Equal(1, filter.outsideWindow)
+
+	var empty *signatureFilter
+	commits, err = empty.apply([]Signature{{Repo: "repo2"}}, nil)
+	req.NoError(err)
+	req.Equal([]Signature{{Repo: "repo2"}}, commits)
+}
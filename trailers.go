@@ -0,0 +1,76 @@
+package idmatch
+
+import (
+	"regexp"
+	"strings"
+)
+
+// trailerRegex matches the "<Kind>-by: Name <email>" commit message trailers.
+var trailerRegex = regexp.MustCompile(
+	`(?im)^[ \t]*([a-z]+(?:-[a-z]+)*-by):[ \t]*([^<\r\n]*?)[ \t]*<([^>\r\n]+)>[ \t]*\r?$`)
+
+// trailerRoles maps the lowercase trailer names to the roles of the mentioned people.
+var trailerRoles = map[string]SignatureRole{
+	"co-authored-by": RoleCoAuthor,
+	"signed-off-by":  RoleSignedOffBy,
+	"reviewed-by":    RoleReviewedBy,
+	"tested-by":      RoleTestedBy,
+}
+
+// TrailerRoles lists the roles of the optional trailers which may be extracted with
+// ExtractionOptions.Trailers. The co-authors are always extracted.
+var TrailerRoles = []SignatureRole{RoleSignedOffBy, RoleReviewedBy, RoleTestedBy}
+
+// isTrailerRole checks whether the role is one of TrailerRoles. The signatures with such roles
+// are weak evidence, see Person.TrailerRole.
+func isTrailerRole(role SignatureRole) bool {
+	for _, trailerRole := range TrailerRoles {
+		if role == trailerRole {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrailers returns the signatures mentioned in the commit message trailers: the co-authors
+// and the optional roles. Repo, Hash, Time and Source are copied from the commit.
+func parseTrailers(message string, commit Signature, roles []SignatureRole) []Signature {
+	var result []Signature
+	for _, match := range trailerRegex.FindAllStringSubmatch(message, -1) {
+		role, known := trailerRoles[strings.ToLower(match[1])]
+		if !known {
+			continue
+		}
+		if role != RoleCoAuthor {
+			enabled := false
+			for _, r := range roles {
+				enabled = enabled || r == role
+			}
+			if !enabled {
+				continue
+			}
+		}
+		name, email := strings.TrimSpace(match[2]), strings.TrimSpace(match[3])
+		if name == "" || email == "" {
+			// the cache requires both
+			continue
+		}
+		result = append(result, Signature{
+			Repo:   commit.Repo,
+			Name:   name,
+			Email:  email,
+			Hash:   commit.Hash,
+			Time:   commit.Time,
+			Source: commit.Source,
+			Role:   role,
+		})
+	}
+	return result
+}
+
+// addTrailers adds the people mentioned in the message trailers of the commit to the aggregator.
+func (a signatureAggregator) addTrailers(message string, commit Signature, roles []SignatureRole) {
+	for _, signature := range parseTrailers(message, commit, roles) {
+		a.add(signature)
+	}
+}
@@ -0,0 +1,43 @@
+package idmatch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrailers(t *testing.T) {
+	req := require.New(t)
+	day := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := Signature{Repo: "repo1", Hash: "aaa", Time: day, Source: SourceGit}
+	message := "Fix the bug (#42)\r\n\r\n* first try\r\n\r\n" +
+		"Co-authored-by: Alice Smith <alice@google.com>\r\n" +
+		"co-authored-by:bob<bob@google.com>\n" +
+		"Co-Authored-By: <eve@google.com>  \n" +
+		"Signed-off-by: Carol <carol@google.com>\n" +
+		"Reviewed-by: Dan <dan@google.com>\n" +
+		"Suggested-by: Frank <frank@google.com>\n" +
+		"Co-authored-by: nobody\n" +
+		"Mentions Co-authored-by: Dave <dave@google.com> inline\n"
+	req.Equal([]Signature{
+		{"repo1", "Alice Smith", "alice@google.com", "aaa", day, SourceGit, RoleCoAuthor, "", nil, nil},
+		{"repo1", "bob", "bob@google.com", "aaa", day, SourceGit, RoleCoAuthor, "", nil, nil},
+	}, parseTrailers(message, commit, nil))
+	req.Equal([]Signature{
+		{"repo1", "Alice Smith", "alice@google.com", "aaa", day, SourceGit, RoleCoAuthor, "", nil, nil},
+		{"repo1", "bob", "bob@google.com", "aaa", day, SourceGit, RoleCoAuthor, "", nil, nil},
+		{"repo1", "Carol", "carol@google.com", "aaa", day, SourceGit, RoleSignedOffBy, "", nil, nil},
+	}, parseTrailers(message, commit, []SignatureRole{RoleSignedOffBy, RoleTestedBy}))
+	req.Empty(parseTrailers("Fix the bug", commit, TrailerRoles))
+	req.True(isTrailerRole(RoleReviewedBy))
+	req.False(isTrailerRole(RoleCoAuthor))
+
+	aggregator := signatureAggregator{}
+	aggregator.addTrailers(message, commit, nil)
+	commit.Hash = "bbb"
+	aggregator.addTrailers("Co-authored-by: bob <bob@google.com>", commit, nil)
+	signatures := aggregator.signatures()
+	req.Len(signatures, 2)
+	req.Equal("bbb", signatures[1].Hash)
+}
@@ -0,0 +1,213 @@
+package idmatch
+
+import (
+	"encoding/csv"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// TrainingPairKind is the way a training pair was sampled.
+type TrainingPairKind string
+
+const (
+	// TrainingPairEmail is the pair of identities with the same email, the likely positive.
+	TrainingPairEmail TrainingPairKind = "email"
+	// TrainingPairPopularName is the pair of identities with the same popular name and different
+	// emails, the hard negative.
+	TrainingPairPopularName TrainingPairKind = "popular_name"
+)
+
+// TrainingPair is a candidate pair of identities with the features for PairScorer.
+type TrainingPair struct {
+	ID1, ID2 int64
+	Kind     TrainingPairKind
+	// Label is the presumed answer: 1 for TrainingPairEmail and 0 for TrainingPairPopularName.
+	// The users are expected to review it before training.
+	Label    int
+	Features PairFeatures
+}
+
+// TrainingPairOptions configure SampleTrainingPairs.
+type TrainingPairOptions struct {
+	// MaxPairs is the maximum number of the sampled pairs of each kind. 0 means no limit.
+	MaxPairs int
+	// Seed initializes the random sampling so that the same people give the same pairs.
+	Seed int64
+}
+
+// SampleTrainingPairs samples the positive pairs of the identities with the same email and
+// the hard negative pairs of the identities with the same popular name and different emails,
+// and computes their features, see PairFeatures.
+func SampleTrainingPairs(people People, blacklist Blacklist, opts TrainingPairOptions) []TrainingPair {
+	ids := make([]int64, 0, len(people))
+	for id := range people {
+		ids = append(ids, id)
+	}
+	Int64Slice(ids).Sort()
+	byEmail := map[string][]int64{}
+	byPopularName := map[string][]int64{}
+	for _, id := range ids {
+		for _, email := range people[id].Emails {
+			byEmail[email] = append(byEmail[email], id)
+		}
+		for _, name := range people[id].NamesWithRepos {
+			if blacklist.isPopularName(name.Name) {
+				byPopularName[name.Name] = append(byPopularName[name.Name], id)
+			}
+		}
+	}
+	shareEmail := func(id1, id2 int64) bool {
+		for _, email1 := range people[id1].Emails {
+			for _, email2 := range people[id2].Emails {
+				if email1 == email2 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	vectors := repositoryVectors(people)
+	var result []TrainingPair
+	sample := func(groups map[string][]int64, kind TrainingPairKind, label int,
+		accept func(id1, id2 int64) bool) {
+		keys := make([]string, 0, len(groups))
+		for key := range groups {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		seen := map[edgeKey]struct{}{}
+		var pairs []edgeKey
+		for _, key := range keys {
+			group := groups[key]
+			for i, id1 := range group {
+				for _, id2 := range group[i+1:] {
+					pair := newEdgeKey(id1, id2)
+					if _, exists := seen[pair]; exists || id1 == id2 || !accept(id1, id2) {
+						continue
+					}
+					seen[pair] = struct{}{}
+					pairs = append(pairs, pair)
+				}
+			}
+		}
+		rng.Shuffle(len(pairs), func(i, j int) { pairs[i], pairs[j] = pairs[j], pairs[i] })
+		if opts.MaxPairs > 0 && len(pairs) > opts.MaxPairs {
+			pairs = pairs[:opts.MaxPairs]
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			if pairs[i].from != pairs[j].from {
+				return pairs[i].from < pairs[j].from
+			}
+			return pairs[i].to < pairs[j].to
+		})
+		for _, pair := range pairs {
+			result = append(result, TrainingPair{
+				ID1: pair.from, ID2: pair.to, Kind: kind, Label: label,
+				Features: computePairFeatures(people[pair.from], people[pair.to], vectors),
+			})
+		}
+		reporter.Commit("training pairs by "+string(kind), len(pairs))
+	}
+	sample(byEmail, TrainingPairEmail, 1, func(id1, id2 int64) bool { return true })
+	sample(byPopularName, TrainingPairPopularName, 0, func(id1, id2 int64) bool {
+		return !shareEmail(id1, id2)
+	})
+	return result
+}
+
+// trainingPairHeader are the leading columns of the training pairs table which are followed by
+// PairFeatureNames.
+var trainingPairHeader = []string{"id1", "name1", "email1", "id2", "name2", "email2", "kind", "label"}
+
+// parquetTrainingPair is the row of the training pairs parquet table.
+type parquetTrainingPair struct {
+	ID1                  int64   `parquet:"name=id1, type=INT_64"`
+	Name1                string  `parquet:"name=name1, type=UTF8"`
+	Email1               string  `parquet:"name=email1, type=UTF8"`
+	ID2                  int64   `parquet:"name=id2, type=INT_64"`
+	Name2                string  `parquet:"name=name2, type=UTF8"`
+	Email2               string  `parquet:"name=email2, type=UTF8"`
+	Kind                 string  `parquet:"name=kind, type=UTF8"`
+	Label                int32   `parquet:"name=label, type=INT_32"`
+	NameDistance         float64 `parquet:"name=name_distance, type=DOUBLE"`
+	NameTokenOverlap     float64 `parquet:"name=name_token_overlap, type=DOUBLE"`
+	EmailLocalDistance   float64 `parquet:"name=email_local_distance, type=DOUBLE"`
+	SameEmailDomain      float64 `parquet:"name=same_email_domain, type=DOUBLE"`
+	RepositorySimilarity float64 `parquet:"name=repository_similarity, type=DOUBLE"`
+	ActivitySimilarity   float64 `parquet:"name=activity_similarity, type=DOUBLE"`
+}
+
+// namesAndEmails returns the names and the emails of the person joined with "|".
+func namesAndEmails(person *Person) (string, string) {
+	names := make([]string, len(person.NamesWithRepos))
+	for i, name := range person.NamesWithRepos {
+		names[i] = name.Name
+	}
+	return strings.Join(names, "|"), strings.Join(person.Emails, "|")
+}
+
+// WriteTrainingPairs saves the pairs with the names and the emails of the identities to the CSV
+// file or to the parquet file if path ends with ".parquet". The "label" column is meant to be
+// reviewed by the users before training PairScorer.
+func WriteTrainingPairs(path string, people People, pairs []TrainingPair) (err error) {
+	if strings.HasSuffix(path, ".parquet") {
+		pw, cleanup := newParquetWriter(path, new(parquetTrainingPair))
+		defer cleanup()
+		for _, pair := range pairs {
+			name1, email1 := namesAndEmails(people[pair.ID1])
+			name2, email2 := namesAndEmails(people[pair.ID2])
+			f := pair.Features
+			if err = pw.Write(parquetTrainingPair{
+				pair.ID1, name1, email1, pair.ID2, name2, email2, string(pair.Kind), int32(pair.Label),
+				f.NameDistance, f.NameTokenOverlap, f.EmailLocalDistance, f.SameEmailDomain,
+				f.RepositorySimilarity, f.ActivitySimilarity}); err != nil {
+				return
+			}
+		}
+		return
+	}
+	var file *os.File
+	file, err = os.Create(path)
+	if err != nil {
+		return
+	}
+	defer func() {
+		errClose := file.Close()
+		if err == nil {
+			err = errClose
+		}
+	}()
+	writer := csv.NewWriter(file)
+	defer func() {
+		writer.Flush()
+		if err == nil {
+			err = writer.Error()
+		}
+	}()
+	header := append(append([]string{}, trainingPairHeader...), PairFeatureNames...)
+	if err = writer.Write(header); err != nil {
+		return
+	}
+	for _, pair := range pairs {
+		name1, email1 := namesAndEmails(people[pair.ID1])
+		name2, email2 := namesAndEmails(people[pair.ID2])
+		record := []string{
+			strconv.FormatInt(pair.ID1, 10), name1, email1, strconv.FormatInt(pair.ID2, 10), name2, email2,
+			string(pair.Kind), strconv.Itoa(pair.Label),
+		}
+		values := pair.Features.Values()
+		for _, name := range PairFeatureNames {
+			record = append(record, strconv.FormatFloat(values[name], 'g', -1, 64))
+		}
+		if err = writer.Write(record); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,70 @@
+package idmatch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTrainingTestPeople() People {
+	return People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"john", "repo1"}}, Emails: []string{"john@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"john smith", ""}}, Emails: []string{"john@google.com"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"john", "repo2"}}, Emails: []string{"jd@google.com"}},
+		4: {ID: 4, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+		5: {ID: 5, NamesWithRepos: []NameWithRepo{{"john", "repo3"}}, Emails: []string{"john@google.com"}},
+	}
+}
+
+func TestSampleTrainingPairs(t *testing.T) {
+	req := require.New(t)
+	blacklist := newTestBlacklist(t)
+	blacklist.PopularNames = map[string]struct{}{"john": {}}
+	people := newTrainingTestPeople()
+	pairs := SampleTrainingPairs(people, blacklist, TrainingPairOptions{})
+	var kinds []string
+	for _, pair := range pairs {
+		kinds = append(kinds, fmt.Sprintf("%s:%d-%d", pair.Kind, pair.ID1, pair.ID2))
+		if pair.Kind == TrainingPairEmail {
+			req.Equal(1, pair.Label)
+		} else {
+			req.Equal(0, pair.Label)
+		}
+	}
+	req.Equal([]string{"email:1-2", "email:1-5", "email:2-5", "popular_name:1-3", "popular_name:3-5"},
+		kinds)
+	req.Equal(1.0, pairs[3].Features.NameTokenOverlap)
+
+	limited := SampleTrainingPairs(people, blacklist, TrainingPairOptions{MaxPairs: 1, Seed: 7})
+	req.Len(limited, 2)
+	req.Equal(limited, SampleTrainingPairs(people, blacklist, TrainingPairOptions{MaxPairs: 1, Seed: 7}))
+}
+
+func TestWriteTrainingPairs(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-training")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	people := newTrainingTestPeople()
+	pairs := []TrainingPair{{ID1: 1, ID2: 2, Kind: TrainingPairEmail, Label: 1,
+		Features: PairFeatures{NameDistance: 0.5, SameEmailDomain: 1}}}
+
+	path := filepath.Join(dir, "pairs.csv")
+	req.NoError(WriteTrainingPairs(path, people, pairs))
+	data, err := ioutil.ReadFile(path)
+	req.NoError(err)
+	req.Equal("id1,name1,email1,id2,name2,email2,kind,label,name_distance,name_token_overlap,"+
+		"email_local_distance,same_email_domain,repository_similarity,activity_similarity\n"+
+		"1,john,john@google.com,2,john smith,john@google.com,email,1,0.5,0,0,1,0,0\n", string(data))
+
+	path = filepath.Join(dir, "pairs.parquet")
+	req.NoError(WriteTrainingPairs(path, people, pairs))
+	info, err := os.Stat(path)
+	req.NoError(err)
+	req.NotZero(info.Size())
+	req.Error(WriteTrainingPairs(filepath.Join(dir, "missing", "pairs.csv"), people, pairs))
+}
@@ -0,0 +1,66 @@
+package idmatch
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// romanization maps the lower case letters which do not decompose to Latin under NFKD to their
+// Latin spelling. Cyrillic follows the common passport-style romanization, Greek follows ELOT 743.
+var romanization = map[rune]string{
+	// Latin letters without a canonical decomposition
+	'ł': "l", 'đ': "d", 'ø': "o", 'ß': "ss", 'æ': "ae", 'œ': "oe", 'þ': "th", 'ð': "d",
+	'ħ': "h", 'ı': "i", 'ŋ': "ng", 'ŧ': "t", 'ĸ': "k",
+	// Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ж': "zh", 'з': "z",
+	'и': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+	'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh",
+	'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'і': "i", 'є': "ye", 'ґ': "g", 'ў': "u", 'ђ': "dj", 'ј': "j", 'љ': "lj", 'њ': "nj",
+	'ћ': "c", 'џ': "dz", 'ѕ': "dz",
+	// Greek
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o",
+}
+
+// transliterate converts the Latin, Cyrillic and Greek strings to the plain Latin script so that
+// the cross-script variants of the same name become equal, e.g. "Łukasz" and "Lukasz" or "Иван"
+// and "Ivan". The string is decomposed with NFKD, the nonspacing marks are removed and the
+// remaining Cyrillic and Greek letters are romanized. The other scripts are not supported and
+// are left intact: Han needs a dictionary rather than a table, and the romanized Kana and Hangul
+// rarely equal the spelling which the people choose for themselves, so the CJK names never match
+// their Latin variants.
+func transliterate(s string) (string, error) {
+	decomposed, _, err := transform.String(
+		transform.Chain(norm.NFKD, transform.RemoveFunc(isDiacritic)), s)
+	if err != nil {
+		return s, err
+	}
+	var builder strings.Builder
+	for _, r := range decomposed {
+		latin, exists := romanization[unicode.ToLower(r)]
+		if !exists {
+			builder.WriteRune(r)
+			continue
+		}
+		if unicode.IsUpper(r) && latin != "" {
+			first, size := utf8.DecodeRuneInString(latin)
+			builder.WriteRune(unicode.ToUpper(first))
+			latin = latin[size:]
+		}
+		builder.WriteString(latin)
+	}
+	return norm.NFC.String(builder.String()), nil
+}
+
+// isDiacritic is isMn without the Kana voicing marks, which are a part of the letter
+// rather than an accent: "ダ" is not "タ".
+func isDiacritic(r rune) bool {
+	return isMn(r) && r != '\u3099' && r != '\u309a'
+}
@@ -0,0 +1,30 @@
+package idmatch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransliterate(t *testing.T) {
+	require := require.New(t)
+	for _, pair := range [][]string{
+		{"Łukasz", "Lukasz"},
+		{"Иван", "Ivan"},
+		{"Щукин", "Shchukin"},
+		{"Müller", "Muller"},
+		{"Straße", "Strasse"},
+		{"Søren", "Soren"},
+		{"Σωκράτης", "Sokratis"},
+		{"ﬁle", "file"},
+		{"王小明", "王小明"},
+		{"やまだ", "やまだ"},
+		{"パク", "パク"},
+		{"김민준", "김민준"},
+		{"John Smith", "John Smith"},
+	} {
+		result, err := transliterate(pair[0])
+		require.NoError(err)
+		require.Equal(pair[1], result)
+	}
+}
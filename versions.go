@@ -0,0 +1,249 @@
+package idmatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/src-d/identity-matching/reporter"
+)
+
+// PeopleVersion describes a snapshot of the identities in PeopleStore.
+type PeopleVersion struct {
+	// ID is the zero-padded sequence number of the version, e.g. "000042".
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	// People is the number of the identities.
+	People int `json:"people"`
+	// Label is the free text given to PeopleStore.Save, e.g. the command line or the dataset.
+	Label string `json:"label,omitempty"`
+	// ExternalIDProvider is the provider passed to People.WriteToParquet.
+	ExternalIDProvider string `json:"external_id_provider,omitempty"`
+}
+
+const (
+	peopleVersionFile   = "version.json"
+	peopleVersionData   = "identities.parquet"
+	peopleCurrentFile   = "CURRENT"
+	peopleVersionDigits = 6
+)
+
+// PeopleStore keeps every matching run as an immutable snapshot in its own directory, so that
+// a bad identity build can be compared with the previous ones and rolled back. The last saved
+// version is the current one until Rollback chooses another. The snapshots are the parquet files
+// of People.WriteToParquet, and each directory has version.json with PeopleVersion.
+type PeopleStore struct {
+	dir string
+}
+
+// OpenPeopleStore opens the store in the directory, which is created if it does not exist.
+func OpenPeopleStore(dir string) (*PeopleStore, error) {
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, err
+	}
+	return &PeopleStore{dir: dir}, nil
+}
+
+// Path returns the path of the parquet files of the version which ReadIdentitiesFromParquet
+// and the "diff" command accept.
+func (s *PeopleStore) Path(id string) string {
+	return filepath.Join(s.dir, id, peopleVersionData)
+}
+
+// Save writes the people as the new version and makes it current. The version directory is
+// claimed before writing, so the concurrent runs never overwrite each other.
+func (s *PeopleStore) Save(people People, externalIDProvider, label string) (PeopleVersion, error) {
+	versions, err := s.Versions()
+	if err != nil {
+		return PeopleVersion{}, err
+	}
+	next := int64(1)
+	if len(versions) > 0 {
+		last, _ := strconv.ParseInt(versions[len(versions)-1].ID, 10, 64)
+		next = last + 1
+	}
+	var version PeopleVersion
+	for ; ; next++ {
+		version.ID = fmt.Sprintf("%0*d", peopleVersionDigits, next)
+		err = os.Mkdir(filepath.Join(s.dir, version.ID), 0777)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return PeopleVersion{}, err
+		}
+	}
+	version.Created = time.Now().UTC()
+	version.People = len(people)
+	version.Label = label
+	version.ExternalIDProvider = externalIDProvider
+	if err = people.WriteToParquet(s.Path(version.ID), externalIDProvider); err != nil {
+		return PeopleVersion{}, err
+	}
+	// version.json is written last, so that Versions skips the unfinished snapshots
+	data, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return PeopleVersion{}, err
+	}
+	if err = writeFileAtomically(filepath.Join(s.dir, version.ID, peopleVersionFile), data); err != nil {
+		return PeopleVersion{}, err
+	}
+	if err = s.setCurrent(version.ID); err != nil {
+		return PeopleVersion{}, err
+	}
+	reporter.Commit("versioned people", version.People)
+	return version, nil
+}
+
+// Versions returns the complete versions sorted by ID.
+func (s *PeopleStore) Versions() ([]PeopleVersion, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var versions []PeopleVersion
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := strconv.ParseInt(entry.Name(), 10, 64); err != nil {
+			continue
+		}
+		version, err := s.Version(entry.Name())
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		id1, _ := strconv.ParseInt(versions[i].ID, 10, 64)
+		id2, _ := strconv.ParseInt(versions[j].ID, 10, 64)
+		return id1 < id2
+	})
+	return versions, nil
+}
+
+// Version returns the description of the version. The error satisfies os.IsNotExist if
+// the version does not exist or is not complete.
+func (s *PeopleStore) Version(id string) (PeopleVersion, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, id, peopleVersionFile))
+	if err != nil {
+		return PeopleVersion{}, err
+	}
+	var version PeopleVersion
+	if err = json.Unmarshal(data, &version); err != nil {
+		return PeopleVersion{}, fmt.Errorf("version %s: %v", id, err)
+	}
+	return version, nil
+}
+
+// Current returns the current version. The second value is false if the store is empty.
+func (s *PeopleStore) Current() (PeopleVersion, bool, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, peopleCurrentFile))
+	if os.IsNotExist(err) {
+		return PeopleVersion{}, false, nil
+	}
+	if err != nil {
+		return PeopleVersion{}, false, err
+	}
+	version, err := s.Version(string(data))
+	if err != nil {
+		return PeopleVersion{}, false, err
+	}
+	return version, true, nil
+}
+
+// Rollback makes the existing version current. The later versions are kept.
+func (s *PeopleStore) Rollback(id string) error {
+	if _, err := s.Version(id); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("version %s does not exist", id)
+		}
+		return err
+	}
+	return s.setCurrent(id)
+}
+
+// Read returns the people of the version and decrypts their emails if c is not nil, see
+// ReadIdentitiesFromParquet.
+func (s *PeopleStore) Read(id string, c *EmailCipher) (People, error) {
+	if _, err := s.Version(id); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("version %s does not exist", id)
+		}
+		return nil, err
+	}
+	people, _, err := ReadIdentitiesFromParquet(s.Path(id), c)
+	return people, err
+}
+
+// Diff returns the changes of the identities from the old version to the new one, see
+// DiffPeople. c decrypts the emails of both versions if it is not nil.
+func (s *PeopleStore) Diff(oldID, newID string, c *EmailCipher) ([]IdentityChange, error) {
+	oldPeople, err := s.Read(oldID, c)
+	if err != nil {
+		return nil, err
+	}
+	newPeople, err := s.Read(newID, c)
+	if err != nil {
+		return nil, err
+	}
+	return DiffPeople(oldPeople, newPeople), nil
+}
+
+// Erase removes the suppressed emails and names from every version, see EraseIdentities, and
+// updates the number of the identities in version.json because the people may be erased whole.
+// The versions stay immutable otherwise: their IDs, creation times and labels are kept.
+// It returns the number of the removed emails and names in all the versions.
+func (s *PeopleStore) Erase(sup Suppressions, c *EmailCipher) (int, error) {
+	versions, err := s.Versions()
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, version := range versions {
+		erased, err := EraseIdentities(s.Path(version.ID), sup, c)
+		if err != nil {
+			return total, fmt.Errorf("version %s: %v", version.ID, err)
+		}
+		if erased == 0 {
+			continue
+		}
+		total += erased
+		people, _, err := readFromParquet(s.Path(version.ID))
+		if err != nil {
+			return total, fmt.Errorf("version %s: %v", version.ID, err)
+		}
+		version.People = len(people)
+		data, err := json.MarshalIndent(version, "", "  ")
+		if err != nil {
+			return total, err
+		}
+		err = writeFileAtomically(filepath.Join(s.dir, version.ID, peopleVersionFile), data)
+		if err != nil {
+			return total, err
+		}
+	}
+	reporter.Commit("versions erased values", total)
+	return total, nil
+}
+
+func (s *PeopleStore) setCurrent(id string) error {
+	return writeFileAtomically(filepath.Join(s.dir, peopleCurrentFile), []byte(id))
+}
+
+// writeFileAtomically replaces the file with the data, the same as writeCheckpoint.
+func writeFileAtomically(path string, data []byte) error {
+	if err := ioutil.WriteFile(path+".tmp", data, 0666); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
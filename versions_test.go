@@ -0,0 +1,122 @@
+package idmatch
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeopleStore(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-versions")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	store, err := OpenPeopleStore(filepath.Join(dir, "store"))
+	req.NoError(err)
+	versions, err := store.Versions()
+	req.NoError(err)
+	req.Empty(versions)
+	_, exists, err := store.Current()
+	req.NoError(err)
+	req.False(exists)
+
+	oldPeople := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}},
+			Emails: []string{"bob@google.com", "bob@home.org"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+	}
+	v1, err := store.Save(oldPeople, "", "first")
+	req.NoError(err)
+	req.Equal("000001", v1.ID)
+	req.Equal(2, v1.People)
+	req.Equal("first", v1.Label)
+
+	// an unfinished snapshot of a concurrent run is skipped
+	req.NoError(os.Mkdir(filepath.Join(dir, "store", "000002"), 0777))
+	newPeople := People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@home.org"}},
+		3: {ID: 3, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+	}
+	v3, err := store.Save(newPeople, "github", "second")
+	req.NoError(err)
+	req.Equal("000003", v3.ID)
+	versions, err = store.Versions()
+	req.NoError(err)
+	req.Len(versions, 2)
+	req.Equal(v1.ID, versions[0].ID)
+	req.True(v1.Created.Equal(versions[0].Created))
+	req.Equal("github", versions[1].ExternalIDProvider)
+	current, exists, err := store.Current()
+	req.NoError(err)
+	req.True(exists)
+	req.Equal(v3.ID, current.ID)
+
+	people, err := store.Read(v1.ID, nil)
+	req.NoError(err)
+	req.Len(people, 2)
+	req.ElementsMatch([]string{"bob@google.com", "bob@home.org"}, people[1].Emails)
+	_, err = store.Read("000002", nil)
+	req.EqualError(err, "version 000002 does not exist")
+
+	changes, err := store.Diff(v1.ID, v3.ID, nil)
+	req.NoError(err)
+	req.Equal([]IdentityChange{{Kind: IdentitySplit, OldIDs: []int64{1}, NewIDs: []int64{1, 2},
+		Emails: []string{"bob@google.com", "bob@home.org"}}}, changes)
+
+	req.NoError(store.Rollback(v1.ID))
+	current, _, err = store.Current()
+	req.NoError(err)
+	req.Equal(v1.ID, current.ID)
+	req.EqualError(store.Rollback("000002"), "version 000002 does not exist")
+	versions, err = store.Versions()
+	req.NoError(err)
+	req.Len(versions, 2)
+}
+
+func TestPeopleStoreErase(t *testing.T) {
+	req := require.New(t)
+	dir, err := ioutil.TempDir("", "idmatch-versions")
+	req.NoError(err)
+	defer os.RemoveAll(dir)
+	store, err := OpenPeopleStore(dir)
+	req.NoError(err)
+	v1, err := store.Save(People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}},
+			Emails: []string{"bob@google.com", "bob@home.org"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"alice", ""}}, Emails: []string{"alice@google.com"}},
+	}, "", "first")
+	req.NoError(err)
+	v2, err := store.Save(People{
+		1: {ID: 1, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@google.com"}},
+		2: {ID: 2, NamesWithRepos: []NameWithRepo{{"bob", ""}}, Emails: []string{"bob@home.org"}},
+	}, "", "second")
+	req.NoError(err)
+
+	s, err := NewSuppressions("bob@home.org", "bob")
+	req.NoError(err)
+	erased, err := store.Erase(s, nil)
+	req.NoError(err)
+	req.Equal(5, erased)
+
+	people, err := store.Read(v1.ID, nil)
+	req.NoError(err)
+	req.Len(people, 2)
+	req.Equal([]string{"bob@google.com"}, people[1].Emails)
+	req.Empty(people[1].NamesWithRepos)
+	version, err := store.Version(v2.ID)
+	req.NoError(err)
+	req.Equal(1, version.People)
+	req.Equal("second", version.Label)
+	req.True(v2.Created.Equal(version.Created))
+	people, err = store.Read(v2.ID, nil)
+	req.NoError(err)
+	req.Len(people, 1)
+	req.Equal([]string{"bob@google.com"}, people[1].Emails)
+	current, _, err := store.Current()
+	req.NoError(err)
+	req.Equal(v2.ID, current.ID)
+}